@@ -0,0 +1,100 @@
+// Command apikey manages the API keys external systems use for the bot's /api/v1 REST API.
+//
+//	apikey create -name hr-system -scopes leaves:read,leaves:write
+//	apikey list
+//	apikey revoke <key id>
+//
+// It connects to the database configured by MONGODB_URI and MONGODB_DATABASE.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"oktel-bot/internal/config"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/service"
+	"oktel-bot/internal/store"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	cfg := config.Load()
+	db, err := store.NewMongoDB(cfg.MongoURI, cfg.MongoDB)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	defer db.Close(context.Background())
+
+	keyStore, err := store.NewAPIKeyStore(ctx, db)
+	if err != nil {
+		log.Fatalf("Failed to init api key store: %v", err)
+	}
+	keys := service.NewAPIKeyService(keyStore)
+
+	switch os.Args[1] {
+	case "create":
+		fs := flag.NewFlagSet("create", flag.ExitOnError)
+		name := fs.String("name", "", "name of the system using the key (recorded as the source of imported leave)")
+		scopes := fs.String("scopes", "", "comma-separated scopes: "+strings.Join(model.APIScopes, ", "))
+		fs.Parse(os.Args[2:])
+
+		secret, key, err := keys.Create(ctx, *name, strings.Split(*scopes, ","))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Created key %s (%s) with scopes %s\n", key.ID.Hex(), key.Name, strings.Join(key.Scopes, ","))
+		fmt.Printf("\n  %s\n\nStore it now: it cannot be shown again.\n", secret)
+
+	case "list":
+		list, err := keys.List(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tLAST USED\tSTATUS")
+		for _, k := range list {
+			lastUsed, status := "never", "active"
+			if k.LastUsedAt != nil {
+				lastUsed = k.LastUsedAt.Format(time.DateTime)
+			}
+			if k.RevokedAt != nil {
+				status = "revoked " + k.RevokedAt.Format(time.DateOnly)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s…\t%s\t%s\t%s\n", k.ID.Hex(), k.Name, k.Prefix, strings.Join(k.Scopes, ","), lastUsed, status)
+		}
+		tw.Flush()
+
+	case "revoke":
+		if len(os.Args) < 3 {
+			usage()
+		}
+		ok, err := keys.Revoke(ctx, os.Args[2])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			log.Fatalf("No active key with ID %s", os.Args[2])
+		}
+		fmt.Printf("Revoked key %s\n", os.Args[2])
+
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	log.Fatalf("usage: apikey create -name NAME -scopes SCOPES | apikey list | apikey revoke ID\nscopes: %s", strings.Join(model.APIScopes, ", "))
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net/http"
 	"os"
@@ -9,17 +10,29 @@ import (
 	"syscall"
 	"time"
 
+	"oktel-bot/internal/app"
 	"oktel-bot/internal/config"
 	"oktel-bot/internal/handler"
 	"oktel-bot/internal/i18n"
-	"oktel-bot/internal/mattermost"
-	"oktel-bot/internal/scheduler"
-	"oktel-bot/internal/service"
+	"oktel-bot/internal/logging"
+	"oktel-bot/internal/metrics"
 	"oktel-bot/internal/store"
 )
 
+// migrateTimeout bounds schema migrations at startup, including waiting for another
+// replica that is already migrating.
+const migrateTimeout = 15 * time.Minute
+
+// readyTimeout bounds the dependency pings of one /ready call.
+const readyTimeout = 3 * time.Second
+
 func main() {
 	cfg := config.Load()
+	logging.Setup(cfg.LogFormat)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg, os.Args[2:])
+		return
+	}
 	i18n.Init("")
 
 	// Connect to MongoDB
@@ -31,55 +44,53 @@ func main() {
 	defer db.Close(mainCtx)
 
 	// 2 Mattermost clients - one per bot identity
-	attendanceMM := mattermost.NewClient(cfg.MattermostURL, cfg.AttendanceBotToken)
-	budgetMM := mattermost.NewClient(cfg.MattermostURL, cfg.BudgetBotToken)
-	botURL := cfg.BotURL
+	attendanceMM := app.NewMattermostClient(cfg, cfg.AttendanceBotToken)
+	budgetMM := app.NewMattermostClient(cfg, cfg.BudgetBotToken)
+
+	// Schema migrations
+	migrateCtx, cancelMigrate := context.WithTimeout(mainCtx, migrateTimeout)
+	defer cancelMigrate()
+	if err := app.Migrate(migrateCtx, cfg, db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
 
-	// Stores (indexes are created inside each constructor)
+	// Stores, services and routes (indexes are created inside each store constructor)
 	initCtx, cancel := context.WithTimeout(mainCtx, 10*time.Second)
 	defer cancel()
-	attendanceStore, err := store.NewAttendanceStore(initCtx, db)
+	stores, err := store.NewMongoStores(initCtx, db)
 	if err != nil {
-		log.Fatalf("Failed to init attendance store: %v", err)
+		log.Fatalf("Failed to init stores: %v", err)
 	}
-	budgetStore, err := store.NewBudgetStore(initCtx, db)
+	bot, err := app.New(initCtx, cfg, stores, attendanceMM, budgetMM)
 	if err != nil {
-		log.Fatalf("Failed to init budget store: %v", err)
+		log.Fatalf("Failed to init bot: %v", err)
 	}
 
-	// Services
-	attendanceSvc := service.NewAttendanceService(attendanceStore, attendanceMM, botURL)
-	budgetSvc := service.NewBudgetService(budgetStore, budgetMM, botURL)
-
 	// Activity check scheduler
-	var checker *scheduler.ActivityChecker
-	if cfg.ActivityCheckEnabled {
-		checker = scheduler.NewActivityChecker(
-			attendanceStore, attendanceMM, botURL,
-			cfg.ActivityCheckPeriodSec, cfg.ActivityCheckTimeoutSec, cfg.ActivityCheckIntervalSec, cfg.ActivityCheckChannel,
-		)
-		checkerCtx, checkerCancel := context.WithCancel(mainCtx)
-		defer checkerCancel()
-		go checker.Start(checkerCtx)
-		log.Println("Activity check scheduler started")
-	} else {
-		log.Println("Activity check scheduler disabled")
-	}
-
-	// Routes
-	mux := http.NewServeMux()
-	handler.NewAttendanceHandler(attendanceSvc, attendanceMM, botURL, cfg.BlockMobile, checker).RegisterRoutes(mux)
-	handler.NewBudgetHandler(budgetSvc, budgetMM, botURL).RegisterRoutes(mux)
+	checkerCtx, checkerCancel := context.WithCancel(mainCtx)
+	defer checkerCancel()
+	bot.Start(checkerCtx)
 
 	// Health checks
+	mux := bot.Mux
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ok"))
 	})
 	mux.HandleFunc("GET /ready", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("ok"))
+		ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+		defer cancel()
+		status, ok := readiness(ctx, map[string]func(context.Context) error{
+			"mongodb":    db.Ping,
+			"mattermost": attendanceMM.Ping,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
 	})
+	mux.Handle("GET /metrics", metrics.Handler())
 
 	// Start server
 	srv := &http.Server{
@@ -106,3 +117,29 @@ func main() {
 	defer cancel()
 	srv.Shutdown(ctx)
 }
+
+// readiness pings each dependency concurrently and reports "ok" or the error for each, and
+// whether all of them are up.
+func readiness(ctx context.Context, checks map[string]func(context.Context) error) (map[string]string, bool) {
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result, len(checks))
+	for name, check := range checks {
+		go func() { results <- result{name, check(ctx)} }()
+	}
+	status := make(map[string]string, len(checks))
+	ok := true
+	for range checks {
+		res := <-results
+		if res.err != nil {
+			status[res.name] = res.err.Error()
+			ok = false
+			log.Printf("ERROR ready: %s: %v", res.name, res.err)
+		} else {
+			status[res.name] = "ok"
+		}
+	}
+	return status, ok
+}
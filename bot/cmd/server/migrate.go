@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"oktel-bot/internal/config"
+	"oktel-bot/internal/store"
+)
+
+// runMigrate implements the migrate subcommand:
+//
+//	bot-service migrate            apply pending migrations
+//	bot-service migrate -dry-run   log what pending migrations would change
+//	bot-service migrate -status    list applied and pending migrations
+func runMigrate(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report what pending migrations would change without applying them")
+	status := fs.Bool("status", false, "list applied and pending migrations")
+	fs.Parse(args)
+
+	db, err := store.NewMongoDB(cfg.MongoURI, cfg.MongoDB)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer db.Close(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	migrator := store.NewMigrator(db)
+	if *status {
+		applied, err := migrator.Applied(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSTATUS\tDESCRIPTION")
+		for _, a := range applied {
+			fmt.Fprintf(tw, "%d\tapplied %s\t%s\n", a.Version, a.AppliedAt.Format(time.DateTime), a.Description)
+		}
+		for _, p := range pending {
+			fmt.Fprintf(tw, "%d\tpending\t%s\n", p.Version, p.Description)
+		}
+		tw.Flush()
+		return
+	}
+
+	n, err := migrator.Up(ctx, *dryRun)
+	if err != nil {
+		log.Fatalf("Migration failed after %d applied: %v", n, err)
+	}
+	switch {
+	case n == 0:
+		fmt.Println("Database is up to date")
+	case *dryRun:
+		fmt.Printf("%d migrations pending (dry run, nothing changed)\n", n)
+	default:
+		fmt.Printf("Applied %d migrations\n", n)
+	}
+}
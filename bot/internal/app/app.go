@@ -0,0 +1,220 @@
+// Package app wires stores, services and HTTP handlers together. It is shared by the
+// standalone bot service (cmd/server) and the Mattermost server plugin (plugin/).
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"oktel-bot/internal/config"
+	"oktel-bot/internal/handler"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/scheduler"
+	"oktel-bot/internal/service"
+	"oktel-bot/internal/store"
+)
+
+// App is a fully wired bot: its routes plus the background jobs that go with them.
+type App struct {
+	Mux         *http.ServeMux
+	checker     *scheduler.ActivityChecker
+	webhooks    *service.WebhookService
+	retention   *scheduler.RetentionJob
+	settings    *service.TeamSettingsService
+	delegations *service.DelegationService
+	calendar    *service.CalendarService
+	listeners   []*mattermost.Client // clients whose caches follow the websocket
+
+	// Exclusive runs a background job that must run on one server only, until ctx is done.
+	// It runs the job directly by default; the plugin replaces it with one holding a
+	// cluster lock, so another server takes over the job if the holder goes away.
+	Exclusive func(ctx context.Context, name string, job func(ctx context.Context))
+}
+
+// NewMattermostClient creates the client of one bot identity, caching lookups as configured.
+func NewMattermostClient(cfg *config.Config, botToken string) *mattermost.Client {
+	return mattermost.NewClient(cfg.MattermostURL, botToken, mattermost.CacheOptions{
+		TTL:  time.Duration(cfg.MattermostCacheTTLSec) * time.Second,
+		Size: cfg.MattermostCacheSize,
+	})
+}
+
+// Migrate brings the database schema up to date and should run before New. With
+// MigrateOnStart off it only warns about pending migrations, which are then applied with
+// the migrate subcommand.
+func Migrate(ctx context.Context, cfg *config.Config, db *store.MongoDB) error {
+	migrator := store.NewMigrator(db)
+	if !cfg.MigrateOnStart {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			log.Printf("WARNING: %d schema migrations pending; run \"bot-service migrate\"", len(pending))
+		}
+		return nil
+	}
+	n, err := migrator.Up(ctx, false)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("Applied %d schema migrations", n)
+	}
+	return nil
+}
+
+// New builds the bot on stores, MongoDB for the standalone service and the Mattermost
+// database for the plugin. attendanceMM and budgetMM are the clients of the two bot
+// identities; cfg.BotURL is the base URL Mattermost calls back.
+func New(ctx context.Context, cfg *config.Config, stores *store.Stores, attendanceMM, budgetMM *mattermost.Client) (*App, error) {
+	botURL := cfg.BotURL
+
+	officeNetworks, err := service.ParseNetworks(cfg.OfficeNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OFFICE_NETWORKS: %w", err)
+	}
+	webhookSubs, err := service.ParseWebhookSubscriptions(cfg.Webhooks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOKS: %w", err)
+	}
+	leaveAttachments, err := service.ParseLeaveAttachmentPolicy(cfg.LeaveAttachments)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LEAVE_ATTACHMENTS: %w", err)
+	}
+	retentionPolicies, err := service.ParseRetentionPolicies(cfg.RetentionPolicies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RETENTION_POLICIES: %w", err)
+	}
+	timesheetCfg, err := service.ParseTimesheetConfig(cfg.TimesheetConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TIMESHEET_CONFIG: %w", err)
+	}
+
+	// Services
+	teamSettings, err := service.NewTeamSettingsService(ctx, stores.TeamSettings, service.TeamConfig{
+		BlockMobile:          cfg.BlockMobile,
+		RequirePhoto:         true,
+		ActivityCheck:        cfg.ActivityCheckEnabled,
+		ActivityCheckPeriod:  time.Duration(cfg.ActivityCheckPeriodSec) * time.Second,
+		ActivityCheckTimeout: time.Duration(cfg.ActivityCheckTimeoutSec) * time.Second,
+		ActivityCheckChannel: cfg.ActivityCheckChannel,
+		BreakReasons:         model.DefaultBreakReasons,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load team settings: %w", err)
+	}
+	webhookSvc := service.NewWebhookService(stores.Webhook, webhookSubs, cfg.WebhookMaxAttempts,
+		time.Duration(cfg.WebhookTimeoutSec)*time.Second, time.Duration(cfg.WebhookLogDays)*24*time.Hour)
+	delegationSvc := service.NewDelegationService(stores.Delegation, attendanceMM, budgetMM)
+	overtimeCfg := service.OvertimeConfig{
+		WorkdayHours: cfg.WorkdayHours,
+		Holidays:     cfg.Holidays,
+		RateWeekday:  cfg.OvertimeRateWeekday,
+		RateWeekend:  cfg.OvertimeRateWeekend,
+		RateHoliday:  cfg.OvertimeRateHoliday,
+	}
+	timesheetSvc := service.NewTimesheetService(stores.Timesheet, stores.Attendance, teamSettings, overtimeCfg, timesheetCfg, webhookSvc)
+	attendanceSvc := service.NewAttendanceService(stores.Attendance, attendanceMM, botURL, service.PhotoCheckConfig{
+		Enabled:          cfg.PhotoCheckEnabled,
+		RejectDuplicates: cfg.PhotoDuplicateAction == "reject",
+		MaxDistance:      cfg.PhotoDuplicateThreshold,
+		LookbackDays:     cfg.PhotoDuplicateLookback,
+		MaxAge:           time.Duration(cfg.PhotoMaxAgeMin) * time.Minute,
+	}, overtimeCfg, service.WorkModeConfig{
+		// Photo and mobile checks of office days come from the team settings
+		Office: service.ModePolicy{
+			RequireOfficeNetwork: len(officeNetworks) > 0,
+		},
+		Remote: service.ModePolicy{
+			RequirePhoto: cfg.RemoteRequirePhoto,
+			BlockMobile:  cfg.RemoteBlockMobile,
+		},
+		BusinessTrip: service.ModePolicy{
+			RequirePhoto: cfg.BusinessTripRequirePhoto,
+			BlockMobile:  cfg.BusinessTripBlockMobile,
+		},
+		OfficeNetworks: officeNetworks,
+	}, leaveAttachments, delegationSvc, webhookSvc, teamSettings, timesheetSvc)
+	calendarSvc := service.NewCalendarService(stores.Attendance, stores.CalendarFeed, cfg.CalendarSecret, cfg.CalendarURL)
+	budgetSvc := service.NewBudgetService(stores.Budget, budgetMM, botURL, delegationSvc, webhookSvc)
+	retentionSvc := service.NewRetentionService(stores.Retention, attendanceMM, retentionPolicies)
+
+	// Activity check scheduler; teams can turn checks on or off in their settings
+	checker := scheduler.NewActivityChecker(stores.Attendance, attendanceMM, botURL, cfg.ActivityCheckIntervalSec, teamSettings)
+
+	// Data retention job
+	var retentionJob *scheduler.RetentionJob
+	if retentionSvc.Enabled() {
+		retentionJob, err = scheduler.NewRetentionJob(retentionSvc, cfg.RetentionJobTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RETENTION_JOB_TIME: %w", err)
+		}
+	}
+
+	// Routes
+	mux := http.NewServeMux()
+	handler.NewAttendanceHandler(attendanceSvc, delegationSvc, calendarSvc, attendanceMM, botURL, checker).RegisterRoutes(mux)
+	handler.NewBudgetHandler(budgetSvc, budgetMM, botURL).RegisterRoutes(mux)
+	apiKeySvc := service.NewAPIKeyService(stores.APIKey)
+	handler.NewWebhookHandler(webhookSvc, apiKeySvc).RegisterRoutes(mux)
+	handler.NewAPIHandler(attendanceSvc, budgetSvc, timesheetSvc, apiKeySvc).RegisterRoutes(mux)
+	setupSvc := service.NewSetupService(attendanceMM, budgetMM, botURL, cfg.SetupSlashCommands)
+	handler.NewAdminHandler(retentionSvc, setupSvc, teamSettings, timesheetSvc, attendanceMM, botURL).RegisterRoutes(mux)
+
+	a := &App{
+		Mux:         mux,
+		checker:     checker,
+		webhooks:    webhookSvc,
+		retention:   retentionJob,
+		settings:    teamSettings,
+		delegations: delegationSvc,
+		calendar:    calendarSvc,
+		Exclusive:   func(ctx context.Context, _ string, job func(context.Context)) { job(ctx) },
+	}
+	if cfg.MattermostWebsocket && attendanceMM.Caching() {
+		a.listeners = []*mattermost.Client{attendanceMM, budgetMM}
+	}
+	return a, nil
+}
+
+// UserDeactivated revokes the calendar feed URLs issued to a user who was deactivated.
+func (a *App) UserDeactivated(ctx context.Context, userID string) error {
+	return a.calendar.RevokeFeed(ctx, userID)
+}
+
+// Start runs the background jobs until ctx is cancelled.
+func (a *App) Start(ctx context.Context) {
+	go a.Exclusive(ctx, "webhooks", a.webhooks.Run)
+	if a.webhooks.Enabled() {
+		log.Println("Webhook dispatcher started")
+	} else {
+		log.Println("Webhook dispatcher disabled; pruning the delivery log only")
+	}
+
+	if a.retention != nil {
+		go a.Exclusive(ctx, "retention", a.retention.Start)
+		log.Println("Data retention job started")
+	} else {
+		log.Println("Data retention job disabled")
+	}
+
+	go a.settings.Run(ctx)
+
+	for _, mm := range a.listeners {
+		go mm.Listen(ctx)
+	}
+	if len(a.listeners) > 0 {
+		log.Println("Mattermost cache invalidation via websocket started")
+	}
+
+	go a.Exclusive(ctx, "activity_check", a.checker.Start)
+	log.Println("Activity check scheduler started")
+
+	go a.Exclusive(ctx, "delegations", a.delegations.Run)
+	log.Println("Delegation sweeper started")
+}
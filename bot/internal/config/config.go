@@ -9,36 +9,105 @@ import (
 type Config struct {
 	Port                     string
 	Env                      string
+	LogFormat                string // "json" (default) or "text"
 	BotURL                   string
 	MongoURI                 string
 	MongoDB                  string
+	MigrateOnStart           bool // apply pending schema migrations at startup
 	MattermostURL            string
 	AttendanceBotToken       string
 	BudgetBotToken           string
+	SetupSlashCommands       bool // /botadmin setup registers the slash commands (off in the plugin)
+	MattermostCacheTTLSec    int  // how long users, channels and members are cached; 0 disables the cache
+	MattermostCacheSize      int  // entries per cache
+	MattermostWebsocket      bool // keep the caches fresh from the Mattermost websocket
 	BlockMobile              bool
 	ActivityCheckEnabled     bool
 	ActivityCheckPeriodSec   int
 	ActivityCheckTimeoutSec  int
 	ActivityCheckIntervalSec int
 	ActivityCheckChannel     string
+
+	PhotoCheckEnabled       bool
+	PhotoDuplicateAction    string // "flag" or "reject"
+	PhotoDuplicateThreshold int    // max differing hash bits to count as the same photo
+	PhotoDuplicateLookback  int    // days of history to compare against
+	PhotoMaxAgeMin          int    // flag photos captured earlier than this before submission
+
+	WorkdayHours        float64  // scheduled hours per weekday; work beyond this counts as overtime
+	Holidays            []string // YYYY-MM-DD dates paid at the holiday overtime rate
+	OvertimeRateWeekday float64
+	OvertimeRateWeekend float64
+	OvertimeRateHoliday float64
+
+	OfficeNetworks           []string // CIDRs office check-ins must come from; empty disables the check
+	RemoteRequirePhoto       bool
+	RemoteBlockMobile        bool
+	BusinessTripRequirePhoto bool
+	BusinessTripBlockMobile  bool
+
+	LeaveAttachments string // type=mode pairs (none, optional, required) for supporting documents
+
+	CalendarSecret string // signs iCalendar feed URLs; empty disables the feed
+	CalendarURL    string // Mattermost route of the plugin, used in feed links; set by the plugin only, as the standalone bot is internal
+
+	Webhooks           string // JSON array of outbound webhook subscriptions; empty disables them
+	WebhookMaxAttempts int
+	WebhookTimeoutSec  int
+	WebhookLogDays     int // days delivered and failed deliveries are kept; 0 keeps them forever
+
+	RetentionPolicies string // JSON array of data retention policies; empty keeps everything
+	RetentionJobTime  string // HH:MM (UTC+7) the daily retention job starts
+
+	TimesheetConfig string // JSON payroll timesheet rules; empty uses the defaults
 }
 
 func Load() *Config {
 	return &Config{
 		Port:                     getEnv("PORT", "3000"),
 		Env:                      getEnv("ENV", "development"),
+		LogFormat:                getEnv("LOG_FORMAT", "json"),
 		BotURL:                   getEnv("BOT_URL", "http://bot-service:3000"),
 		MongoURI:                 getEnv("MONGODB_URI", "mongodb://localhost:27017"),
 		MongoDB:                  getEnv("MONGODB_DATABASE", "oktel"),
+		MigrateOnStart:           getEnv("MIGRATE_ON_START", "true") == "true",
 		MattermostURL:            strings.TrimRight(getEnv("MATTERMOST_URL", "http://localhost:8065"), "/"),
 		AttendanceBotToken:       getEnv("ATTENDANCE_BOT_TOKEN", ""),
 		BudgetBotToken:           getEnv("BUDGET_BOT_TOKEN", ""),
+		SetupSlashCommands:       getEnv("SETUP_SLASH_COMMANDS", "true") == "true",
+		MattermostCacheTTLSec:    getEnvInt("MATTERMOST_CACHE_TTL", 300),
+		MattermostCacheSize:      getEnvInt("MATTERMOST_CACHE_SIZE", 5000),
+		MattermostWebsocket:      getEnv("MATTERMOST_WEBSOCKET", "true") == "true",
 		BlockMobile:              getEnv("ATTENDANCE_BLOCK_MOBILE", "true") == "true",
 		ActivityCheckEnabled:     getEnv("ACTIVITY_CHECK_ENABLED", "false") == "true",
 		ActivityCheckPeriodSec:   getEnvInt("ACTIVITY_CHECK_PERIOD", 3600),
 		ActivityCheckTimeoutSec:  getEnvInt("ACTIVITY_CHECK_TIMEOUT", 10),
 		ActivityCheckIntervalSec: getEnvInt("ACTIVITY_CHECK_INTERVAL", 300),
 		ActivityCheckChannel:     getEnv("ACTIVITY_CHECK_CHANNEL", "attendance-oa"),
+		PhotoCheckEnabled:        getEnv("PHOTO_CHECK_ENABLED", "true") == "true",
+		PhotoDuplicateAction:     getEnv("PHOTO_DUPLICATE_ACTION", "flag"),
+		PhotoDuplicateThreshold:  getEnvInt("PHOTO_DUPLICATE_THRESHOLD", 6),
+		PhotoDuplicateLookback:   getEnvInt("PHOTO_DUPLICATE_LOOKBACK_DAYS", 30),
+		PhotoMaxAgeMin:           getEnvInt("PHOTO_MAX_AGE_MINUTES", 60),
+		WorkdayHours:             getEnvFloat("WORKDAY_HOURS", 8),
+		Holidays:                 getEnvList("HOLIDAYS"),
+		OvertimeRateWeekday:      getEnvFloat("OVERTIME_RATE_WEEKDAY", 1.5),
+		OvertimeRateWeekend:      getEnvFloat("OVERTIME_RATE_WEEKEND", 2.0),
+		OvertimeRateHoliday:      getEnvFloat("OVERTIME_RATE_HOLIDAY", 3.0),
+		OfficeNetworks:           getEnvList("OFFICE_NETWORKS"),
+		RemoteRequirePhoto:       getEnv("REMOTE_REQUIRE_PHOTO", "true") == "true",
+		RemoteBlockMobile:        getEnv("REMOTE_BLOCK_MOBILE", "false") == "true",
+		BusinessTripRequirePhoto: getEnv("BUSINESS_TRIP_REQUIRE_PHOTO", "false") == "true",
+		BusinessTripBlockMobile:  getEnv("BUSINESS_TRIP_BLOCK_MOBILE", "false") == "true",
+		LeaveAttachments:         getEnv("LEAVE_ATTACHMENTS", "off=optional,late_arrival=optional,early_departure=optional"),
+		CalendarSecret:           getEnv("CALENDAR_SECRET", ""),
+		Webhooks:                 getEnv("WEBHOOKS", ""),
+		WebhookMaxAttempts:       getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookTimeoutSec:        getEnvInt("WEBHOOK_TIMEOUT", 10),
+		WebhookLogDays:           getEnvInt("WEBHOOK_LOG_DAYS", 30),
+		RetentionPolicies:        getEnv("RETENTION_POLICIES", ""),
+		RetentionJobTime:         getEnv("RETENTION_JOB_TIME", "02:00"),
+		TimesheetConfig:          getEnv("TIMESHEET_CONFIG", ""),
 	}
 }
 
@@ -57,3 +126,23 @@ func getEnvInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+// getEnvList splits a comma-separated variable, dropping empty entries.
+func getEnvList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
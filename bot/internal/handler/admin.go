@@ -0,0 +1,730 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/service"
+)
+
+// AdminHandler serves /botadmin, the bot's administration command:
+//
+//	/botadmin export [@user]                       DM the caller a JSON file of everything the bot stores about a user
+//	/botadmin erase @user                          delete everything the bot stores about a user (offboarding)
+//	/botadmin setup attendance <team>              create the team's attendance channels and commands
+//	/botadmin setup budget <suffix> [partners...]  create the budget channels and command
+//	/botadmin verify attendance|budget ...         report drift from what setup would create
+//	/botadmin config [get|set <key> <value>]       edit the team's settings (a dialog without arguments)
+//	/botadmin breaks [add|edit <id>|remove <id>|reset]  list or edit the team's break reasons
+//	/botadmin timesheet <YYYY-MM> [csv|xlsx]       DM the caller the team's payroll timesheet
+//	/botadmin timesheet lock <YYYY-MM>             freeze a closed month (or close its correction)
+//	/botadmin timesheet correct <YYYY-MM> <reason> reopen a locked month for edits
+//
+// Anyone may export their own data; everything else requires the system admin role.
+type AdminHandler struct {
+	retention  *service.RetentionService
+	setup      *service.SetupService
+	settings   *service.TeamSettingsService
+	timesheets *service.TimesheetService
+	mm         *mattermost.Client
+	botURL     string
+}
+
+func NewAdminHandler(retention *service.RetentionService, setup *service.SetupService, settings *service.TeamSettingsService, timesheets *service.TimesheetService, mm *mattermost.Client, botURL string) *AdminHandler {
+	return &AdminHandler{retention: retention, setup: setup, settings: settings, timesheets: timesheets, mm: mm, botURL: botURL}
+}
+
+// caller fetches the user running a command and returns a context with their locale.
+// The user is not read from the cache, so a revoked system admin role takes effect at once.
+func (h *AdminHandler) caller(ctx context.Context, userID string) (context.Context, *mattermost.UserInfo, error) {
+	user, err := h.mm.GetUserFresh(userID)
+	if err != nil {
+		return ctx, nil, err
+	}
+	if user.Locale != "" {
+		ctx = i18n.WithLocale(ctx, user.Locale)
+	}
+	return ctx, user, nil
+}
+
+func ephemeral(w http.ResponseWriter, text string) {
+	writeJSON(w, SlashResponse{ResponseType: "ephemeral", Text: text})
+}
+
+// HandleBotAdmin handles the /botadmin slash command.
+func (h *AdminHandler) HandleBotAdmin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx, caller, err := h.caller(r.Context(), r.FormValue("user_id"))
+	if err != nil {
+		log.Printf("ERROR botadmin: get caller: %v", err)
+		http.Error(w, "unknown user", http.StatusBadRequest)
+		return
+	}
+
+	args := strings.Fields(r.FormValue("text"))
+	if len(args) == 0 {
+		ephemeral(w, i18n.T(ctx, "admin.usage"))
+		return
+	}
+	switch args[0] {
+	case "export":
+		h.export(ctx, w, caller, args[1:])
+	case "erase":
+		h.confirmErase(ctx, w, caller, args[1:])
+	case "setup", "verify":
+		h.runSetup(ctx, w, caller, r.FormValue("team_id"), args[0] == "setup", args[1:])
+	case "config":
+		h.config(ctx, w, caller, r.FormValue("team_id"), r.FormValue("trigger_id"), args[1:])
+	case "breaks":
+		h.breaks(ctx, w, caller, r.FormValue("team_id"), r.FormValue("trigger_id"), args[1:])
+	case "timesheet":
+		h.timesheet(ctx, w, caller, r.FormValue("team_id"), args[1:])
+	default:
+		ephemeral(w, i18n.T(ctx, "admin.usage"))
+	}
+}
+
+// targetUser resolves the optional @username argument, defaulting to the caller.
+func (h *AdminHandler) targetUser(caller *mattermost.UserInfo, args []string) (*mattermost.UserInfo, string, error) {
+	if len(args) == 0 {
+		return caller, caller.Username, nil
+	}
+	username := strings.TrimPrefix(args[0], "@")
+	user, err := h.mm.GetUserByUsername(username)
+	return user, username, err
+}
+
+func (h *AdminHandler) export(ctx context.Context, w http.ResponseWriter, caller *mattermost.UserInfo, args []string) {
+	target, username, err := h.targetUser(caller, args)
+	if err != nil {
+		ephemeral(w, i18n.T(ctx, "admin.err.unknown_user", map[string]any{"Username": username}))
+		return
+	}
+	if target.ID != caller.ID && !caller.IsSystemAdmin() {
+		ephemeral(w, i18n.T(ctx, "admin.err.not_admin"))
+		return
+	}
+
+	if err := h.sendExport(ctx, caller, target); err != nil {
+		log.Printf("ERROR botadmin: export %s: %v", target.Username, err)
+		ephemeral(w, i18n.T(ctx, "admin.err.export_failed"))
+		return
+	}
+	ephemeral(w, i18n.T(ctx, "admin.export.sent", map[string]any{"Username": target.Username}))
+}
+
+// sendExport DMs the caller the target user's data as a JSON file.
+func (h *AdminHandler) sendExport(ctx context.Context, caller, target *mattermost.UserInfo) error {
+	name, data, err := h.retention.Export(ctx, target)
+	if err != nil {
+		return err
+	}
+	channelID, err := h.mm.DMChannelID(caller.ID)
+	if err != nil {
+		return err
+	}
+	fileID, err := h.mm.UploadFile(channelID, name, data)
+	if err != nil {
+		return err
+	}
+	_, err = h.mm.CreatePost(&mattermost.Post{
+		ChannelID: channelID,
+		Message:   i18n.T(ctx, "admin.export.message", map[string]any{"Username": target.Username}),
+		FileIds:   []string{fileID},
+	})
+	return err
+}
+
+// confirmErase asks the admin to confirm erasing a user's data.
+func (h *AdminHandler) confirmErase(ctx context.Context, w http.ResponseWriter, caller *mattermost.UserInfo, args []string) {
+	if !caller.IsSystemAdmin() {
+		ephemeral(w, i18n.T(ctx, "admin.err.not_admin"))
+		return
+	}
+	if len(args) == 0 {
+		ephemeral(w, i18n.T(ctx, "admin.usage"))
+		return
+	}
+	target, username, err := h.targetUser(caller, args)
+	if err != nil {
+		ephemeral(w, i18n.T(ctx, "admin.err.unknown_user", map[string]any{"Username": username}))
+		return
+	}
+
+	writeJSON(w, SlashResponse{
+		ResponseType: "ephemeral",
+		Attachments: []mattermost.Attachment{{
+			Text:  i18n.T(ctx, "admin.erase.confirm", map[string]any{"Username": target.Username}),
+			Color: "#d24b4e",
+			Actions: []mattermost.Action{{
+				Name: i18n.T(ctx, "admin.btn.erase"),
+				Type: "button",
+				Integration: mattermost.Integration{
+					URL:     h.botURL + "/api/botadmin/erase",
+					Context: map[string]any{"user_id": target.ID, "username": target.Username},
+				},
+			}},
+		}},
+	})
+}
+
+// HandleErase erases a user's data once the admin confirms.
+func (h *AdminHandler) HandleErase(w http.ResponseWriter, r *http.Request) {
+	var req ActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx, caller, err := h.caller(r.Context(), req.UserID)
+	if err != nil || !caller.IsSystemAdmin() {
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "admin.err.not_admin")})
+		return
+	}
+	userID, _ := req.Context["user_id"].(string)
+	username, _ := req.Context["username"].(string)
+	if userID == "" {
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.missing_id")})
+		return
+	}
+
+	result, err := h.retention.Erase(ctx, userID)
+	var text string
+	if err != nil {
+		log.Printf("ERROR botadmin: erase %s: %v", username, err)
+		text = i18n.T(ctx, "admin.err.erase_failed", map[string]any{"Username": username, "Error": err.Error()})
+	} else {
+		log.Printf("botadmin: %s erased the data of %s (%s)", caller.Username, username, userID)
+		text = i18n.T(ctx, "admin.erase.done", map[string]any{
+			"Username":      username,
+			"Attendance":    result.Attendance,
+			"Photos":        result.Photos,
+			"Leave":         result.Leave,
+			"CompTime":      result.CompTime,
+			"Delegations":   result.Delegations,
+			"Summaries":     result.Summaries,
+			"Webhooks":      result.Webhooks,
+			"CalendarFeeds": result.CalendarFeeds,
+			"Timesheets":    result.Timesheets,
+			"Budgets":       result.Budgets,
+		})
+	}
+	writeJSON(w, ActionResponse{
+		Update: &ActionUpdate{
+			Message: text,
+			Props:   &mattermost.Props{Attachments: []mattermost.Attachment{}},
+		},
+	})
+}
+
+// runSetup runs /botadmin setup or verify for attendance or budget in the caller's team.
+func (h *AdminHandler) runSetup(ctx context.Context, w http.ResponseWriter, caller *mattermost.UserInfo, teamID string, fix bool, args []string) {
+	if !caller.IsSystemAdmin() {
+		ephemeral(w, i18n.T(ctx, "admin.err.not_admin"))
+		return
+	}
+	if len(args) < 2 {
+		ephemeral(w, i18n.T(ctx, "admin.usage"))
+		return
+	}
+
+	var checks []service.SetupCheck
+	var err error
+	switch {
+	case args[0] == "attendance" && len(args) == 2 && fix:
+		checks, err = h.setup.SetupAttendance(ctx, teamID, args[1])
+	case args[0] == "attendance" && len(args) == 2:
+		checks, err = h.setup.VerifyAttendance(ctx, teamID, args[1])
+	case args[0] == "budget" && fix:
+		checks, err = h.setup.SetupBudget(ctx, teamID, args[1], args[2:])
+	case args[0] == "budget":
+		checks, err = h.setup.VerifyBudget(ctx, teamID, args[1], args[2:])
+	default:
+		ephemeral(w, i18n.T(ctx, "admin.usage"))
+		return
+	}
+	if errors.Is(err, service.ErrInvalidSetupName) {
+		ephemeral(w, i18n.T(ctx, "admin.err.invalid_name"))
+		return
+	}
+
+	target := strings.Join(args, " ")
+	if fix {
+		log.Printf("botadmin: %s ran setup %s", caller.Username, target)
+	}
+	ephemeral(w, h.setupReport(ctx, target, fix, checks))
+}
+
+// setupReport renders one line per channel or command: what setup did, the drift left and
+// any error.
+func (h *AdminHandler) setupReport(ctx context.Context, target string, fix bool, checks []service.SetupCheck) string {
+	var b strings.Builder
+	if fix {
+		b.WriteString(i18n.T(ctx, "admin.setup.report", map[string]any{"Target": target}))
+	} else {
+		b.WriteString(i18n.T(ctx, "admin.verify.report", map[string]any{"Target": target}))
+	}
+	clean := true
+	for _, c := range checks {
+		icon := "✅"
+		var items []string
+		for _, key := range c.Done {
+			items = append(items, i18n.T(ctx, key))
+		}
+		if len(c.Problems) > 0 {
+			icon = "⚠️"
+			for _, key := range c.Problems {
+				items = append(items, i18n.T(ctx, key))
+			}
+		}
+		if c.Err != nil {
+			icon = "❌"
+			items = append(items, i18n.T(ctx, "admin.setup.error", map[string]any{"Error": c.Err.Error()}))
+		}
+		if len(items) == 0 {
+			items = append(items, i18n.T(ctx, "admin.setup.ok"))
+		}
+		clean = clean && c.OK()
+		fmt.Fprintf(&b, "\n%s `%s`: %s", icon, c.Name, strings.Join(items, ", "))
+	}
+	if !fix && clean {
+		b.WriteString("\n\n" + i18n.T(ctx, "admin.verify.clean"))
+	}
+	return b.String()
+}
+
+// config runs /botadmin config for the caller's team.
+func (h *AdminHandler) config(ctx context.Context, w http.ResponseWriter, caller *mattermost.UserInfo, teamID, triggerID string, args []string) {
+	if !caller.IsSystemAdmin() {
+		ephemeral(w, i18n.T(ctx, "admin.err.not_admin"))
+		return
+	}
+	switch {
+	case len(args) == 0:
+		if err := h.openConfigDialog(ctx, teamID, triggerID); err != nil {
+			log.Printf("ERROR botadmin: open config dialog: %v", err)
+			ephemeral(w, i18n.T(ctx, "attendance.err.open_form"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case args[0] == "get":
+		ephemeral(w, h.configTable(ctx, teamID))
+	case args[0] == "set" && len(args) >= 3:
+		key, value := args[1], strings.Join(args[2:], " ")
+		if err := h.settings.Set(ctx, teamID, key, value, caller.ID); err != nil {
+			ephemeral(w, err.Error())
+			return
+		}
+		log.Printf("botadmin: %s set %s=%q for team %s", caller.Username, key, value, teamID)
+		value, _ = h.settings.SettingValue(teamID, key)
+		ephemeral(w, i18n.T(ctx, "admin.config.saved", map[string]any{"Key": key, "Value": value}))
+	default:
+		ephemeral(w, i18n.T(ctx, "admin.usage"))
+	}
+}
+
+// configTable renders a team's effective settings, marking those not overridden.
+func (h *AdminHandler) configTable(ctx context.Context, teamID string) string {
+	var b strings.Builder
+	b.WriteString(i18n.T(ctx, "admin.config.header"))
+	b.WriteString("\n\n| " + i18n.T(ctx, "admin.config.col_setting") + " | " + i18n.T(ctx, "admin.config.col_value") + " |\n|---|---|")
+	for _, key := range service.TeamSettingKeys {
+		value, overridden := h.settings.SettingValue(teamID, key)
+		if value == "" {
+			value = "-"
+		}
+		if !overridden {
+			value += " " + i18n.T(ctx, "admin.config.default_marker")
+		}
+		fmt.Fprintf(&b, "\n| `%s` | %s |", key, value)
+	}
+	return b.String()
+}
+
+// openConfigDialog opens the team settings dialog. Every field shows the team's override,
+// with the effective default as placeholder; clearing a field removes the override.
+func (h *AdminHandler) openConfigDialog(ctx context.Context, teamID, triggerID string) error {
+	ts := h.settings.Settings(teamID)
+	overrides := map[string]string{
+		service.SettingActivityCheckChannel: ts.ActivityCheckChannel,
+		service.SettingTimezone:             ts.Timezone,
+		service.SettingLocale:               ts.Locale,
+	}
+	for key, v := range map[string]*bool{
+		service.SettingBlockMobile:   ts.BlockMobile,
+		service.SettingRequirePhoto:  ts.RequirePhoto,
+		service.SettingActivityCheck: ts.ActivityCheck,
+	} {
+		if v != nil {
+			overrides[key] = fmt.Sprint(*v)
+		}
+	}
+	if ts.ActivityCheckPeriod > 0 {
+		overrides[service.SettingActivityCheckPeriod] = fmt.Sprint(ts.ActivityCheckPeriod)
+	}
+	if ts.ActivityCheckTimeout > 0 {
+		overrides[service.SettingActivityCheckTimeout] = fmt.Sprint(ts.ActivityCheckTimeout)
+	}
+
+	var elements []mattermost.DialogElement
+	for _, key := range service.TeamSettingKeys {
+		effective, overridden := h.settings.SettingValue(teamID, key)
+		el := mattermost.DialogElement{
+			DisplayName: i18n.T(ctx, "admin.config.key."+key),
+			Name:        key,
+			Type:        "text",
+			Optional:    true,
+			Default:     overrides[key],
+		}
+		if !overridden {
+			el.Placeholder = effective
+		}
+		switch key {
+		case service.SettingBlockMobile, service.SettingRequirePhoto, service.SettingActivityCheck:
+			el.Type = "select"
+			el.Placeholder = ""
+			el.Options = []mattermost.SelectOption{
+				{Text: i18n.T(ctx, "admin.config.opt.on"), Value: "true"},
+				{Text: i18n.T(ctx, "admin.config.opt.off"), Value: "false"},
+			}
+			if !overridden {
+				el.HelpText = i18n.T(ctx, "admin.config.help.default", map[string]any{"Value": effective})
+			}
+		case service.SettingActivityCheckPeriod, service.SettingActivityCheckTimeout:
+			el.SubType = "number"
+		case service.SettingLocale:
+			el.Type = "select"
+			el.Placeholder = ""
+			for _, locale := range []string{"en", "vi", "zh-CN", "zh-TW"} {
+				el.Options = append(el.Options, mattermost.SelectOption{Text: locale, Value: locale})
+			}
+			el.HelpText = i18n.T(ctx, "admin.config.help.locale")
+		}
+		elements = append(elements, el)
+	}
+
+	return h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: triggerID,
+		URL:       h.botURL + "/api/botadmin/config",
+		Dialog: mattermost.Dialog{
+			Title:       i18n.T(ctx, "admin.config.title"),
+			CallbackID:  teamID,
+			Elements:    elements,
+			SubmitLabel: i18n.T(ctx, "admin.config.submit"),
+		},
+	})
+}
+
+// HandleConfigSubmit saves the team settings dialog.
+func (h *AdminHandler) HandleConfigSubmit(w http.ResponseWriter, r *http.Request) {
+	var sub DialogSubmission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if sub.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx, caller, err := h.caller(r.Context(), sub.UserID)
+	if err != nil || !caller.IsSystemAdmin() {
+		writeJSON(w, map[string]string{"error": i18n.T(ctx, "admin.err.not_admin")})
+		return
+	}
+
+	teamID := sub.CallbackID
+	ts := h.settings.Settings(teamID)
+	fieldErrors := map[string]string{}
+	for _, key := range service.TeamSettingKeys {
+		if err := service.ApplySetting(ctx, &ts, key, sub.Submission[key]); err != nil {
+			fieldErrors[key] = err.Error()
+		}
+	}
+	if len(fieldErrors) > 0 {
+		writeJSON(w, map[string]any{"errors": fieldErrors})
+		return
+	}
+	if err := h.settings.Save(ctx, &ts, caller.ID); err != nil {
+		log.Printf("ERROR botadmin: save team settings: %v", err)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("botadmin: %s updated the settings of team %s", caller.Username, teamID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// breakLabelLocales are the locales a custom break reason can be labelled in.
+var breakLabelLocales = []string{"en", "vi", "zh-CN", "zh-TW"}
+
+// breaks runs /botadmin breaks for the caller's team.
+func (h *AdminHandler) breaks(ctx context.Context, w http.ResponseWriter, caller *mattermost.UserInfo, teamID, triggerID string, args []string) {
+	if !caller.IsSystemAdmin() {
+		ephemeral(w, i18n.T(ctx, "admin.err.not_admin"))
+		return
+	}
+	var err error
+	switch {
+	case len(args) == 0:
+		ephemeral(w, h.breaksTable(ctx, teamID))
+		return
+	case args[0] == "add" || (args[0] == "edit" && len(args) == 2):
+		reason := model.BreakReason{}
+		if args[0] == "edit" {
+			var ok bool
+			if reason, ok = h.settings.BreakReason(teamID, args[1]); !ok {
+				ephemeral(w, i18n.T(ctx, "admin.breaks.err.unknown", map[string]any{"ID": args[1]}))
+				return
+			}
+		}
+		if err := h.openBreakDialog(ctx, teamID, triggerID, reason); err != nil {
+			log.Printf("ERROR botadmin: open break reason dialog: %v", err)
+			ephemeral(w, i18n.T(ctx, "attendance.err.open_form"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	case args[0] == "remove" && len(args) == 2:
+		err = h.settings.RemoveBreakReason(ctx, teamID, args[1], caller.ID)
+	case args[0] == "reset":
+		err = h.settings.ResetBreakReasons(ctx, teamID, caller.ID)
+	default:
+		ephemeral(w, i18n.T(ctx, "admin.usage"))
+		return
+	}
+	if err != nil {
+		ephemeral(w, err.Error())
+		return
+	}
+	log.Printf("botadmin: %s ran breaks %s for team %s", caller.Username, strings.Join(args, " "), teamID)
+	ephemeral(w, h.breaksTable(ctx, teamID))
+}
+
+// breaksTable renders a team's break reasons and their limits.
+func (h *AdminHandler) breaksTable(ctx context.Context, teamID string) string {
+	limit := func(n int) string {
+		if n == 0 {
+			return "-"
+		}
+		return fmt.Sprint(n)
+	}
+	var b strings.Builder
+	b.WriteString(i18n.T(ctx, "admin.breaks.header"))
+	b.WriteString("\n\n" + i18n.T(ctx, "admin.breaks.columns") + "\n|---|---|---|---|---|")
+	for _, r := range h.settings.BreakReasons(teamID) {
+		fmt.Fprintf(&b, "\n| `%s` | %s | %s | %s | %s |", r.ID, service.BreakReasonLabel(ctx, r),
+			limit(r.MaxPerDay), limit(r.MaxMinutes), limit(r.MaxDailyMinutes))
+	}
+	return b.String()
+}
+
+// openBreakDialog opens the dialog adding a break reason, or editing one when reason has
+// an ID.
+func (h *AdminHandler) openBreakDialog(ctx context.Context, teamID, triggerID string, reason model.BreakReason) error {
+	var elements []mattermost.DialogElement
+	if reason.ID == "" {
+		elements = append(elements, mattermost.DialogElement{
+			DisplayName: i18n.T(ctx, "admin.breaks.field.id"),
+			Name:        "id",
+			Type:        "text",
+			HelpText:    i18n.T(ctx, "admin.breaks.help.id"),
+		})
+	}
+	for _, locale := range breakLabelLocales {
+		elements = append(elements, mattermost.DialogElement{
+			DisplayName: i18n.T(ctx, "admin.breaks.field.label", map[string]any{"Locale": locale}),
+			Name:        "label_" + locale,
+			Type:        "text",
+			Optional:    true,
+			Default:     reason.Labels[locale],
+		})
+	}
+	for _, f := range []struct {
+		name  string
+		value int
+	}{
+		{"max_per_day", reason.MaxPerDay},
+		{"max_minutes", reason.MaxMinutes},
+		{"max_daily_minutes", reason.MaxDailyMinutes},
+	} {
+		el := mattermost.DialogElement{
+			DisplayName: i18n.T(ctx, "admin.breaks.field."+f.name),
+			Name:        f.name,
+			Type:        "text",
+			SubType:     "number",
+			Optional:    true,
+			HelpText:    i18n.T(ctx, "admin.breaks.help.limit"),
+		}
+		if f.value > 0 {
+			el.Default = fmt.Sprint(f.value)
+		}
+		elements = append(elements, el)
+	}
+
+	title := i18n.T(ctx, "admin.breaks.title.add")
+	if reason.ID != "" {
+		title = i18n.T(ctx, "admin.breaks.title.edit", map[string]any{"ID": reason.ID})
+	}
+	return h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: triggerID,
+		URL:       h.botURL + "/api/botadmin/breaks",
+		Dialog: mattermost.Dialog{
+			Title:       title,
+			CallbackID:  teamID + ":" + reason.ID,
+			Elements:    elements,
+			SubmitLabel: i18n.T(ctx, "admin.config.submit"),
+		},
+	})
+}
+
+// HandleBreakSubmit saves the break reason dialog.
+func (h *AdminHandler) HandleBreakSubmit(w http.ResponseWriter, r *http.Request) {
+	var sub DialogSubmission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if sub.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx, caller, err := h.caller(r.Context(), sub.UserID)
+	if err != nil || !caller.IsSystemAdmin() {
+		writeJSON(w, map[string]string{"error": i18n.T(ctx, "admin.err.not_admin")})
+		return
+	}
+
+	teamID, id, _ := strings.Cut(sub.CallbackID, ":")
+	if id == "" {
+		id = strings.ToLower(strings.TrimSpace(sub.Submission["id"]))
+	}
+	reason := model.BreakReason{ID: id, Labels: map[string]string{}}
+	for _, locale := range breakLabelLocales {
+		reason.Labels[locale] = strings.TrimSpace(sub.Submission["label_"+locale])
+	}
+	fieldErrors := map[string]string{}
+	for name, dst := range map[string]*int{
+		"max_per_day":       &reason.MaxPerDay,
+		"max_minutes":       &reason.MaxMinutes,
+		"max_daily_minutes": &reason.MaxDailyMinutes,
+	} {
+		v := strings.TrimSpace(sub.Submission[name])
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			fieldErrors[name] = i18n.T(ctx, "admin.breaks.err.limit")
+			continue
+		}
+		*dst = n
+	}
+	if len(fieldErrors) > 0 {
+		writeJSON(w, map[string]any{"errors": fieldErrors})
+		return
+	}
+	if err := h.settings.SaveBreakReason(ctx, teamID, reason, caller.ID); err != nil {
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("botadmin: %s saved break reason %s for team %s", caller.Username, reason.ID, teamID)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *AdminHandler) timesheet(ctx context.Context, w http.ResponseWriter, caller *mattermost.UserInfo, teamID string, args []string) {
+	if !caller.IsSystemAdmin() {
+		ephemeral(w, i18n.T(ctx, "admin.err.not_admin"))
+		return
+	}
+	var (
+		sheet *model.Timesheet
+		key   string
+		err   error
+	)
+	switch {
+	case len(args) == 2 && args[0] == "lock":
+		sheet, err = h.timesheets.Lock(ctx, teamID, args[1], caller.Username)
+		key = "admin.timesheet.locked"
+	case len(args) >= 3 && args[0] == "correct":
+		sheet, err = h.timesheets.OpenCorrection(ctx, teamID, args[1], caller.Username, strings.Join(args[2:], " "))
+		key = "admin.timesheet.correction_opened"
+	case len(args) == 1 || len(args) == 2 && args[0] != "lock" && args[0] != "correct":
+		format := service.TimesheetFormatCSV
+		if len(args) == 2 {
+			format = args[1]
+		}
+		err = h.sendTimesheet(ctx, caller, teamID, args[0], format)
+		key = "admin.timesheet.sent"
+	default:
+		ephemeral(w, i18n.T(ctx, "admin.usage"))
+		return
+	}
+	if err != nil {
+		var invalid *service.InvalidInputError
+		if errors.As(err, &invalid) {
+			ephemeral(w, err.Error())
+			return
+		}
+		log.Printf("ERROR botadmin: timesheet %s: %v", strings.Join(args, " "), err)
+		ephemeral(w, i18n.T(ctx, "admin.err.timesheet_failed"))
+		return
+	}
+	log.Printf("botadmin: %s ran timesheet %s for team %s", caller.Username, strings.Join(args, " "), teamID)
+	data := map[string]any{"Month": args[len(args)-1]}
+	if sheet != nil {
+		data = map[string]any{"Month": sheet.Month, "Revision": sheet.Revision, "Employees": len(sheet.Rows)}
+	}
+	ephemeral(w, i18n.T(ctx, key, data))
+}
+
+// sendTimesheet DMs the caller a team's timesheet for a month as a payroll file.
+func (h *AdminHandler) sendTimesheet(ctx context.Context, caller *mattermost.UserInfo, teamID, month, format string) error {
+	sheet, err := h.timesheets.Get(ctx, teamID, month)
+	if err != nil {
+		return err
+	}
+	name, _, data, err := h.timesheets.Export(ctx, sheet, format)
+	if err != nil {
+		return err
+	}
+	channelID, err := h.mm.DMChannelID(caller.ID)
+	if err != nil {
+		return err
+	}
+	fileID, err := h.mm.UploadFile(channelID, name, data)
+	if err != nil {
+		return err
+	}
+	msgKey := "admin.timesheet.message_draft"
+	if sheet.LockedAt != nil {
+		msgKey = "admin.timesheet.message"
+	}
+	_, err = h.mm.CreatePost(&mattermost.Post{
+		ChannelID: channelID,
+		Message:   i18n.T(ctx, msgKey, map[string]any{"Month": month, "Revision": sheet.Revision, "Employees": len(sheet.Rows)}),
+		FileIds:   []string{fileID},
+	})
+	return err
+}
+
+// RegisterRoutes registers the /botadmin routes on the given mux.
+func (h *AdminHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/botadmin", h.HandleBotAdmin)
+	mux.HandleFunc("POST /api/botadmin/erase", h.HandleErase)
+	mux.HandleFunc("POST /api/botadmin/config", h.HandleConfigSubmit)
+	mux.HandleFunc("POST /api/botadmin/breaks", h.HandleBreakSubmit)
+}
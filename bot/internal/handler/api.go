@@ -0,0 +1,340 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/service"
+	"oktel-bot/internal/store"
+)
+
+// maxBulkImport caps the number of leave requests or balance adjustments in one bulk import.
+const maxBulkImport = 500
+
+type apiKeyCtxKey struct{}
+
+// APIHandler serves the versioned REST API for external systems under /api/v1.
+// Requests authenticate with an API key (Authorization: Bearer okb_...) and each route
+// requires one scope. Error messages follow the Accept-Language header.
+type APIHandler struct {
+	attendance *service.AttendanceService
+	budgets    *service.BudgetService
+	timesheets *service.TimesheetService
+	keys       *service.APIKeyService
+}
+
+func NewAPIHandler(attendance *service.AttendanceService, budgets *service.BudgetService, timesheets *service.TimesheetService, keys *service.APIKeyService) *APIHandler {
+	return &APIHandler{attendance: attendance, budgets: budgets, timesheets: timesheets, keys: keys}
+}
+
+// APIError is the body of every non-2xx API response.
+type APIError struct {
+	Error string `json:"error"`
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("ERROR encoding response: %v", err)
+	}
+}
+
+// writeAPIServiceError responds 400 for invalid input and 500 for anything else.
+func writeAPIServiceError(w http.ResponseWriter, err error) {
+	var invalid *service.InvalidInputError
+	if errors.As(err, &invalid) {
+		writeAPIJSON(w, http.StatusBadRequest, APIError{Error: err.Error()})
+		return
+	}
+	log.Printf("ERROR api: %v", err)
+	writeAPIJSON(w, http.StatusInternalServerError, APIError{Error: "internal error"})
+}
+
+// requireAPIKey wraps a route so it only runs for an active API key holding scope.
+func requireAPIKey(keys *service.APIKeyService, scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			secret = r.Header.Get("X-API-Key")
+		}
+		key, err := keys.Authenticate(r.Context(), strings.TrimSpace(secret))
+		if err != nil {
+			writeAPIServiceError(w, err)
+			return
+		}
+		if key == nil {
+			writeAPIJSON(w, http.StatusUnauthorized, APIError{Error: "invalid or missing API key"})
+			return
+		}
+		if !key.HasScope(scope) {
+			writeAPIJSON(w, http.StatusForbidden, APIError{Error: "API key lacks scope " + scope})
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiKeyCtxKey{}, key)
+		if lang := r.Header.Get("Accept-Language"); lang != "" {
+			ctx = i18n.WithLocale(ctx, lang)
+		}
+		next(w, r.WithContext(ctx))
+	}
+}
+
+func apiKeyFrom(ctx context.Context) *model.APIKey {
+	key, _ := ctx.Value(apiKeyCtxKey{}).(*model.APIKey)
+	return key
+}
+
+// pageParams reads the zero-based page and per_page query params.
+func pageParams(r *http.Request) (int, int) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	return page, perPage
+}
+
+// HandleListLeaves lists leave requests, newest first.
+// Query params: user_id, team_id, status, type, source, external_id, from, to (YYYY-MM-DD; any
+// requested date in range), page, per_page.
+func (h *APIHandler) HandleListLeaves(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page, perPage := pageParams(r)
+	result, err := h.attendance.ListLeaveRequests(r.Context(), store.LeaveRequestFilter{
+		UserID:     q.Get("user_id"),
+		TeamID:     q.Get("team_id"),
+		Status:     model.LeaveStatus(q.Get("status")),
+		Type:       model.LeaveType(q.Get("type")),
+		Source:     q.Get("source"),
+		ExternalID: q.Get("external_id"),
+		From:       q.Get("from"),
+		To:         q.Get("to"),
+	}, page, perPage)
+	if err != nil {
+		writeAPIServiceError(w, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, result)
+}
+
+// HandleGetLeave returns one leave request.
+func (h *APIHandler) HandleGetLeave(w http.ResponseWriter, r *http.Request) {
+	req, err := h.attendance.GetLeaveRequest(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIServiceError(w, err)
+		return
+	}
+	if req == nil {
+		writeAPIJSON(w, http.StatusNotFound, APIError{Error: "leave request not found"})
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, req)
+}
+
+// HandleCreateLeave imports one leave request approved in another system. It responds
+// 201 when created and 200 with the existing request when external_id was imported before.
+func (h *APIHandler) HandleCreateLeave(w http.ResponseWriter, r *http.Request) {
+	var in service.LeaveImport
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeAPIJSON(w, http.StatusBadRequest, APIError{Error: "invalid JSON body"})
+		return
+	}
+	req, created, err := h.attendance.ImportLeave(r.Context(), in, apiKeyFrom(r.Context()).Name)
+	if err != nil {
+		writeAPIServiceError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeAPIJSON(w, status, req)
+}
+
+// BulkImportResult is the outcome of one item of a bulk import, in request order.
+type BulkImportResult struct {
+	Index   int                 `json:"index"`
+	Created bool                `json:"created"`
+	Leave   *model.LeaveRequest `json:"leave,omitempty"`
+	Error   string              `json:"error,omitempty"`
+}
+
+// HandleBulkCreateLeaves imports up to maxBulkImport leave requests. Items are imported
+// independently: one invalid item does not stop the others.
+func (h *APIHandler) HandleBulkCreateLeaves(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Leaves []service.LeaveImport `json:"leaves"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAPIJSON(w, http.StatusBadRequest, APIError{Error: "invalid JSON body"})
+		return
+	}
+	if len(body.Leaves) == 0 || len(body.Leaves) > maxBulkImport {
+		writeAPIJSON(w, http.StatusBadRequest, APIError{Error: "leaves must contain 1 to " + strconv.Itoa(maxBulkImport) + " items"})
+		return
+	}
+
+	source := apiKeyFrom(r.Context()).Name
+	results := make([]BulkImportResult, len(body.Leaves))
+	for i, in := range body.Leaves {
+		results[i].Index = i
+		req, created, err := h.attendance.ImportLeave(r.Context(), in, source)
+		if err != nil {
+			var invalid *service.InvalidInputError
+			if !errors.As(err, &invalid) {
+				log.Printf("ERROR api: bulk import item %d: %v", i, err)
+				results[i].Error = "internal error"
+			} else {
+				results[i].Error = err.Error()
+			}
+			continue
+		}
+		results[i].Created = created
+		results[i].Leave = req
+	}
+	writeAPIJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+// BulkAdjustmentResult is the outcome of one item of a bulk balance adjustment, in request
+// order. Balance is the user's comp time balance in minutes after the item.
+type BulkAdjustmentResult struct {
+	Index      int                       `json:"index"`
+	Created    bool                      `json:"created"`
+	Adjustment *model.CompTimeAdjustment `json:"adjustment,omitempty"`
+	Balance    int                       `json:"balance_minutes"`
+	Error      string                    `json:"error,omitempty"`
+}
+
+// HandleBulkAdjustBalances applies up to maxBulkImport comp time adjustments. Items are
+// applied independently: one invalid item does not stop the others.
+func (h *APIHandler) HandleBulkAdjustBalances(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Adjustments []service.BalanceAdjustment `json:"adjustments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAPIJSON(w, http.StatusBadRequest, APIError{Error: "invalid JSON body"})
+		return
+	}
+	if len(body.Adjustments) == 0 || len(body.Adjustments) > maxBulkImport {
+		writeAPIJSON(w, http.StatusBadRequest, APIError{Error: "adjustments must contain 1 to " + strconv.Itoa(maxBulkImport) + " items"})
+		return
+	}
+
+	source := apiKeyFrom(r.Context()).Name
+	results := make([]BulkAdjustmentResult, len(body.Adjustments))
+	for i, in := range body.Adjustments {
+		results[i].Index = i
+		adj, balance, created, err := h.attendance.AdjustCompTime(r.Context(), in, source)
+		if err != nil {
+			var invalid *service.InvalidInputError
+			if !errors.As(err, &invalid) {
+				log.Printf("ERROR api: bulk adjustment item %d: %v", i, err)
+				results[i].Error = "internal error"
+			} else {
+				results[i].Error = err.Error()
+			}
+			continue
+		}
+		results[i].Created = created
+		results[i].Adjustment = adj
+		results[i].Balance = balance
+	}
+	writeAPIJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+// HandleListAttendance lists attendance records, latest date first.
+// Query params: user_id, team_id, channel_id, from, to (YYYY-MM-DD), page, per_page.
+func (h *APIHandler) HandleListAttendance(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page, perPage := pageParams(r)
+	result, err := h.attendance.ListAttendance(r.Context(), store.AttendanceFilter{
+		UserID:    q.Get("user_id"),
+		TeamID:    q.Get("team_id"),
+		ChannelID: q.Get("channel_id"),
+		From:      q.Get("from"),
+		To:        q.Get("to"),
+	}, page, perPage)
+	if err != nil {
+		writeAPIServiceError(w, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, result)
+}
+
+// HandleListBudgets lists budget requests, newest first.
+// Query params: team_id, step (1-6), state (open|completed|rejected|withdrawn), from, to (creation
+// date, YYYY-MM-DD), page, per_page.
+func (h *APIHandler) HandleListBudgets(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page, perPage := pageParams(r)
+	result, err := h.budgets.ListRequests(r.Context(), q.Get("team_id"), q.Get("step"), q.Get("state"), q.Get("from"), q.Get("to"), page, perPage)
+	if err != nil {
+		writeAPIServiceError(w, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, result)
+}
+
+// HandleGetBudget returns one budget request.
+func (h *APIHandler) HandleGetBudget(w http.ResponseWriter, r *http.Request) {
+	req, err := h.budgets.GetRequest(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIServiceError(w, err)
+		return
+	}
+	if req == nil {
+		writeAPIJSON(w, http.StatusNotFound, APIError{Error: "budget request not found"})
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, req)
+}
+
+// HandleGetTimesheet returns a team's payroll timesheet for a month (YYYY-MM): the locked
+// rows if the month is locked, else live totals.
+// Query params: team_id (required), format (json|csv|xlsx, default json).
+func (h *APIHandler) HandleGetTimesheet(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	teamID := q.Get("team_id")
+	if teamID == "" {
+		writeAPIJSON(w, http.StatusBadRequest, APIError{Error: i18n.T(r.Context(), "api.err.team_required")})
+		return
+	}
+	sheet, err := h.timesheets.Get(r.Context(), teamID, r.PathValue("month"))
+	if err != nil {
+		writeAPIServiceError(w, err)
+		return
+	}
+	format := q.Get("format")
+	if format == "" || format == "json" {
+		writeAPIJSON(w, http.StatusOK, sheet)
+		return
+	}
+	name, contentType, data, err := h.timesheets.Export(r.Context(), sheet, format)
+	if err != nil {
+		writeAPIServiceError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	if _, err := w.Write(data); err != nil {
+		log.Printf("ERROR writing timesheet: %v", err)
+	}
+}
+
+// RegisterRoutes registers the /api/v1 routes on the given mux.
+func (h *APIHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/leaves", requireAPIKey(h.keys, model.ScopeLeavesRead, h.HandleListLeaves))
+	mux.HandleFunc("GET /api/v1/leaves/{id}", requireAPIKey(h.keys, model.ScopeLeavesRead, h.HandleGetLeave))
+	mux.HandleFunc("POST /api/v1/leaves", requireAPIKey(h.keys, model.ScopeLeavesWrite, h.HandleCreateLeave))
+	mux.HandleFunc("POST /api/v1/leaves/bulk", requireAPIKey(h.keys, model.ScopeLeavesWrite, h.HandleBulkCreateLeaves))
+	mux.HandleFunc("POST /api/v1/balances/bulk", requireAPIKey(h.keys, model.ScopeBalancesWrite, h.HandleBulkAdjustBalances))
+	mux.HandleFunc("GET /api/v1/attendance", requireAPIKey(h.keys, model.ScopeAttendanceRead, h.HandleListAttendance))
+	mux.HandleFunc("GET /api/v1/budgets", requireAPIKey(h.keys, model.ScopeBudgetsRead, h.HandleListBudgets))
+	mux.HandleFunc("GET /api/v1/budgets/{id}", requireAPIKey(h.keys, model.ScopeBudgetsRead, h.HandleGetBudget))
+	mux.HandleFunc("GET /api/v1/timesheets/{month}", requireAPIKey(h.keys, model.ScopeTimesheetsRead, h.HandleGetTimesheet))
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/service"
+	"oktel-bot/internal/store"
+)
+
+// memAPIKeyStore keeps API keys in memory. Methods the tests don't use panic through the
+// nil embedded interface.
+type memAPIKeyStore struct {
+	store.APIKeyStore
+	keys map[string]*model.APIKey
+}
+
+func (s *memAPIKeyStore) Create(_ context.Context, key *model.APIKey) error {
+	key.ID = bson.NewObjectID()
+	s.keys[key.Hash] = key
+	return nil
+}
+
+func (s *memAPIKeyStore) GetByHash(_ context.Context, hash string) (*model.APIKey, error) {
+	return s.keys[hash], nil
+}
+
+func (s *memAPIKeyStore) TouchLastUsed(context.Context, bson.ObjectID, time.Time) error {
+	return nil
+}
+
+// memAttendanceStore keeps comp time adjustments in memory.
+type memAttendanceStore struct {
+	store.AttendanceStore
+	adjustments []*model.CompTimeAdjustment
+}
+
+func (s *memAttendanceStore) GetCompTimeBalance(_ context.Context, userID string) (int, error) {
+	balance := 0
+	for _, adj := range s.adjustments {
+		if adj.UserID == userID {
+			balance += adj.Minutes
+		}
+	}
+	return balance, nil
+}
+
+func (s *memAttendanceStore) CreateCompTimeAdjustment(_ context.Context, adj *model.CompTimeAdjustment) error {
+	adj.ID = bson.NewObjectID()
+	s.adjustments = append(s.adjustments, adj)
+	return nil
+}
+
+func (s *memAttendanceStore) FindCompTimeAdjustment(_ context.Context, source, externalID string) (*model.CompTimeAdjustment, error) {
+	for _, adj := range s.adjustments {
+		if adj.Source == source && adj.ExternalID == externalID {
+			return adj, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestBulkAdjustBalances(t *testing.T) {
+	i18n.Init("en")
+
+	// Mattermost knows every user whose ID starts with "user".
+	mm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := strings.CutPrefix(r.URL.Path, "/api/v4/users/")
+		if !ok || !strings.HasPrefix(id, "user") {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(mattermost.UserInfo{ID: id, Username: id})
+	}))
+	defer mm.Close()
+
+	attendance := &memAttendanceStore{}
+	keys := service.NewAPIKeyService(&memAPIKeyStore{keys: map[string]*model.APIKey{}})
+	attendanceSvc := service.NewAttendanceService(attendance, mattermost.NewClient(mm.URL, "token", mattermost.CacheOptions{}), "",
+		service.PhotoCheckConfig{}, service.OvertimeConfig{}, service.WorkModeConfig{}, service.LeaveAttachmentPolicy{}, nil, nil, nil, nil)
+	mux := http.NewServeMux()
+	NewAPIHandler(attendanceSvc, nil, nil, keys).RegisterRoutes(mux)
+
+	hrKey, _, err := keys.Create(context.Background(), "hr", []string{model.ScopeBalancesWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaveKey, _, err := keys.Create(context.Background(), "leave-import", []string{model.ScopeLeavesWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/balances/bulk", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) []BulkAdjustmentResult {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		var body struct {
+			Results []BulkAdjustmentResult `json:"results"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Results
+	}
+	balance := func(t *testing.T, userID string) int {
+		t.Helper()
+		b, err := attendance.GetCompTimeBalance(context.Background(), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	t.Run("adjusts balances", func(t *testing.T) {
+		results := decode(t, post(hrKey, `{"adjustments": [
+			{"user_id": "user1", "minutes": 240, "reason": "carry-over", "external_id": "HR-1"},
+			{"user_id": "user1", "minutes": -60, "reason": "correction"},
+			{"user_id": "user2", "minutes": 30, "reason": "carry-over"},
+			{"user_id": "user2", "minutes": 0, "reason": "nothing"},
+			{"user_id": "ghost", "minutes": 30, "reason": "carry-over"}
+		]}`))
+		if len(results) != 5 {
+			t.Fatalf("got %d results, want 5", len(results))
+		}
+		for i, want := range []int{240, 180, 30} {
+			if r := results[i]; r.Error != "" || !r.Created || r.Balance != want {
+				t.Errorf("result %d = %+v, want created with balance %d", i, r, want)
+			}
+		}
+		for _, i := range []int{3, 4} {
+			if results[i].Error == "" || results[i].Adjustment != nil {
+				t.Errorf("result %d = %+v, want an error", i, results[i])
+			}
+		}
+		if got := balance(t, "user1"); got != 180 {
+			t.Errorf("user1 balance = %d, want 180", got)
+		}
+		if got := balance(t, "user2"); got != 30 {
+			t.Errorf("user2 balance = %d, want 30", got)
+		}
+		if results[0].Adjustment.Source != "hr" {
+			t.Errorf("source = %q, want the key name", results[0].Adjustment.Source)
+		}
+	})
+
+	t.Run("re-importing an external ID changes nothing", func(t *testing.T) {
+		results := decode(t, post(hrKey, `{"adjustments": [{"user_id": "user1", "minutes": 240, "reason": "carry-over", "external_id": "HR-1"}]}`))
+		if len(results) != 1 || results[0].Created || results[0].Balance != 180 {
+			t.Errorf("results = %+v, want the existing adjustment and balance 180", results)
+		}
+		if got := balance(t, "user1"); got != 180 {
+			t.Errorf("user1 balance = %d, want 180", got)
+		}
+	})
+
+	t.Run("rejects keys without the scope", func(t *testing.T) {
+		before := len(attendance.adjustments)
+		body := `{"adjustments": [{"user_id": "user1", "minutes": 60, "reason": "bonus"}]}`
+		if rec := post(leaveKey, body); rec.Code != http.StatusForbidden {
+			t.Errorf("key without balances:write: status = %d, want 403", rec.Code)
+		}
+		if rec := post("", body); rec.Code != http.StatusUnauthorized {
+			t.Errorf("no key: status = %d, want 401", rec.Code)
+		}
+		if len(attendance.adjustments) != before {
+			t.Errorf("rejected requests stored %d adjustments", len(attendance.adjustments)-before)
+		}
+	})
+
+	t.Run("rejects empty and oversized batches", func(t *testing.T) {
+		if rec := post(hrKey, `{"adjustments": []}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("empty batch: status = %d, want 400", rec.Code)
+		}
+		items := strings.Repeat(`{"user_id": "user1", "minutes": 1, "reason": "x"},`, maxBulkImport+1)
+		if rec := post(hrKey, `{"adjustments": [`+strings.TrimSuffix(items, ",")+`]}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("oversized batch: status = %d, want 400", rec.Code)
+		}
+	})
+}
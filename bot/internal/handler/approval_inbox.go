@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/service"
+)
+
+// pendingDialogMax bounds how many requests the /xinphep pending dialog offers at once.
+const pendingDialogMax = 100
+
+// respondPending replies to "/xinphep pending" with the requests awaiting the user's
+// decision, grouped by date, and opens a dialog to approve or reject several of them.
+func (h *AttendanceHandler) respondPending(ctx context.Context, w http.ResponseWriter, teamID, userID, triggerID string) {
+	pending, err := h.svc.PendingApprovals(ctx, teamID, userID)
+	if err != nil {
+		log.Printf("ERROR list pending approvals: %v", err)
+		writeJSON(w, SlashResponse{ResponseType: "ephemeral", Text: i18n.T(ctx, "attendance.pending.err.load")})
+		return
+	}
+	if len(pending) == 0 {
+		writeJSON(w, SlashResponse{ResponseType: "ephemeral", Text: i18n.T(ctx, "attendance.pending.none")})
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(ctx, "attendance.pending.header", map[string]any{"Count": len(pending)}))
+	options := make([]mattermost.SelectOption, 0, min(len(pending), pendingDialogMax))
+	date := ""
+	for i, p := range pending {
+		label := pendingLabel(ctx, p.Request)
+		if p.Date != date {
+			date = p.Date
+			sb.WriteString("\n\n**" + model.FormatDateDisplay(date) + "**")
+		}
+		sb.WriteString("\n- " + label)
+		if i < pendingDialogMax {
+			options = append(options, mattermost.SelectOption{Text: label, Value: p.Request.ID.Hex()})
+		}
+	}
+	if len(pending) > pendingDialogMax {
+		sb.WriteString("\n\n" + i18n.T(ctx, "attendance.pending.truncated", map[string]any{"Max": pendingDialogMax}))
+	}
+
+	err = h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: triggerID,
+		URL:       h.botURL + "/api/attendance/pending-submit",
+		Dialog: mattermost.Dialog{
+			Title:       i18n.T(ctx, "attendance.dialog.pending_title"),
+			SubmitLabel: i18n.T(ctx, "attendance.dialog.pending_submit"),
+			Elements: []mattermost.DialogElement{
+				{
+					DisplayName: i18n.T(ctx, "attendance.field.pending_requests"),
+					Name:        "requests",
+					Type:        "select",
+					MultiSelect: true,
+					Options:     options,
+				},
+				{
+					DisplayName: i18n.T(ctx, "attendance.field.decision"),
+					Name:        "decision",
+					Type:        "radio",
+					Default:     service.DecisionApprove,
+					Options: []mattermost.SelectOption{
+						{Text: i18n.T(ctx, "attendance.btn.approve"), Value: service.DecisionApprove},
+						{Text: i18n.T(ctx, "attendance.btn.reject"), Value: service.DecisionReject},
+					},
+				},
+				{
+					DisplayName: i18n.T(ctx, "attendance.field.reason"),
+					Name:        "reason",
+					Type:        "textarea",
+					Optional:    true,
+					HelpText:    i18n.T(ctx, "attendance.help.pending_reason"),
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("ERROR open pending dialog: %v", err)
+		sb.WriteString("\n\n" + i18n.T(ctx, "attendance.err.open_form"))
+	}
+
+	writeJSON(w, SlashResponse{ResponseType: "ephemeral", Text: sb.String()})
+}
+
+// pendingLabel describes a request awaiting approval in one line.
+func pendingLabel(ctx context.Context, req *model.LeaveRequest) string {
+	leaveType := i18n.T(ctx, "leave.type."+string(req.Type))
+	if req.Status == model.LeaveStatusPendingChange {
+		return i18n.T(ctx, "attendance.pending.change_item", map[string]any{
+			"Username": req.Username,
+			"Type":     leaveType,
+			"OldDate":  model.FormatDateDisplay(req.OldDate),
+			"NewDate":  model.FormatDateDisplay(req.NewDate),
+		})
+	}
+	dates := make([]string, len(req.Dates))
+	for i, d := range req.Dates {
+		dates[i] = model.FormatDateDisplay(d)
+	}
+	return i18n.T(ctx, "attendance.pending.item", map[string]any{
+		"Username": req.Username,
+		"Type":     leaveType,
+		"Dates":    strings.Join(dates, ", "),
+	})
+}
+
+// pendingSubmission is the /xinphep pending dialog submission. Unlike DialogSubmission its
+// values are not all strings: the multiselect sends a list.
+type pendingSubmission struct {
+	UserID     string         `json:"user_id"`
+	UserName   string         `json:"user_name"`
+	Submission map[string]any `json:"submission"`
+	Cancelled  bool           `json:"cancelled"`
+}
+
+// HandlePendingSubmit approves or rejects the requests chosen in the /xinphep pending
+// dialog, then sends the approver a summary.
+func (h *AttendanceHandler) HandlePendingSubmit(w http.ResponseWriter, r *http.Request) {
+	var sub pendingSubmission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if sub.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), sub.UserID)
+
+	var requestIDs []string
+	switch v := sub.Submission["requests"].(type) {
+	case []any:
+		for _, id := range v {
+			if s, ok := id.(string); ok && s != "" {
+				requestIDs = append(requestIDs, s)
+			}
+		}
+	case string:
+		requestIDs = strings.FieldsFunc(v, func(r rune) bool { return r == ',' })
+	}
+	decision, _ := sub.Submission["decision"].(string)
+	if decision != service.DecisionReject {
+		decision = service.DecisionApprove
+	}
+	reason, _ := sub.Submission["reason"].(string)
+	reason = strings.TrimSpace(reason)
+
+	errs := map[string]string{}
+	if len(requestIDs) == 0 {
+		errs["requests"] = i18n.T(ctx, "attendance.err.pending_none_selected")
+	}
+	if decision == service.DecisionReject && reason == "" {
+		errs["reason"] = i18n.T(ctx, "attendance.err.reject_reason_required")
+	}
+	if len(errs) > 0 {
+		writeJSON(w, map[string]any{"errors": errs})
+		return
+	}
+
+	username := sub.UserName
+	if username == "" {
+		user, err := h.mm.GetUser(sub.UserID)
+		if err == nil {
+			username = user.Username
+		}
+	}
+
+	res := h.svc.DecidePending(ctx, requestIDs, decision, reason, sub.UserID, username)
+	summary := i18n.T(ctx, "attendance.pending.summary_"+decision, map[string]any{
+		"Done":  len(res.Done),
+		"Total": len(requestIDs),
+	})
+	for _, f := range res.Failures {
+		label := f.RequestID
+		if f.Request != nil {
+			label = pendingLabel(ctx, f.Request)
+		}
+		summary += "\n- " + label + ": " + f.Err.Error()
+	}
+	if len(res.Done) == 0 {
+		writeJSON(w, map[string]string{"error": summary})
+		return
+	}
+	if err := h.mm.SendDM(sub.UserID, summary); err != nil {
+		log.Printf("pending approvals: send summary to %s: %v", sub.UserID, err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -16,21 +17,35 @@ import (
 	"oktel-bot/internal/service"
 )
 
+// vnTZ is the time zone dates are entered in.
+var vnTZ = time.FixedZone("UTC+7", 7*60*60)
+
 type AttendanceHandler struct {
 	svc             *service.AttendanceService
+	delegations     *service.DelegationService
+	calendar        *service.CalendarService
 	mm              *mattermost.Client
 	botURL          string
-	blockMobile     bool
 	activityChecker *scheduler.ActivityChecker
 }
 
-func NewAttendanceHandler(svc *service.AttendanceService, mm *mattermost.Client, botURL string, blockMobile bool, activityChecker *scheduler.ActivityChecker) *AttendanceHandler {
-	return &AttendanceHandler{svc: svc, mm: mm, botURL: botURL, blockMobile: blockMobile, activityChecker: activityChecker}
+func NewAttendanceHandler(svc *service.AttendanceService, delegations *service.DelegationService, calendar *service.CalendarService, mm *mattermost.Client, botURL string, activityChecker *scheduler.ActivityChecker) *AttendanceHandler {
+	return &AttendanceHandler{svc: svc, delegations: delegations, calendar: calendar, mm: mm, botURL: botURL, activityChecker: activityChecker}
 }
 
-// isMobileRequest checks the X-Mattermost-Is-Mobile header injected by the Mattermost server.
-func (h *AttendanceHandler) isMobileRequest(r *http.Request) bool {
-	return h.blockMobile && r.Header.Get("X-Mattermost-Is-Mobile") == "true"
+// policyViolation checks the request against the policy for the user's attendance mode today
+// (office, remote or business trip) and returns the i18n key of the violated rule, or "".
+// It relies on the X-Mattermost-Is-Mobile and X-Mattermost-Client-Ip headers injected by the Mattermost server;
+// the server only sends the latter when ServiceSettings.ForwardClientIPToIntegrations is on.
+func (h *AttendanceHandler) policyViolation(ctx context.Context, r *http.Request, userID, teamID string) string {
+	policy := h.svc.TodayPolicy(ctx, userID, teamID)
+	if policy.BlockMobile && r.Header.Get("X-Mattermost-Is-Mobile") == "true" {
+		return "attendance.err.mobile_blocked"
+	}
+	if policy.RequireOfficeNetwork && !h.svc.InOfficeNetwork(r.Header.Get("X-Mattermost-Client-Ip")) {
+		return "attendance.err.network_blocked"
+	}
+	return ""
 }
 
 // deviceFromHeaders builds a device description from headers forwarded by the Mattermost server.
@@ -57,19 +72,35 @@ func deviceFromHeaders(r *http.Request) string {
 	return strings.TrimSpace(device)
 }
 
-func (h *AttendanceHandler) denyMobileSlash(ctx context.Context, w http.ResponseWriter) {
+func (h *AttendanceHandler) denySlash(ctx context.Context, w http.ResponseWriter, msgKey string) {
 	writeJSON(w, SlashResponse{
 		ResponseType: "ephemeral",
-		Text:         i18n.T(ctx, "attendance.err.mobile_blocked"),
+		Text:         i18n.T(ctx, msgKey),
 	})
 }
 
-func (h *AttendanceHandler) denyMobileAction(ctx context.Context, w http.ResponseWriter) {
-	writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.mobile_blocked")})
+func (h *AttendanceHandler) denyAction(ctx context.Context, w http.ResponseWriter, msgKey string) {
+	writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, msgKey)})
+}
+
+func (h *AttendanceHandler) denyDialog(ctx context.Context, w http.ResponseWriter, msgKey string) {
+	writeJSON(w, map[string]string{"error": i18n.T(ctx, msgKey)})
 }
 
-func (h *AttendanceHandler) denyMobileDialog(ctx context.Context, w http.ResponseWriter) {
-	writeJSON(w, map[string]string{"error": i18n.T(ctx, "attendance.err.mobile_blocked")})
+// photoElement builds the attendance photo field, optional when the user's mode does not require a photo.
+func photoElement(ctx context.Context, required bool) mattermost.DialogElement {
+	helpText := i18n.T(ctx, "attendance.helptext.photo")
+	if !required {
+		helpText = i18n.T(ctx, "attendance.helptext.photo_optional")
+	}
+	return mattermost.DialogElement{
+		DisplayName: i18n.T(ctx, "attendance.field.photo"),
+		Name:        "photo",
+		Type:        "file",
+		Optional:    !required,
+		HelpText:    helpText,
+		Accept:      "image/*",
+	}
 }
 
 // SlashCommand is the Mattermost slash command request.
@@ -91,6 +122,7 @@ type ActionRequest struct {
 	UserID    string         `json:"user_id"`
 	UserName  string         `json:"user_name"`
 	ChannelID string         `json:"channel_id"`
+	TeamID    string         `json:"team_id"`
 	PostID    string         `json:"post_id"`
 	TriggerID string         `json:"trigger_id"`
 	Type      string         `json:"type"`
@@ -141,6 +173,16 @@ func (h *AttendanceHandler) localeCtx(ctx context.Context, userID string) contex
 	return i18n.WithLocale(ctx, user.Locale)
 }
 
+// breakButtonKeys are the i18n keys of the built-in break buttons, by reason. Custom
+// reasons use their label.
+var breakButtonKeys = map[string]string{
+	"nghi_ngoi": "attendance.btn.rest",
+	"di_an":     "attendance.btn.eat",
+	"tieu_tien": "attendance.btn.restroom_s",
+	"dai_tien":  "attendance.btn.restroom_l",
+	"hut_thuoc": "attendance.btn.smoke",
+}
+
 // HandleDiemDanh handles /diemdanh slash command (attendance check-in/out/break).
 func (h *AttendanceHandler) HandleDiemDanh(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
@@ -150,8 +192,8 @@ func (h *AttendanceHandler) HandleDiemDanh(w http.ResponseWriter, r *http.Reques
 
 	ctx := h.localeCtx(r.Context(), r.FormValue("user_id"))
 
-	if h.isMobileRequest(r) {
-		h.denyMobileSlash(ctx, w)
+	if key := h.policyViolation(ctx, r, r.FormValue("user_id"), r.FormValue("team_id")); key != "" {
+		h.denySlash(ctx, w, key)
 		return
 	}
 
@@ -164,50 +206,42 @@ func (h *AttendanceHandler) HandleDiemDanh(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	actions := []mattermost.Action{
+		{Name: i18n.T(ctx, "attendance.btn.checkin"), Type: "button", Integration: mattermost.Integration{
+			URL:     h.botURL + "/api/attendance/checkin",
+			Context: map[string]any{"action": "checkin"},
+		}},
+		{Name: i18n.T(ctx, "attendance.btn.checkout"), Type: "button", Integration: mattermost.Integration{
+			URL:     h.botURL + "/api/attendance/checkout",
+			Context: map[string]any{"action": "checkout"},
+		}},
+	}
+	// Break buttons for the reasons the team offers
+	for _, reason := range h.svc.BreakReasons(r.FormValue("team_id")) {
+		label := service.BreakReasonLabel(ctx, reason)
+		if key, ok := breakButtonKeys[reason.ID]; ok && len(reason.Labels) == 0 {
+			label = i18n.T(ctx, key)
+		}
+		actions = append(actions, mattermost.Action{Name: label, Type: "button", Integration: mattermost.Integration{
+			URL:     h.botURL + "/api/attendance/break-start",
+			Context: map[string]any{"reason": reason.ID},
+		}})
+	}
+	actions = append(actions, mattermost.Action{Name: i18n.T(ctx, "attendance.btn.back_seat"), Type: "button", Integration: mattermost.Integration{
+		URL:     h.botURL + "/api/attendance/break-end",
+		Context: map[string]any{"action": "break-end"},
+	}})
+
 	writeJSON(w, SlashResponse{
 		ResponseType: "ephemeral",
-		Attachments: []mattermost.Attachment{
-			{
-				Actions: []mattermost.Action{
-					{Name: i18n.T(ctx, "attendance.btn.checkin"), Type: "button", Integration: mattermost.Integration{
-						URL:     h.botURL + "/api/attendance/checkin",
-						Context: map[string]any{"action": "checkin"},
-					}},
-					{Name: i18n.T(ctx, "attendance.btn.checkout"), Type: "button", Integration: mattermost.Integration{
-						URL:     h.botURL + "/api/attendance/checkout",
-						Context: map[string]any{"action": "checkout"},
-					}},
-					{Name: i18n.T(ctx, "attendance.btn.rest"), Type: "button", Integration: mattermost.Integration{
-						URL:     h.botURL + "/api/attendance/break-start",
-						Context: map[string]any{"reason": "nghi_ngoi"},
-					}},
-					{Name: i18n.T(ctx, "attendance.btn.eat"), Type: "button", Integration: mattermost.Integration{
-						URL:     h.botURL + "/api/attendance/break-start",
-						Context: map[string]any{"reason": "di_an"},
-					}},
-					{Name: i18n.T(ctx, "attendance.btn.restroom_s"), Type: "button", Integration: mattermost.Integration{
-						URL:     h.botURL + "/api/attendance/break-start",
-						Context: map[string]any{"reason": "tieu_tien"},
-					}},
-					{Name: i18n.T(ctx, "attendance.btn.restroom_l"), Type: "button", Integration: mattermost.Integration{
-						URL:     h.botURL + "/api/attendance/break-start",
-						Context: map[string]any{"reason": "dai_tien"},
-					}},
-					{Name: i18n.T(ctx, "attendance.btn.smoke"), Type: "button", Integration: mattermost.Integration{
-						URL:     h.botURL + "/api/attendance/break-start",
-						Context: map[string]any{"reason": "hut_thuoc"},
-					}},
-					{Name: i18n.T(ctx, "attendance.btn.back_seat"), Type: "button", Integration: mattermost.Integration{
-						URL:     h.botURL + "/api/attendance/break-end",
-						Context: map[string]any{"action": "break-end"},
-					}},
-				},
-			},
-		},
+		Attachments:  []mattermost.Attachment{{Actions: actions}},
 	})
 }
 
 // HandleXinPhep handles /xinphep slash command (leave/late/early requests).
+// "/xinphep calendar [week|month]" shows the team leave calendar instead,
+// "/xinphep calendar reset" revokes the caller's feed links, and
+// "/xinphep pending" the approver's inbox.
 func (h *AttendanceHandler) HandleXinPhep(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
@@ -225,6 +259,25 @@ func (h *AttendanceHandler) HandleXinPhep(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if args := strings.Fields(r.FormValue("text")); len(args) > 0 {
+		switch args[0] {
+		case "calendar":
+			period := "week"
+			if len(args) > 1 {
+				period = args[1]
+			}
+			if period == "reset" {
+				h.respondCalendarReset(ctx, w, r.FormValue("team_id"), r.FormValue("user_id"))
+				return
+			}
+			h.respondCalendar(ctx, w, r.FormValue("team_id"), r.FormValue("user_id"), period)
+			return
+		case "pending":
+			h.respondPending(ctx, w, r.FormValue("team_id"), r.FormValue("user_id"), r.FormValue("trigger_id"))
+			return
+		}
+	}
+
 	writeJSON(w, SlashResponse{
 		ResponseType: "ephemeral",
 		Attachments: []mattermost.Attachment{
@@ -242,16 +295,101 @@ func (h *AttendanceHandler) HandleXinPhep(w http.ResponseWriter, r *http.Request
 						URL:     h.botURL + "/api/attendance/early-form",
 						Context: map[string]any{"action": "early-form"},
 					}},
+					{Name: i18n.T(ctx, "attendance.btn.overtime"), Type: "button", Integration: mattermost.Integration{
+						URL:     h.botURL + "/api/attendance/overtime-form",
+						Context: map[string]any{"action": "overtime-form"},
+					}},
+					{Name: i18n.T(ctx, "attendance.btn.work_mode"), Type: "button", Integration: mattermost.Integration{
+						URL:     h.botURL + "/api/attendance/work-mode-form",
+						Context: map[string]any{"action": "work-mode-form"},
+					}},
 					{Name: i18n.T(ctx, "attendance.btn.change_dates"), Type: "button", Integration: mattermost.Integration{
 						URL:     h.botURL + "/api/attendance/change-form",
 						Context: map[string]any{"action": "change-form"},
 					}},
+					{Name: i18n.T(ctx, "attendance.btn.cancel_leave"), Type: "button", Integration: mattermost.Integration{
+						URL:     h.botURL + "/api/attendance/cancel-form",
+						Context: map[string]any{"action": "cancel-form"},
+					}},
+					{Name: i18n.T(ctx, "attendance.btn.attach"), Type: "button", Integration: mattermost.Integration{
+						URL:     h.botURL + "/api/attendance/attach-form",
+						Context: map[string]any{"action": "attach-form"},
+					}},
+					{Name: i18n.T(ctx, "attendance.btn.delegate"), Type: "button", Integration: mattermost.Integration{
+						URL:     h.botURL + "/api/attendance/delegate-form",
+						Context: map[string]any{"action": "delegate-form"},
+					}},
 				},
 			},
 		},
 	})
 }
 
+// respondCalendar replies to "/xinphep calendar" with the team's leave table for the
+// current week or month, followed by the feed subscription links when enabled.
+func (h *AttendanceHandler) respondCalendar(ctx context.Context, w http.ResponseWriter, teamID, userID, period string) {
+	if period != "week" && period != "month" {
+		writeJSON(w, SlashResponse{
+			ResponseType: "ephemeral",
+			Text:         i18n.T(ctx, "calendar.err.usage"),
+		})
+		return
+	}
+
+	from, to := service.CalendarRange(period, time.Now())
+	text, err := h.calendar.RenderTable(ctx, teamID, from, to)
+	if err != nil {
+		log.Printf("ERROR render calendar: %v", err)
+		text = i18n.T(ctx, "calendar.err.load")
+	} else if links := h.feedLinks(ctx, teamID, userID); links != "" {
+		text += "\n\n" + links
+	}
+
+	writeJSON(w, SlashResponse{
+		ResponseType: "ephemeral",
+		Text:         text,
+	})
+}
+
+// respondCalendarReset replies to "/xinphep calendar reset" by replacing the caller's
+// feed key, which revokes the feed links they were given, and showing the new links.
+func (h *AttendanceHandler) respondCalendarReset(ctx context.Context, w http.ResponseWriter, teamID, userID string) {
+	text := i18n.T(ctx, "calendar.reset_done")
+	if err := h.calendar.ResetFeedKey(ctx, userID); err != nil {
+		log.Printf("ERROR reset calendar feed key: %v", err)
+		text = i18n.T(ctx, "calendar.err.reset")
+	} else if links := h.feedLinks(ctx, teamID, userID); links != "" {
+		text += "\n\n" + links
+	}
+
+	writeJSON(w, SlashResponse{
+		ResponseType: "ephemeral",
+		Text:         text,
+	})
+}
+
+// feedLinks returns the feed subscription links issued to a user, or "" if the feed is
+// disabled or the links can't be issued.
+func (h *AttendanceHandler) feedLinks(ctx context.Context, teamID, userID string) string {
+	teamURL, err := h.calendar.FeedURL(ctx, service.CalendarScopeTeam, teamID, userID)
+	if err != nil {
+		log.Printf("ERROR issue calendar feed link: %v", err)
+		return ""
+	}
+	if teamURL == "" {
+		return ""
+	}
+	userURL, err := h.calendar.FeedURL(ctx, service.CalendarScopeUser, userID, userID)
+	if err != nil {
+		log.Printf("ERROR issue calendar feed link: %v", err)
+		return ""
+	}
+	return i18n.T(ctx, "calendar.subscribe", map[string]any{
+		"TeamURL": teamURL,
+		"UserURL": userURL,
+	})
+}
+
 // HandleCheckIn opens the check-in dialog with optional photo upload.
 func (h *AttendanceHandler) HandleCheckIn(w http.ResponseWriter, r *http.Request) {
 	var req ActionRequest
@@ -262,8 +400,8 @@ func (h *AttendanceHandler) HandleCheckIn(w http.ResponseWriter, r *http.Request
 
 	ctx := h.localeCtx(r.Context(), req.UserID)
 
-	if h.isMobileRequest(r) {
-		h.denyMobileAction(ctx, w)
+	if key := h.policyViolation(ctx, r, req.UserID, req.TeamID); key != "" {
+		h.denyAction(ctx, w, key)
 		return
 	}
 
@@ -274,14 +412,7 @@ func (h *AttendanceHandler) HandleCheckIn(w http.ResponseWriter, r *http.Request
 			Title:       i18n.T(ctx, "attendance.dialog.checkin_title"),
 			SubmitLabel: i18n.T(ctx, "attendance.dialog.checkin_submit"),
 			Elements: []mattermost.DialogElement{
-				{
-					DisplayName: i18n.T(ctx, "attendance.field.photo"),
-					Name:        "photo",
-					Type:        "file",
-					Optional:    false,
-					HelpText:    i18n.T(ctx, "attendance.helptext.photo"),
-					Accept:      "image/*",
-				},
+				photoElement(ctx, h.svc.TodayPolicy(ctx, req.UserID, req.TeamID).RequirePhoto),
 			},
 		},
 	})
@@ -308,8 +439,8 @@ func (h *AttendanceHandler) HandleCheckInSubmit(w http.ResponseWriter, r *http.R
 	username := sub.UserName
 	ctx := h.localeCtx(r.Context(), sub.UserID)
 
-	if h.isMobileRequest(r) {
-		h.denyMobileDialog(ctx, w)
+	if key := h.policyViolation(ctx, r, sub.UserID, sub.TeamID); key != "" {
+		h.denyDialog(ctx, w, key)
 		return
 	}
 
@@ -345,15 +476,15 @@ func (h *AttendanceHandler) HandleBreakStart(w http.ResponseWriter, r *http.Requ
 
 	ctx := h.localeCtx(r.Context(), req.UserID)
 
-	if h.isMobileRequest(r) {
-		h.denyMobileAction(ctx, w)
+	if key := h.policyViolation(ctx, r, req.UserID, req.TeamID); key != "" {
+		h.denyAction(ctx, w, key)
 		return
 	}
 
 	reasonKey, _ := req.Context["reason"].(string)
 	device := deviceFromHeaders(r)
 
-	msg, err := h.svc.BreakStart(ctx, req.UserID, req.UserName, reasonKey, device)
+	msg, err := h.svc.BreakStart(ctx, req.UserID, req.UserName, req.TeamID, reasonKey, device)
 	if err != nil {
 		writeJSON(w, ActionResponse{EphemeralText: err.Error()})
 		return
@@ -371,13 +502,13 @@ func (h *AttendanceHandler) HandleBreakEnd(w http.ResponseWriter, r *http.Reques
 
 	ctx := h.localeCtx(r.Context(), req.UserID)
 
-	if h.isMobileRequest(r) {
-		h.denyMobileAction(ctx, w)
+	if key := h.policyViolation(ctx, r, req.UserID, req.TeamID); key != "" {
+		h.denyAction(ctx, w, key)
 		return
 	}
 
 	device := deviceFromHeaders(r)
-	msg, err := h.svc.BreakEnd(ctx, req.UserID, req.UserName, device)
+	msg, err := h.svc.BreakEnd(ctx, req.UserID, req.UserName, req.TeamID, device)
 	if err != nil {
 		writeJSON(w, ActionResponse{EphemeralText: err.Error()})
 		return
@@ -395,8 +526,8 @@ func (h *AttendanceHandler) HandleCheckOut(w http.ResponseWriter, r *http.Reques
 
 	ctx := h.localeCtx(r.Context(), req.UserID)
 
-	if h.isMobileRequest(r) {
-		h.denyMobileAction(ctx, w)
+	if key := h.policyViolation(ctx, r, req.UserID, req.TeamID); key != "" {
+		h.denyAction(ctx, w, key)
 		return
 	}
 
@@ -407,14 +538,7 @@ func (h *AttendanceHandler) HandleCheckOut(w http.ResponseWriter, r *http.Reques
 			Title:       i18n.T(ctx, "attendance.dialog.checkout_title"),
 			SubmitLabel: i18n.T(ctx, "attendance.dialog.checkout_submit"),
 			Elements: []mattermost.DialogElement{
-				{
-					DisplayName: i18n.T(ctx, "attendance.field.photo"),
-					Name:        "photo",
-					Type:        "file",
-					Optional:    false,
-					HelpText:    i18n.T(ctx, "attendance.helptext.photo"),
-					Accept:      "image/*",
-				},
+				photoElement(ctx, h.svc.TodayPolicy(ctx, req.UserID, req.TeamID).RequirePhoto),
 			},
 		},
 	})
@@ -441,8 +565,8 @@ func (h *AttendanceHandler) HandleCheckOutSubmit(w http.ResponseWriter, r *http.
 	username := sub.UserName
 	ctx := h.localeCtx(r.Context(), sub.UserID)
 
-	if h.isMobileRequest(r) {
-		h.denyMobileDialog(ctx, w)
+	if key := h.policyViolation(ctx, r, sub.UserID, sub.TeamID); key != "" {
+		h.denyDialog(ctx, w, key)
 		return
 	}
 
@@ -456,7 +580,7 @@ func (h *AttendanceHandler) HandleCheckOutSubmit(w http.ResponseWriter, r *http.
 	fileID := sub.Submission["photo"]
 	device := deviceFromHeaders(r)
 
-	_, err := h.svc.CheckOut(ctx, sub.UserID, username, fileID, device)
+	_, err := h.svc.CheckOut(ctx, sub.UserID, username, sub.TeamID, fileID, device)
 	if err != nil {
 		log.Printf("ERROR check-out: %v", err)
 		writeJSON(w, map[string]string{"error": err.Error()})
@@ -467,7 +591,8 @@ func (h *AttendanceHandler) HandleCheckOutSubmit(w http.ResponseWriter, r *http.
 }
 
 // buildApproverOptions builds the approver select options from approval channel members.
-func (h *AttendanceHandler) buildApproverOptions(channelID string) []mattermost.SelectOption {
+// Members who have delegated their approvals today are replaced by their delegate.
+func (h *AttendanceHandler) buildApproverOptions(ctx context.Context, channelID string) []mattermost.SelectOption {
 	var options []mattermost.SelectOption
 	channelInfo, err := h.mm.GetChannel(channelID)
 	if err != nil {
@@ -483,7 +608,18 @@ func (h *AttendanceHandler) buildApproverOptions(channelID string) []mattermost.
 	if err != nil {
 		return nil
 	}
+	delegated := h.delegations.ActiveByDelegator(ctx, model.DelegationScopeAttendance)
 	for _, m := range members {
+		if d, ok := delegated[m.UserID]; ok {
+			options = append(options, mattermost.SelectOption{
+				Text: i18n.T(ctx, "delegation.option.on_behalf_of", map[string]any{
+					"Delegate":  d.DelegateUsername,
+					"Delegator": d.DelegatorUsername,
+				}),
+				Value: d.DelegateUsername,
+			})
+			continue
+		}
 		user, err := h.mm.GetUser(m.UserID)
 		if err != nil {
 			continue
@@ -511,6 +647,37 @@ func appendApproverElement(ctx context.Context, elements []mattermost.DialogElem
 	return elements
 }
 
+// attachmentAccept limits supporting documents to photos and PDFs.
+const attachmentAccept = "image/*,application/pdf"
+
+// appendAttachmentElement appends the supporting document field when the leave type takes
+// attachments.
+func appendAttachmentElement(ctx context.Context, elements []mattermost.DialogElement, mode service.AttachmentMode) []mattermost.DialogElement {
+	if mode == service.AttachmentNone {
+		return elements
+	}
+	helpText := i18n.T(ctx, "attendance.helptext.attachment_optional")
+	if mode == service.AttachmentRequired {
+		helpText = i18n.T(ctx, "attendance.helptext.attachment")
+	}
+	return append(elements, mattermost.DialogElement{
+		DisplayName: i18n.T(ctx, "attendance.field.attachment"),
+		Name:        "attachment",
+		Type:        "file",
+		Optional:    mode != service.AttachmentRequired,
+		HelpText:    helpText,
+		Accept:      attachmentAccept,
+	})
+}
+
+// submittedFiles returns the file ID of a dialog file element, if any.
+func submittedFiles(sub DialogSubmission, name string) []string {
+	if id := strings.TrimSpace(sub.Submission[name]); id != "" {
+		return []string{id}
+	}
+	return nil
+}
+
 // HandleLeaveForm opens the leave request dialog.
 func (h *AttendanceHandler) HandleLeaveForm(w http.ResponseWriter, r *http.Request) {
 	var req ActionRequest
@@ -521,7 +688,7 @@ func (h *AttendanceHandler) HandleLeaveForm(w http.ResponseWriter, r *http.Reque
 
 	ctx := h.localeCtx(r.Context(), req.UserID)
 
-	approverOptions := h.buildApproverOptions(req.ChannelID)
+	approverOptions := h.buildApproverOptions(ctx, req.ChannelID)
 
 	elements := []mattermost.DialogElement{
 		{
@@ -559,6 +726,29 @@ func (h *AttendanceHandler) HandleLeaveForm(w http.ResponseWriter, r *http.Reque
 		},
 	}
 
+	elements = appendAttachmentElement(ctx, elements, h.svc.AttachmentMode(model.LeaveTypeOff))
+
+	// Offer paying the leave from comp time only when there is some to spend
+	balance, err := h.svc.CompTimeBalance(ctx, req.UserID)
+	if err != nil {
+		log.Printf("ERROR get comp time balance: %v", err)
+	}
+	if balance > 0 {
+		elements = append(elements, mattermost.DialogElement{
+			DisplayName: i18n.T(ctx, "attendance.field.comp_time"),
+			Name:        "comp_time",
+			Type:        "select",
+			Optional:    true,
+			HelpText: i18n.T(ctx, "attendance.helptext.comp_time", map[string]any{
+				"Hours": fmt.Sprintf("%g", float64(balance*10/60)/10),
+			}),
+			Options: []mattermost.SelectOption{
+				{Text: i18n.T(ctx, "attendance.option.leave_regular"), Value: "false"},
+				{Text: i18n.T(ctx, "attendance.option.leave_comp_time"), Value: "true"},
+			},
+		})
+	}
+
 	if len(approverOptions) > 0 {
 		elements = append(elements, mattermost.DialogElement{
 			DisplayName: i18n.T(ctx, "attendance.field.approver"),
@@ -620,6 +810,8 @@ func (h *AttendanceHandler) HandleLeaveSubmit(w http.ResponseWriter, r *http.Req
 		sub.Submission["reason"],
 		"",
 		approver,
+		submittedFiles(sub, "attachment"),
+		sub.Submission["comp_time"] == "true",
 	)
 	if err != nil {
 		log.Printf("ERROR create leave request: %v", err)
@@ -661,7 +853,8 @@ func (h *AttendanceHandler) HandleLateArrivalForm(w http.ResponseWriter, r *http
 			Placeholder: i18n.T(ctx, "attendance.placeholder.reason"),
 		},
 	}
-	elements = appendApproverElement(ctx, elements, h.buildApproverOptions(req.ChannelID))
+	elements = appendAttachmentElement(ctx, elements, h.svc.AttachmentMode(model.LeaveTypeLateArrival))
+	elements = appendApproverElement(ctx, elements, h.buildApproverOptions(ctx, req.ChannelID))
 
 	if err := h.mm.OpenDialog(&mattermost.DialogRequest{
 		TriggerID: req.TriggerID,
@@ -705,6 +898,8 @@ func (h *AttendanceHandler) HandleLateArrivalSubmit(w http.ResponseWriter, r *ht
 		sub.Submission["reason"],
 		sub.Submission["time"],
 		approver,
+		submittedFiles(sub, "attachment"),
+		false,
 	)
 	if err != nil {
 		log.Printf("ERROR create late arrival request: %v", err)
@@ -746,7 +941,8 @@ func (h *AttendanceHandler) HandleEarlyDepartureForm(w http.ResponseWriter, r *h
 			Placeholder: i18n.T(ctx, "attendance.placeholder.reason"),
 		},
 	}
-	elements = appendApproverElement(ctx, elements, h.buildApproverOptions(req.ChannelID))
+	elements = appendAttachmentElement(ctx, elements, h.svc.AttachmentMode(model.LeaveTypeEarlyDeparture))
+	elements = appendApproverElement(ctx, elements, h.buildApproverOptions(ctx, req.ChannelID))
 
 	if err := h.mm.OpenDialog(&mattermost.DialogRequest{
 		TriggerID: req.TriggerID,
@@ -790,6 +986,8 @@ func (h *AttendanceHandler) HandleEarlyDepartureSubmit(w http.ResponseWriter, r
 		sub.Submission["reason"],
 		sub.Submission["time"],
 		approver,
+		submittedFiles(sub, "attachment"),
+		false,
 	)
 	if err != nil {
 		log.Printf("ERROR create early departure request: %v", err)
@@ -800,8 +998,8 @@ func (h *AttendanceHandler) HandleEarlyDepartureSubmit(w http.ResponseWriter, r
 	w.WriteHeader(http.StatusOK)
 }
 
-// HandleApprove handles the approve button click.
-func (h *AttendanceHandler) HandleApprove(w http.ResponseWriter, r *http.Request) {
+// HandleOvertimeForm opens the overtime request dialog.
+func (h *AttendanceHandler) HandleOvertimeForm(w http.ResponseWriter, r *http.Request) {
 	var req ActionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
@@ -810,72 +1008,57 @@ func (h *AttendanceHandler) HandleApprove(w http.ResponseWriter, r *http.Request
 
 	ctx := h.localeCtx(r.Context(), req.UserID)
 
-	requestID, _ := req.Context["request_id"].(string)
-	if requestID == "" {
-		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.missing_id")})
-		return
-	}
-
-	result, err := h.svc.ApproveLeave(ctx, requestID, req.UserID, req.UserName)
-	if err != nil {
-		writeJSON(w, ActionResponse{EphemeralText: err.Error()})
-		return
-	}
-
-	writeJSON(w, ActionResponse{
-		Update: &ActionUpdate{
-			Props: &mattermost.Props{
-				MessageKey:  result.MessageKey,
-				MessageData: result.MessageData,
-				Attachments: []mattermost.Attachment{},
+	elements := []mattermost.DialogElement{
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.date"),
+			Name:        "date",
+			Type:        "text",
+			SubType:     "date",
+			Placeholder: i18n.T(ctx, "attendance.placeholder.dates"),
+		},
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.planned_hours"),
+			Name:        "planned_hours",
+			Type:        "text",
+			Placeholder: i18n.T(ctx, "attendance.placeholder.planned_hours"),
+		},
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.compensatory"),
+			Name:        "compensatory",
+			Type:        "select",
+			HelpText:    i18n.T(ctx, "attendance.helptext.compensatory"),
+			Options: []mattermost.SelectOption{
+				{Text: i18n.T(ctx, "attendance.option.overtime_pay"), Value: "false"},
+				{Text: i18n.T(ctx, "attendance.option.overtime_comp_time"), Value: "true"},
 			},
 		},
-	})
-}
-
-// HandleReject opens a dialog asking for the rejection reason.
-func (h *AttendanceHandler) HandleReject(w http.ResponseWriter, r *http.Request) {
-	var req ActionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
-		return
-	}
-
-	ctx := h.localeCtx(r.Context(), req.UserID)
-
-	requestID, _ := req.Context["request_id"].(string)
-	if requestID == "" {
-		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.missing_id")})
-		return
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.reason"),
+			Name:        "reason",
+			Type:        "textarea",
+			Placeholder: i18n.T(ctx, "attendance.placeholder.reason"),
+		},
 	}
+	elements = appendApproverElement(ctx, elements, h.buildApproverOptions(ctx, req.ChannelID))
 
-	err := h.mm.OpenDialog(&mattermost.DialogRequest{
+	if err := h.mm.OpenDialog(&mattermost.DialogRequest{
 		TriggerID: req.TriggerID,
-		URL:       h.botURL + "/api/attendance/reject-submit",
+		URL:       h.botURL + "/api/attendance/overtime",
 		Dialog: mattermost.Dialog{
-			CallbackID:  requestID,
-			Title:       i18n.T(ctx, "attendance.dialog.reject_title"),
-			SubmitLabel: i18n.T(ctx, "attendance.dialog.reject_submit"),
-			Elements: []mattermost.DialogElement{
-				{
-					DisplayName: i18n.T(ctx, "attendance.field.reason"),
-					Name:        "reason",
-					Type:        "textarea",
-					Placeholder: i18n.T(ctx, "attendance.placeholder.reject"),
-				},
-			},
+			Title:       i18n.T(ctx, "attendance.dialog.overtime_title"),
+			SubmitLabel: i18n.T(ctx, "attendance.dialog.submit"),
+			Elements:    elements,
 		},
-	})
-	if err != nil {
-		log.Printf("ERROR open reject dialog: %v", err)
+	}); err != nil {
+		log.Printf("ERROR open overtime dialog: %v", err)
 		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
 		return
 	}
 	writeJSON(w, ActionResponse{})
 }
 
-// HandleRejectSubmit processes the reject dialog submission.
-func (h *AttendanceHandler) HandleRejectSubmit(w http.ResponseWriter, r *http.Request) {
+// HandleOvertimeSubmit processes the overtime dialog submission.
+func (h *AttendanceHandler) HandleOvertimeSubmit(w http.ResponseWriter, r *http.Request) {
 	var sub DialogSubmission
 	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
@@ -886,25 +1069,23 @@ func (h *AttendanceHandler) HandleRejectSubmit(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	requestID := sub.CallbackID
-	if requestID == "" {
-		writeJSON(w, map[string]string{"error": "Missing request ID"})
-		return
-	}
-
 	ctx := h.localeCtx(r.Context(), sub.UserID)
 
-	username := sub.UserName
-	if username == "" {
-		user, err := h.mm.GetUser(sub.UserID)
-		if err == nil {
-			username = user.Username
-		}
-	}
+	approver := strings.TrimSpace(sub.Submission["approver"])
 
-	err := h.svc.RejectLeave(ctx, requestID, sub.UserID, username, sub.Submission["reason"])
+	err := h.svc.CreateOvertimeRequest(
+		ctx,
+		sub.UserID,
+		sub.UserName,
+		sub.ChannelID,
+		sub.Submission["date"],
+		sub.Submission["planned_hours"],
+		sub.Submission["compensatory"] == "true",
+		sub.Submission["reason"],
+		approver,
+	)
 	if err != nil {
-		log.Printf("ERROR reject leave: %v", err)
+		log.Printf("ERROR create overtime request: %v", err)
 		writeJSON(w, map[string]string{"error": err.Error()})
 		return
 	}
@@ -912,8 +1093,8 @@ func (h *AttendanceHandler) HandleRejectSubmit(w http.ResponseWriter, r *http.Re
 	w.WriteHeader(http.StatusOK)
 }
 
-// HandleChangeDatesForm opens the change dates dialog.
-func (h *AttendanceHandler) HandleChangeDatesForm(w http.ResponseWriter, r *http.Request) {
+// HandleWorkModeForm opens the remote work / business trip request dialog.
+func (h *AttendanceHandler) HandleWorkModeForm(w http.ResponseWriter, r *http.Request) {
 	var req ActionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
@@ -922,78 +1103,416 @@ func (h *AttendanceHandler) HandleChangeDatesForm(w http.ResponseWriter, r *http
 
 	ctx := h.localeCtx(r.Context(), req.UserID)
 
-	leaves, err := h.svc.GetUserFutureLeaves(ctx, req.UserID)
-	if err != nil {
-		log.Printf("ERROR get future leaves: %v", err)
-		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
-		return
-	}
-
-	// Build options: each option is one future date from a leave request
-	today := time.Now().Format(time.DateOnly)
-	var options []mattermost.SelectOption
-	for _, l := range leaves {
-		for _, d := range l.Dates {
-			if d < today {
-				continue
-			}
-			label := fmt.Sprintf("%s (%s)",
-				model.FormatDateDisplay(d),
-				i18n.T(ctx, leaveStatusI18nKey(l.Status)),
-			)
-			options = append(options, mattermost.SelectOption{
-				Text:  label,
-				Value: l.ID.Hex() + ":" + d,
-			})
-		}
-	}
-
-	if len(options) == 0 {
-		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.no_future_leaves")})
-		return
-	}
-
 	elements := []mattermost.DialogElement{
 		{
-			DisplayName: i18n.T(ctx, "attendance.field.select_date"),
-			Name:        "old_date",
+			DisplayName: i18n.T(ctx, "attendance.field.mode"),
+			Name:        "mode",
 			Type:        "select",
-			Options:     options,
+			Options: []mattermost.SelectOption{
+				{Text: i18n.T(ctx, "leave.type.remote"), Value: string(model.LeaveTypeRemote)},
+				{Text: i18n.T(ctx, "leave.type.business_trip"), Value: string(model.LeaveTypeBusinessTrip)},
+			},
 		},
 		{
-			DisplayName: i18n.T(ctx, "attendance.field.new_date"),
-			Name:        "new_date",
+			DisplayName: i18n.T(ctx, "attendance.field.date1"),
+			Name:        "date1",
 			Type:        "text",
 			SubType:     "date",
 		},
 		{
-			DisplayName: i18n.T(ctx, "attendance.field.change_reason"),
-			Name:        "change_reason",
-			Type:        "textarea",
-			Placeholder: i18n.T(ctx, "attendance.placeholder.change_reason"),
-		},
-	}
-	elements = appendApproverElement(ctx, elements, h.buildApproverOptions(req.ChannelID))
-
-	err = h.mm.OpenDialog(&mattermost.DialogRequest{
-		TriggerID: req.TriggerID,
-		URL:       h.botURL + "/api/attendance/change-submit",
-		Dialog: mattermost.Dialog{
-			Title:       i18n.T(ctx, "attendance.dialog.change_title"),
-			SubmitLabel: i18n.T(ctx, "attendance.dialog.submit"),
-			Elements:    elements,
+			DisplayName: i18n.T(ctx, "attendance.field.date2"),
+			Name:        "date2",
+			Type:        "text",
+			SubType:     "date",
+			Optional:    true,
 		},
-	})
-	if err != nil {
-		log.Printf("ERROR open change dialog: %v", err)
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.date3"),
+			Name:        "date3",
+			Type:        "text",
+			SubType:     "date",
+			Optional:    true,
+		},
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.date4"),
+			Name:        "date4",
+			Type:        "text",
+			SubType:     "date",
+			Optional:    true,
+		},
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.reason"),
+			Name:        "reason",
+			Type:        "textarea",
+			Placeholder: i18n.T(ctx, "attendance.placeholder.work_mode_reason"),
+		},
+	}
+	elements = appendApproverElement(ctx, elements, h.buildApproverOptions(ctx, req.ChannelID))
+
+	if err := h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: req.TriggerID,
+		URL:       h.botURL + "/api/attendance/work-mode",
+		Dialog: mattermost.Dialog{
+			Title:       i18n.T(ctx, "attendance.dialog.work_mode_title"),
+			SubmitLabel: i18n.T(ctx, "attendance.dialog.submit"),
+			Elements:    elements,
+		},
+	}); err != nil {
+		log.Printf("ERROR open work mode dialog: %v", err)
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
+		return
+	}
+	writeJSON(w, ActionResponse{})
+}
+
+// HandleWorkModeSubmit processes the remote work / business trip dialog submission.
+func (h *AttendanceHandler) HandleWorkModeSubmit(w http.ResponseWriter, r *http.Request) {
+	var sub DialogSubmission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if sub.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), sub.UserID)
+
+	var dates []string
+	for _, key := range []string{"date1", "date2", "date3", "date4"} {
+		if d := strings.TrimSpace(sub.Submission[key]); d != "" {
+			dates = append(dates, d)
+		}
+	}
+
+	approver := strings.TrimSpace(sub.Submission["approver"])
+
+	err := h.svc.CreateWorkModeRequest(
+		ctx,
+		sub.UserID,
+		sub.UserName,
+		sub.ChannelID,
+		model.LeaveType(sub.Submission["mode"]),
+		dates,
+		sub.Submission["reason"],
+		approver,
+	)
+	if err != nil {
+		log.Printf("ERROR create work mode request: %v", err)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleApprove handles the approve button click.
+func (h *AttendanceHandler) HandleApprove(w http.ResponseWriter, r *http.Request) {
+	var req ActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), req.UserID)
+
+	requestID, _ := req.Context["request_id"].(string)
+	if requestID == "" {
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.missing_id")})
+		return
+	}
+
+	result, err := h.svc.ApproveLeave(ctx, requestID, req.UserID, req.UserName)
+	if err != nil {
+		writeJSON(w, ActionResponse{EphemeralText: err.Error()})
+		return
+	}
+
+	writeJSON(w, ActionResponse{
+		Update: &ActionUpdate{
+			Props: &mattermost.Props{
+				MessageKey:  result.MessageKey,
+				MessageData: result.MessageData,
+				Attachments: []mattermost.Attachment{},
+			},
+		},
+	})
+}
+
+// HandleReject opens a dialog asking for the rejection reason.
+func (h *AttendanceHandler) HandleReject(w http.ResponseWriter, r *http.Request) {
+	var req ActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), req.UserID)
+
+	requestID, _ := req.Context["request_id"].(string)
+	if requestID == "" {
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.missing_id")})
+		return
+	}
+
+	err := h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: req.TriggerID,
+		URL:       h.botURL + "/api/attendance/reject-submit",
+		Dialog: mattermost.Dialog{
+			CallbackID:  requestID,
+			Title:       i18n.T(ctx, "attendance.dialog.reject_title"),
+			SubmitLabel: i18n.T(ctx, "attendance.dialog.reject_submit"),
+			Elements: []mattermost.DialogElement{
+				{
+					DisplayName: i18n.T(ctx, "attendance.field.reason"),
+					Name:        "reason",
+					Type:        "textarea",
+					Placeholder: i18n.T(ctx, "attendance.placeholder.reject"),
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("ERROR open reject dialog: %v", err)
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
+		return
+	}
+	writeJSON(w, ActionResponse{})
+}
+
+// HandleRejectSubmit processes the reject dialog submission.
+func (h *AttendanceHandler) HandleRejectSubmit(w http.ResponseWriter, r *http.Request) {
+	var sub DialogSubmission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if sub.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	requestID := sub.CallbackID
+	if requestID == "" {
+		writeJSON(w, map[string]string{"error": "Missing request ID"})
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), sub.UserID)
+
+	username := sub.UserName
+	if username == "" {
+		user, err := h.mm.GetUser(sub.UserID)
+		if err == nil {
+			username = user.Username
+		}
+	}
+
+	err := h.svc.RejectLeave(ctx, requestID, sub.UserID, username, sub.Submission["reason"])
+	if err != nil {
+		log.Printf("ERROR reject leave: %v", err)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleChangeDatesForm opens the change dates dialog.
+func (h *AttendanceHandler) HandleChangeDatesForm(w http.ResponseWriter, r *http.Request) {
+	var req ActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), req.UserID)
+
+	leaves, err := h.svc.GetUserFutureLeaves(ctx, req.UserID)
+	if err != nil {
+		log.Printf("ERROR get future leaves: %v", err)
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
+		return
+	}
+
+	// Build options: each option is one future date from a leave request
+	today := time.Now().In(vnTZ).Format(time.DateOnly)
+	var options []mattermost.SelectOption
+	for _, l := range leaves {
+		for _, d := range l.Dates {
+			if d < today {
+				continue
+			}
+			label := fmt.Sprintf("%s (%s)",
+				model.FormatDateDisplay(d),
+				i18n.T(ctx, leaveStatusI18nKey(l.Status)),
+			)
+			options = append(options, mattermost.SelectOption{
+				Text:  label,
+				Value: l.ID.Hex() + ":" + d,
+			})
+		}
+	}
+
+	if len(options) == 0 {
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.no_future_leaves")})
+		return
+	}
+
+	elements := []mattermost.DialogElement{
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.select_date"),
+			Name:        "old_date",
+			Type:        "select",
+			Options:     options,
+		},
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.new_date"),
+			Name:        "new_date",
+			Type:        "text",
+			SubType:     "date",
+		},
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.change_reason"),
+			Name:        "change_reason",
+			Type:        "textarea",
+			Placeholder: i18n.T(ctx, "attendance.placeholder.change_reason"),
+		},
+	}
+	elements = appendApproverElement(ctx, elements, h.buildApproverOptions(ctx, req.ChannelID))
+
+	err = h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: req.TriggerID,
+		URL:       h.botURL + "/api/attendance/change-submit",
+		Dialog: mattermost.Dialog{
+			Title:       i18n.T(ctx, "attendance.dialog.change_title"),
+			SubmitLabel: i18n.T(ctx, "attendance.dialog.submit"),
+			Elements:    elements,
+		},
+	})
+	if err != nil {
+		log.Printf("ERROR open change dialog: %v", err)
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
+		return
+	}
+	writeJSON(w, ActionResponse{})
+}
+
+// HandleChangeDatesSubmit processes the change dates dialog submission.
+func (h *AttendanceHandler) HandleChangeDatesSubmit(w http.ResponseWriter, r *http.Request) {
+	var sub DialogSubmission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if sub.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), sub.UserID)
+
+	// Value is "requestID:oldDate"
+	selection := sub.Submission["old_date"]
+	parts := strings.SplitN(selection, ":", 2)
+	if len(parts) != 2 {
+		writeJSON(w, map[string]string{"error": i18n.T(ctx, "attendance.err.missing_id")})
+		return
+	}
+	leaveID := parts[0]
+	oldDate := parts[1]
+
+	newDate := strings.TrimSpace(sub.Submission["new_date"])
+	changeReason := sub.Submission["change_reason"]
+	approver := strings.TrimSpace(sub.Submission["approver"])
+
+	err := h.svc.RequestDateChange(ctx, leaveID, sub.UserID, oldDate, newDate, changeReason, approver)
+	if err != nil {
+		log.Printf("ERROR change dates: %v", err)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleChangeApprove handles the approve button click for a date change.
+func (h *AttendanceHandler) HandleChangeApprove(w http.ResponseWriter, r *http.Request) {
+	var req ActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), req.UserID)
+
+	requestID, _ := req.Context["request_id"].(string)
+	if requestID == "" {
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.missing_id")})
+		return
+	}
+
+	result, err := h.svc.ApproveDateChange(ctx, requestID, req.UserID, req.UserName)
+	if err != nil {
+		writeJSON(w, ActionResponse{EphemeralText: err.Error()})
+		return
+	}
+
+	writeJSON(w, ActionResponse{
+		Update: &ActionUpdate{
+			Props: &mattermost.Props{
+				MessageKey:  result.MessageKey,
+				MessageData: result.MessageData,
+				Attachments: []mattermost.Attachment{},
+			},
+		},
+	})
+}
+
+// HandleChangeReject opens a dialog asking for the change rejection reason.
+func (h *AttendanceHandler) HandleChangeReject(w http.ResponseWriter, r *http.Request) {
+	var req ActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), req.UserID)
+
+	requestID, _ := req.Context["request_id"].(string)
+	if requestID == "" {
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.missing_id")})
+		return
+	}
+
+	err := h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: req.TriggerID,
+		URL:       h.botURL + "/api/attendance/change-reject-submit",
+		Dialog: mattermost.Dialog{
+			CallbackID:  requestID,
+			Title:       i18n.T(ctx, "attendance.dialog.reject_change_title"),
+			SubmitLabel: i18n.T(ctx, "attendance.dialog.reject_submit"),
+			Elements: []mattermost.DialogElement{
+				{
+					DisplayName: i18n.T(ctx, "attendance.field.reason"),
+					Name:        "reason",
+					Type:        "textarea",
+					Placeholder: i18n.T(ctx, "attendance.placeholder.reject"),
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("ERROR open change reject dialog: %v", err)
 		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
 		return
 	}
 	writeJSON(w, ActionResponse{})
 }
 
-// HandleChangeDatesSubmit processes the change dates dialog submission.
-func (h *AttendanceHandler) HandleChangeDatesSubmit(w http.ResponseWriter, r *http.Request) {
+// HandleChangeRejectSubmit processes the change rejection dialog submission.
+func (h *AttendanceHandler) HandleChangeRejectSubmit(w http.ResponseWriter, r *http.Request) {
 	var sub DialogSubmission
 	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
@@ -1004,25 +1523,150 @@ func (h *AttendanceHandler) HandleChangeDatesSubmit(w http.ResponseWriter, r *ht
 		return
 	}
 
+	requestID := sub.CallbackID
+	if requestID == "" {
+		writeJSON(w, map[string]string{"error": "Missing request ID"})
+		return
+	}
+
 	ctx := h.localeCtx(r.Context(), sub.UserID)
 
-	// Value is "requestID:oldDate"
-	selection := sub.Submission["old_date"]
-	parts := strings.SplitN(selection, ":", 2)
+	username := sub.UserName
+	if username == "" {
+		user, err := h.mm.GetUser(sub.UserID)
+		if err == nil {
+			username = user.Username
+		}
+	}
+
+	err := h.svc.RejectDateChange(ctx, requestID, sub.UserID, username, sub.Submission["reason"])
+	if err != nil {
+		log.Printf("ERROR reject date change: %v", err)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleCancelForm opens the cancel/withdraw dialog listing the user's future requests.
+func (h *AttendanceHandler) HandleCancelForm(w http.ResponseWriter, r *http.Request) {
+	var req ActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), req.UserID)
+
+	leaves, err := h.svc.GetUserFutureLeaves(ctx, req.UserID)
+	if err != nil {
+		log.Printf("ERROR get future leaves: %v", err)
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
+		return
+	}
+
+	// Build options: a whole request ("requestID:") or one of its future dates ("requestID:date")
+	today := time.Now().In(vnTZ).Format(time.DateOnly)
+	var options []mattermost.SelectOption
+	for _, l := range leaves {
+		var future []string
+		for _, d := range l.Dates {
+			if d >= today {
+				future = append(future, d)
+			}
+		}
+		leaveType := i18n.T(ctx, leaveTypeI18nKey(l.Type))
+		status := i18n.T(ctx, leaveStatusI18nKey(l.Status))
+		if len(future) > 1 {
+			display := make([]string, len(future))
+			for i, d := range future {
+				display[i] = model.FormatDateDisplay(d)
+			}
+			options = append(options, mattermost.SelectOption{
+				Text: i18n.T(ctx, "attendance.option.cancel_all", map[string]any{
+					"Type": leaveType, "Dates": strings.Join(display, ", "), "Status": status,
+				}),
+				Value: l.ID.Hex() + ":",
+			})
+		}
+		for _, d := range future {
+			options = append(options, mattermost.SelectOption{
+				Text:  fmt.Sprintf("%s — %s (%s)", model.FormatDateDisplay(d), leaveType, status),
+				Value: l.ID.Hex() + ":" + d,
+			})
+		}
+	}
+
+	if len(options) == 0 {
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.no_future_leaves")})
+		return
+	}
+
+	elements := []mattermost.DialogElement{
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.cancel_selection"),
+			Name:        "selection",
+			Type:        "select",
+			Options:     options,
+			HelpText:    i18n.T(ctx, "attendance.helptext.cancel_selection"),
+		},
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.cancel_reason"),
+			Name:        "cancel_reason",
+			Type:        "textarea",
+			Placeholder: i18n.T(ctx, "attendance.placeholder.cancel_reason"),
+		},
+	}
+	elements = appendApproverElement(ctx, elements, h.buildApproverOptions(ctx, req.ChannelID))
+
+	err = h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: req.TriggerID,
+		URL:       h.botURL + "/api/attendance/cancel-submit",
+		Dialog: mattermost.Dialog{
+			Title:       i18n.T(ctx, "attendance.dialog.cancel_title"),
+			SubmitLabel: i18n.T(ctx, "attendance.dialog.submit"),
+			Elements:    elements,
+		},
+	})
+	if err != nil {
+		log.Printf("ERROR open cancel dialog: %v", err)
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
+		return
+	}
+	writeJSON(w, ActionResponse{})
+}
+
+// HandleCancelSubmit processes the cancel/withdraw dialog submission.
+func (h *AttendanceHandler) HandleCancelSubmit(w http.ResponseWriter, r *http.Request) {
+	var sub DialogSubmission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if sub.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), sub.UserID)
+
+	// Value is "requestID:date", or "requestID:" for the whole request
+	parts := strings.SplitN(sub.Submission["selection"], ":", 2)
 	if len(parts) != 2 {
 		writeJSON(w, map[string]string{"error": i18n.T(ctx, "attendance.err.missing_id")})
 		return
 	}
-	leaveID := parts[0]
-	oldDate := parts[1]
+	var dates []string
+	if parts[1] != "" {
+		dates = []string{parts[1]}
+	}
 
-	newDate := strings.TrimSpace(sub.Submission["new_date"])
-	changeReason := sub.Submission["change_reason"]
 	approver := strings.TrimSpace(sub.Submission["approver"])
 
-	err := h.svc.RequestDateChange(ctx, leaveID, sub.UserID, oldDate, newDate, changeReason, approver)
+	err := h.svc.RequestCancellation(ctx, parts[0], sub.UserID, dates, sub.Submission["cancel_reason"], approver)
 	if err != nil {
-		log.Printf("ERROR change dates: %v", err)
+		log.Printf("ERROR cancel leave: %v", err)
 		writeJSON(w, map[string]string{"error": err.Error()})
 		return
 	}
@@ -1030,8 +1674,8 @@ func (h *AttendanceHandler) HandleChangeDatesSubmit(w http.ResponseWriter, r *ht
 	w.WriteHeader(http.StatusOK)
 }
 
-// HandleChangeApprove handles the approve button click for a date change.
-func (h *AttendanceHandler) HandleChangeApprove(w http.ResponseWriter, r *http.Request) {
+// HandleCancelApprove handles the approve button click for a cancellation.
+func (h *AttendanceHandler) HandleCancelApprove(w http.ResponseWriter, r *http.Request) {
 	var req ActionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
@@ -1046,7 +1690,7 @@ func (h *AttendanceHandler) HandleChangeApprove(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	result, err := h.svc.ApproveDateChange(ctx, requestID, req.UserID, req.UserName)
+	result, err := h.svc.ApproveCancellation(ctx, requestID, req.UserID, req.UserName)
 	if err != nil {
 		writeJSON(w, ActionResponse{EphemeralText: err.Error()})
 		return
@@ -1063,8 +1707,8 @@ func (h *AttendanceHandler) HandleChangeApprove(w http.ResponseWriter, r *http.R
 	})
 }
 
-// HandleChangeReject opens a dialog asking for the change rejection reason.
-func (h *AttendanceHandler) HandleChangeReject(w http.ResponseWriter, r *http.Request) {
+// HandleCancelReject opens a dialog asking for the cancellation rejection reason.
+func (h *AttendanceHandler) HandleCancelReject(w http.ResponseWriter, r *http.Request) {
 	var req ActionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
@@ -1081,10 +1725,10 @@ func (h *AttendanceHandler) HandleChangeReject(w http.ResponseWriter, r *http.Re
 
 	err := h.mm.OpenDialog(&mattermost.DialogRequest{
 		TriggerID: req.TriggerID,
-		URL:       h.botURL + "/api/attendance/change-reject-submit",
+		URL:       h.botURL + "/api/attendance/cancel-reject-submit",
 		Dialog: mattermost.Dialog{
 			CallbackID:  requestID,
-			Title:       i18n.T(ctx, "attendance.dialog.reject_change_title"),
+			Title:       i18n.T(ctx, "attendance.dialog.reject_cancel_title"),
 			SubmitLabel: i18n.T(ctx, "attendance.dialog.reject_submit"),
 			Elements: []mattermost.DialogElement{
 				{
@@ -1097,15 +1741,15 @@ func (h *AttendanceHandler) HandleChangeReject(w http.ResponseWriter, r *http.Re
 		},
 	})
 	if err != nil {
-		log.Printf("ERROR open change reject dialog: %v", err)
+		log.Printf("ERROR open cancel reject dialog: %v", err)
 		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
 		return
 	}
 	writeJSON(w, ActionResponse{})
 }
 
-// HandleChangeRejectSubmit processes the change rejection dialog submission.
-func (h *AttendanceHandler) HandleChangeRejectSubmit(w http.ResponseWriter, r *http.Request) {
+// HandleCancelRejectSubmit processes the cancellation rejection dialog submission.
+func (h *AttendanceHandler) HandleCancelRejectSubmit(w http.ResponseWriter, r *http.Request) {
 	var sub DialogSubmission
 	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
@@ -1132,13 +1776,122 @@ func (h *AttendanceHandler) HandleChangeRejectSubmit(w http.ResponseWriter, r *h
 		}
 	}
 
-	err := h.svc.RejectDateChange(ctx, requestID, sub.UserID, username, sub.Submission["reason"])
+	err := h.svc.RejectCancellation(ctx, requestID, sub.UserID, username, sub.Submission["reason"])
 	if err != nil {
-		log.Printf("ERROR reject date change: %v", err)
+		log.Printf("ERROR reject cancellation: %v", err)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleDelegateForm opens the dialog for delegating approvals to another user.
+func (h *AttendanceHandler) HandleDelegateForm(w http.ResponseWriter, r *http.Request) {
+	var req ActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), req.UserID)
+
+	today := time.Now().In(vnTZ).Format(time.DateOnly)
+	elements := []mattermost.DialogElement{
+		{
+			DisplayName: i18n.T(ctx, "delegation.field.delegate"),
+			Name:        "delegate",
+			Type:        "select",
+			DataSource:  "users",
+			Placeholder: i18n.T(ctx, "delegation.placeholder.delegate"),
+		},
+		{
+			DisplayName: i18n.T(ctx, "delegation.field.scope"),
+			Name:        "scope",
+			Type:        "select",
+			Options: []mattermost.SelectOption{
+				{Text: i18n.T(ctx, "delegation.scope.all"), Value: string(model.DelegationScopeAll)},
+				{Text: i18n.T(ctx, "delegation.scope.attendance"), Value: string(model.DelegationScopeAttendance)},
+				{Text: i18n.T(ctx, "delegation.scope.budget"), Value: string(model.DelegationScopeBudget)},
+			},
+		},
+		{
+			DisplayName: i18n.T(ctx, "delegation.field.from"),
+			Name:        "from",
+			Type:        "text",
+			SubType:     "date",
+			Placeholder: today,
+		},
+		{
+			DisplayName: i18n.T(ctx, "delegation.field.to"),
+			Name:        "to",
+			Type:        "text",
+			SubType:     "date",
+			Placeholder: today,
+		},
+		{
+			DisplayName: i18n.T(ctx, "attendance.field.reason"),
+			Name:        "reason",
+			Type:        "textarea",
+			Optional:    true,
+		},
+	}
+
+	if err := h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: req.TriggerID,
+		URL:       h.botURL + "/api/attendance/delegate",
+		Dialog: mattermost.Dialog{
+			Title:       i18n.T(ctx, "delegation.dialog.title"),
+			SubmitLabel: i18n.T(ctx, "attendance.dialog.submit"),
+			Elements:    elements,
+		},
+	}); err != nil {
+		log.Printf("ERROR open delegate dialog: %v", err)
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
+		return
+	}
+	writeJSON(w, ActionResponse{})
+}
+
+// HandleDelegateSubmit processes the delegation dialog submission.
+func (h *AttendanceHandler) HandleDelegateSubmit(w http.ResponseWriter, r *http.Request) {
+	var sub DialogSubmission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if sub.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), sub.UserID)
+
+	d, err := h.delegations.CreateDelegation(
+		ctx,
+		sub.UserID,
+		sub.UserName,
+		sub.Submission["delegate"],
+		model.DelegationScope(sub.Submission["scope"]),
+		strings.TrimSpace(sub.Submission["from"]),
+		strings.TrimSpace(sub.Submission["to"]),
+		sub.Submission["reason"],
+	)
+	if err != nil {
+		log.Printf("ERROR create delegation: %v", err)
 		writeJSON(w, map[string]string{"error": err.Error()})
 		return
 	}
 
+	if err := h.mm.SendDM(sub.UserID, i18n.T(ctx, "delegation.msg.created", map[string]any{
+		"Delegate": d.DelegateUsername,
+		"Scope":    i18n.T(ctx, "delegation.scope."+string(d.Scope)),
+		"From":     model.FormatDateDisplay(d.From),
+		"To":       model.FormatDateDisplay(d.To),
+	})); err != nil {
+		log.Printf("ERROR notify delegator: %v", err)
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -1150,6 +1903,12 @@ func leaveTypeI18nKey(lt model.LeaveType) string {
 		return "leave.type.late"
 	case model.LeaveTypeEarlyDeparture:
 		return "leave.type.early"
+	case model.LeaveTypeOvertime:
+		return "leave.type.overtime"
+	case model.LeaveTypeRemote:
+		return "leave.type.remote"
+	case model.LeaveTypeBusinessTrip:
+		return "leave.type.business_trip"
 	default:
 		return "leave.type.off"
 	}
@@ -1166,6 +1925,71 @@ func leaveStatusI18nKey(s model.LeaveStatus) string {
 	}
 }
 
+// HandleCalendarFeed serves the leave calendar as an iCalendar feed, through the plugin's
+// Mattermost route only (see CalendarService.FeedEnabled).
+// Query params: user (the user the link was issued to), team (team ID) for the team feed
+// rather than the user's own, and token (the signature issued by /xinphep calendar).
+func (h *AttendanceHandler) HandleCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	userID := q.Get("user")
+	scope, id := service.CalendarScopeUser, userID
+	if teamID := q.Get("team"); teamID != "" {
+		scope, id = service.CalendarScopeTeam, teamID
+	}
+	ok, err := h.calendar.VerifyFeedToken(r.Context(), scope, id, userID, q.Get("token"))
+	if err != nil {
+		log.Printf("ERROR verify calendar feed token: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	// The URL outlives the session it was issued in, so check the user is still allowed
+	// to see the calendar on every fetch.
+	allowed, err := h.feedAllowed(scope, id, userID)
+	if err != nil {
+		log.Printf("ERROR check calendar feed access: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	ics, err := h.calendar.RenderICS(r.Context(), scope, id)
+	if err != nil {
+		log.Printf("ERROR render calendar feed: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="leave.ics"`)
+	w.Write(ics)
+}
+
+// feedAllowed reports whether userID is active and, for the team feed, still a member of
+// the team.
+func (h *AttendanceHandler) feedAllowed(scope, id, userID string) (bool, error) {
+	user, err := h.mm.GetUserFresh(userID)
+	if errors.Is(err, mattermost.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !user.IsActive() {
+		return false, nil
+	}
+	if scope != service.CalendarScopeTeam {
+		return true, nil
+	}
+	return h.mm.IsTeamMember(id, userID)
+}
+
 // HandleReport returns attendance statistics filtered by date range and optionally by user, team and/or channel.
 // Query params: from (YYYY-MM-DD, required), to (YYYY-MM-DD, required), user_id (optional), team_id (optional), channel_id (optional).
 func (h *AttendanceHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
@@ -1222,12 +2046,16 @@ func (h *AttendanceHandler) HandleActivityConfirm(w http.ResponseWriter, r *http
 		return
 	}
 
-	if h.isMobileRequest(r) {
-		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "activity.check.mobile_blocked")})
+	if key := h.policyViolation(ctx, r, req.UserID, req.TeamID); key != "" {
+		if key == "attendance.err.mobile_blocked" {
+			key = "activity.check.mobile_blocked"
+		}
+		h.denyAction(ctx, w, key)
 		return
 	}
 
-	result := h.activityChecker.HandleConfirm(ctx, req.UserID)
+	date, _ := req.Context["date"].(string)
+	result := h.activityChecker.HandleConfirm(ctx, req.UserID, date)
 	switch result {
 	case model.ActivityCheckConfirmed:
 		writeJSON(w, ActionResponse{
@@ -1263,6 +2091,10 @@ func (h *AttendanceHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /api/attendance/late", h.HandleLateArrivalSubmit)
 	mux.HandleFunc("POST /api/attendance/early-form", h.HandleEarlyDepartureForm)
 	mux.HandleFunc("POST /api/attendance/early", h.HandleEarlyDepartureSubmit)
+	mux.HandleFunc("POST /api/attendance/overtime-form", h.HandleOvertimeForm)
+	mux.HandleFunc("POST /api/attendance/overtime", h.HandleOvertimeSubmit)
+	mux.HandleFunc("POST /api/attendance/work-mode-form", h.HandleWorkModeForm)
+	mux.HandleFunc("POST /api/attendance/work-mode", h.HandleWorkModeSubmit)
 	mux.HandleFunc("POST /api/attendance/approve", h.HandleApprove)
 	mux.HandleFunc("POST /api/attendance/reject", h.HandleReject)
 	mux.HandleFunc("POST /api/attendance/reject-submit", h.HandleRejectSubmit)
@@ -1271,9 +2103,20 @@ func (h *AttendanceHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /api/attendance/change-approve", h.HandleChangeApprove)
 	mux.HandleFunc("POST /api/attendance/change-reject", h.HandleChangeReject)
 	mux.HandleFunc("POST /api/attendance/change-reject-submit", h.HandleChangeRejectSubmit)
+	mux.HandleFunc("POST /api/attendance/cancel-form", h.HandleCancelForm)
+	mux.HandleFunc("POST /api/attendance/cancel-submit", h.HandleCancelSubmit)
+	mux.HandleFunc("POST /api/attendance/cancel-approve", h.HandleCancelApprove)
+	mux.HandleFunc("POST /api/attendance/cancel-reject", h.HandleCancelReject)
+	mux.HandleFunc("POST /api/attendance/cancel-reject-submit", h.HandleCancelRejectSubmit)
+	mux.HandleFunc("POST /api/attendance/attach-form", h.HandleAttachForm)
+	mux.HandleFunc("POST /api/attendance/attach-submit", h.HandleAttachSubmit)
+	mux.HandleFunc("POST /api/attendance/delegate-form", h.HandleDelegateForm)
+	mux.HandleFunc("POST /api/attendance/delegate", h.HandleDelegateSubmit)
+	mux.HandleFunc("POST /api/attendance/pending-submit", h.HandlePendingSubmit)
 	mux.HandleFunc("POST /api/attendance/activity-confirm", h.HandleActivityConfirm)
 	mux.HandleFunc("GET /api/attendance/report", h.HandleReport)
 	mux.HandleFunc("GET /api/attendance/stats", h.HandleStats)
+	mux.HandleFunc("GET /api/attendance/calendar.ics", h.HandleCalendarFeed)
 }
 
 func writeJSON(w http.ResponseWriter, v any) {
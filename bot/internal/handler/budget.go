@@ -9,6 +9,7 @@ import (
 
 	"oktel-bot/internal/i18n"
 	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
 	"oktel-bot/internal/service"
 )
 
@@ -68,13 +69,7 @@ func (h *BudgetHandler) HandleSlashCommand(w http.ResponseWriter, r *http.Reques
 		Dialog: mattermost.Dialog{
 			Title:       i18n.T(ctx, "budget.dialog.create_title"),
 			SubmitLabel: i18n.T(ctx, "budget.dialog.submit"),
-			Elements: []mattermost.DialogElement{
-				{DisplayName: i18n.T(ctx, "budget.field.name"), Name: "name", Type: "text"},
-				{DisplayName: i18n.T(ctx, "budget.field.partner"), Name: "partner", Type: "text", Placeholder: i18n.T(ctx, "budget.placeholder.partner")},
-				{DisplayName: i18n.T(ctx, "budget.field.amount"), Name: "amount", Type: "text", Placeholder: i18n.T(ctx, "budget.placeholder.amount")},
-				{DisplayName: i18n.T(ctx, "budget.field.purpose"), Name: "purpose", Type: "textarea"},
-				{DisplayName: i18n.T(ctx, "budget.field.deadline"), Name: "deadline", Type: "text", SubType: "date", Placeholder: i18n.T(ctx, "budget.placeholder.deadline")},
-			},
+			Elements:    budgetRequestElements(ctx, nil, true),
 		},
 	})
 	if err != nil {
@@ -89,7 +84,27 @@ func (h *BudgetHandler) HandleSlashCommand(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusOK)
 }
 
-// HandleSaleCreate processes the budget creation dialog submission.
+// budgetRequestElements returns the requester's dialog fields, prefilled from req if set.
+// The partner decides the request's channels, so it cannot be edited.
+func budgetRequestElements(ctx context.Context, req *model.BudgetRequest, withPartner bool) []mattermost.DialogElement {
+	if req == nil {
+		req = &model.BudgetRequest{}
+	}
+	elements := []mattermost.DialogElement{
+		{DisplayName: i18n.T(ctx, "budget.field.name"), Name: "name", Type: "text", Default: req.Name},
+	}
+	if withPartner {
+		elements = append(elements, mattermost.DialogElement{DisplayName: i18n.T(ctx, "budget.field.partner"), Name: "partner", Type: "text", Placeholder: i18n.T(ctx, "budget.placeholder.partner"), Default: req.Partner})
+	}
+	return append(elements,
+		mattermost.DialogElement{DisplayName: i18n.T(ctx, "budget.field.amount"), Name: "amount", Type: "text", Placeholder: i18n.T(ctx, "budget.placeholder.amount"), Default: req.Amount},
+		mattermost.DialogElement{DisplayName: i18n.T(ctx, "budget.field.purpose"), Name: "purpose", Type: "textarea", Default: req.Purpose},
+		mattermost.DialogElement{DisplayName: i18n.T(ctx, "budget.field.deadline"), Name: "deadline", Type: "text", SubType: "date", Placeholder: i18n.T(ctx, "budget.placeholder.deadline"), Default: req.Deadline},
+	)
+}
+
+// HandleSaleCreate processes the budget creation dialog submission. A callback ID is the
+// request being cloned.
 func (h *BudgetHandler) HandleSaleCreate(w http.ResponseWriter, r *http.Request) {
 	var sub DialogSubmission
 	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
@@ -103,16 +118,30 @@ func (h *BudgetHandler) HandleSaleCreate(w http.ResponseWriter, r *http.Request)
 
 	ctx := h.localeCtx(r.Context(), sub.UserID)
 
-	err := h.svc.CreateRequest(
-		ctx,
-		sub.UserID,
-		sub.ChannelID,
-		sub.Submission["name"],
-		sub.Submission["partner"],
-		sub.Submission["amount"],
-		sub.Submission["purpose"],
-		sub.Submission["deadline"],
-	)
+	var err error
+	if sub.CallbackID != "" {
+		err = h.svc.CloneRequest(
+			ctx,
+			sub.CallbackID,
+			sub.UserID,
+			sub.Submission["name"],
+			sub.Submission["partner"],
+			sub.Submission["amount"],
+			sub.Submission["purpose"],
+			sub.Submission["deadline"],
+		)
+	} else {
+		err = h.svc.CreateRequest(
+			ctx,
+			sub.UserID,
+			sub.ChannelID,
+			sub.Submission["name"],
+			sub.Submission["partner"],
+			sub.Submission["amount"],
+			sub.Submission["purpose"],
+			sub.Submission["deadline"],
+		)
+	}
 	if err != nil {
 		log.Printf("ERROR create budget request: %v", err)
 		writeJSON(w, map[string]string{"error": err.Error()})
@@ -309,6 +338,7 @@ func (h *BudgetHandler) HandlePartnerPaymentSubmit(w http.ResponseWriter, r *htt
 	err := h.svc.SubmitPayment(
 		ctx,
 		sub.CallbackID,
+		sub.UserID,
 		sub.Submission["recipient_name"],
 		sub.Submission["bank_account"],
 		sub.Submission["bank_name"],
@@ -450,4 +480,11 @@ func (h *BudgetHandler) RegisterRoutes(mux *http.ServeMux) {
 
 	// Reject
 	mux.HandleFunc("POST /api/budget/reject", h.HandleReject)
+
+	// Requester: edit, withdraw, clone
+	mux.HandleFunc("POST /api/budget/edit-form", h.HandleEditForm)
+	mux.HandleFunc("POST /api/budget/edit", h.HandleEditSubmit)
+	mux.HandleFunc("POST /api/budget/withdraw-form", h.HandleWithdrawForm)
+	mux.HandleFunc("POST /api/budget/withdraw", h.HandleWithdrawSubmit)
+	mux.HandleFunc("POST /api/budget/clone-form", h.HandleCloneForm)
 }
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+)
+
+// HandleEditForm opens the edit dialog for the requester, prefilled with the request.
+func (h *BudgetHandler) HandleEditForm(w http.ResponseWriter, r *http.Request) {
+	var req ActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), req.UserID)
+	requestID, _ := req.Context["request_id"].(string)
+
+	budget, err := h.svc.GetEditableRequest(ctx, requestID, req.UserID)
+	if err != nil {
+		writeJSON(w, ActionResponse{EphemeralText: err.Error()})
+		return
+	}
+
+	elements := budgetRequestElements(ctx, budget, false)
+	if budget.CurrentStep > model.BudgetStepSaleCreated {
+		elements[0].HelpText = i18n.T(ctx, "budget.helptext.edit_reconfirm")
+	}
+	err = h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: req.TriggerID,
+		URL:       h.botURL + "/api/budget/edit",
+		Dialog: mattermost.Dialog{
+			Title:       i18n.T(ctx, "budget.dialog.edit_title"),
+			CallbackID:  requestID,
+			SubmitLabel: i18n.T(ctx, "budget.dialog.save"),
+			Elements:    elements,
+		},
+	})
+	if err != nil {
+		log.Printf("ERROR open budget edit dialog: %v", err)
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "budget.err.open_form")})
+		return
+	}
+	writeJSON(w, ActionResponse{})
+}
+
+// HandleEditSubmit processes the edit dialog submission.
+func (h *BudgetHandler) HandleEditSubmit(w http.ResponseWriter, r *http.Request) {
+	var sub DialogSubmission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if sub.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), sub.UserID)
+
+	err := h.svc.EditRequest(
+		ctx,
+		sub.CallbackID,
+		sub.UserID,
+		sub.Submission["name"],
+		sub.Submission["amount"],
+		sub.Submission["purpose"],
+		sub.Submission["deadline"],
+	)
+	if err != nil {
+		log.Printf("ERROR edit budget request: %v", err)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleWithdrawForm opens the withdrawal reason dialog for the requester.
+func (h *BudgetHandler) HandleWithdrawForm(w http.ResponseWriter, r *http.Request) {
+	var req ActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), req.UserID)
+	requestID, _ := req.Context["request_id"].(string)
+
+	if _, err := h.svc.GetEditableRequest(ctx, requestID, req.UserID); err != nil {
+		writeJSON(w, ActionResponse{EphemeralText: err.Error()})
+		return
+	}
+
+	err := h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: req.TriggerID,
+		URL:       h.botURL + "/api/budget/withdraw",
+		Dialog: mattermost.Dialog{
+			Title:       i18n.T(ctx, "budget.dialog.withdraw_title"),
+			CallbackID:  requestID,
+			SubmitLabel: i18n.T(ctx, "budget.dialog.withdraw"),
+			Elements: []mattermost.DialogElement{
+				{DisplayName: i18n.T(ctx, "budget.field.reason"), Name: "reason", Type: "textarea"},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("ERROR open budget withdraw dialog: %v", err)
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "budget.err.open_form")})
+		return
+	}
+	writeJSON(w, ActionResponse{})
+}
+
+// HandleWithdrawSubmit processes the withdrawal dialog submission.
+func (h *BudgetHandler) HandleWithdrawSubmit(w http.ResponseWriter, r *http.Request) {
+	var sub DialogSubmission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if sub.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), sub.UserID)
+
+	err := h.svc.WithdrawRequest(ctx, sub.CallbackID, sub.UserID, sub.Submission["reason"])
+	if err != nil {
+		log.Printf("ERROR withdraw budget request: %v", err)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleCloneForm opens the create dialog prefilled with a request of the requester. The
+// submission creates a new request linked to the source.
+func (h *BudgetHandler) HandleCloneForm(w http.ResponseWriter, r *http.Request) {
+	var req ActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), req.UserID)
+	requestID, _ := req.Context["request_id"].(string)
+
+	source, err := h.svc.GetOwnRequest(ctx, requestID, req.UserID)
+	if err != nil {
+		writeJSON(w, ActionResponse{EphemeralText: err.Error()})
+		return
+	}
+
+	err = h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: req.TriggerID,
+		URL:       h.botURL + "/api/budget/sale-create",
+		Dialog: mattermost.Dialog{
+			Title:       i18n.T(ctx, "budget.dialog.clone_title"),
+			CallbackID:  requestID,
+			SubmitLabel: i18n.T(ctx, "budget.dialog.submit"),
+			Elements:    budgetRequestElements(ctx, source, true),
+		},
+	})
+	if err != nil {
+		log.Printf("ERROR open budget clone dialog: %v", err)
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "budget.err.open_form")})
+		return
+	}
+	writeJSON(w, ActionResponse{})
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+)
+
+// HandleAttachForm opens the dialog that adds a supporting document to a submitted request.
+func (h *AttendanceHandler) HandleAttachForm(w http.ResponseWriter, r *http.Request) {
+	var req ActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), req.UserID)
+
+	leaves, err := h.svc.GetUserAttachableLeaves(ctx, req.UserID)
+	if err != nil {
+		log.Printf("ERROR get attachable leaves: %v", err)
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
+		return
+	}
+
+	var options []mattermost.SelectOption
+	for _, l := range leaves {
+		display := make([]string, len(l.Dates))
+		for i, d := range l.Dates {
+			display[i] = model.FormatDateDisplay(d)
+		}
+		options = append(options, mattermost.SelectOption{
+			Text: fmt.Sprintf("%s — %s (%s)",
+				strings.Join(display, ", "),
+				i18n.T(ctx, leaveTypeI18nKey(l.Type)),
+				i18n.T(ctx, leaveStatusI18nKey(l.Status)),
+			),
+			Value: l.ID.Hex(),
+		})
+	}
+
+	if len(options) == 0 {
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.no_attachable_leaves")})
+		return
+	}
+
+	err = h.mm.OpenDialog(&mattermost.DialogRequest{
+		TriggerID: req.TriggerID,
+		URL:       h.botURL + "/api/attendance/attach-submit",
+		Dialog: mattermost.Dialog{
+			Title:       i18n.T(ctx, "attendance.dialog.attach_title"),
+			SubmitLabel: i18n.T(ctx, "attendance.dialog.submit"),
+			Elements: []mattermost.DialogElement{
+				{
+					DisplayName: i18n.T(ctx, "attendance.field.attach_request"),
+					Name:        "request_id",
+					Type:        "select",
+					Options:     options,
+				},
+				{
+					DisplayName: i18n.T(ctx, "attendance.field.attachment"),
+					Name:        "attachment",
+					Type:        "file",
+					HelpText:    i18n.T(ctx, "attendance.helptext.attachment"),
+					Accept:      attachmentAccept,
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("ERROR open attach dialog: %v", err)
+		writeJSON(w, ActionResponse{EphemeralText: i18n.T(ctx, "attendance.err.open_form")})
+		return
+	}
+	writeJSON(w, ActionResponse{})
+}
+
+// HandleAttachSubmit processes the supporting document dialog submission.
+func (h *AttendanceHandler) HandleAttachSubmit(w http.ResponseWriter, r *http.Request) {
+	var sub DialogSubmission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if sub.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx := h.localeCtx(r.Context(), sub.UserID)
+
+	requestID := strings.TrimSpace(sub.Submission["request_id"])
+	if requestID == "" {
+		writeJSON(w, map[string]string{"error": i18n.T(ctx, "attendance.err.missing_id")})
+		return
+	}
+
+	err := h.svc.AddLeaveAttachment(ctx, requestID, sub.UserID, strings.TrimSpace(sub.Submission["attachment"]))
+	if err != nil {
+		log.Printf("ERROR add leave attachment: %v", err)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -1,15 +1,110 @@
 package handler
 
 import (
-	"log"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
+	"strconv"
 	"time"
+
+	"oktel-bot/internal/logging"
+	"oktel-bot/internal/metrics"
 )
 
+// maxPeekBody bounds how much of a JSON body LoggingMiddleware reads to find the user ID.
+const maxPeekBody = 1 << 20
+
+// LoggingMiddleware logs each request as a structured line and records its latency. The
+// request ID comes from X-Request-Id (or is generated) and is sent back in the response;
+// it and the calling user's ID are added to every log written with the request's context.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start).Round(time.Millisecond))
+		requestID := r.Header.Get("X-Request-Id")
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-Id", requestID)
+		ctx := logging.WithRequest(r.Context(), requestID)
+		r = r.WithContext(ctx)
+		userID := requestUserID(r)
+		logging.SetUser(ctx, userID)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// The mux sets the matched pattern on the request it was given
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		elapsed := time.Since(start)
+		metrics.RequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Observe(elapsed.Seconds())
+		slog.InfoContext(ctx, "request",
+			"method", r.Method,
+			"route", route,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", elapsed.Milliseconds(),
+		)
 	})
 }
+
+// requestUserID finds the calling user: Mattermost sends it as user_id in slash command
+// forms and in action and dialog bodies, and the webapp API in the Mattermost-User-Id header.
+// A JSON body is read and put back for the handler.
+func requestUserID(r *http.Request) string {
+	if id := r.Header.Get("Mattermost-User-Id"); id != "" {
+		return id
+	}
+	if r.Method != http.MethodPost || r.Body == nil {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err == nil {
+			return r.PostForm.Get("user_id")
+		}
+	case "application/json":
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBody+1))
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		if err != nil || len(body) > maxPeekBody {
+			return ""
+		}
+		var payload struct {
+			UserID string `json:"user_id"`
+		}
+		if json.Unmarshal(body, &payload) == nil {
+			return payload.UserID
+		}
+	}
+	return ""
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
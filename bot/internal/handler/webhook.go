@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/service"
+)
+
+// WebhookHandler exposes the outbound webhook delivery log for debugging integrations.
+// Deliveries carry the payloads of every event, so the routes are part of the REST API and
+// need an API key with the webhooks:manage scope.
+type WebhookHandler struct {
+	svc  *service.WebhookService
+	keys *service.APIKeyService
+}
+
+func NewWebhookHandler(svc *service.WebhookService, keys *service.APIKeyService) *WebhookHandler {
+	return &WebhookHandler{svc: svc, keys: keys}
+}
+
+// HandleDeliveries lists recent deliveries, newest first.
+// Query params: status (pending|delivered|failed), event, event_id, limit (default 100, max 500).
+func (h *WebhookHandler) HandleDeliveries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	deliveries, err := h.svc.Deliveries(r.Context(), model.WebhookDeliveryStatus(q.Get("status")), q.Get("event"), q.Get("event_id"), limit)
+	if err != nil {
+		writeAPIServiceError(w, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, deliveries)
+}
+
+// HandleRedeliver queues a delivery to be sent again.
+func (h *WebhookHandler) HandleRedeliver(w http.ResponseWriter, r *http.Request) {
+	d, err := h.svc.Redeliver(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIJSON(w, http.StatusBadRequest, APIError{Error: err.Error()})
+		return
+	}
+	if d == nil {
+		writeAPIJSON(w, http.StatusNotFound, APIError{Error: "delivery not found"})
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, d)
+}
+
+// RegisterRoutes registers the webhook delivery log routes on the given mux.
+func (h *WebhookHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/webhooks/deliveries", requireAPIKey(h.keys, model.ScopeWebhooksManage, h.HandleDeliveries))
+	mux.HandleFunc("POST /api/v1/webhooks/deliveries/{id}/redeliver", requireAPIKey(h.keys, model.ScopeWebhooksManage, h.HandleRedeliver))
+}
@@ -46,6 +46,16 @@ func Init(defLocale string) {
 	log.Printf("i18n: loaded %d locale files, default=%s", len(entries), defaultLocale)
 }
 
+// Supported reports whether a locale file is loaded for the given locale (e.g. "vi", "zh-TW").
+func Supported(locale string) bool {
+	for _, tag := range bundle.LanguageTags() {
+		if tag.String() == locale {
+			return true
+		}
+	}
+	return false
+}
+
 // WithLocale returns a new context carrying the given locale string (e.g. "vi", "en").
 func WithLocale(ctx context.Context, locale string) context.Context {
 	return context.WithValue(ctx, ctxKey{}, locale)
@@ -0,0 +1,85 @@
+// Package logging sets up the bot's structured logs. The bot logs through the standard log
+// package; Setup routes those lines to log/slog, so every line comes out as JSON with a
+// level, and request-scoped lines logged with slog's Context functions carry the request
+// and user IDs.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup makes slog the destination of the standard logger, writing JSON to stdout, or
+// plain text when format is "text".
+func Setup(format string) {
+	var h slog.Handler
+	if format == "text" {
+		h = slog.NewTextHandler(os.Stdout, nil)
+	} else {
+		h = slog.NewJSONHandler(os.Stdout, nil)
+	}
+	slog.SetDefault(slog.New(&contextHandler{h}))
+}
+
+type ctxKey struct{}
+
+// requestInfo is attached to a request's context by WithRequest. UserID is filled in once
+// the user is known.
+type requestInfo struct {
+	RequestID string
+	UserID    string
+}
+
+// WithRequest returns a context carrying a request ID, to be added to the request's logs.
+func WithRequest(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &requestInfo{RequestID: requestID})
+}
+
+// SetUser records the user a request is made by, for the rest of its logs.
+func SetUser(ctx context.Context, userID string) {
+	if info, ok := ctx.Value(ctxKey{}).(*requestInfo); ok {
+		info.UserID = userID
+	}
+}
+
+// RequestID returns the request ID of a context, if any.
+func RequestID(ctx context.Context) string {
+	if info, ok := ctx.Value(ctxKey{}).(*requestInfo); ok {
+		return info.RequestID
+	}
+	return ""
+}
+
+// contextHandler adds the request and user IDs of the context to each record, and logs
+// lines following the bot's "ERROR ..." convention at error level.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if msg, ok := strings.CutPrefix(r.Message, "ERROR "); ok && r.Level == slog.LevelInfo {
+		nr := slog.NewRecord(r.Time, slog.LevelError, msg, r.PC)
+		r.Attrs(func(a slog.Attr) bool {
+			nr.AddAttrs(a)
+			return true
+		})
+		r = nr
+	}
+	if info, ok := ctx.Value(ctxKey{}).(*requestInfo); ok {
+		r.AddAttrs(slog.String("request_id", info.RequestID))
+		if info.UserID != "" {
+			r.AddAttrs(slog.String("user_id", info.UserID))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
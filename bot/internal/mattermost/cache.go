@@ -0,0 +1,148 @@
+package mattermost
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"oktel-bot/internal/metrics"
+)
+
+// CacheOptions configures the client's lookup caches. A zero TTL turns caching off.
+type CacheOptions struct {
+	TTL  time.Duration
+	Size int // entries per cache
+}
+
+// cache is a size-bounded LRU cache whose entries expire after a TTL. Concurrent misses
+// for the same key share one load. A nil cache always loads.
+type cache[V any] struct {
+	name string // metrics label
+	ttl  time.Duration
+	size int
+
+	mu    sync.Mutex
+	order *list.List // of *cacheEntry[V], most recently used first
+	items map[string]*list.Element
+	gen   uint64 // bumped by every invalidation, so loads started before one are not stored
+	group singleflight.Group
+}
+
+type cacheEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+func newCache[V any](name string, opts CacheOptions) *cache[V] {
+	if opts.TTL <= 0 || opts.Size <= 0 {
+		return nil
+	}
+	return &cache[V]{
+		name:  name,
+		ttl:   opts.TTL,
+		size:  opts.Size,
+		order: list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+// get returns the cached value of key, or loads and caches it. Errors are not cached.
+func (c *cache[V]) get(key string, load func() (V, error)) (V, error) {
+	if c == nil {
+		return load()
+	}
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry[V])
+		if time.Now().Before(entry.expires) {
+			c.order.MoveToFront(el)
+			c.mu.Unlock()
+			metrics.MattermostCache.WithLabelValues(c.name, metrics.CacheHit).Inc()
+			return entry.value, nil
+		}
+		c.removeElement(el)
+	}
+	gen := c.gen
+	c.mu.Unlock()
+	metrics.MattermostCache.WithLabelValues(c.name, metrics.CacheMiss).Inc()
+
+	v, err, _ := c.group.Do(key, func() (any, error) {
+		v, err := load()
+		if err == nil {
+			c.add(key, v, gen)
+		}
+		return v, err
+	})
+	return v.(V), err
+}
+
+// add stores a value unless the cache was invalidated since gen.
+func (c *cache[V]) add(key string, value V, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry[V]{key: key, value: value, expires: time.Now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// put stores a value the client already has, e.g. a channel it just created.
+func (c *cache[V]) put(key string, value V) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	gen := c.gen
+	c.mu.Unlock()
+	c.add(key, value, gen)
+}
+
+// remove drops a key. Callers waiting on a load of it that started earlier get that
+// result, but later calls load again.
+func (c *cache[V]) remove(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	c.group.Forget(key)
+}
+
+// removeFunc drops every entry fn matches.
+func (c *cache[V]) removeFunc(fn func(key string, value V) bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for key, el := range c.items {
+		if fn(key, el.Value.(*cacheEntry[V]).value) {
+			c.removeElement(el)
+			c.group.Forget(key)
+		}
+	}
+}
+
+// clear drops every entry.
+func (c *cache[V]) clear() {
+	c.removeFunc(func(string, V) bool { return true })
+}
+
+func (c *cache[V]) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry[V]).key)
+}
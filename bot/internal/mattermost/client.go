@@ -2,25 +2,94 @@ package mattermost
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"oktel-bot/internal/metrics"
 )
 
+// ErrNotFound is returned when the requested Mattermost object does not exist.
+var ErrNotFound = errors.New("not found")
+
 type Client struct {
 	baseURL    string
 	botToken   string
 	httpClient *http.Client
 	botUserID  string // resolved lazily via /api/v4/users/me
+
+	// Lookup caches, nil when caching is off. See Invalidate* and Listen.
+	users          *cache[*UserInfo]
+	channels       *cache[*ChannelInfo]
+	channelsByName *cache[*ChannelInfo] // by team ID + "/" + name
+	members        *cache[[]ChannelMember]
 }
 
-func NewClient(baseURL, botToken string) *Client {
+// NewClient creates a client for one bot identity. Users, channels and channel members it
+// looks up are cached as set by cacheOpts.
+func NewClient(baseURL, botToken string, cacheOpts CacheOptions) *Client {
 	return &Client{
-		baseURL:    baseURL,
-		botToken:   botToken,
-		httpClient: &http.Client{},
+		baseURL:        baseURL,
+		botToken:       botToken,
+		httpClient:     &http.Client{Transport: instrumentedTransport{http.DefaultTransport}},
+		users:          newCache[*UserInfo]("users", cacheOpts),
+		channels:       newCache[*ChannelInfo]("channels", cacheOpts),
+		channelsByName: newCache[*ChannelInfo]("channels_by_name", cacheOpts),
+		members:        newCache[[]ChannelMember]("channel_members", cacheOpts),
+	}
+}
+
+// Caching reports whether lookups are cached.
+func (c *Client) Caching() bool {
+	return c.users != nil
+}
+
+// InvalidateUser drops a cached user, e.g. after their profile changed.
+func (c *Client) InvalidateUser(userID string) {
+	c.users.remove(userID)
+}
+
+// InvalidateChannel drops a cached channel, its lookups by name and its members.
+func (c *Client) InvalidateChannel(channelID string) {
+	c.channels.remove(channelID)
+	c.channelsByName.removeFunc(func(_ string, info *ChannelInfo) bool { return info.ID == channelID })
+	c.members.remove(channelID)
+}
+
+// InvalidateChannelMembers drops the cached members of a channel.
+func (c *Client) InvalidateChannelMembers(channelID string) {
+	c.members.remove(channelID)
+}
+
+// ClearCache drops everything cached.
+func (c *Client) ClearCache() {
+	c.users.clear()
+	c.channels.clear()
+	c.channelsByName.clear()
+	c.members.clear()
+}
+
+// instrumentedTransport counts failed API calls in metrics.MattermostErrors.
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		metrics.MattermostErrors.WithLabelValues(metrics.Endpoint(req.Method, req.URL.Path), "network").Inc()
+	case resp.StatusCode >= 400:
+		metrics.MattermostErrors.WithLabelValues(metrics.Endpoint(req.Method, req.URL.Path), strconv.Itoa(resp.StatusCode)).Inc()
 	}
+	return resp, err
 }
 
 // Post represents a Mattermost post.
@@ -95,7 +164,10 @@ type DialogElement struct {
 	HelpText    string         `json:"help_text,omitempty"`
 	Optional    bool           `json:"optional"`
 	Options     []SelectOption `json:"options,omitempty"`
+	MultiSelect bool           `json:"multiselect,omitempty"` // select only; submitted as a list
+	DataSource  string         `json:"data_source,omitempty"` // "users" or "channels" for dynamic selects
 	Accept      string         `json:"accept,omitempty"`
+	Default     string         `json:"default,omitempty"`
 }
 
 // SelectOption represents an option in a select element.
@@ -136,24 +208,32 @@ func (c *Client) getBotUserID() (string, error) {
 	return c.botUserID, nil
 }
 
-// SendDM sends a direct message to a user.
-func (c *Client) SendDM(userID, message string) error {
+// DMChannelID gets or creates the direct message channel between the bot and a user.
+func (c *Client) DMChannelID(userID string) (string, error) {
 	botID, err := c.getBotUserID()
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Get or create a DM channel between bot and user
 	var channel struct {
 		ID string `json:"id"`
 	}
 	payload := []string{userID, botID}
 	if err := c.doJSON("POST", "/api/v4/channels/direct", payload, &channel); err != nil {
-		return fmt.Errorf("create dm channel: %w", err)
+		return "", fmt.Errorf("create dm channel: %w", err)
+	}
+	return channel.ID, nil
+}
+
+// SendDM sends a direct message to a user.
+func (c *Client) SendDM(userID, message string) error {
+	channelID, err := c.DMChannelID(userID)
+	if err != nil {
+		return err
 	}
 
 	_, err = c.CreatePost(&Post{
-		ChannelID: channel.ID,
+		ChannelID: channelID,
 		Message:   message,
 	})
 	return err
@@ -161,20 +241,12 @@ func (c *Client) SendDM(userID, message string) error {
 
 // SendDMPost sends a post (with attachments/buttons) as a direct message to a user.
 func (c *Client) SendDMPost(userID string, post *Post) (*Post, error) {
-	botID, err := c.getBotUserID()
+	channelID, err := c.DMChannelID(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	var channel struct {
-		ID string `json:"id"`
-	}
-	payload := []string{userID, botID}
-	if err := c.doJSON("POST", "/api/v4/channels/direct", payload, &channel); err != nil {
-		return nil, fmt.Errorf("create dm channel: %w", err)
-	}
-
-	post.ChannelID = channel.ID
+	post.ChannelID = channelID
 	return c.CreatePost(post)
 }
 
@@ -185,21 +257,212 @@ func (c *Client) OpenDialog(req *DialogRequest) error {
 
 // GetChannelByName looks up a channel by team ID and name.
 func (c *Client) GetChannelByName(teamID, channelName string) (string, error) {
-	var channel struct {
-		ID string `json:"id"`
+	info, err := c.GetChannelInfoByName(teamID, channelName)
+	if err != nil {
+		return "", err
 	}
-	if err := c.doJSON("GET", fmt.Sprintf("/api/v4/teams/%s/channels/name/%s", teamID, channelName), nil, &channel); err != nil {
-		return "", fmt.Errorf("get channel by name: %w", err)
+	return info.ID, nil
+}
+
+// GetChannelInfoByName retrieves channel info by team ID and name. Private channels the
+// bot is not a member of are reported as not found.
+func (c *Client) GetChannelInfoByName(teamID, channelName string) (*ChannelInfo, error) {
+	info, err := c.channelsByName.get(teamID+"/"+channelName, func() (*ChannelInfo, error) {
+		var info ChannelInfo
+		if err := c.doJSON("GET", fmt.Sprintf("/api/v4/teams/%s/channels/name/%s", teamID, channelName), nil, &info); err != nil {
+			return nil, fmt.Errorf("get channel by name: %w", err)
+		}
+		return &info, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return channel.ID, nil
+	out := *info
+	return &out, nil
+}
+
+// CreateChannel creates a public or private channel in a team. The bot becomes its first member.
+func (c *Client) CreateChannel(teamID, name, displayName string, private bool) (*ChannelInfo, error) {
+	channelType := ChannelTypeOpen
+	if private {
+		channelType = ChannelTypePrivate
+	}
+	payload := map[string]string{
+		"team_id":      teamID,
+		"name":         name,
+		"display_name": displayName,
+		"type":         channelType,
+	}
+	var info ChannelInfo
+	if err := c.doJSON("POST", "/api/v4/channels", payload, &info); err != nil {
+		return nil, fmt.Errorf("create channel: %w", err)
+	}
+	cached := info
+	c.channels.put(info.ID, &cached)
+	c.channelsByName.put(teamID+"/"+info.Name, &cached)
+	return &info, nil
+}
+
+// IsChannelMember reports whether the bot is a member of a channel.
+func (c *Client) IsChannelMember(channelID string) (bool, error) {
+	err := c.doJSON("GET", "/api/v4/channels/"+channelID+"/members/me", nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("get channel member: %w", err)
+	}
+	return true, nil
+}
+
+// IsTeamMember reports whether a user is a current member of a team.
+func (c *Client) IsTeamMember(teamID, userID string) (bool, error) {
+	var member struct {
+		DeleteAt int64 `json:"delete_at"`
+	}
+	err := c.doJSON("GET", "/api/v4/teams/"+teamID+"/members/"+userID, nil, &member)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("get team member: %w", err)
+	}
+	return member.DeleteAt == 0, nil
+}
+
+// JoinChannel adds the bot to a public channel.
+func (c *Client) JoinChannel(channelID string) error {
+	botID, err := c.getBotUserID()
+	if err != nil {
+		return err
+	}
+	if err := c.doJSON("POST", "/api/v4/channels/"+channelID+"/members", map[string]string{"user_id": botID}, nil); err != nil {
+		return fmt.Errorf("join channel: %w", err)
+	}
+	c.members.remove(channelID)
+	return nil
+}
+
+// AddChannelMember adds a user to a channel the bot is a member of.
+func (c *Client) AddChannelMember(channelID, userID string) error {
+	if err := c.doJSON("POST", "/api/v4/channels/"+channelID+"/members", map[string]string{"user_id": userID}, nil); err != nil {
+		return fmt.Errorf("add channel member: %w", err)
+	}
+	c.members.remove(channelID)
+	return nil
+}
+
+// RemoveChannelMember removes a user from a channel. Removing a user who is not a member
+// succeeds.
+func (c *Client) RemoveChannelMember(channelID, userID string) error {
+	err := c.doJSON("DELETE", "/api/v4/channels/"+channelID+"/members/"+userID, nil, nil)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("remove channel member: %w", err)
+	}
+	c.members.remove(channelID)
+	return nil
+}
+
+// PinPost pins a post to its channel.
+func (c *Client) PinPost(postID string) error {
+	if err := c.doJSON("POST", "/api/v4/posts/"+postID+"/pin", nil, nil); err != nil {
+		return fmt.Errorf("pin post: %w", err)
+	}
+	return nil
+}
+
+// HasPinnedPost reports whether a channel has a pinned post by the bot with the given
+// props.message_key.
+func (c *Client) HasPinnedPost(channelID, messageKey string) (bool, error) {
+	botID, err := c.getBotUserID()
+	if err != nil {
+		return false, err
+	}
+	// Decode only what is needed: pinned posts of other integrations may carry props
+	// that do not fit Props.
+	var list struct {
+		Posts map[string]struct {
+			UserID string `json:"user_id"`
+			Props  struct {
+				MessageKey string `json:"message_key"`
+			} `json:"props"`
+		} `json:"posts"`
+	}
+	if err := c.doJSON("GET", "/api/v4/channels/"+channelID+"/pinned", nil, &list); err != nil {
+		return false, fmt.Errorf("get pinned posts: %w", err)
+	}
+	for _, p := range list.Posts {
+		if p.UserID == botID && p.Props.MessageKey == messageKey {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Command is a custom slash command.
+type Command struct {
+	ID               string `json:"id,omitempty"`
+	TeamID           string `json:"team_id"`
+	Trigger          string `json:"trigger"`
+	Method           string `json:"method"`
+	URL              string `json:"url"`
+	DisplayName      string `json:"display_name,omitempty"`
+	AutoComplete     bool   `json:"auto_complete"`
+	AutoCompleteDesc string `json:"auto_complete_desc,omitempty"`
+	AutoCompleteHint string `json:"auto_complete_hint,omitempty"`
+}
+
+// ListCommands returns the custom slash commands of a team.
+func (c *Client) ListCommands(teamID string) ([]Command, error) {
+	var cmds []Command
+	if err := c.doJSON("GET", "/api/v4/commands?custom_only=true&team_id="+teamID, nil, &cmds); err != nil {
+		return nil, fmt.Errorf("list commands: %w", err)
+	}
+	return cmds, nil
+}
+
+// CreateCommand registers a custom slash command, owned by the bot.
+func (c *Client) CreateCommand(cmd *Command) error {
+	if err := c.doJSON("POST", "/api/v4/commands", cmd, nil); err != nil {
+		return fmt.Errorf("create command: %w", err)
+	}
+	return nil
 }
 
 // GetUser retrieves a user's info by ID.
 func (c *Client) GetUser(userID string) (*UserInfo, error) {
+	info, err := c.users.get(userID, func() (*UserInfo, error) {
+		var info UserInfo
+		if err := c.doJSON("GET", "/api/v4/users/"+userID, nil, &info); err != nil {
+			return nil, fmt.Errorf("get user: %w", err)
+		}
+		return &info, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := *info
+	return &out, nil
+}
+
+// GetUserFresh retrieves a user's info from the server, bypassing the cache, for
+// permission checks that must see a role change at once. The cached copy is refreshed.
+func (c *Client) GetUserFresh(userID string) (*UserInfo, error) {
 	var info UserInfo
 	if err := c.doJSON("GET", "/api/v4/users/"+userID, nil, &info); err != nil {
 		return nil, fmt.Errorf("get user: %w", err)
 	}
+	cached := info
+	c.users.put(userID, &cached)
+	return &info, nil
+}
+
+// GetUserByUsername retrieves a user's info by username.
+func (c *Client) GetUserByUsername(username string) (*UserInfo, error) {
+	var info UserInfo
+	if err := c.doJSON("GET", "/api/v4/users/username/"+username, nil, &info); err != nil {
+		return nil, fmt.Errorf("get user by username: %w", err)
+	}
 	return &info, nil
 }
 
@@ -208,26 +471,65 @@ type UserInfo struct {
 	ID       string `json:"id"`
 	Username string `json:"username"`
 	Locale   string `json:"locale"`
+	Roles    string `json:"roles"` // space-separated system roles
+	DeleteAt int64  `json:"delete_at"`
+}
+
+// IsActive reports whether the user account is active, i.e. not deactivated.
+func (u *UserInfo) IsActive() bool {
+	return u.DeleteAt == 0
+}
+
+// IsSystemAdmin reports whether the user has the system admin role.
+func (u *UserInfo) IsSystemAdmin() bool {
+	for _, role := range strings.Fields(u.Roles) {
+		if role == "system_admin" {
+			return true
+		}
+	}
+	return false
 }
 
 // GetChannel retrieves channel info by ID.
 func (c *Client) GetChannel(channelID string) (*ChannelInfo, error) {
-	var info ChannelInfo
-	if err := c.doJSON("GET", "/api/v4/channels/"+channelID, nil, &info); err != nil {
-		return nil, fmt.Errorf("get channel: %w", err)
+	info, err := c.channels.get(channelID, func() (*ChannelInfo, error) {
+		var info ChannelInfo
+		if err := c.doJSON("GET", "/api/v4/channels/"+channelID, nil, &info); err != nil {
+			return nil, fmt.Errorf("get channel: %w", err)
+		}
+		return &info, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return &info, nil
+	out := *info
+	return &out, nil
 }
 
+// Channel types.
+const (
+	ChannelTypeOpen    = "O"
+	ChannelTypePrivate = "P"
+)
+
 // ChannelInfo holds basic channel information.
 type ChannelInfo struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	TeamID string `json:"team_id"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Type        string `json:"type"`
+	TeamID      string `json:"team_id"`
 }
 
 // GetChannelMembers returns all members of a channel.
 func (c *Client) GetChannelMembers(channelID string) ([]ChannelMember, error) {
+	members, err := c.members.get(channelID, func() ([]ChannelMember, error) {
+		return c.fetchChannelMembers(channelID)
+	})
+	return slices.Clone(members), err
+}
+
+func (c *Client) fetchChannelMembers(channelID string) ([]ChannelMember, error) {
 	var members []ChannelMember
 	page := 0
 	perPage := 200
@@ -252,6 +554,199 @@ type ChannelMember struct {
 	ChannelID string `json:"channel_id"`
 }
 
+// Ping checks that the Mattermost server is up, via /api/v4/system/ping.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/v4/system/ping", nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ping: api error %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// ErrFileTooLarge is returned by GetFile for a file over the size limit.
+var ErrFileTooLarge = errors.New("file too large")
+
+// GetFile downloads the raw content of an uploaded file of at most maxSize bytes.
+func (c *Client) GetFile(fileID string, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequest("GET", c.baseURL+"/api/v4/files/"+fileID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.botToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("get file: api error %d: %s", resp.StatusCode, string(respBody))
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("get file: %w (%d bytes)", ErrFileTooLarge, resp.ContentLength)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("get file: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("get file: %w (over %d bytes)", ErrFileTooLarge, maxSize)
+	}
+	return data, nil
+}
+
+// FileInfo is the metadata of an uploaded file.
+type FileInfo struct {
+	ID        string `json:"id"`
+	CreatorID string `json:"user_id"`
+	ChannelID string `json:"channel_id"`
+	PostID    string `json:"post_id"`
+	Name      string `json:"name"`
+	Extension string `json:"extension"`
+	Size      int64  `json:"size"`
+	MimeType  string `json:"mime_type"`
+}
+
+// GetFileInfo returns the metadata of an uploaded file.
+func (c *Client) GetFileInfo(fileID string) (*FileInfo, error) {
+	var info FileInfo
+	if err := c.doJSON("GET", "/api/v4/files/"+fileID+"/info", nil, &info); err != nil {
+		return nil, fmt.Errorf("get file info: %w", err)
+	}
+	return &info, nil
+}
+
+// UploadFile uploads a file to a channel, to be attached to a post, and returns its file ID.
+func (c *Client) UploadFile(channelID, filename string, data []byte) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("channel_id", channelID); err != nil {
+		return "", fmt.Errorf("upload file: %w", err)
+	}
+	part, err := mw.CreateFormFile("files", filename)
+	if err != nil {
+		return "", fmt.Errorf("upload file: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", fmt.Errorf("upload file: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return "", fmt.Errorf("upload file: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", c.baseURL+"/api/v4/files", &body)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.botToken)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("upload file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("upload file: api error %d: %s", resp.StatusCode, string(respBody))
+	}
+	var result struct {
+		FileInfos []struct {
+			ID string `json:"id"`
+		} `json:"file_infos"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	if len(result.FileInfos) == 0 {
+		return "", errors.New("upload file: no file info returned")
+	}
+	return result.FileInfos[0].ID, nil
+}
+
+// DeleteFile permanently deletes an uploaded file that is not attached to a post, such as
+// a photo submitted through a dialog. A file that no longer exists is not an error.
+func (c *Client) DeleteFile(fileID string) error {
+	err := c.doJSON("DELETE", "/api/v4/files/"+fileID, nil, nil)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("delete file: %w", err)
+	}
+	return nil
+}
+
+// RemoveMessageData deletes keys from a post's props.message_data, leaving its other props
+// untouched. A post that no longer exists is not an error.
+func (c *Client) RemoveMessageData(postID string, keys ...string) error {
+	var post struct {
+		Props map[string]any `json:"props"`
+	}
+	if err := c.doJSON("GET", "/api/v4/posts/"+postID, nil, &post); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("get post: %w", err)
+	}
+	data, _ := post.Props["message_data"].(map[string]any)
+	changed := false
+	for _, k := range keys {
+		if _, ok := data[k]; ok {
+			delete(data, k)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	patch := map[string]any{"props": post.Props}
+	if err := c.doJSON("PUT", "/api/v4/posts/"+postID+"/patch", patch, nil); err != nil {
+		return fmt.Errorf("patch post: %w", err)
+	}
+	return nil
+}
+
+// SetMessageData sets keys of a post's props.message_data, leaving its other props and
+// buttons untouched. A post that no longer exists is not an error.
+func (c *Client) SetMessageData(postID string, values map[string]any) error {
+	var post struct {
+		Props map[string]any `json:"props"`
+	}
+	if err := c.doJSON("GET", "/api/v4/posts/"+postID, nil, &post); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("get post: %w", err)
+	}
+	if post.Props == nil {
+		post.Props = map[string]any{}
+	}
+	data, _ := post.Props["message_data"].(map[string]any)
+	if data == nil {
+		data = map[string]any{}
+	}
+	for k, v := range values {
+		data[k] = v
+	}
+	post.Props["message_data"] = data
+	patch := map[string]any{"props": post.Props}
+	if err := c.doJSON("PUT", "/api/v4/posts/"+postID+"/patch", patch, nil); err != nil {
+		return fmt.Errorf("patch post: %w", err)
+	}
+	return nil
+}
+
 func (c *Client) doJSON(method, path string, body any, result any) error {
 	var reqBody io.Reader
 	if body != nil {
@@ -275,6 +770,10 @@ func (c *Client) doJSON(method, path string, body any, result any) error {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("api error %d: %s: %w", resp.StatusCode, string(respBody), ErrNotFound)
+	}
 	if resp.StatusCode >= 400 {
 		respBody, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("api error %d: %s", resp.StatusCode, string(respBody))
@@ -0,0 +1,121 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Reconnect backoff of Listen: the first retry waits listenBaseBackoff, doubling up to
+// listenMaxBackoff.
+const (
+	listenBaseBackoff = time.Second
+	listenMaxBackoff  = time.Minute
+)
+
+// wsEvent is an event from the Mattermost websocket. Some data values are JSON strings.
+type wsEvent struct {
+	Event     string         `json:"event"`
+	Data      map[string]any `json:"data"`
+	Broadcast struct {
+		ChannelID string `json:"channel_id"`
+		UserID    string `json:"user_id"`
+	} `json:"broadcast"`
+}
+
+// Listen keeps the caches fresh from the Mattermost websocket until ctx is cancelled:
+// profile and role changes drop the user, channel changes the channel, and membership
+// changes the channel's members. Everything is dropped on each reconnect, since events
+// may have been missed while disconnected.
+func (c *Client) Listen(ctx context.Context) {
+	if !c.Caching() {
+		return
+	}
+	backoff := listenBaseBackoff
+	for {
+		start := time.Now()
+		err := c.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > listenMaxBackoff {
+			backoff = listenBaseBackoff
+		}
+		log.Printf("mattermost websocket: %v; reconnecting in %s", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, listenMaxBackoff)
+	}
+}
+
+// listen reads events from one websocket connection until it fails.
+func (c *Client) listen(ctx context.Context) error {
+	url := "ws" + strings.TrimPrefix(c.baseURL, "http") + "/api/v4/websocket"
+	header := http.Header{"Authorization": {"Bearer " + c.botToken}}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c.ClearCache()
+	for {
+		var ev wsEvent
+		if err := conn.ReadJSON(&ev); err != nil {
+			return err
+		}
+		c.handleEvent(&ev)
+	}
+}
+
+func (c *Client) handleEvent(ev *wsEvent) {
+	str := func(key string) string {
+		s, _ := ev.Data[key].(string)
+		return s
+	}
+	switch ev.Event {
+	case "user_updated":
+		if user, ok := ev.Data["user"].(map[string]any); ok {
+			if id, ok := user["id"].(string); ok {
+				c.InvalidateUser(id)
+			}
+		}
+	case "user_role_updated":
+		c.InvalidateUser(str("user_id"))
+	case "user_added", "user_removed":
+		if ev.Broadcast.ChannelID != "" {
+			c.InvalidateChannelMembers(ev.Broadcast.ChannelID)
+		}
+		if id := str("channel_id"); id != "" {
+			c.InvalidateChannelMembers(id)
+		}
+	case "channel_member_updated":
+		var member ChannelMember
+		if json.Unmarshal([]byte(str("channelMember")), &member) == nil && member.ChannelID != "" {
+			c.InvalidateChannelMembers(member.ChannelID)
+		}
+	case "channel_updated":
+		var info ChannelInfo
+		if json.Unmarshal([]byte(str("channel")), &info) == nil && info.ID != "" {
+			c.InvalidateChannel(info.ID)
+		} else if ev.Broadcast.ChannelID != "" {
+			c.InvalidateChannel(ev.Broadcast.ChannelID)
+		}
+	case "channel_converted", "channel_deleted", "channel_restored":
+		if id := str("channel_id"); id != "" {
+			c.InvalidateChannel(id)
+		} else if ev.Broadcast.ChannelID != "" {
+			c.InvalidateChannel(ev.Broadcast.ChannelID)
+		}
+	}
+}
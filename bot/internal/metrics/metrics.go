@@ -0,0 +1,109 @@
+// Package metrics holds the bot's Prometheus metrics, served at /metrics.
+package metrics
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "oktel_bot"
+
+// Results counted by MattermostCache.
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// Activity check results counted by ActivityChecks.
+const (
+	ActivityCheckSent      = "sent"
+	ActivityCheckConfirmed = "confirmed"
+	ActivityCheckExpired   = "expired"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	// RequestDuration is the latency of the bot's HTTP routes, labelled with the route
+	// pattern rather than the path so IDs do not create new series.
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	// MattermostErrors counts failed Mattermost API calls; code is the HTTP status, or
+	// "network" when no response came back.
+	MattermostErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mattermost_api_errors_total",
+		Help:      "Failed Mattermost API calls by endpoint and status code.",
+	}, []string{"endpoint", "code"})
+
+	// MattermostCache counts lookups in the Mattermost client's caches by result.
+	MattermostCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mattermost_cache_lookups_total",
+		Help:      "Mattermost client cache lookups by cache and result.",
+	}, []string{"cache", "result"})
+
+	// ActivityChecks counts activity checks by result.
+	ActivityChecks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "activity_checks_total",
+		Help:      "Activity checks sent, confirmed and expired.",
+	}, []string{"result"})
+
+	// LeaveTransitions counts leave request events (requested, approved, ...) by leave type.
+	LeaveTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leave_transitions_total",
+		Help:      "Leave request transitions by event and leave type.",
+	}, []string{"event", "type"})
+
+	// BudgetTransitions counts budget request events by the step the request is at after it.
+	BudgetTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "budget_transitions_total",
+		Help:      "Budget request transitions by event and resulting step.",
+	}, []string{"event", "step"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestDuration, MattermostErrors, MattermostCache, ActivityChecks, LeaveTransitions, BudgetTransitions,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// mattermostID matches the 26-character IDs Mattermost uses for users, channels, posts and
+// so on.
+var mattermostID = regexp.MustCompile(`^[a-z0-9]{26}$`)
+
+// Endpoint turns a Mattermost API call into a label value with IDs and names replaced, e.g.
+// "GET /api/v4/users/{id}" or "GET /api/v4/teams/{id}/channels/name/{name}".
+func Endpoint(method, path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		switch {
+		case mattermostID.MatchString(p):
+			parts[i] = "{id}"
+		case i > 0 && (parts[i-1] == "name" || parts[i-1] == "username" || parts[i-1] == "email"):
+			parts[i] = "{name}"
+		}
+	}
+	return method + " " + strings.Join(parts, "/")
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// API key scopes for the /api/v1 REST API.
+const (
+	ScopeLeavesRead     = "leaves:read"
+	ScopeLeavesWrite    = "leaves:write"
+	ScopeBalancesWrite  = "balances:write"
+	ScopeAttendanceRead = "attendance:read"
+	ScopeBudgetsRead    = "budgets:read"
+	ScopeTimesheetsRead = "timesheets:read"
+	ScopeWebhooksManage = "webhooks:manage"
+)
+
+// APIScopes lists every valid API key scope.
+var APIScopes = []string{ScopeLeavesRead, ScopeLeavesWrite, ScopeBalancesWrite, ScopeAttendanceRead, ScopeBudgetsRead, ScopeTimesheetsRead, ScopeWebhooksManage}
+
+// APIKey authenticates an external system. Only the SHA-256 hash of the key is stored;
+// Prefix is kept to tell keys apart in listings.
+type APIKey struct {
+	ID         bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string        `bson:"name" json:"name"` // also recorded as the source of imported leave
+	Prefix     string        `bson:"prefix" json:"prefix"`
+	Hash       string        `bson:"hash" json:"-"`
+	Scopes     []string      `bson:"scopes" json:"scopes"`
+	LastUsedAt *time.Time    `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time    `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time     `bson:"created_at" json:"created_at"`
+}
+
+// HasScope reports whether the key grants scope.
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
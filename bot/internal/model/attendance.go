@@ -22,11 +22,53 @@ const (
 	ActivityCheckExpired   ActivityCheckStatus = "expired"
 )
 
+// AttendanceMode is where the user worked on a given day.
+type AttendanceMode string
+
+const (
+	AttendanceModeOffice       AttendanceMode = "office"
+	AttendanceModeRemote       AttendanceMode = "remote"
+	AttendanceModeBusinessTrip AttendanceMode = "business_trip"
+)
+
 const (
 	AttendanceChannel         = "attendance"
 	AttendanceApprovalChannel = "attendance-approval"
 )
 
+// BreakReason is a break reason offered by /diemdanh. The built-in reasons are labelled by
+// "attendance.break_reason.<id>"; custom ones carry a label per locale. A zero limit means
+// no limit.
+type BreakReason struct {
+	ID              string            `bson:"id" json:"id"`
+	Labels          map[string]string `bson:"labels,omitempty" json:"labels,omitempty"` // by locale, e.g. "vi"
+	MaxPerDay       int               `bson:"max_per_day,omitempty" json:"max_per_day,omitempty"`
+	MaxMinutes      int               `bson:"max_minutes,omitempty" json:"max_minutes,omitempty"`             // per break
+	MaxDailyMinutes int               `bson:"max_daily_minutes,omitempty" json:"max_daily_minutes,omitempty"` // all of a day's breaks
+}
+
+// Label returns the reason's label in the given locale, falling back to English. It is
+// empty for a reason without labels.
+func (r *BreakReason) Label(locale string) string {
+	if l := r.Labels[locale]; l != "" {
+		return l
+	}
+	return r.Labels["en"]
+}
+
+// DefaultBreakReasons are offered to teams without break reasons of their own, in button
+// order.
+var DefaultBreakReasons = []BreakReason{
+	{ID: "nghi_ngoi"}, {ID: "di_an"}, {ID: "tieu_tien"}, {ID: "dai_tien"}, {ID: "hut_thuoc"},
+}
+
+// Break limits a break can exceed.
+const (
+	BreakLimitPerDay       = "per_day"       // more breaks for the reason than MaxPerDay
+	BreakLimitDuration     = "duration"      // longer than MaxMinutes
+	BreakLimitDailyMinutes = "daily_minutes" // the day's breaks for the reason add up to more than MaxDailyMinutes
+)
+
 // BreakRecord represents a single break period with a reason.
 type BreakRecord struct {
 	Start       time.Time  `bson:"start" json:"start"`
@@ -34,6 +76,24 @@ type BreakRecord struct {
 	End         *time.Time `bson:"end,omitempty" json:"end,omitempty"`
 	EndDevice   string     `bson:"end_device,omitempty" json:"end_device,omitempty"`
 	Reason      string     `bson:"reason" json:"reason"`
+	Exceeded    []string   `bson:"exceeded,omitempty" json:"exceeded,omitempty"` // BreakLimit* this break went over
+}
+
+type PhotoFlagType string
+
+const (
+	PhotoFlagDuplicate PhotoFlagType = "duplicate" // photo matches one the user submitted recently
+	PhotoFlagStale     PhotoFlagType = "stale"     // EXIF capture time is long before submission
+)
+
+// PhotoFlag records a suspicious check-in or check-out photo.
+type PhotoFlag struct {
+	Type        PhotoFlagType `bson:"type" json:"type"`
+	Action      string        `bson:"action" json:"action"` // "checkin" or "checkout"
+	FileID      string        `bson:"file_id" json:"file_id"`
+	MatchedDate string        `bson:"matched_date,omitempty" json:"matched_date,omitempty"` // duplicate: date of the matching photo
+	Distance    int           `bson:"distance,omitempty" json:"distance,omitempty"`         // duplicate: hash bit distance
+	TakenAt     *time.Time    `bson:"taken_at,omitempty" json:"taken_at,omitempty"`         // stale: EXIF capture time
 }
 
 type AttendanceRecord struct {
@@ -51,12 +111,19 @@ type AttendanceRecord struct {
 	CheckOut        *time.Time       `bson:"check_out,omitempty" json:"check_out"`
 	CheckOutDevice  string           `bson:"checkout_device,omitempty" json:"checkout_device,omitempty"`
 	CheckOutImageID string           `bson:"checkout_image_id,omitempty" json:"checkout_image_id,omitempty"`
+	CheckOutPostID  string           `bson:"checkout_post_id,omitempty" json:"checkout_post_id,omitempty"` // checkout post showing the photo
 	Status          AttendanceStatus `bson:"status" json:"status"`
+	Mode            AttendanceMode   `bson:"mode,omitempty" json:"mode,omitempty"` // empty on records created before modes existed
 	CreatedAt       time.Time        `bson:"created_at" json:"created_at"`
 	UpdatedAt       time.Time        `bson:"updated_at" json:"updated_at"`
 
 	// Activity check fields
-	LastCheckAt     *time.Time `bson:"last_check_at,omitempty" json:"last_check_at,omitempty"`
-	LastCheckPostID string     `bson:"last_check_post_id,omitempty" json:"last_check_post_id,omitempty"`
+	LastCheckAt     *time.Time          `bson:"last_check_at,omitempty" json:"last_check_at,omitempty"`
+	LastCheckPostID string              `bson:"last_check_post_id,omitempty" json:"last_check_post_id,omitempty"`
 	LastCheckStatus ActivityCheckStatus `bson:"last_check_status,omitempty" json:"last_check_status,omitempty"`
+
+	// Photo check fields
+	CheckInPhotoHash  string      `bson:"checkin_photo_hash,omitempty" json:"checkin_photo_hash,omitempty"`
+	CheckOutPhotoHash string      `bson:"checkout_photo_hash,omitempty" json:"checkout_photo_hash,omitempty"`
+	PhotoFlags        []PhotoFlag `bson:"photo_flags,omitempty" json:"photo_flags,omitempty"`
 }
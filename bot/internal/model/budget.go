@@ -79,8 +79,10 @@ type BudgetRequest struct {
 	PaymentAt     *time.Time `bson:"payment_at,omitempty" json:"payment_at"`
 
 	// Step 5: Approval
-	ApproverID string     `bson:"approver_id,omitempty" json:"approver_id"`
-	ApprovedAt *time.Time `bson:"approved_at,omitempty" json:"approved_at"`
+	ApproverID         string     `bson:"approver_id,omitempty" json:"approver_id"`
+	OnBehalfOfID       string     `bson:"on_behalf_of_id,omitempty" json:"on_behalf_of_id,omitempty"` // delegator when the approver acted as their delegate
+	OnBehalfOfUsername string     `bson:"on_behalf_of_username,omitempty" json:"on_behalf_of_username,omitempty"`
+	ApprovedAt         *time.Time `bson:"approved_at,omitempty" json:"approved_at"`
 
 	// Step 6: Finance Completion
 	FinanceUserID   string     `bson:"finance_user_id,omitempty" json:"finance_user_id"`
@@ -91,6 +93,92 @@ type BudgetRequest struct {
 	// Rejection
 	RejectedAt *time.Time `bson:"rejected_at,omitempty" json:"rejected_at"`
 
+	// Withdrawal by the requester
+	WithdrawnAt    *time.Time `bson:"withdrawn_at,omitempty" json:"withdrawn_at,omitempty"`
+	WithdrawReason string     `bson:"withdraw_reason,omitempty" json:"withdraw_reason,omitempty"`
+
+	ClonedFromID string               `bson:"cloned_from_id,omitempty" json:"cloned_from_id,omitempty"` // request this one was cloned from
+	History      []BudgetHistoryEntry `bson:"history,omitempty" json:"history,omitempty"`
+
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
+
+// Closed reports whether the request was completed, rejected or withdrawn.
+func (r *BudgetRequest) Closed() bool {
+	return r.CurrentStep >= BudgetStepCompleted || r.RejectedAt != nil || r.WithdrawnAt != nil
+}
+
+// userIDs returns the fields holding the users who filled a step of the request.
+func (r *BudgetRequest) userIDs() []*string {
+	return []*string{&r.SaleUserID, &r.PartnerUserID, &r.TLQCUserID, &r.ApproverID, &r.OnBehalfOfID, &r.FinanceUserID}
+}
+
+// Involves reports whether userID filled a step of the request or acted on it.
+func (r *BudgetRequest) Involves(userID string) bool {
+	for _, id := range r.userIDs() {
+		if *id == userID {
+			return true
+		}
+	}
+	for _, h := range r.History {
+		if h.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ForgetUser removes userID from the request and its history, for erasure. The request
+// itself is a business record and is kept. It reports whether anything changed.
+func (r *BudgetRequest) ForgetUser(userID string) bool {
+	if userID == "" || !r.Involves(userID) {
+		return false
+	}
+	if r.OnBehalfOfID == userID {
+		r.OnBehalfOfUsername = ""
+	}
+	for _, id := range r.userIDs() {
+		if *id == userID {
+			*id = ""
+		}
+	}
+	for i := range r.History {
+		if r.History[i].UserID == userID {
+			r.History[i].UserID = ""
+		}
+	}
+	return true
+}
+
+// Budget request history actions.
+const (
+	BudgetActionCreated          = "created"
+	BudgetActionContentSubmitted = "content_submitted"
+	BudgetActionReturned         = "returned"
+	BudgetActionConfirmed        = "confirmed"
+	BudgetActionPaymentSubmitted = "payment_submitted"
+	BudgetActionApproved         = "approved"
+	BudgetActionCompleted        = "completed"
+	BudgetActionRejected         = "rejected"
+	BudgetActionEdited           = "edited"
+	BudgetActionWithdrawn        = "withdrawn"
+)
+
+// BudgetHistoryEntry records one action on a budget request and the step it left the
+// request at.
+type BudgetHistoryEntry struct {
+	Action  string              `bson:"action" json:"action"`
+	UserID  string              `bson:"user_id" json:"user_id"`
+	Step    BudgetStep          `bson:"step" json:"step"`
+	Reason  string              `bson:"reason,omitempty" json:"reason,omitempty"`
+	Changes []BudgetFieldChange `bson:"changes,omitempty" json:"changes,omitempty"` // edits only
+	At      time.Time           `bson:"at" json:"at"`
+}
+
+// BudgetFieldChange is one field changed by an edit.
+type BudgetFieldChange struct {
+	Field string `bson:"field" json:"field"`
+	Old   string `bson:"old" json:"old"`
+	New   string `bson:"new" json:"new"`
+}
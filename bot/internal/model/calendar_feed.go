@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// CalendarFeed holds the key that signs the calendar feed URLs issued to a user. Replacing
+// the key with /xinphep calendar reset revokes every URL signed with the old one.
+type CalendarFeed struct {
+	UserID    string    `bson:"_id" json:"user_id"`
+	Key       string    `bson:"key" json:"-"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// DelegationScope is the approval workflow a delegation applies to.
+type DelegationScope string
+
+const (
+	DelegationScopeAttendance DelegationScope = "attendance"
+	DelegationScopeBudget     DelegationScope = "budget"
+	DelegationScopeAll        DelegationScope = "all"
+)
+
+// Delegation hands an approver's authority to another user for a date range
+// (YYYY-MM-DD, inclusive).
+type Delegation struct {
+	ID                bson.ObjectID   `bson:"_id,omitempty" json:"id"`
+	DelegatorID       string          `bson:"delegator_id" json:"delegator_id"`
+	DelegatorUsername string          `bson:"delegator_username" json:"delegator_username"`
+	DelegateID        string          `bson:"delegate_id" json:"delegate_id"`
+	DelegateUsername  string          `bson:"delegate_username" json:"delegate_username"`
+	Scope             DelegationScope `bson:"scope" json:"scope"`
+	From              string          `bson:"from" json:"from"`
+	To                string          `bson:"to" json:"to"`
+	Reason            string          `bson:"reason,omitempty" json:"reason"`
+
+	// Set when the delegation was created automatically from the delegator's approved leave.
+	Auto           bool   `bson:"auto,omitempty" json:"auto"`
+	LeaveRequestID string `bson:"leave_request_id,omitempty" json:"leave_request_id"`
+
+	// Approval channels the delegate was added to for this delegation, left when it ends.
+	AddedChannels []DelegationChannel `bson:"added_channels,omitempty" json:"added_channels,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// DelegationChannel is an approval channel a delegate was added to. Scope tells which
+// bot manages the channel.
+type DelegationChannel struct {
+	ChannelID string          `bson:"channel_id" json:"channel_id"`
+	Scope     DelegationScope `bson:"scope" json:"scope"`
+}
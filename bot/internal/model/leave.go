@@ -12,6 +12,9 @@ const (
 	LeaveTypeOff            LeaveType = "off"
 	LeaveTypeLateArrival    LeaveType = "late_arrival"
 	LeaveTypeEarlyDeparture LeaveType = "early_departure"
+	LeaveTypeOvertime       LeaveType = "overtime"
+	LeaveTypeRemote         LeaveType = "remote"        // pre-approved work from home
+	LeaveTypeBusinessTrip   LeaveType = "business_trip" // pre-approved work travel
 )
 
 type LeaveStatus string
@@ -21,6 +24,8 @@ const (
 	LeaveStatusApproved      LeaveStatus = "approved"
 	LeaveStatusRejected      LeaveStatus = "rejected"
 	LeaveStatusPendingChange LeaveStatus = "pending_change"
+	LeaveStatusPendingCancel LeaveStatus = "pending_cancel" // approved leave awaiting cancellation approval
+	LeaveStatusCancelled     LeaveStatus = "cancelled"      // withdrawn by the requester
 )
 
 type LeaveRequest struct {
@@ -39,6 +44,8 @@ type LeaveRequest struct {
 	Status               LeaveStatus   `bson:"status" json:"status"`
 	ApproverID           string        `bson:"approver_id,omitempty" json:"approver_id"`
 	ApproverUsername     string        `bson:"approver_username,omitempty" json:"approver_username"`
+	OnBehalfOfID         string        `bson:"on_behalf_of_id,omitempty" json:"on_behalf_of_id,omitempty"` // delegator when the approver acted as their delegate
+	OnBehalfOfUsername   string        `bson:"on_behalf_of_username,omitempty" json:"on_behalf_of_username,omitempty"`
 	ApprovedAt           *time.Time    `bson:"approved_at,omitempty" json:"approved_at"`
 	RejectReason         string        `bson:"reject_reason,omitempty" json:"reject_reason"`
 	OldDate              string        `bson:"old_date,omitempty" json:"old_date,omitempty"`
@@ -47,8 +54,92 @@ type LeaveRequest struct {
 	PreviousStatus       LeaveStatus   `bson:"previous_status,omitempty" json:"previous_status,omitempty"`
 	ChangePostID         string        `bson:"change_post_id,omitempty" json:"change_post_id,omitempty"`
 	ChangeApprovalPostID string        `bson:"change_approval_post_id,omitempty" json:"change_approval_post_id,omitempty"`
-	CreatedAt            time.Time     `bson:"created_at" json:"created_at"`
-	UpdatedAt            time.Time     `bson:"updated_at" json:"updated_at"`
+
+	// Supporting documents such as a medical certificate, uploaded with the request or later.
+	Attachments []LeaveAttachment `bson:"attachments,omitempty" json:"attachments,omitempty"`
+
+	// Set on leave imported through the REST API: the API key name and the source system's ID.
+	Source     string `bson:"source,omitempty" json:"source,omitempty"`
+	ExternalID string `bson:"external_id,omitempty" json:"external_id,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+
+	// Overtime fields
+	PlannedHours            float64    `bson:"planned_hours,omitempty" json:"planned_hours,omitempty"`
+	CompensatoryLeave       bool       `bson:"compensatory_leave,omitempty" json:"compensatory_leave,omitempty"` // convert approved overtime to comp time
+	ActualOvertimeMinutes   int        `bson:"actual_overtime_minutes,omitempty" json:"actual_overtime_minutes,omitempty"`
+	CreditedOvertimeMinutes int        `bson:"credited_overtime_minutes,omitempty" json:"credited_overtime_minutes,omitempty"` // actual, capped at planned
+	OvertimeDayType         string     `bson:"overtime_day_type,omitempty" json:"overtime_day_type,omitempty"`
+	ReconciledAt            *time.Time `bson:"reconciled_at,omitempty" json:"reconciled_at,omitempty"`
+
+	// Comp time spent per day on a day-off request paid from the overtime balance.
+	CompTimeDayMinutes int `bson:"comp_time_day_minutes,omitempty" json:"comp_time_day_minutes,omitempty"`
+
+	// Cancellation fields
+	CancelDates          []string   `bson:"cancel_dates,omitempty" json:"cancel_dates,omitempty"`       // dates awaiting cancellation approval
+	CancelledDates       []string   `bson:"cancelled_dates,omitempty" json:"cancelled_dates,omitempty"` // dates removed by partial cancellation
+	CancelReason         string     `bson:"cancel_reason,omitempty" json:"cancel_reason,omitempty"`
+	CancelledAt          *time.Time `bson:"cancelled_at,omitempty" json:"cancelled_at,omitempty"`
+	CancelPostID         string     `bson:"cancel_post_id,omitempty" json:"cancel_post_id,omitempty"`
+	CancelApprovalPostID string     `bson:"cancel_approval_post_id,omitempty" json:"cancel_approval_post_id,omitempty"`
+}
+
+// LeaveAttachment is a supporting document stored as a Mattermost file.
+type LeaveAttachment struct {
+	FileID  string    `bson:"file_id" json:"file_id"`
+	Name    string    `bson:"name" json:"name"`
+	AddedAt time.Time `bson:"added_at" json:"added_at"`
+}
+
+// MaxLeaveAttachments caps the documents on one leave request.
+const MaxLeaveAttachments = 10
+
+// CompTimeEarned returns the comp time minutes an approved, reconciled overtime request
+// adds to the user's balance.
+func (r *LeaveRequest) CompTimeEarned() int {
+	if r.Type != LeaveTypeOvertime || !r.CompensatoryLeave || r.ReconciledAt == nil {
+		return 0
+	}
+	if r.Status != LeaveStatusApproved && r.Status != LeaveStatusPendingCancel {
+		return 0
+	}
+	return r.CreditedOvertimeMinutes
+}
+
+// CompTimeSpent returns the comp time minutes a day-off request holds against the user's
+// balance while it is pending or approved. Partially cancelled dates are released.
+func (r *LeaveRequest) CompTimeSpent() int {
+	if r.Type != LeaveTypeOff || r.CompTimeDayMinutes == 0 {
+		return 0
+	}
+	switch r.Status {
+	case LeaveStatusPending, LeaveStatusApproved, LeaveStatusPendingChange, LeaveStatusPendingCancel:
+		return r.CompTimeDayMinutes * len(r.Dates)
+	}
+	return 0
+}
+
+// CompTimeAdjustment credits (positive minutes) or debits (negative) a user's comp time
+// balance outside of overtime and day-off requests, e.g. a balance carried over from the HR
+// system. Adjustments are imported through the REST API.
+type CompTimeAdjustment struct {
+	ID         bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     string        `bson:"user_id" json:"user_id"`
+	Minutes    int           `bson:"minutes" json:"minutes"`
+	Reason     string        `bson:"reason" json:"reason"`
+	Source     string        `bson:"source" json:"source"` // API key name
+	ExternalID string        `bson:"external_id,omitempty" json:"external_id,omitempty"`
+	CreatedAt  time.Time     `bson:"created_at" json:"created_at"`
+}
+
+// CompTimeBalance sums the comp time a user's requests earn and spend, in minutes.
+func CompTimeBalance(reqs []*LeaveRequest) int {
+	balance := 0
+	for _, r := range reqs {
+		balance += r.CompTimeEarned() - r.CompTimeSpent()
+	}
+	return balance
 }
 
 // FormatDateDisplay converts a date from YYYY-MM-DD to DD/MM/YYYY for display.
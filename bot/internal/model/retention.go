@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+)
+
+// RetentionPolicy says how long a team's attendance personal data is kept. A zero period
+// keeps that data forever. The policy without a team ID is the global policy, applied to
+// every team that has no policy of its own.
+type RetentionPolicy struct {
+	TeamID      string `json:"team_id,omitempty"`
+	PhotoDays   int    `json:"photo_days"`   // delete check-in/out photos and their files after this many days
+	DeviceDays  int    `json:"device_days"`  // remove device strings from attendance records after this many days
+	RecordYears int    `json:"record_years"` // delete attendance records and leave requests after this many years, keeping monthly summaries
+}
+
+// RetentionRun is one daily run of the retention job. Its ID is the run date, so only
+// one replica runs the job per day.
+type RetentionRun struct {
+	Date       string     `bson:"_id" json:"date"` // YYYY-MM-DD
+	StartedAt  time.Time  `bson:"started_at" json:"started_at"`
+	FinishedAt *time.Time `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+
+	PhotosPurged      int    `bson:"photos_purged" json:"photos_purged"`
+	DevicesAnonymized int64  `bson:"devices_anonymized" json:"devices_anonymized"`
+	RecordsDeleted    int64  `bson:"records_deleted" json:"records_deleted"`
+	LeaveDeleted      int64  `bson:"leave_deleted" json:"leave_deleted"`
+	Error             string `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// AttendanceSummary is a user's attendance for one month, kept after the month's records
+// are deleted by the retention policy.
+type AttendanceSummary struct {
+	UserID       string         `bson:"user_id" json:"user_id"`
+	TeamID       string         `bson:"team_id" json:"team_id"`
+	Month        string         `bson:"month" json:"month"` // YYYY-MM
+	DaysWorked   int            `bson:"days_worked" json:"days_worked"`
+	WorkMinutes  int            `bson:"work_minutes" json:"work_minutes"` // checked-in time minus breaks
+	BreakMinutes int            `bson:"break_minutes" json:"break_minutes"`
+	ModeDays     map[string]int `bson:"mode_days,omitempty" json:"mode_days,omitempty"`   // days worked per attendance mode
+	LeaveDays    map[string]int `bson:"leave_days,omitempty" json:"leave_days,omitempty"` // approved request dates per leave type
+	UpdatedAt    time.Time      `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// TeamSettings holds one team's overrides of the bot's global settings, edited with
+// /botadmin config. A nil or empty field falls back to the environment setting.
+type TeamSettings struct {
+	TeamID               string        `bson:"_id" json:"team_id"`
+	BlockMobile          *bool         `bson:"block_mobile,omitempty" json:"block_mobile,omitempty"`
+	RequirePhoto         *bool         `bson:"require_photo,omitempty" json:"require_photo,omitempty"`
+	ActivityCheck        *bool         `bson:"activity_check,omitempty" json:"activity_check,omitempty"`
+	ActivityCheckPeriod  int           `bson:"activity_check_period,omitempty" json:"activity_check_period,omitempty"`   // minutes
+	ActivityCheckTimeout int           `bson:"activity_check_timeout,omitempty" json:"activity_check_timeout,omitempty"` // minutes
+	ActivityCheckChannel string        `bson:"activity_check_channel,omitempty" json:"activity_check_channel,omitempty"`
+	BreakReasons         []BreakReason `bson:"break_reasons,omitempty" json:"break_reasons,omitempty"` // offered in this order
+	Timezone             string        `bson:"timezone,omitempty" json:"timezone,omitempty"`           // IANA name, e.g. "Asia/Tokyo"
+	Locale               string        `bson:"locale,omitempty" json:"locale,omitempty"`               // for team channel notices
+	UpdatedAt            time.Time     `bson:"updated_at" json:"updated_at"`
+	UpdatedBy            string        `bson:"updated_by,omitempty" json:"updated_by,omitempty"`
+}
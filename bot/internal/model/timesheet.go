@@ -0,0 +1,84 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Timesheet is one team's payroll totals for a month. Only locked months are stored: the
+// rows are frozen when the month is locked and change only through a correction.
+type Timesheet struct {
+	ID          bson.ObjectID         `bson:"_id,omitempty" json:"id,omitempty"`
+	TeamID      string                `bson:"team_id" json:"team_id"`
+	Month       string                `bson:"month" json:"month"` // YYYY-MM
+	Revision    int                   `bson:"revision" json:"revision"`
+	Rows        []TimesheetRow        `bson:"rows" json:"rows"`
+	GeneratedAt time.Time             `bson:"generated_at" json:"generated_at"`
+	LockedAt    *time.Time            `bson:"locked_at,omitempty" json:"locked_at,omitempty"`
+	LockedBy    string                `bson:"locked_by,omitempty" json:"locked_by,omitempty"`
+	Correction  *TimesheetCorrection  `bson:"correction,omitempty" json:"correction,omitempty"` // open correction; edits are allowed
+	Corrections []TimesheetCorrection `bson:"corrections,omitempty" json:"corrections,omitempty"`
+}
+
+// Locked reports whether edits to the month are refused.
+func (t *Timesheet) Locked() bool {
+	return t != nil && t.LockedAt != nil && t.Correction == nil
+}
+
+// UserRow returns the row of userID, or nil if the sheet has none.
+func (t *Timesheet) UserRow(userID string) *TimesheetRow {
+	for i := range t.Rows {
+		if t.Rows[i].UserID == userID {
+			return &t.Rows[i]
+		}
+	}
+	return nil
+}
+
+// ForgetUser blanks the user ID and username of userID's row, for erasure. The totals are
+// payroll records and are kept. It reports whether the sheet had a row for the user.
+func (t *Timesheet) ForgetUser(userID string) bool {
+	row := t.UserRow(userID)
+	if userID == "" || row == nil {
+		return false
+	}
+	row.UserID = ""
+	row.Username = ""
+	return true
+}
+
+// TimesheetCorrection reopens a locked month for edits. Locking the month again closes it
+// and records whose totals changed.
+type TimesheetCorrection struct {
+	Reason   string     `bson:"reason" json:"reason"`
+	OpenedBy string     `bson:"opened_by" json:"opened_by"`
+	OpenedAt time.Time  `bson:"opened_at" json:"opened_at"`
+	ClosedBy string     `bson:"closed_by,omitempty" json:"closed_by,omitempty"`
+	ClosedAt *time.Time `bson:"closed_at,omitempty" json:"closed_at,omitempty"`
+	Changed  []string   `bson:"changed,omitempty" json:"changed,omitempty"` // usernames
+}
+
+// TimesheetRow is one employee's monthly totals. Minutes are already rounded by the
+// timesheet rules.
+type TimesheetRow struct {
+	UserID                  string `bson:"user_id" json:"user_id"`
+	Username                string `bson:"username" json:"username"`
+	DaysWorked              int    `bson:"days_worked" json:"days_worked"`
+	IncompleteDays          int    `bson:"incomplete_days" json:"incomplete_days"` // checked in without checking out
+	WorkedMinutes           int    `bson:"worked_minutes" json:"worked_minutes"`   // net of breaks
+	PaidLeaveDays           int    `bson:"paid_leave_days" json:"paid_leave_days"`
+	UnpaidLeaveDays         int    `bson:"unpaid_leave_days" json:"unpaid_leave_days"`
+	LateCount               int    `bson:"late_count" json:"late_count"`
+	LateMinutes             int    `bson:"late_minutes" json:"late_minutes"`
+	LateArrivalRequests     int    `bson:"late_arrival_requests" json:"late_arrival_requests"`
+	EarlyDepartureRequests  int    `bson:"early_departure_requests" json:"early_departure_requests"`
+	OvertimeWeekdayMinutes  int    `bson:"overtime_weekday_minutes" json:"overtime_weekday_minutes"`
+	OvertimeWeekendMinutes  int    `bson:"overtime_weekend_minutes" json:"overtime_weekend_minutes"`
+	OvertimeHolidayMinutes  int    `bson:"overtime_holiday_minutes" json:"overtime_holiday_minutes"`
+	OvertimeWeightedMinutes int    `bson:"overtime_weighted_minutes" json:"overtime_weighted_minutes"` // paid overtime times the day type rate
+	CompTimeMinutes         int    `bson:"comp_time_minutes" json:"comp_time_minutes"`
+	HolidayWorkMinutes      int    `bson:"holiday_work_minutes" json:"holiday_work_minutes"`
+	RemoteDays              int    `bson:"remote_days" json:"remote_days"`
+	BusinessTripDays        int    `bson:"business_trip_days" json:"business_trip_days"`
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Webhook event types pushed to subscribed endpoints.
+const (
+	EventAttendanceCheckedIn  = "attendance.checked_in"
+	EventAttendanceCheckedOut = "attendance.checked_out"
+	EventLeaveRequested       = "leave.requested"
+	EventLeaveApproved        = "leave.approved"
+	EventLeaveRejected        = "leave.rejected"
+	EventLeaveChanged         = "leave.changed"   // date change approved
+	EventLeaveCancelled       = "leave.cancelled" // cancellation approved, whole or partial
+	EventBudgetCreated        = "budget.created"
+	EventBudgetStepChanged    = "budget.step_changed"
+	EventBudgetCompleted      = "budget.completed"
+	EventBudgetRejected       = "budget.rejected"
+	EventBudgetEdited         = "budget.edited"    // requester changed the request
+	EventBudgetWithdrawn      = "budget.withdrawn" // requester withdrew the request
+	EventTimesheetLocked      = "timesheet.locked" // month locked or correction closed
+)
+
+// WebhookSubscription is an endpoint and the events it receives. Events may be exact
+// names, "prefix.*" or "*".
+type WebhookSubscription struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"` // HMAC key for the X-Oktel-Signature header
+}
+
+// Matches reports whether the subscription receives the event.
+func (s WebhookSubscription) Matches(event string) bool {
+	for _, e := range s.Events {
+		if e == "*" || e == event {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(e, "*"); ok && strings.HasPrefix(event, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed" // gave up after the last attempt
+)
+
+// WebhookDelivery is one event sent to one endpoint, kept as the delivery log.
+type WebhookDelivery struct {
+	ID             bson.ObjectID         `bson:"_id,omitempty" json:"id"`
+	EventID        string                `bson:"event_id" json:"event_id"` // shared by all deliveries of an event
+	Event          string                `bson:"event" json:"event"`
+	UserID         string                `bson:"user_id,omitempty" json:"user_id,omitempty"` // user the event is about, for data exports and erasure
+	URL            string                `bson:"url" json:"url"`
+	Payload        string                `bson:"payload" json:"payload"` // JSON body exactly as signed
+	Status         WebhookDeliveryStatus `bson:"status" json:"status"`
+	Attempts       int                   `bson:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time             `bson:"next_attempt_at" json:"next_attempt_at"`
+	LastStatusCode int                   `bson:"last_status_code,omitempty" json:"last_status_code,omitempty"`
+	LastError      string                `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	DeliveredAt    *time.Time            `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,143 @@
+package photo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+const (
+	tagDateTime           = 0x0132
+	tagExifIFDPointer     = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+
+	exifTimeLayout = "2006:01:02 15:04:05"
+)
+
+// CaptureTime reads the capture time from a JPEG's EXIF block. It prefers
+// DateTimeOriginal (with OffsetTimeOriginal when present) and falls back to the
+// IFD0 DateTime. Only JPEG carries EXIF in practice; other formats return false.
+func CaptureTime(data []byte, loc *time.Location) (time.Time, bool) {
+	tiff := findExifTIFF(data)
+	if tiff == nil {
+		return time.Time{}, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return time.Time{}, false
+	}
+
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:8]))
+	value, offset := "", ""
+	if ptr, ok := ifd0[tagExifIFDPointer]; ok {
+		exif := readIFD(tiff, order, ptr.offset)
+		value = exif[tagDateTimeOriginal].ascii(tiff)
+		offset = exif[tagOffsetTimeOriginal].ascii(tiff)
+	}
+	if value == "" {
+		value = ifd0[tagDateTime].ascii(tiff)
+	}
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if offset != "" {
+		if t, err := time.Parse(exifTimeLayout+"-07:00", value+offset); err == nil {
+			return t, true
+		}
+	}
+	t, err := time.ParseInLocation(exifTimeLayout, value, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// findExifTIFF walks the JPEG markers and returns the TIFF payload of the APP1 Exif segment.
+func findExifTIFF(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil
+		}
+		marker := data[pos+1]
+		// Start of scan: image data follows, no more metadata segments.
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+		segment := data[pos+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			tiff := segment[6:]
+			if len(tiff) < 8 {
+				return nil
+			}
+			return tiff
+		}
+		pos = end
+	}
+	return nil
+}
+
+type ifdEntry struct {
+	typ    uint16
+	count  uint32
+	offset uint32 // value offset, or the inline value for small entries
+	inline []byte
+}
+
+// ascii returns the string value of an ASCII (type 2) entry.
+func (e ifdEntry) ascii(tiff []byte) string {
+	if e.typ != 2 || e.count == 0 {
+		return ""
+	}
+	var raw []byte
+	if e.count <= 4 {
+		raw = e.inline[:e.count]
+	} else {
+		end := uint64(e.offset) + uint64(e.count)
+		if end > uint64(len(tiff)) {
+			return ""
+		}
+		raw = tiff[e.offset:end]
+	}
+	return strings.TrimSpace(strings.TrimRight(string(raw), "\x00"))
+}
+
+// readIFD parses one image file directory into a tag → entry map.
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) map[uint16]ifdEntry {
+	entries := make(map[uint16]ifdEntry)
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return entries
+	}
+	n := int(order.Uint16(tiff[offset : offset+2]))
+	base := int(offset) + 2
+	for i := 0; i < n; i++ {
+		p := base + i*12
+		if p+12 > len(tiff) {
+			break
+		}
+		entries[order.Uint16(tiff[p:p+2])] = ifdEntry{
+			typ:    order.Uint16(tiff[p+2 : p+4]),
+			count:  order.Uint32(tiff[p+4 : p+8]),
+			offset: order.Uint32(tiff[p+8 : p+12]),
+			inline: tiff[p+8 : p+12],
+		}
+	}
+	return entries
+}
@@ -0,0 +1,185 @@
+package photo
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+type asciiTag struct {
+	tag   uint16
+	value string
+}
+
+// buildTIFF lays out a TIFF header, IFD0 with the given ASCII tags and, if exif is not nil,
+// a pointer to an Exif IFD with its own tags. Values longer than 4 bytes follow the IFDs.
+func buildTIFF(order binary.ByteOrder, ifd0, exif []asciiTag) []byte {
+	ifdSize := func(n int) int { return 2 + 12*n + 4 }
+	n0 := len(ifd0)
+	if exif != nil {
+		n0++
+	}
+	exifOffset := 8 + ifdSize(n0)
+	end := exifOffset
+	if exif != nil {
+		end += ifdSize(len(exif))
+	}
+
+	buf := make([]byte, end)
+	if order == binary.ByteOrder(binary.LittleEndian) {
+		copy(buf, "II")
+	} else {
+		copy(buf, "MM")
+	}
+	order.PutUint16(buf[2:], 42)
+	order.PutUint32(buf[4:], 8)
+
+	writeIFD := func(at int, tags []asciiTag, pointer bool) {
+		n := len(tags)
+		if pointer {
+			n++
+		}
+		order.PutUint16(buf[at:], uint16(n))
+		p := at + 2
+		for _, tag := range tags {
+			value := append([]byte(tag.value), 0)
+			order.PutUint16(buf[p:], tag.tag)
+			order.PutUint16(buf[p+2:], 2)
+			order.PutUint32(buf[p+4:], uint32(len(value)))
+			if len(value) <= 4 {
+				copy(buf[p+8:p+12], value)
+			} else {
+				order.PutUint32(buf[p+8:], uint32(len(buf)))
+				buf = append(buf, value...)
+			}
+			p += 12
+		}
+		if pointer {
+			order.PutUint16(buf[p:], tagExifIFDPointer)
+			order.PutUint16(buf[p+2:], 4)
+			order.PutUint32(buf[p+4:], 1)
+			order.PutUint32(buf[p+8:], uint32(exifOffset))
+		}
+	}
+	writeIFD(8, ifd0, exif != nil)
+	if exif != nil {
+		writeIFD(exifOffset, exif, false)
+	}
+	return buf
+}
+
+// jpegWithExif returns the start of a JPEG: a JFIF APP0 segment, then an APP1 Exif segment
+// holding tiff.
+func jpegWithExif(tiff []byte) []byte {
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x07, 'J', 'F', 'I', 'F', 0x00}
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	data = append(data, 0xFF, 0xE1, 0, 0)
+	binary.BigEndian.PutUint16(data[len(data)-2:], uint16(len(segment)+2))
+	data = append(data, segment...)
+	return append(data, 0xFF, 0xD9)
+}
+
+func TestCaptureTime(t *testing.T) {
+	loc := time.FixedZone("ICT", 7*60*60)
+	original := asciiTag{tagDateTimeOriginal, "2026:03:02 08:15:30"}
+	modified := asciiTag{tagDateTime, "2026:03:05 19:00:00"}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		want   time.Time
+		wantOK bool
+	}{
+		{
+			name:   "original time in the given location",
+			data:   jpegWithExif(buildTIFF(binary.LittleEndian, []asciiTag{modified}, []asciiTag{original})),
+			want:   time.Date(2026, 3, 2, 8, 15, 30, 0, loc),
+			wantOK: true,
+		},
+		{
+			name: "original time with its offset",
+			data: jpegWithExif(buildTIFF(binary.LittleEndian, nil, []asciiTag{
+				original, {tagOffsetTimeOriginal, "+09:00"},
+			})),
+			want:   time.Date(2026, 3, 2, 8, 15, 30, 0, time.FixedZone("", 9*60*60)),
+			wantOK: true,
+		},
+		{
+			name:   "big endian",
+			data:   jpegWithExif(buildTIFF(binary.BigEndian, nil, []asciiTag{original})),
+			want:   time.Date(2026, 3, 2, 8, 15, 30, 0, loc),
+			wantOK: true,
+		},
+		{
+			name:   "falls back to DateTime",
+			data:   jpegWithExif(buildTIFF(binary.BigEndian, []asciiTag{modified}, nil)),
+			want:   time.Date(2026, 3, 5, 19, 0, 0, 0, loc),
+			wantOK: true,
+		},
+		{
+			name: "unparseable time",
+			data: jpegWithExif(buildTIFF(binary.LittleEndian, nil, []asciiTag{{tagDateTimeOriginal, "yesterday"}})),
+		},
+		{
+			name: "no time tags",
+			data: jpegWithExif(buildTIFF(binary.LittleEndian, nil, []asciiTag{})),
+		},
+		{
+			name: "no Exif segment",
+			data: []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9},
+		},
+		{
+			name: "not a JPEG",
+			data: []byte("\x89PNG\r\n\x1a\n"),
+		},
+		{
+			name: "truncated segment",
+			data: jpegWithExif(buildTIFF(binary.LittleEndian, nil, []asciiTag{original}))[:30],
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := CaptureTime(tc.data, loc)
+			if ok != tc.wantOK {
+				t.Fatalf("CaptureTime ok = %v, want %v", ok, tc.wantOK)
+			}
+			if ok && !got.Equal(tc.want) {
+				t.Errorf("CaptureTime = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCaptureTimeCorruptOffsets(t *testing.T) {
+	tiff := buildTIFF(binary.LittleEndian, nil, []asciiTag{{tagDateTimeOriginal, "2026:03:02 08:15:30"}})
+	// Point IFD0 past the end of the data.
+	corrupt := append([]byte(nil), tiff...)
+	binary.LittleEndian.PutUint32(corrupt[4:], uint32(len(corrupt)+100))
+	if _, ok := CaptureTime(jpegWithExif(corrupt), time.UTC); ok {
+		t.Error("CaptureTime read a time through an IFD offset past the end of the data")
+	}
+
+	// Point the time value past the end of the data.
+	corrupt = append([]byte(nil), tiff...)
+	exifIFD := binary.LittleEndian.Uint32(corrupt[8+2+8:])
+	binary.LittleEndian.PutUint32(corrupt[exifIFD+2+8:], uint32(len(corrupt)))
+	if _, ok := CaptureTime(jpegWithExif(corrupt), time.UTC); ok {
+		t.Error("CaptureTime read a time through a value offset past the end of the data")
+	}
+}
+
+func TestAnalyzeReadsCaptureTime(t *testing.T) {
+	jpg := encodeJPEG(t, testImage(120, 80), 90)
+	tiff := buildTIFF(binary.LittleEndian, nil, []asciiTag{{tagDateTimeOriginal, "2026:03:02 08:15:30"}})
+	segment := jpegWithExif(tiff)
+	// Insert the APP0 and APP1 segments after the JPEG's SOI marker.
+	withExif := append(append(append([]byte(nil), jpg[:2]...), segment[2:len(segment)-2]...), jpg[2:]...)
+
+	info, err := Analyze(withExif, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 3, 2, 8, 15, 30, 0, time.UTC); info.TakenAt == nil || !info.TakenAt.Equal(want) {
+		t.Errorf("TakenAt = %v, want %v", info.TakenAt, want)
+	}
+}
@@ -0,0 +1,116 @@
+package photo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"strconv"
+	"time"
+)
+
+// maxPixels bounds the images Analyze decodes, so a small file can't declare huge dimensions.
+const maxPixels = 50_000_000
+
+// Info holds what the bot extracts from an uploaded attendance photo.
+type Info struct {
+	Hash    string     // perceptual hash as 16 hex digits
+	TakenAt *time.Time // EXIF capture time, nil if unavailable
+}
+
+// Analyze decodes an image, computes its perceptual hash and reads the EXIF capture time.
+// Capture times without a timezone offset are interpreted in loc.
+func Analyze(data []byte, loc *time.Location) (*Info, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	info := &Info{Hash: FormatHash(DHash(img))}
+	if t, ok := CaptureTime(data, loc); ok {
+		info.TakenAt = &t
+	}
+	return info, nil
+}
+
+// DHash computes a 64-bit difference hash. The image is reduced to a 9x8 grayscale
+// grid and each bit records whether a cell is brighter than its right neighbour,
+// so re-encoded, resized or slightly recompressed copies of a photo hash alike.
+func DHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	b := img.Bounds()
+	var grid [h][w]float64
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := b.Min.Y + (y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := b.Min.X + (x+1)*b.Dx()/w
+			grid[y][x] = averageLuma(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// averageLuma returns the mean luminance of the rectangle [x0,x1)x[y0,y1).
+// Large rectangles are sampled on a sparse grid to keep hashing cheap for camera photos.
+func averageLuma(img image.Image, x0, y0, x1, y1 int) float64 {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	step := 1
+	if span := max(x1-x0, y1-y0); span > 32 {
+		step = span / 32
+	}
+	var sum float64
+	var n int
+	for y := y0; y < y1; y += step {
+		for x := x0; x < x1; x += step {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+// FormatHash encodes a hash as 16 hex digits for storage.
+func FormatHash(h uint64) string {
+	return fmt.Sprintf("%016x", h)
+}
+
+// Distance returns the number of differing bits between two stored hashes,
+// or -1 if either hash cannot be parsed.
+func Distance(a, b string) int {
+	ha, err := strconv.ParseUint(a, 16, 64)
+	if err != nil {
+		return -1
+	}
+	hb, err := strconv.ParseUint(b, 16, 64)
+	if err != nil {
+		return -1
+	}
+	return bits.OnesCount64(ha ^ hb)
+}
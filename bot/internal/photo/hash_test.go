@@ -0,0 +1,156 @@
+package photo
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// testImage returns a w x h image of random gray blocks, so every cell of the hash grid
+// differs from its neighbours.
+func testImage(w, h int) *image.Gray {
+	rng := rand.New(rand.NewSource(1))
+	const block = 20
+	shades := make([][]uint8, h/block+1)
+	for i := range shades {
+		shades[i] = make([]uint8, w/block+1)
+		for j := range shades[i] {
+			shades[i][j] = uint8(rng.Intn(256))
+		}
+	}
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{Y: shades[y/block][x/block]})
+		}
+	}
+	return img
+}
+
+// halve returns img scaled down to half size by averaging 2x2 pixels.
+func halve(img *image.Gray) *image.Gray {
+	b := img.Bounds()
+	out := image.NewGray(image.Rect(0, 0, b.Dx()/2, b.Dy()/2))
+	for y := 0; y < b.Dy()/2; y++ {
+		for x := 0; x < b.Dx()/2; x++ {
+			sum := int(img.GrayAt(2*x, 2*y).Y) + int(img.GrayAt(2*x+1, 2*y).Y) +
+				int(img.GrayAt(2*x, 2*y+1).Y) + int(img.GrayAt(2*x+1, 2*y+1).Y)
+			out.SetGray(x, y, color.Gray{Y: uint8(sum / 4)})
+		}
+	}
+	return out
+}
+
+// mirror returns img flipped horizontally.
+func mirror(img *image.Gray) *image.Gray {
+	b := img.Bounds()
+	out := image.NewGray(b)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			out.SetGray(b.Dx()-1-x, y, img.GrayAt(x, y))
+		}
+	}
+	return out
+}
+
+func encodeJPEG(t *testing.T, img image.Image, quality int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{"identical", "00ff00ff00ff00ff", "00ff00ff00ff00ff", 0},
+		{"one bit", "0000000000000000", "0000000000000001", 1},
+		{"every bit", "0000000000000000", "ffffffffffffffff", 64},
+		{"case insensitive", "00000000000000AB", "00000000000000ab", 0},
+		{"invalid first", "not a hash", "0000000000000000", -1},
+		{"invalid second", "0000000000000000", "", -1},
+		{"too long", "0000000000000000", "10000000000000000", -1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Distance(tc.a, tc.b); got != tc.want {
+				t.Errorf("Distance(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDHash(t *testing.T) {
+	img := testImage(360, 240)
+	hash := FormatHash(DHash(img))
+
+	decode := func(t *testing.T, data []byte) image.Image {
+		t.Helper()
+		decoded, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decoded
+	}
+
+	t.Run("copies hash alike", func(t *testing.T) {
+		copies := map[string]image.Image{
+			"recompressed": decode(t, encodeJPEG(t, img, 60)),
+			"resized":      halve(img),
+			"both":         decode(t, encodeJPEG(t, halve(img), 50)),
+		}
+		for name, c := range copies {
+			if d := Distance(hash, FormatHash(DHash(c))); d > 4 {
+				t.Errorf("%s copy is %d bits away, want at most 4", name, d)
+			}
+		}
+	})
+
+	t.Run("different photos hash apart", func(t *testing.T) {
+		if d := Distance(hash, FormatHash(DHash(mirror(img)))); d < 16 {
+			t.Errorf("mirrored photo is %d bits away, want at least 16", d)
+		}
+	})
+
+	t.Run("uniform image", func(t *testing.T) {
+		if h := DHash(image.NewGray(image.Rect(0, 0, 50, 50))); h != 0 {
+			t.Errorf("DHash of a blank image = %016x, want 0", h)
+		}
+	})
+
+	t.Run("images smaller than the grid", func(t *testing.T) {
+		DHash(testImage(3, 2)) // must not panic or divide by zero
+	})
+}
+
+func TestAnalyze(t *testing.T) {
+	img := testImage(120, 80)
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := Analyze(pngData.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := FormatHash(DHash(img)); info.Hash != want {
+		t.Errorf("Hash = %s, want %s", info.Hash, want)
+	}
+	if info.TakenAt != nil {
+		t.Errorf("TakenAt = %v for a PNG without EXIF, want nil", info.TakenAt)
+	}
+
+	if _, err := Analyze([]byte("not an image"), nil); err == nil {
+		t.Error("Analyze accepted data that is not an image")
+	}
+}
@@ -3,13 +3,16 @@ package scheduler
 import (
 	"context"
 	"log"
+	"sync"
 	"time"
 
 	"golang.org/x/sync/errgroup"
 
 	"oktel-bot/internal/i18n"
 	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/metrics"
 	"oktel-bot/internal/model"
+	"oktel-bot/internal/service"
 	"oktel-bot/internal/store"
 )
 
@@ -17,40 +20,76 @@ var vnTZ = time.FixedZone("UTC+7", 7*60*60)
 
 // ActivityChecker periodically DMs users who are currently working
 // to confirm they are still active. All state is stored in the attendance record.
+// Whether a team is checked, how often and where expiries are reported come from the
+// team settings.
 type ActivityChecker struct {
-	store       *store.AttendanceStore
-	mm          *mattermost.Client
-	botURL      string
-	period      time.Duration
-	timeout     time.Duration
-	interval    time.Duration
-	channelName string
+	store    store.AttendanceStore
+	mm       *mattermost.Client
+	botURL   string
+	interval time.Duration
+	settings *service.TeamSettingsService
+
+	mu       sync.Mutex
+	channels map[string]notifyChannel // by team ID
+}
+
+// notifyChannel is a resolved activity check channel.
+type notifyChannel struct {
+	name string
+	id   string
 }
 
 // NewActivityChecker creates a new ActivityChecker.
-func NewActivityChecker(store *store.AttendanceStore, mm *mattermost.Client, botURL string, periodSec, timeoutSec, intervalSec int, channelName string) *ActivityChecker {
-	return &ActivityChecker{
-		store:       store,
-		mm:          mm,
-		botURL:      botURL,
-		period:      time.Duration(periodSec) * time.Second,
-		timeout:     time.Duration(timeoutSec) * time.Second,
-		interval:    time.Duration(intervalSec) * time.Second,
-		channelName: channelName,
+func NewActivityChecker(store store.AttendanceStore, mm *mattermost.Client, botURL string, intervalSec int, settings *service.TeamSettingsService) *ActivityChecker {
+	ac := &ActivityChecker{
+		store:    store,
+		mm:       mm,
+		botURL:   botURL,
+		interval: time.Duration(intervalSec) * time.Second,
+		settings: settings,
+		channels: map[string]notifyChannel{},
 	}
+	settings.OnChange(func(teamID string) {
+		ac.mu.Lock()
+		delete(ac.channels, teamID)
+		ac.mu.Unlock()
+	})
+	return ac
 }
 
 func (ac *ActivityChecker) getNotificationChannelID(teamID, fallback string) string {
-	if ac.channelName == "" {
+	name := ac.settings.Config(teamID).ActivityCheckChannel
+	if name == "" {
 		return fallback
 	}
-	chID, err := ac.mm.GetChannelByName(teamID, ac.channelName)
+	ac.mu.Lock()
+	ch, ok := ac.channels[teamID]
+	ac.mu.Unlock()
+	if ok && ch.name == name {
+		return ch.id
+	}
+	chID, err := ac.mm.GetChannelByName(teamID, name)
 	if err != nil || chID == "" {
 		return fallback
 	}
+	ac.mu.Lock()
+	ac.channels[teamID] = notifyChannel{name: name, id: chID}
+	ac.mu.Unlock()
 	return chID
 }
 
+// noticeLocale returns the locale of notices posted to a team channel: the team's locale,
+// or else the user's.
+func (ac *ActivityChecker) noticeLocale(teamID, userID string) string {
+	if locale := ac.settings.Config(teamID).Locale; locale != "" {
+		return locale
+	}
+	if user, _ := ac.mm.GetUser(userID); user != nil {
+		return user.Locale
+	}
+	return ""
+}
+
 // Start runs the activity check loop. It blocks until ctx is cancelled.
 func (ac *ActivityChecker) Start(ctx context.Context) {
 	ticker := time.NewTicker(ac.interval)
@@ -70,14 +109,16 @@ func (ac *ActivityChecker) Start(ctx context.Context) {
 }
 
 func (ac *ActivityChecker) tick(ctx context.Context) {
-	today := time.Now().In(vnTZ).Format(time.DateOnly)
-	records, err := ac.store.GetAttendanceByDate(ctx, today)
+	// Teams in other timezones may already be on tomorrow's date or still on yesterday's
+	now := time.Now()
+	from := now.In(vnTZ).AddDate(0, 0, -1).Format(time.DateOnly)
+	to := now.In(vnTZ).AddDate(0, 0, 1).Format(time.DateOnly)
+	records, err := ac.store.GetAttendanceByDateRange(ctx, from, to, "", "", "")
 	if err != nil {
 		log.Printf("activity check: get attendance: %v", err)
 		return
 	}
 
-	now := time.Now()
 	g, gctx := errgroup.WithContext(ctx)
 	g.SetLimit(20)
 
@@ -85,10 +126,14 @@ func (ac *ActivityChecker) tick(ctx context.Context) {
 		if rec.Status != model.AttendanceStatusWorking {
 			continue
 		}
+		cfg := ac.settings.Config(rec.TeamID)
+		if !cfg.ActivityCheck || rec.Date != now.In(cfg.Location).Format(time.DateOnly) {
+			continue
+		}
 
 		// Handle expired pending checks
 		if rec.LastCheckStatus == model.ActivityCheckPending && rec.LastCheckAt != nil {
-			if now.Sub(*rec.LastCheckAt) >= ac.timeout {
+			if now.Sub(*rec.LastCheckAt) >= cfg.ActivityCheckTimeout {
 				g.Go(func() error {
 					ac.expireCheck(gctx, rec)
 					return nil
@@ -103,12 +148,12 @@ func (ac *ActivityChecker) tick(ctx context.Context) {
 			baseline = *rec.LastCheckAt
 		}
 
-		if now.Before(baseline.Add(ac.period)) {
+		if now.Before(baseline.Add(cfg.ActivityCheckPeriod)) {
 			continue
 		}
 
 		g.Go(func() error {
-			ac.sendCheck(gctx, rec)
+			ac.sendCheck(gctx, rec, cfg.ActivityCheckTimeout)
 			return nil
 		})
 	}
@@ -116,7 +161,7 @@ func (ac *ActivityChecker) tick(ctx context.Context) {
 	_ = g.Wait()
 }
 
-func (ac *ActivityChecker) sendCheck(ctx context.Context, rec *model.AttendanceRecord) {
+func (ac *ActivityChecker) sendCheck(ctx context.Context, rec *model.AttendanceRecord, timeout time.Duration) {
 	user, err := ac.mm.GetUser(rec.UserID)
 	if err != nil {
 		log.Printf("activity check: get user %s: %v", rec.UserID, err)
@@ -124,7 +169,7 @@ func (ac *ActivityChecker) sendCheck(ctx context.Context, rec *model.AttendanceR
 	}
 
 	lctx := i18n.WithLocale(ctx, user.Locale)
-	timeoutSec := int(ac.timeout.Seconds())
+	timeoutSec := int(timeout.Seconds())
 	prompt := i18n.T(lctx, "activity.check.prompt", map[string]any{"Timeout": timeoutSec})
 	note := i18n.T(lctx, "activity.check.note")
 	btnLabel := i18n.T(lctx, "activity.check.btn.confirm")
@@ -140,6 +185,7 @@ func (ac *ActivityChecker) sendCheck(ctx context.Context, rec *model.AttendanceR
 						URL: ac.botURL + "/api/attendance/activity-confirm",
 						Context: map[string]any{
 							"user_id": rec.UserID,
+							"date":    rec.Date,
 						},
 					},
 				}},
@@ -150,6 +196,7 @@ func (ac *ActivityChecker) sendCheck(ctx context.Context, rec *model.AttendanceR
 		log.Printf("activity check: send DM to %s: %v", rec.Username, err)
 		return
 	}
+	metrics.ActivityChecks.WithLabelValues(metrics.ActivityCheckSent).Inc()
 
 	now := time.Now()
 	rec.LastCheckAt = &now
@@ -162,8 +209,7 @@ func (ac *ActivityChecker) sendCheck(ctx context.Context, rec *model.AttendanceR
 
 func (ac *ActivityChecker) expireCheck(ctx context.Context, rec *model.AttendanceRecord) {
 	// Re-check current status
-	today := time.Now().In(vnTZ).Format(time.DateOnly)
-	fresh, err := ac.store.GetTodayRecord(ctx, rec.UserID, today)
+	fresh, err := ac.store.GetTodayRecord(ctx, rec.UserID, rec.Date)
 	if err != nil {
 		log.Printf("activity check: re-check %s: %v", rec.UserID, err)
 		return
@@ -177,14 +223,10 @@ func (ac *ActivityChecker) expireCheck(ctx context.Context, rec *model.Attendanc
 		log.Printf("activity check: update expired for %s: %v", rec.UserID, err)
 		return
 	}
+	metrics.ActivityChecks.WithLabelValues(metrics.ActivityCheckExpired).Inc()
 
 	// Post notification in attendance channel
-	user, _ := ac.mm.GetUser(rec.UserID)
-	locale := ""
-	if user != nil {
-		locale = user.Locale
-	}
-	lctx := i18n.WithLocale(ctx, locale)
+	lctx := i18n.WithLocale(ctx, ac.noticeLocale(fresh.TeamID, rec.UserID))
 	msg := i18n.T(lctx, "activity.check.expired", map[string]any{
 		"Username": fresh.Username,
 	})
@@ -197,28 +239,36 @@ func (ac *ActivityChecker) expireCheck(ctx context.Context, rec *model.Attendanc
 	}
 
 	// Update DM to show expired message
+	user, _ := ac.mm.GetUser(rec.UserID)
+	if user != nil {
+		lctx = i18n.WithLocale(ctx, user.Locale)
+	}
 	ac.mm.UpdatePost(fresh.LastCheckPostID, &mattermost.Post{
 		Message: i18n.T(lctx, "activity.check.dm.expired"),
 		Props:   mattermost.Props{Attachments: []mattermost.Attachment{}},
 	})
 }
 
-// HandleConfirm processes a user's confirm button click.
+// HandleConfirm processes a user's confirm button click for the record of the given date,
+// or of today (UTC+7) for buttons sent before the date was included.
 // Checks the DB record to determine if within timeout.
-func (ac *ActivityChecker) HandleConfirm(ctx context.Context, userID string) model.ActivityCheckStatus {
-	today := time.Now().In(vnTZ).Format(time.DateOnly)
-	rec, err := ac.store.GetTodayRecord(ctx, userID, today)
+func (ac *ActivityChecker) HandleConfirm(ctx context.Context, userID, date string) model.ActivityCheckStatus {
+	if date == "" {
+		date = time.Now().In(vnTZ).Format(time.DateOnly)
+	}
+	rec, err := ac.store.GetTodayRecord(ctx, userID, date)
 	if err != nil || rec == nil || rec.LastCheckStatus != model.ActivityCheckPending {
 		return ""
 	}
 
 	now := time.Now()
 
-	if rec.LastCheckAt != nil && now.Sub(*rec.LastCheckAt) <= ac.timeout {
+	if rec.LastCheckAt != nil && now.Sub(*rec.LastCheckAt) <= ac.settings.Config(rec.TeamID).ActivityCheckTimeout {
 		rec.LastCheckStatus = model.ActivityCheckConfirmed
 		if err := ac.store.UpdateRecord(ctx, rec); err != nil {
 			log.Printf("activity check: update confirmed for %s: %v", userID, err)
 		}
+		metrics.ActivityChecks.WithLabelValues(metrics.ActivityCheckConfirmed).Inc()
 		return model.ActivityCheckConfirmed
 	}
 
@@ -227,14 +277,10 @@ func (ac *ActivityChecker) HandleConfirm(ctx context.Context, userID string) mod
 	if err := ac.store.UpdateRecord(ctx, rec); err != nil {
 		log.Printf("activity check: update expired for %s: %v", userID, err)
 	}
+	metrics.ActivityChecks.WithLabelValues(metrics.ActivityCheckExpired).Inc()
 
 	// Post notification
-	user, _ := ac.mm.GetUser(userID)
-	locale := ""
-	if user != nil {
-		locale = user.Locale
-	}
-	lctx := i18n.WithLocale(ctx, locale)
+	lctx := i18n.WithLocale(ctx, ac.noticeLocale(rec.TeamID, userID))
 	msg := i18n.T(lctx, "activity.check.expired", map[string]any{
 		"Username": rec.Username,
 	})
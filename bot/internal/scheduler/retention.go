@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"oktel-bot/internal/service"
+)
+
+// RetentionJob runs the data retention policies once a day at a fixed local time, like the
+// server's data retention deletion job.
+type RetentionJob struct {
+	svc  *service.RetentionService
+	hour int
+	min  int
+}
+
+// NewRetentionJob creates a job that runs daily at startTime (HH:MM, UTC+7).
+func NewRetentionJob(svc *service.RetentionService, startTime string) (*RetentionJob, error) {
+	t, err := time.Parse("15:04", startTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start time %q: %w", startTime, err)
+	}
+	return &RetentionJob{svc: svc, hour: t.Hour(), min: t.Minute()}, nil
+}
+
+// Start runs the job until ctx is cancelled. If today's start time has already passed it runs
+// right away; the service skips days that another replica has already run.
+func (j *RetentionJob) Start(ctx context.Context) {
+	for {
+		now := time.Now().In(vnTZ)
+		next := time.Date(now.Year(), now.Month(), now.Day(), j.hour, j.min, 0, 0, vnTZ)
+		if next.After(now) {
+			select {
+			case <-ctx.Done():
+				log.Println("retention job stopped")
+				return
+			case <-time.After(next.Sub(now)):
+			}
+		}
+
+		j.run(ctx)
+
+		// Wait for tomorrow's start time
+		tomorrow := next.AddDate(0, 0, 1)
+		select {
+		case <-ctx.Done():
+			log.Println("retention job stopped")
+			return
+		case <-time.After(time.Until(tomorrow)):
+		}
+	}
+}
+
+func (j *RetentionJob) run(ctx context.Context) {
+	run, err := j.svc.Run(ctx, time.Now())
+	if err != nil {
+		log.Printf("ERROR retention job: %v", err)
+	}
+	if run != nil {
+		log.Printf("retention job: %d photos purged, %d records anonymized, %d attendance records and %d leave requests deleted",
+			run.PhotosPurged, run.DevicesAnonymized, run.RecordsDeleted, run.LeaveDeleted)
+	}
+}
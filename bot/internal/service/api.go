@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/store"
+)
+
+// Page sizes for REST API list endpoints.
+const (
+	defaultPerPage = 60
+	maxPerPage     = 200
+)
+
+// InvalidInputError marks an error caused by the caller's input rather than a failure of the bot.
+type InvalidInputError struct {
+	Err error
+}
+
+func (e *InvalidInputError) Error() string { return e.Err.Error() }
+func (e *InvalidInputError) Unwrap() error { return e.Err }
+
+func invalidInput(msg string) error {
+	return &InvalidInputError{Err: errors.New(msg)}
+}
+
+// Page is one page of a list query.
+type Page[T any] struct {
+	Items   []T   `json:"items"`
+	Page    int   `json:"page"`
+	PerPage int   `json:"per_page"`
+	Total   int64 `json:"total"`
+}
+
+// pageBounds normalizes a zero-based page and page size and returns the documents to skip.
+func pageBounds(page, perPage int) (int, int, int) {
+	if page < 0 {
+		page = 0
+	}
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	perPage = min(perPage, maxPerPage)
+	return page, perPage, page * perPage
+}
+
+// validateRange checks optional YYYY-MM-DD bounds.
+func validateRange(ctx context.Context, from, to string) error {
+	for _, d := range []string{from, to} {
+		if d == "" {
+			continue
+		}
+		if err := validateDateFormat(ctx, []string{d}); err != nil {
+			return &InvalidInputError{Err: err}
+		}
+	}
+	if from != "" && to != "" && from > to {
+		return invalidInput(i18n.T(ctx, "api.err.invalid_range"))
+	}
+	return nil
+}
+
+// ListLeaveRequests returns a page of leave requests matching f, newest first.
+func (s *AttendanceService) ListLeaveRequests(ctx context.Context, f store.LeaveRequestFilter, page, perPage int) (*Page[*model.LeaveRequest], error) {
+	if err := validateRange(ctx, f.From, f.To); err != nil {
+		return nil, err
+	}
+	page, perPage, skip := pageBounds(page, perPage)
+	items, total, err := s.store.ListLeaveRequests(ctx, f, skip, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &Page[*model.LeaveRequest]{Items: items, Page: page, PerPage: perPage, Total: total}, nil
+}
+
+// GetLeaveRequest returns a leave request by ID, or nil if it does not exist.
+func (s *AttendanceService) GetLeaveRequest(ctx context.Context, requestID string) (*model.LeaveRequest, error) {
+	id, err := bson.ObjectIDFromHex(requestID)
+	if err != nil {
+		return nil, nil
+	}
+	return s.store.GetLeaveRequestByID(ctx, id)
+}
+
+// ListAttendance returns a page of attendance records matching f, latest date first.
+func (s *AttendanceService) ListAttendance(ctx context.Context, f store.AttendanceFilter, page, perPage int) (*Page[*model.AttendanceRecord], error) {
+	if err := validateRange(ctx, f.From, f.To); err != nil {
+		return nil, err
+	}
+	page, perPage, skip := pageBounds(page, perPage)
+	items, total, err := s.store.ListAttendance(ctx, f, skip, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &Page[*model.AttendanceRecord]{Items: items, Page: page, PerPage: perPage, Total: total}, nil
+}
+
+// importableTypes are the leave types that can be imported; overtime needs a worked
+// day to reconcile against, so it is only requested in chat.
+var importableTypes = []model.LeaveType{
+	model.LeaveTypeOff, model.LeaveTypeLateArrival, model.LeaveTypeEarlyDeparture,
+	model.LeaveTypeRemote, model.LeaveTypeBusinessTrip,
+}
+
+// LeaveImport is leave approved in another system.
+type LeaveImport struct {
+	UserID       string          `json:"user_id"`
+	TeamID       string          `json:"team_id"`
+	Type         model.LeaveType `json:"type"`
+	Dates        []string        `json:"dates"`
+	Reason       string          `json:"reason"`
+	ExpectedTime string          `json:"expected_time"` // HH:MM, required for late arrival / early departure
+	ExternalID   string          `json:"external_id"`   // the source system's ID; makes re-imports idempotent
+}
+
+// ImportLeave stores leave approved elsewhere as an approved request, without posting to
+// Mattermost. It applies the same date and overlap rules as requests made in chat, except
+// that past dates are allowed. Importing an external ID again returns the existing request
+// with created=false. source is the name of the API key making the import.
+func (s *AttendanceService) ImportLeave(ctx context.Context, in LeaveImport, source string) (*model.LeaveRequest, bool, error) {
+	if in.UserID == "" {
+		return nil, false, invalidInput(i18n.T(ctx, "api.err.user_required"))
+	}
+	if !slices.Contains(importableTypes, in.Type) {
+		return nil, false, invalidInput(i18n.T(ctx, "api.err.invalid_type", map[string]any{"Type": string(in.Type)}))
+	}
+	if in.Type == model.LeaveTypeLateArrival || in.Type == model.LeaveTypeEarlyDeparture {
+		if _, err := time.Parse("15:04", in.ExpectedTime); err != nil {
+			return nil, false, invalidInput(i18n.T(ctx, "api.err.invalid_time"))
+		}
+	} else {
+		in.ExpectedTime = ""
+	}
+	if err := validateDateFormat(ctx, in.Dates); err != nil {
+		return nil, false, &InvalidInputError{Err: err}
+	}
+	dates := slices.Clone(in.Dates)
+	slices.Sort(dates)
+	dates = slices.Compact(dates)
+	if err := s.timesheets.CheckEditable(ctx, in.TeamID, dates); err != nil {
+		return nil, false, &InvalidInputError{Err: err}
+	}
+
+	if in.ExternalID != "" {
+		existing, _, err := s.store.ListLeaveRequests(ctx, store.LeaveRequestFilter{Source: source, ExternalID: in.ExternalID}, 0, 1)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(existing) > 0 {
+			return existing[0], false, nil
+		}
+	}
+
+	user, err := s.mm.GetUser(in.UserID)
+	if err != nil {
+		return nil, false, invalidInput(i18n.T(ctx, "api.err.unknown_user", map[string]any{"UserID": in.UserID}))
+	}
+
+	now := time.Now()
+	req := &model.LeaveRequest{
+		UserID:       user.ID,
+		Username:     user.Username,
+		TeamID:       in.TeamID,
+		Type:         in.Type,
+		Dates:        dates,
+		Reason:       in.Reason,
+		ExpectedTime: in.ExpectedTime,
+		Status:       model.LeaveStatusApproved,
+		ApprovedAt:   &now,
+		Source:       source,
+		ExternalID:   in.ExternalID,
+	}
+	if err := s.checkOverlap(ctx, req); err != nil {
+		return nil, false, err
+	}
+	if err := s.store.CreateLeaveRequest(ctx, req); err != nil {
+		return nil, false, fmt.Errorf("create leave request: %w", err)
+	}
+	s.hooks.Emit(ctx, model.EventLeaveApproved, map[string]any{"leave_request": req})
+	return req, true, nil
+}
+
+// BalanceAdjustment is a change to a user's comp time balance made in another system.
+type BalanceAdjustment struct {
+	UserID     string `json:"user_id"`
+	Minutes    int    `json:"minutes"` // positive to credit, negative to debit
+	Reason     string `json:"reason"`
+	ExternalID string `json:"external_id"` // the source system's ID; makes re-imports idempotent
+}
+
+// AdjustCompTime records a comp time adjustment and returns it with the user's new balance
+// in minutes. Importing an external ID again returns the existing adjustment with
+// created=false. source is the name of the API key making the import.
+func (s *AttendanceService) AdjustCompTime(ctx context.Context, in BalanceAdjustment, source string) (*model.CompTimeAdjustment, int, bool, error) {
+	if in.UserID == "" {
+		return nil, 0, false, invalidInput(i18n.T(ctx, "api.err.user_required"))
+	}
+	if in.Minutes == 0 {
+		return nil, 0, false, invalidInput(i18n.T(ctx, "api.err.minutes_required"))
+	}
+	if strings.TrimSpace(in.Reason) == "" {
+		return nil, 0, false, invalidInput(i18n.T(ctx, "api.err.reason_required"))
+	}
+
+	adj, err := s.findCompTimeAdjustment(ctx, source, in.ExternalID)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	created := adj == nil
+	if created {
+		if _, err := s.mm.GetUser(in.UserID); err != nil {
+			return nil, 0, false, invalidInput(i18n.T(ctx, "api.err.unknown_user", map[string]any{"UserID": in.UserID}))
+		}
+		adj = &model.CompTimeAdjustment{
+			UserID:     in.UserID,
+			Minutes:    in.Minutes,
+			Reason:     strings.TrimSpace(in.Reason),
+			Source:     source,
+			ExternalID: in.ExternalID,
+		}
+		if err := s.store.CreateCompTimeAdjustment(ctx, adj); err != nil {
+			return nil, 0, false, fmt.Errorf("create comp time adjustment: %w", err)
+		}
+	}
+
+	balance, err := s.CompTimeBalance(ctx, adj.UserID)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return adj, balance, created, nil
+}
+
+func (s *AttendanceService) findCompTimeAdjustment(ctx context.Context, source, externalID string) (*model.CompTimeAdjustment, error) {
+	if externalID == "" {
+		return nil, nil
+	}
+	adj, err := s.store.FindCompTimeAdjustment(ctx, source, externalID)
+	if err != nil {
+		return nil, fmt.Errorf("find comp time adjustment: %w", err)
+	}
+	return adj, nil
+}
+
+// ListRequests returns a page of budget requests matching the filters, newest first.
+// state is "open", "completed", "rejected" or "withdrawn"; from and to (YYYY-MM-DD) bound the creation date.
+func (s *BudgetService) ListRequests(ctx context.Context, teamID, step, state, from, to string, page, perPage int) (*Page[*model.BudgetRequest], error) {
+	if err := validateRange(ctx, from, to); err != nil {
+		return nil, err
+	}
+	f := store.BudgetFilter{TeamID: teamID, State: state}
+	if step != "" {
+		n, err := strconv.Atoi(step)
+		if err != nil || n < int(model.BudgetStepSaleCreated) || n > int(model.BudgetStepCompleted) {
+			return nil, invalidInput(i18n.T(ctx, "api.err.invalid_step", map[string]any{"Step": step}))
+		}
+		f.Step = model.BudgetStep(n)
+	}
+	switch state {
+	case "", "open", "completed", "rejected", "withdrawn":
+	default:
+		return nil, invalidInput(i18n.T(ctx, "api.err.invalid_state", map[string]any{"State": state}))
+	}
+	if from != "" {
+		f.CreatedFrom, _ = time.ParseInLocation(time.DateOnly, from, vnTZ)
+	}
+	if to != "" {
+		t, _ := time.ParseInLocation(time.DateOnly, to, vnTZ)
+		f.CreatedBefore = t.AddDate(0, 0, 1)
+	}
+
+	page, perPage, skip := pageBounds(page, perPage)
+	items, total, err := s.store.List(ctx, f, skip, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &Page[*model.BudgetRequest]{Items: items, Page: page, PerPage: perPage, Total: total}, nil
+}
+
+// GetRequest returns a budget request by ID, or nil if it does not exist.
+func (s *BudgetService) GetRequest(ctx context.Context, requestID string) (*model.BudgetRequest, error) {
+	id, err := bson.ObjectIDFromHex(requestID)
+	if err != nil {
+		return nil, nil
+	}
+	return s.store.GetByID(ctx, id)
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/store"
+)
+
+// apiKeyPrefix marks bot API keys so they are easy to spot in configs and secret scanners.
+const apiKeyPrefix = "okb_"
+
+// APIKeyService issues and verifies the API keys external systems use for /api/v1.
+type APIKeyService struct {
+	store store.APIKeyStore
+}
+
+func NewAPIKeyService(store store.APIKeyStore) *APIKeyService {
+	return &APIKeyService{store: store}
+}
+
+// Create issues a key with the given scopes. The returned secret is shown once; only its
+// hash is stored.
+func (s *APIKeyService) Create(ctx context.Context, name string, scopes []string) (string, *model.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, errors.New("name is required")
+	}
+	var clean []string
+	for _, sc := range scopes {
+		if sc = strings.TrimSpace(sc); sc == "" || slices.Contains(clean, sc) {
+			continue
+		}
+		if !slices.Contains(model.APIScopes, sc) {
+			return "", nil, fmt.Errorf("unknown scope %q (valid: %s)", sc, strings.Join(model.APIScopes, ", "))
+		}
+		clean = append(clean, sc)
+	}
+	if len(clean) == 0 {
+		return "", nil, errors.New("at least one scope is required")
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, fmt.Errorf("generate key: %w", err)
+	}
+	secret := apiKeyPrefix + hex.EncodeToString(b)
+	key := &model.APIKey{
+		Name:   name,
+		Prefix: secret[:len(apiKeyPrefix)+8],
+		Hash:   hashAPIKey(secret),
+		Scopes: clean,
+	}
+	if err := s.store.Create(ctx, key); err != nil {
+		return "", nil, fmt.Errorf("create api key: %w", err)
+	}
+	return secret, key, nil
+}
+
+// Authenticate returns the active key matching secret, or nil if there is none.
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (*model.APIKey, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, nil
+	}
+	key, err := s.store.GetByHash(ctx, hashAPIKey(secret))
+	if err != nil || key == nil || key.RevokedAt != nil {
+		return nil, err
+	}
+	// Record usage at most once a minute per key
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > time.Minute {
+		if err := s.store.TouchLastUsed(ctx, key.ID, now); err != nil {
+			log.Printf("api: touch key %s: %v", key.Prefix, err)
+		}
+	}
+	return key, nil
+}
+
+func (s *APIKeyService) List(ctx context.Context) ([]*model.APIKey, error) {
+	return s.store.List(ctx)
+}
+
+// Revoke disables a key. It reports whether an active key with that ID existed.
+func (s *APIKeyService) Revoke(ctx context.Context, keyID string) (bool, error) {
+	id, err := bson.ObjectIDFromHex(keyID)
+	if err != nil {
+		return false, fmt.Errorf("invalid key ID: %w", err)
+	}
+	return s.store.Revoke(ctx, id)
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+)
+
+// Decisions taken on several requests at once from /xinphep pending.
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+// PendingApproval is a request awaiting an approver's decision. Date is the day it is
+// about: the new date of a date change, else the request's first date.
+type PendingApproval struct {
+	Request *model.LeaveRequest
+	Date    string
+	Change  bool // a date change of an approved request
+}
+
+// PendingApprovals returns the team's leave requests and date changes that userID can
+// decide on, ordered by date: those in approval channels they are a member of, or act in
+// as a delegate.
+func (s *AttendanceService) PendingApprovals(ctx context.Context, teamID, userID string) ([]PendingApproval, error) {
+	reqs, err := s.store.FindAwaitingApproval(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	allowed := map[string]bool{}
+	var out []PendingApproval
+	for _, req := range reqs {
+		ok, seen := allowed[req.ApprovalChannelID]
+		if !seen {
+			ok = s.canApprove(ctx, req.ApprovalChannelID, userID)
+			allowed[req.ApprovalChannelID] = ok
+		}
+		if !ok {
+			continue
+		}
+		p := PendingApproval{Request: req, Change: req.Status == model.LeaveStatusPendingChange}
+		if p.Change {
+			p.Date = req.NewDate
+		} else if len(req.Dates) > 0 {
+			p.Date = req.Dates[0]
+		}
+		out = append(out, p)
+	}
+	// Stable, so requests of the same day stay oldest first
+	slices.SortStableFunc(out, func(a, b PendingApproval) int { return cmp.Compare(a.Date, b.Date) })
+	return out, nil
+}
+
+// canApprove reports whether userID may decide on requests posted to an approval channel:
+// as a member of it, or as the delegate of a member.
+func (s *AttendanceService) canApprove(ctx context.Context, approvalChannelID, userID string) bool {
+	members, err := s.mm.GetChannelMembers(approvalChannelID)
+	if err == nil {
+		for _, m := range members {
+			if m.UserID == userID {
+				return true
+			}
+		}
+	}
+	return s.delegations.OnBehalfOf(ctx, model.DelegationScopeAttendance, approvalChannelID, userID) != nil
+}
+
+// BulkDecisionResult is the outcome of DecidePending.
+type BulkDecisionResult struct {
+	Done     []*model.LeaveRequest
+	Failures []BulkDecisionFailure
+}
+
+// BulkDecisionFailure is a request DecidePending could not act on. Request is nil when the
+// request could not be loaded.
+type BulkDecisionFailure struct {
+	RequestID string
+	Request   *model.LeaveRequest
+	Err       error
+}
+
+// DecidePending approves or rejects several requests with one shared reason. Each request is
+// handled as its own approve or reject buttons would: new requests through ApproveLeave and
+// RejectLeave, date changes through ApproveDateChange and RejectDateChange, and the approval
+// posts lose their buttons. Requests the user cannot decide on, or that were decided
+// meanwhile, are reported as failures without stopping the others.
+func (s *AttendanceService) DecidePending(ctx context.Context, requestIDs []string, decision, reason, userID, username string) *BulkDecisionResult {
+	res := &BulkDecisionResult{}
+	allowed := map[string]bool{}
+	for _, requestID := range requestIDs {
+		req, err := s.decidePending(ctx, requestID, decision, reason, userID, username, allowed)
+		if err != nil {
+			res.Failures = append(res.Failures, BulkDecisionFailure{RequestID: requestID, Request: req, Err: err})
+			continue
+		}
+		res.Done = append(res.Done, req)
+	}
+	return res
+}
+
+func (s *AttendanceService) decidePending(ctx context.Context, requestID, decision, reason, userID, username string, allowed map[string]bool) (*model.LeaveRequest, error) {
+	id, err := bson.ObjectIDFromHex(requestID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request ID: %w", err)
+	}
+	req, err := s.store.GetLeaveRequestByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get leave request: %w", err)
+	}
+	if req == nil {
+		return nil, errors.New(i18n.T(ctx, "attendance.err.not_found"))
+	}
+	ok, seen := allowed[req.ApprovalChannelID]
+	if !seen {
+		ok = s.canApprove(ctx, req.ApprovalChannelID, userID)
+		allowed[req.ApprovalChannelID] = ok
+	}
+	if !ok {
+		return req, errors.New(i18n.T(ctx, "attendance.err.not_approver"))
+	}
+
+	switch {
+	case req.Status == model.LeaveStatusPending && decision == DecisionApprove:
+		result, err := s.ApproveLeave(ctx, requestID, userID, username)
+		if err != nil {
+			return req, err
+		}
+		s.closeApprovalPost(req.ApprovalPostID, req, result)
+	case req.Status == model.LeaveStatusPending:
+		err = s.RejectLeave(ctx, requestID, userID, username, reason)
+	case req.Status == model.LeaveStatusPendingChange && decision == DecisionApprove:
+		result, err := s.ApproveDateChange(ctx, requestID, userID, username)
+		if err != nil {
+			return req, err
+		}
+		s.closeApprovalPost(req.ChangeApprovalPostID, req, result)
+	case req.Status == model.LeaveStatusPendingChange:
+		err = s.RejectDateChange(ctx, requestID, userID, username, reason)
+	default:
+		err = errors.New(i18n.T(ctx, "attendance.err.already_processed", map[string]any{"Status": string(req.Status)}))
+	}
+	return req, err
+}
+
+// closeApprovalPost shows the decision on an approval post and removes its buttons, as the
+// approve button's response does.
+func (s *AttendanceService) closeApprovalPost(postID string, req *model.LeaveRequest, result *LeaveUpdateResult) {
+	s.mm.UpdatePost(postID, &mattermost.Post{
+		ChannelID: req.ApprovalChannelID,
+		Message:   "@" + req.Username,
+		Props: mattermost.Props{
+			MessageKey:  result.MessageKey,
+			MessageData: result.MessageData,
+			Attachments: []mattermost.Attachment{},
+		},
+	})
+}
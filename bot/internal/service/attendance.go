@@ -2,11 +2,15 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"oktel-bot/internal/i18n"
 	"oktel-bot/internal/mattermost"
 	"oktel-bot/internal/model"
 	"oktel-bot/internal/store"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,13 +20,33 @@ import (
 var vnTZ = time.FixedZone("UTC+7", 7*60*60)
 
 type AttendanceService struct {
-	store  *store.AttendanceStore
-	mm     *mattermost.Client
-	botURL string // Bot service base URL for integration callbacks
+	store       store.AttendanceStore
+	mm          *mattermost.Client
+	botURL      string // Bot service base URL for integration callbacks
+	photoCheck  PhotoCheckConfig
+	overtime    OvertimeConfig
+	workModes   WorkModeConfig
+	attachments LeaveAttachmentPolicy
+	delegations *DelegationService
+	hooks       *WebhookService
+	settings    *TeamSettingsService
+	timesheets  *TimesheetService
 }
 
-func NewAttendanceService(store *store.AttendanceStore, mm *mattermost.Client, botURL string) *AttendanceService {
-	return &AttendanceService{store: store, mm: mm, botURL: botURL}
+func NewAttendanceService(store store.AttendanceStore, mm *mattermost.Client, botURL string, photoCheck PhotoCheckConfig, overtime OvertimeConfig, workModes WorkModeConfig, attachments LeaveAttachmentPolicy, delegations *DelegationService, hooks *WebhookService, settings *TeamSettingsService, timesheets *TimesheetService) *AttendanceService {
+	return &AttendanceService{store: store, mm: mm, botURL: botURL, photoCheck: photoCheck, overtime: overtime, workModes: workModes, attachments: attachments, delegations: delegations, hooks: hooks, settings: settings, timesheets: timesheets}
+}
+
+// approvalChannelID resolves the approval channel paired with an attendance channel
+// (e.g. "attendance-dev" → "attendance-approval-dev").
+func (s *AttendanceService) approvalChannelID(channelInfo *mattermost.ChannelInfo) (string, error) {
+	suffix := strings.TrimPrefix(channelInfo.Name, model.AttendanceChannel)
+	approvalChannelName := model.AttendanceApprovalChannel + suffix
+	approvalChannelID, err := s.mm.GetChannelByName(channelInfo.TeamID, approvalChannelName)
+	if err != nil {
+		return "", fmt.Errorf("get approval channel '%s': %w", approvalChannelName, err)
+	}
+	return approvalChannelID, nil
 }
 
 // CheckInResult holds the result of a check-in operation.
@@ -32,12 +56,20 @@ type CheckInResult struct {
 }
 
 func (s *AttendanceService) CheckIn(ctx context.Context, userID, username, channelID, fileID, device string) (*CheckInResult, error) {
-	if fileID == "" {
-		return nil, fmt.Errorf(i18n.T(ctx, "attendance.err.photo_required"))
+	now := time.Now()
+
+	// Get channel info to retrieve TeamID, whose timezone decides the attendance day
+	channelInfo, err := s.mm.GetChannel(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("get channel info: %w", err)
 	}
+	loc := s.settings.Location(channelInfo.TeamID)
+	date := now.In(loc).Format(time.DateOnly)
 
-	now := time.Now()
-	date := now.In(vnTZ).Format(time.DateOnly)
+	mode := s.modeForDate(ctx, userID, date)
+	if fileID == "" && s.policy(channelInfo.TeamID, mode).RequirePhoto {
+		return nil, fmt.Errorf(i18n.T(ctx, "attendance.err.photo_required"))
+	}
 
 	record, err := s.store.GetTodayRecord(ctx, userID, date)
 	if err != nil {
@@ -45,34 +77,41 @@ func (s *AttendanceService) CheckIn(ctx context.Context, userID, username, chann
 	}
 	if record != nil {
 		return nil, fmt.Errorf(i18n.T(ctx, "attendance.msg.already_checked_in", map[string]any{
-			"Username": username, "Time": record.CheckIn.In(vnTZ).Format(time.TimeOnly),
+			"Username": username, "Time": record.CheckIn.In(loc).Format(time.TimeOnly),
 		}))
 	}
 
-	// Get channel info to retrieve TeamID
-	channelInfo, err := s.mm.GetChannel(channelID)
+	photoResult, err := s.inspectPhoto(ctx, userID, "checkin", fileID, now, nil)
 	if err != nil {
-		return nil, fmt.Errorf("get channel info: %w", err)
+		return nil, err
 	}
 
 	record = &model.AttendanceRecord{
-		UserID:        userID,
-		Username:      username,
-		TeamID:        channelInfo.TeamID,
-		ChannelID:     channelID,
-		Date:          date,
-		CheckIn:       &now,
-		CheckInDevice: device,
-		Status:        model.AttendanceStatusWorking,
+		UserID:           userID,
+		Username:         username,
+		TeamID:           channelInfo.TeamID,
+		ChannelID:        channelID,
+		Date:             date,
+		CheckIn:          &now,
+		CheckInDevice:    device,
+		Status:           model.AttendanceStatusWorking,
+		Mode:             mode,
+		CheckInPhotoHash: photoResult.Hash,
+		PhotoFlags:       photoResult.Flags,
 	}
 	if err := s.store.CreateRecord(ctx, record); err != nil {
 		return nil, fmt.Errorf("create record: %w", err)
 	}
 
 	msg := "@" + username
+	msgKey := "attendance.msg.checked_in"
 	msgData := map[string]any{
 		"Username": username,
 	}
+	if mode != model.AttendanceModeOffice {
+		msgKey = "attendance.msg.checked_in_mode"
+		msgData["Mode"] = string(mode)
+	}
 	if fileID != "" {
 		msgData["FileID"] = fileID
 		record.CheckInImageID = fileID
@@ -81,7 +120,7 @@ func (s *AttendanceService) CheckIn(ctx context.Context, userID, username, chann
 		ChannelID: channelID,
 		Message:   msg,
 		Props: mattermost.Props{
-			MessageKey:  "attendance.msg.checked_in",
+			MessageKey:  msgKey,
 			MessageData: msgData,
 		},
 	}
@@ -96,12 +135,15 @@ func (s *AttendanceService) CheckIn(ctx context.Context, userID, username, chann
 		return nil, fmt.Errorf("update record: %w", err)
 	}
 
+	s.reportPhotoFlags(ctx, record, photoResult.Flags)
+	s.hooks.Emit(ctx, model.EventAttendanceCheckedIn, map[string]any{"attendance_record": record})
+
 	return &CheckInResult{Message: fmt.Sprintf("%s checked in at %s", username, now.Format(time.TimeOnly)), PostID: post.ID}, nil
 }
 
-func (s *AttendanceService) BreakStart(ctx context.Context, userID, username, reason, device string) (string, error) {
+func (s *AttendanceService) BreakStart(ctx context.Context, userID, username, teamID, reason, device string) (string, error) {
 	now := time.Now()
-	date := now.In(vnTZ).Format(time.DateOnly)
+	date := now.In(s.settings.Location(teamID)).Format(time.DateOnly)
 
 	record, err := s.store.GetTodayRecord(ctx, userID, date)
 	if err != nil {
@@ -119,11 +161,18 @@ func (s *AttendanceService) BreakStart(ctx context.Context, userID, username, re
 		}))
 	}
 
+	def, ok := s.settings.BreakReason(record.TeamID, reason)
+	if !ok {
+		return "", errors.New(i18n.T(ctx, "attendance.err.unknown_break_reason"))
+	}
+
 	record.Breaks = append(record.Breaks, model.BreakRecord{
 		Start:       now,
 		StartDevice: device,
 		Reason:      reason,
 	})
+	exceeded := startLimits(def, record.Breaks, now)
+	record.Breaks[len(record.Breaks)-1].Exceeded = exceeded
 	record.Status = model.AttendanceStatusBreak
 	if err := s.store.UpdateRecord(ctx, record); err != nil {
 		return "", err
@@ -134,19 +183,17 @@ func (s *AttendanceService) BreakStart(ctx context.Context, userID, username, re
 		RootID:    record.PostID,
 		Message:   "@" + username,
 		Props: mattermost.Props{
-			MessageKey: "attendance.msg.break_start",
-			MessageData: map[string]any{
-				"Username": username,
-				"Reason":   reason,
-			},
+			MessageKey:  "attendance.msg.break_start",
+			MessageData: reasonData(map[string]any{"Username": username}, def),
 		},
 	})
+	s.postBreakAlerts(ctx, record, username, def, exceeded, now)
 	return fmt.Sprintf("%s started break at %s", username, now.Format(time.TimeOnly)), nil
 }
 
-func (s *AttendanceService) BreakEnd(ctx context.Context, userID, username, device string) (string, error) {
+func (s *AttendanceService) BreakEnd(ctx context.Context, userID, username, teamID, device string) (string, error) {
 	now := time.Now()
-	date := now.In(vnTZ).Format(time.DateOnly)
+	date := now.In(s.settings.Location(teamID)).Format(time.DateOnly)
 
 	record, err := s.store.GetTodayRecord(ctx, userID, date)
 	if err != nil {
@@ -164,15 +211,17 @@ func (s *AttendanceService) BreakEnd(ctx context.Context, userID, username, devi
 	last.End = &now
 	last.EndDevice = device
 	breakDuration := now.Sub(last.Start)
+	def := s.breakReason(record.TeamID, last.Reason)
+	exceeded := endLimits(def, record.Breaks, now)
+	last.Exceeded = append(last.Exceeded, exceeded...)
 	record.Status = model.AttendanceStatusWorking
 	if err := s.store.UpdateRecord(ctx, record); err != nil {
 		return "", err
 	}
 
-	displayReason := i18n.T(ctx, "attendance.break_reason."+last.Reason)
 	fallbackData := map[string]any{
 		"Username": username,
-		"Reason":   displayReason,
+		"Reason":   BreakReasonLabel(ctx, def),
 		"Duration": formatDuration(ctx, breakDuration),
 	}
 	s.mm.CreatePost(&mattermost.Post{
@@ -181,23 +230,20 @@ func (s *AttendanceService) BreakEnd(ctx context.Context, userID, username, devi
 		Message:   i18n.T(ctx, "attendance.msg.break_end", fallbackData),
 		Props: mattermost.Props{
 			MessageKey: "attendance.msg.break_end",
-			MessageData: map[string]any{
+			MessageData: reasonData(map[string]any{
 				"Username": username,
-				"Reason":   last.Reason,
 				"Duration": int(breakDuration.Round(time.Second).Seconds()),
-			},
+			}, def),
 		},
 	})
+	s.postBreakAlerts(ctx, record, username, def, exceeded, now)
 	return fmt.Sprintf("%s ended break at %s", username, now.Format(time.TimeOnly)), nil
 }
 
-func (s *AttendanceService) CheckOut(ctx context.Context, userID, username, fileID, device string) (string, error) {
-	if fileID == "" {
-		return "", fmt.Errorf(i18n.T(ctx, "attendance.err.photo_required"))
-	}
-
+func (s *AttendanceService) CheckOut(ctx context.Context, userID, username, teamID, fileID, device string) (string, error) {
 	now := time.Now()
-	date := now.In(vnTZ).Format(time.DateOnly)
+	loc := s.settings.Location(teamID)
+	date := now.In(loc).Format(time.DateOnly)
 
 	record, err := s.store.GetTodayRecord(ctx, userID, date)
 	if err != nil {
@@ -211,9 +257,17 @@ func (s *AttendanceService) CheckOut(ctx context.Context, userID, username, file
 	}
 	if record.CheckOut != nil {
 		return "", fmt.Errorf(i18n.T(ctx, "attendance.msg.already_checked_out", map[string]any{
-			"Username": username, "Time": record.CheckOut.In(vnTZ).Format(time.TimeOnly),
+			"Username": username, "Time": record.CheckOut.In(loc).Format(time.TimeOnly),
 		}))
 	}
+	if fileID == "" && s.policy(record.TeamID, record.Mode).RequirePhoto {
+		return "", fmt.Errorf(i18n.T(ctx, "attendance.err.photo_required"))
+	}
+
+	photoResult, err := s.inspectPhoto(ctx, userID, "checkout", fileID, now, record)
+	if err != nil {
+		return "", err
+	}
 
 	record.CheckOut = &now
 	record.CheckOutDevice = device
@@ -221,6 +275,8 @@ func (s *AttendanceService) CheckOut(ctx context.Context, userID, username, file
 	if fileID != "" {
 		record.CheckOutImageID = fileID
 	}
+	record.CheckOutPhotoHash = photoResult.Hash
+	record.PhotoFlags = append(record.PhotoFlags, photoResult.Flags...)
 	if err := s.store.UpdateRecord(ctx, record); err != nil {
 		return "", err
 	}
@@ -236,14 +292,13 @@ func (s *AttendanceService) CheckOut(ctx context.Context, userID, username, file
 		}
 		dur := end.Sub(b.Start)
 		totalBreak += dur
-		displayReason := i18n.T(ctx, "attendance.break_reason."+b.Reason)
+		def := s.breakReason(record.TeamID, b.Reason)
 		breakLines = append(breakLines, fmt.Sprintf("%d. %s — %s",
-			idx+1, displayReason, formatDuration(ctx, dur),
+			idx+1, BreakReasonLabel(ctx, def), formatDuration(ctx, dur),
 		))
-		breaksData = append(breaksData, map[string]any{
-			"Reason":   b.Reason,
+		breaksData = append(breaksData, reasonData(map[string]any{
 			"Duration": int(dur.Round(time.Second).Seconds()),
-		})
+		}, def))
 	}
 
 	totalTime := now.Sub(*record.CheckIn)
@@ -263,7 +318,7 @@ func (s *AttendanceService) CheckOut(ctx context.Context, userID, username, file
 		"BreakCount":     len(record.Breaks),
 		"BreakList":      breakList,
 	}
-	s.mm.CreatePost(&mattermost.Post{
+	checkoutPost, err := s.mm.CreatePost(&mattermost.Post{
 		ChannelID: record.ChannelID,
 		RootID:    record.PostID,
 		Message:   i18n.T(ctx, "attendance.msg.checked_out", fallbackData),
@@ -280,85 +335,132 @@ func (s *AttendanceService) CheckOut(ctx context.Context, userID, username, file
 			},
 		},
 	})
+	if err != nil {
+		log.Printf("ERROR checkout post: %v", err)
+	} else if fileID != "" {
+		// Remembered so the retention job can remove the photo from the post
+		record.CheckOutPostID = checkoutPost.ID
+		if err := s.store.UpdateRecord(ctx, record); err != nil {
+			log.Printf("ERROR update record: %v", err)
+		}
+	}
+
+	s.reportPhotoFlags(ctx, record, photoResult.Flags)
+	s.reconcileOvertimeForRecord(ctx, record)
+	s.hooks.Emit(ctx, model.EventAttendanceCheckedOut, map[string]any{"attendance_record": record})
+
 	return fmt.Sprintf("%s checked out at %s", username, now.Format(time.TimeOnly)), nil
 }
 
-func (s *AttendanceService) CreateLeaveRequest(ctx context.Context, userID, username, channelID string, leaveType model.LeaveType, dates []string, reason, timeStr, approver string) error {
+// CreateLeaveRequest submits a leave request. With useCompTime, a day-off request is paid
+// from the user's comp time balance, one workday per date.
+func (s *AttendanceService) CreateLeaveRequest(ctx context.Context, userID, username, channelID string, leaveType model.LeaveType, dates []string, reason, timeStr, approver string, fileIDs []string, useCompTime bool) error {
+	attachments, err := s.resolveAttachments(ctx, userID, fileIDs)
+	if err != nil {
+		return err
+	}
+	req := &model.LeaveRequest{
+		UserID:       userID,
+		Username:     username,
+		ChannelID:    channelID,
+		Type:         leaveType,
+		Dates:        dates,
+		Reason:       reason,
+		ExpectedTime: timeStr,
+		Attachments:  attachments,
+	}
+	if useCompTime && leaveType == model.LeaveTypeOff {
+		req.CompTimeDayMinutes = int(math.Round(s.overtime.WorkdayHours * 60))
+	}
+	return s.submitLeaveRequest(ctx, req, approver)
+}
+
+// CompTimeBalance returns a user's comp time balance in minutes.
+func (s *AttendanceService) CompTimeBalance(ctx context.Context, userID string) (int, error) {
+	balance, err := s.store.GetCompTimeBalance(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("get comp time balance: %w", err)
+	}
+	return balance, nil
+}
+
+// checkCompTime rejects a request paid from comp time when the user's balance does not
+// cover it. A stored request already counts against the balance, so it only needs the
+// balance not to have gone negative since it was submitted.
+func (s *AttendanceService) checkCompTime(ctx context.Context, req *model.LeaveRequest) error {
+	if req.CompTimeDayMinutes == 0 {
+		return nil
+	}
+	balance, err := s.CompTimeBalance(ctx, req.UserID)
+	if err != nil {
+		return err
+	}
+	needed := 0
+	if req.ID.IsZero() {
+		needed = req.CompTimeDayMinutes * len(req.Dates)
+	}
+	if balance < needed {
+		return errors.New(i18n.T(ctx, "attendance.err.comp_time_insufficient", map[string]any{
+			"Balance": formatDuration(ctx, time.Duration(max(balance, 0))*time.Minute),
+			"Needed":  formatDuration(ctx, time.Duration(req.CompTimeDayMinutes*len(req.Dates))*time.Minute),
+		}))
+	}
+	return nil
+}
+
+// submitLeaveRequest validates a new request, stores it as pending and posts it to the
+// main channel and, with approve/reject buttons, to the approval channel.
+func (s *AttendanceService) submitLeaveRequest(ctx context.Context, req *model.LeaveRequest, approver string) error {
 	// Lookup username if not provided (dialog submissions may omit it)
-	if username == "" {
-		user, err := s.mm.GetUser(userID)
+	if req.Username == "" {
+		user, err := s.mm.GetUser(req.UserID)
 		if err != nil {
 			return fmt.Errorf("get user info: %w", err)
 		}
-		username = user.Username
+		req.Username = user.Username
 	}
 
-	if err := validateDateList(ctx, dates); err != nil {
+	if err := validateDateList(ctx, req.Dates); err != nil {
 		return fmt.Errorf("validate dates: %w", err)
 	}
-
-	// Check for overlapping leave requests (pending or approved) on the same dates
-	existing, err := s.store.FindLeaveRequestsByUserAndDates(ctx, userID, dates)
-	if err != nil {
-		return fmt.Errorf("check existing leaves: %w", err)
+	if err := s.checkOverlap(ctx, req); err != nil {
+		return err
 	}
-	for _, e := range existing {
-		if e.Type == leaveType && (e.Status == model.LeaveStatusPending || e.Status == model.LeaveStatusApproved || e.Status == model.LeaveStatusPendingChange) {
-			checkDates := e.Dates
-			if e.Status == model.LeaveStatusPendingChange && e.NewDate != "" {
-				checkDates = append(append([]string{}, checkDates...), e.NewDate)
-			}
-			overlap := findOverlap(dates, checkDates)
-			if len(overlap) > 0 {
-				displayOverlap := make([]string, len(overlap))
-				for i, d := range overlap {
-					displayOverlap[i] = model.FormatDateDisplay(d)
-				}
-				return fmt.Errorf(i18n.T(ctx, "attendance.err.duplicate_leave", map[string]any{"Dates": strings.Join(displayOverlap, ", ")}))
-			}
-		}
+	if err := s.checkAttachments(ctx, req); err != nil {
+		return err
+	}
+	if err := s.checkCompTime(ctx, req); err != nil {
+		return err
 	}
 
 	// Resolve approval channel before creating any posts
-	channelInfo, err := s.mm.GetChannel(channelID)
+	channelInfo, err := s.mm.GetChannel(req.ChannelID)
 	if err != nil {
 		return fmt.Errorf("get channel info: %w", err)
 	}
 
-	// Extract suffix from channel name (e.g. "attendance-dev" → suffix "-dev")
-	suffix := strings.TrimPrefix(channelInfo.Name, model.AttendanceChannel)
-	approvalChannelName := model.AttendanceApprovalChannel + suffix
-	approvalChannelID, err := s.mm.GetChannelByName(channelInfo.TeamID, approvalChannelName)
+	approvalChannelID, err := s.approvalChannelID(channelInfo)
 	if err != nil {
-		return fmt.Errorf("get approval channel '%s': %w", approvalChannelName, err)
+		return err
 	}
 
 	// Create DB record first to get the ID
-	req := &model.LeaveRequest{
-		UserID:            userID,
-		Username:          username,
-		TeamID:            channelInfo.TeamID,
-		ChannelID:         channelID,
-		ApprovalChannelID: approvalChannelID,
-		Type:              leaveType,
-		Dates:             dates,
-		Reason:            reason,
-		ExpectedTime:      timeStr,
-		Status:            model.LeaveStatusPending,
-	}
+	req.TeamID = channelInfo.TeamID
+	req.ApprovalChannelID = approvalChannelID
+	req.Status = model.LeaveStatusPending
 	if err := s.store.CreateLeaveRequest(ctx, req); err != nil {
 		return fmt.Errorf("create leave request: %w", err)
 	}
 
 	idHex := req.ID.Hex()
 
-	msgKey := leaveMessageKey(leaveType)
-	msgData := leaveMessageData(username, leaveType, dates, reason, timeStr, string(model.LeaveStatusPending))
+	msgKey := leaveMessageKey(req.Type)
+	msgData := leaveMessageData(req)
 
 	// Post info message to main channel (no buttons)
 	infoPost, err := s.mm.CreatePost(&mattermost.Post{
-		ChannelID: channelID,
-		Message:   "@" + username,
+		ChannelID: req.ChannelID,
+		Message:   "@" + req.Username,
 		Props: mattermost.Props{
 			MessageKey:  msgKey,
 			MessageData: msgData,
@@ -369,10 +471,7 @@ func (s *AttendanceService) CreateLeaveRequest(ctx context.Context, userID, user
 	}
 
 	// Post approval message to approval channel (with buttons)
-	mention := "@all"
-	if approver != "" {
-		mention = "@" + approver
-	}
+	mention := s.delegations.ApprovalMention(ctx, model.DelegationScopeAttendance, approvalChannelID, approver)
 	msgData["Mention"] = mention
 	approvalPost, err := s.mm.CreatePost(&mattermost.Post{
 		ChannelID: approvalChannelID,
@@ -413,7 +512,11 @@ func (s *AttendanceService) CreateLeaveRequest(ctx context.Context, userID, user
 	// Update record with post IDs
 	req.PostID = infoPost.ID
 	req.ApprovalPostID = approvalPost.ID
-	return s.store.UpdateLeaveRequest(ctx, req)
+	if err := s.store.UpdateLeaveRequest(ctx, req); err != nil {
+		return err
+	}
+	s.hooks.Emit(ctx, model.EventLeaveRequested, map[string]any{"leave_request": req})
+	return nil
 }
 
 func (s *AttendanceService) ApproveLeave(ctx context.Context, requestID, approverID, approverUsername string) (*LeaveUpdateResult, error) {
@@ -432,18 +535,25 @@ func (s *AttendanceService) ApproveLeave(ctx context.Context, requestID, approve
 	if req.Status != model.LeaveStatusPending {
 		return nil, fmt.Errorf(i18n.T(ctx, "attendance.err.already_processed", map[string]any{"Status": string(req.Status)}))
 	}
+	if err := s.timesheets.CheckEditable(ctx, req.TeamID, req.Dates); err != nil {
+		return nil, err
+	}
+	if err := s.checkCompTime(ctx, req); err != nil {
+		return nil, err
+	}
 	now := time.Now()
 	req.Status = model.LeaveStatusApproved
-	req.ApproverID = approverID
-	req.ApproverUsername = approverUsername
+	s.recordApprover(ctx, req, approverID, approverUsername)
 	req.ApprovedAt = &now
 
 	if err := s.store.UpdateLeaveRequest(ctx, req); err != nil {
 		return nil, fmt.Errorf("update leave request: %w", err)
 	}
+	s.delegations.AutoDelegate(ctx, req)
+	s.hooks.Emit(ctx, model.EventLeaveApproved, map[string]any{"leave_request": req})
 
 	msgKey := leaveMessageKey(req.Type)
-	msgData := leaveMessageData(req.Username, req.Type, req.Dates, req.Reason, req.ExpectedTime, string(req.Status))
+	msgData := leaveMessageData(req)
 
 	// Update info post in main channel (status only)
 	s.mm.UpdatePost(req.PostID, &mattermost.Post{
@@ -456,19 +566,27 @@ func (s *AttendanceService) ApproveLeave(ctx context.Context, requestID, approve
 	})
 
 	// Reply in thread to notify requester
+	replyKey, replyData := approvedReply("attendance.msg.approved", req.Username, approverUsername, req.OnBehalfOfUsername)
 	s.mm.CreatePost(&mattermost.Post{
 		ChannelID: req.ChannelID,
 		RootID:    req.PostID,
 		Message:   "@" + req.Username,
 		Props: mattermost.Props{
-			MessageKey: "attendance.msg.approved",
-			MessageData: map[string]any{
-				"Username": req.Username,
-				"Approver": approverUsername,
-			},
+			MessageKey:  replyKey,
+			MessageData: replyData,
 		},
 	})
 
+	// Overtime approved after the day was already worked: reconcile right away
+	if req.Type == model.LeaveTypeOvertime {
+		record, err := s.store.GetTodayRecord(ctx, req.UserID, req.Dates[0])
+		if err != nil {
+			log.Printf("overtime: get record for %s on %s: %v", req.Username, req.Dates[0], err)
+		} else if record != nil && record.Status == model.AttendanceStatusCompleted {
+			s.reconcileOvertime(ctx, req, record)
+		}
+	}
+
 	return &LeaveUpdateResult{MessageKey: msgKey, MessageData: msgData}, nil
 }
 
@@ -490,17 +608,17 @@ func (s *AttendanceService) RejectLeave(ctx context.Context, requestID, rejecter
 	}
 	now := time.Now()
 	req.Status = model.LeaveStatusRejected
-	req.ApproverID = rejecterID
-	req.ApproverUsername = rejecterUsername
+	s.recordApprover(ctx, req, rejecterID, rejecterUsername)
 	req.ApprovedAt = &now
 	req.RejectReason = reason
 
 	if err := s.store.UpdateLeaveRequest(ctx, req); err != nil {
 		return fmt.Errorf("update leave request: %w", err)
 	}
+	s.hooks.Emit(ctx, model.EventLeaveRejected, map[string]any{"leave_request": req})
 
 	msgKey := leaveMessageKey(req.Type)
-	msgData := leaveMessageData(req.Username, req.Type, req.Dates, req.Reason, req.ExpectedTime, string(req.Status))
+	msgData := leaveMessageData(req)
 
 	// Update info post in main channel (status only)
 	s.mm.UpdatePost(req.PostID, &mattermost.Post{
@@ -605,7 +723,7 @@ func (s *AttendanceService) RequestDateChange(ctx context.Context, requestID str
 		if e.ID == req.ID {
 			continue
 		}
-		if e.Status == model.LeaveStatusPending || e.Status == model.LeaveStatusApproved || e.Status == model.LeaveStatusPendingChange {
+		if e.Status == model.LeaveStatusPending || e.Status == model.LeaveStatusApproved || e.Status == model.LeaveStatusPendingChange || e.Status == model.LeaveStatusPendingCancel {
 			checkDates := e.Dates
 			if e.Status == model.LeaveStatusPendingChange && e.NewDate != "" {
 				checkDates = append(append([]string{}, checkDates...), e.NewDate)
@@ -631,7 +749,7 @@ func (s *AttendanceService) RequestDateChange(ctx context.Context, requestID str
 		}
 
 		msgKey := leaveMessageKey(req.Type)
-		msgData := leaveMessageData(req.Username, req.Type, req.Dates, req.Reason, req.ExpectedTime, string(req.Status))
+		msgData := leaveMessageData(req)
 
 		// Update existing info post in main channel
 		s.mm.UpdatePost(req.PostID, &mattermost.Post{
@@ -644,10 +762,7 @@ func (s *AttendanceService) RequestDateChange(ctx context.Context, requestID str
 		})
 
 		// Update existing approval post (keep buttons)
-		mention := "@all"
-		if approver != "" {
-			mention = "@" + approver
-		}
+		mention := s.delegations.ApprovalMention(ctx, model.DelegationScopeAttendance, req.ApprovalChannelID, approver)
 		msgData["Mention"] = mention
 		s.mm.UpdatePost(req.ApprovalPostID, &mattermost.Post{
 			ChannelID: req.ApprovalChannelID,
@@ -726,10 +841,7 @@ func (s *AttendanceService) RequestDateChange(ctx context.Context, requestID str
 	}
 
 	// Create NEW approval post in approval channel (with buttons)
-	changeMention := "@all"
-	if approver != "" {
-		changeMention = "@" + approver
-	}
+	changeMention := s.delegations.ApprovalMention(ctx, model.DelegationScopeAttendance, req.ApprovalChannelID, approver)
 	changeMsgData["Mention"] = changeMention
 	approvalPost, err := s.mm.CreatePost(&mattermost.Post{
 		ChannelID: req.ApprovalChannelID,
@@ -784,6 +896,9 @@ func (s *AttendanceService) ApproveDateChange(ctx context.Context, requestID, ap
 	if req.Status != model.LeaveStatusPendingChange {
 		return nil, fmt.Errorf(i18n.T(ctx, "attendance.err.not_pending_change"))
 	}
+	if err := s.timesheets.CheckEditable(ctx, req.TeamID, []string{req.OldDate, req.NewDate}); err != nil {
+		return nil, err
+	}
 
 	now := time.Now()
 	// Save change info before clearing
@@ -799,8 +914,7 @@ func (s *AttendanceService) ApproveDateChange(ctx context.Context, requestID, ap
 		}
 	}
 	req.Status = model.LeaveStatusApproved
-	req.ApproverID = approverID
-	req.ApproverUsername = approverUsername
+	s.recordApprover(ctx, req, approverID, approverUsername)
 	req.ApprovedAt = &now
 	req.OldDate = ""
 	req.NewDate = ""
@@ -810,6 +924,11 @@ func (s *AttendanceService) ApproveDateChange(ctx context.Context, requestID, ap
 	if err := s.store.UpdateLeaveRequest(ctx, req); err != nil {
 		return nil, fmt.Errorf("update leave request: %w", err)
 	}
+	s.hooks.Emit(ctx, model.EventLeaveChanged, map[string]any{
+		"leave_request": req,
+		"old_date":      oldDate,
+		"new_date":      newDate,
+	})
 
 	// Keep the change message format, just update status
 	changeMsgKey := "leave.msg.change_leave"
@@ -826,16 +945,14 @@ func (s *AttendanceService) ApproveDateChange(ctx context.Context, requestID, ap
 	})
 
 	// Thread reply on change post to notify requester
+	replyKey, replyData := approvedReply("attendance.msg.change_approved", req.Username, approverUsername, req.OnBehalfOfUsername)
 	s.mm.CreatePost(&mattermost.Post{
 		ChannelID: req.ChannelID,
 		RootID:    req.ChangePostID,
 		Message:   "@" + req.Username,
 		Props: mattermost.Props{
-			MessageKey: "attendance.msg.change_approved",
-			MessageData: map[string]any{
-				"Username": req.Username,
-				"Approver": approverUsername,
-			},
+			MessageKey:  replyKey,
+			MessageData: replyData,
 		},
 	})
 
@@ -930,63 +1047,99 @@ func leaveChangeMessageData(username, oldDate, newDate, reason, changeReason, st
 	}
 }
 
-// AttendanceReport is the top-level response for the report API.
+// AttendanceReport is the top-level response for the report API. BreakReasons defines the
+// keys of the users' break stats: the team's reasons (or the defaults), then any other
+// reason found in the range.
 type AttendanceReport struct {
-	From  string       `json:"from"`
-	To    string       `json:"to"`
-	Users []UserReport `json:"users"`
+	From         string              `json:"from"`
+	To           string              `json:"to"`
+	BreakReasons []model.BreakReason `json:"break_reasons"`
+	Users        []UserReport        `json:"users"`
+}
+
+// BreakStats sums the breaks taken for one reason.
+type BreakStats struct {
+	Count    int `json:"count"`
+	Minutes  int `json:"minutes"`            // finished breaks only
+	Exceeded int `json:"exceeded,omitempty"` // breaks over one of the reason's limits
+}
+
+func (b *BreakStats) add(rec model.BreakRecord) {
+	b.Count++
+	if rec.End != nil {
+		b.Minutes += int(rec.End.Sub(rec.Start).Minutes())
+	}
+	if len(rec.Exceeded) > 0 {
+		b.Exceeded++
+	}
 }
 
 // UserReport contains per-user attendance statistics.
 type UserReport struct {
-	UserID          string            `json:"user_id"`
-	Username        string            `json:"username"`
-	DaysWorked      int               `json:"days_worked"`
-	DaysLeave       int               `json:"days_leave"`
-	LateArrivals    int               `json:"late_arrivals"`
-	EarlyDepartures int               `json:"early_departures"`
-	BreakRest       int               `json:"break_rest"`
-	BreakEat        int               `json:"break_eat"`
-	BreakRestroomS  int               `json:"break_restroom_s"`
-	BreakRestroomL  int               `json:"break_restroom_l"`
-	BreakSmoke      int               `json:"break_smoke"`
-	Attendance      []AttendanceEntry `json:"attendance"`
-	LeaveRequests   []LeaveEntry      `json:"leave_requests"`
+	UserID          string                `json:"user_id"`
+	Username        string                `json:"username"`
+	DaysWorked      int                   `json:"days_worked"`
+	DaysLeave       int                   `json:"days_leave"`
+	LateArrivals    int                   `json:"late_arrivals"`
+	EarlyDepartures int                   `json:"early_departures"`
+	BreakStats      map[string]BreakStats `json:"break_stats"` // by break reason ID
+	PhotoFlags      int                   `json:"photo_flags"`
+	DaysByMode      map[string]int        `json:"days_by_mode"` // attendance days per mode: office, remote, business_trip
+	Overtime        OvertimeTotals        `json:"overtime"`
+	Attendance      []AttendanceEntry     `json:"attendance"`
+	LeaveRequests   []LeaveEntry          `json:"leave_requests"`
 }
 
 // BreakLog is a single break record with start/end times.
 type BreakLog struct {
-	Reason      string `json:"reason"`
-	Start       int64  `json:"start"`
-	StartDevice string `json:"start_device,omitempty"`
-	End         int64  `json:"end,omitempty"`
-	EndDevice   string `json:"end_device,omitempty"`
+	Reason      string   `json:"reason"`
+	Start       int64    `json:"start"`
+	StartDevice string   `json:"start_device,omitempty"`
+	End         int64    `json:"end,omitempty"`
+	EndDevice   string   `json:"end_device,omitempty"`
+	Exceeded    []string `json:"exceeded,omitempty"` // break limits this break went over
 }
 
 type AttendanceEntry struct {
-	Date            string     `json:"date"`
-	CheckIn         int64      `json:"check_in,omitempty"`
-	CheckInImageID  string     `json:"checkin_image_id,omitempty"`
-	CheckInDevice   string     `json:"checkin_device,omitempty"`
-	CheckOut        int64      `json:"check_out,omitempty"`
-	CheckOutDevice  string     `json:"checkout_device,omitempty"`
-	CheckOutImageID string     `json:"checkout_image_id,omitempty"`
-	Status          string     `json:"status"`
-	TotalBreaks     int        `json:"total_breaks"`
-	BreakRest       int        `json:"break_rest"`
-	BreakEat        int        `json:"break_eat"`
-	BreakRestroomS  int        `json:"break_restroom_s"`
-	BreakRestroomL  int        `json:"break_restroom_l"`
-	BreakSmoke      int        `json:"break_smoke"`
-	Breaks          []BreakLog `json:"breaks,omitempty"`
+	Date            string                `json:"date"`
+	CheckIn         int64                 `json:"check_in,omitempty"`
+	CheckInImageID  string                `json:"checkin_image_id,omitempty"`
+	CheckInDevice   string                `json:"checkin_device,omitempty"`
+	CheckOut        int64                 `json:"check_out,omitempty"`
+	CheckOutDevice  string                `json:"checkout_device,omitempty"`
+	CheckOutImageID string                `json:"checkout_image_id,omitempty"`
+	Status          string                `json:"status"`
+	Mode            string                `json:"mode"`
+	TotalBreaks     int                   `json:"total_breaks"`
+	BreakStats      map[string]BreakStats `json:"break_stats"` // by break reason ID
+	Breaks          []BreakLog            `json:"breaks,omitempty"`
+	PhotoFlags      []PhotoFlagLog        `json:"photo_flags,omitempty"`
+}
+
+// PhotoFlagLog is a suspicious check-in/check-out photo as shown in the report.
+type PhotoFlagLog struct {
+	Type        string `json:"type"`
+	Action      string `json:"action"`
+	FileID      string `json:"file_id"`
+	MatchedDate string `json:"matched_date,omitempty"`
+	Distance    int    `json:"distance,omitempty"`
+	TakenAt     int64  `json:"taken_at,omitempty"`
 }
 
 type LeaveEntry struct {
-	Type         string   `json:"type"`
-	Dates        []string `json:"dates"`
-	Reason       string   `json:"reason"`
-	ExpectedTime string   `json:"expected_time,omitempty"`
-	Status       string   `json:"status"`
+	Type                    string   `json:"type"`
+	Dates                   []string `json:"dates"`
+	Reason                  string   `json:"reason"`
+	ExpectedTime            string   `json:"expected_time,omitempty"`
+	Status                  string   `json:"status"`
+	CancelledDates          []string `json:"cancelled_dates,omitempty"`
+	PlannedHours            float64  `json:"planned_hours,omitempty"`
+	CompensatoryLeave       bool     `json:"compensatory_leave,omitempty"`
+	ActualOvertimeMinutes   int      `json:"actual_overtime_minutes,omitempty"`
+	CreditedOvertimeMinutes int      `json:"credited_overtime_minutes,omitempty"`
+	OvertimeDayType         string   `json:"overtime_day_type,omitempty"`
+
+	Attachments []model.LeaveAttachment `json:"attachments,omitempty"`
 }
 
 // GetReport returns attendance statistics for a date range, optionally filtered by user, team and/or channel.
@@ -1012,11 +1165,12 @@ func (s *AttendanceService) GetReport(ctx context.Context, from, to, userID, tea
 	}
 
 	// Group by user
+	reasons := map[string]bool{} // break reasons seen
 	userMap := make(map[string]*UserReport)
 	getUser := func(uid, uname string) *UserReport {
 		u, ok := userMap[uid]
 		if !ok {
-			u = &UserReport{UserID: uid, Username: uname}
+			u = &UserReport{UserID: uid, Username: uname, DaysByMode: map[string]int{}, BreakStats: map[string]BreakStats{}}
 			userMap[uid] = u
 		}
 		return u
@@ -1027,6 +1181,9 @@ func (s *AttendanceService) GetReport(ctx context.Context, from, to, userID, tea
 		entry := AttendanceEntry{
 			Date:   rec.Date,
 			Status: string(rec.Status),
+			Mode:   string(recordMode(rec)),
+
+			BreakStats: map[string]BreakStats{},
 		}
 		if rec.CheckIn != nil {
 			entry.CheckIn = rec.CheckIn.Unix()
@@ -1045,47 +1202,59 @@ func (s *AttendanceService) GetReport(ctx context.Context, from, to, userID, tea
 				Reason:      b.Reason,
 				Start:       b.Start.Unix(),
 				StartDevice: b.StartDevice,
+				Exceeded:    b.Exceeded,
 			}
 			if b.End != nil {
 				log.End = b.End.Unix()
 				log.EndDevice = b.EndDevice
 			}
 			entry.Breaks = append(entry.Breaks, log)
-			switch b.Reason {
-			case "nghi_ngoi":
-				u.BreakRest++
-				entry.BreakRest++
-			case "di_an":
-				u.BreakEat++
-				entry.BreakEat++
-			case "tieu_tien":
-				u.BreakRestroomS++
-				entry.BreakRestroomS++
-			case "dai_tien":
-				u.BreakRestroomL++
-				entry.BreakRestroomL++
-			case "hut_thuoc":
-				u.BreakSmoke++
-				entry.BreakSmoke++
+			for _, stats := range []map[string]BreakStats{u.BreakStats, entry.BreakStats} {
+				st := stats[b.Reason]
+				st.add(b)
+				stats[b.Reason] = st
 			}
+			reasons[b.Reason] = true
+		}
+		for _, f := range rec.PhotoFlags {
+			flag := PhotoFlagLog{
+				Type:        string(f.Type),
+				Action:      f.Action,
+				FileID:      f.FileID,
+				MatchedDate: f.MatchedDate,
+				Distance:    f.Distance,
+			}
+			if f.TakenAt != nil {
+				flag.TakenAt = f.TakenAt.Unix()
+			}
+			entry.PhotoFlags = append(entry.PhotoFlags, flag)
+			u.PhotoFlags++
 		}
 		u.Attendance = append(u.Attendance, entry)
 		u.DaysWorked++
+		u.DaysByMode[entry.Mode]++
 	}
 
 	for _, req := range leaveReqs {
 		u := getUser(req.UserID, req.Username)
 		entry := LeaveEntry{
-			Type:         string(req.Type),
-			Dates:        req.Dates,
-			Reason:       req.Reason,
-			ExpectedTime: req.ExpectedTime,
-			Status:       string(req.Status),
+			Type:                    string(req.Type),
+			Dates:                   req.Dates,
+			Reason:                  req.Reason,
+			ExpectedTime:            req.ExpectedTime,
+			Status:                  string(req.Status),
+			CancelledDates:          req.CancelledDates,
+			PlannedHours:            req.PlannedHours,
+			CompensatoryLeave:       req.CompensatoryLeave,
+			ActualOvertimeMinutes:   req.ActualOvertimeMinutes,
+			CreditedOvertimeMinutes: req.CreditedOvertimeMinutes,
+			OvertimeDayType:         req.OvertimeDayType,
+			Attachments:             req.Attachments,
 		}
 		u.LeaveRequests = append(u.LeaveRequests, entry)
 
 		// Count only approved or pending
-		if req.Status == model.LeaveStatusRejected {
+		if req.Status == model.LeaveStatusRejected || req.Status == model.LeaveStatusCancelled {
 			continue
 		}
 		switch req.Type {
@@ -1093,6 +1262,13 @@ func (s *AttendanceService) GetReport(ctx context.Context, from, to, userID, tea
 			u.LateArrivals++
 		case model.LeaveTypeEarlyDeparture:
 			u.EarlyDepartures++
+		case model.LeaveTypeOvertime:
+			// Only reconciled overtime counts, and only if the day falls within the range
+			if req.ReconciledAt != nil && req.Dates[0] >= from && req.Dates[0] <= to {
+				u.Overtime.add(s.overtime, req)
+			}
+		case model.LeaveTypeRemote, model.LeaveTypeBusinessTrip:
+			// Working days, counted from the attendance records above
 		default:
 			// Count leave days that fall within the range
 			for _, d := range req.Dates {
@@ -1109,7 +1285,7 @@ func (s *AttendanceService) GetReport(ctx context.Context, from, to, userID, tea
 		users = append(users, *u)
 	}
 
-	return &AttendanceReport{From: from, To: to, Users: users}, nil
+	return &AttendanceReport{From: from, To: to, BreakReasons: s.reportBreakReasons(teamID, reasons), Users: users}, nil
 }
 
 // AttendanceStats contains aggregate counts for a date range.
@@ -1123,7 +1299,10 @@ type AttendanceStats struct {
 	TotalOnLeave     int    `json:"total_on_leave"`
 	TotalLateArrival int    `json:"total_late_arrivals"`
 	TotalEarlyDepart int    `json:"total_early_departures"`
+	TotalOvertimeMin int    `json:"total_overtime_minutes"`
 	PendingRequests  int    `json:"pending_requests"`
+
+	CheckedInByMode map[string]int `json:"checked_in_by_mode"` // office, remote, business_trip
 }
 
 // GetAttendanceStats returns aggregate attendance counts for a date range, optionally filtered by channel.
@@ -1148,10 +1327,11 @@ func (s *AttendanceService) GetStats(ctx context.Context, from, to, channelID st
 		return nil, fmt.Errorf("get leave requests: %w", err)
 	}
 
-	stats := &AttendanceStats{From: from, To: to}
+	stats := &AttendanceStats{From: from, To: to, CheckedInByMode: map[string]int{}}
 
 	for _, rec := range records {
 		stats.TotalCheckedIn++
+		stats.CheckedInByMode[string(recordMode(rec))]++
 		switch rec.Status {
 		case model.AttendanceStatusWorking:
 			stats.TotalWorking++
@@ -1164,7 +1344,7 @@ func (s *AttendanceService) GetStats(ctx context.Context, from, to, channelID st
 	}
 
 	for _, req := range leaves {
-		if req.Status == model.LeaveStatusRejected {
+		if req.Status == model.LeaveStatusRejected || req.Status == model.LeaveStatusCancelled {
 			continue
 		}
 		if req.Status == model.LeaveStatusPending {
@@ -1175,6 +1355,12 @@ func (s *AttendanceService) GetStats(ctx context.Context, from, to, channelID st
 			stats.TotalLateArrival++
 		case model.LeaveTypeEarlyDeparture:
 			stats.TotalEarlyDepart++
+		case model.LeaveTypeOvertime:
+			if req.ReconciledAt != nil && req.Dates[0] >= from && req.Dates[0] <= to {
+				stats.TotalOvertimeMin += req.CreditedOvertimeMinutes
+			}
+		case model.LeaveTypeRemote, model.LeaveTypeBusinessTrip:
+			// Working days, counted from the attendance records above
 		default:
 			// Count only leave days within the range
 			for _, d := range req.Dates {
@@ -1189,14 +1375,11 @@ func (s *AttendanceService) GetStats(ctx context.Context, from, to, channelID st
 }
 
 func validateDateList(ctx context.Context, dates []string) error {
-	if len(dates) == 0 {
-		return fmt.Errorf(i18n.T(ctx, "attendance.err.date_required"))
+	if err := validateDateFormat(ctx, dates); err != nil {
+		return err
 	}
 	today := time.Now().In(vnTZ).Format(time.DateOnly)
 	for _, d := range dates {
-		if _, err := time.Parse(time.DateOnly, d); err != nil {
-			return fmt.Errorf(i18n.T(ctx, "attendance.err.invalid_date", map[string]any{"Date": d}))
-		}
 		if d < today {
 			return fmt.Errorf(i18n.T(ctx, "attendance.err.past_date", map[string]any{"Date": d}))
 		}
@@ -1204,6 +1387,45 @@ func validateDateList(ctx context.Context, dates []string) error {
 	return nil
 }
 
+// validateDateFormat checks that dates is non-empty and every entry is YYYY-MM-DD.
+func validateDateFormat(ctx context.Context, dates []string) error {
+	if len(dates) == 0 {
+		return errors.New(i18n.T(ctx, "attendance.err.date_required"))
+	}
+	for _, d := range dates {
+		if _, err := time.Parse(time.DateOnly, d); err != nil {
+			return errors.New(i18n.T(ctx, "attendance.err.invalid_date", map[string]any{"Date": d}))
+		}
+	}
+	return nil
+}
+
+// checkOverlap rejects a request whose dates overlap an active request (pending or
+// approved) of the same type from the same user.
+func (s *AttendanceService) checkOverlap(ctx context.Context, req *model.LeaveRequest) error {
+	existing, err := s.store.FindLeaveRequestsByUserAndDates(ctx, req.UserID, req.Dates)
+	if err != nil {
+		return fmt.Errorf("check existing leaves: %w", err)
+	}
+	for _, e := range existing {
+		if e.Type == req.Type && (e.Status == model.LeaveStatusPending || e.Status == model.LeaveStatusApproved || e.Status == model.LeaveStatusPendingChange || e.Status == model.LeaveStatusPendingCancel) {
+			checkDates := e.Dates
+			if e.Status == model.LeaveStatusPendingChange && e.NewDate != "" {
+				checkDates = append(append([]string{}, checkDates...), e.NewDate)
+			}
+			overlap := findOverlap(req.Dates, checkDates)
+			if len(overlap) > 0 {
+				displayOverlap := make([]string, len(overlap))
+				for i, d := range overlap {
+					displayOverlap[i] = model.FormatDateDisplay(d)
+				}
+				return invalidInput(i18n.T(ctx, "attendance.err.duplicate_leave", map[string]any{"Dates": strings.Join(displayOverlap, ", ")}))
+			}
+		}
+	}
+	return nil
+}
+
 func findOverlap(a, b []string) []string {
 	set := make(map[string]struct{}, len(b))
 	for _, v := range b {
@@ -1230,27 +1452,41 @@ func leaveMessageKey(leaveType model.LeaveType) string {
 		return "leave.msg.late"
 	case model.LeaveTypeEarlyDeparture:
 		return "leave.msg.early"
+	case model.LeaveTypeOvertime:
+		return "leave.msg.overtime"
+	case model.LeaveTypeRemote, model.LeaveTypeBusinessTrip:
+		return "leave.msg.work_mode"
 	default:
 		return "leave.msg.leave"
 	}
 }
 
-func leaveMessageData(username string, leaveType model.LeaveType, dates []string, reason, timeStr, status string) map[string]any {
-	displayDates := make([]string, len(dates))
-	for i, d := range dates {
+func leaveMessageData(req *model.LeaveRequest) map[string]any {
+	displayDates := make([]string, len(req.Dates))
+	for i, d := range req.Dates {
 		displayDates[i] = model.FormatDateDisplay(d)
 	}
-	return map[string]any{
-		"Username":     username,
-		"LeaveType":    string(leaveType),
+	data := map[string]any{
+		"Username":     req.Username,
+		"LeaveType":    string(req.Type),
 		"Dates":        strings.Join(displayDates, ", "),
-		"Reason":       reason,
-		"ExpectedTime": timeStr,
-		"Status":       status,
+		"Reason":       req.Reason,
+		"ExpectedTime": req.ExpectedTime,
+		"Status":       string(req.Status),
+	}
+	if req.Type == model.LeaveTypeOvertime {
+		data["PlannedHours"] = req.PlannedHours
+		data["Compensatory"] = strconv.FormatBool(req.CompensatoryLeave)
+	}
+	if req.CompTimeDayMinutes > 0 {
+		data["CompTimeHours"] = float64(req.CompTimeDayMinutes*len(req.Dates)) / 60
 	}
+	if len(req.Attachments) > 0 {
+		data["Attachments"] = attachmentMessageData(req.Attachments)
+	}
+	return data
 }
 
-
 func formatDuration(ctx context.Context, d time.Duration) string {
 	d = d.Round(time.Second)
 	h := int(d.Hours())
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+)
+
+// maxBreakReasonID bounds custom break reason IDs, which end up in button contexts and
+// report columns.
+const maxBreakReasonID = 32
+
+// BreakReasons returns the break reasons offered to a team, in button order.
+func (s *TeamSettingsService) BreakReasons(teamID string) []model.BreakReason {
+	return s.Config(teamID).BreakReasons
+}
+
+// BreakReason looks up a break reason a team offers.
+func (s *TeamSettingsService) BreakReason(teamID, id string) (model.BreakReason, bool) {
+	for _, r := range s.BreakReasons(teamID) {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return model.BreakReason{}, false
+}
+
+// FindBreakReason looks up a break reason in the defaults and in every team, for labelling
+// breaks whose reason a team no longer offers. Unknown reasons come back with just the ID.
+func (s *TeamSettingsService) FindBreakReason(id string) model.BreakReason {
+	for _, r := range s.defaults.BreakReasons {
+		if r.ID == id {
+			return r
+		}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, ts := range s.settings {
+		for _, r := range ts.BreakReasons {
+			if r.ID == id {
+				return r
+			}
+		}
+	}
+	return model.BreakReason{ID: id}
+}
+
+// SaveBreakReason adds a break reason to a team, or replaces the one with the same ID. A
+// team without break reasons of its own starts from the defaults.
+func (s *TeamSettingsService) SaveBreakReason(ctx context.Context, teamID string, reason model.BreakReason, updatedBy string) error {
+	if err := validateBreakReason(ctx, &reason); err != nil {
+		return err
+	}
+	ts := s.Settings(teamID)
+	reasons := s.BreakReasons(teamID)
+	if i := slices.IndexFunc(reasons, func(r model.BreakReason) bool { return r.ID == reason.ID }); i >= 0 {
+		reasons[i] = reason
+	} else {
+		reasons = append(reasons, reason)
+	}
+	ts.BreakReasons = reasons
+	return s.Save(ctx, &ts, updatedBy)
+}
+
+// RemoveBreakReason stops offering a break reason to a team. Past breaks keep it.
+func (s *TeamSettingsService) RemoveBreakReason(ctx context.Context, teamID, id, updatedBy string) error {
+	reasons := s.BreakReasons(teamID)
+	i := slices.IndexFunc(reasons, func(r model.BreakReason) bool { return r.ID == id })
+	if i < 0 {
+		return errors.New(i18n.T(ctx, "admin.breaks.err.unknown", map[string]any{"ID": id}))
+	}
+	if len(reasons) == 1 {
+		return errors.New(i18n.T(ctx, "admin.breaks.err.last"))
+	}
+	ts := s.Settings(teamID)
+	ts.BreakReasons = slices.Delete(reasons, i, i+1)
+	return s.Save(ctx, &ts, updatedBy)
+}
+
+// ResetBreakReasons makes a team offer the default break reasons again.
+func (s *TeamSettingsService) ResetBreakReasons(ctx context.Context, teamID, updatedBy string) error {
+	ts := s.Settings(teamID)
+	ts.BreakReasons = nil
+	return s.Save(ctx, &ts, updatedBy)
+}
+
+// validateBreakReason checks a break reason before it is saved, dropping empty labels.
+// Only the built-in reasons may go without a label.
+func validateBreakReason(ctx context.Context, r *model.BreakReason) error {
+	if len(r.ID) > maxBreakReasonID || !validSetupName.MatchString(r.ID) {
+		return errors.New(i18n.T(ctx, "admin.breaks.err.id", map[string]any{"ID": r.ID}))
+	}
+	for locale, label := range r.Labels {
+		if label == "" {
+			delete(r.Labels, locale)
+			continue
+		}
+		if !i18n.Supported(locale) {
+			return errors.New(i18n.T(ctx, "admin.config.err.locale", map[string]any{"Value": locale}))
+		}
+	}
+	builtIn := slices.ContainsFunc(model.DefaultBreakReasons, func(d model.BreakReason) bool { return d.ID == r.ID })
+	if len(r.Labels) == 0 && !builtIn {
+		return errors.New(i18n.T(ctx, "admin.breaks.err.label"))
+	}
+	for _, n := range []int{r.MaxPerDay, r.MaxMinutes, r.MaxDailyMinutes} {
+		if n < 0 || n > 24*60 {
+			return errors.New(i18n.T(ctx, "admin.breaks.err.limit"))
+		}
+	}
+	return nil
+}
+
+// BreakReasonLabel returns a break reason's label in the context's locale: its own label,
+// the built-in translation, or else its ID.
+func BreakReasonLabel(ctx context.Context, r model.BreakReason) string {
+	if l := r.Label(i18n.LocaleFromContext(ctx)); l != "" {
+		return l
+	}
+	key := "attendance.break_reason." + r.ID
+	if l := i18n.T(ctx, key); l != key {
+		return l
+	}
+	return r.ID
+}
+
+// BreakReasons returns the break reasons offered to a team, in button order.
+func (s *AttendanceService) BreakReasons(teamID string) []model.BreakReason {
+	return s.settings.BreakReasons(teamID)
+}
+
+// breakReason returns the definition of a break's reason, also for reasons the team no
+// longer offers.
+func (s *AttendanceService) breakReason(teamID, id string) model.BreakReason {
+	if r, ok := s.settings.BreakReason(teamID, id); ok {
+		return r
+	}
+	return s.settings.FindBreakReason(id)
+}
+
+// reportBreakReasons returns the break reasons of a report: the team's, or the defaults
+// for a report across teams, followed by the other reasons seen, sorted by ID.
+func (s *AttendanceService) reportBreakReasons(teamID string, seen map[string]bool) []model.BreakReason {
+	reasons := s.settings.BreakReasons(teamID)
+	var other []string
+	for id := range seen {
+		if !slices.ContainsFunc(reasons, func(r model.BreakReason) bool { return r.ID == id }) {
+			other = append(other, id)
+		}
+	}
+	slices.Sort(other)
+	for _, id := range other {
+		reasons = append(reasons, s.settings.FindBreakReason(id))
+	}
+	return reasons
+}
+
+// reasonData adds a break reason to post message data: its ID, which the webapp
+// translates, and the labels of a custom reason.
+func reasonData(data map[string]any, r model.BreakReason) map[string]any {
+	data["Reason"] = r.ID
+	if len(r.Labels) > 0 {
+		data["ReasonLabels"] = r.Labels
+	}
+	return data
+}
+
+// breakUsage sums a day's breaks for one reason, up to and including breaks[upTo]. Open
+// breaks count until now.
+func breakUsage(breaks []model.BreakRecord, reason string, upTo int, now time.Time) (count int, total time.Duration) {
+	for _, b := range breaks[:upTo+1] {
+		if b.Reason != reason {
+			continue
+		}
+		count++
+		end := now
+		if b.End != nil {
+			end = *b.End
+		}
+		total += end.Sub(b.Start)
+	}
+	return count, total
+}
+
+// startLimits returns the limits the last break of a record exceeds when it starts.
+func startLimits(r model.BreakReason, breaks []model.BreakRecord, now time.Time) []string {
+	last := len(breaks) - 1
+	count, _ := breakUsage(breaks, r.ID, last, now)
+	if r.MaxPerDay > 0 && count > r.MaxPerDay {
+		return []string{model.BreakLimitPerDay}
+	}
+	return nil
+}
+
+// endLimits returns the limits the last break of a record newly exceeds when it ends. The
+// daily total is only reported by the break that goes over it.
+func endLimits(r model.BreakReason, breaks []model.BreakRecord, now time.Time) []string {
+	last := len(breaks) - 1
+	b := breaks[last]
+	var exceeded []string
+	if r.MaxMinutes > 0 && b.End.Sub(b.Start) > time.Duration(r.MaxMinutes)*time.Minute {
+		exceeded = append(exceeded, model.BreakLimitDuration)
+	}
+	if r.MaxDailyMinutes > 0 {
+		limit := time.Duration(r.MaxDailyMinutes) * time.Minute
+		_, total := breakUsage(breaks, r.ID, last, now)
+		if total > limit && total-b.End.Sub(b.Start) <= limit {
+			exceeded = append(exceeded, model.BreakLimitDailyMinutes)
+		}
+	}
+	return exceeded
+}
+
+// postBreakAlerts posts one alert per exceeded limit on the record's check-in thread.
+func (s *AttendanceService) postBreakAlerts(ctx context.Context, record *model.AttendanceRecord, username string, r model.BreakReason, exceeded []string, now time.Time) {
+	if len(exceeded) == 0 || record.PostID == "" {
+		return
+	}
+	last := len(record.Breaks) - 1
+	count, total := breakUsage(record.Breaks, r.ID, last, now)
+	b := record.Breaks[last]
+	end := now
+	if b.End != nil {
+		end = *b.End
+	}
+	for _, limit := range exceeded {
+		key := "attendance.msg.break_limit." + limit
+		data := map[string]any{"Username": username, "Count": count}
+		var dur time.Duration
+		switch limit {
+		case model.BreakLimitPerDay:
+			data["Limit"] = r.MaxPerDay
+		case model.BreakLimitDuration:
+			data["Limit"] = r.MaxMinutes
+			dur = end.Sub(b.Start)
+		case model.BreakLimitDailyMinutes:
+			data["Limit"] = r.MaxDailyMinutes
+			dur = total
+		}
+		fallback := map[string]any{"Reason": BreakReasonLabel(ctx, r), "Duration": formatDuration(ctx, dur)}
+		for k, v := range data {
+			fallback[k] = v
+		}
+		data["Duration"] = int(dur.Round(time.Second).Seconds())
+		s.mm.CreatePost(&mattermost.Post{
+			ChannelID: record.ChannelID,
+			RootID:    record.PostID,
+			Message:   i18n.T(ctx, key, fallback),
+			Props: mattermost.Props{
+				MessageKey:  key,
+				MessageData: reasonData(data, r),
+			},
+		})
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -15,13 +16,15 @@ import (
 )
 
 type BudgetService struct {
-	store  *store.BudgetStore
-	mm     *mattermost.Client
-	botURL string
+	store       store.BudgetStore
+	mm          *mattermost.Client
+	botURL      string
+	delegations *DelegationService
+	hooks       *WebhookService
 }
 
-func NewBudgetService(store *store.BudgetStore, mm *mattermost.Client, botURL string) *BudgetService {
-	return &BudgetService{store: store, mm: mm, botURL: botURL}
+func NewBudgetService(store store.BudgetStore, mm *mattermost.Client, botURL string, delegations *DelegationService, hooks *WebhookService) *BudgetService {
+	return &BudgetService{store: store, mm: mm, botURL: botURL, delegations: delegations, hooks: hooks}
 }
 
 // channelIDs holds the resolved IDs for all budget channels.
@@ -65,6 +68,12 @@ func (s *BudgetService) resolveChannels(teamID, partner, suffix, saleChannelID s
 
 // CreateRequest handles step 1: Sale creates a budget request from budget-sale channel.
 func (s *BudgetService) CreateRequest(ctx context.Context, userID, channelID, name, partner, amount, purpose, deadline string) error {
+	return s.createRequest(ctx, userID, channelID, name, partner, amount, purpose, deadline, "")
+}
+
+// createRequest creates a request from a sale channel; clonedFromID links it to the
+// request it was cloned from.
+func (s *BudgetService) createRequest(ctx context.Context, userID, channelID, name, partner, amount, purpose, deadline, clonedFromID string) error {
 	// Extract suffix and teamID from sale channel (e.g. "budget-sale-dev" → suffix "-dev")
 	channelInfo, err := s.mm.GetChannel(channelID)
 	if err != nil {
@@ -91,10 +100,13 @@ func (s *BudgetService) CreateRequest(ctx context.Context, userID, channelID, na
 		Amount:            amount,
 		Purpose:           purpose,
 		Deadline:          deadline,
+		ClonedFromID:      clonedFromID,
 	}
+	recordBudgetAction(req, model.BudgetActionCreated, userID, "", nil)
 	if err := s.store.Create(ctx, req); err != nil {
 		return fmt.Errorf("create budget request: %w", err)
 	}
+	s.hooks.Emit(ctx, model.EventBudgetCreated, map[string]any{"budget_request": req})
 
 	idHex := req.ID.Hex()
 
@@ -109,6 +121,7 @@ func (s *BudgetService) CreateRequest(ctx context.Context, userID, channelID, na
 				"Partner": partner,
 				"Amount":  amount,
 			},
+			Attachments: s.saleActions(ctx, req),
 		},
 	})
 	if err != nil {
@@ -126,18 +139,7 @@ func (s *BudgetService) CreateRequest(ctx context.Context, userID, channelID, na
 				"Partner": partner,
 				"Amount":  amount,
 			},
-			Attachments: []mattermost.Attachment{{
-				Actions: []mattermost.Action{
-					{
-						Name: i18n.T(ctx, "budget.btn.fill_content"),
-						Type: "button",
-						Integration: mattermost.Integration{
-							URL:     s.botURL + "/api/budget/partner-content-form",
-							Context: map[string]any{"request_id": idHex},
-						},
-					},
-				},
-			}},
+			Attachments: s.contentActions(ctx, idHex),
 		},
 	})
 	if err != nil {
@@ -163,17 +165,16 @@ func (s *BudgetService) SubmitContent(ctx context.Context, requestID, userID, po
 	req.PageLink = pageLink
 	req.ContentAt = &now
 	req.CurrentStep = model.BudgetStepPartnerContent
+	recordBudgetAction(req, model.BudgetActionContentSubmitted, userID, "", nil)
 
 	if err := s.store.Update(ctx, req); err != nil {
 		return err
 	}
+	s.emitStepChanged(ctx, req, model.BudgetStepSaleCreated)
 
 	idHex := req.ID.Hex()
 	infoMsg := formatBudgetStatus(ctx, req, i18n.T(ctx, "budget.status.step2"))
-	contentDetail := fmt.Sprintf("\n| %s | %s |\n| %s | %s |\n| %s | %s |",
-		i18n.T(ctx, "budget.info.post_content"), postContent,
-		i18n.T(ctx, "budget.info.post_link"), postLink,
-		i18n.T(ctx, "budget.info.page_link"), pageLink)
+	contentDetail := formatContentDetail(ctx, req)
 
 	// Update partner post (remove button, show content)
 	s.mm.UpdatePost(req.PartnerPostID, &mattermost.Post{
@@ -184,28 +185,7 @@ func (s *BudgetService) SubmitContent(ctx context.Context, requestID, userID, po
 		},
 	})
 
-	tlqcProps := mattermost.Props{
-		Attachments: []mattermost.Attachment{{
-			Actions: []mattermost.Action{
-				{
-					Name: i18n.T(ctx, "budget.btn.confirm"),
-					Type: "button",
-					Integration: mattermost.Integration{
-						URL:     s.botURL + "/api/budget/tlqc-confirm",
-						Context: map[string]any{"request_id": idHex},
-					},
-				},
-				{
-					Name: i18n.T(ctx, "budget.btn.return"),
-					Type: "button",
-					Integration: mattermost.Integration{
-						URL:     s.botURL + "/api/budget/tlqc-return-form",
-						Context: map[string]any{"request_id": idHex},
-					},
-				},
-			},
-		}},
-	}
+	tlqcProps := mattermost.Props{Attachments: s.tlqcActions(ctx, idHex)}
 
 	if req.TLQCPostID != "" {
 		// Resubmit: update existing TLQC post and notify in thread
@@ -240,10 +220,7 @@ func (s *BudgetService) SubmitContent(ctx context.Context, requestID, userID, po
 	}
 
 	// Update sale post status
-	s.mm.UpdatePost(req.SalePostID, &mattermost.Post{
-		ChannelID: req.SaleChannelID,
-		Message:   infoMsg,
-	})
+	s.updateSalePost(ctx, req, infoMsg)
 
 	return s.store.Update(ctx, req)
 }
@@ -259,10 +236,12 @@ func (s *BudgetService) ConfirmTLQC(ctx context.Context, requestID, userID strin
 	req.TLQCUserID = userID
 	req.TLQCConfirmedAt = &now
 	req.CurrentStep = model.BudgetStepTLQCConfirmed
+	recordBudgetAction(req, model.BudgetActionConfirmed, userID, "", nil)
 
 	if err := s.store.Update(ctx, req); err != nil {
 		return err
 	}
+	s.emitStepChanged(ctx, req, model.BudgetStepPartnerContent)
 
 	idHex := req.ID.Hex()
 	infoMsg := formatBudgetStatus(ctx, req, i18n.T(ctx, "budget.status.step3"))
@@ -311,10 +290,7 @@ func (s *BudgetService) ConfirmTLQC(ctx context.Context, requestID, userID strin
 	})
 
 	// Update sale post status
-	s.mm.UpdatePost(req.SalePostID, &mattermost.Post{
-		ChannelID: req.SaleChannelID,
-		Message:   infoMsg,
-	})
+	s.updateSalePost(ctx, req, infoMsg)
 
 	return nil
 }
@@ -336,10 +312,12 @@ func (s *BudgetService) ReturnToPartner(ctx context.Context, requestID, userID,
 	req.PageLink = ""
 	req.ContentAt = nil
 	req.CurrentStep = model.BudgetStepSaleCreated
+	recordBudgetAction(req, model.BudgetActionReturned, userID, reason, nil)
 
 	if err := s.store.Update(ctx, req); err != nil {
 		return err
 	}
+	s.emitStepChanged(ctx, req, model.BudgetStepPartnerContent)
 
 	idHex := req.ID.Hex()
 	infoMsg := formatBudgetStatus(ctx, req, i18n.T(ctx, "budget.status.returned"))
@@ -358,18 +336,7 @@ func (s *BudgetService) ReturnToPartner(ctx context.Context, requestID, userID,
 		ChannelID: req.PartnerChannelID,
 		Message:   infoMsg,
 		Props: mattermost.Props{
-			Attachments: []mattermost.Attachment{{
-				Actions: []mattermost.Action{
-					{
-						Name: i18n.T(ctx, "budget.btn.fill_content"),
-						Type: "button",
-						Integration: mattermost.Integration{
-							URL:     s.botURL + "/api/budget/partner-content-form",
-							Context: map[string]any{"request_id": idHex},
-						},
-					},
-				},
-			}},
+			Attachments: s.contentActions(ctx, idHex),
 		},
 	})
 
@@ -388,16 +355,13 @@ func (s *BudgetService) ReturnToPartner(ctx context.Context, requestID, userID,
 	})
 
 	// Update sale post status
-	s.mm.UpdatePost(req.SalePostID, &mattermost.Post{
-		ChannelID: req.SaleChannelID,
-		Message:   infoMsg,
-	})
+	s.updateSalePost(ctx, req, infoMsg)
 
 	return nil
 }
 
 // SubmitPayment handles step 4: Partner submits payment info from budget-partner-{partner}.
-func (s *BudgetService) SubmitPayment(ctx context.Context, requestID, recipientName, bankAccount, bankName, paymentAmount string) error {
+func (s *BudgetService) SubmitPayment(ctx context.Context, requestID, userID, recipientName, bankAccount, bankName, paymentAmount string) error {
 	req, err := s.getAndValidate(ctx, requestID, model.BudgetStepTLQCConfirmed)
 	if err != nil {
 		return err
@@ -410,10 +374,12 @@ func (s *BudgetService) SubmitPayment(ctx context.Context, requestID, recipientN
 	req.PaymentAmount = paymentAmount
 	req.PaymentAt = &now
 	req.CurrentStep = model.BudgetStepPaymentInfo
+	recordBudgetAction(req, model.BudgetActionPaymentSubmitted, userID, "", nil)
 
 	if err := s.store.Update(ctx, req); err != nil {
 		return err
 	}
+	s.emitStepChanged(ctx, req, model.BudgetStepTLQCConfirmed)
 
 	idHex := req.ID.Hex()
 	infoMsg := formatBudgetStatus(ctx, req, i18n.T(ctx, "budget.status.step4"))
@@ -435,7 +401,7 @@ func (s *BudgetService) SubmitPayment(ctx context.Context, requestID, recipientN
 	// Create post in budget-approval with approve + reject buttons
 	approvalPost, err := s.mm.CreatePost(&mattermost.Post{
 		ChannelID: req.ApprovalChannelID,
-		Message:   "@all",
+		Message:   s.delegations.ApprovalMention(ctx, model.DelegationScopeBudget, req.ApprovalChannelID, ""),
 		Props: mattermost.Props{
 			MessageKey: "budget.msg.approval_review",
 			MessageData: map[string]any{
@@ -470,10 +436,7 @@ func (s *BudgetService) SubmitPayment(ctx context.Context, requestID, recipientN
 	}
 
 	// Update sale post status
-	s.mm.UpdatePost(req.SalePostID, &mattermost.Post{
-		ChannelID: req.SaleChannelID,
-		Message:   infoMsg,
-	})
+	s.updateSalePost(ctx, req, infoMsg)
 
 	req.ApprovalPostID = approvalPost.ID
 	return s.store.Update(ctx, req)
@@ -488,15 +451,28 @@ func (s *BudgetService) Approve(ctx context.Context, requestID, userID string) e
 
 	now := time.Now()
 	req.ApproverID = userID
+	if d := s.delegations.OnBehalfOf(ctx, model.DelegationScopeBudget, req.ApprovalChannelID, userID); d != nil {
+		req.OnBehalfOfID = d.DelegatorID
+		req.OnBehalfOfUsername = d.DelegatorUsername
+	}
 	req.ApprovedAt = &now
 	req.CurrentStep = model.BudgetStepApproved
+	recordBudgetAction(req, model.BudgetActionApproved, userID, "", nil)
 
 	if err := s.store.Update(ctx, req); err != nil {
 		return err
 	}
+	s.emitStepChanged(ctx, req, model.BudgetStepPaymentInfo)
 
 	idHex := req.ID.Hex()
 	infoMsg := formatBudgetStatus(ctx, req, i18n.T(ctx, "budget.status.step5"))
+	if req.OnBehalfOfUsername != "" {
+		infoMsg += fmt.Sprintf("\n| %s | %s |", i18n.T(ctx, "budget.info.approved_by"),
+			i18n.T(ctx, "budget.info.on_behalf_of", map[string]any{
+				"Approver":   s.extractUsername(s.userMention(userID)),
+				"OnBehalfOf": req.OnBehalfOfUsername,
+			}))
+	}
 
 	// Update approval post (remove buttons)
 	s.mm.UpdatePost(req.ApprovalPostID, &mattermost.Post{
@@ -537,10 +513,7 @@ func (s *BudgetService) Approve(ctx context.Context, requestID, userID string) e
 	}
 
 	// Update sale post status
-	s.mm.UpdatePost(req.SalePostID, &mattermost.Post{
-		ChannelID: req.SaleChannelID,
-		Message:   infoMsg,
-	})
+	s.updateSalePost(ctx, req, infoMsg)
 
 	req.FinancePostID = financePost.ID
 	return s.store.Update(ctx, req)
@@ -559,13 +532,15 @@ func (s *BudgetService) Complete(ctx context.Context, requestID, transactionCode
 	req.BillURL = billURL
 	req.CompletedAt = &now
 	req.CurrentStep = model.BudgetStepCompleted
+	recordBudgetAction(req, model.BudgetActionCompleted, userID, "", nil)
 
 	if err := s.store.Update(ctx, req); err != nil {
 		return err
 	}
+	s.hooks.Emit(ctx, model.EventBudgetCompleted, map[string]any{"budget_request": req})
 
 	completedMsg := formatCompletedMsg(ctx, req)
-	s.updateAllPosts(req, completedMsg)
+	s.updateAllPosts(ctx, req, completedMsg)
 	return nil
 }
 
@@ -589,22 +564,28 @@ func (s *BudgetService) RejectRequest(ctx context.Context, requestID, userID str
 	if req.RejectedAt != nil {
 		return fmt.Errorf(i18n.T(ctx, "budget.err.already_rejected"))
 	}
+	if req.WithdrawnAt != nil {
+		return errors.New(i18n.T(ctx, "budget.err.been_withdrawn"))
+	}
 
 	now := time.Now()
 	req.RejectedAt = &now
+	recordBudgetAction(req, model.BudgetActionRejected, userID, "", nil)
 	if err := s.store.Update(ctx, req); err != nil {
 		return err
 	}
+	s.hooks.Emit(ctx, model.EventBudgetRejected, map[string]any{"budget_request": req})
 
 	rejectedMsg := formatRejectedMsg(ctx, req)
-	s.updateAllPosts(req, rejectedMsg)
+	s.updateAllPosts(ctx, req, rejectedMsg)
 	return nil
 }
 
 // updateAllPosts updates all existing posts across all channels with the given message.
-func (s *BudgetService) updateAllPosts(req *model.BudgetRequest, msg string) {
+// The sale post keeps the requester's actions that still apply.
+func (s *BudgetService) updateAllPosts(ctx context.Context, req *model.BudgetRequest, msg string) {
+	s.updateSalePost(ctx, req, msg)
 	posts := []struct{ postID, channelID string }{
-		{req.SalePostID, req.SaleChannelID},
 		{req.PartnerPostID, req.PartnerChannelID},
 		{req.TLQCPostID, req.TLQCChannelID},
 		{req.ApprovalPostID, req.ApprovalChannelID},
@@ -623,6 +604,15 @@ func (s *BudgetService) updateAllPosts(req *model.BudgetRequest, msg string) {
 	}
 }
 
+// emitStepChanged reports a workflow step transition to webhook subscribers.
+func (s *BudgetService) emitStepChanged(ctx context.Context, req *model.BudgetRequest, from model.BudgetStep) {
+	s.hooks.Emit(ctx, model.EventBudgetStepChanged, map[string]any{
+		"budget_request": req,
+		"from_step":      from,
+		"to_step":        req.CurrentStep,
+	})
+}
+
 func (s *BudgetService) getAndValidate(ctx context.Context, requestID string, expectedStep model.BudgetStep) (*model.BudgetRequest, error) {
 	id, err := bson.ObjectIDFromHex(requestID)
 	if err != nil {
@@ -639,6 +629,9 @@ func (s *BudgetService) getAndValidate(ctx context.Context, requestID string, ex
 	if req.RejectedAt != nil {
 		return nil, fmt.Errorf(i18n.T(ctx, "budget.err.been_rejected"))
 	}
+	if req.WithdrawnAt != nil {
+		return nil, errors.New(i18n.T(ctx, "budget.err.been_withdrawn"))
+	}
 	if req.CurrentStep != expectedStep {
 		return nil, fmt.Errorf(i18n.T(ctx, "budget.err.wrong_step", map[string]any{
 			"Current": fmt.Sprintf("%d", req.CurrentStep), "Expected": fmt.Sprintf("%d", expectedStep),
@@ -661,6 +654,14 @@ func formatBudgetStatus(ctx context.Context, req *model.BudgetRequest, statusLab
 	return formatBudgetInfo(ctx, req) + fmt.Sprintf("\n| %s | %s |", i18n.T(ctx, "budget.info.status"), statusLabel)
 }
 
+// formatContentDetail renders the partner's content as rows to append to the info table.
+func formatContentDetail(ctx context.Context, req *model.BudgetRequest) string {
+	return fmt.Sprintf("\n| %s | %s |\n| %s | %s |\n| %s | %s |",
+		i18n.T(ctx, "budget.info.post_content"), req.PostContent,
+		i18n.T(ctx, "budget.info.post_link"), req.PostLink,
+		i18n.T(ctx, "budget.info.page_link"), req.PageLink)
+}
+
 func formatCompletedMsg(ctx context.Context, req *model.BudgetRequest) string {
 	msg := formatBudgetInfo(ctx, req)
 	msg += fmt.Sprintf("\n| %s | %s |", i18n.T(ctx, "budget.info.transaction_code"), req.TransactionCode)
@@ -677,6 +678,13 @@ func formatRejectedMsg(ctx context.Context, req *model.BudgetRequest) string {
 		i18n.T(ctx, "budget.status.rejected", map[string]any{"Step": fmt.Sprintf("%d", req.CurrentStep)}))
 }
 
+func formatWithdrawnMsg(ctx context.Context, req *model.BudgetRequest) string {
+	return formatBudgetInfo(ctx, req) + fmt.Sprintf("\n| %s | %s |\n| %s | %s |",
+		i18n.T(ctx, "budget.info.withdraw_reason"), req.WithdrawReason,
+		i18n.T(ctx, "budget.info.status"),
+		i18n.T(ctx, "budget.status.withdrawn", map[string]any{"Step": fmt.Sprintf("%d", req.CurrentStep)}))
+}
+
 // userMention returns a @username mention for a user ID, falling back to @all on error.
 func (s *BudgetService) userMention(userID string) string {
 	if userID == "" {
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+)
+
+// recordBudgetAction appends an action to the request's history, at the step the action
+// left it at.
+func recordBudgetAction(req *model.BudgetRequest, action, userID, reason string, changes []model.BudgetFieldChange) {
+	req.History = append(req.History, model.BudgetHistoryEntry{
+		Action:  action,
+		UserID:  userID,
+		Step:    req.CurrentStep,
+		Reason:  reason,
+		Changes: changes,
+		At:      time.Now(),
+	})
+}
+
+// saleActions returns the requester's buttons on the sale post: edit and withdraw while
+// the request is open, clone always.
+func (s *BudgetService) saleActions(ctx context.Context, req *model.BudgetRequest) []mattermost.Attachment {
+	action := func(key, path string) mattermost.Action {
+		return mattermost.Action{
+			Name: i18n.T(ctx, key),
+			Type: "button",
+			Integration: mattermost.Integration{
+				URL:     s.botURL + path,
+				Context: map[string]any{"request_id": req.ID.Hex()},
+			},
+		}
+	}
+	var actions []mattermost.Action
+	if !req.Closed() {
+		actions = append(actions,
+			action("budget.btn.edit", "/api/budget/edit-form"),
+			action("budget.btn.withdraw", "/api/budget/withdraw-form"),
+		)
+	}
+	actions = append(actions, action("budget.btn.clone", "/api/budget/clone-form"))
+	return []mattermost.Attachment{{Actions: actions}}
+}
+
+// updateSalePost replaces the sale post's message, keeping the requester's buttons.
+func (s *BudgetService) updateSalePost(ctx context.Context, req *model.BudgetRequest, msg string) {
+	if req.SalePostID == "" {
+		return
+	}
+	s.mm.UpdatePost(req.SalePostID, &mattermost.Post{
+		ChannelID: req.SaleChannelID,
+		Message:   msg,
+		Props: mattermost.Props{
+			Attachments: s.saleActions(ctx, req),
+		},
+	})
+}
+
+// contentActions returns the partner's button to fill in the post content.
+func (s *BudgetService) contentActions(ctx context.Context, idHex string) []mattermost.Attachment {
+	return []mattermost.Attachment{{
+		Actions: []mattermost.Action{
+			{
+				Name: i18n.T(ctx, "budget.btn.fill_content"),
+				Type: "button",
+				Integration: mattermost.Integration{
+					URL:     s.botURL + "/api/budget/partner-content-form",
+					Context: map[string]any{"request_id": idHex},
+				},
+			},
+		},
+	}}
+}
+
+// tlqcActions returns TLQC's confirm and return buttons.
+func (s *BudgetService) tlqcActions(ctx context.Context, idHex string) []mattermost.Attachment {
+	return []mattermost.Attachment{{
+		Actions: []mattermost.Action{
+			{
+				Name: i18n.T(ctx, "budget.btn.confirm"),
+				Type: "button",
+				Integration: mattermost.Integration{
+					URL:     s.botURL + "/api/budget/tlqc-confirm",
+					Context: map[string]any{"request_id": idHex},
+				},
+			},
+			{
+				Name: i18n.T(ctx, "budget.btn.return"),
+				Type: "button",
+				Integration: mattermost.Integration{
+					URL:     s.botURL + "/api/budget/tlqc-return-form",
+					Context: map[string]any{"request_id": idHex},
+				},
+			},
+		},
+	}}
+}
+
+// GetOwnRequest returns a budget request created by userID.
+func (s *BudgetService) GetOwnRequest(ctx context.Context, requestID, userID string) (*model.BudgetRequest, error) {
+	id, err := bson.ObjectIDFromHex(requestID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request ID: %w", err)
+	}
+	req, err := s.store.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return nil, errors.New(i18n.T(ctx, "budget.err.not_found"))
+	}
+	if req.SaleUserID != userID {
+		return nil, errors.New(i18n.T(ctx, "budget.err.not_requester"))
+	}
+	return req, nil
+}
+
+// GetEditableRequest returns an open budget request created by userID.
+func (s *BudgetService) GetEditableRequest(ctx context.Context, requestID, userID string) (*model.BudgetRequest, error) {
+	req, err := s.GetOwnRequest(ctx, requestID, userID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case req.WithdrawnAt != nil:
+		return nil, errors.New(i18n.T(ctx, "budget.err.been_withdrawn"))
+	case req.RejectedAt != nil:
+		return nil, errors.New(i18n.T(ctx, "budget.err.been_rejected"))
+	case req.CurrentStep >= model.BudgetStepCompleted:
+		return nil, errors.New(i18n.T(ctx, "budget.err.already_completed"))
+	}
+	return req, nil
+}
+
+// EditRequest changes the requester's fields of an open request. Before the partner submits
+// content the change applies in place; afterwards the request goes back to step 2 so TLQC
+// confirms it again, and the partner resubmits payment info.
+func (s *BudgetService) EditRequest(ctx context.Context, requestID, userID, name, amount, purpose, deadline string) error {
+	req, err := s.GetEditableRequest(ctx, requestID, userID)
+	if err != nil {
+		return err
+	}
+
+	var changes []model.BudgetFieldChange
+	for _, f := range []struct {
+		field string
+		value *string
+		new   string
+	}{
+		{"name", &req.Name, name},
+		{"amount", &req.Amount, amount},
+		{"purpose", &req.Purpose, purpose},
+		{"deadline", &req.Deadline, deadline},
+	} {
+		if *f.value != f.new {
+			changes = append(changes, model.BudgetFieldChange{Field: f.field, Old: *f.value, New: f.new})
+			*f.value = f.new
+		}
+	}
+	if len(changes) == 0 {
+		return errors.New(i18n.T(ctx, "budget.err.no_changes"))
+	}
+
+	from := req.CurrentStep
+	reconfirm := from > model.BudgetStepSaleCreated
+	if reconfirm {
+		// Keep the partner's content and TLQCUserID (to notify them); everything TLQC
+		// confirmed and after it must be redone
+		req.TLQCConfirmedAt = nil
+		req.RecipientName = ""
+		req.BankAccount = ""
+		req.BankName = ""
+		req.PaymentAmount = ""
+		req.PaymentAt = nil
+		req.ApproverID = ""
+		req.OnBehalfOfID = ""
+		req.OnBehalfOfUsername = ""
+		req.ApprovedAt = nil
+		req.CurrentStep = model.BudgetStepPartnerContent
+	}
+	recordBudgetAction(req, model.BudgetActionEdited, userID, "", changes)
+
+	if err := s.store.Update(ctx, req); err != nil {
+		return err
+	}
+	s.hooks.Emit(ctx, model.EventBudgetEdited, map[string]any{
+		"budget_request": req,
+		"changes":        changes,
+		"from_step":      from,
+	})
+
+	idHex := req.ID.Hex()
+	summary := formatBudgetChanges(ctx, changes)
+	requesterMention := s.userMention(userID)
+
+	if !reconfirm {
+		infoMsg := formatBudgetStatus(ctx, req, i18n.T(ctx, "budget.status.step1"))
+		s.mm.UpdatePost(req.PartnerPostID, &mattermost.Post{
+			ChannelID: req.PartnerChannelID,
+			Message:   infoMsg,
+			Props:     mattermost.Props{Attachments: s.contentActions(ctx, idHex)},
+		})
+		s.notifyEdited(req, requesterMention, summary)
+		s.updateSalePost(ctx, req, infoMsg)
+		return nil
+	}
+
+	infoMsg := formatBudgetStatus(ctx, req, i18n.T(ctx, "budget.status.reconfirm"))
+	contentDetail := formatContentDetail(ctx, req)
+
+	// Approval and finance posts of the previous pass lose their buttons
+	for _, p := range []struct{ postID, channelID string }{
+		{req.ApprovalPostID, req.ApprovalChannelID},
+		{req.FinancePostID, req.FinanceChannelID},
+	} {
+		if p.postID != "" && from >= model.BudgetStepPaymentInfo {
+			s.mm.UpdatePost(p.postID, &mattermost.Post{
+				ChannelID: p.channelID,
+				Message:   infoMsg,
+				Props:     mattermost.Props{Attachments: []mattermost.Attachment{}},
+			})
+		}
+	}
+
+	s.mm.UpdatePost(req.PartnerPostID, &mattermost.Post{
+		ChannelID: req.PartnerChannelID,
+		Message:   infoMsg + contentDetail,
+		Props:     mattermost.Props{Attachments: []mattermost.Attachment{}},
+	})
+	s.notifyEdited(req, requesterMention, summary)
+
+	if req.TLQCPostID != "" {
+		s.mm.UpdatePost(req.TLQCPostID, &mattermost.Post{
+			ChannelID: req.TLQCChannelID,
+			Message:   infoMsg + contentDetail,
+			Props:     mattermost.Props{Attachments: s.tlqcActions(ctx, idHex)},
+		})
+		tlqcMention := s.userMention(req.TLQCUserID)
+		s.mm.CreatePost(&mattermost.Post{
+			ChannelID: req.TLQCChannelID,
+			RootID:    req.TLQCPostID,
+			Message:   tlqcMention,
+			Props: mattermost.Props{
+				MessageKey: "budget.msg.edited_reconfirm",
+				MessageData: map[string]any{
+					"Username": s.extractUsername(tlqcMention),
+					"Changes":  summary,
+				},
+			},
+		})
+	}
+
+	s.updateSalePost(ctx, req, infoMsg)
+	return nil
+}
+
+// notifyEdited tells the partner channel, in the request's thread, what the requester changed.
+func (s *BudgetService) notifyEdited(req *model.BudgetRequest, requesterMention, summary string) {
+	s.mm.CreatePost(&mattermost.Post{
+		ChannelID: req.PartnerChannelID,
+		RootID:    req.PartnerPostID,
+		Message:   requesterMention,
+		Props: mattermost.Props{
+			MessageKey: "budget.msg.request_edited",
+			MessageData: map[string]any{
+				"Username": s.extractUsername(requesterMention),
+				"Changes":  summary,
+			},
+		},
+	})
+}
+
+// WithdrawRequest lets the requester end an open request with a reason.
+func (s *BudgetService) WithdrawRequest(ctx context.Context, requestID, userID, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return errors.New(i18n.T(ctx, "budget.err.reason_required"))
+	}
+	req, err := s.GetEditableRequest(ctx, requestID, userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	req.WithdrawnAt = &now
+	req.WithdrawReason = reason
+	recordBudgetAction(req, model.BudgetActionWithdrawn, userID, reason, nil)
+	if err := s.store.Update(ctx, req); err != nil {
+		return err
+	}
+	s.hooks.Emit(ctx, model.EventBudgetWithdrawn, map[string]any{"budget_request": req})
+
+	s.updateAllPosts(ctx, req, formatWithdrawnMsg(ctx, req))
+	return nil
+}
+
+// CloneRequest creates a new request in the source request's sale channel from edited
+// copies of its fields. Only the requester of the source may clone it.
+func (s *BudgetService) CloneRequest(ctx context.Context, sourceID, userID, name, partner, amount, purpose, deadline string) error {
+	source, err := s.GetOwnRequest(ctx, sourceID, userID)
+	if err != nil {
+		return err
+	}
+	return s.createRequest(ctx, userID, source.SaleChannelID, name, partner, amount, purpose, deadline, source.ID.Hex())
+}
+
+// formatBudgetChanges renders edits as "Field: old → new" items.
+func formatBudgetChanges(ctx context.Context, changes []model.BudgetFieldChange) string {
+	parts := make([]string, len(changes))
+	for i, c := range changes {
+		parts[i] = fmt.Sprintf("%s: %s → %s", i18n.T(ctx, "budget.field."+c.Field), c.Old, c.New)
+	}
+	return strings.Join(parts, "; ")
+}
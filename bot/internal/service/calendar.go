@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/store"
+)
+
+// Calendar feed scopes: a whole team or a single user.
+const (
+	CalendarScopeTeam = "team"
+	CalendarScopeUser = "user"
+)
+
+// calendarTypes are the request types shown on the leave calendar, in column order.
+var calendarTypes = []model.LeaveType{model.LeaveTypeOff, model.LeaveTypeLateArrival, model.LeaveTypeEarlyDeparture}
+
+// Feed window relative to today.
+const (
+	calendarFeedPastDays   = 30
+	calendarFeedFutureDays = 365
+)
+
+// CalendarService renders the team leave calendar, in chat and as a subscribable iCalendar feed.
+type CalendarService struct {
+	store   store.AttendanceStore
+	feeds   store.CalendarFeedStore
+	secret  []byte // signs feed URLs; empty disables the feed
+	feedURL string // base URL of the plugin's Mattermost route, used in feed links; empty disables the feed
+}
+
+func NewCalendarService(store store.AttendanceStore, feeds store.CalendarFeedStore, secret, feedURL string) *CalendarService {
+	return &CalendarService{store: store, feeds: feeds, secret: []byte(secret), feedURL: strings.TrimRight(feedURL, "/")}
+}
+
+// feedToken signs a feed scope and ID (team or user ID) for the user the URL is issued
+// to, with that user's feed key.
+func (s *CalendarService) feedToken(scope, id, userID, key string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(scope + ":" + id + ":" + userID + ":" + key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// feedKey returns a user's feed key, issuing one on first use.
+func (s *CalendarService) feedKey(ctx context.Context, userID string) (string, error) {
+	feed, err := s.feeds.Get(ctx, userID)
+	if err != nil || feed != nil {
+		return feedKeyOf(feed), err
+	}
+	key, err := newFeedKey()
+	if err != nil {
+		return "", err
+	}
+	if err := s.feeds.Create(ctx, &model.CalendarFeed{UserID: userID, Key: key, UpdatedAt: time.Now()}); err != nil {
+		return "", err
+	}
+	// Another request may have issued the key first; use whichever was stored.
+	feed, err = s.feeds.Get(ctx, userID)
+	return feedKeyOf(feed), err
+}
+
+func feedKeyOf(feed *model.CalendarFeed) string {
+	if feed == nil {
+		return ""
+	}
+	return feed.Key
+}
+
+func newFeedKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate calendar feed key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// FeedEnabled reports whether feed links are issued and served: the feed needs a secret
+// and the Mattermost route of the plugin, as the standalone bot is not reachable from
+// outside the cluster.
+func (s *CalendarService) FeedEnabled() bool {
+	return len(s.secret) > 0 && s.feedURL != ""
+}
+
+// VerifyFeedToken reports whether token was issued to userID for the scope and ID with the
+// user's current feed key. It always fails when the feed is disabled.
+func (s *CalendarService) VerifyFeedToken(ctx context.Context, scope, id, userID, token string) (bool, error) {
+	if !s.FeedEnabled() || id == "" || userID == "" {
+		return false, nil
+	}
+	feed, err := s.feeds.Get(ctx, userID)
+	if err != nil || feed == nil {
+		return false, err
+	}
+	return hmac.Equal([]byte(token), []byte(s.feedToken(scope, id, userID, feed.Key))), nil
+}
+
+// FeedURL returns the subscription URL for a scope and ID issued to userID, or "" if the
+// feed is disabled. For the user scope, id is userID.
+func (s *CalendarService) FeedURL(ctx context.Context, scope, id, userID string) (string, error) {
+	if !s.FeedEnabled() {
+		return "", nil
+	}
+	key, err := s.feedKey(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set(scope, id)
+	if scope != CalendarScopeUser {
+		q.Set(CalendarScopeUser, userID)
+	}
+	q.Set("token", s.feedToken(scope, id, userID, key))
+	return s.feedURL + "/api/attendance/calendar.ics?" + q.Encode(), nil
+}
+
+// ResetFeedKey replaces a user's feed key, revoking every feed URL issued to them.
+func (s *CalendarService) ResetFeedKey(ctx context.Context, userID string) error {
+	key, err := newFeedKey()
+	if err != nil {
+		return err
+	}
+	return s.feeds.Save(ctx, &model.CalendarFeed{UserID: userID, Key: key, UpdatedAt: time.Now()})
+}
+
+// RevokeFeed deletes a user's feed key, revoking every feed URL issued to them, when the
+// user is deactivated.
+func (s *CalendarService) RevokeFeed(ctx context.Context, userID string) error {
+	return s.feeds.Delete(ctx, userID)
+}
+
+// entries returns the calendar's requests overlapping [from, to], skipping rejected and cancelled ones.
+func (s *CalendarService) entries(ctx context.Context, from, to, teamID, userID string) ([]*model.LeaveRequest, error) {
+	requests, err := s.store.GetLeaveRequestsByDateRange(ctx, from, to, userID, teamID, "")
+	if err != nil {
+		return nil, err
+	}
+	var out []*model.LeaveRequest
+	for _, req := range requests {
+		if req.Status == model.LeaveStatusRejected || req.Status == model.LeaveStatusCancelled {
+			continue
+		}
+		if !slices.Contains(calendarTypes, req.Type) {
+			continue
+		}
+		out = append(out, req)
+	}
+	return out, nil
+}
+
+// CalendarRange returns the current week (Monday to Sunday) or month in vnTZ.
+func CalendarRange(period string, now time.Time) (from, to time.Time) {
+	now = now.In(vnTZ)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, vnTZ)
+	if period == "month" {
+		from = day.AddDate(0, 0, 1-day.Day())
+		return from, from.AddDate(0, 1, -1)
+	}
+	from = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	return from, from.AddDate(0, 0, 6)
+}
+
+// RenderTable renders who is off, late or leaving early between from and to as a markdown table.
+// Days without entries are omitted.
+func (s *CalendarService) RenderTable(ctx context.Context, teamID string, from, to time.Time) (string, error) {
+	fromStr, toStr := from.Format(time.DateOnly), to.Format(time.DateOnly)
+	requests, err := s.entries(ctx, fromStr, toStr, teamID, "")
+	if err != nil {
+		return "", err
+	}
+
+	// date → type → names
+	cells := map[string]map[model.LeaveType][]string{}
+	for _, req := range requests {
+		name := "@" + req.Username
+		if req.ExpectedTime != "" {
+			name += " (" + req.ExpectedTime + ")"
+		}
+		if req.Status == model.LeaveStatusPending || req.Status == model.LeaveStatusPendingChange {
+			name += " " + i18n.T(ctx, "calendar.pending")
+		}
+		for _, d := range req.Dates {
+			if d < fromStr || d > toStr {
+				continue
+			}
+			if cells[d] == nil {
+				cells[d] = map[model.LeaveType][]string{}
+			}
+			cells[d][req.Type] = append(cells[d][req.Type], name)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(i18n.T(ctx, "calendar.header", map[string]any{
+		"From": model.FormatDateDisplay(fromStr),
+		"To":   model.FormatDateDisplay(toStr),
+	}))
+	if len(cells) == 0 {
+		b.WriteString("\n" + i18n.T(ctx, "calendar.empty"))
+		return b.String(), nil
+	}
+	fmt.Fprintf(&b, "\n| %s |", i18n.T(ctx, "calendar.col.date"))
+	for _, lt := range calendarTypes {
+		fmt.Fprintf(&b, " %s |", i18n.T(ctx, calendarTypeKey(lt)))
+	}
+	b.WriteString("\n|:--|" + strings.Repeat(":--|", len(calendarTypes)))
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		date := d.Format(time.DateOnly)
+		if cells[date] == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "\n| %s %s |", i18n.T(ctx, "calendar.weekday."+strings.ToLower(d.Weekday().String()[:3])), model.FormatDateDisplay(date))
+		for _, lt := range calendarTypes {
+			names := cells[date][lt]
+			slices.Sort(names)
+			fmt.Fprintf(&b, " %s |", strings.Join(names, ", "))
+		}
+	}
+	return b.String(), nil
+}
+
+// RenderICS renders an iCalendar feed for a team or a single user, covering the last
+// calendarFeedPastDays and the next calendarFeedFutureDays. Each requested date is an
+// all-day event; requests still awaiting approval are marked tentative. Reasons are only
+// included in a user's own feed, since a team feed URL can be shared outside the team.
+func (s *CalendarService) RenderICS(ctx context.Context, scope, id string) ([]byte, error) {
+	var teamID, userID string
+	if scope == CalendarScopeUser {
+		userID = id
+	} else {
+		teamID = id
+	}
+	today := time.Now().In(vnTZ)
+	from := today.AddDate(0, 0, -calendarFeedPastDays).Format(time.DateOnly)
+	to := today.AddDate(0, 0, calendarFeedFutureDays).Format(time.DateOnly)
+	requests, err := s.entries(ctx, from, to, teamID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//oktel-bot//leave calendar//EN")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "METHOD:PUBLISH")
+	writeICSLine(&b, "X-WR-CALNAME:"+escapeICSText(i18n.T(ctx, "calendar.feed_name")))
+	writeICSLine(&b, "X-WR-TIMEZONE:Asia/Ho_Chi_Minh")
+	for _, req := range requests {
+		summary := "@" + req.Username + " — " + i18n.T(ctx, calendarTypeKey(req.Type))
+		if req.ExpectedTime != "" {
+			summary += " (" + req.ExpectedTime + ")"
+		}
+		status := "CONFIRMED"
+		if req.Status == model.LeaveStatusPending || req.Status == model.LeaveStatusPendingChange {
+			status = "TENTATIVE"
+		}
+		for _, d := range req.Dates {
+			if d < from || d > to {
+				continue
+			}
+			day, err := time.Parse(time.DateOnly, d)
+			if err != nil {
+				continue
+			}
+			writeICSLine(&b, "BEGIN:VEVENT")
+			writeICSLine(&b, "UID:"+req.ID.Hex()+"-"+d+"@oktel-bot")
+			writeICSLine(&b, "DTSTAMP:"+req.UpdatedAt.UTC().Format("20060102T150405Z"))
+			writeICSLine(&b, "DTSTART;VALUE=DATE:"+day.Format("20060102"))
+			writeICSLine(&b, "DTEND;VALUE=DATE:"+day.AddDate(0, 0, 1).Format("20060102"))
+			writeICSLine(&b, "SUMMARY:"+escapeICSText(summary))
+			if req.Reason != "" && scope == CalendarScopeUser {
+				writeICSLine(&b, "DESCRIPTION:"+escapeICSText(req.Reason))
+			}
+			writeICSLine(&b, "STATUS:"+status)
+			writeICSLine(&b, "TRANSP:TRANSPARENT")
+			writeICSLine(&b, "END:VEVENT")
+		}
+	}
+	writeICSLine(&b, "END:VCALENDAR")
+	return []byte(b.String()), nil
+}
+
+func calendarTypeKey(lt model.LeaveType) string {
+	switch lt {
+	case model.LeaveTypeLateArrival:
+		return "leave.type.late"
+	case model.LeaveTypeEarlyDeparture:
+		return "leave.type.early"
+	default:
+		return "leave.type.off"
+	}
+}
+
+// escapeICSText escapes a TEXT value per RFC 5545 section 3.3.11.
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeICSLine writes a content line terminated by CRLF, folding it at 75 octets
+// without splitting UTF-8 sequences.
+func writeICSLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	b.WriteString(line + "\r\n")
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// RequestCancellation withdraws a pending request or asks an approver to cancel an approved one.
+// dates selects which dates to cancel; an empty list cancels every remaining future date.
+func (s *AttendanceService) RequestCancellation(ctx context.Context, requestID, userID string, dates []string, reason, approver string) error {
+	id, err := bson.ObjectIDFromHex(requestID)
+	if err != nil {
+		return fmt.Errorf("invalid request ID: %w", err)
+	}
+
+	req, err := s.store.GetLeaveRequestByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("get leave request: %w", err)
+	}
+	if req == nil {
+		return errors.New(i18n.T(ctx, "attendance.err.not_found"))
+	}
+	if req.UserID != userID {
+		return errors.New(i18n.T(ctx, "attendance.err.cancel_not_owner"))
+	}
+	if req.Status != model.LeaveStatusPending && req.Status != model.LeaveStatusApproved {
+		return errors.New(i18n.T(ctx, "attendance.err.cancel_invalid_status", map[string]any{"Status": string(req.Status)}))
+	}
+
+	// Only future dates can be cancelled
+	today := time.Now().In(vnTZ).Format(time.DateOnly)
+	if len(dates) == 0 {
+		for _, d := range req.Dates {
+			if d >= today {
+				dates = append(dates, d)
+			}
+		}
+	}
+	if len(dates) == 0 {
+		return errors.New(i18n.T(ctx, "attendance.err.cancel_past_dates"))
+	}
+	for _, d := range dates {
+		if d < today || !slices.Contains(req.Dates, d) {
+			return errors.New(i18n.T(ctx, "attendance.err.cancel_past_dates"))
+		}
+	}
+
+	if req.Status == model.LeaveStatusPending {
+		// Pending: nobody has approved it yet, so withdraw directly
+		applyCancellation(req, dates, time.Now())
+		if err := s.store.UpdateLeaveRequest(ctx, req); err != nil {
+			return fmt.Errorf("update leave request: %w", err)
+		}
+
+		msgKey := leaveMessageKey(req.Type)
+		msgData := leaveMessageData(req)
+
+		// Update existing info post in main channel
+		s.mm.UpdatePost(req.PostID, &mattermost.Post{
+			ChannelID: req.ChannelID,
+			Message:   "@" + req.Username,
+			Props: mattermost.Props{
+				MessageKey:  msgKey,
+				MessageData: msgData,
+			},
+		})
+
+		// Update approval post: drop the buttons once nothing is left to approve
+		var attachments []mattermost.Attachment
+		if req.Status == model.LeaveStatusPending {
+			attachments = []mattermost.Attachment{{
+				Actions: []mattermost.Action{
+					{
+						Name: i18n.T(ctx, "attendance.btn.approve"),
+						Type: "button",
+						Integration: mattermost.Integration{
+							URL:     s.botURL + "/api/attendance/approve",
+							Context: map[string]any{"request_id": req.ID.Hex()},
+						},
+					},
+					{
+						Name: i18n.T(ctx, "attendance.btn.reject"),
+						Type: "button",
+						Integration: mattermost.Integration{
+							URL:     s.botURL + "/api/attendance/reject",
+							Context: map[string]any{"request_id": req.ID.Hex()},
+						},
+					},
+				},
+			}}
+		} else {
+			attachments = []mattermost.Attachment{}
+		}
+		s.mm.UpdatePost(req.ApprovalPostID, &mattermost.Post{
+			ChannelID: req.ApprovalChannelID,
+			Message:   "@" + req.Username,
+			Props: mattermost.Props{
+				MessageKey:  msgKey,
+				MessageData: msgData,
+				Attachments: attachments,
+			},
+		})
+
+		// Thread reply on approval post so approvers see the withdrawal
+		s.mm.CreatePost(&mattermost.Post{
+			ChannelID: req.ApprovalChannelID,
+			RootID:    req.ApprovalPostID,
+			Message: i18n.T(ctx, "attendance.msg.leave_withdrawn", map[string]any{
+				"Username": req.Username,
+				"Dates":    formatDateList(dates),
+				"Reason":   reason,
+			}),
+		})
+
+		return nil
+	}
+
+	// Approved: an approver has to confirm the cancellation
+	req.PreviousStatus = req.Status
+	req.Status = model.LeaveStatusPendingCancel
+	req.CancelDates = dates
+	req.CancelReason = reason
+
+	if err := s.store.UpdateLeaveRequest(ctx, req); err != nil {
+		return fmt.Errorf("update leave request: %w", err)
+	}
+
+	idHex := req.ID.Hex()
+	cancelMsgKey := "leave.msg.cancel_leave"
+	cancelMsgData := leaveCancelMessageData(req, dates, reason, string(req.Status))
+
+	// Create info post in main channel (no buttons)
+	infoPost, err := s.mm.CreatePost(&mattermost.Post{
+		ChannelID: req.ChannelID,
+		Message:   "@" + req.Username,
+		Props: mattermost.Props{
+			MessageKey:  cancelMsgKey,
+			MessageData: cancelMsgData,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("post cancel info message: %w", err)
+	}
+
+	// Create approval post in approval channel (with buttons)
+	mention := s.delegations.ApprovalMention(ctx, model.DelegationScopeAttendance, req.ApprovalChannelID, approver)
+	cancelMsgData["Mention"] = mention
+	approvalPost, err := s.mm.CreatePost(&mattermost.Post{
+		ChannelID: req.ApprovalChannelID,
+		Message:   mention,
+		Props: mattermost.Props{
+			MessageKey:  cancelMsgKey,
+			MessageData: cancelMsgData,
+			Attachments: []mattermost.Attachment{{
+				Actions: []mattermost.Action{
+					{
+						Name: i18n.T(ctx, "attendance.btn.approve_cancel"),
+						Type: "button",
+						Integration: mattermost.Integration{
+							URL:     s.botURL + "/api/attendance/cancel-approve",
+							Context: map[string]any{"request_id": idHex},
+						},
+					},
+					{
+						Name: i18n.T(ctx, "attendance.btn.reject_cancel"),
+						Type: "button",
+						Integration: mattermost.Integration{
+							URL:     s.botURL + "/api/attendance/cancel-reject",
+							Context: map[string]any{"request_id": idHex},
+						},
+					},
+				},
+			}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("post cancel approval message: %w", err)
+	}
+
+	req.CancelPostID = infoPost.ID
+	req.CancelApprovalPostID = approvalPost.ID
+	return s.store.UpdateLeaveRequest(ctx, req)
+}
+
+func (s *AttendanceService) ApproveCancellation(ctx context.Context, requestID, approverID, approverUsername string) (*LeaveUpdateResult, error) {
+	id, err := bson.ObjectIDFromHex(requestID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request ID: %w", err)
+	}
+
+	req, err := s.store.GetLeaveRequestByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get leave request: %w", err)
+	}
+	if req == nil {
+		return nil, errors.New(i18n.T(ctx, "attendance.err.not_found"))
+	}
+	if req.Status != model.LeaveStatusPendingCancel {
+		return nil, errors.New(i18n.T(ctx, "attendance.err.not_pending_cancel"))
+	}
+	if err := s.timesheets.CheckEditable(ctx, req.TeamID, req.CancelDates); err != nil {
+		return nil, err
+	}
+
+	// Save cancel info before clearing
+	dates := req.CancelDates
+	reason := req.CancelReason
+
+	req.Status = req.PreviousStatus
+	req.PreviousStatus = ""
+	req.CancelDates = nil
+	req.CancelReason = ""
+	applyCancellation(req, dates, time.Now())
+
+	if err := s.store.UpdateLeaveRequest(ctx, req); err != nil {
+		return nil, fmt.Errorf("update leave request: %w", err)
+	}
+	if req.Status == model.LeaveStatusCancelled {
+		s.delegations.RevokeAuto(ctx, req.ID.Hex())
+	}
+	s.hooks.Emit(ctx, model.EventLeaveCancelled, map[string]any{
+		"leave_request":   req,
+		"cancelled_dates": dates,
+	})
+
+	// Keep the cancel message format, just update status
+	cancelMsgKey := "leave.msg.cancel_leave"
+	cancelMsgData := leaveCancelMessageData(req, dates, reason, string(model.LeaveStatusApproved))
+
+	s.mm.UpdatePost(req.CancelPostID, &mattermost.Post{
+		ChannelID: req.ChannelID,
+		Message:   "@" + req.Username,
+		Props: mattermost.Props{
+			MessageKey:  cancelMsgKey,
+			MessageData: cancelMsgData,
+		},
+	})
+
+	// Reflect the remaining dates (or cancelled status) on the original info and approval posts
+	msgKey := leaveMessageKey(req.Type)
+	msgData := leaveMessageData(req)
+	s.mm.UpdatePost(req.PostID, &mattermost.Post{
+		ChannelID: req.ChannelID,
+		Message:   "@" + req.Username,
+		Props: mattermost.Props{
+			MessageKey:  msgKey,
+			MessageData: msgData,
+		},
+	})
+	s.mm.UpdatePost(req.ApprovalPostID, &mattermost.Post{
+		ChannelID: req.ApprovalChannelID,
+		Message:   "@" + req.Username,
+		Props: mattermost.Props{
+			MessageKey:  msgKey,
+			MessageData: msgData,
+			Attachments: []mattermost.Attachment{},
+		},
+	})
+
+	// Thread reply on cancel post to notify requester
+	var onBehalfOf string
+	if d := s.delegations.OnBehalfOf(ctx, model.DelegationScopeAttendance, req.ApprovalChannelID, approverID); d != nil {
+		onBehalfOf = d.DelegatorUsername
+	}
+	replyKey, replyData := approvedReply("attendance.msg.cancel_approved", req.Username, approverUsername, onBehalfOf)
+	s.mm.CreatePost(&mattermost.Post{
+		ChannelID: req.ChannelID,
+		RootID:    req.CancelPostID,
+		Message:   "@" + req.Username,
+		Props: mattermost.Props{
+			MessageKey:  replyKey,
+			MessageData: replyData,
+		},
+	})
+
+	return &LeaveUpdateResult{MessageKey: cancelMsgKey, MessageData: cancelMsgData}, nil
+}
+
+func (s *AttendanceService) RejectCancellation(ctx context.Context, requestID, rejecterID, rejecterUsername, reason string) error {
+	id, err := bson.ObjectIDFromHex(requestID)
+	if err != nil {
+		return fmt.Errorf("invalid request ID: %w", err)
+	}
+
+	req, err := s.store.GetLeaveRequestByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("get leave request: %w", err)
+	}
+	if req == nil {
+		return errors.New(i18n.T(ctx, "attendance.err.not_found"))
+	}
+	if req.Status != model.LeaveStatusPendingCancel {
+		return errors.New(i18n.T(ctx, "attendance.err.not_pending_cancel"))
+	}
+
+	// Save cancel info before clearing
+	dates := req.CancelDates
+	cancelReason := req.CancelReason
+
+	// Restore previous status
+	req.Status = req.PreviousStatus
+	req.PreviousStatus = ""
+	req.CancelDates = nil
+	req.CancelReason = ""
+
+	if err := s.store.UpdateLeaveRequest(ctx, req); err != nil {
+		return fmt.Errorf("update leave request: %w", err)
+	}
+
+	cancelMsgKey := "leave.msg.cancel_leave"
+	cancelMsgData := leaveCancelMessageData(req, dates, cancelReason, string(model.LeaveStatusRejected))
+
+	s.mm.UpdatePost(req.CancelPostID, &mattermost.Post{
+		ChannelID: req.ChannelID,
+		Message:   "@" + req.Username,
+		Props: mattermost.Props{
+			MessageKey:  cancelMsgKey,
+			MessageData: cancelMsgData,
+		},
+	})
+
+	// Update cancel approval post (remove buttons)
+	s.mm.UpdatePost(req.CancelApprovalPostID, &mattermost.Post{
+		ChannelID: req.ApprovalChannelID,
+		Message:   "@" + req.Username,
+		Props: mattermost.Props{
+			MessageKey:  cancelMsgKey,
+			MessageData: cancelMsgData,
+			Attachments: []mattermost.Attachment{},
+		},
+	})
+
+	s.mm.CreatePost(&mattermost.Post{
+		ChannelID: req.ChannelID,
+		RootID:    req.CancelPostID,
+		Message:   "@" + req.Username,
+		Props: mattermost.Props{
+			MessageKey: "attendance.msg.cancel_rejected",
+			MessageData: map[string]any{
+				"Username": req.Username,
+				"Approver": rejecterUsername,
+				"Reason":   reason,
+			},
+		},
+	})
+
+	return nil
+}
+
+// applyCancellation removes dates from a request. Cancelling every remaining date cancels
+// the whole request; its dates are kept so it still shows up in reports as cancelled.
+func applyCancellation(req *model.LeaveRequest, dates []string, now time.Time) {
+	var remaining []string
+	for _, d := range req.Dates {
+		if !slices.Contains(dates, d) {
+			remaining = append(remaining, d)
+		}
+	}
+	req.CancelledAt = &now
+	if len(remaining) == 0 {
+		req.Status = model.LeaveStatusCancelled
+		return
+	}
+	req.Dates = remaining
+	req.CancelledDates = append(req.CancelledDates, dates...)
+}
+
+func leaveCancelMessageData(req *model.LeaveRequest, dates []string, cancelReason, status string) map[string]any {
+	return map[string]any{
+		"Username":     req.Username,
+		"LeaveType":    string(req.Type),
+		"Dates":        formatDateList(dates),
+		"Reason":       req.Reason,
+		"CancelReason": cancelReason,
+		"Status":       status,
+	}
+}
+
+// formatDateList joins YYYY-MM-DD dates for display.
+func formatDateList(dates []string) string {
+	display := make([]string, len(dates))
+	for i, d := range dates {
+		display[i] = model.FormatDateDisplay(d)
+	}
+	return strings.Join(display, ", ")
+}
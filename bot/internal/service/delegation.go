@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/store"
+)
+
+// delegationSweepEvery is how often delegates are removed from the approval channels of
+// delegations that have ended.
+const delegationSweepEvery = time.Hour
+
+// DelegationService manages approvers handing their approval authority to another
+// user while they are away. Delegates are added to the approval channels they are
+// mentioned in, so that they can act on the approval posts, and removed from them once
+// the delegation ends.
+type DelegationService struct {
+	store    store.DelegationStore
+	mm       *mattermost.Client
+	budgetMM *mattermost.Client
+}
+
+func NewDelegationService(store store.DelegationStore, mm, budgetMM *mattermost.Client) *DelegationService {
+	return &DelegationService{store: store, mm: mm, budgetMM: budgetMM}
+}
+
+// client returns the client of the bot that manages the approval channels of a scope.
+func (s *DelegationService) client(scope model.DelegationScope) *mattermost.Client {
+	if scope == model.DelegationScopeBudget {
+		return s.budgetMM
+	}
+	return s.mm
+}
+
+// CreateDelegation delegates the user's approvals in scope to delegateID from one date to another (inclusive).
+func (s *DelegationService) CreateDelegation(ctx context.Context, delegatorID, delegatorUsername, delegateID string, scope model.DelegationScope, from, to, reason string) (*model.Delegation, error) {
+	if delegateID == "" || delegateID == delegatorID {
+		return nil, errors.New(i18n.T(ctx, "delegation.err.invalid_delegate"))
+	}
+	switch scope {
+	case model.DelegationScopeAttendance, model.DelegationScopeBudget, model.DelegationScopeAll:
+	default:
+		return nil, errors.New(i18n.T(ctx, "delegation.err.invalid_scope"))
+	}
+	if err := validateDateList(ctx, []string{from, to}); err != nil {
+		return nil, err
+	}
+	if from > to {
+		return nil, errors.New(i18n.T(ctx, "delegation.err.invalid_range"))
+	}
+
+	if delegatorUsername == "" {
+		user, err := s.mm.GetUser(delegatorID)
+		if err != nil {
+			return nil, fmt.Errorf("get user info: %w", err)
+		}
+		delegatorUsername = user.Username
+	}
+	delegate, err := s.mm.GetUser(delegateID)
+	if err != nil {
+		return nil, fmt.Errorf("get delegate info: %w", err)
+	}
+
+	d := &model.Delegation{
+		DelegatorID:       delegatorID,
+		DelegatorUsername: delegatorUsername,
+		DelegateID:        delegateID,
+		DelegateUsername:  delegate.Username,
+		Scope:             scope,
+		From:              from,
+		To:                to,
+		Reason:            reason,
+	}
+	if err := s.store.Create(ctx, d); err != nil {
+		return nil, fmt.Errorf("create delegation: %w", err)
+	}
+	s.notifyDelegate(ctx, d)
+	return d, nil
+}
+
+// AutoDelegate hands the approvals of a requester who is an approver to the user who
+// approved their leave, for the span of that leave.
+func (s *DelegationService) AutoDelegate(ctx context.Context, req *model.LeaveRequest) {
+	if req.Type != model.LeaveTypeOff || req.ApproverID == "" || req.ApproverID == req.UserID || len(req.Dates) == 0 {
+		return
+	}
+
+	var scopes []model.DelegationScope
+	if s.isMember(req.ApprovalChannelID, req.UserID) {
+		scopes = append(scopes, model.DelegationScopeAttendance)
+	}
+	if ch, err := s.mm.GetChannel(req.ApprovalChannelID); err == nil {
+		suffix := strings.TrimPrefix(ch.Name, model.AttendanceApprovalChannel)
+		if budgetID, err := s.mm.GetChannelByName(req.TeamID, model.BudgetApprovalChannel+suffix); err == nil && s.isMember(budgetID, req.UserID) {
+			scopes = append(scopes, model.DelegationScopeBudget)
+		}
+	}
+	if len(scopes) == 0 {
+		return
+	}
+	scope := scopes[0]
+	if len(scopes) > 1 {
+		scope = model.DelegationScopeAll
+	}
+
+	d := &model.Delegation{
+		DelegatorID:       req.UserID,
+		DelegatorUsername: req.Username,
+		DelegateID:        req.ApproverID,
+		DelegateUsername:  req.ApproverUsername,
+		Scope:             scope,
+		From:              slices.Min(req.Dates),
+		To:                slices.Max(req.Dates),
+		Reason:            req.Reason,
+		Auto:              true,
+		LeaveRequestID:    req.ID.Hex(),
+	}
+	if err := s.store.Create(ctx, d); err != nil {
+		log.Printf("delegation: auto-delegate for %s: %v", req.Username, err)
+		return
+	}
+	s.notifyDelegate(ctx, d)
+}
+
+// RevokeAuto removes the delegations created automatically from a leave request.
+func (s *DelegationService) RevokeAuto(ctx context.Context, leaveRequestID string) {
+	deleted, err := s.store.DeleteByLeaveRequest(ctx, leaveRequestID)
+	if err != nil {
+		log.Printf("delegation: revoke for leave %s: %v", leaveRequestID, err)
+		return
+	}
+	for _, d := range deleted {
+		s.revokeAccess(ctx, d)
+	}
+}
+
+// Run removes delegates from approval channels as their delegations end, until ctx is
+// cancelled.
+func (s *DelegationService) Run(ctx context.Context) {
+	ticker := time.NewTicker(delegationSweepEvery)
+	defer ticker.Stop()
+
+	for {
+		s.revokeEnded(ctx)
+		select {
+		case <-ctx.Done():
+			log.Println("delegation sweeper stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *DelegationService) revokeEnded(ctx context.Context) {
+	ended, err := s.store.FindEndedWithChannels(ctx, vnToday())
+	if err != nil {
+		log.Printf("delegation: find ended: %v", err)
+		return
+	}
+	for _, d := range ended {
+		s.revokeAccess(ctx, d)
+	}
+}
+
+// grantAccess adds a delegate to an approval channel of their delegator so that they can
+// see its approval posts and act on them, and records the channel on the delegation. It
+// reports whether the delegate is now a member.
+func (s *DelegationService) grantAccess(ctx context.Context, scope model.DelegationScope, d *model.Delegation, channelID string) bool {
+	if err := s.client(scope).AddChannelMember(channelID, d.DelegateID); err != nil {
+		log.Printf("delegation: add @%s to approval channel %s: %v", d.DelegateUsername, channelID, err)
+		return false
+	}
+	ch := model.DelegationChannel{ChannelID: channelID, Scope: scope}
+	if err := s.store.AddChannel(ctx, d.ID, ch); err != nil {
+		log.Printf("delegation: record channel for @%s: %v", d.DelegateUsername, err)
+	}
+	d.AddedChannels = append(d.AddedChannels, ch)
+	return true
+}
+
+// revokeAccess removes a delegate from the approval channels they were added to for a
+// delegation. A channel still covered by another of their active delegations is handed
+// over to it instead.
+func (s *DelegationService) revokeAccess(ctx context.Context, d *model.Delegation) {
+	if len(d.AddedChannels) == 0 {
+		return
+	}
+	for _, ch := range d.AddedChannels {
+		if next := s.coveringDelegation(ctx, d, ch); next != nil {
+			if err := s.store.AddChannel(ctx, next.ID, ch); err != nil {
+				log.Printf("delegation: hand over channel for @%s: %v", d.DelegateUsername, err)
+				return
+			}
+			continue
+		}
+		if err := s.client(ch.Scope).RemoveChannelMember(ch.ChannelID, d.DelegateID); err != nil {
+			log.Printf("delegation: remove @%s from approval channel %s: %v", d.DelegateUsername, ch.ChannelID, err)
+			return // retried on the next sweep
+		}
+	}
+	if err := s.store.ClearChannels(ctx, d.ID); err != nil {
+		log.Printf("delegation: clear channels for @%s: %v", d.DelegateUsername, err)
+	}
+}
+
+// coveringDelegation returns another active delegation to the same delegate from a member
+// of the channel, or nil.
+func (s *DelegationService) coveringDelegation(ctx context.Context, d *model.Delegation, ch model.DelegationChannel) *model.Delegation {
+	active, err := s.store.FindActive(ctx, vnToday(), ch.Scope, "", d.DelegateID)
+	if err != nil || len(active) == 0 {
+		return nil
+	}
+	members, err := s.client(ch.Scope).GetChannelMembers(ch.ChannelID)
+	if err != nil {
+		return nil
+	}
+	for _, other := range active {
+		if other.ID != d.ID && slices.ContainsFunc(members, func(m mattermost.ChannelMember) bool { return m.UserID == other.DelegatorID }) {
+			return other
+		}
+	}
+	return nil
+}
+
+// ActiveByDelegator returns today's delegations in scope keyed by delegator user ID.
+func (s *DelegationService) ActiveByDelegator(ctx context.Context, scope model.DelegationScope) map[string]*model.Delegation {
+	active, err := s.store.FindActive(ctx, vnToday(), scope, "", "")
+	if err != nil {
+		log.Printf("delegation: find active: %v", err)
+		return nil
+	}
+	byDelegator := make(map[string]*model.Delegation, len(active))
+	for _, d := range active {
+		byDelegator[d.DelegatorID] = d
+	}
+	return byDelegator
+}
+
+// ApprovalMention returns who an approval post should mention. A chosen approver who
+// has delegated is replaced by their delegate; otherwise "@all" is extended with the
+// delegates of channel members. Delegates who aren't members of the approval channel are
+// added to it for the rest of the delegation; one who can't be added is not mentioned.
+func (s *DelegationService) ApprovalMention(ctx context.Context, scope model.DelegationScope, approvalChannelID, approver string) string {
+	date := vnToday()
+	members, membersErr := s.client(scope).GetChannelMembers(approvalChannelID)
+	if membersErr != nil {
+		log.Printf("delegation: get approval channel members: %v", membersErr)
+	}
+	inChannel := make(map[string]bool, len(members))
+	for _, m := range members {
+		inChannel[m.UserID] = true
+	}
+	// canSee reports whether the delegate of d can see the approval post, adding them to
+	// the channel when needed.
+	canSee := func(d *model.Delegation) bool {
+		return inChannel[d.DelegateID] || (membersErr == nil && s.grantAccess(ctx, scope, d, approvalChannelID))
+	}
+
+	if approver != "" {
+		d, err := s.store.FindActiveByDelegatorUsername(ctx, date, scope, approver)
+		if err != nil {
+			log.Printf("delegation: find for @%s: %v", approver, err)
+		}
+		if d != nil && canSee(d) {
+			return "@" + d.DelegateUsername
+		}
+		return "@" + approver
+	}
+
+	mention := "@all"
+	active, err := s.store.FindActive(ctx, date, scope, "", "")
+	if err != nil || len(active) == 0 {
+		return mention
+	}
+	mentioned := map[string]bool{}
+	for _, d := range active {
+		if inChannel[d.DelegatorID] && !inChannel[d.DelegateID] && !mentioned[d.DelegateID] && canSee(d) {
+			mentioned[d.DelegateID] = true
+			mention += " @" + d.DelegateUsername
+		}
+	}
+	return mention
+}
+
+// OnBehalfOf returns the delegation under which userID is acting when they approve in
+// the given approval channel: an active delegation to them from a member of the
+// channel. A delegate who is also an approver there is taken to act for the delegator
+// while the delegation lasts.
+func (s *DelegationService) OnBehalfOf(ctx context.Context, scope model.DelegationScope, approvalChannelID, userID string) *model.Delegation {
+	active, err := s.store.FindActive(ctx, vnToday(), scope, "", userID)
+	if err != nil {
+		log.Printf("delegation: find for delegate %s: %v", userID, err)
+		return nil
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	members, err := s.client(scope).GetChannelMembers(approvalChannelID)
+	if err != nil {
+		return nil
+	}
+	inChannel := make(map[string]bool, len(members))
+	for _, m := range members {
+		inChannel[m.UserID] = true
+	}
+	for _, d := range active {
+		if inChannel[d.DelegatorID] {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *DelegationService) notifyDelegate(ctx context.Context, d *model.Delegation) {
+	msg := i18n.T(ctx, "delegation.msg.assigned", map[string]any{
+		"Delegator": d.DelegatorUsername,
+		"Scope":     i18n.T(ctx, "delegation.scope."+string(d.Scope)),
+		"From":      model.FormatDateDisplay(d.From),
+		"To":        model.FormatDateDisplay(d.To),
+	})
+	if err := s.mm.SendDM(d.DelegateID, msg); err != nil {
+		log.Printf("delegation: notify @%s: %v", d.DelegateUsername, err)
+	}
+}
+
+func (s *DelegationService) isMember(channelID, userID string) bool {
+	members, err := s.mm.GetChannelMembers(channelID)
+	if err != nil {
+		return false
+	}
+	for _, m := range members {
+		if m.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func vnToday() string {
+	return time.Now().In(vnTZ).Format(time.DateOnly)
+}
+
+// recordApprover stores who acted on a leave request and, when they acted as a
+// delegate, on whose behalf.
+func (s *AttendanceService) recordApprover(ctx context.Context, req *model.LeaveRequest, approverID, approverUsername string) {
+	req.ApproverID = approverID
+	req.ApproverUsername = approverUsername
+	req.OnBehalfOfID = ""
+	req.OnBehalfOfUsername = ""
+	if d := s.delegations.OnBehalfOf(ctx, model.DelegationScopeAttendance, req.ApprovalChannelID, approverID); d != nil {
+		req.OnBehalfOfID = d.DelegatorID
+		req.OnBehalfOfUsername = d.DelegatorUsername
+	}
+}
+
+// approvedReply returns the thread reply key and data announcing an approval, naming
+// the delegator when the approver acted on their behalf.
+func approvedReply(key, username, approverUsername, onBehalfOf string) (string, map[string]any) {
+	data := map[string]any{
+		"Username": username,
+		"Approver": approverUsername,
+	}
+	if onBehalfOf != "" {
+		data["OnBehalfOf"] = onBehalfOf
+		return key + "_on_behalf", data
+	}
+	return key, data
+}
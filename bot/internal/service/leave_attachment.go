@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+)
+
+// AttachmentMode says whether a leave type takes supporting documents.
+type AttachmentMode string
+
+const (
+	AttachmentNone     AttachmentMode = "none"
+	AttachmentOptional AttachmentMode = "optional"
+	AttachmentRequired AttachmentMode = "required"
+)
+
+// attachmentLookbackDays is how far back a leave request can still receive documents,
+// e.g. a medical certificate handed in after the sick day.
+const attachmentLookbackDays = 30
+
+// LeaveAttachmentPolicy maps leave types to their attachment mode. Types not listed take
+// no attachments.
+type LeaveAttachmentPolicy map[model.LeaveType]AttachmentMode
+
+// Mode returns the attachment mode of a leave type.
+func (p LeaveAttachmentPolicy) Mode(leaveType model.LeaveType) AttachmentMode {
+	if m, ok := p[leaveType]; ok {
+		return m
+	}
+	return AttachmentNone
+}
+
+// ParseLeaveAttachmentPolicy parses a comma-separated list of type=mode pairs
+// (e.g. "off=required,late_arrival=optional").
+func ParseLeaveAttachmentPolicy(s string) (LeaveAttachmentPolicy, error) {
+	policy := LeaveAttachmentPolicy{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		leaveType, mode, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q: want type=mode", item)
+		}
+		lt := model.LeaveType(strings.TrimSpace(leaveType))
+		switch lt {
+		case model.LeaveTypeOff, model.LeaveTypeLateArrival, model.LeaveTypeEarlyDeparture,
+			model.LeaveTypeOvertime, model.LeaveTypeRemote, model.LeaveTypeBusinessTrip:
+		default:
+			return nil, fmt.Errorf("unknown leave type %q", lt)
+		}
+		m := AttachmentMode(strings.TrimSpace(mode))
+		switch m {
+		case AttachmentNone, AttachmentOptional, AttachmentRequired:
+		default:
+			return nil, fmt.Errorf("unknown attachment mode %q for %s", m, lt)
+		}
+		policy[lt] = m
+	}
+	return policy, nil
+}
+
+// AttachmentMode returns whether requests of a leave type take supporting documents.
+func (s *AttendanceService) AttachmentMode(leaveType model.LeaveType) AttachmentMode {
+	return s.attachments.Mode(leaveType)
+}
+
+// resolveAttachments checks that the files were uploaded by the user and returns them as
+// leave attachments.
+func (s *AttendanceService) resolveAttachments(ctx context.Context, userID string, fileIDs []string) ([]model.LeaveAttachment, error) {
+	var out []model.LeaveAttachment
+	now := time.Now()
+	for _, fileID := range fileIDs {
+		if fileID == "" {
+			continue
+		}
+		info, err := s.mm.GetFileInfo(fileID)
+		if err != nil {
+			return nil, fmt.Errorf("get attachment: %w", err)
+		}
+		if info.CreatorID != userID {
+			return nil, errors.New(i18n.T(ctx, "attendance.err.attachment_not_yours"))
+		}
+		out = append(out, model.LeaveAttachment{FileID: info.ID, Name: info.Name, AddedAt: now})
+	}
+	return out, nil
+}
+
+// checkAttachments enforces the attachment policy on a new request.
+func (s *AttendanceService) checkAttachments(ctx context.Context, req *model.LeaveRequest) error {
+	switch s.attachments.Mode(req.Type) {
+	case AttachmentNone:
+		if len(req.Attachments) > 0 {
+			return errors.New(i18n.T(ctx, "attendance.err.attachment_not_allowed"))
+		}
+	case AttachmentRequired:
+		if len(req.Attachments) == 0 {
+			return errors.New(i18n.T(ctx, "attendance.err.attachment_required"))
+		}
+	}
+	return nil
+}
+
+// GetUserAttachableLeaves returns the user's pending or approved requests of types that
+// take documents, with a date no older than attachmentLookbackDays.
+func (s *AttendanceService) GetUserAttachableLeaves(ctx context.Context, userID string) ([]model.LeaveRequest, error) {
+	from := time.Now().AddDate(0, 0, -attachmentLookbackDays).Format(time.DateOnly)
+	leaves, err := s.store.FindFutureLeaveRequestsByUser(ctx, userID, from)
+	if err != nil {
+		return nil, err
+	}
+	var out []model.LeaveRequest
+	for _, l := range leaves {
+		if s.attachments.Mode(l.Type) != AttachmentNone && len(l.Attachments) < model.MaxLeaveAttachments {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+// AddLeaveAttachment attaches a document to one of the user's leave requests after
+// submission. The request's posts show the new document and the approvers are told in the
+// approval thread.
+func (s *AttendanceService) AddLeaveAttachment(ctx context.Context, requestID, userID, fileID string) error {
+	id, err := bson.ObjectIDFromHex(requestID)
+	if err != nil {
+		return fmt.Errorf("invalid request ID: %w", err)
+	}
+	req, err := s.store.GetLeaveRequestByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("get leave request: %w", err)
+	}
+	if req == nil {
+		return errors.New(i18n.T(ctx, "attendance.err.not_found"))
+	}
+	if req.UserID != userID {
+		return errors.New(i18n.T(ctx, "attendance.err.attachment_not_owner"))
+	}
+	if req.Status != model.LeaveStatusPending && req.Status != model.LeaveStatusApproved {
+		return errors.New(i18n.T(ctx, "attendance.err.attachment_invalid_status", map[string]any{"Status": string(req.Status)}))
+	}
+	if s.attachments.Mode(req.Type) == AttachmentNone {
+		return errors.New(i18n.T(ctx, "attendance.err.attachment_not_allowed"))
+	}
+	if len(req.Attachments) >= model.MaxLeaveAttachments {
+		return errors.New(i18n.T(ctx, "attendance.err.attachment_limit", map[string]any{"Max": model.MaxLeaveAttachments}))
+	}
+	added, err := s.resolveAttachments(ctx, userID, []string{fileID})
+	if err != nil {
+		return err
+	}
+	if len(added) == 0 {
+		return errors.New(i18n.T(ctx, "attendance.err.attachment_missing"))
+	}
+
+	req.Attachments = append(req.Attachments, added...)
+	req.UpdatedAt = time.Now()
+	if err := s.store.UpdateLeaveRequest(ctx, req); err != nil {
+		return fmt.Errorf("update leave request: %w", err)
+	}
+
+	data := map[string]any{"Attachments": attachmentMessageData(req.Attachments)}
+	for _, postID := range []string{req.PostID, req.ApprovalPostID} {
+		if postID == "" {
+			continue
+		}
+		if err := s.mm.SetMessageData(postID, data); err != nil {
+			log.Printf("ERROR leave attachment: update post %s: %v", postID, err)
+		}
+	}
+	if req.ApprovalPostID != "" {
+		s.mm.CreatePost(&mattermost.Post{
+			ChannelID: req.ApprovalChannelID,
+			RootID:    req.ApprovalPostID,
+			Message:   "@" + req.Username,
+			Props: mattermost.Props{
+				MessageKey: "leave.msg.attachment_added",
+				MessageData: map[string]any{
+					"Username":    req.Username,
+					"Attachments": attachmentMessageData(added),
+				},
+			},
+		})
+	}
+	return nil
+}
+
+// attachmentMessageData lists attachments for a post's message data, which the webapp
+// renders as file links.
+func attachmentMessageData(attachments []model.LeaveAttachment) []map[string]string {
+	out := make([]map[string]string, len(attachments))
+	for i, a := range attachments {
+		out[i] = map[string]string{"FileID": a.FileID, "Name": a.Name}
+	}
+	return out
+}
+
+// deleteLeaveAttachments permanently deletes the files attached to a leave request.
+func deleteLeaveAttachments(mm *mattermost.Client, req *model.LeaveRequest) error {
+	for _, a := range req.Attachments {
+		if err := mm.DeleteFile(a.FileID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+)
+
+const (
+	OvertimeDayWeekday = "weekday"
+	OvertimeDayWeekend = "weekend"
+	OvertimeDayHoliday = "holiday"
+)
+
+// OvertimeConfig holds the work schedule and pay rates used to reconcile overtime.
+type OvertimeConfig struct {
+	WorkdayHours float64  // scheduled hours on a weekday; only work beyond this is overtime
+	Holidays     []string // YYYY-MM-DD; all work on these days is overtime
+	RateWeekday  float64
+	RateWeekend  float64
+	RateHoliday  float64
+}
+
+// dayType classifies a date as weekday, weekend or holiday.
+func (c OvertimeConfig) dayType(date string) string {
+	if slices.Contains(c.Holidays, date) {
+		return OvertimeDayHoliday
+	}
+	t, err := time.Parse(time.DateOnly, date)
+	if err == nil && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+		return OvertimeDayWeekend
+	}
+	return OvertimeDayWeekday
+}
+
+// rate returns the pay multiplier for a day type.
+func (c OvertimeConfig) rate(dayType string) float64 {
+	switch dayType {
+	case OvertimeDayHoliday:
+		return c.RateHoliday
+	case OvertimeDayWeekend:
+		return c.RateWeekend
+	default:
+		return c.RateWeekday
+	}
+}
+
+// CreateOvertimeRequest submits planned overtime for one date. It goes through the same
+// approval channel and buttons as leave requests.
+func (s *AttendanceService) CreateOvertimeRequest(ctx context.Context, userID, username, channelID, date, plannedHours string, compensatory bool, reason, approver string) error {
+	hours, err := strconv.ParseFloat(strings.TrimSpace(plannedHours), 64)
+	if err != nil || hours <= 0 || hours > 24 {
+		return errors.New(i18n.T(ctx, "attendance.err.invalid_overtime_hours", map[string]any{"Hours": plannedHours}))
+	}
+	return s.submitLeaveRequest(ctx, &model.LeaveRequest{
+		UserID:            userID,
+		Username:          username,
+		ChannelID:         channelID,
+		Type:              model.LeaveTypeOvertime,
+		Dates:             []string{date},
+		Reason:            reason,
+		PlannedHours:      hours,
+		CompensatoryLeave: compensatory,
+	}, approver)
+}
+
+// reconcileOvertime compares approved overtime for a completed attendance day with the
+// time actually worked beyond schedule. Credited time is capped at the planned hours.
+func (s *AttendanceService) reconcileOvertime(ctx context.Context, req *model.LeaveRequest, record *model.AttendanceRecord) {
+	if record == nil || record.CheckIn == nil || record.CheckOut == nil || req.ReconciledAt != nil {
+		return
+	}
+
+	var totalBreak time.Duration
+	for _, b := range record.Breaks {
+		if b.End != nil {
+			totalBreak += b.End.Sub(b.Start)
+		}
+	}
+	worked := record.CheckOut.Sub(*record.CheckIn) - totalBreak
+
+	dayType := s.overtime.dayType(record.Date)
+	actual := worked
+	if dayType == OvertimeDayWeekday {
+		actual -= time.Duration(s.overtime.WorkdayHours * float64(time.Hour))
+	}
+	actualMin := max(int(actual.Minutes()), 0)
+	plannedMin := int(math.Round(req.PlannedHours * 60))
+
+	now := time.Now()
+	req.ActualOvertimeMinutes = actualMin
+	req.CreditedOvertimeMinutes = min(actualMin, plannedMin)
+	req.OvertimeDayType = dayType
+	req.ReconciledAt = &now
+	if err := s.store.UpdateLeaveRequest(ctx, req); err != nil {
+		log.Printf("overtime: update request %s: %v", req.ID.Hex(), err)
+		return
+	}
+
+	msg := i18n.T(ctx, "attendance.msg.overtime_reconciled", map[string]any{
+		"Username": req.Username,
+		"Date":     model.FormatDateDisplay(record.Date),
+		"Planned":  formatDuration(ctx, time.Duration(plannedMin)*time.Minute),
+		"Actual":   formatDuration(ctx, time.Duration(actualMin)*time.Minute),
+		"Credited": formatDuration(ctx, time.Duration(req.CreditedOvertimeMinutes)*time.Minute),
+		"DayType":  i18n.T(ctx, "attendance.overtime_day."+dayType),
+		"Rate":     fmt.Sprintf("x%g", s.overtime.rate(dayType)),
+	})
+	if req.CompensatoryLeave && req.CreditedOvertimeMinutes > 0 {
+		msg += "\n" + i18n.T(ctx, "attendance.msg.overtime_comp_time", map[string]any{
+			"Credited": formatDuration(ctx, time.Duration(req.CreditedOvertimeMinutes)*time.Minute),
+		})
+	}
+	s.mm.CreatePost(&mattermost.Post{
+		ChannelID: req.ChannelID,
+		RootID:    req.PostID,
+		Message:   msg,
+	})
+}
+
+// reconcileOvertimeForRecord reconciles the user's approved overtime for a just-completed day, if any.
+func (s *AttendanceService) reconcileOvertimeForRecord(ctx context.Context, record *model.AttendanceRecord) {
+	req, err := s.store.GetApprovedOvertime(ctx, record.UserID, record.Date)
+	if err != nil {
+		log.Printf("overtime: find request for %s on %s: %v", record.Username, record.Date, err)
+		return
+	}
+	if req != nil {
+		s.reconcileOvertime(ctx, req, record)
+	}
+}
+
+// OvertimeTotals sums credited overtime minutes by day type for a report.
+type OvertimeTotals struct {
+	WeekdayMinutes  int `json:"weekday_minutes"`
+	WeekendMinutes  int `json:"weekend_minutes"`
+	HolidayMinutes  int `json:"holiday_minutes"`
+	WeightedMinutes int `json:"weighted_minutes"`  // paid overtime multiplied by the day type rate
+	CompTimeMinutes int `json:"comp_time_minutes"` // overtime converted to compensatory leave instead of pay
+}
+
+// add accumulates one reconciled overtime request.
+func (t *OvertimeTotals) add(cfg OvertimeConfig, req *model.LeaveRequest) {
+	minutes := req.CreditedOvertimeMinutes
+	switch req.OvertimeDayType {
+	case OvertimeDayHoliday:
+		t.HolidayMinutes += minutes
+	case OvertimeDayWeekend:
+		t.WeekendMinutes += minutes
+	default:
+		t.WeekdayMinutes += minutes
+	}
+	if req.CompensatoryLeave {
+		t.CompTimeMinutes += minutes
+		return
+	}
+	t.WeightedMinutes += int(math.Round(float64(minutes) * cfg.rate(req.OvertimeDayType)))
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/photo"
+)
+
+// PhotoCheckConfig controls duplicate and stale photo detection on check-in and check-out.
+type PhotoCheckConfig struct {
+	Enabled          bool
+	RejectDuplicates bool          // reject the submission instead of only flagging it
+	MaxDistance      int           // max differing hash bits to treat two photos as the same
+	LookbackDays     int           // how many days of the user's photos to compare against
+	MaxAge           time.Duration // flag photos whose EXIF capture time is older than this
+}
+
+// Uploaded photos larger than this are not inspected.
+const maxPhotoCheckSize = 20 << 20
+
+// photoCheckResult is the outcome of inspecting one uploaded photo.
+type photoCheckResult struct {
+	Hash  string
+	Flags []model.PhotoFlag
+}
+
+// inspectPhoto downloads an uploaded photo, hashes it and compares it with the user's
+// recent photos. On check-out, current is the record being checked out of: its check-in
+// photo is compared too, only the check-out photo the upload replaces is skipped.
+// Download or decode failures are logged and never block attendance.
+func (s *AttendanceService) inspectPhoto(ctx context.Context, userID, action, fileID string, submittedAt time.Time, current *model.AttendanceRecord) (*photoCheckResult, error) {
+	result := &photoCheckResult{}
+	if !s.photoCheck.Enabled || fileID == "" {
+		return result, nil
+	}
+
+	data, err := s.mm.GetFile(fileID, maxPhotoCheckSize)
+	if err != nil {
+		log.Printf("photo check: download %s: %v", fileID, err)
+		return result, nil
+	}
+	info, err := photo.Analyze(data, vnTZ)
+	if err != nil {
+		log.Printf("photo check: analyze %s: %v", fileID, err)
+		return result, nil
+	}
+	result.Hash = info.Hash
+
+	from := submittedAt.In(vnTZ).AddDate(0, 0, -s.photoCheck.LookbackDays).Format(time.DateOnly)
+	recent, err := s.store.GetPhotoRecordsSince(ctx, userID, from)
+	if err != nil {
+		log.Printf("photo check: load recent photos for %s: %v", userID, err)
+		recent = nil
+	}
+
+	var best *model.PhotoFlag
+	for _, rec := range recent {
+		hashes := []string{rec.CheckInPhotoHash, rec.CheckOutPhotoHash}
+		if current != nil && rec.ID == current.ID {
+			hashes = hashes[:1]
+		}
+		for _, h := range hashes {
+			if h == "" {
+				continue
+			}
+			d := photo.Distance(info.Hash, h)
+			if d < 0 || d > s.photoCheck.MaxDistance {
+				continue
+			}
+			if best == nil || d < best.Distance {
+				best = &model.PhotoFlag{
+					Type:        model.PhotoFlagDuplicate,
+					Action:      action,
+					FileID:      fileID,
+					MatchedDate: rec.Date,
+					Distance:    d,
+				}
+			}
+		}
+	}
+	if best != nil {
+		if s.photoCheck.RejectDuplicates {
+			return nil, errors.New(i18n.T(ctx, "attendance.err.photo_duplicate", map[string]any{
+				"Date": model.FormatDateDisplay(best.MatchedDate),
+			}))
+		}
+		result.Flags = append(result.Flags, *best)
+	}
+
+	if info.TakenAt != nil && s.photoCheck.MaxAge > 0 && submittedAt.Sub(*info.TakenAt) > s.photoCheck.MaxAge {
+		result.Flags = append(result.Flags, model.PhotoFlag{
+			Type:    model.PhotoFlagStale,
+			Action:  action,
+			FileID:  fileID,
+			TakenAt: info.TakenAt,
+		})
+	}
+
+	return result, nil
+}
+
+// reportPhotoFlags posts each flag raised for a record to the team's approval channel.
+func (s *AttendanceService) reportPhotoFlags(ctx context.Context, record *model.AttendanceRecord, flags []model.PhotoFlag) {
+	if len(flags) == 0 {
+		return
+	}
+	channelInfo, err := s.mm.GetChannel(record.ChannelID)
+	if err != nil {
+		log.Printf("photo check: get channel %s: %v", record.ChannelID, err)
+		return
+	}
+	approvalChannelID, err := s.approvalChannelID(channelInfo)
+	if err != nil {
+		log.Printf("photo check: %v", err)
+		return
+	}
+
+	for _, f := range flags {
+		data := map[string]any{
+			"Username": record.Username,
+			"Action":   i18n.T(ctx, "attendance.photo_action."+f.Action),
+			"Date":     model.FormatDateDisplay(record.Date),
+		}
+		msgID := "attendance.msg.photo_flag_duplicate"
+		switch f.Type {
+		case model.PhotoFlagDuplicate:
+			data["MatchedDate"] = model.FormatDateDisplay(f.MatchedDate)
+			data["Distance"] = f.Distance
+		case model.PhotoFlagStale:
+			msgID = "attendance.msg.photo_flag_stale"
+			data["TakenAt"] = f.TakenAt.In(vnTZ).Format("02/01/2006 15:04")
+		}
+		if _, err := s.mm.CreatePost(&mattermost.Post{
+			ChannelID: approvalChannelID,
+			Message:   i18n.T(ctx, msgID, data) + "\n\n![photo](/api/v4/files/" + f.FileID + "/preview)",
+		}); err != nil {
+			log.Printf("photo check: post flag for %s: %v", record.Username, err)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/store"
+)
+
+// photoPurgeBatch is how many records the photo purge loads at a time.
+const photoPurgeBatch = 200
+
+// RetentionService applies data retention policies to attendance data and exports or erases
+// a single user's data. Like the server's data retention job, a global policy covers every
+// team without a policy of its own.
+type RetentionService struct {
+	store    store.RetentionStore
+	mm       *mattermost.Client
+	policies []model.RetentionPolicy
+}
+
+func NewRetentionService(store store.RetentionStore, mm *mattermost.Client, policies []model.RetentionPolicy) *RetentionService {
+	return &RetentionService{store: store, mm: mm, policies: policies}
+}
+
+// ParseRetentionPolicies parses the RETENTION_POLICIES setting: a JSON array of policies,
+// at most one without a team ID (the global policy) and at most one per team.
+func ParseRetentionPolicies(raw string) ([]model.RetentionPolicy, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var policies []model.RetentionPolicy
+	if err := json.Unmarshal([]byte(raw), &policies); err != nil {
+		return nil, fmt.Errorf("parse retention policies: %w", err)
+	}
+	seen := map[string]bool{}
+	for _, p := range policies {
+		if seen[p.TeamID] {
+			if p.TeamID == "" {
+				return nil, errors.New("more than one global retention policy")
+			}
+			return nil, fmt.Errorf("more than one retention policy for team %s", p.TeamID)
+		}
+		seen[p.TeamID] = true
+		if p.PhotoDays < 0 || p.DeviceDays < 0 || p.RecordYears < 0 {
+			return nil, errors.New("retention periods must not be negative")
+		}
+	}
+	return policies, nil
+}
+
+// Enabled reports whether any policy deletes anything. Safe to call on a nil service.
+func (s *RetentionService) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	for _, p := range s.policies {
+		if p.PhotoDays > 0 || p.DeviceDays > 0 || p.RecordYears > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// scope returns the teams a policy applies to.
+func (s *RetentionService) scope(p model.RetentionPolicy) store.RetentionScope {
+	if p.TeamID != "" {
+		return store.RetentionScope{TeamID: p.TeamID}
+	}
+	var teams []string
+	for _, other := range s.policies {
+		if other.TeamID != "" {
+			teams = append(teams, other.TeamID)
+		}
+	}
+	return store.RetentionScope{ExcludeTeamIDs: teams}
+}
+
+// Run applies every policy once for the day of now. It returns nil without doing anything
+// if a run for that day was already started, e.g. by another replica.
+func (s *RetentionService) Run(ctx context.Context, now time.Time) (*model.RetentionRun, error) {
+	today := now.In(vnTZ)
+	run := &model.RetentionRun{Date: today.Format(time.DateOnly), StartedAt: time.Now()}
+	claimed, err := s.store.ClaimRun(ctx, run)
+	if err != nil || !claimed {
+		return nil, err
+	}
+
+	runErr := s.applyPolicies(ctx, today, run)
+	finished := time.Now()
+	run.FinishedAt = &finished
+	if runErr != nil {
+		run.Error = runErr.Error()
+	}
+	if err := s.store.FinishRun(ctx, run); err != nil {
+		log.Printf("ERROR retention: save run: %v", err)
+	}
+	return run, runErr
+}
+
+func (s *RetentionService) applyPolicies(ctx context.Context, today time.Time, run *model.RetentionRun) error {
+	for _, p := range s.policies {
+		sc := s.scope(p)
+		if p.PhotoDays > 0 {
+			n, err := s.purgePhotos(ctx, sc, today.AddDate(0, 0, -p.PhotoDays).Format(time.DateOnly))
+			run.PhotosPurged += n
+			if err != nil {
+				return fmt.Errorf("purge photos: %w", err)
+			}
+		}
+		if p.DeviceDays > 0 {
+			n, err := s.store.AnonymizeDevices(ctx, sc, today.AddDate(0, 0, -p.DeviceDays).Format(time.DateOnly))
+			run.DevicesAnonymized += n
+			if err != nil {
+				return err
+			}
+		}
+		if p.RecordYears > 0 {
+			records, leave, err := s.deleteRecords(ctx, sc, today.AddDate(-p.RecordYears, 0, 0))
+			run.RecordsDeleted += records
+			run.LeaveDeleted += leave
+			if err != nil {
+				return fmt.Errorf("delete records: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// purgePhotos deletes the photo files of records dated before the given date, removes them
+// from the check-in and check-out posts and clears the record's references. A record whose
+// file cannot be deleted is left for the next run.
+func (s *RetentionService) purgePhotos(ctx context.Context, sc store.RetentionScope, before string) (int, error) {
+	purged := 0
+	var after bson.ObjectID
+	for {
+		records, err := s.store.RecordsWithPhotos(ctx, sc, before, after, photoPurgeBatch)
+		if err != nil {
+			return purged, err
+		}
+		if len(records) == 0 {
+			return purged, nil
+		}
+		for _, rec := range records {
+			after = rec.ID
+			if err := s.purgeRecordPhotos(ctx, rec); err != nil {
+				log.Printf("ERROR retention: purge photos of %s %s: %v", rec.Username, rec.Date, err)
+				continue
+			}
+			purged++
+		}
+	}
+}
+
+func (s *RetentionService) purgeRecordPhotos(ctx context.Context, rec *model.AttendanceRecord) error {
+	for _, fileID := range []string{rec.CheckInImageID, rec.CheckOutImageID} {
+		if fileID == "" {
+			continue
+		}
+		if err := s.mm.DeleteFile(fileID); err != nil {
+			return err
+		}
+	}
+	for _, postID := range []string{rec.PostID, rec.CheckOutPostID} {
+		if postID == "" {
+			continue
+		}
+		if err := s.mm.RemoveMessageData(postID, "FileID"); err != nil {
+			return err
+		}
+	}
+	return s.store.ClearPhotos(ctx, rec)
+}
+
+// deleteRecords deletes attendance records and leave requests, with their attached
+// documents, from months that ended before cutoff, oldest month first, after saving each
+// month's per-user summary.
+func (s *RetentionService) deleteRecords(ctx context.Context, sc store.RetentionScope, cutoff time.Time) (int64, int64, error) {
+	end := time.Date(cutoff.Year(), cutoff.Month(), 1, 0, 0, 0, 0, vnTZ)
+	oldest, err := s.store.OldestDate(ctx, sc, end.Format(time.DateOnly))
+	if err != nil || oldest == "" {
+		return 0, 0, err
+	}
+	month, err := time.ParseInLocation(time.DateOnly, oldest, vnTZ)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parse date %q: %w", oldest, err)
+	}
+	month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, vnTZ)
+
+	var records, leave int64
+	for ; month.Before(end); month = month.AddDate(0, 1, 0) {
+		from, to := month.Format(time.DateOnly), month.AddDate(0, 1, 0).Format(time.DateOnly)
+		recs, reqs, err := s.store.PeriodData(ctx, sc, from, to)
+		if err != nil {
+			return records, leave, err
+		}
+		if err := s.store.SaveSummaries(ctx, summarizeMonth(month.Format("2006-01"), from, to, recs, reqs)); err != nil {
+			return records, leave, err
+		}
+		for _, req := range reqs {
+			// Requests with later dates are only trimmed and keep their documents
+			if len(req.Attachments) > 0 && slices.Max(req.Dates) < to {
+				if err := deleteLeaveAttachments(s.mm, req); err != nil {
+					return records, leave, fmt.Errorf("delete attachments of %s: %w", req.ID.Hex(), err)
+				}
+			}
+		}
+		r, l, err := s.store.DeleteBefore(ctx, sc, to)
+		records += r
+		leave += l
+		if err != nil {
+			return records, leave, err
+		}
+	}
+	return records, leave, nil
+}
+
+// summarizeMonth builds the per-user summaries of one month from its attendance records
+// and the leave requests with dates in [from, to).
+func summarizeMonth(month, from, to string, records []*model.AttendanceRecord, leave []*model.LeaveRequest) []*model.AttendanceSummary {
+	byUser := map[[2]string]*model.AttendanceSummary{}
+	get := func(userID, teamID string) *model.AttendanceSummary {
+		key := [2]string{userID, teamID}
+		if sum, ok := byUser[key]; ok {
+			return sum
+		}
+		sum := &model.AttendanceSummary{UserID: userID, TeamID: teamID, Month: month}
+		byUser[key] = sum
+		return sum
+	}
+
+	for _, rec := range records {
+		if rec.CheckIn == nil {
+			continue
+		}
+		sum := get(rec.UserID, rec.TeamID)
+		sum.DaysWorked++
+		if sum.ModeDays == nil {
+			sum.ModeDays = map[string]int{}
+		}
+		sum.ModeDays[string(recordMode(rec))]++
+
+		var breaks time.Duration
+		for _, b := range rec.Breaks {
+			if b.End != nil {
+				breaks += b.End.Sub(b.Start)
+			}
+		}
+		sum.BreakMinutes += int(breaks.Minutes())
+		if rec.CheckOut != nil {
+			sum.WorkMinutes += int((rec.CheckOut.Sub(*rec.CheckIn) - breaks).Minutes())
+		}
+	}
+
+	for _, req := range leave {
+		if req.Status != model.LeaveStatusApproved && req.Status != model.LeaveStatusPendingCancel {
+			continue
+		}
+		for _, d := range req.Dates {
+			if d < from || d >= to {
+				continue
+			}
+			sum := get(req.UserID, req.TeamID)
+			if sum.LeaveDays == nil {
+				sum.LeaveDays = map[string]int{}
+			}
+			sum.LeaveDays[string(req.Type)]++
+		}
+	}
+
+	out := make([]*model.AttendanceSummary, 0, len(byUser))
+	for _, sum := range byUser {
+		out = append(out, sum)
+	}
+	return out
+}
+
+// UserExport is the "export my data" document for one user.
+type UserExport struct {
+	UserID     string    `json:"user_id"`
+	Username   string    `json:"username"`
+	ExportedAt time.Time `json:"exported_at"`
+	*store.UserData
+}
+
+// Export returns everything the bot stores about a user as an indented JSON file, with the
+// file's name.
+func (s *RetentionService) Export(ctx context.Context, user *mattermost.UserInfo) (string, []byte, error) {
+	data, err := s.store.GetUserData(ctx, user.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	body, err := json.MarshalIndent(UserExport{
+		UserID:     user.ID,
+		Username:   user.Username,
+		ExportedAt: now,
+		UserData:   data,
+	}, "", "  ")
+	if err != nil {
+		return "", nil, fmt.Errorf("marshal export: %w", err)
+	}
+	return fmt.Sprintf("bot-data-%s-%s.json", user.Username, now.In(vnTZ).Format(time.DateOnly)), body, nil
+}
+
+// EraseResult is what Erase removed.
+type EraseResult struct {
+	store.UserDataCounts
+	Photos int
+}
+
+// Erase deletes everything the bot stores about a user, including photo files and leave
+// documents, for offboarding. Budget requests and timesheets are business and payroll
+// records: they are kept, with the user's ID and name removed.
+func (s *RetentionService) Erase(ctx context.Context, userID string) (*EraseResult, error) {
+	data, err := s.store.GetUserData(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	result := &EraseResult{}
+	for _, rec := range data.Attendance {
+		if rec.CheckInImageID == "" && rec.CheckOutImageID == "" {
+			continue
+		}
+		if err := s.purgeRecordPhotos(ctx, rec); err != nil {
+			return result, fmt.Errorf("purge photos of %s: %w", rec.Date, err)
+		}
+		result.Photos++
+	}
+	for _, req := range data.Leave {
+		if err := deleteLeaveAttachments(s.mm, req); err != nil {
+			return result, fmt.Errorf("delete attachments of leave request %s: %w", req.ID.Hex(), err)
+		}
+	}
+	counts, err := s.store.DeleteUserData(ctx, userID)
+	if counts != nil {
+		result.UserDataCounts = *counts
+	}
+	return result, err
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/mattermost"
+	"oktel-bot/internal/model"
+)
+
+// validSetupName matches team names, budget suffixes and partner names usable in channel names.
+var validSetupName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// SetupService creates the channels and slash commands that follow the channel naming
+// convention, and reports where a team has drifted from it.
+type SetupService struct {
+	attendanceMM     *mattermost.Client
+	budgetMM         *mattermost.Client
+	botURL           string
+	registerCommands bool
+}
+
+// NewSetupService creates a setup service. With registerCommands off (the plugin registers
+// its own commands) slash commands are neither registered nor checked.
+func NewSetupService(attendanceMM, budgetMM *mattermost.Client, botURL string, registerCommands bool) *SetupService {
+	return &SetupService{attendanceMM: attendanceMM, budgetMM: budgetMM, botURL: botURL, registerCommands: registerCommands}
+}
+
+// channelSpec is a channel one of the bots posts to.
+type channelSpec struct {
+	name        string
+	displayName string
+	private     bool
+	howToKey    string // i18n key of the pinned how-to post, also its message_key
+}
+
+// commandSpec is a slash command served by the standalone bot.
+type commandSpec struct {
+	trigger     string
+	displayName string
+	desc        string
+	hint        string
+	path        string
+}
+
+// setupPlan is everything one bot needs in a team.
+type setupPlan struct {
+	mm       *mattermost.Client
+	channels []channelSpec
+	commands []commandSpec
+}
+
+// SetupCheck is the state of one channel or slash command: what Setup changed and the
+// drift that is left to fix by hand. Done and Problems hold i18n keys.
+type SetupCheck struct {
+	Name     string // "~channel-name" or "/trigger"
+	Done     []string
+	Problems []string
+	Err      error
+}
+
+// OK reports whether nothing is wrong.
+func (c *SetupCheck) OK() bool {
+	return c.Err == nil && len(c.Problems) == 0
+}
+
+// ErrInvalidSetupName is returned for a team, suffix or partner that cannot be part of a
+// channel name.
+var ErrInvalidSetupName = errors.New("invalid name")
+
+func normalizeSetupName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "-"))
+	if !validSetupName.MatchString(name) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidSetupName, name)
+	}
+	return name, nil
+}
+
+// attendancePlan returns the attendance channels of a team name, e.g. "dev" →
+// attendance-dev and attendance-approval-dev.
+func (s *SetupService) attendancePlan(team string) (*setupPlan, error) {
+	team, err := normalizeSetupName(team)
+	if err != nil {
+		return nil, err
+	}
+	suffix := "-" + team
+	return &setupPlan{
+		mm: s.attendanceMM,
+		channels: []channelSpec{
+			{model.AttendanceChannel + suffix, "Attendance " + team, false, "admin.howto.attendance"},
+			{model.AttendanceApprovalChannel + suffix, "Attendance Approval " + team, true, "admin.howto.attendance_approval"},
+		},
+		commands: []commandSpec{
+			{"diemdanh", "Attendance", "Check in, check out or take a break", "", "/api/diemdanh"},
+			{"xinphep", "Leave Request", "Request leave, late arrival, early departure or overtime", "[calendar [week|month] | pending]", "/api/xinphep"},
+			{"botadmin", "Bot Administration", "Export or erase a user's data, set up channels, team settings", "export [@user] | erase @user | setup | verify | config | breaks", "/api/botadmin"},
+		},
+	}, nil
+}
+
+// budgetPlan returns the budget channels of a suffix and its partners, e.g. "dev" and
+// "facebook" → budget-sale-dev, budget-tlqc-dev, budget-approval-dev, budget-finance-dev and
+// budget-partner-facebook-dev.
+func (s *SetupService) budgetPlan(suffix string, partners []string) (*setupPlan, error) {
+	name, err := normalizeSetupName(suffix)
+	if err != nil {
+		return nil, err
+	}
+	suffix = "-" + name
+	plan := &setupPlan{
+		mm: s.budgetMM,
+		channels: []channelSpec{
+			{model.BudgetSaleChannel + suffix, "Budget Sale " + name, true, "admin.howto.budget_sale"},
+			{model.BudgetTLQCChannel + suffix, "Budget TLQC " + name, true, "admin.howto.budget_tlqc"},
+			{model.BudgetApprovalChannel + suffix, "Budget Approval " + name, true, "admin.howto.budget_approval"},
+			{model.BudgetFinanceChannel + suffix, "Budget Finance " + name, true, "admin.howto.budget_finance"},
+		},
+		commands: []commandSpec{
+			{"budget", "Budget Request", "Create a budget request", "", "/api/budget"},
+		},
+	}
+	for _, p := range partners {
+		partner, err := normalizeSetupName(p)
+		if err != nil {
+			return nil, err
+		}
+		plan.channels = append(plan.channels, channelSpec{
+			model.PartnerChannelName(partner) + suffix, "Budget Partner " + partner + " " + name, true, "admin.howto.budget_partner",
+		})
+	}
+	return plan, nil
+}
+
+// SetupAttendance creates whatever is missing for attendance in a team: the channels, the
+// bot's membership, the pinned how-to posts and the slash commands.
+func (s *SetupService) SetupAttendance(ctx context.Context, teamID, team string) ([]SetupCheck, error) {
+	plan, err := s.attendancePlan(team)
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(ctx, teamID, plan, true), nil
+}
+
+// VerifyAttendance reports how a team's attendance channels and commands differ from what
+// SetupAttendance would create, without changing anything.
+func (s *SetupService) VerifyAttendance(ctx context.Context, teamID, team string) ([]SetupCheck, error) {
+	plan, err := s.attendancePlan(team)
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(ctx, teamID, plan, false), nil
+}
+
+// SetupBudget creates whatever is missing for the budget workflow of a channel suffix and
+// its partners.
+func (s *SetupService) SetupBudget(ctx context.Context, teamID, suffix string, partners []string) ([]SetupCheck, error) {
+	plan, err := s.budgetPlan(suffix, partners)
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(ctx, teamID, plan, true), nil
+}
+
+// VerifyBudget reports how the budget channels of a suffix and its partners differ from
+// what SetupBudget would create, without changing anything.
+func (s *SetupService) VerifyBudget(ctx context.Context, teamID, suffix string, partners []string) ([]SetupCheck, error) {
+	plan, err := s.budgetPlan(suffix, partners)
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(ctx, teamID, plan, false), nil
+}
+
+// apply checks every channel and command of a plan, fixing what it can when fix is set.
+func (s *SetupService) apply(ctx context.Context, teamID string, plan *setupPlan, fix bool) []SetupCheck {
+	checks := make([]SetupCheck, 0, len(plan.channels)+len(plan.commands))
+	for _, spec := range plan.channels {
+		checks = append(checks, s.checkChannel(ctx, plan.mm, teamID, spec, fix))
+	}
+	if !s.registerCommands {
+		return checks
+	}
+	existing, err := plan.mm.ListCommands(teamID)
+	for _, spec := range plan.commands {
+		if err != nil {
+			checks = append(checks, SetupCheck{Name: "/" + spec.trigger, Err: err})
+			continue
+		}
+		checks = append(checks, s.checkCommand(plan.mm, teamID, spec, existing, fix))
+	}
+	return checks
+}
+
+func (s *SetupService) checkChannel(ctx context.Context, mm *mattermost.Client, teamID string, spec channelSpec, fix bool) SetupCheck {
+	check := SetupCheck{Name: "~" + spec.name}
+
+	info, err := mm.GetChannelInfoByName(teamID, spec.name)
+	if errors.Is(err, mattermost.ErrNotFound) {
+		if !fix {
+			check.Problems = append(check.Problems, "admin.drift.missing")
+			return check
+		}
+		// A private channel the bot is not in also looks missing; creating it then fails
+		// because the name is taken, and the error says so.
+		info, err = mm.CreateChannel(teamID, spec.name, spec.displayName, spec.private)
+		if err != nil {
+			check.Err = err
+			return check
+		}
+		check.Done = append(check.Done, "admin.setup.created")
+	} else if err != nil {
+		check.Err = err
+		return check
+	}
+
+	if spec.private && info.Type == mattermost.ChannelTypeOpen {
+		check.Problems = append(check.Problems, "admin.drift.public")
+	}
+
+	member, err := mm.IsChannelMember(info.ID)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	if !member {
+		if !fix {
+			check.Problems = append(check.Problems, "admin.drift.not_member")
+		} else if err := mm.JoinChannel(info.ID); err != nil {
+			check.Err = err
+			return check
+		} else {
+			check.Done = append(check.Done, "admin.setup.joined")
+		}
+	}
+
+	pinned, err := mm.HasPinnedPost(info.ID, spec.howToKey)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	if pinned {
+		return check
+	}
+	if !fix {
+		check.Problems = append(check.Problems, "admin.drift.not_pinned")
+		return check
+	}
+	post, err := mm.CreatePost(&mattermost.Post{
+		ChannelID: info.ID,
+		Message:   i18n.T(ctx, spec.howToKey),
+		Props:     mattermost.Props{MessageKey: spec.howToKey},
+	})
+	if err == nil {
+		err = mm.PinPost(post.ID)
+	}
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	check.Done = append(check.Done, "admin.setup.pinned")
+	return check
+}
+
+func (s *SetupService) checkCommand(mm *mattermost.Client, teamID string, spec commandSpec, existing []mattermost.Command, fix bool) SetupCheck {
+	check := SetupCheck{Name: "/" + spec.trigger}
+	url := s.botURL + spec.path
+	for _, cmd := range existing {
+		if cmd.Trigger != spec.trigger {
+			continue
+		}
+		if cmd.URL != url {
+			check.Problems = append(check.Problems, "admin.drift.command_url")
+		}
+		return check
+	}
+	if !fix {
+		check.Problems = append(check.Problems, "admin.drift.command_missing")
+		return check
+	}
+	err := mm.CreateCommand(&mattermost.Command{
+		TeamID:           teamID,
+		Trigger:          spec.trigger,
+		Method:           "P",
+		URL:              url,
+		DisplayName:      spec.displayName,
+		AutoComplete:     true,
+		AutoCompleteDesc: spec.desc,
+		AutoCompleteHint: spec.hint,
+	})
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	check.Done = append(check.Done, "admin.setup.registered")
+	return check
+}
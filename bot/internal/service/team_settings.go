@@ -0,0 +1,351 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/store"
+)
+
+const (
+	// teamSettingsPoll is how often settings changed by another replica are picked up.
+	teamSettingsPoll = 30 * time.Second
+	// teamSettingsOverlap re-reads recent changes on each poll, so a replica whose clock is a
+	// little behind does not get its changes skipped.
+	teamSettingsOverlap = time.Minute
+)
+
+// Team setting keys, as used by /botadmin config get|set.
+const (
+	SettingBlockMobile          = "block_mobile"
+	SettingRequirePhoto         = "require_photo"
+	SettingActivityCheck        = "activity_check"
+	SettingActivityCheckPeriod  = "activity_check_period"
+	SettingActivityCheckTimeout = "activity_check_timeout"
+	SettingActivityCheckChannel = "activity_check_channel"
+	SettingTimezone             = "timezone"
+	SettingLocale               = "locale"
+)
+
+// TeamSettingKeys lists the team settings in display order.
+var TeamSettingKeys = []string{
+	SettingBlockMobile, SettingRequirePhoto,
+	SettingActivityCheck, SettingActivityCheckPeriod, SettingActivityCheckTimeout, SettingActivityCheckChannel,
+	SettingTimezone, SettingLocale,
+}
+
+// TeamConfig is the effective configuration of one team: its overrides on top of the
+// global settings. BlockMobile and RequirePhoto apply to office days.
+type TeamConfig struct {
+	BlockMobile          bool
+	RequirePhoto         bool
+	ActivityCheck        bool
+	ActivityCheckPeriod  time.Duration
+	ActivityCheckTimeout time.Duration
+	ActivityCheckChannel string
+	BreakReasons         []model.BreakReason
+	Location             *time.Location
+	Locale               string
+}
+
+// TeamSettingsService serves per-team settings from an in-memory copy of the team_settings
+// collection. Changes made here apply at once; changes made by other replicas are picked up
+// by Run. Either way, OnChange listeners are called with the team ID.
+type TeamSettingsService struct {
+	store    store.TeamSettingsStore
+	defaults TeamConfig
+
+	mu        sync.RWMutex
+	settings  map[string]*model.TeamSettings
+	synced    time.Time // newest updated_at seen
+	listeners []func(teamID string)
+}
+
+// NewTeamSettingsService creates the service and loads every team's settings. The defaults
+// come from the environment; without a Location, teams default to UTC+7.
+func NewTeamSettingsService(ctx context.Context, store store.TeamSettingsStore, defaults TeamConfig) (*TeamSettingsService, error) {
+	if defaults.Location == nil {
+		defaults.Location = vnTZ
+	}
+	s := &TeamSettingsService{store: store, defaults: defaults, settings: map[string]*model.TeamSettings{}}
+	if err := s.sync(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OnChange registers a function called after a team's settings change.
+func (s *TeamSettingsService) OnChange(fn func(teamID string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Run picks up changes made by other replicas until ctx is cancelled.
+func (s *TeamSettingsService) Run(ctx context.Context) {
+	ticker := time.NewTicker(teamSettingsPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.sync(ctx); err != nil {
+				log.Printf("ERROR team settings: sync: %v", err)
+			}
+		}
+	}
+}
+
+// sync loads the settings changed since the last sync and notifies listeners of those
+// that differ from the cached copy.
+func (s *TeamSettingsService) sync(ctx context.Context) error {
+	s.mu.RLock()
+	since := s.synced
+	s.mu.RUnlock()
+	if !since.IsZero() {
+		since = since.Add(-teamSettingsOverlap)
+	}
+	changed, err := s.store.ChangedSince(ctx, since)
+	if err != nil {
+		return err
+	}
+	for _, ts := range changed {
+		s.mu.Lock()
+		old := s.settings[ts.TeamID]
+		fresh := old == nil || !old.UpdatedAt.Equal(ts.UpdatedAt)
+		s.settings[ts.TeamID] = ts
+		if ts.UpdatedAt.After(s.synced) {
+			s.synced = ts.UpdatedAt
+		}
+		s.mu.Unlock()
+		if fresh {
+			s.notify(ts.TeamID)
+		}
+	}
+	return nil
+}
+
+func (s *TeamSettingsService) notify(teamID string) {
+	s.mu.RLock()
+	listeners := slices.Clone(s.listeners)
+	s.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(teamID)
+	}
+}
+
+// Settings returns a copy of a team's stored overrides.
+func (s *TeamSettingsService) Settings(teamID string) model.TeamSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if ts, ok := s.settings[teamID]; ok {
+		out := *ts
+		out.BreakReasons = slices.Clone(ts.BreakReasons)
+		return out
+	}
+	return model.TeamSettings{TeamID: teamID}
+}
+
+// Config returns the effective configuration of a team.
+func (s *TeamSettingsService) Config(teamID string) TeamConfig {
+	cfg := s.defaults
+	cfg.BreakReasons = slices.Clone(cfg.BreakReasons)
+	ts := s.Settings(teamID)
+	if ts.BlockMobile != nil {
+		cfg.BlockMobile = *ts.BlockMobile
+	}
+	if ts.RequirePhoto != nil {
+		cfg.RequirePhoto = *ts.RequirePhoto
+	}
+	if ts.ActivityCheck != nil {
+		cfg.ActivityCheck = *ts.ActivityCheck
+	}
+	if ts.ActivityCheckPeriod > 0 {
+		cfg.ActivityCheckPeriod = time.Duration(ts.ActivityCheckPeriod) * time.Minute
+	}
+	if ts.ActivityCheckTimeout > 0 {
+		cfg.ActivityCheckTimeout = time.Duration(ts.ActivityCheckTimeout) * time.Minute
+	}
+	if ts.ActivityCheckChannel != "" {
+		cfg.ActivityCheckChannel = ts.ActivityCheckChannel
+	}
+	if len(ts.BreakReasons) > 0 {
+		cfg.BreakReasons = ts.BreakReasons
+	}
+	if ts.Timezone != "" {
+		if loc, err := loadLocation(ts.Timezone); err == nil {
+			cfg.Location = loc
+		}
+	}
+	if ts.Locale != "" {
+		cfg.Locale = ts.Locale
+	}
+	return cfg
+}
+
+// locations caches time.LoadLocation, which reads the zone database on every call.
+var locations sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// Location returns a team's timezone, which decides its attendance day.
+func (s *TeamSettingsService) Location(teamID string) *time.Location {
+	return s.Config(teamID).Location
+}
+
+// Set changes one setting of a team. The value "default" removes the override. Invalid
+// values return a localized error.
+func (s *TeamSettingsService) Set(ctx context.Context, teamID, key, value, updatedBy string) error {
+	ts := s.Settings(teamID)
+	if err := ApplySetting(ctx, &ts, key, value); err != nil {
+		return err
+	}
+	return s.Save(ctx, &ts, updatedBy)
+}
+
+// Save replaces a team's settings, e.g. from the config dialog.
+func (s *TeamSettingsService) Save(ctx context.Context, ts *model.TeamSettings, updatedBy string) error {
+	ts.UpdatedBy = updatedBy
+	if err := s.store.Save(ctx, ts); err != nil {
+		return err
+	}
+	saved := *ts
+	s.mu.Lock()
+	s.settings[ts.TeamID] = &saved
+	if ts.UpdatedAt.After(s.synced) {
+		s.synced = ts.UpdatedAt
+	}
+	s.mu.Unlock()
+	s.notify(ts.TeamID)
+	return nil
+}
+
+// ApplySetting parses and validates one setting value into ts, like Set does, without
+// saving. The value "default" (or an empty value) removes the override.
+func ApplySetting(ctx context.Context, ts *model.TeamSettings, key, value string) error {
+	value = strings.TrimSpace(value)
+	reset := value == "" || value == "default"
+	invalid := func(msgKey string, data map[string]any) error {
+		if data == nil {
+			data = map[string]any{}
+		}
+		data["Key"] = key
+		data["Value"] = value
+		return errors.New(i18n.T(ctx, msgKey, data))
+	}
+
+	switch key {
+	case SettingBlockMobile, SettingRequirePhoto, SettingActivityCheck:
+		var v *bool
+		if !reset {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return invalid("admin.config.err.bool", nil)
+			}
+			v = &b
+		}
+		switch key {
+		case SettingBlockMobile:
+			ts.BlockMobile = v
+		case SettingRequirePhoto:
+			ts.RequirePhoto = v
+		default:
+			ts.ActivityCheck = v
+		}
+
+	case SettingActivityCheckPeriod, SettingActivityCheckTimeout:
+		n := 0
+		if !reset {
+			var err error
+			n, err = strconv.Atoi(value)
+			if err != nil || n < 1 || n > 24*60 {
+				return invalid("admin.config.err.minutes", nil)
+			}
+		}
+		if key == SettingActivityCheckPeriod {
+			ts.ActivityCheckPeriod = n
+		} else {
+			ts.ActivityCheckTimeout = n
+		}
+
+	case SettingActivityCheckChannel:
+		if reset {
+			ts.ActivityCheckChannel = ""
+			return nil
+		}
+		name := strings.ToLower(strings.TrimPrefix(value, "~"))
+		if !validSetupName.MatchString(name) {
+			return invalid("admin.config.err.channel", nil)
+		}
+		ts.ActivityCheckChannel = name
+
+	case SettingTimezone:
+		if reset {
+			ts.Timezone = ""
+			return nil
+		}
+		if _, err := loadLocation(value); err != nil || value == "Local" {
+			return invalid("admin.config.err.timezone", nil)
+		}
+		ts.Timezone = value
+
+	case SettingLocale:
+		if reset {
+			ts.Locale = ""
+			return nil
+		}
+		if !i18n.Supported(value) {
+			return invalid("admin.config.err.locale", nil)
+		}
+		ts.Locale = value
+
+	default:
+		return invalid("admin.config.err.unknown_key", map[string]any{"Keys": strings.Join(TeamSettingKeys, ", ")})
+	}
+	return nil
+}
+
+// SettingValue formats one of a team's effective settings, and whether it is overridden.
+func (s *TeamSettingsService) SettingValue(teamID, key string) (value string, overridden bool) {
+	ts := s.Settings(teamID)
+	cfg := s.Config(teamID)
+	switch key {
+	case SettingBlockMobile:
+		return strconv.FormatBool(cfg.BlockMobile), ts.BlockMobile != nil
+	case SettingRequirePhoto:
+		return strconv.FormatBool(cfg.RequirePhoto), ts.RequirePhoto != nil
+	case SettingActivityCheck:
+		return strconv.FormatBool(cfg.ActivityCheck), ts.ActivityCheck != nil
+	case SettingActivityCheckPeriod:
+		return fmt.Sprintf("%g", cfg.ActivityCheckPeriod.Minutes()), ts.ActivityCheckPeriod > 0
+	case SettingActivityCheckTimeout:
+		return fmt.Sprintf("%g", cfg.ActivityCheckTimeout.Minutes()), ts.ActivityCheckTimeout > 0
+	case SettingActivityCheckChannel:
+		return cfg.ActivityCheckChannel, ts.ActivityCheckChannel != ""
+	case SettingTimezone:
+		return cfg.Location.String(), ts.Timezone != ""
+	case SettingLocale:
+		return cfg.Locale, ts.Locale != ""
+	}
+	return "", false
+}
@@ -0,0 +1,515 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/store"
+)
+
+// Timesheet export formats.
+const (
+	TimesheetFormatCSV  = "csv"
+	TimesheetFormatXLSX = "xlsx"
+)
+
+// RoundingRule rounds minutes to a multiple of a unit. A unit of 0 or 1 keeps exact minutes.
+type RoundingRule struct {
+	UnitMinutes int    `json:"unit_minutes"`
+	Mode        string `json:"mode"` // "nearest" (default), "up" or "down"
+}
+
+func (r RoundingRule) apply(minutes int) int {
+	if r.UnitMinutes <= 1 || minutes <= 0 {
+		return max(minutes, 0)
+	}
+	units := float64(minutes) / float64(r.UnitMinutes)
+	switch r.Mode {
+	case "up":
+		units = math.Ceil(units)
+	case "down":
+		units = math.Floor(units)
+	default:
+		units = math.Round(units)
+	}
+	return int(units) * r.UnitMinutes
+}
+
+// TimesheetRounding holds the rounding rule of each kind of time. Rules apply per day (per
+// request for overtime) before the month is summed.
+type TimesheetRounding struct {
+	Worked   RoundingRule `json:"worked"`
+	Late     RoundingRule `json:"late"`
+	Overtime RoundingRule `json:"overtime"`
+}
+
+// TimesheetColumn maps a timesheet field to a column header of the payroll file.
+type TimesheetColumn struct {
+	Field  string `json:"field"`
+	Header string `json:"header"`
+}
+
+// TimesheetConfig holds the payroll rules of the TIMESHEET_CONFIG setting.
+type TimesheetConfig struct {
+	WorkStart           string            `json:"work_start"` // HH:MM; later weekday check-ins count as late; empty disables
+	LateGraceMinutes    int               `json:"late_grace_minutes"`
+	AnnualPaidLeaveDays int               `json:"annual_paid_leave_days"` // leave days beyond this in a calendar year are unpaid; 0 pays all
+	HoursDecimals       int               `json:"hours_decimals"`
+	Rounding            TimesheetRounding `json:"rounding"`
+	Columns             []TimesheetColumn `json:"columns"` // file layout; empty exports every field
+}
+
+// timesheetField is one value a timesheet column can hold.
+type timesheetField struct {
+	name  string
+	value func(row *model.TimesheetRow, hours func(int) float64) any
+}
+
+var timesheetFields = []timesheetField{
+	{"user_id", func(r *model.TimesheetRow, _ func(int) float64) any { return r.UserID }},
+	{"username", func(r *model.TimesheetRow, _ func(int) float64) any { return r.Username }},
+	{"days_worked", func(r *model.TimesheetRow, _ func(int) float64) any { return r.DaysWorked }},
+	{"incomplete_days", func(r *model.TimesheetRow, _ func(int) float64) any { return r.IncompleteDays }},
+	{"worked_hours", func(r *model.TimesheetRow, h func(int) float64) any { return h(r.WorkedMinutes) }},
+	{"paid_leave_days", func(r *model.TimesheetRow, _ func(int) float64) any { return r.PaidLeaveDays }},
+	{"unpaid_leave_days", func(r *model.TimesheetRow, _ func(int) float64) any { return r.UnpaidLeaveDays }},
+	{"late_count", func(r *model.TimesheetRow, _ func(int) float64) any { return r.LateCount }},
+	{"late_minutes", func(r *model.TimesheetRow, _ func(int) float64) any { return r.LateMinutes }},
+	{"late_arrival_requests", func(r *model.TimesheetRow, _ func(int) float64) any { return r.LateArrivalRequests }},
+	{"early_departure_requests", func(r *model.TimesheetRow, _ func(int) float64) any { return r.EarlyDepartureRequests }},
+	{"overtime_weekday_hours", func(r *model.TimesheetRow, h func(int) float64) any { return h(r.OvertimeWeekdayMinutes) }},
+	{"overtime_weekend_hours", func(r *model.TimesheetRow, h func(int) float64) any { return h(r.OvertimeWeekendMinutes) }},
+	{"overtime_holiday_hours", func(r *model.TimesheetRow, h func(int) float64) any { return h(r.OvertimeHolidayMinutes) }},
+	{"overtime_weighted_hours", func(r *model.TimesheetRow, h func(int) float64) any { return h(r.OvertimeWeightedMinutes) }},
+	{"comp_time_hours", func(r *model.TimesheetRow, h func(int) float64) any { return h(r.CompTimeMinutes) }},
+	{"holiday_work_hours", func(r *model.TimesheetRow, h func(int) float64) any { return h(r.HolidayWorkMinutes) }},
+	{"remote_days", func(r *model.TimesheetRow, _ func(int) float64) any { return r.RemoteDays }},
+	{"business_trip_days", func(r *model.TimesheetRow, _ func(int) float64) any { return r.BusinessTripDays }},
+}
+
+func findTimesheetField(name string) *timesheetField {
+	for i := range timesheetFields {
+		if timesheetFields[i].name == name {
+			return &timesheetFields[i]
+		}
+	}
+	return nil
+}
+
+// ParseTimesheetConfig parses the TIMESHEET_CONFIG setting, a JSON object. Missing fields
+// keep their defaults: work starts at 08:30, 12 paid leave days a year, hours with two
+// decimals, exact minutes and every field as a column named after it.
+func ParseTimesheetConfig(raw string) (TimesheetConfig, error) {
+	cfg := TimesheetConfig{WorkStart: "08:30", AnnualPaidLeaveDays: 12, HoursDecimals: 2}
+	if raw != "" {
+		dec := json.NewDecoder(strings.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return cfg, fmt.Errorf("parse timesheet config: %w", err)
+		}
+	}
+	if cfg.WorkStart != "" {
+		if _, err := time.Parse("15:04", cfg.WorkStart); err != nil {
+			return cfg, fmt.Errorf("invalid work_start %q: want HH:MM", cfg.WorkStart)
+		}
+	}
+	if cfg.LateGraceMinutes < 0 || cfg.AnnualPaidLeaveDays < 0 {
+		return cfg, errors.New("late_grace_minutes and annual_paid_leave_days must not be negative")
+	}
+	if cfg.HoursDecimals < 0 || cfg.HoursDecimals > 4 {
+		return cfg, errors.New("hours_decimals must be between 0 and 4")
+	}
+	for name, rule := range map[string]RoundingRule{"worked": cfg.Rounding.Worked, "late": cfg.Rounding.Late, "overtime": cfg.Rounding.Overtime} {
+		if rule.UnitMinutes < 0 {
+			return cfg, fmt.Errorf("rounding.%s.unit_minutes must not be negative", name)
+		}
+		switch rule.Mode {
+		case "", "nearest", "up", "down":
+		default:
+			return cfg, fmt.Errorf("unknown rounding.%s.mode %q (valid: nearest, up, down)", name, rule.Mode)
+		}
+	}
+	if len(cfg.Columns) == 0 {
+		for _, f := range timesheetFields {
+			cfg.Columns = append(cfg.Columns, TimesheetColumn{Field: f.name})
+		}
+	}
+	for i, c := range cfg.Columns {
+		if c.Field != "month" && findTimesheetField(c.Field) == nil {
+			return cfg, fmt.Errorf("unknown timesheet field %q", c.Field)
+		}
+		if c.Header == "" {
+			cfg.Columns[i].Header = c.Field
+		}
+	}
+	return cfg, nil
+}
+
+// TimesheetService turns a month of attendance into payroll totals per employee, locks
+// closed months and exports them for the payroll system.
+type TimesheetService struct {
+	store      store.TimesheetStore
+	attendance store.AttendanceStore
+	settings   *TeamSettingsService
+	overtime   OvertimeConfig
+	cfg        TimesheetConfig
+	hooks      *WebhookService
+}
+
+func NewTimesheetService(store store.TimesheetStore, attendance store.AttendanceStore, settings *TeamSettingsService, overtime OvertimeConfig, cfg TimesheetConfig, hooks *WebhookService) *TimesheetService {
+	return &TimesheetService{store: store, attendance: attendance, settings: settings, overtime: overtime, cfg: cfg, hooks: hooks}
+}
+
+// monthRange returns the first and last date of a YYYY-MM month.
+func monthRange(ctx context.Context, month string) (string, string, error) {
+	t, err := time.Parse("2006-01", month)
+	if err != nil {
+		return "", "", invalidInput(i18n.T(ctx, "timesheet.err.invalid_month", map[string]any{"Month": month}))
+	}
+	return t.Format(time.DateOnly), t.AddDate(0, 1, -1).Format(time.DateOnly), nil
+}
+
+// Get returns a team's timesheet for a month: the locked rows if the month was locked
+// (even while a correction is open), else totals computed from the current data.
+func (s *TimesheetService) Get(ctx context.Context, teamID, month string) (*model.Timesheet, error) {
+	if _, _, err := monthRange(ctx, month); err != nil {
+		return nil, err
+	}
+	sheet, err := s.store.Get(ctx, teamID, month)
+	if err != nil || sheet != nil {
+		return sheet, err
+	}
+	rows, err := s.compute(ctx, teamID, month)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Timesheet{TeamID: teamID, Month: month, Rows: rows, GeneratedAt: time.Now()}, nil
+}
+
+// Lock freezes a month that has ended. Locking a month with an open correction closes the
+// correction, recomputes the rows and raises the revision.
+func (s *TimesheetService) Lock(ctx context.Context, teamID, month, username string) (*model.Timesheet, error) {
+	first, _, err := monthRange(ctx, month)
+	if err != nil {
+		return nil, err
+	}
+	today := time.Now().In(s.settings.Location(teamID)).Format(time.DateOnly)
+	if next, _ := time.Parse(time.DateOnly, first); next.AddDate(0, 1, 0).Format(time.DateOnly) > today {
+		return nil, invalidInput(i18n.T(ctx, "timesheet.err.month_not_ended", map[string]any{"Month": month}))
+	}
+
+	sheet, err := s.store.Get(ctx, teamID, month)
+	if err != nil {
+		return nil, err
+	}
+	if sheet.Locked() {
+		return nil, invalidInput(i18n.T(ctx, "timesheet.err.already_locked", map[string]any{"Month": month}))
+	}
+	rows, err := s.compute(ctx, teamID, month)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if sheet == nil {
+		sheet = &model.Timesheet{TeamID: teamID, Month: month}
+	} else {
+		c := *sheet.Correction
+		c.ClosedBy = username
+		c.ClosedAt = &now
+		c.Changed = changedRows(sheet.Rows, rows)
+		sheet.Corrections = append(sheet.Corrections, c)
+		sheet.Correction = nil
+	}
+	sheet.Revision++
+	sheet.Rows = rows
+	sheet.GeneratedAt = now
+	sheet.LockedAt = &now
+	sheet.LockedBy = username
+	if err := s.store.Save(ctx, sheet); err != nil {
+		return nil, err
+	}
+	s.hooks.Emit(ctx, model.EventTimesheetLocked, map[string]any{
+		"team_id":  teamID,
+		"month":    month,
+		"revision": sheet.Revision,
+	})
+	return sheet, nil
+}
+
+// OpenCorrection reopens a locked month for edits, recording who asked and why. The month
+// stays open until it is locked again.
+func (s *TimesheetService) OpenCorrection(ctx context.Context, teamID, month, username, reason string) (*model.Timesheet, error) {
+	if _, _, err := monthRange(ctx, month); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(reason) == "" {
+		return nil, invalidInput(i18n.T(ctx, "timesheet.err.reason_required"))
+	}
+	sheet, err := s.store.Get(ctx, teamID, month)
+	if err != nil {
+		return nil, err
+	}
+	if sheet == nil {
+		return nil, invalidInput(i18n.T(ctx, "timesheet.err.not_locked", map[string]any{"Month": month}))
+	}
+	if sheet.Correction != nil {
+		return nil, invalidInput(i18n.T(ctx, "timesheet.err.correction_open", map[string]any{"Month": month}))
+	}
+	sheet.Correction = &model.TimesheetCorrection{Reason: strings.TrimSpace(reason), OpenedBy: username, OpenedAt: time.Now()}
+	if err := s.store.Save(ctx, sheet); err != nil {
+		return nil, err
+	}
+	return sheet, nil
+}
+
+// CheckEditable refuses changes to dates of a team's locked months. Safe to call on a nil
+// service.
+func (s *TimesheetService) CheckEditable(ctx context.Context, teamID string, dates []string) error {
+	if s == nil || teamID == "" {
+		return nil
+	}
+	var months []string
+	for _, d := range dates {
+		if len(d) >= 7 && !slices.Contains(months, d[:7]) {
+			months = append(months, d[:7])
+		}
+	}
+	for _, month := range months {
+		sheet, err := s.store.Get(ctx, teamID, month)
+		if err != nil {
+			return err
+		}
+		if sheet.Locked() {
+			return errors.New(i18n.T(ctx, "attendance.err.month_locked", map[string]any{"Month": month}))
+		}
+	}
+	return nil
+}
+
+// changedRows returns the usernames whose totals differ between two sets of rows.
+func changedRows(before, after []model.TimesheetRow) []string {
+	old := map[string]model.TimesheetRow{}
+	for _, r := range before {
+		old[r.UserID] = r
+	}
+	var changed []string
+	for _, r := range after {
+		if prev, ok := old[r.UserID]; !ok || prev != r {
+			changed = append(changed, r.Username)
+		}
+		delete(old, r.UserID)
+	}
+	for _, r := range old {
+		changed = append(changed, r.Username)
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// isWorkday reports whether a date is a scheduled working day: a weekday that is not a holiday.
+func (s *TimesheetService) isWorkday(date string) bool {
+	return s.overtime.dayType(date) == OvertimeDayWeekday
+}
+
+// compute sums a team's month of attendance records and approved requests per employee.
+func (s *TimesheetService) compute(ctx context.Context, teamID, month string) ([]model.TimesheetRow, error) {
+	first, last, err := monthRange(ctx, month)
+	if err != nil {
+		return nil, err
+	}
+	records, err := s.attendance.GetAttendanceByDateRange(ctx, first, last, "", teamID, "")
+	if err != nil {
+		return nil, fmt.Errorf("get attendance: %w", err)
+	}
+	// Leave from the start of the year decides which of this month's leave days are paid
+	leaves, err := s.attendance.GetLeaveRequestsByDateRange(ctx, month[:4]+"-01-01", last, "", teamID, "")
+	if err != nil {
+		return nil, fmt.Errorf("get leave requests: %w", err)
+	}
+
+	rows := map[string]*model.TimesheetRow{}
+	get := func(userID, username string) *model.TimesheetRow {
+		row, ok := rows[userID]
+		if !ok {
+			row = &model.TimesheetRow{UserID: userID, Username: username}
+			rows[userID] = row
+		}
+		return row
+	}
+	inMonth := func(d string) bool { return d >= first && d <= last }
+
+	loc := s.settings.Location(teamID)
+	for _, rec := range records {
+		if rec.CheckIn == nil {
+			continue
+		}
+		row := get(rec.UserID, rec.Username)
+		row.DaysWorked++
+		switch recordMode(rec) {
+		case model.AttendanceModeRemote:
+			row.RemoteDays++
+		case model.AttendanceModeBusinessTrip:
+			row.BusinessTripDays++
+		}
+
+		if s.cfg.WorkStart != "" && s.isWorkday(rec.Date) {
+			start, err := time.ParseInLocation("2006-01-02 15:04", rec.Date+" "+s.cfg.WorkStart, loc)
+			if err == nil {
+				late := rec.CheckIn.Sub(start)
+				if late > time.Duration(s.cfg.LateGraceMinutes)*time.Minute {
+					row.LateCount++
+					row.LateMinutes += s.cfg.Rounding.Late.apply(int(late.Minutes()))
+				}
+			}
+		}
+
+		if rec.CheckOut == nil {
+			row.IncompleteDays++
+			continue
+		}
+		var breaks time.Duration
+		for _, b := range rec.Breaks {
+			if b.End != nil {
+				breaks += b.End.Sub(b.Start)
+			}
+		}
+		worked := s.cfg.Rounding.Worked.apply(int((rec.CheckOut.Sub(*rec.CheckIn) - breaks).Minutes()))
+		row.WorkedMinutes += worked
+		if s.overtime.dayType(rec.Date) == OvertimeDayHoliday {
+			row.HolidayWorkMinutes += worked
+		}
+	}
+
+	type leaveDay struct{ date, username string }
+	offDays := map[string][]leaveDay{} // by user, from the start of the year
+	for _, req := range leaves {
+		if req.Status != model.LeaveStatusApproved && req.Status != model.LeaveStatusPendingCancel {
+			continue
+		}
+		switch req.Type {
+		case model.LeaveTypeOff:
+			for _, d := range req.Dates {
+				if d > last || !s.isWorkday(d) {
+					continue
+				}
+				// Paid from comp time, so it leaves the annual allowance alone
+				if req.CompTimeDayMinutes > 0 {
+					if inMonth(d) {
+						get(req.UserID, req.Username).PaidLeaveDays++
+					}
+					continue
+				}
+				offDays[req.UserID] = append(offDays[req.UserID], leaveDay{d, req.Username})
+			}
+		case model.LeaveTypeLateArrival, model.LeaveTypeEarlyDeparture:
+			for _, d := range req.Dates {
+				if !inMonth(d) {
+					continue
+				}
+				row := get(req.UserID, req.Username)
+				if req.Type == model.LeaveTypeLateArrival {
+					row.LateArrivalRequests++
+				} else {
+					row.EarlyDepartureRequests++
+				}
+			}
+		case model.LeaveTypeOvertime:
+			if req.ReconciledAt == nil || !inMonth(req.Dates[0]) {
+				continue
+			}
+			row := get(req.UserID, req.Username)
+			minutes := s.cfg.Rounding.Overtime.apply(req.CreditedOvertimeMinutes)
+			switch req.OvertimeDayType {
+			case OvertimeDayHoliday:
+				row.OvertimeHolidayMinutes += minutes
+			case OvertimeDayWeekend:
+				row.OvertimeWeekendMinutes += minutes
+			default:
+				row.OvertimeWeekdayMinutes += minutes
+			}
+			if req.CompensatoryLeave {
+				row.CompTimeMinutes += minutes
+			} else {
+				row.OvertimeWeightedMinutes += int(math.Round(float64(minutes) * s.overtime.rate(req.OvertimeDayType)))
+			}
+		}
+	}
+
+	// The first AnnualPaidLeaveDays leave days of the year are paid, later ones unpaid
+	for userID, days := range offDays {
+		slices.SortFunc(days, func(a, b leaveDay) int { return strings.Compare(a.date, b.date) })
+		days = slices.CompactFunc(days, func(a, b leaveDay) bool { return a.date == b.date })
+		for i, d := range days {
+			if !inMonth(d.date) {
+				continue
+			}
+			row := get(userID, d.username)
+			if s.cfg.AnnualPaidLeaveDays == 0 || i < s.cfg.AnnualPaidLeaveDays {
+				row.PaidLeaveDays++
+			} else {
+				row.UnpaidLeaveDays++
+			}
+		}
+	}
+
+	out := make([]model.TimesheetRow, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, *row)
+	}
+	slices.SortFunc(out, func(a, b model.TimesheetRow) int { return strings.Compare(a.Username, b.Username) })
+	return out, nil
+}
+
+// Export renders a timesheet in the configured column layout as CSV or XLSX and returns
+// the file name, content type and data.
+func (s *TimesheetService) Export(ctx context.Context, sheet *model.Timesheet, format string) (string, string, []byte, error) {
+	header := make([]string, len(s.cfg.Columns))
+	for i, c := range s.cfg.Columns {
+		header[i] = c.Header
+	}
+	scale := math.Pow(10, float64(s.cfg.HoursDecimals))
+	hours := func(minutes int) float64 { return math.Round(float64(minutes)/60*scale) / scale }
+	table := make([][]any, len(sheet.Rows))
+	for i := range sheet.Rows {
+		table[i] = make([]any, len(s.cfg.Columns))
+		for j, c := range s.cfg.Columns {
+			if c.Field == "month" {
+				table[i][j] = sheet.Month
+				continue
+			}
+			table[i][j] = findTimesheetField(c.Field).value(&sheet.Rows[i], hours)
+		}
+	}
+
+	name := "timesheet-" + sheet.Month
+	if sheet.LockedAt == nil {
+		name += "-draft"
+	} else if sheet.Revision > 1 {
+		name += fmt.Sprintf("-r%d", sheet.Revision)
+	}
+	var buf bytes.Buffer
+	switch format {
+	case TimesheetFormatCSV:
+		if err := writeTimesheetCSV(&buf, header, table, s.cfg.HoursDecimals); err != nil {
+			return "", "", nil, err
+		}
+		return name + ".csv", "text/csv; charset=utf-8", buf.Bytes(), nil
+	case TimesheetFormatXLSX:
+		if err := writeTimesheetXLSX(&buf, sheet.Month, header, table); err != nil {
+			return "", "", nil, err
+		}
+		return name + ".xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buf.Bytes(), nil
+	default:
+		return "", "", nil, invalidInput(i18n.T(ctx, "timesheet.err.invalid_format", map[string]any{"Format": format}))
+	}
+}
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/metrics"
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/store"
+)
+
+// Retry schedule: the first retry waits webhookBaseBackoff, doubling up to webhookMaxBackoff.
+const (
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = time.Hour
+	webhookPollEvery   = 10 * time.Second
+	webhookPruneEvery  = time.Hour
+)
+
+// WebhookEnvelope is the JSON body of every delivery.
+type WebhookEnvelope struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// WebhookService pushes bot events to HR and finance systems. Emit records one
+// delivery per matching subscription; Run sends them with retries and backoff, and
+// prunes finished deliveries from the log once they are older than keep.
+// A nil *WebhookService emits nothing.
+type WebhookService struct {
+	store       store.WebhookStore
+	subs        []model.WebhookSubscription
+	client      *http.Client
+	maxAttempts int
+	keep        time.Duration // 0 keeps the delivery log forever
+	wake        chan struct{}
+}
+
+func NewWebhookService(store store.WebhookStore, subs []model.WebhookSubscription, maxAttempts int, timeout, keep time.Duration) *WebhookService {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &WebhookService{
+		store:       store,
+		subs:        subs,
+		client:      &http.Client{Timeout: timeout},
+		maxAttempts: maxAttempts,
+		keep:        keep,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// ParseWebhookSubscriptions parses the WEBHOOKS JSON array. Every subscription needs an
+// absolute http(s) URL, at least one event and a signing secret.
+func ParseWebhookSubscriptions(raw string) ([]model.WebhookSubscription, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var subs []model.WebhookSubscription
+	if err := json.Unmarshal([]byte(raw), &subs); err != nil {
+		return nil, fmt.Errorf("parse webhooks: %w", err)
+	}
+	for i, sub := range subs {
+		u, err := url.Parse(sub.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook %d: invalid url %q", i, sub.URL)
+		}
+		if len(sub.Events) == 0 {
+			return nil, fmt.Errorf("webhook %d: no events", i)
+		}
+		if sub.Secret == "" {
+			return nil, fmt.Errorf("webhook %d: secret is required", i)
+		}
+	}
+	return subs, nil
+}
+
+// countTransition counts a leave or budget event in the metrics, labelled with the leave
+// type or the budget request's resulting step.
+func countTransition(event string, data any) {
+	fields, _ := data.(map[string]any)
+	if name, ok := strings.CutPrefix(event, "leave."); ok {
+		leaveType := ""
+		if req, ok := fields["leave_request"].(*model.LeaveRequest); ok {
+			leaveType = string(req.Type)
+		}
+		metrics.LeaveTransitions.WithLabelValues(name, leaveType).Inc()
+	} else if name, ok := strings.CutPrefix(event, "budget."); ok {
+		step := ""
+		if req, ok := fields["budget_request"].(*model.BudgetRequest); ok {
+			step = strconv.Itoa(int(req.CurrentStep))
+		}
+		metrics.BudgetTransitions.WithLabelValues(name, step).Inc()
+	}
+}
+
+// eventUserID returns the user a leave or attendance event is about, or "" for other events.
+func eventUserID(data any) string {
+	fields, _ := data.(map[string]any)
+	if req, ok := fields["leave_request"].(*model.LeaveRequest); ok {
+		return req.UserID
+	}
+	if rec, ok := fields["attendance_record"].(*model.AttendanceRecord); ok {
+		return rec.UserID
+	}
+	return ""
+}
+
+// Enabled reports whether any subscription is configured.
+func (s *WebhookService) Enabled() bool {
+	return s != nil && len(s.subs) > 0
+}
+
+// Emit queues an event for every subscription that receives it. Failures are logged and
+// never affect the caller's workflow. Leave and budget events are counted in the metrics
+// whether or not webhooks are enabled.
+func (s *WebhookService) Emit(ctx context.Context, event string, data any) {
+	countTransition(event, data)
+	if !s.Enabled() {
+		return
+	}
+	env := WebhookEnvelope{
+		ID:        bson.NewObjectID().Hex(),
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	body, err := json.Marshal(env)
+	if err != nil {
+		log.Printf("webhook: marshal %s: %v", event, err)
+		return
+	}
+
+	queued := false
+	for _, sub := range s.subs {
+		if !sub.Matches(event) {
+			continue
+		}
+		d := &model.WebhookDelivery{
+			EventID:       env.ID,
+			Event:         event,
+			UserID:        eventUserID(data),
+			URL:           sub.URL,
+			Payload:       string(body),
+			Status:        model.WebhookDeliveryPending,
+			NextAttemptAt: env.CreatedAt,
+		}
+		if err := s.store.Create(context.WithoutCancel(ctx), d); err != nil {
+			log.Printf("webhook: queue %s for %s: %v", event, sub.URL, err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run delivers queued events until ctx is cancelled. Deliveries survive restarts: they
+// are picked up again from the delivery log. With no subscriptions it only prunes the log.
+func (s *WebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollEvery)
+	defer ticker.Stop()
+	prune := time.NewTicker(webhookPruneEvery)
+	defer prune.Stop()
+
+	s.prune(ctx)
+	for {
+		if s.Enabled() {
+			s.dispatchDue(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			log.Println("webhook dispatcher stopped")
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		case <-prune.C:
+			s.prune(ctx)
+		}
+	}
+}
+
+// prune deletes the delivered and failed deliveries older than the log is kept.
+func (s *WebhookService) prune(ctx context.Context) {
+	if s.keep <= 0 {
+		return
+	}
+	n, err := s.store.DeleteFinishedBefore(ctx, time.Now().Add(-s.keep))
+	if err != nil {
+		log.Printf("webhook: prune delivery log: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("webhook: pruned %d deliveries from the log", n)
+	}
+}
+
+func (s *WebhookService) dispatchDue(ctx context.Context) {
+	lease := s.client.Timeout + time.Minute
+	for ctx.Err() == nil {
+		d, err := s.store.ClaimDue(ctx, time.Now(), lease)
+		if err != nil {
+			log.Printf("webhook: %v", err)
+			return
+		}
+		if d == nil {
+			return
+		}
+		s.attempt(ctx, d)
+	}
+}
+
+// attempt sends one delivery and records the outcome, scheduling a retry on failure.
+func (s *WebhookService) attempt(ctx context.Context, d *model.WebhookDelivery) {
+	d.Attempts++
+	code, err := s.send(ctx, d)
+	d.LastStatusCode = code
+	now := time.Now()
+	switch {
+	case err == nil:
+		d.Status = model.WebhookDeliveryDelivered
+		d.DeliveredAt = &now
+		d.LastError = ""
+	case d.Attempts >= s.maxAttempts:
+		d.Status = model.WebhookDeliveryFailed
+		d.LastError = err.Error()
+		log.Printf("webhook: giving up on %s %s to %s after %d attempts: %v", d.Event, d.ID.Hex(), d.URL, d.Attempts, err)
+	default:
+		d.LastError = err.Error()
+		d.NextAttemptAt = now.Add(webhookBackoff(d.Attempts))
+	}
+	if err := s.store.Update(context.WithoutCancel(ctx), d); err != nil {
+		log.Printf("webhook: update delivery %s: %v", d.ID.Hex(), err)
+	}
+}
+
+func (s *WebhookService) send(ctx context.Context, d *model.WebhookDelivery) (int, error) {
+	var secret string
+	found := false
+	for _, sub := range s.subs {
+		if sub.URL == d.URL {
+			secret, found = sub.Secret, true
+			break
+		}
+	}
+	if !found {
+		return 0, errors.New("endpoint is no longer subscribed")
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader([]byte(d.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "oktel-bot-webhooks")
+	req.Header.Set("X-Oktel-Event", d.Event)
+	req.Header.Set("X-Oktel-Delivery", d.ID.Hex())
+	req.Header.Set("X-Oktel-Timestamp", timestamp)
+	req.Header.Set("X-Oktel-Signature", "sha256="+SignWebhook(secret, timestamp, []byte(d.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "timestamp.body" under the subscription secret.
+// Receivers recompute it to authenticate a delivery and reject stale timestamps to stop replays.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff returns the wait before the retry following the given attempt.
+func webhookBackoff(attempts int) time.Duration {
+	d := webhookBaseBackoff
+	for i := 1; i < attempts && d < webhookMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, webhookMaxBackoff)
+}
+
+// Deliveries returns the delivery log, newest first.
+func (s *WebhookService) Deliveries(ctx context.Context, status model.WebhookDeliveryStatus, event, eventID string, limit int) ([]*model.WebhookDelivery, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.store.List(ctx, status, event, eventID, limit)
+}
+
+// Redeliver queues a delivery to be sent again right away, resetting its attempt count.
+func (s *WebhookService) Redeliver(ctx context.Context, deliveryID string) (*model.WebhookDelivery, error) {
+	id, err := bson.ObjectIDFromHex(deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid delivery ID: %w", err)
+	}
+	d, err := s.store.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get delivery: %w", err)
+	}
+	if d == nil {
+		return nil, nil
+	}
+	d.Status = model.WebhookDeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now()
+	if err := s.store.Update(ctx, d); err != nil {
+		return nil, fmt.Errorf("update delivery: %w", err)
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return d, nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/model"
+)
+
+// ModePolicy lists the office checks enforced for one attendance mode.
+type ModePolicy struct {
+	RequirePhoto         bool
+	BlockMobile          bool
+	RequireOfficeNetwork bool
+}
+
+// WorkModeConfig holds the attendance policy for each mode and the office networks
+// used by the network check.
+type WorkModeConfig struct {
+	Office         ModePolicy
+	Remote         ModePolicy
+	BusinessTrip   ModePolicy
+	OfficeNetworks []*net.IPNet
+}
+
+// ParseNetworks parses a list of CIDRs (e.g. "10.0.0.0/8"). Bare IPs are treated as single hosts.
+func ParseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	var out []*net.IPNet
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil && ip.To4() != nil {
+				c += "/32"
+			} else {
+				c += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("parse network %q: %w", c, err)
+		}
+		out = append(out, network)
+	}
+	return out, nil
+}
+
+// policy returns the checks that apply to a mode. Records without a mode are office days.
+func (c WorkModeConfig) policy(mode model.AttendanceMode) ModePolicy {
+	switch mode {
+	case model.AttendanceModeRemote:
+		return c.Remote
+	case model.AttendanceModeBusinessTrip:
+		return c.BusinessTrip
+	default:
+		return c.Office
+	}
+}
+
+// policy returns the checks for a mode in a team. Office days follow the team's mobile and
+// photo settings.
+func (s *AttendanceService) policy(teamID string, mode model.AttendanceMode) ModePolicy {
+	p := s.workModes.policy(mode)
+	if mode != model.AttendanceModeRemote && mode != model.AttendanceModeBusinessTrip {
+		cfg := s.settings.Config(teamID)
+		p.BlockMobile = cfg.BlockMobile
+		p.RequirePhoto = cfg.RequirePhoto
+	}
+	return p
+}
+
+// modeForDate resolves the user's attendance mode from approved remote or business-trip
+// requests. Lookup failures fall back to the office policy.
+func (s *AttendanceService) modeForDate(ctx context.Context, userID, date string) model.AttendanceMode {
+	req, err := s.store.GetApprovedWorkMode(ctx, userID, date)
+	if err != nil {
+		log.Printf("work mode: lookup for %s on %s: %v", userID, date, err)
+		return model.AttendanceModeOffice
+	}
+	if req == nil {
+		return model.AttendanceModeOffice
+	}
+	return model.AttendanceMode(req.Type)
+}
+
+// TodayPolicy returns the policy for the user's current attendance day in a team. Once the
+// user has checked in, the mode stored on the record wins.
+func (s *AttendanceService) TodayPolicy(ctx context.Context, userID, teamID string) ModePolicy {
+	date := time.Now().In(s.settings.Location(teamID)).Format(time.DateOnly)
+	record, err := s.store.GetTodayRecord(ctx, userID, date)
+	if err == nil && record != nil && record.Mode != "" {
+		return s.policy(teamID, record.Mode)
+	}
+	return s.policy(teamID, s.modeForDate(ctx, userID, date))
+}
+
+// InOfficeNetwork reports whether ip belongs to one of the configured office networks.
+// With no networks configured every address is accepted.
+func (s *AttendanceService) InOfficeNetwork(ip string) bool {
+	if len(s.workModes.OfficeNetworks) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range s.workModes.OfficeNetworks {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWorkModeRequest asks for remote work or a business trip on the given dates. It goes
+// through the same approval flow as leave requests.
+func (s *AttendanceService) CreateWorkModeRequest(ctx context.Context, userID, username, channelID string, mode model.LeaveType, dates []string, reason, approver string) error {
+	if mode != model.LeaveTypeRemote && mode != model.LeaveTypeBusinessTrip {
+		return errors.New(i18n.T(ctx, "attendance.err.invalid_mode", map[string]any{"Mode": string(mode)}))
+	}
+	return s.CreateLeaveRequest(ctx, userID, username, channelID, mode, dates, reason, "", approver, nil, false)
+}
+
+// recordMode returns the record's mode, treating records from before modes existed as office days.
+func recordMode(rec *model.AttendanceRecord) model.AttendanceMode {
+	if rec.Mode == "" {
+		return model.AttendanceModeOffice
+	}
+	return rec.Mode
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"oktel-bot/internal/model"
+)
+
+type mongoAPIKeyStore struct {
+	coll *mongo.Collection
+}
+
+func NewAPIKeyStore(ctx context.Context, db *MongoDB) (APIKeyStore, error) {
+	keys := db.Collection("api_keys")
+
+	if _, err := keys.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}); err != nil {
+		return nil, fmt.Errorf("create api_keys indexes: %w", err)
+	}
+
+	return &mongoAPIKeyStore{coll: keys}, nil
+}
+
+// Create inserts a new key and sets the ID on the struct.
+func (s *mongoAPIKeyStore) Create(ctx context.Context, key *model.APIKey) error {
+	key.CreatedAt = time.Now()
+	res, err := s.coll.InsertOne(ctx, key)
+	if err != nil {
+		return err
+	}
+	key.ID = res.InsertedID.(bson.ObjectID)
+	return nil
+}
+
+// GetByHash returns the key with the given hash, or nil if not found.
+func (s *mongoAPIKeyStore) GetByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := s.coll.FindOne(ctx, bson.M{"hash": hash}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find api key: %w", err)
+	}
+	return &key, nil
+}
+
+func (s *mongoAPIKeyStore) List(ctx context.Context) ([]*model.APIKey, error) {
+	cursor, err := s.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("find api keys: %w", err)
+	}
+	var results []*model.APIKey
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("decode api keys: %w", err)
+	}
+	return results, nil
+}
+
+// TouchLastUsed records that a key was just used.
+func (s *mongoAPIKeyStore) TouchLastUsed(ctx context.Context, id bson.ObjectID, at time.Time) error {
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": at}})
+	return err
+}
+
+// Revoke marks a key as revoked. It reports whether an active key was found.
+func (s *mongoAPIKeyStore) Revoke(ctx context.Context, id bson.ObjectID) (bool, error) {
+	res, err := s.coll.UpdateOne(ctx,
+		bson.M{"_id": id, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
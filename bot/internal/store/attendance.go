@@ -12,14 +12,16 @@ import (
 	"oktel-bot/internal/model"
 )
 
-type AttendanceStore struct {
-	attendance *mongo.Collection
-	leave      *mongo.Collection
+type mongoAttendanceStore struct {
+	attendance  *mongo.Collection
+	leave       *mongo.Collection
+	adjustments *mongo.Collection
 }
 
-func NewAttendanceStore(ctx context.Context, db *MongoDB) (*AttendanceStore, error) {
+func NewAttendanceStore(ctx context.Context, db *MongoDB) (AttendanceStore, error) {
 	attendance := db.Collection("attendance")
 	leave := db.Collection("leave_requests")
+	adjustments := db.Collection("comp_time_adjustments")
 
 	if _, err := attendance.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{
@@ -36,15 +38,30 @@ func NewAttendanceStore(ctx context.Context, db *MongoDB) (*AttendanceStore, err
 		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "dates", Value: 1}}},
 		{Keys: bson.D{{Key: "dates", Value: 1}}},
 		{Keys: bson.D{{Key: "team_id", Value: 1}, {Key: "dates", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "team_id", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "source", Value: 1}, {Key: "external_id", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
 	}); err != nil {
 		return nil, fmt.Errorf("create leave_requests indexes: %w", err)
 	}
 
-	return &AttendanceStore{attendance: attendance, leave: leave}, nil
+	if _, err := adjustments.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "source", Value: 1}, {Key: "external_id", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	}); err != nil {
+		return nil, fmt.Errorf("create comp_time_adjustments indexes: %w", err)
+	}
+
+	return &mongoAttendanceStore{attendance: attendance, leave: leave, adjustments: adjustments}, nil
 }
 
 // GetTodayRecord returns today's attendance record for a user, or nil if not found.
-func (s *AttendanceStore) GetTodayRecord(ctx context.Context, userID, date string) (*model.AttendanceRecord, error) {
+func (s *mongoAttendanceStore) GetTodayRecord(ctx context.Context, userID, date string) (*model.AttendanceRecord, error) {
 	var record model.AttendanceRecord
 	err := s.attendance.FindOne(ctx, bson.M{
 		"user_id": userID,
@@ -60,7 +77,7 @@ func (s *AttendanceStore) GetTodayRecord(ctx context.Context, userID, date strin
 }
 
 // CreateRecord inserts a new attendance record and sets the ID on the struct.
-func (s *AttendanceStore) CreateRecord(ctx context.Context, record *model.AttendanceRecord) error {
+func (s *mongoAttendanceStore) CreateRecord(ctx context.Context, record *model.AttendanceRecord) error {
 	record.CreatedAt = time.Now()
 	record.UpdatedAt = time.Now()
 	res, err := s.attendance.InsertOne(ctx, record)
@@ -72,14 +89,14 @@ func (s *AttendanceStore) CreateRecord(ctx context.Context, record *model.Attend
 }
 
 // UpdateRecord updates an existing attendance record.
-func (s *AttendanceStore) UpdateRecord(ctx context.Context, record *model.AttendanceRecord) error {
+func (s *mongoAttendanceStore) UpdateRecord(ctx context.Context, record *model.AttendanceRecord) error {
 	record.UpdatedAt = time.Now()
 	_, err := s.attendance.ReplaceOne(ctx, bson.M{"_id": record.ID}, record)
 	return err
 }
 
 // CreateLeaveRequest inserts a new leave request and sets the ID on the struct.
-func (s *AttendanceStore) CreateLeaveRequest(ctx context.Context, req *model.LeaveRequest) error {
+func (s *mongoAttendanceStore) CreateLeaveRequest(ctx context.Context, req *model.LeaveRequest) error {
 	req.CreatedAt = time.Now()
 	req.UpdatedAt = time.Now()
 	res, err := s.leave.InsertOne(ctx, req)
@@ -91,7 +108,7 @@ func (s *AttendanceStore) CreateLeaveRequest(ctx context.Context, req *model.Lea
 }
 
 // GetLeaveRequestByID retrieves a leave request by its ObjectID.
-func (s *AttendanceStore) GetLeaveRequestByID(ctx context.Context, id bson.ObjectID) (*model.LeaveRequest, error) {
+func (s *mongoAttendanceStore) GetLeaveRequestByID(ctx context.Context, id bson.ObjectID) (*model.LeaveRequest, error) {
 	var req model.LeaveRequest
 	err := s.leave.FindOne(ctx, bson.M{"_id": id}).Decode(&req)
 	if err == mongo.ErrNoDocuments {
@@ -104,7 +121,7 @@ func (s *AttendanceStore) GetLeaveRequestByID(ctx context.Context, id bson.Objec
 }
 
 // FindLeaveRequestsByUserAndDates returns leave requests for a user that contain any of the given dates.
-func (s *AttendanceStore) FindLeaveRequestsByUserAndDates(ctx context.Context, userID string, dates []string) ([]model.LeaveRequest, error) {
+func (s *mongoAttendanceStore) FindLeaveRequestsByUserAndDates(ctx context.Context, userID string, dates []string) ([]model.LeaveRequest, error) {
 	cursor, err := s.leave.Find(ctx, bson.M{
 		"user_id": userID,
 		"dates":   bson.M{"$elemMatch": bson.M{"$in": dates}},
@@ -121,7 +138,7 @@ func (s *AttendanceStore) FindLeaveRequestsByUserAndDates(ctx context.Context, u
 
 // FindFutureLeaveRequestsByUser returns pending or approved leave requests
 // where at least one date is >= fromDate.
-func (s *AttendanceStore) FindFutureLeaveRequestsByUser(ctx context.Context, userID string, fromDate string) ([]model.LeaveRequest, error) {
+func (s *mongoAttendanceStore) FindFutureLeaveRequestsByUser(ctx context.Context, userID string, fromDate string) ([]model.LeaveRequest, error) {
 	cursor, err := s.leave.Find(ctx, bson.M{
 		"user_id": userID,
 		"status":  bson.M{"$in": []string{string(model.LeaveStatusPending), string(model.LeaveStatusApproved)}},
@@ -137,15 +154,32 @@ func (s *AttendanceStore) FindFutureLeaveRequestsByUser(ctx context.Context, use
 	return results, nil
 }
 
+// FindAwaitingApproval returns a team's leave requests awaiting a decision: new requests
+// and date changes, oldest first.
+func (s *mongoAttendanceStore) FindAwaitingApproval(ctx context.Context, teamID string) ([]*model.LeaveRequest, error) {
+	cursor, err := s.leave.Find(ctx, bson.M{
+		"team_id": teamID,
+		"status":  bson.M{"$in": []string{string(model.LeaveStatusPending), string(model.LeaveStatusPendingChange)}},
+	}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("find pending leave requests: %w", err)
+	}
+	var results []*model.LeaveRequest
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("decode pending leave requests: %w", err)
+	}
+	return results, nil
+}
+
 // UpdateLeaveRequest updates an existing leave request.
-func (s *AttendanceStore) UpdateLeaveRequest(ctx context.Context, req *model.LeaveRequest) error {
+func (s *mongoAttendanceStore) UpdateLeaveRequest(ctx context.Context, req *model.LeaveRequest) error {
 	req.UpdatedAt = time.Now()
 	_, err := s.leave.ReplaceOne(ctx, bson.M{"_id": req.ID}, req)
 	return err
 }
 
 // GetLeaveRequestsByDate returns all leave requests that include the given date (YYYY-MM-DD).
-func (s *AttendanceStore) GetLeaveRequestsByDate(ctx context.Context, date string) ([]*model.LeaveRequest, error) {
+func (s *mongoAttendanceStore) GetLeaveRequestsByDate(ctx context.Context, date string) ([]*model.LeaveRequest, error) {
 	cursor, err := s.leave.Find(ctx, bson.M{"dates": date})
 	if err != nil {
 		return nil, fmt.Errorf("find leave requests: %w", err)
@@ -158,7 +192,7 @@ func (s *AttendanceStore) GetLeaveRequestsByDate(ctx context.Context, date strin
 }
 
 // GetAttendanceByDate returns all attendance records for the given date (YYYY-MM-DD).
-func (s *AttendanceStore) GetAttendanceByDate(ctx context.Context, date string) ([]*model.AttendanceRecord, error) {
+func (s *mongoAttendanceStore) GetAttendanceByDate(ctx context.Context, date string) ([]*model.AttendanceRecord, error) {
 	cursor, err := s.attendance.Find(ctx, bson.M{"date": date})
 	if err != nil {
 		return nil, fmt.Errorf("find attendance: %w", err)
@@ -171,7 +205,7 @@ func (s *AttendanceStore) GetAttendanceByDate(ctx context.Context, date string)
 }
 
 // GetAttendanceByDateRange returns attendance records within a date range, optionally filtered by user, team and/or channel.
-func (s *AttendanceStore) GetAttendanceByDateRange(ctx context.Context, from, to, userID, teamID, channelID string) ([]*model.AttendanceRecord, error) {
+func (s *mongoAttendanceStore) GetAttendanceByDateRange(ctx context.Context, from, to, userID, teamID, channelID string) ([]*model.AttendanceRecord, error) {
 	filter := bson.M{"date": bson.M{"$gte": from, "$lte": to}}
 	if userID != "" {
 		filter["user_id"] = userID
@@ -194,7 +228,7 @@ func (s *AttendanceStore) GetAttendanceByDateRange(ctx context.Context, from, to
 }
 
 // GetLeaveRequestsByDateRange returns leave requests that overlap with a date range, optionally filtered by user, team and/or channel.
-func (s *AttendanceStore) GetLeaveRequestsByDateRange(ctx context.Context, from, to, userID, teamID, channelID string) ([]*model.LeaveRequest, error) {
+func (s *mongoAttendanceStore) GetLeaveRequestsByDateRange(ctx context.Context, from, to, userID, teamID, channelID string) ([]*model.LeaveRequest, error) {
 	filter := bson.M{"dates": bson.M{"$elemMatch": bson.M{"$gte": from, "$lte": to}}}
 	if userID != "" {
 		filter["user_id"] = userID
@@ -215,3 +249,226 @@ func (s *AttendanceStore) GetLeaveRequestsByDateRange(ctx context.Context, from,
 	}
 	return results, nil
 }
+
+// GetPhotoRecordsSince returns a user's attendance records from fromDate onward that have a photo hash.
+func (s *mongoAttendanceStore) GetPhotoRecordsSince(ctx context.Context, userID, fromDate string) ([]*model.AttendanceRecord, error) {
+	cursor, err := s.attendance.Find(ctx, bson.M{
+		"user_id": userID,
+		"date":    bson.M{"$gte": fromDate},
+		"$or": []bson.M{
+			{"checkin_photo_hash": bson.M{"$exists": true}},
+			{"checkout_photo_hash": bson.M{"$exists": true}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find photo records: %w", err)
+	}
+	var results []*model.AttendanceRecord
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("decode photo records: %w", err)
+	}
+	return results, nil
+}
+
+// GetApprovedOvertime returns the user's approved overtime request covering the given date, if any.
+func (s *mongoAttendanceStore) GetApprovedOvertime(ctx context.Context, userID, date string) (*model.LeaveRequest, error) {
+	var req model.LeaveRequest
+	err := s.leave.FindOne(ctx, bson.M{
+		"user_id": userID,
+		"type":    model.LeaveTypeOvertime,
+		"status":  bson.M{"$in": []model.LeaveStatus{model.LeaveStatusApproved, model.LeaveStatusPendingCancel}},
+		"dates":   date,
+	}).Decode(&req)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find overtime request: %w", err)
+	}
+	return &req, nil
+}
+
+// GetApprovedWorkMode returns the user's approved remote or business-trip request covering the given date, if any.
+func (s *mongoAttendanceStore) GetApprovedWorkMode(ctx context.Context, userID, date string) (*model.LeaveRequest, error) {
+	var req model.LeaveRequest
+	err := s.leave.FindOne(ctx, bson.M{
+		"user_id": userID,
+		"type":    bson.M{"$in": []model.LeaveType{model.LeaveTypeRemote, model.LeaveTypeBusinessTrip}},
+		"status":  bson.M{"$in": []model.LeaveStatus{model.LeaveStatusApproved, model.LeaveStatusPendingCancel}},
+		"dates":   date,
+	}).Decode(&req)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find work mode request: %w", err)
+	}
+	return &req, nil
+}
+
+// GetCompTimeBalance returns a user's comp time in minutes: credited compensatory overtime
+// and adjustments less what pending and approved day-off requests spend.
+func (s *mongoAttendanceStore) GetCompTimeBalance(ctx context.Context, userID string) (int, error) {
+	cursor, err := s.leave.Find(ctx, bson.M{
+		"user_id": userID,
+		"$or": []bson.M{
+			{"type": model.LeaveTypeOvertime, "compensatory_leave": true},
+			{"type": model.LeaveTypeOff, "comp_time_day_minutes": bson.M{"$gt": 0}},
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("find comp time requests: %w", err)
+	}
+	var reqs []*model.LeaveRequest
+	if err := cursor.All(ctx, &reqs); err != nil {
+		return 0, fmt.Errorf("decode comp time requests: %w", err)
+	}
+	balance := model.CompTimeBalance(reqs)
+
+	cursor, err = s.adjustments.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, fmt.Errorf("find comp time adjustments: %w", err)
+	}
+	var adjustments []*model.CompTimeAdjustment
+	if err := cursor.All(ctx, &adjustments); err != nil {
+		return 0, fmt.Errorf("decode comp time adjustments: %w", err)
+	}
+	for _, adj := range adjustments {
+		balance += adj.Minutes
+	}
+	return balance, nil
+}
+
+// CreateCompTimeAdjustment inserts a new adjustment and sets the ID on the struct.
+func (s *mongoAttendanceStore) CreateCompTimeAdjustment(ctx context.Context, adj *model.CompTimeAdjustment) error {
+	adj.CreatedAt = time.Now()
+	res, err := s.adjustments.InsertOne(ctx, adj)
+	if err != nil {
+		return fmt.Errorf("insert comp time adjustment: %w", err)
+	}
+	adj.ID = res.InsertedID.(bson.ObjectID)
+	return nil
+}
+
+// FindCompTimeAdjustment returns the adjustment a source imported with an external ID, or nil.
+func (s *mongoAttendanceStore) FindCompTimeAdjustment(ctx context.Context, source, externalID string) (*model.CompTimeAdjustment, error) {
+	var adj model.CompTimeAdjustment
+	err := s.adjustments.FindOne(ctx, bson.M{"source": source, "external_id": externalID}).Decode(&adj)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find comp time adjustment: %w", err)
+	}
+	return &adj, nil
+}
+
+// LeaveRequestFilter narrows ListLeaveRequests. Empty fields match everything; From and To
+// (YYYY-MM-DD) match requests with any date in the range.
+type LeaveRequestFilter struct {
+	UserID     string
+	TeamID     string
+	Status     model.LeaveStatus
+	Type       model.LeaveType
+	Source     string
+	ExternalID string
+	From       string
+	To         string
+}
+
+// ListLeaveRequests returns a page of leave requests, newest first, and the total number matching.
+func (s *mongoAttendanceStore) ListLeaveRequests(ctx context.Context, f LeaveRequestFilter, skip, limit int) ([]*model.LeaveRequest, int64, error) {
+	filter := bson.M{}
+	if f.UserID != "" {
+		filter["user_id"] = f.UserID
+	}
+	if f.TeamID != "" {
+		filter["team_id"] = f.TeamID
+	}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+	if f.Type != "" {
+		filter["type"] = f.Type
+	}
+	if f.Source != "" {
+		filter["source"] = f.Source
+	}
+	if f.ExternalID != "" {
+		filter["external_id"] = f.ExternalID
+	}
+	if dates := dateRange(f.From, f.To); dates != nil {
+		filter["dates"] = bson.M{"$elemMatch": dates}
+	}
+	total, err := s.leave.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count leave requests: %w", err)
+	}
+	cursor, err := s.leave.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(skip)).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("find leave requests: %w", err)
+	}
+	results := []*model.LeaveRequest{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, 0, fmt.Errorf("decode leave requests: %w", err)
+	}
+	return results, total, nil
+}
+
+// AttendanceFilter narrows ListAttendance. Empty fields match everything.
+type AttendanceFilter struct {
+	UserID    string
+	TeamID    string
+	ChannelID string
+	From      string
+	To        string
+}
+
+// ListAttendance returns a page of attendance records, latest date first, and the total number matching.
+func (s *mongoAttendanceStore) ListAttendance(ctx context.Context, f AttendanceFilter, skip, limit int) ([]*model.AttendanceRecord, int64, error) {
+	filter := bson.M{}
+	if f.UserID != "" {
+		filter["user_id"] = f.UserID
+	}
+	if f.TeamID != "" {
+		filter["team_id"] = f.TeamID
+	}
+	if f.ChannelID != "" {
+		filter["channel_id"] = f.ChannelID
+	}
+	if dates := dateRange(f.From, f.To); dates != nil {
+		filter["date"] = dates
+	}
+	total, err := s.attendance.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count attendance: %w", err)
+	}
+	cursor, err := s.attendance.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "date", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(skip)).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("find attendance: %w", err)
+	}
+	results := []*model.AttendanceRecord{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, 0, fmt.Errorf("decode attendance: %w", err)
+	}
+	return results, total, nil
+}
+
+// dateRange builds a $gte/$lte condition from optional bounds, or nil if both are empty.
+func dateRange(from, to string) bson.M {
+	cond := bson.M{}
+	if from != "" {
+		cond["$gte"] = from
+	}
+	if to != "" {
+		cond["$lte"] = to
+	}
+	if len(cond) == 0 {
+		return nil
+	}
+	return cond
+}
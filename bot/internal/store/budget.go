@@ -7,15 +7,16 @@ import (
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 
 	"oktel-bot/internal/model"
 )
 
-type BudgetStore struct {
+type mongoBudgetStore struct {
 	coll *mongo.Collection
 }
 
-func NewBudgetStore(ctx context.Context, db *MongoDB) (*BudgetStore, error) {
+func NewBudgetStore(ctx context.Context, db *MongoDB) (BudgetStore, error) {
 	budget := db.Collection("budget_requests")
 
 	if _, err := budget.Indexes().CreateMany(ctx, []mongo.IndexModel{
@@ -25,11 +26,11 @@ func NewBudgetStore(ctx context.Context, db *MongoDB) (*BudgetStore, error) {
 		return nil, fmt.Errorf("create budget indexes: %w", err)
 	}
 
-	return &BudgetStore{coll: budget}, nil
+	return &mongoBudgetStore{coll: budget}, nil
 }
 
 // Create inserts a new budget request and sets the ID on the struct.
-func (s *BudgetStore) Create(ctx context.Context, req *model.BudgetRequest) error {
+func (s *mongoBudgetStore) Create(ctx context.Context, req *model.BudgetRequest) error {
 	req.CreatedAt = time.Now()
 	req.UpdatedAt = time.Now()
 	res, err := s.coll.InsertOne(ctx, req)
@@ -40,7 +41,7 @@ func (s *BudgetStore) Create(ctx context.Context, req *model.BudgetRequest) erro
 	return nil
 }
 
-func (s *BudgetStore) GetByID(ctx context.Context, id bson.ObjectID) (*model.BudgetRequest, error) {
+func (s *mongoBudgetStore) GetByID(ctx context.Context, id bson.ObjectID) (*model.BudgetRequest, error) {
 	var req model.BudgetRequest
 	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&req)
 	if err == mongo.ErrNoDocuments {
@@ -52,8 +53,68 @@ func (s *BudgetStore) GetByID(ctx context.Context, id bson.ObjectID) (*model.Bud
 	return &req, nil
 }
 
-func (s *BudgetStore) Update(ctx context.Context, req *model.BudgetRequest) error {
+func (s *mongoBudgetStore) Update(ctx context.Context, req *model.BudgetRequest) error {
 	req.UpdatedAt = time.Now()
 	_, err := s.coll.ReplaceOne(ctx, bson.M{"_id": req.ID}, req)
 	return err
 }
+
+// BudgetFilter narrows List. State is "open", "completed", "rejected" or "withdrawn"; CreatedFrom
+// (inclusive) and CreatedBefore (exclusive) bound the creation time. Zero fields match everything.
+type BudgetFilter struct {
+	TeamID        string
+	Step          model.BudgetStep
+	State         string
+	CreatedFrom   time.Time
+	CreatedBefore time.Time
+}
+
+// List returns a page of budget requests, newest first, and the total number matching.
+func (s *mongoBudgetStore) List(ctx context.Context, f BudgetFilter, skip, limit int) ([]*model.BudgetRequest, int64, error) {
+	filter := bson.M{}
+	if f.TeamID != "" {
+		filter["team_id"] = f.TeamID
+	}
+	if f.Step != 0 {
+		filter["current_step"] = f.Step
+	}
+	switch f.State {
+	case "open":
+		if f.Step == 0 {
+			filter["current_step"] = bson.M{"$lt": model.BudgetStepCompleted}
+		}
+		filter["rejected_at"] = bson.M{"$exists": false}
+		filter["withdrawn_at"] = bson.M{"$exists": false}
+	case "completed":
+		filter["current_step"] = model.BudgetStepCompleted
+	case "rejected":
+		filter["rejected_at"] = bson.M{"$exists": true}
+	case "withdrawn":
+		filter["withdrawn_at"] = bson.M{"$exists": true}
+	}
+	created := bson.M{}
+	if !f.CreatedFrom.IsZero() {
+		created["$gte"] = f.CreatedFrom
+	}
+	if !f.CreatedBefore.IsZero() {
+		created["$lt"] = f.CreatedBefore
+	}
+	if len(created) > 0 {
+		filter["created_at"] = created
+	}
+	total, err := s.coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count budget requests: %w", err)
+	}
+	cursor, err := s.coll.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(skip)).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("find budget requests: %w", err)
+	}
+	results := []*model.BudgetRequest{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, 0, fmt.Errorf("decode budget requests: %w", err)
+	}
+	return results, total, nil
+}
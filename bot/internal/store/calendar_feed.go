@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"oktel-bot/internal/model"
+)
+
+type mongoCalendarFeedStore struct {
+	coll *mongo.Collection
+}
+
+func NewCalendarFeedStore(ctx context.Context, db *MongoDB) (CalendarFeedStore, error) {
+	return &mongoCalendarFeedStore{coll: db.Collection("calendar_feeds")}, nil
+}
+
+// Get returns a user's feed key, or nil if none was issued yet.
+func (s *mongoCalendarFeedStore) Get(ctx context.Context, userID string) (*model.CalendarFeed, error) {
+	var feed model.CalendarFeed
+	err := s.coll.FindOne(ctx, bson.M{"_id": userID}).Decode(&feed)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find calendar feed: %w", err)
+	}
+	return &feed, nil
+}
+
+// Create saves a user's first feed key. It does nothing if the user already has one.
+func (s *mongoCalendarFeedStore) Create(ctx context.Context, feed *model.CalendarFeed) error {
+	_, err := s.coll.UpdateOne(ctx,
+		bson.M{"_id": feed.UserID},
+		bson.M{"$setOnInsert": bson.M{"key": feed.Key, "updated_at": feed.UpdatedAt}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("create calendar feed: %w", err)
+	}
+	return nil
+}
+
+// Save replaces a user's feed key, creating it if needed.
+func (s *mongoCalendarFeedStore) Save(ctx context.Context, feed *model.CalendarFeed) error {
+	_, err := s.coll.ReplaceOne(ctx, bson.M{"_id": feed.UserID}, feed, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("save calendar feed: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a user's feed key, if any.
+func (s *mongoCalendarFeedStore) Delete(ctx context.Context, userID string) error {
+	if _, err := s.coll.DeleteOne(ctx, bson.M{"_id": userID}); err != nil {
+		return fmt.Errorf("delete calendar feed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"oktel-bot/internal/model"
+)
+
+type mongoDelegationStore struct {
+	coll *mongo.Collection
+}
+
+func NewDelegationStore(ctx context.Context, db *MongoDB) (DelegationStore, error) {
+	delegations := db.Collection("delegations")
+
+	if _, err := delegations.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "delegator_id", Value: 1}, {Key: "to", Value: 1}}},
+		{Keys: bson.D{{Key: "delegate_id", Value: 1}, {Key: "to", Value: 1}}},
+		{Keys: bson.D{{Key: "leave_request_id", Value: 1}}},
+	}); err != nil {
+		return nil, fmt.Errorf("create delegations indexes: %w", err)
+	}
+
+	return &mongoDelegationStore{coll: delegations}, nil
+}
+
+// Create inserts a new delegation and sets the ID on the struct.
+func (s *mongoDelegationStore) Create(ctx context.Context, d *model.Delegation) error {
+	d.CreatedAt = time.Now()
+	res, err := s.coll.InsertOne(ctx, d)
+	if err != nil {
+		return err
+	}
+	d.ID = res.InsertedID.(bson.ObjectID)
+	return nil
+}
+
+// FindActive returns delegations covering the given date for a scope, optionally
+// filtered by delegator and/or delegate user ID.
+func (s *mongoDelegationStore) FindActive(ctx context.Context, date string, scope model.DelegationScope, delegatorID, delegateID string) ([]*model.Delegation, error) {
+	filter := bson.M{
+		"from":  bson.M{"$lte": date},
+		"to":    bson.M{"$gte": date},
+		"scope": bson.M{"$in": []model.DelegationScope{scope, model.DelegationScopeAll}},
+	}
+	if delegatorID != "" {
+		filter["delegator_id"] = delegatorID
+	}
+	if delegateID != "" {
+		filter["delegate_id"] = delegateID
+	}
+	return s.find(ctx, filter)
+}
+
+// FindActiveByDelegatorUsername returns the delegation covering the given date for a delegator, or nil if none.
+func (s *mongoDelegationStore) FindActiveByDelegatorUsername(ctx context.Context, date string, scope model.DelegationScope, username string) (*model.Delegation, error) {
+	var d model.Delegation
+	err := s.coll.FindOne(ctx, bson.M{
+		"delegator_username": username,
+		"from":               bson.M{"$lte": date},
+		"to":                 bson.M{"$gte": date},
+		"scope":              bson.M{"$in": []model.DelegationScope{scope, model.DelegationScopeAll}},
+	}).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find delegation: %w", err)
+	}
+	return &d, nil
+}
+
+// DeleteByLeaveRequest removes the delegations created automatically from a leave request
+// and returns them.
+func (s *mongoDelegationStore) DeleteByLeaveRequest(ctx context.Context, leaveRequestID string) ([]*model.Delegation, error) {
+	filter := bson.M{"leave_request_id": leaveRequestID}
+	deleted, err := s.find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.coll.DeleteMany(ctx, filter); err != nil {
+		return nil, fmt.Errorf("delete delegations: %w", err)
+	}
+	return deleted, nil
+}
+
+// AddChannel records an approval channel the delegate was added to.
+func (s *mongoDelegationStore) AddChannel(ctx context.Context, id bson.ObjectID, ch model.DelegationChannel) error {
+	_, err := s.coll.UpdateByID(ctx, id, bson.M{"$addToSet": bson.M{"added_channels": ch}})
+	return err
+}
+
+// ClearChannels forgets the approval channels the delegate was added to.
+func (s *mongoDelegationStore) ClearChannels(ctx context.Context, id bson.ObjectID) error {
+	_, err := s.coll.UpdateByID(ctx, id, bson.M{"$unset": bson.M{"added_channels": ""}})
+	return err
+}
+
+// FindEndedWithChannels returns the delegations that ended before date and still have
+// approval channels recorded.
+func (s *mongoDelegationStore) FindEndedWithChannels(ctx context.Context, date string) ([]*model.Delegation, error) {
+	return s.find(ctx, bson.M{
+		"to":               bson.M{"$lt": date},
+		"added_channels.0": bson.M{"$exists": true},
+	})
+}
+
+func (s *mongoDelegationStore) find(ctx context.Context, filter bson.M) ([]*model.Delegation, error) {
+	cursor, err := s.coll.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("find delegations: %w", err)
+	}
+	var results []*model.Delegation
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("decode delegations: %w", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,255 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	// migrationLockLease is how long a migration lock is held before another replica may
+	// take it over, in case the holder died mid-run.
+	migrationLockLease = 10 * time.Minute
+	// migrationLockRenew is how often the holder extends the lease while migrations run.
+	migrationLockRenew = migrationLockLease / 3
+	// migrationLockPoll is how often a replica retries a lock held by another one.
+	migrationLockPoll = 2 * time.Second
+)
+
+// Migration is one versioned change to the bot's collections. Up must be safe to re-run:
+// a replica that dies mid-migration leaves the version unrecorded and it runs again.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, run *MigrationRun) error
+}
+
+// AppliedMigration is a schema_migrations document recording a migration that ran.
+type AppliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+	DurationMs  int64     `bson:"duration_ms"`
+}
+
+// MigrationRun gives a migration access to the database. In dry-run mode writes are
+// counted and logged instead of applied.
+type MigrationRun struct {
+	db     *MongoDB
+	dryRun bool
+	label  string
+}
+
+// UpdateMany applies update to the documents of collection matching filter and returns
+// how many were (or, in dry-run mode, would be) modified.
+func (r *MigrationRun) UpdateMany(ctx context.Context, collection string, filter, update any) (int64, error) {
+	coll := r.db.Collection(collection)
+	if r.dryRun {
+		n, err := coll.CountDocuments(ctx, filter)
+		if err != nil {
+			return 0, fmt.Errorf("count %s: %w", collection, err)
+		}
+		log.Printf("migrate: %s: would update %d %s documents", r.label, n, collection)
+		return n, nil
+	}
+	res, err := coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, fmt.Errorf("update %s: %w", collection, err)
+	}
+	log.Printf("migrate: %s: updated %d %s documents", r.label, res.ModifiedCount, collection)
+	return res.ModifiedCount, nil
+}
+
+// Migrator applies the registered migrations in version order and records each one in
+// the schema_migrations collection.
+type Migrator struct {
+	db         *MongoDB
+	applied    *mongo.Collection
+	lock       *mongo.Collection
+	migrations []Migration
+}
+
+func NewMigrator(db *MongoDB) *Migrator {
+	ms := slices.Clone(migrations)
+	slices.SortFunc(ms, func(a, b Migration) int { return a.Version - b.Version })
+	return &Migrator{
+		db:         db,
+		applied:    db.Collection("schema_migrations"),
+		lock:       db.Collection("schema_migrations_lock"),
+		migrations: ms,
+	}
+}
+
+// Applied returns the recorded migrations, oldest version first.
+func (m *Migrator) Applied(ctx context.Context) ([]AppliedMigration, error) {
+	cursor, err := m.applied.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("find schema_migrations: %w", err)
+	}
+	var out []AppliedMigration
+	if err := cursor.All(ctx, &out); err != nil {
+		return nil, fmt.Errorf("decode schema_migrations: %w", err)
+	}
+	return out, nil
+}
+
+// Pending returns the migrations that have not been applied, in the order they would run.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		done[a.Version] = true
+	}
+	var pending []Migration
+	for _, mg := range m.migrations {
+		if !done[mg.Version] {
+			pending = append(pending, mg)
+		}
+	}
+	return pending, nil
+}
+
+// Up runs the pending migrations and returns how many ran. It holds the migration lock for
+// the whole run, renewing its lease, and waits for it while another replica migrates. A
+// dry run takes no lock and records nothing; it only logs what each migration would change.
+func (m *Migrator) Up(ctx context.Context, dryRun bool) (int, error) {
+	if !dryRun {
+		lockCtx, release, err := m.acquireLock(ctx)
+		if err != nil {
+			return 0, err
+		}
+		defer release()
+		ctx = lockCtx
+	}
+
+	// Read pending only once the lock is held: another replica may just have finished
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i, mg := range pending {
+		label := fmt.Sprintf("%03d %s", mg.Version, mg.Description)
+		if dryRun {
+			log.Printf("migrate: %s (dry run)", label)
+		} else {
+			log.Printf("migrate: applying %s", label)
+		}
+
+		start := time.Now()
+		if err := mg.Up(ctx, &MigrationRun{db: m.db, dryRun: dryRun, label: label}); err != nil {
+			return i, fmt.Errorf("migration %d (%s): %w", mg.Version, mg.Description, err)
+		}
+		if dryRun {
+			continue
+		}
+		if _, err := m.applied.InsertOne(ctx, AppliedMigration{
+			Version:     mg.Version,
+			Description: mg.Description,
+			AppliedAt:   time.Now(),
+			DurationMs:  time.Since(start).Milliseconds(),
+		}); err != nil {
+			return i, fmt.Errorf("record migration %d: %w", mg.Version, err)
+		}
+	}
+	return len(pending), nil
+}
+
+// acquireLock takes the migration lock, polling until it is free or its lease expires, and
+// renews the lease until the returned func releases it. The returned context is cancelled
+// if the lock is lost, so a run that outlives its lease stops before another replica starts.
+func (m *Migrator) acquireLock(ctx context.Context) (context.Context, func(), error) {
+	owner := lockOwner()
+	for waited := false; ; waited = true {
+		now := time.Now()
+		_, err := m.lock.UpdateOne(ctx,
+			bson.M{"_id": "migrate", "expires_at": bson.M{"$lt": now}},
+			bson.M{"$set": bson.M{"owner": owner, "locked_at": now, "expires_at": now.Add(migrationLockLease)}},
+			options.UpdateOne().SetUpsert(true),
+		)
+		if err == nil {
+			break
+		}
+		// The upsert collides with the live lock document while another replica holds it
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, nil, fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if !waited {
+			log.Println("migrate: another instance is migrating, waiting for its lock")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("acquire migration lock: %w", ctx.Err())
+		case <-time.After(migrationLockPoll):
+		}
+	}
+
+	lockCtx, cancelLock := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.renewLock(lockCtx, owner, cancelLock)
+	}()
+
+	return lockCtx, func() {
+		cancelLock(nil)
+		<-done
+		// Release even if ctx was cancelled so the next replica need not wait out the lease
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := m.lock.DeleteOne(releaseCtx, bson.M{"_id": "migrate", "owner": owner}); err != nil {
+			log.Printf("ERROR releasing migration lock: %v", err)
+		}
+	}, nil
+}
+
+// renewLock extends the lease every migrationLockRenew until ctx is done. It cancels the
+// run when the lock document no longer names this owner; a failed renewal is retried on
+// the next tick while the lease still has time left.
+func (m *Migrator) renewLock(ctx context.Context, owner string, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(migrationLockRenew)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		res, err := m.lock.UpdateOne(ctx,
+			bson.M{"_id": "migrate", "owner": owner},
+			bson.M{"$set": bson.M{"expires_at": now.Add(migrationLockLease)}},
+		)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("ERROR renewing migration lock: %v", err)
+			}
+			continue
+		}
+		if res.MatchedCount == 0 {
+			log.Println("ERROR migration lock was lost, stopping migrations")
+			cancel(errors.New("migration lock lost"))
+			return
+		}
+	}
+}
+
+// lockOwner identifies this process in the lock document.
+func lockOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(b))
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// migrations are the schema migrations in version order. Append new ones with the next
+// version; never renumber or edit one that has shipped.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "store missing or null attendance breaks as an empty list",
+		Up: func(ctx context.Context, run *MigrationRun) error {
+			// Records written with a nil slice stored breaks as null, which the API returns as-is
+			_, err := run.UpdateMany(ctx, "attendance",
+				bson.M{"$or": bson.A{bson.M{"breaks": nil}, bson.M{"breaks": bson.M{"$exists": false}}}},
+				bson.M{"$set": bson.M{"breaks": bson.A{}}},
+			)
+			return err
+		},
+	},
+	{
+		Version:     2,
+		Description: "set office mode on attendance records created before work modes",
+		Up: func(ctx context.Context, run *MigrationRun) error {
+			_, err := run.UpdateMany(ctx, "attendance",
+				bson.M{"mode": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"mode": "office"}},
+			)
+			return err
+		},
+	},
+	{
+		Version:     3,
+		Description: "store team break reasons as documents instead of IDs",
+		Up: func(ctx context.Context, run *MigrationRun) error {
+			_, err := run.UpdateMany(ctx, "team_settings",
+				bson.M{"break_reasons.0": bson.M{"$type": "string"}},
+				bson.A{bson.M{"$set": bson.M{"break_reasons": bson.M{
+					"$map": bson.M{"input": "$break_reasons", "in": bson.M{"id": "$$this"}},
+				}}}},
+			)
+			return err
+		},
+	},
+}
@@ -41,6 +41,62 @@ func (m *MongoDB) Collection(name string) *mongo.Collection {
 	return m.db.Collection(name)
 }
 
+// Ping checks that the primary can be reached, for the readiness check.
+func (m *MongoDB) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx, readpref.Primary())
+}
+
 func (m *MongoDB) Close(ctx context.Context) error {
 	return m.client.Disconnect(ctx)
 }
+
+// NewMongoStores creates every store on db, creating their indexes.
+func NewMongoStores(ctx context.Context, db *MongoDB) (*Stores, error) {
+	attendance, err := NewAttendanceStore(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("init attendance store: %w", err)
+	}
+	budget, err := NewBudgetStore(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("init budget store: %w", err)
+	}
+	delegation, err := NewDelegationStore(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("init delegation store: %w", err)
+	}
+	webhook, err := NewWebhookStore(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("init webhook store: %w", err)
+	}
+	apiKey, err := NewAPIKeyStore(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("init api key store: %w", err)
+	}
+	retention, err := NewRetentionStore(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("init retention store: %w", err)
+	}
+	teamSettings, err := NewTeamSettingsStore(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("init team settings store: %w", err)
+	}
+	timesheet, err := NewTimesheetStore(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("init timesheet store: %w", err)
+	}
+	calendarFeed, err := NewCalendarFeedStore(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("init calendar feed store: %w", err)
+	}
+	return &Stores{
+		Attendance:   attendance,
+		Budget:       budget,
+		Delegation:   delegation,
+		Webhook:      webhook,
+		APIKey:       apiKey,
+		Retention:    retention,
+		TeamSettings: teamSettings,
+		Timesheet:    timesheet,
+		CalendarFeed: calendarFeed,
+	}, nil
+}
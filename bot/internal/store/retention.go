@@ -0,0 +1,437 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"oktel-bot/internal/model"
+)
+
+// RetentionScope selects the teams a retention policy applies to: one team, or (with an
+// empty TeamID) every team except those with their own policy.
+type RetentionScope struct {
+	TeamID         string
+	ExcludeTeamIDs []string
+}
+
+func (sc RetentionScope) filter() bson.M {
+	if sc.TeamID != "" {
+		return bson.M{"team_id": sc.TeamID}
+	}
+	if len(sc.ExcludeTeamIDs) > 0 {
+		return bson.M{"team_id": bson.M{"$nin": sc.ExcludeTeamIDs}}
+	}
+	return bson.M{}
+}
+
+type mongoRetentionStore struct {
+	attendance  *mongo.Collection
+	leave       *mongo.Collection
+	delegations *mongo.Collection
+	summaries   *mongo.Collection
+	webhooks    *mongo.Collection
+	budgets     *mongo.Collection
+	timesheets  *mongo.Collection
+	feeds       *mongo.Collection
+	adjustments *mongo.Collection
+	runs        *mongo.Collection
+}
+
+func NewRetentionStore(ctx context.Context, db *MongoDB) (RetentionStore, error) {
+	summaries := db.Collection("attendance_summaries")
+	if _, err := summaries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "team_id", Value: 1}, {Key: "month", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "team_id", Value: 1}, {Key: "month", Value: 1}}},
+	}); err != nil {
+		return nil, fmt.Errorf("create attendance_summaries indexes: %w", err)
+	}
+
+	return &mongoRetentionStore{
+		attendance:  db.Collection("attendance"),
+		leave:       db.Collection("leave_requests"),
+		delegations: db.Collection("delegations"),
+		summaries:   summaries,
+		webhooks:    db.Collection("webhook_deliveries"),
+		budgets:     db.Collection("budget_requests"),
+		timesheets:  db.Collection("timesheets"),
+		feeds:       db.Collection("calendar_feeds"),
+		adjustments: db.Collection("comp_time_adjustments"),
+		runs:        db.Collection("retention_runs"),
+	}, nil
+}
+
+// ClaimRun records the start of a daily run. It returns false if a run for that date
+// already exists, e.g. because another replica started it.
+func (s *mongoRetentionStore) ClaimRun(ctx context.Context, run *model.RetentionRun) (bool, error) {
+	if _, err := s.runs.InsertOne(ctx, run); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("insert retention run: %w", err)
+	}
+	return true, nil
+}
+
+// FinishRun saves the outcome of a run.
+func (s *mongoRetentionStore) FinishRun(ctx context.Context, run *model.RetentionRun) error {
+	_, err := s.runs.ReplaceOne(ctx, bson.M{"_id": run.Date}, run)
+	return err
+}
+
+// RecordsWithPhotos returns up to limit records in scope dated before the given date that
+// still reference a photo, in ID order starting after afterID.
+func (s *mongoRetentionStore) RecordsWithPhotos(ctx context.Context, sc RetentionScope, before string, afterID bson.ObjectID, limit int) ([]*model.AttendanceRecord, error) {
+	filter := sc.filter()
+	filter["date"] = bson.M{"$lt": before}
+	filter["_id"] = bson.M{"$gt": afterID}
+	filter["$or"] = bson.A{
+		bson.M{"checkin_image_id": bson.M{"$exists": true}},
+		bson.M{"checkout_image_id": bson.M{"$exists": true}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := s.attendance.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("find photo records: %w", err)
+	}
+	var records []*model.AttendanceRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("decode photo records: %w", err)
+	}
+	return records, nil
+}
+
+// ClearPhotos removes a record's photo references and photo hashes. File IDs are also
+// cleared from its photo flags; the flags themselves are kept.
+func (s *mongoRetentionStore) ClearPhotos(ctx context.Context, record *model.AttendanceRecord) error {
+	set := bson.M{"updated_at": time.Now()}
+	if len(record.PhotoFlags) > 0 {
+		flags := make([]model.PhotoFlag, len(record.PhotoFlags))
+		for i, f := range record.PhotoFlags {
+			f.FileID = ""
+			flags[i] = f
+		}
+		set["photo_flags"] = flags
+	}
+	_, err := s.attendance.UpdateByID(ctx, record.ID, bson.M{
+		"$set": set,
+		"$unset": bson.M{
+			"checkin_image_id":    "",
+			"checkout_image_id":   "",
+			"checkout_post_id":    "",
+			"checkin_photo_hash":  "",
+			"checkout_photo_hash": "",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("clear photos: %w", err)
+	}
+	return nil
+}
+
+// AnonymizeDevices removes device strings from records in scope dated before the given date.
+func (s *mongoRetentionStore) AnonymizeDevices(ctx context.Context, sc RetentionScope, before string) (int64, error) {
+	filter := sc.filter()
+	filter["date"] = bson.M{"$lt": before}
+	filter["$or"] = bson.A{
+		bson.M{"checkin_device": bson.M{"$exists": true}},
+		bson.M{"checkout_device": bson.M{"$exists": true}},
+		bson.M{"breaks.start_device": bson.M{"$exists": true}},
+		bson.M{"breaks.end_device": bson.M{"$exists": true}},
+	}
+	res, err := s.attendance.UpdateMany(ctx, filter, bson.M{
+		"$set": bson.M{"updated_at": time.Now()},
+		"$unset": bson.M{
+			"checkin_device":          "",
+			"checkout_device":         "",
+			"breaks.$[].start_device": "",
+			"breaks.$[].end_device":   "",
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("anonymize devices: %w", err)
+	}
+	return res.ModifiedCount, nil
+}
+
+// OldestDate returns the earliest attendance or leave date in scope before the given date,
+// or "" if there is none.
+func (s *mongoRetentionStore) OldestDate(ctx context.Context, sc RetentionScope, before string) (string, error) {
+	oldest := ""
+
+	filter := sc.filter()
+	filter["date"] = bson.M{"$lt": before}
+	var record model.AttendanceRecord
+	err := s.attendance.FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "date", Value: 1}})).Decode(&record)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return "", fmt.Errorf("find oldest attendance: %w", err)
+	}
+	if err == nil {
+		oldest = record.Date
+	}
+
+	// An ascending sort on an array field orders by its smallest element
+	filter = sc.filter()
+	filter["dates"] = bson.M{"$lt": before}
+	var req model.LeaveRequest
+	err = s.leave.FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "dates", Value: 1}})).Decode(&req)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return "", fmt.Errorf("find oldest leave request: %w", err)
+	}
+	for _, d := range req.Dates {
+		if oldest == "" || d < oldest {
+			oldest = d
+		}
+	}
+	return oldest, nil
+}
+
+// PeriodData returns the attendance records in scope dated in [from, to) and the leave
+// requests in scope with any date in that range.
+func (s *mongoRetentionStore) PeriodData(ctx context.Context, sc RetentionScope, from, to string) ([]*model.AttendanceRecord, []*model.LeaveRequest, error) {
+	filter := sc.filter()
+	filter["date"] = bson.M{"$gte": from, "$lt": to}
+	cursor, err := s.attendance.Find(ctx, filter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find attendance: %w", err)
+	}
+	var records []*model.AttendanceRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, nil, fmt.Errorf("decode attendance: %w", err)
+	}
+
+	filter = sc.filter()
+	filter["dates"] = bson.M{"$elemMatch": bson.M{"$gte": from, "$lt": to}}
+	cursor, err = s.leave.Find(ctx, filter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find leave requests: %w", err)
+	}
+	var leave []*model.LeaveRequest
+	if err := cursor.All(ctx, &leave); err != nil {
+		return nil, nil, fmt.Errorf("decode leave requests: %w", err)
+	}
+	return records, leave, nil
+}
+
+// SaveSummaries inserts or replaces monthly summaries.
+func (s *mongoRetentionStore) SaveSummaries(ctx context.Context, summaries []*model.AttendanceSummary) error {
+	for _, sum := range summaries {
+		sum.UpdatedAt = time.Now()
+		_, err := s.summaries.ReplaceOne(ctx,
+			bson.M{"user_id": sum.UserID, "team_id": sum.TeamID, "month": sum.Month},
+			sum,
+			options.Replace().SetUpsert(true),
+		)
+		if err != nil {
+			return fmt.Errorf("save summary: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteBefore deletes the attendance records in scope dated before the given date and the
+// leave requests in scope whose dates are all before it. Leave requests with later dates
+// too keep only those, so no data before the date remains.
+func (s *mongoRetentionStore) DeleteBefore(ctx context.Context, sc RetentionScope, before string) (int64, int64, error) {
+	filter := sc.filter()
+	filter["date"] = bson.M{"$lt": before}
+	records, err := s.attendance.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, 0, fmt.Errorf("delete attendance: %w", err)
+	}
+
+	filter = sc.filter()
+	filter["$and"] = bson.A{
+		bson.M{"dates": bson.M{"$lt": before}},
+		bson.M{"dates": bson.M{"$not": bson.M{"$gte": before}}},
+	}
+	leave, err := s.leave.DeleteMany(ctx, filter)
+	if err != nil {
+		return records.DeletedCount, 0, fmt.Errorf("delete leave requests: %w", err)
+	}
+
+	filter = sc.filter()
+	filter["dates"] = bson.M{"$lt": before}
+	if _, err := s.leave.UpdateMany(ctx, filter, bson.M{
+		"$pull": bson.M{"dates": bson.M{"$lt": before}},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}); err != nil {
+		return records.DeletedCount, leave.DeletedCount, fmt.Errorf("trim leave requests: %w", err)
+	}
+	return records.DeletedCount, leave.DeletedCount, nil
+}
+
+// UserData is everything the bot stores about one user.
+type UserData struct {
+	Attendance   []*model.AttendanceRecord   `json:"attendance"`
+	Leave        []*model.LeaveRequest       `json:"leave_requests"`
+	CompTime     []*model.CompTimeAdjustment `json:"comp_time_adjustments"`
+	Delegations  []*model.Delegation         `json:"delegations"`
+	Summaries    []*model.AttendanceSummary  `json:"monthly_summaries"`
+	Timesheets   []*UserTimesheetRow         `json:"timesheet_rows"`
+	Budgets      []*model.BudgetRequest      `json:"budget_requests"`
+	Webhooks     []*model.WebhookDelivery    `json:"webhook_deliveries"`
+	CalendarFeed *model.CalendarFeed         `json:"calendar_feed,omitempty"`
+}
+
+// UserTimesheetRow is a user's row of a team's monthly timesheet.
+type UserTimesheetRow struct {
+	TeamID string             `json:"team_id"`
+	Month  string             `json:"month"`
+	Row    model.TimesheetRow `json:"row"`
+}
+
+// TimesheetRowsOf returns userID's rows of sheets.
+func TimesheetRowsOf(sheets []*model.Timesheet, userID string) []*UserTimesheetRow {
+	var rows []*UserTimesheetRow
+	for _, sheet := range sheets {
+		if row := sheet.UserRow(userID); row != nil {
+			rows = append(rows, &UserTimesheetRow{TeamID: sheet.TeamID, Month: sheet.Month, Row: *row})
+		}
+	}
+	return rows
+}
+
+// GetUserData returns all attendance records, leave requests, comp time adjustments,
+// delegations (given or received), monthly summaries, timesheet rows, budget requests the
+// user took part in, webhook deliveries and the calendar feed of a user.
+func (s *mongoRetentionStore) GetUserData(ctx context.Context, userID string) (*UserData, error) {
+	data := &UserData{}
+	byDate := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
+	if err := findAll(ctx, s.attendance, bson.M{"user_id": userID}, byDate, &data.Attendance); err != nil {
+		return nil, fmt.Errorf("find attendance: %w", err)
+	}
+	byCreated := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	if err := findAll(ctx, s.leave, bson.M{"user_id": userID}, byCreated, &data.Leave); err != nil {
+		return nil, fmt.Errorf("find leave requests: %w", err)
+	}
+	if err := findAll(ctx, s.adjustments, bson.M{"user_id": userID}, byCreated, &data.CompTime); err != nil {
+		return nil, fmt.Errorf("find comp time adjustments: %w", err)
+	}
+	if err := findAll(ctx, s.delegations, delegationsOf(userID), byCreated, &data.Delegations); err != nil {
+		return nil, fmt.Errorf("find delegations: %w", err)
+	}
+	byMonth := options.Find().SetSort(bson.D{{Key: "month", Value: 1}})
+	if err := findAll(ctx, s.summaries, bson.M{"user_id": userID}, byMonth, &data.Summaries); err != nil {
+		return nil, fmt.Errorf("find summaries: %w", err)
+	}
+	var sheets []*model.Timesheet
+	if err := findAll(ctx, s.timesheets, bson.M{"rows.user_id": userID}, byMonth, &sheets); err != nil {
+		return nil, fmt.Errorf("find timesheets: %w", err)
+	}
+	data.Timesheets = TimesheetRowsOf(sheets, userID)
+	if err := findAll(ctx, s.budgets, budgetsOf(userID), byCreated, &data.Budgets); err != nil {
+		return nil, fmt.Errorf("find budget requests: %w", err)
+	}
+	if err := findAll(ctx, s.webhooks, bson.M{"user_id": userID}, byCreated, &data.Webhooks); err != nil {
+		return nil, fmt.Errorf("find webhook deliveries: %w", err)
+	}
+	var feed model.CalendarFeed
+	err := s.feeds.FindOne(ctx, bson.M{"_id": userID}).Decode(&feed)
+	switch {
+	case err == nil:
+		data.CalendarFeed = &feed
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return nil, fmt.Errorf("find calendar feed: %w", err)
+	}
+	return data, nil
+}
+
+// UserDataCounts is the number of documents removed by DeleteUserData. Timesheets and
+// Budgets count the documents the user was removed from; the documents themselves are
+// payroll and business records and are kept.
+type UserDataCounts struct {
+	Attendance    int64
+	Leave         int64
+	CompTime      int64
+	Delegations   int64
+	Summaries     int64
+	Timesheets    int64
+	Budgets       int64
+	Webhooks      int64
+	CalendarFeeds int64
+}
+
+// DeleteUserData deletes everything GetUserData returns for a user. Timesheet rows and
+// budget requests keep their figures but lose the user's ID and name.
+func (s *mongoRetentionStore) DeleteUserData(ctx context.Context, userID string) (*UserDataCounts, error) {
+	counts := &UserDataCounts{}
+	for _, d := range []struct {
+		coll   *mongo.Collection
+		filter bson.M
+		n      *int64
+	}{
+		{s.attendance, bson.M{"user_id": userID}, &counts.Attendance},
+		{s.leave, bson.M{"user_id": userID}, &counts.Leave},
+		{s.adjustments, bson.M{"user_id": userID}, &counts.CompTime},
+		{s.delegations, delegationsOf(userID), &counts.Delegations},
+		{s.summaries, bson.M{"user_id": userID}, &counts.Summaries},
+		{s.webhooks, bson.M{"user_id": userID}, &counts.Webhooks},
+		{s.feeds, bson.M{"_id": userID}, &counts.CalendarFeeds},
+	} {
+		res, err := d.coll.DeleteMany(ctx, d.filter)
+		if err != nil {
+			return counts, fmt.Errorf("delete from %s: %w", d.coll.Name(), err)
+		}
+		*d.n = res.DeletedCount
+	}
+
+	var sheets []*model.Timesheet
+	if err := findAll(ctx, s.timesheets, bson.M{"rows.user_id": userID}, options.Find(), &sheets); err != nil {
+		return counts, fmt.Errorf("find timesheets: %w", err)
+	}
+	for _, sheet := range sheets {
+		if !sheet.ForgetUser(userID) {
+			continue
+		}
+		if _, err := s.timesheets.ReplaceOne(ctx, bson.M{"_id": sheet.ID}, sheet); err != nil {
+			return counts, fmt.Errorf("update timesheet: %w", err)
+		}
+		counts.Timesheets++
+	}
+	var budgets []*model.BudgetRequest
+	if err := findAll(ctx, s.budgets, budgetsOf(userID), options.Find(), &budgets); err != nil {
+		return counts, fmt.Errorf("find budget requests: %w", err)
+	}
+	for _, req := range budgets {
+		if !req.ForgetUser(userID) {
+			continue
+		}
+		req.UpdatedAt = time.Now()
+		if _, err := s.budgets.ReplaceOne(ctx, bson.M{"_id": req.ID}, req); err != nil {
+			return counts, fmt.Errorf("update budget request: %w", err)
+		}
+		counts.Budgets++
+	}
+	return counts, nil
+}
+
+// budgetsOf matches the budget requests userID filled a step of or acted on.
+func budgetsOf(userID string) bson.M {
+	var or bson.A
+	for _, field := range []string{"sale_user_id", "partner_user_id", "tlqc_user_id", "approver_id",
+		"on_behalf_of_id", "finance_user_id", "history.user_id"} {
+		or = append(or, bson.M{field: userID})
+	}
+	return bson.M{"$or": or}
+}
+
+func delegationsOf(userID string) bson.M {
+	return bson.M{"$or": bson.A{bson.M{"delegator_id": userID}, bson.M{"delegate_id": userID}}}
+}
+
+func findAll[T any](ctx context.Context, coll *mongo.Collection, filter bson.M, opts *options.FindOptionsBuilder, out *[]T) error {
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, out)
+}
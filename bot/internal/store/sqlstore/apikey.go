@@ -0,0 +1,93 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/model"
+)
+
+type apiKeyStore struct {
+	db *sql.DB
+}
+
+func apiKeyColumns(key *model.APIKey) ([]column, error) {
+	doc, err := bson.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	return []column{
+		{"id", key.ID.Hex()},
+		{"hash", key.Hash},
+		{"created_at", millis(key.CreatedAt)},
+		{"doc", doc},
+	}, nil
+}
+
+func (s *apiKeyStore) Create(ctx context.Context, key *model.APIKey) error {
+	key.CreatedAt = time.Now()
+	newID(&key.ID)
+	cols, err := apiKeyColumns(key)
+	if err != nil {
+		return err
+	}
+	return insert(ctx, s.db, "oktel_api_keys", cols)
+}
+
+func (s *apiKeyStore) GetByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	key, err := getDoc[model.APIKey](ctx, s.db, `SELECT doc FROM oktel_api_keys WHERE hash = ?`, hash)
+	if err != nil {
+		return nil, fmt.Errorf("find api key: %w", err)
+	}
+	return key, nil
+}
+
+func (s *apiKeyStore) List(ctx context.Context) ([]*model.APIKey, error) {
+	results, err := selectDocs[model.APIKey](ctx, s.db, `SELECT doc FROM oktel_api_keys ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("find api keys: %w", err)
+	}
+	return results, nil
+}
+
+// modify applies fn to a key under a row lock and saves it if fn returns true. It reports
+// whether the key was saved.
+func (s *apiKeyStore) modify(ctx context.Context, id bson.ObjectID, fn func(key *model.APIKey) bool) (bool, error) {
+	saved := false
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		key, err := getDoc[model.APIKey](ctx, tx, `SELECT doc FROM oktel_api_keys WHERE id = ? FOR UPDATE`, id.Hex())
+		if err != nil || key == nil || !fn(key) {
+			return err
+		}
+		cols, err := apiKeyColumns(key)
+		if err != nil {
+			return err
+		}
+		saved = true
+		return update(ctx, tx, "oktel_api_keys", cols[0], cols[1:])
+	})
+	return saved, err
+}
+
+func (s *apiKeyStore) TouchLastUsed(ctx context.Context, id bson.ObjectID, at time.Time) error {
+	_, err := s.modify(ctx, id, func(key *model.APIKey) bool {
+		key.LastUsedAt = &at
+		return true
+	})
+	return err
+}
+
+func (s *apiKeyStore) Revoke(ctx context.Context, id bson.ObjectID) (bool, error) {
+	return s.modify(ctx, id, func(key *model.APIKey) bool {
+		if key.RevokedAt != nil {
+			return false
+		}
+		now := time.Now()
+		key.RevokedAt = &now
+		return true
+	})
+}
@@ -0,0 +1,343 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/store"
+)
+
+type attendanceStore struct {
+	db *sql.DB
+}
+
+func attendanceColumns(record *model.AttendanceRecord) ([]column, error) {
+	doc, err := bson.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	hasDevice := record.CheckInDevice != "" || record.CheckOutDevice != ""
+	for _, b := range record.Breaks {
+		hasDevice = hasDevice || b.StartDevice != "" || b.EndDevice != ""
+	}
+	return []column{
+		{"id", record.ID.Hex()},
+		{"user_id", record.UserID},
+		{"team_id", record.TeamID},
+		{"channel_id", record.ChannelID},
+		{"date", record.Date},
+		{"has_photo", record.CheckInImageID != "" || record.CheckOutImageID != ""},
+		{"has_device", hasDevice},
+		{"doc", doc},
+	}, nil
+}
+
+// saveRecord overwrites a stored attendance record.
+func saveRecord(ctx context.Context, q querier, record *model.AttendanceRecord) error {
+	cols, err := attendanceColumns(record)
+	if err != nil {
+		return err
+	}
+	return update(ctx, q, "oktel_attendance", cols[0], cols[1:])
+}
+
+func leaveColumns(req *model.LeaveRequest) ([]column, error) {
+	doc, err := bson.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return []column{
+		{"id", req.ID.Hex()},
+		{"user_id", req.UserID},
+		{"team_id", req.TeamID},
+		{"channel_id", req.ChannelID},
+		{"type", string(req.Type)},
+		{"status", string(req.Status)},
+		{"source", req.Source},
+		{"external_id", req.ExternalID},
+		{"dates", textArray(req.Dates)},
+		{"created_at", millis(req.CreatedAt)},
+		{"doc", doc},
+	}, nil
+}
+
+// saveLeave overwrites a stored leave request.
+func saveLeave(ctx context.Context, q querier, req *model.LeaveRequest) error {
+	cols, err := leaveColumns(req)
+	if err != nil {
+		return err
+	}
+	return update(ctx, q, "oktel_leave_requests", cols[0], cols[1:])
+}
+
+func (s *attendanceStore) GetTodayRecord(ctx context.Context, userID, date string) (*model.AttendanceRecord, error) {
+	record, err := getDoc[model.AttendanceRecord](ctx, s.db,
+		`SELECT doc FROM oktel_attendance WHERE user_id = ? AND date = ?`, userID, date)
+	if err != nil {
+		return nil, fmt.Errorf("find attendance: %w", err)
+	}
+	return record, nil
+}
+
+func (s *attendanceStore) CreateRecord(ctx context.Context, record *model.AttendanceRecord) error {
+	record.CreatedAt = time.Now()
+	record.UpdatedAt = time.Now()
+	newID(&record.ID)
+	cols, err := attendanceColumns(record)
+	if err != nil {
+		return err
+	}
+	return insert(ctx, s.db, "oktel_attendance", cols)
+}
+
+func (s *attendanceStore) UpdateRecord(ctx context.Context, record *model.AttendanceRecord) error {
+	record.UpdatedAt = time.Now()
+	return saveRecord(ctx, s.db, record)
+}
+
+func (s *attendanceStore) CreateLeaveRequest(ctx context.Context, req *model.LeaveRequest) error {
+	req.CreatedAt = time.Now()
+	req.UpdatedAt = time.Now()
+	newID(&req.ID)
+	cols, err := leaveColumns(req)
+	if err != nil {
+		return err
+	}
+	return insert(ctx, s.db, "oktel_leave_requests", cols)
+}
+
+func (s *attendanceStore) GetLeaveRequestByID(ctx context.Context, id bson.ObjectID) (*model.LeaveRequest, error) {
+	req, err := getDoc[model.LeaveRequest](ctx, s.db, `SELECT doc FROM oktel_leave_requests WHERE id = ?`, id.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("find leave request: %w", err)
+	}
+	return req, nil
+}
+
+func (s *attendanceStore) FindLeaveRequestsByUserAndDates(ctx context.Context, userID string, dates []string) ([]model.LeaveRequest, error) {
+	reqs, err := selectDocs[model.LeaveRequest](ctx, s.db,
+		`SELECT doc FROM oktel_leave_requests WHERE user_id = ? AND dates && ?::text[]`, userID, textArray(dates))
+	if err != nil {
+		return nil, fmt.Errorf("find leave requests: %w", err)
+	}
+	results := make([]model.LeaveRequest, len(reqs))
+	for i, req := range reqs {
+		results[i] = *req
+	}
+	return results, nil
+}
+
+func (s *attendanceStore) FindFutureLeaveRequestsByUser(ctx context.Context, userID string, fromDate string) ([]model.LeaveRequest, error) {
+	w := &where{}
+	w.eq("user_id", userID)
+	w.in("status", string(model.LeaveStatusPending), string(model.LeaveStatusApproved))
+	w.anyDate("d >= ?", fromDate)
+	reqs, err := selectDocs[model.LeaveRequest](ctx, s.db, `SELECT doc FROM oktel_leave_requests`+w.String(), w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("find future leave requests: %w", err)
+	}
+	results := make([]model.LeaveRequest, len(reqs))
+	for i, req := range reqs {
+		results[i] = *req
+	}
+	return results, nil
+}
+
+func (s *attendanceStore) FindAwaitingApproval(ctx context.Context, teamID string) ([]*model.LeaveRequest, error) {
+	w := &where{}
+	w.add("team_id = ?", teamID)
+	w.in("status", string(model.LeaveStatusPending), string(model.LeaveStatusPendingChange))
+	results, err := selectDocs[model.LeaveRequest](ctx, s.db,
+		`SELECT doc FROM oktel_leave_requests`+w.String()+` ORDER BY created_at, id`, w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("find pending leave requests: %w", err)
+	}
+	return results, nil
+}
+
+func (s *attendanceStore) UpdateLeaveRequest(ctx context.Context, req *model.LeaveRequest) error {
+	req.UpdatedAt = time.Now()
+	return saveLeave(ctx, s.db, req)
+}
+
+func (s *attendanceStore) GetLeaveRequestsByDate(ctx context.Context, date string) ([]*model.LeaveRequest, error) {
+	results, err := selectDocs[model.LeaveRequest](ctx, s.db, `SELECT doc FROM oktel_leave_requests WHERE ? = ANY(dates)`, date)
+	if err != nil {
+		return nil, fmt.Errorf("find leave requests: %w", err)
+	}
+	return results, nil
+}
+
+func (s *attendanceStore) GetAttendanceByDate(ctx context.Context, date string) ([]*model.AttendanceRecord, error) {
+	results, err := selectDocs[model.AttendanceRecord](ctx, s.db, `SELECT doc FROM oktel_attendance WHERE date = ?`, date)
+	if err != nil {
+		return nil, fmt.Errorf("find attendance: %w", err)
+	}
+	return results, nil
+}
+
+func (s *attendanceStore) GetAttendanceByDateRange(ctx context.Context, from, to, userID, teamID, channelID string) ([]*model.AttendanceRecord, error) {
+	w := &where{}
+	w.add("date >= ? AND date <= ?", from, to)
+	w.eq("user_id", userID)
+	w.eq("team_id", teamID)
+	w.eq("channel_id", channelID)
+	results, err := selectDocs[model.AttendanceRecord](ctx, s.db, `SELECT doc FROM oktel_attendance`+w.String(), w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("find attendance: %w", err)
+	}
+	return results, nil
+}
+
+func (s *attendanceStore) GetLeaveRequestsByDateRange(ctx context.Context, from, to, userID, teamID, channelID string) ([]*model.LeaveRequest, error) {
+	w := &where{}
+	w.anyDate("d >= ? AND d <= ?", from, to)
+	w.eq("user_id", userID)
+	w.eq("team_id", teamID)
+	w.eq("channel_id", channelID)
+	results, err := selectDocs[model.LeaveRequest](ctx, s.db, `SELECT doc FROM oktel_leave_requests`+w.String(), w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("find leave requests: %w", err)
+	}
+	return results, nil
+}
+
+func (s *attendanceStore) GetPhotoRecordsSince(ctx context.Context, userID, fromDate string) ([]*model.AttendanceRecord, error) {
+	records, err := selectDocs[model.AttendanceRecord](ctx, s.db,
+		`SELECT doc FROM oktel_attendance WHERE user_id = ? AND date >= ?`, userID, fromDate)
+	if err != nil {
+		return nil, fmt.Errorf("find photo records: %w", err)
+	}
+	// One user's records over the lookback window are few; keep those with a hash here
+	results := records[:0]
+	for _, r := range records {
+		if r.CheckInPhotoHash != "" || r.CheckOutPhotoHash != "" {
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+func (s *attendanceStore) GetApprovedOvertime(ctx context.Context, userID, date string) (*model.LeaveRequest, error) {
+	w := &where{}
+	w.add("user_id = ?", userID)
+	w.add("type = ?", string(model.LeaveTypeOvertime))
+	w.in("status", string(model.LeaveStatusApproved), string(model.LeaveStatusPendingCancel))
+	w.add("? = ANY(dates)", date)
+	req, err := getDoc[model.LeaveRequest](ctx, s.db, `SELECT doc FROM oktel_leave_requests`+w.String()+` LIMIT 1`, w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("find overtime request: %w", err)
+	}
+	return req, nil
+}
+
+func (s *attendanceStore) GetApprovedWorkMode(ctx context.Context, userID, date string) (*model.LeaveRequest, error) {
+	w := &where{}
+	w.add("user_id = ?", userID)
+	w.in("type", string(model.LeaveTypeRemote), string(model.LeaveTypeBusinessTrip))
+	w.in("status", string(model.LeaveStatusApproved), string(model.LeaveStatusPendingCancel))
+	w.add("? = ANY(dates)", date)
+	req, err := getDoc[model.LeaveRequest](ctx, s.db, `SELECT doc FROM oktel_leave_requests`+w.String()+` LIMIT 1`, w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("find work mode request: %w", err)
+	}
+	return req, nil
+}
+
+func (s *attendanceStore) GetCompTimeBalance(ctx context.Context, userID string) (int, error) {
+	w := &where{}
+	w.add("user_id = ?", userID)
+	w.in("type", string(model.LeaveTypeOvertime), string(model.LeaveTypeOff))
+	reqs, err := selectDocs[model.LeaveRequest](ctx, s.db, `SELECT doc FROM oktel_leave_requests`+w.String(), w.args...)
+	if err != nil {
+		return 0, fmt.Errorf("find comp time requests: %w", err)
+	}
+	var adjusted int
+	if err := s.db.QueryRowContext(ctx, rebind(`SELECT COALESCE(SUM(minutes), 0) FROM oktel_comp_time_adjustments WHERE user_id = ?`), userID).Scan(&adjusted); err != nil {
+		return 0, fmt.Errorf("sum comp time adjustments: %w", err)
+	}
+	return model.CompTimeBalance(reqs) + adjusted, nil
+}
+
+func (s *attendanceStore) CreateCompTimeAdjustment(ctx context.Context, adj *model.CompTimeAdjustment) error {
+	adj.CreatedAt = time.Now()
+	newID(&adj.ID)
+	doc, err := bson.Marshal(adj)
+	if err != nil {
+		return err
+	}
+	return insert(ctx, s.db, "oktel_comp_time_adjustments", []column{
+		{"id", adj.ID.Hex()},
+		{"user_id", adj.UserID},
+		{"source", adj.Source},
+		{"external_id", adj.ExternalID},
+		{"minutes", adj.Minutes},
+		{"doc", doc},
+	})
+}
+
+func (s *attendanceStore) FindCompTimeAdjustment(ctx context.Context, source, externalID string) (*model.CompTimeAdjustment, error) {
+	adj, err := getDoc[model.CompTimeAdjustment](ctx, s.db, `SELECT doc FROM oktel_comp_time_adjustments WHERE source = ? AND external_id = ? LIMIT 1`, source, externalID)
+	if err != nil {
+		return nil, fmt.Errorf("find comp time adjustment: %w", err)
+	}
+	return adj, nil
+}
+
+func (s *attendanceStore) ListLeaveRequests(ctx context.Context, f store.LeaveRequestFilter, skip, limit int) ([]*model.LeaveRequest, int64, error) {
+	w := &where{}
+	w.eq("user_id", f.UserID)
+	w.eq("team_id", f.TeamID)
+	w.eq("status", string(f.Status))
+	w.eq("type", string(f.Type))
+	w.eq("source", f.Source)
+	w.eq("external_id", f.ExternalID)
+	switch {
+	case f.From != "" && f.To != "":
+		w.anyDate("d >= ? AND d <= ?", f.From, f.To)
+	case f.From != "":
+		w.anyDate("d >= ?", f.From)
+	case f.To != "":
+		w.anyDate("d <= ?", f.To)
+	}
+	total, err := count(ctx, s.db, `SELECT COUNT(*) FROM oktel_leave_requests`+w.String(), w.args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count leave requests: %w", err)
+	}
+	results, err := selectDocs[model.LeaveRequest](ctx, s.db,
+		`SELECT doc FROM oktel_leave_requests`+w.String()+` ORDER BY created_at DESC, id DESC OFFSET ? LIMIT ?`,
+		append(w.args, skip, limit)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("find leave requests: %w", err)
+	}
+	return results, total, nil
+}
+
+func (s *attendanceStore) ListAttendance(ctx context.Context, f store.AttendanceFilter, skip, limit int) ([]*model.AttendanceRecord, int64, error) {
+	w := &where{}
+	w.eq("user_id", f.UserID)
+	w.eq("team_id", f.TeamID)
+	w.eq("channel_id", f.ChannelID)
+	if f.From != "" {
+		w.add("date >= ?", f.From)
+	}
+	if f.To != "" {
+		w.add("date <= ?", f.To)
+	}
+	total, err := count(ctx, s.db, `SELECT COUNT(*) FROM oktel_attendance`+w.String(), w.args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count attendance: %w", err)
+	}
+	results, err := selectDocs[model.AttendanceRecord](ctx, s.db,
+		`SELECT doc FROM oktel_attendance`+w.String()+` ORDER BY date DESC, id DESC OFFSET ? LIMIT ?`,
+		append(w.args, skip, limit)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("find attendance: %w", err)
+	}
+	return results, total, nil
+}
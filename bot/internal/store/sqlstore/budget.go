@@ -0,0 +1,99 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/store"
+)
+
+type budgetStore struct {
+	db *sql.DB
+}
+
+func budgetColumns(req *model.BudgetRequest) ([]column, error) {
+	doc, err := bson.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return []column{
+		{"id", req.ID.Hex()},
+		{"team_id", req.TeamID},
+		{"current_step", int64(req.CurrentStep)},
+		{"rejected", req.RejectedAt != nil},
+		{"withdrawn", req.WithdrawnAt != nil},
+		{"created_at", millis(req.CreatedAt)},
+		{"doc", doc},
+	}, nil
+}
+
+func (s *budgetStore) Create(ctx context.Context, req *model.BudgetRequest) error {
+	req.CreatedAt = time.Now()
+	req.UpdatedAt = time.Now()
+	newID(&req.ID)
+	cols, err := budgetColumns(req)
+	if err != nil {
+		return err
+	}
+	return insert(ctx, s.db, "oktel_budget_requests", cols)
+}
+
+func (s *budgetStore) GetByID(ctx context.Context, id bson.ObjectID) (*model.BudgetRequest, error) {
+	req, err := getDoc[model.BudgetRequest](ctx, s.db, `SELECT doc FROM oktel_budget_requests WHERE id = ?`, id.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("find budget request: %w", err)
+	}
+	return req, nil
+}
+
+func (s *budgetStore) Update(ctx context.Context, req *model.BudgetRequest) error {
+	req.UpdatedAt = time.Now()
+	cols, err := budgetColumns(req)
+	if err != nil {
+		return err
+	}
+	return update(ctx, s.db, "oktel_budget_requests", cols[0], cols[1:])
+}
+
+func (s *budgetStore) List(ctx context.Context, f store.BudgetFilter, skip, limit int) ([]*model.BudgetRequest, int64, error) {
+	w := &where{}
+	w.eq("team_id", f.TeamID)
+	if f.Step != 0 {
+		w.add("current_step = ?", int64(f.Step))
+	}
+	switch f.State {
+	case "open":
+		if f.Step == 0 {
+			w.add("current_step < ?", int64(model.BudgetStepCompleted))
+		}
+		w.add("NOT rejected AND NOT withdrawn")
+	case "completed":
+		w.add("current_step = ?", int64(model.BudgetStepCompleted))
+	case "rejected":
+		w.add("rejected")
+	case "withdrawn":
+		w.add("withdrawn")
+	}
+	if !f.CreatedFrom.IsZero() {
+		w.add("created_at >= ?", millis(f.CreatedFrom))
+	}
+	if !f.CreatedBefore.IsZero() {
+		w.add("created_at < ?", millis(f.CreatedBefore))
+	}
+	total, err := count(ctx, s.db, `SELECT COUNT(*) FROM oktel_budget_requests`+w.String(), w.args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count budget requests: %w", err)
+	}
+	results, err := selectDocs[model.BudgetRequest](ctx, s.db,
+		`SELECT doc FROM oktel_budget_requests`+w.String()+` ORDER BY created_at DESC, id DESC OFFSET ? LIMIT ?`,
+		append(w.args, skip, limit)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("find budget requests: %w", err)
+	}
+	return results, total, nil
+}
@@ -0,0 +1,56 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/model"
+)
+
+type calendarFeedStore struct {
+	db *sql.DB
+}
+
+func (s *calendarFeedStore) Get(ctx context.Context, userID string) (*model.CalendarFeed, error) {
+	feed, err := getDoc[model.CalendarFeed](ctx, s.db, `SELECT doc FROM oktel_calendar_feeds WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("find calendar feed: %w", err)
+	}
+	return feed, nil
+}
+
+func (s *calendarFeedStore) Create(ctx context.Context, feed *model.CalendarFeed) error {
+	doc, err := bson.Marshal(feed)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, rebind(`INSERT INTO oktel_calendar_feeds (user_id, doc) VALUES (?, ?) ON CONFLICT (user_id) DO NOTHING`),
+		feed.UserID, doc); err != nil {
+		return fmt.Errorf("create calendar feed: %w", err)
+	}
+	return nil
+}
+
+func (s *calendarFeedStore) Save(ctx context.Context, feed *model.CalendarFeed) error {
+	doc, err := bson.Marshal(feed)
+	if err != nil {
+		return err
+	}
+	if err := upsert(ctx, s.db, "oktel_calendar_feeds", []string{"user_id"}, []column{
+		{"user_id", feed.UserID},
+		{"doc", doc},
+	}); err != nil {
+		return fmt.Errorf("save calendar feed: %w", err)
+	}
+	return nil
+}
+
+func (s *calendarFeedStore) Delete(ctx context.Context, userID string) error {
+	if _, err := s.db.ExecContext(ctx, rebind(`DELETE FROM oktel_calendar_feeds WHERE user_id = ?`), userID); err != nil {
+		return fmt.Errorf("delete calendar feed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/model"
+)
+
+type delegationStore struct {
+	db *sql.DB
+}
+
+func delegationColumns(d *model.Delegation) ([]column, error) {
+	doc, err := bson.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return []column{
+		{"id", d.ID.Hex()},
+		{"delegator_id", d.DelegatorID},
+		{"delegator_username", d.DelegatorUsername},
+		{"delegate_id", d.DelegateID},
+		{"scope", string(d.Scope)},
+		{"from_date", d.From},
+		{"to_date", d.To},
+		{"leave_request_id", d.LeaveRequestID},
+		{"has_channels", len(d.AddedChannels) > 0},
+		{"created_at", millis(d.CreatedAt)},
+		{"doc", doc},
+	}, nil
+}
+
+func (s *delegationStore) Create(ctx context.Context, d *model.Delegation) error {
+	d.CreatedAt = time.Now()
+	newID(&d.ID)
+	cols, err := delegationColumns(d)
+	if err != nil {
+		return err
+	}
+	return insert(ctx, s.db, "oktel_delegations", cols)
+}
+
+// active starts the conditions of delegations of a scope covering a date.
+func active(date string, scope model.DelegationScope) *where {
+	w := &where{}
+	w.add("from_date <= ? AND to_date >= ?", date, date)
+	w.in("scope", string(scope), string(model.DelegationScopeAll))
+	return w
+}
+
+func (s *delegationStore) FindActive(ctx context.Context, date string, scope model.DelegationScope, delegatorID, delegateID string) ([]*model.Delegation, error) {
+	w := active(date, scope)
+	w.eq("delegator_id", delegatorID)
+	w.eq("delegate_id", delegateID)
+	results, err := selectDocs[model.Delegation](ctx, s.db, `SELECT doc FROM oktel_delegations`+w.String(), w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("find delegations: %w", err)
+	}
+	return results, nil
+}
+
+func (s *delegationStore) FindActiveByDelegatorUsername(ctx context.Context, date string, scope model.DelegationScope, username string) (*model.Delegation, error) {
+	w := active(date, scope)
+	w.add("delegator_username = ?", username)
+	d, err := getDoc[model.Delegation](ctx, s.db, `SELECT doc FROM oktel_delegations`+w.String()+` LIMIT 1`, w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("find delegation: %w", err)
+	}
+	return d, nil
+}
+
+func (s *delegationStore) DeleteByLeaveRequest(ctx context.Context, leaveRequestID string) ([]*model.Delegation, error) {
+	deleted, err := selectDocs[model.Delegation](ctx, s.db,
+		`DELETE FROM oktel_delegations WHERE leave_request_id = ? RETURNING doc`, leaveRequestID)
+	if err != nil {
+		return nil, fmt.Errorf("delete delegations: %w", err)
+	}
+	return deleted, nil
+}
+
+// modify applies fn to a delegation under a row lock and saves it.
+func (s *delegationStore) modify(ctx context.Context, id bson.ObjectID, fn func(d *model.Delegation)) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		d, err := getDoc[model.Delegation](ctx, tx, `SELECT doc FROM oktel_delegations WHERE id = ? FOR UPDATE`, id.Hex())
+		if err != nil || d == nil {
+			return err
+		}
+		fn(d)
+		cols, err := delegationColumns(d)
+		if err != nil {
+			return err
+		}
+		return update(ctx, tx, "oktel_delegations", cols[0], cols[1:])
+	})
+}
+
+func (s *delegationStore) AddChannel(ctx context.Context, id bson.ObjectID, ch model.DelegationChannel) error {
+	return s.modify(ctx, id, func(d *model.Delegation) {
+		if !slices.Contains(d.AddedChannels, ch) {
+			d.AddedChannels = append(d.AddedChannels, ch)
+		}
+	})
+}
+
+func (s *delegationStore) ClearChannels(ctx context.Context, id bson.ObjectID) error {
+	return s.modify(ctx, id, func(d *model.Delegation) {
+		d.AddedChannels = nil
+	})
+}
+
+func (s *delegationStore) FindEndedWithChannels(ctx context.Context, date string) ([]*model.Delegation, error) {
+	results, err := selectDocs[model.Delegation](ctx, s.db,
+		`SELECT doc FROM oktel_delegations WHERE to_date < ? AND has_channels`, date)
+	if err != nil {
+		return nil, fmt.Errorf("find delegations: %w", err)
+	}
+	return results, nil
+}
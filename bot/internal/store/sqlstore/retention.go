@@ -0,0 +1,366 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/model"
+	"oktel-bot/internal/store"
+)
+
+type retentionStore struct {
+	db *sql.DB
+}
+
+func (s *retentionStore) ClaimRun(ctx context.Context, run *model.RetentionRun) (bool, error) {
+	doc, err := bson.Marshal(run)
+	if err != nil {
+		return false, err
+	}
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO oktel_retention_runs (date, doc) VALUES ($1, $2) ON CONFLICT (date) DO NOTHING`, run.Date, doc)
+	if err != nil {
+		return false, fmt.Errorf("insert retention run: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("insert retention run: %w", err)
+	}
+	return n == 1, nil
+}
+
+func (s *retentionStore) FinishRun(ctx context.Context, run *model.RetentionRun) error {
+	doc, err := bson.Marshal(run)
+	if err != nil {
+		return err
+	}
+	return update(ctx, s.db, "oktel_retention_runs", column{"date", run.Date}, []column{{"doc", doc}})
+}
+
+func (s *retentionStore) RecordsWithPhotos(ctx context.Context, sc store.RetentionScope, before string, afterID bson.ObjectID, limit int) ([]*model.AttendanceRecord, error) {
+	w := &where{}
+	w.scope(sc)
+	w.add("date < ?", before)
+	w.add("id > ?", afterID.Hex())
+	w.add("has_photo")
+	records, err := selectDocs[model.AttendanceRecord](ctx, s.db,
+		`SELECT doc FROM oktel_attendance`+w.String()+` ORDER BY id LIMIT ?`, append(w.args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("find photo records: %w", err)
+	}
+	return records, nil
+}
+
+// modifyRecords applies fn to the records matching w under a row lock and saves them. It
+// returns the number of records.
+func (s *retentionStore) modifyRecords(ctx context.Context, w *where, fn func(record *model.AttendanceRecord)) (int64, error) {
+	var n int64
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		records, err := selectDocs[model.AttendanceRecord](ctx, tx,
+			`SELECT doc FROM oktel_attendance`+w.String()+` FOR UPDATE`, w.args...)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			fn(record)
+			record.UpdatedAt = time.Now()
+			if err := saveRecord(ctx, tx, record); err != nil {
+				return err
+			}
+		}
+		n = int64(len(records))
+		return nil
+	})
+	return n, err
+}
+
+func (s *retentionStore) ClearPhotos(ctx context.Context, record *model.AttendanceRecord) error {
+	w := &where{}
+	w.add("id = ?", record.ID.Hex())
+	_, err := s.modifyRecords(ctx, w, func(record *model.AttendanceRecord) {
+		record.CheckInImageID = ""
+		record.CheckOutImageID = ""
+		record.CheckOutPostID = ""
+		record.CheckInPhotoHash = ""
+		record.CheckOutPhotoHash = ""
+		for i := range record.PhotoFlags {
+			record.PhotoFlags[i].FileID = ""
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("clear photos: %w", err)
+	}
+	return nil
+}
+
+func (s *retentionStore) AnonymizeDevices(ctx context.Context, sc store.RetentionScope, before string) (int64, error) {
+	w := &where{}
+	w.scope(sc)
+	w.add("date < ?", before)
+	w.add("has_device")
+	n, err := s.modifyRecords(ctx, w, func(record *model.AttendanceRecord) {
+		record.CheckInDevice = ""
+		record.CheckOutDevice = ""
+		for i := range record.Breaks {
+			record.Breaks[i].StartDevice = ""
+			record.Breaks[i].EndDevice = ""
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("anonymize devices: %w", err)
+	}
+	return n, nil
+}
+
+func (s *retentionStore) OldestDate(ctx context.Context, sc store.RetentionScope, before string) (string, error) {
+	w := &where{}
+	w.scope(sc)
+	w.add("date < ?", before)
+	var oldest sql.NullString
+	if err := s.db.QueryRowContext(ctx, rebind(`SELECT MIN(date) FROM oktel_attendance`+w.String()), w.args...).Scan(&oldest); err != nil {
+		return "", fmt.Errorf("find oldest attendance: %w", err)
+	}
+
+	w = &where{}
+	w.scope(sc)
+	w.add("d < ?", before)
+	var oldestLeave sql.NullString
+	if err := s.db.QueryRowContext(ctx, rebind(`SELECT MIN(d) FROM oktel_leave_requests, unnest(dates) d`+w.String()), w.args...).Scan(&oldestLeave); err != nil {
+		return "", fmt.Errorf("find oldest leave request: %w", err)
+	}
+	if oldestLeave.Valid && (!oldest.Valid || oldestLeave.String < oldest.String) {
+		return oldestLeave.String, nil
+	}
+	return oldest.String, nil
+}
+
+func (s *retentionStore) PeriodData(ctx context.Context, sc store.RetentionScope, from, to string) ([]*model.AttendanceRecord, []*model.LeaveRequest, error) {
+	w := &where{}
+	w.scope(sc)
+	w.add("date >= ? AND date < ?", from, to)
+	records, err := selectDocs[model.AttendanceRecord](ctx, s.db, `SELECT doc FROM oktel_attendance`+w.String(), w.args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find attendance: %w", err)
+	}
+
+	w = &where{}
+	w.scope(sc)
+	w.anyDate("d >= ? AND d < ?", from, to)
+	leave, err := selectDocs[model.LeaveRequest](ctx, s.db, `SELECT doc FROM oktel_leave_requests`+w.String(), w.args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find leave requests: %w", err)
+	}
+	return records, leave, nil
+}
+
+func (s *retentionStore) SaveSummaries(ctx context.Context, summaries []*model.AttendanceSummary) error {
+	for _, sum := range summaries {
+		sum.UpdatedAt = time.Now()
+		doc, err := bson.Marshal(sum)
+		if err != nil {
+			return err
+		}
+		if err := upsert(ctx, s.db, "oktel_attendance_summaries", []string{"user_id", "team_id", "month"}, []column{
+			{"user_id", sum.UserID},
+			{"team_id", sum.TeamID},
+			{"month", sum.Month},
+			{"doc", doc},
+		}); err != nil {
+			return fmt.Errorf("save summary: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *retentionStore) DeleteBefore(ctx context.Context, sc store.RetentionScope, before string) (int64, int64, error) {
+	w := &where{}
+	w.scope(sc)
+	w.add("date < ?", before)
+	res, err := s.db.ExecContext(ctx, rebind(`DELETE FROM oktel_attendance`+w.String()), w.args...)
+	if err != nil {
+		return 0, 0, fmt.Errorf("delete attendance: %w", err)
+	}
+	records, _ := res.RowsAffected()
+
+	w = &where{}
+	w.scope(sc)
+	w.anyDate("d < ?", before)
+	w.add("NOT EXISTS (SELECT 1 FROM unnest(dates) d WHERE d >= ?)", before)
+	res, err = s.db.ExecContext(ctx, rebind(`DELETE FROM oktel_leave_requests`+w.String()), w.args...)
+	if err != nil {
+		return records, 0, fmt.Errorf("delete leave requests: %w", err)
+	}
+	leave, _ := res.RowsAffected()
+
+	w = &where{}
+	w.scope(sc)
+	w.anyDate("d < ?", before)
+	err = inTx(ctx, s.db, func(tx *sql.Tx) error {
+		reqs, err := selectDocs[model.LeaveRequest](ctx, tx, `SELECT doc FROM oktel_leave_requests`+w.String()+` FOR UPDATE`, w.args...)
+		if err != nil {
+			return err
+		}
+		for _, req := range reqs {
+			var dates []string
+			for _, d := range req.Dates {
+				if d >= before {
+					dates = append(dates, d)
+				}
+			}
+			req.Dates = dates
+			req.UpdatedAt = time.Now()
+			if err := saveLeave(ctx, tx, req); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return records, leave, fmt.Errorf("trim leave requests: %w", err)
+	}
+	return records, leave, nil
+}
+
+func (s *retentionStore) GetUserData(ctx context.Context, userID string) (*store.UserData, error) {
+	data := &store.UserData{}
+	var err error
+	if data.Attendance, err = selectDocs[model.AttendanceRecord](ctx, s.db,
+		`SELECT doc FROM oktel_attendance WHERE user_id = ? ORDER BY date`, userID); err != nil {
+		return nil, fmt.Errorf("find attendance: %w", err)
+	}
+	if data.Leave, err = selectDocs[model.LeaveRequest](ctx, s.db,
+		`SELECT doc FROM oktel_leave_requests WHERE user_id = ? ORDER BY created_at`, userID); err != nil {
+		return nil, fmt.Errorf("find leave requests: %w", err)
+	}
+	if data.CompTime, err = selectDocs[model.CompTimeAdjustment](ctx, s.db,
+		`SELECT doc FROM oktel_comp_time_adjustments WHERE user_id = ? ORDER BY id`, userID); err != nil {
+		return nil, fmt.Errorf("find comp time adjustments: %w", err)
+	}
+	if data.Delegations, err = selectDocs[model.Delegation](ctx, s.db,
+		`SELECT doc FROM oktel_delegations WHERE delegator_id = ? OR delegate_id = ? ORDER BY created_at`, userID, userID); err != nil {
+		return nil, fmt.Errorf("find delegations: %w", err)
+	}
+	if data.Summaries, err = selectDocs[model.AttendanceSummary](ctx, s.db,
+		`SELECT doc FROM oktel_attendance_summaries WHERE user_id = ? ORDER BY month`, userID); err != nil {
+		return nil, fmt.Errorf("find summaries: %w", err)
+	}
+	sheets, err := s.userTimesheets(ctx, s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	data.Timesheets = store.TimesheetRowsOf(sheets, userID)
+	if data.Budgets, err = s.userBudgets(ctx, s.db, userID); err != nil {
+		return nil, err
+	}
+	if data.Webhooks, err = selectDocs[model.WebhookDelivery](ctx, s.db,
+		`SELECT doc FROM oktel_webhook_deliveries WHERE user_id = ? ORDER BY created_at`, userID); err != nil {
+		return nil, fmt.Errorf("find webhook deliveries: %w", err)
+	}
+	if data.CalendarFeed, err = getDoc[model.CalendarFeed](ctx, s.db,
+		`SELECT doc FROM oktel_calendar_feeds WHERE user_id = ?`, userID); err != nil {
+		return nil, fmt.Errorf("find calendar feed: %w", err)
+	}
+	return data, nil
+}
+
+// userTimesheets returns the timesheets with a row for userID. The rows are only in the
+// documents, so every sheet is read.
+func (s *retentionStore) userTimesheets(ctx context.Context, q querier, userID string) ([]*model.Timesheet, error) {
+	sheets, err := selectDocs[model.Timesheet](ctx, q, `SELECT doc FROM oktel_timesheets ORDER BY month, team_id`)
+	if err != nil {
+		return nil, fmt.Errorf("find timesheets: %w", err)
+	}
+	var out []*model.Timesheet
+	for _, sheet := range sheets {
+		if sheet.UserRow(userID) != nil {
+			out = append(out, sheet)
+		}
+	}
+	return out, nil
+}
+
+// userBudgets returns the budget requests userID took part in. The people on a request are
+// only in its document, so every request is read.
+func (s *retentionStore) userBudgets(ctx context.Context, q querier, userID string) ([]*model.BudgetRequest, error) {
+	reqs, err := selectDocs[model.BudgetRequest](ctx, q, `SELECT doc FROM oktel_budget_requests ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("find budget requests: %w", err)
+	}
+	var out []*model.BudgetRequest
+	for _, req := range reqs {
+		if req.Involves(userID) {
+			out = append(out, req)
+		}
+	}
+	return out, nil
+}
+
+func (s *retentionStore) DeleteUserData(ctx context.Context, userID string) (*store.UserDataCounts, error) {
+	counts := &store.UserDataCounts{}
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, d := range []struct {
+			table string
+			cond  string
+			n     *int64
+		}{
+			{"oktel_attendance", "user_id = $1", &counts.Attendance},
+			{"oktel_leave_requests", "user_id = $1", &counts.Leave},
+			{"oktel_comp_time_adjustments", "user_id = $1", &counts.CompTime},
+			{"oktel_delegations", "delegator_id = $1 OR delegate_id = $1", &counts.Delegations},
+			{"oktel_attendance_summaries", "user_id = $1", &counts.Summaries},
+			{"oktel_webhook_deliveries", "user_id = $1", &counts.Webhooks},
+			{"oktel_calendar_feeds", "user_id = $1", &counts.CalendarFeeds},
+		} {
+			res, err := tx.ExecContext(ctx, "DELETE FROM "+d.table+" WHERE "+d.cond, userID)
+			if err != nil {
+				return fmt.Errorf("delete from %s: %w", d.table, err)
+			}
+			*d.n, _ = res.RowsAffected()
+		}
+
+		sheets, err := s.userTimesheets(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		for _, sheet := range sheets {
+			sheet.ForgetUser(userID)
+			doc, err := bson.Marshal(sheet)
+			if err != nil {
+				return err
+			}
+			if err := upsert(ctx, tx, "oktel_timesheets", []string{"team_id", "month"}, []column{
+				{"team_id", sheet.TeamID},
+				{"month", sheet.Month},
+				{"doc", doc},
+			}); err != nil {
+				return fmt.Errorf("update timesheet: %w", err)
+			}
+			counts.Timesheets++
+		}
+		reqs, err := s.userBudgets(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		for _, req := range reqs {
+			req.ForgetUser(userID)
+			req.UpdatedAt = time.Now()
+			cols, err := budgetColumns(req)
+			if err != nil {
+				return err
+			}
+			if err := update(ctx, tx, "oktel_budget_requests", cols[0], cols[1:]); err != nil {
+				return fmt.Errorf("update budget request: %w", err)
+			}
+			counts.Budgets++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,180 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"oktel-bot/internal/model"
+)
+
+// recordingDriver is a database/sql driver that answers every query with no rows and
+// records the statements it was sent.
+type recordingDriver struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+func (d *recordingDriver) record(query string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries = append(d.queries, query)
+}
+
+// reset returns the recorded statements and forgets them.
+func (d *recordingDriver) reset() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	queries := d.queries
+	d.queries = nil
+	return queries
+}
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *recordingConn) Close() error                        { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c *recordingConn) Commit() error                       { return nil }
+func (c *recordingConn) Rollback() error                     { return nil }
+
+func (c *recordingConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.d.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *recordingConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.d.record(query)
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return []string{"doc"} }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+var recorder = &recordingDriver{}
+
+func init() {
+	sql.Register("oktel-recording", recorder)
+}
+
+// tableModels maps every table in the schema to the model its doc column holds.
+var tableModels = map[string]any{
+	"oktel_attendance":            model.AttendanceRecord{},
+	"oktel_leave_requests":        model.LeaveRequest{},
+	"oktel_budget_requests":       model.BudgetRequest{},
+	"oktel_delegations":           model.Delegation{},
+	"oktel_webhook_deliveries":    model.WebhookDelivery{},
+	"oktel_api_keys":              model.APIKey{},
+	"oktel_team_settings":         model.TeamSettings{},
+	"oktel_timesheets":            model.Timesheet{},
+	"oktel_attendance_summaries":  model.AttendanceSummary{},
+	"oktel_retention_runs":        model.RetentionRun{},
+	"oktel_calendar_feeds":        model.CalendarFeed{},
+	"oktel_comp_time_adjustments": model.CompTimeAdjustment{},
+}
+
+// personFields are the fields other than *UserID that hold a Mattermost user ID.
+var personFields = map[string]bool{
+	"ApproverID":   true,
+	"OnBehalfOfID": true,
+	"DelegatorID":  true,
+	"DelegateID":   true,
+}
+
+// holdsUserID reports whether t, or a struct it contains, has a field holding a user ID.
+func holdsUserID(t reflect.Type, seen map[reflect.Type]bool) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return holdsUserID(t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Type.Kind() == reflect.String && (strings.HasSuffix(f.Name, "UserID") || personFields[f.Name]) {
+				return true
+			}
+			if holdsUserID(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var tableName = regexp.MustCompile(`\boktel_[a-z_]+`)
+
+// tablesIn returns the tables the queries refer to.
+func tablesIn(queries []string) map[string]bool {
+	tables := map[string]bool{}
+	for _, q := range queries {
+		for _, name := range tableName.FindAllString(q, -1) {
+			tables[name] = true
+		}
+	}
+	return tables
+}
+
+func TestUserDataCoversEveryTable(t *testing.T) {
+	created := regexp.MustCompile(`CREATE TABLE IF NOT EXISTS (oktel_[a-z_]+)`)
+	var userTables []string
+	for _, version := range schema {
+		for _, stmt := range version {
+			m := created.FindStringSubmatch(stmt)
+			if m == nil {
+				continue
+			}
+			doc, ok := tableModels[m[1]]
+			if !ok {
+				t.Errorf("table %s has no model in tableModels; add it so this test can check it", m[1])
+				continue
+			}
+			if holdsUserID(reflect.TypeOf(doc), map[reflect.Type]bool{}) {
+				userTables = append(userTables, m[1])
+			}
+		}
+	}
+	if len(userTables) == 0 {
+		t.Fatal("found no tables with user IDs in the schema")
+	}
+
+	db, err := sql.Open("oktel-recording", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := &retentionStore{db: db}
+	ctx := context.Background()
+
+	recorder.reset()
+	if _, err := s.GetUserData(ctx, "user1"); err != nil {
+		t.Fatal(err)
+	}
+	exported := tablesIn(recorder.reset())
+	if _, err := s.DeleteUserData(ctx, "user1"); err != nil {
+		t.Fatal(err)
+	}
+	erased := tablesIn(recorder.reset())
+
+	for _, table := range userTables {
+		if !exported[table] {
+			t.Errorf("GetUserData does not read %s, which holds user IDs", table)
+		}
+		if !erased[table] {
+			t.Errorf("DeleteUserData does not touch %s, which holds user IDs", table)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// schema holds the statements of each schema version, in version order. Append new
+// versions; never edit one that has shipped.
+var schema = [][]string{
+	{
+		`CREATE TABLE IF NOT EXISTS oktel_attendance (
+			id TEXT COLLATE "C" PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			team_id TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			date TEXT NOT NULL,
+			has_photo BOOLEAN NOT NULL,
+			has_device BOOLEAN NOT NULL,
+			doc BYTEA NOT NULL,
+			UNIQUE (user_id, date)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_attendance_date ON oktel_attendance (date)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_attendance_team_date ON oktel_attendance (team_id, date)`,
+
+		`CREATE TABLE IF NOT EXISTS oktel_leave_requests (
+			id TEXT COLLATE "C" PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			team_id TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			status TEXT NOT NULL,
+			source TEXT NOT NULL,
+			external_id TEXT NOT NULL,
+			dates TEXT[] NOT NULL,
+			created_at BIGINT NOT NULL,
+			doc BYTEA NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_leave_requests_user ON oktel_leave_requests (user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_leave_requests_dates ON oktel_leave_requests USING GIN (dates)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_leave_requests_status_team ON oktel_leave_requests (status, team_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_leave_requests_source ON oktel_leave_requests (source, external_id)`,
+
+		`CREATE TABLE IF NOT EXISTS oktel_budget_requests (
+			id TEXT COLLATE "C" PRIMARY KEY,
+			team_id TEXT NOT NULL,
+			current_step INTEGER NOT NULL,
+			rejected BOOLEAN NOT NULL,
+			withdrawn BOOLEAN NOT NULL,
+			created_at BIGINT NOT NULL,
+			doc BYTEA NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_budget_requests_created ON oktel_budget_requests (created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_budget_requests_team_created ON oktel_budget_requests (team_id, created_at)`,
+
+		`CREATE TABLE IF NOT EXISTS oktel_delegations (
+			id TEXT COLLATE "C" PRIMARY KEY,
+			delegator_id TEXT NOT NULL,
+			delegator_username TEXT NOT NULL,
+			delegate_id TEXT NOT NULL,
+			scope TEXT NOT NULL,
+			from_date TEXT NOT NULL,
+			to_date TEXT NOT NULL,
+			leave_request_id TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			doc BYTEA NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_delegations_delegator ON oktel_delegations (delegator_id, to_date)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_delegations_delegate ON oktel_delegations (delegate_id, to_date)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_delegations_leave_request ON oktel_delegations (leave_request_id)`,
+
+		`CREATE TABLE IF NOT EXISTS oktel_webhook_deliveries (
+			id TEXT COLLATE "C" PRIMARY KEY,
+			status TEXT NOT NULL,
+			event TEXT NOT NULL,
+			event_id TEXT NOT NULL,
+			next_attempt_at BIGINT NOT NULL,
+			created_at BIGINT NOT NULL,
+			doc BYTEA NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_webhook_deliveries_due ON oktel_webhook_deliveries (status, next_attempt_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_webhook_deliveries_event ON oktel_webhook_deliveries (event_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_webhook_deliveries_created ON oktel_webhook_deliveries (created_at)`,
+
+		`CREATE TABLE IF NOT EXISTS oktel_api_keys (
+			id TEXT COLLATE "C" PRIMARY KEY,
+			hash TEXT NOT NULL UNIQUE,
+			created_at BIGINT NOT NULL,
+			doc BYTEA NOT NULL
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS oktel_team_settings (
+			team_id TEXT PRIMARY KEY,
+			updated_at BIGINT NOT NULL,
+			doc BYTEA NOT NULL
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS oktel_timesheets (
+			team_id TEXT NOT NULL,
+			month TEXT NOT NULL,
+			doc BYTEA NOT NULL,
+			PRIMARY KEY (team_id, month)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS oktel_attendance_summaries (
+			user_id TEXT NOT NULL,
+			team_id TEXT NOT NULL,
+			month TEXT NOT NULL,
+			doc BYTEA NOT NULL,
+			PRIMARY KEY (user_id, team_id, month)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_attendance_summaries_team_month ON oktel_attendance_summaries (team_id, month)`,
+
+		`CREATE TABLE IF NOT EXISTS oktel_retention_runs (
+			date TEXT PRIMARY KEY,
+			doc BYTEA NOT NULL
+		)`,
+	},
+	{
+		`ALTER TABLE oktel_delegations ADD COLUMN has_channels BOOLEAN NOT NULL DEFAULT false`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_delegations_channels ON oktel_delegations (to_date) WHERE has_channels`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS oktel_calendar_feeds (
+			user_id TEXT PRIMARY KEY,
+			doc BYTEA NOT NULL
+		)`,
+	},
+	{
+		`ALTER TABLE oktel_webhook_deliveries ADD COLUMN user_id TEXT NOT NULL DEFAULT ''`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_webhook_deliveries_user ON oktel_webhook_deliveries (user_id) WHERE user_id <> ''`,
+	},
+	{
+		`CREATE TABLE IF NOT EXISTS oktel_comp_time_adjustments (
+			id TEXT COLLATE "C" PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			source TEXT NOT NULL,
+			external_id TEXT NOT NULL,
+			minutes INTEGER NOT NULL,
+			doc BYTEA NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_comp_time_adjustments_user ON oktel_comp_time_adjustments (user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_oktel_comp_time_adjustments_source ON oktel_comp_time_adjustments (source, external_id)`,
+	},
+}
+
+// migrate applies the schema versions not yet recorded in oktel_schema_migrations. The
+// servers of a cluster activate the plugin at the same time, so the whole run holds a
+// transaction-scoped advisory lock: the others wait and then find nothing to do.
+func migrate(ctx context.Context, db *sql.DB) error {
+	return inTx(ctx, db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('oktel_schema_migrations'))`); err != nil {
+			return fmt.Errorf("lock schema migrations: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS oktel_schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied_at BIGINT NOT NULL
+		)`); err != nil {
+			return fmt.Errorf("create oktel_schema_migrations: %w", err)
+		}
+		var current int
+		if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM oktel_schema_migrations`).Scan(&current); err != nil {
+			return fmt.Errorf("read schema version: %w", err)
+		}
+		for i := current; i < len(schema); i++ {
+			version := i + 1
+			for _, stmt := range schema[i] {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("schema version %d: %w", version, err)
+				}
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO oktel_schema_migrations (version, applied_at) VALUES ($1, $2)`,
+				version, millis(time.Now())); err != nil {
+				return fmt.Errorf("record schema version %d: %w", version, err)
+			}
+			log.Printf("migrate: applied schema version %d", version)
+		}
+		return nil
+	})
+}
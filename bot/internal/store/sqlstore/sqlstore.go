@@ -0,0 +1,271 @@
+// Package sqlstore implements the stores on PostgreSQL, for the server plugin to keep its
+// records in the Mattermost database through the plugin database driver. Each record is
+// kept whole as a BSON document, exactly as the MongoDB stores save it, next to the columns
+// its queries filter and sort on.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/store"
+)
+
+// New brings the schema in db up to date and returns the stores on it.
+func New(ctx context.Context, db *sql.DB) (*store.Stores, error) {
+	if err := migrate(ctx, db); err != nil {
+		return nil, err
+	}
+	return &store.Stores{
+		Attendance:   &attendanceStore{db: db},
+		Budget:       &budgetStore{db: db},
+		Delegation:   &delegationStore{db: db},
+		Webhook:      &webhookStore{db: db},
+		APIKey:       &apiKeyStore{db: db},
+		Retention:    &retentionStore{db: db},
+		TeamSettings: &teamSettingsStore{db: db},
+		Timesheet:    &timesheetStore{db: db},
+		CalendarFeed: &calendarFeedStore{db: db},
+	}, nil
+}
+
+// querier is a *sql.DB or *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// column is a column of a row being written.
+type column struct {
+	name  string
+	value any
+}
+
+// insert adds a row.
+func insert(ctx context.Context, q querier, table string, cols []column) error {
+	names, args := split(cols)
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(names, ", "), placeholders(len(cols)))
+	_, err := q.ExecContext(ctx, rebind(query), args...)
+	return err
+}
+
+// upsert adds a row or, if one with the same key columns exists, overwrites its other columns.
+func upsert(ctx context.Context, q querier, table string, key []string, cols []column) error {
+	names, args := split(cols)
+	var set []string
+	for _, name := range names {
+		if !contains(key, name) {
+			set = append(set, name+" = EXCLUDED."+name)
+		}
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		table, strings.Join(names, ", "), placeholders(len(cols)), strings.Join(key, ", "), strings.Join(set, ", "))
+	_, err := q.ExecContext(ctx, rebind(query), args...)
+	return err
+}
+
+// update overwrites the columns of the row whose key column has the given value.
+func update(ctx context.Context, q querier, table string, key column, cols []column) error {
+	names, args := split(cols)
+	for i, name := range names {
+		names[i] = name + " = ?"
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?", table, strings.Join(names, ", "), key.name)
+	_, err := q.ExecContext(ctx, rebind(query), append(args, key.value)...)
+	return err
+}
+
+func split(cols []column) ([]string, []any) {
+	names := make([]string, len(cols))
+	args := make([]any, len(cols))
+	for i, c := range cols {
+		names[i] = c.name
+		args[i] = c.value
+	}
+	return names, args
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func strArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// rebind numbers the ? placeholders of query as PostgreSQL expects.
+func rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// getDoc decodes the doc column of the first row of query, or returns nil if there is none.
+func getDoc[T any](ctx context.Context, q querier, query string, args ...any) (*T, error) {
+	var data []byte
+	err := q.QueryRowContext(ctx, rebind(query), args...).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	v := new(T)
+	if err := bson.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// selectDocs decodes the doc column of every row of query.
+func selectDocs[T any](ctx context.Context, q querier, query string, args ...any) ([]*T, error) {
+	rows, err := q.QueryContext(ctx, rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []*T{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		v := new(T)
+		if err := bson.Unmarshal(data, v); err != nil {
+			return nil, err
+		}
+		results = append(results, v)
+	}
+	return results, rows.Err()
+}
+
+// count returns the single number query selects.
+func count(ctx context.Context, q querier, query string, args ...any) (int64, error) {
+	var n int64
+	err := q.QueryRowContext(ctx, rebind(query), args...).Scan(&n)
+	return n, err
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds.
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// where collects the conditions of a query, with ? placeholders.
+type where struct {
+	conds []string
+	args  []any
+}
+
+func (w *where) add(cond string, args ...any) {
+	w.conds = append(w.conds, cond)
+	w.args = append(w.args, args...)
+}
+
+// eq adds column = value unless value is empty.
+func (w *where) eq(column, value string) {
+	if value != "" {
+		w.add(column+" = ?", value)
+	}
+}
+
+// in adds column IN (values).
+func (w *where) in(column string, values ...string) {
+	if len(values) == 0 {
+		w.add("FALSE")
+		return
+	}
+	w.add(column+" IN ("+placeholders(len(values))+")", strArgs(values)...)
+}
+
+// scope adds the teams of a retention scope.
+func (w *where) scope(sc store.RetentionScope) {
+	if sc.TeamID != "" {
+		w.add("team_id = ?", sc.TeamID)
+		return
+	}
+	if len(sc.ExcludeTeamIDs) > 0 {
+		w.add("team_id NOT IN ("+placeholders(len(sc.ExcludeTeamIDs))+")", strArgs(sc.ExcludeTeamIDs)...)
+	}
+}
+
+// anyDate adds that the dates column has an element matching cond, e.g. "d >= ?".
+func (w *where) anyDate(cond string, args ...any) {
+	w.add("EXISTS (SELECT 1 FROM unnest(dates) d WHERE "+cond+")", args...)
+}
+
+// String returns the WHERE clause, or "" without conditions.
+func (w *where) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+// textArray passes a string slice as a text[] literal, as the plugin database driver only
+// carries scalar values. Use it as ?::text[].
+type textArray []string
+
+func (a textArray) Value() (driver.Value, error) {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, s := range a {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(`"` + escape.Replace(s) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+// millis stores times as Unix milliseconds, the precision of the BSON documents.
+func millis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+// newID sets a new ID unless id is already set, as MongoDB does on insert.
+func newID(id *bson.ObjectID) {
+	if id.IsZero() {
+		*id = bson.NewObjectID()
+	}
+}
@@ -0,0 +1,44 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/model"
+)
+
+type teamSettingsStore struct {
+	db *sql.DB
+}
+
+func (s *teamSettingsStore) ChangedSince(ctx context.Context, t time.Time) ([]*model.TeamSettings, error) {
+	var since int64
+	if !t.IsZero() {
+		since = millis(t)
+	}
+	results, err := selectDocs[model.TeamSettings](ctx, s.db, `SELECT doc FROM oktel_team_settings WHERE updated_at > ?`, since)
+	if err != nil {
+		return nil, fmt.Errorf("find team settings: %w", err)
+	}
+	return results, nil
+}
+
+func (s *teamSettingsStore) Save(ctx context.Context, settings *model.TeamSettings) error {
+	settings.UpdatedAt = time.Now().Truncate(time.Millisecond) // as stored, so cached copies compare equal
+	doc, err := bson.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	if err := upsert(ctx, s.db, "oktel_team_settings", []string{"team_id"}, []column{
+		{"team_id", settings.TeamID},
+		{"updated_at", millis(settings.UpdatedAt)},
+		{"doc", doc},
+	}); err != nil {
+		return fmt.Errorf("save team settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/model"
+)
+
+type timesheetStore struct {
+	db *sql.DB
+}
+
+func (s *timesheetStore) Get(ctx context.Context, teamID, month string) (*model.Timesheet, error) {
+	sheet, err := getDoc[model.Timesheet](ctx, s.db, `SELECT doc FROM oktel_timesheets WHERE team_id = ? AND month = ?`, teamID, month)
+	if err != nil {
+		return nil, fmt.Errorf("find timesheet: %w", err)
+	}
+	return sheet, nil
+}
+
+func (s *timesheetStore) Save(ctx context.Context, sheet *model.Timesheet) error {
+	doc, err := bson.Marshal(sheet)
+	if err != nil {
+		return err
+	}
+	if err := upsert(ctx, s.db, "oktel_timesheets", []string{"team_id", "month"}, []column{
+		{"team_id", sheet.TeamID},
+		{"month", sheet.Month},
+		{"doc", doc},
+	}); err != nil {
+		return fmt.Errorf("save timesheet: %w", err)
+	}
+	return nil
+}
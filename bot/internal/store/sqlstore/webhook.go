@@ -0,0 +1,106 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/model"
+)
+
+type webhookStore struct {
+	db *sql.DB
+}
+
+func deliveryColumns(d *model.WebhookDelivery) ([]column, error) {
+	doc, err := bson.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return []column{
+		{"id", d.ID.Hex()},
+		{"status", string(d.Status)},
+		{"event", d.Event},
+		{"event_id", d.EventID},
+		{"user_id", d.UserID},
+		{"next_attempt_at", millis(d.NextAttemptAt)},
+		{"created_at", millis(d.CreatedAt)},
+		{"doc", doc},
+	}, nil
+}
+
+func saveDelivery(ctx context.Context, q querier, d *model.WebhookDelivery) error {
+	cols, err := deliveryColumns(d)
+	if err != nil {
+		return err
+	}
+	return update(ctx, q, "oktel_webhook_deliveries", cols[0], cols[1:])
+}
+
+func (s *webhookStore) Create(ctx context.Context, d *model.WebhookDelivery) error {
+	now := time.Now()
+	d.CreatedAt = now
+	d.UpdatedAt = now
+	newID(&d.ID)
+	cols, err := deliveryColumns(d)
+	if err != nil {
+		return err
+	}
+	return insert(ctx, s.db, "oktel_webhook_deliveries", cols)
+}
+
+func (s *webhookStore) Update(ctx context.Context, d *model.WebhookDelivery) error {
+	d.UpdatedAt = time.Now()
+	return saveDelivery(ctx, s.db, d)
+}
+
+func (s *webhookStore) GetByID(ctx context.Context, id bson.ObjectID) (*model.WebhookDelivery, error) {
+	return getDoc[model.WebhookDelivery](ctx, s.db, `SELECT doc FROM oktel_webhook_deliveries WHERE id = ?`, id.Hex())
+}
+
+// ClaimDue skips deliveries locked by a dispatcher on another server claiming at the same time.
+func (s *webhookStore) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*model.WebhookDelivery, error) {
+	var d *model.WebhookDelivery
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		var err error
+		d, err = getDoc[model.WebhookDelivery](ctx, tx, `SELECT doc FROM oktel_webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at LIMIT 1 FOR UPDATE SKIP LOCKED`,
+			string(model.WebhookDeliveryPending), millis(now))
+		if err != nil || d == nil {
+			return err
+		}
+		d.NextAttemptAt = now.Add(lease)
+		return saveDelivery(ctx, tx, d)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook delivery: %w", err)
+	}
+	return d, nil
+}
+
+func (s *webhookStore) List(ctx context.Context, status model.WebhookDeliveryStatus, event, eventID string, limit int) ([]*model.WebhookDelivery, error) {
+	w := &where{}
+	w.eq("status", string(status))
+	w.eq("event", event)
+	w.eq("event_id", eventID)
+	results, err := selectDocs[model.WebhookDelivery](ctx, s.db,
+		`SELECT doc FROM oktel_webhook_deliveries`+w.String()+` ORDER BY created_at DESC LIMIT ?`,
+		append(w.args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("find webhook deliveries: %w", err)
+	}
+	return results, nil
+}
+
+func (s *webhookStore) DeleteFinishedBefore(ctx context.Context, t time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, rebind(`DELETE FROM oktel_webhook_deliveries WHERE status <> ? AND created_at < ?`),
+		string(model.WebhookDeliveryPending), millis(t))
+	if err != nil {
+		return 0, fmt.Errorf("delete webhook deliveries: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,196 @@
+// Package store persists the bot's records. Every store has a MongoDB implementation, used
+// by the standalone service, and a PostgreSQL one in package sqlstore, used by the server
+// plugin through the Mattermost database. Lookups of a single record return nil, nil when
+// it does not exist.
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"oktel-bot/internal/model"
+)
+
+// Stores is one implementation of every store.
+type Stores struct {
+	Attendance   AttendanceStore
+	Budget       BudgetStore
+	Delegation   DelegationStore
+	Webhook      WebhookStore
+	APIKey       APIKeyStore
+	Retention    RetentionStore
+	TeamSettings TeamSettingsStore
+	Timesheet    TimesheetStore
+	CalendarFeed CalendarFeedStore
+}
+
+// AttendanceStore holds attendance records and leave requests.
+type AttendanceStore interface {
+	// GetTodayRecord returns a user's attendance record for a date (YYYY-MM-DD).
+	GetTodayRecord(ctx context.Context, userID, date string) (*model.AttendanceRecord, error)
+	// CreateRecord inserts a new attendance record and sets the ID on the struct.
+	CreateRecord(ctx context.Context, record *model.AttendanceRecord) error
+	UpdateRecord(ctx context.Context, record *model.AttendanceRecord) error
+	// CreateLeaveRequest inserts a new leave request and sets the ID on the struct.
+	CreateLeaveRequest(ctx context.Context, req *model.LeaveRequest) error
+	GetLeaveRequestByID(ctx context.Context, id bson.ObjectID) (*model.LeaveRequest, error)
+	// FindLeaveRequestsByUserAndDates returns a user's leave requests containing any of the dates.
+	FindLeaveRequestsByUserAndDates(ctx context.Context, userID string, dates []string) ([]model.LeaveRequest, error)
+	// FindFutureLeaveRequestsByUser returns a user's pending or approved leave requests with
+	// at least one date on or after fromDate.
+	FindFutureLeaveRequestsByUser(ctx context.Context, userID string, fromDate string) ([]model.LeaveRequest, error)
+	// FindAwaitingApproval returns a team's new requests and date changes awaiting a
+	// decision, oldest first.
+	FindAwaitingApproval(ctx context.Context, teamID string) ([]*model.LeaveRequest, error)
+	UpdateLeaveRequest(ctx context.Context, req *model.LeaveRequest) error
+	GetLeaveRequestsByDate(ctx context.Context, date string) ([]*model.LeaveRequest, error)
+	GetAttendanceByDate(ctx context.Context, date string) ([]*model.AttendanceRecord, error)
+	// GetAttendanceByDateRange returns the records dated from..to, optionally filtered by
+	// user, team and/or channel.
+	GetAttendanceByDateRange(ctx context.Context, from, to, userID, teamID, channelID string) ([]*model.AttendanceRecord, error)
+	// GetLeaveRequestsByDateRange returns the leave requests with any date in from..to,
+	// optionally filtered by user, team and/or channel.
+	GetLeaveRequestsByDateRange(ctx context.Context, from, to, userID, teamID, channelID string) ([]*model.LeaveRequest, error)
+	// GetPhotoRecordsSince returns a user's records from fromDate onward that have a photo hash.
+	GetPhotoRecordsSince(ctx context.Context, userID, fromDate string) ([]*model.AttendanceRecord, error)
+	// GetApprovedOvertime returns the user's approved overtime request covering a date.
+	GetApprovedOvertime(ctx context.Context, userID, date string) (*model.LeaveRequest, error)
+	// GetApprovedWorkMode returns the user's approved remote or business-trip request
+	// covering a date.
+	GetApprovedWorkMode(ctx context.Context, userID, date string) (*model.LeaveRequest, error)
+	// GetCompTimeBalance returns a user's comp time in minutes: credited compensatory
+	// overtime and adjustments less what pending and approved day-off requests spend.
+	GetCompTimeBalance(ctx context.Context, userID string) (int, error)
+	// CreateCompTimeAdjustment inserts a new adjustment and sets the ID on the struct.
+	CreateCompTimeAdjustment(ctx context.Context, adj *model.CompTimeAdjustment) error
+	// FindCompTimeAdjustment returns the adjustment a source imported with an external ID.
+	FindCompTimeAdjustment(ctx context.Context, source, externalID string) (*model.CompTimeAdjustment, error)
+	// ListLeaveRequests returns a page of leave requests, newest first, and the total number matching.
+	ListLeaveRequests(ctx context.Context, f LeaveRequestFilter, skip, limit int) ([]*model.LeaveRequest, int64, error)
+	// ListAttendance returns a page of attendance records, latest date first, and the total
+	// number matching.
+	ListAttendance(ctx context.Context, f AttendanceFilter, skip, limit int) ([]*model.AttendanceRecord, int64, error)
+}
+
+// BudgetStore holds budget requests.
+type BudgetStore interface {
+	// Create inserts a new budget request and sets the ID on the struct.
+	Create(ctx context.Context, req *model.BudgetRequest) error
+	GetByID(ctx context.Context, id bson.ObjectID) (*model.BudgetRequest, error)
+	Update(ctx context.Context, req *model.BudgetRequest) error
+	// List returns a page of budget requests, newest first, and the total number matching.
+	List(ctx context.Context, f BudgetFilter, skip, limit int) ([]*model.BudgetRequest, int64, error)
+}
+
+// DelegationStore holds approval delegations.
+type DelegationStore interface {
+	// Create inserts a new delegation and sets the ID on the struct.
+	Create(ctx context.Context, d *model.Delegation) error
+	// FindActive returns the delegations of a scope covering a date, optionally filtered
+	// by delegator and/or delegate user ID.
+	FindActive(ctx context.Context, date string, scope model.DelegationScope, delegatorID, delegateID string) ([]*model.Delegation, error)
+	// FindActiveByDelegatorUsername returns a delegator's delegation covering a date.
+	FindActiveByDelegatorUsername(ctx context.Context, date string, scope model.DelegationScope, username string) (*model.Delegation, error)
+	// DeleteByLeaveRequest removes the delegations created automatically from a leave
+	// request and returns them.
+	DeleteByLeaveRequest(ctx context.Context, leaveRequestID string) ([]*model.Delegation, error)
+	// AddChannel records an approval channel the delegate was added to.
+	AddChannel(ctx context.Context, id bson.ObjectID, ch model.DelegationChannel) error
+	// ClearChannels forgets the approval channels the delegate was added to.
+	ClearChannels(ctx context.Context, id bson.ObjectID) error
+	// FindEndedWithChannels returns the delegations that ended before a date and still
+	// have approval channels recorded.
+	FindEndedWithChannels(ctx context.Context, date string) ([]*model.Delegation, error)
+}
+
+// WebhookStore holds outbound webhook deliveries.
+type WebhookStore interface {
+	// Create inserts a new delivery and sets the ID on the struct.
+	Create(ctx context.Context, d *model.WebhookDelivery) error
+	Update(ctx context.Context, d *model.WebhookDelivery) error
+	GetByID(ctx context.Context, id bson.ObjectID) (*model.WebhookDelivery, error)
+	// ClaimDue returns a pending delivery whose next attempt is due and pushes its next
+	// attempt back by lease, so a dispatcher that dies mid-delivery leaves it to be retried.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*model.WebhookDelivery, error)
+	// List returns the most recent deliveries, newest first, optionally filtered by
+	// status, event and event ID.
+	List(ctx context.Context, status model.WebhookDeliveryStatus, event, eventID string, limit int) ([]*model.WebhookDelivery, error)
+	// DeleteFinishedBefore deletes the delivered and failed deliveries created before t.
+	DeleteFinishedBefore(ctx context.Context, t time.Time) (int64, error)
+}
+
+// APIKeyStore holds the keys of the REST API.
+type APIKeyStore interface {
+	// Create inserts a new key and sets the ID on the struct.
+	Create(ctx context.Context, key *model.APIKey) error
+	GetByHash(ctx context.Context, hash string) (*model.APIKey, error)
+	List(ctx context.Context) ([]*model.APIKey, error)
+	// TouchLastUsed records that a key was just used.
+	TouchLastUsed(ctx context.Context, id bson.ObjectID, at time.Time) error
+	// Revoke marks a key as revoked. It reports whether an active key was found.
+	Revoke(ctx context.Context, id bson.ObjectID) (bool, error)
+}
+
+// RetentionStore reads and deletes personal data for retention policies, data exports and
+// erasure.
+type RetentionStore interface {
+	// ClaimRun records the start of a daily run. It returns false if a run for that date
+	// already exists, e.g. because another replica started it.
+	ClaimRun(ctx context.Context, run *model.RetentionRun) (bool, error)
+	// FinishRun saves the outcome of a run.
+	FinishRun(ctx context.Context, run *model.RetentionRun) error
+	// RecordsWithPhotos returns up to limit records in scope dated before the given date
+	// that still reference a photo, in ID order starting after afterID.
+	RecordsWithPhotos(ctx context.Context, sc RetentionScope, before string, afterID bson.ObjectID, limit int) ([]*model.AttendanceRecord, error)
+	// ClearPhotos removes a record's photo references and photo hashes. File IDs are also
+	// cleared from its photo flags; the flags themselves are kept.
+	ClearPhotos(ctx context.Context, record *model.AttendanceRecord) error
+	// AnonymizeDevices removes device strings from records in scope dated before the given date.
+	AnonymizeDevices(ctx context.Context, sc RetentionScope, before string) (int64, error)
+	// OldestDate returns the earliest attendance or leave date in scope before the given
+	// date, or "" if there is none.
+	OldestDate(ctx context.Context, sc RetentionScope, before string) (string, error)
+	// PeriodData returns the attendance records in scope dated in [from, to) and the leave
+	// requests in scope with any date in that range.
+	PeriodData(ctx context.Context, sc RetentionScope, from, to string) ([]*model.AttendanceRecord, []*model.LeaveRequest, error)
+	// SaveSummaries inserts or replaces monthly summaries.
+	SaveSummaries(ctx context.Context, summaries []*model.AttendanceSummary) error
+	// DeleteBefore deletes the attendance records in scope dated before the given date and
+	// the leave requests in scope whose dates are all before it, and trims the earlier
+	// dates from the other leave requests in scope.
+	DeleteBefore(ctx context.Context, sc RetentionScope, before string) (int64, int64, error)
+	// GetUserData returns everything stored about a user.
+	GetUserData(ctx context.Context, userID string) (*UserData, error)
+	// DeleteUserData deletes everything GetUserData returns for a user.
+	DeleteUserData(ctx context.Context, userID string) (*UserDataCounts, error)
+}
+
+// TeamSettingsStore holds the per-team settings.
+type TeamSettingsStore interface {
+	// ChangedSince returns the settings of every team updated after t; the zero time returns all.
+	ChangedSince(ctx context.Context, t time.Time) ([]*model.TeamSettings, error)
+	// Save replaces a team's settings, creating them if needed.
+	Save(ctx context.Context, settings *model.TeamSettings) error
+}
+
+// TimesheetStore holds locked monthly timesheets.
+type TimesheetStore interface {
+	// Get returns a team's timesheet for a month (YYYY-MM), or nil if the month was never locked.
+	Get(ctx context.Context, teamID, month string) (*model.Timesheet, error)
+	// Save replaces a team's timesheet for its month, creating it if needed.
+	Save(ctx context.Context, sheet *model.Timesheet) error
+}
+
+// CalendarFeedStore holds the keys that sign each user's calendar feed URLs.
+type CalendarFeedStore interface {
+	// Get returns a user's feed key, or nil if none was issued yet.
+	Get(ctx context.Context, userID string) (*model.CalendarFeed, error)
+	// Create saves a user's first feed key. It does nothing if the user already has one.
+	Create(ctx context.Context, feed *model.CalendarFeed) error
+	// Save replaces a user's feed key, creating it if needed.
+	Save(ctx context.Context, feed *model.CalendarFeed) error
+	// Delete removes a user's feed key, if any.
+	Delete(ctx context.Context, userID string) error
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"oktel-bot/internal/model"
+)
+
+type mongoTeamSettingsStore struct {
+	coll *mongo.Collection
+}
+
+func NewTeamSettingsStore(ctx context.Context, db *MongoDB) (TeamSettingsStore, error) {
+	coll := db.Collection("team_settings")
+
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "updated_at", Value: 1}},
+	}); err != nil {
+		return nil, fmt.Errorf("create team_settings indexes: %w", err)
+	}
+
+	return &mongoTeamSettingsStore{coll: coll}, nil
+}
+
+// ChangedSince returns the settings of every team updated after t; the zero time returns all.
+func (s *mongoTeamSettingsStore) ChangedSince(ctx context.Context, t time.Time) ([]*model.TeamSettings, error) {
+	cursor, err := s.coll.Find(ctx, bson.M{"updated_at": bson.M{"$gt": t}})
+	if err != nil {
+		return nil, fmt.Errorf("find team settings: %w", err)
+	}
+	var results []*model.TeamSettings
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("decode team settings: %w", err)
+	}
+	return results, nil
+}
+
+// Save replaces a team's settings, creating them if needed.
+func (s *mongoTeamSettingsStore) Save(ctx context.Context, settings *model.TeamSettings) error {
+	settings.UpdatedAt = time.Now().Truncate(time.Millisecond) // as stored, so cached copies compare equal
+	_, err := s.coll.ReplaceOne(ctx, bson.M{"_id": settings.TeamID}, settings, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("save team settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"oktel-bot/internal/model"
+)
+
+type mongoTimesheetStore struct {
+	coll *mongo.Collection
+}
+
+func NewTimesheetStore(ctx context.Context, db *MongoDB) (TimesheetStore, error) {
+	coll := db.Collection("timesheets")
+
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "team_id", Value: 1}, {Key: "month", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return nil, fmt.Errorf("create timesheets indexes: %w", err)
+	}
+
+	return &mongoTimesheetStore{coll: coll}, nil
+}
+
+// Get returns a team's stored timesheet for a month (YYYY-MM), or nil if the month was
+// never locked.
+func (s *mongoTimesheetStore) Get(ctx context.Context, teamID, month string) (*model.Timesheet, error) {
+	var sheet model.Timesheet
+	err := s.coll.FindOne(ctx, bson.M{"team_id": teamID, "month": month}).Decode(&sheet)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find timesheet: %w", err)
+	}
+	return &sheet, nil
+}
+
+// Save replaces a team's timesheet for its month, creating it if needed.
+func (s *mongoTimesheetStore) Save(ctx context.Context, sheet *model.Timesheet) error {
+	_, err := s.coll.ReplaceOne(ctx,
+		bson.M{"team_id": sheet.TeamID, "month": sheet.Month},
+		sheet,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("save timesheet: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"oktel-bot/internal/model"
+)
+
+type mongoWebhookStore struct {
+	coll *mongo.Collection
+}
+
+func NewWebhookStore(ctx context.Context, db *MongoDB) (WebhookStore, error) {
+	deliveries := db.Collection("webhook_deliveries")
+
+	if _, err := deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "event_id", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	}); err != nil {
+		return nil, fmt.Errorf("create webhook_deliveries indexes: %w", err)
+	}
+
+	return &mongoWebhookStore{coll: deliveries}, nil
+}
+
+// Create inserts a new delivery and sets the ID on the struct.
+func (s *mongoWebhookStore) Create(ctx context.Context, d *model.WebhookDelivery) error {
+	now := time.Now()
+	d.CreatedAt = now
+	d.UpdatedAt = now
+	res, err := s.coll.InsertOne(ctx, d)
+	if err != nil {
+		return err
+	}
+	d.ID = res.InsertedID.(bson.ObjectID)
+	return nil
+}
+
+func (s *mongoWebhookStore) Update(ctx context.Context, d *model.WebhookDelivery) error {
+	d.UpdatedAt = time.Now()
+	_, err := s.coll.ReplaceOne(ctx, bson.M{"_id": d.ID}, d)
+	return err
+}
+
+func (s *mongoWebhookStore) GetByID(ctx context.Context, id bson.ObjectID) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ClaimDue returns a pending delivery whose next attempt is due and pushes its next
+// attempt back by lease, so a dispatcher that dies mid-delivery leaves it to be retried.
+// It returns nil, nil when nothing is due.
+func (s *mongoWebhookStore) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	err := s.coll.FindOneAndUpdate(ctx,
+		bson.M{"status": model.WebhookDeliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).SetReturnDocument(options.After),
+	).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook delivery: %w", err)
+	}
+	return &d, nil
+}
+
+// List returns the most recent deliveries, newest first, optionally filtered by
+// status, event and event ID.
+func (s *mongoWebhookStore) List(ctx context.Context, status model.WebhookDeliveryStatus, event, eventID string, limit int) ([]*model.WebhookDelivery, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	if event != "" {
+		filter["event"] = event
+	}
+	if eventID != "" {
+		filter["event_id"] = eventID
+	}
+	cursor, err := s.coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("find webhook deliveries: %w", err)
+	}
+	results := []*model.WebhookDelivery{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("decode webhook deliveries: %w", err)
+	}
+	return results, nil
+}
+
+// DeleteFinishedBefore deletes the delivered and failed deliveries created before t.
+func (s *mongoWebhookStore) DeleteFinishedBefore(ctx context.Context, t time.Time) (int64, error) {
+	res, err := s.coll.DeleteMany(ctx, bson.M{
+		"status":     bson.M{"$ne": model.WebhookDeliveryPending},
+		"created_at": bson.M{"$lt": t},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("delete webhook deliveries: %w", err)
+	}
+	return res.DeletedCount, nil
+}
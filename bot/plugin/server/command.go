@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+// commands are registered on activation; commandRoutes maps each trigger to the
+// handler that serves it in the standalone deployment.
+var (
+	commands = []*model.Command{
+		{
+			Trigger:          "diemdanh",
+			DisplayName:      "Attendance",
+			AutoComplete:     true,
+			AutoCompleteDesc: "Check in, check out or take a break",
+		},
+		{
+			Trigger:          "xinphep",
+			DisplayName:      "Leave Request",
+			AutoComplete:     true,
+			AutoCompleteDesc: "Request leave, late arrival, early departure or overtime",
+			AutoCompleteHint: "[calendar [week|month] | pending]",
+		},
+		{
+			Trigger:          "budget",
+			DisplayName:      "Budget Request",
+			AutoComplete:     true,
+			AutoCompleteDesc: "Create a budget request",
+		},
+		{
+			Trigger:          "botadmin",
+			DisplayName:      "Bot Administration",
+			AutoComplete:     true,
+			AutoCompleteDesc: "Export or erase a user's data, set up channels, team settings",
+			AutoCompleteHint: "export [@user] | erase @user | setup | verify | config | breaks",
+		},
+	}
+	commandRoutes = map[string]string{
+		"diemdanh": "/api/diemdanh",
+		"xinphep":  "/api/xinphep",
+		"budget":   "/api/budget",
+		"botadmin": "/api/botadmin",
+	}
+)
+
+// ExecuteCommand replays a slash command as the form POST Mattermost sends to an
+// outgoing command URL, with the same client headers, and returns the handler's response.
+func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+	fields := strings.Fields(args.Command)
+	if len(fields) == 0 {
+		return &model.CommandResponse{}, nil
+	}
+	trigger := strings.TrimPrefix(fields[0], "/")
+	route, ok := commandRoutes[trigger]
+	if !ok || p.bot == nil {
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			Text:         "Unknown command: /" + trigger,
+		}, nil
+	}
+
+	user, appErr := p.API.GetUser(args.UserId)
+	if appErr != nil {
+		return nil, appErr
+	}
+	channel, appErr := p.API.GetChannel(args.ChannelId)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	form := url.Values{}
+	form.Set("command", "/"+trigger)
+	form.Set("text", strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(args.Command), fields[0])))
+	form.Set("user_id", user.Id)
+	form.Set("user_name", user.Username)
+	form.Set("channel_id", channel.Id)
+	form.Set("channel_name", channel.Name)
+	form.Set("team_id", args.TeamId)
+	form.Set("trigger_id", args.TriggerId)
+
+	req := httptest.NewRequest(http.MethodPost, route, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	p.setClientHeaders(req, c)
+
+	rec := httptest.NewRecorder()
+	p.bot.Mux.ServeHTTP(rec, req)
+
+	resp, err := model.CommandResponseFromHTTPBody(rec.Header().Get("Content-Type"), rec.Body)
+	if err != nil {
+		return nil, model.NewAppError("ExecuteCommand", "plugin.oktel_bot.command_response.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+)
+
+// configuration mirrors the settings_schema in plugin.json. Settings not exposed there
+// (photo checks, overtime rates, office networks, ...) are read from the server's
+// environment exactly like the standalone service does.
+type configuration struct {
+	BlockMobile          bool
+	ActivityCheckEnabled bool
+	ActivityCheckChannel string
+	CalendarSecret       string
+	Webhooks             string
+	RetentionPolicies    string
+}
+
+// getConfiguration returns the active configuration. The returned value must not be modified.
+func (p *Plugin) getConfiguration() *configuration {
+	p.configurationLock.RLock()
+	defer p.configurationLock.RUnlock()
+
+	if p.configuration == nil {
+		return &configuration{}
+	}
+	return p.configuration
+}
+
+// OnConfigurationChange loads the plugin settings. The bot is wired once on activation,
+// so changes take effect after the plugin is re-enabled.
+func (p *Plugin) OnConfigurationChange() error {
+	conf := new(configuration)
+	if err := p.API.LoadPluginConfiguration(conf); err != nil {
+		return fmt.Errorf("load plugin configuration: %w", err)
+	}
+
+	p.configurationLock.Lock()
+	p.configuration = conf
+	p.configurationLock.Unlock()
+	return nil
+}
@@ -0,0 +1,4 @@
+package main
+
+// manifestID must match "id" in plugin.json; it is part of every callback URL the bot posts.
+const manifestID = "com.oktel.bot"
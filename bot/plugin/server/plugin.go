@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+
+	"oktel-bot/internal/app"
+	"oktel-bot/internal/config"
+	"oktel-bot/internal/i18n"
+	"oktel-bot/internal/store/sqlstore"
+)
+
+// maxBody bounds the action and dialog bodies ServeHTTP rewrites.
+const maxBody = 1 << 20
+
+// The two bot identities, as in the standalone deployment.
+var (
+	attendanceBot = &model.Bot{
+		Username:    "attendance-bot",
+		DisplayName: "Attendance",
+		Description: "Check-in, check-out and leave requests.",
+	}
+	budgetBot = &model.Bot{
+		Username:    "budget-bot",
+		DisplayName: "Budget",
+		Description: "Budget request approvals.",
+	}
+)
+
+// Plugin runs the attendance and budget bots inside the Mattermost server. Actions and
+// dialogs call back into ServeHTTP, slash commands arrive through ExecuteCommand, and the
+// bots talk to the server through access tokens the plugin issues to its own bot accounts.
+// Records are kept in the Mattermost database, so every server of a cluster shares them.
+type Plugin struct {
+	plugin.MattermostPlugin
+	client *pluginapi.Client
+
+	configurationLock sync.RWMutex
+	configuration     *configuration
+
+	bot  *app.App
+	stop context.CancelFunc
+}
+
+// botTokenRecord is the access token of a bot account, kept in the KV store so that every
+// server of a cluster and every activation uses the same one.
+type botTokenRecord struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+}
+
+func (p *Plugin) OnActivate() error {
+	p.client = pluginapi.NewClient(p.API, p.Driver)
+	i18n.Init("")
+
+	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
+	if siteURL == nil || *siteURL == "" {
+		return errors.New("Site URL must be configured for the bot's callback URLs")
+	}
+	baseURL := strings.TrimRight(*siteURL, "/")
+
+	if driver := p.client.Store.DriverName(); driver != model.DatabaseDriverPostgres {
+		return fmt.Errorf("the bot needs a PostgreSQL database, not %s", driver)
+	}
+
+	conf := p.getConfiguration()
+	cfg := config.Load()
+	cfg.BlockMobile = conf.BlockMobile
+	cfg.ActivityCheckEnabled = conf.ActivityCheckEnabled
+	cfg.ActivityCheckChannel = conf.ActivityCheckChannel
+	cfg.CalendarSecret = conf.CalendarSecret
+	cfg.Webhooks = conf.Webhooks
+	cfg.RetentionPolicies = conf.RetentionPolicies
+	cfg.SetupSlashCommands = false // registered below
+	cfg.MattermostURL = baseURL
+	cfg.BotURL = baseURL + "/plugins/" + manifestID
+	cfg.CalendarURL = cfg.BotURL
+
+	attendanceToken, err := p.botToken(attendanceBot, baseURL)
+	if err != nil {
+		return err
+	}
+	budgetToken, err := p.botToken(budgetBot, baseURL)
+	if err != nil {
+		return err
+	}
+
+	db, err := p.client.Store.GetMasterDB()
+	if err != nil {
+		return fmt.Errorf("get database: %w", err)
+	}
+	initCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	stores, err := sqlstore.New(initCtx, db)
+	if err != nil {
+		return fmt.Errorf("init stores: %w", err)
+	}
+	bot, err := app.New(initCtx, cfg, stores,
+		app.NewMattermostClient(cfg, attendanceToken),
+		app.NewMattermostClient(cfg, budgetToken))
+	if err != nil {
+		return err
+	}
+	bot.Exclusive = p.runExclusive
+
+	for _, cmd := range commands {
+		if err := p.client.SlashCommand.Register(cmd); err != nil {
+			return fmt.Errorf("register /%s: %w", cmd.Trigger, err)
+		}
+	}
+
+	runCtx, stop := context.WithCancel(context.Background())
+	bot.Start(runCtx)
+
+	p.bot = bot
+	p.stop = stop
+	return nil
+}
+
+// OnDeactivate stops the background jobs. The bot tokens are kept for the next activation
+// and for the other servers of the cluster, which may still be running the plugin.
+func (p *Plugin) OnDeactivate() error {
+	if p.stop != nil {
+		p.stop()
+	}
+	p.bot, p.stop = nil, nil
+	return nil
+}
+
+// UserHasBeenDeactivated revokes the calendar feed URLs issued to a deactivated user, so
+// that calendar apps stop receiving the team's leave.
+func (p *Plugin) UserHasBeenDeactivated(c *plugin.Context, user *model.User) {
+	if p.bot == nil {
+		return
+	}
+	if err := p.bot.UserDeactivated(context.Background(), user.Id); err != nil {
+		p.client.Log.Error("Failed to revoke the calendar feed of a deactivated user", "user_id", user.Id, "error", err.Error())
+	}
+}
+
+// runExclusive runs a background job on one server of the cluster at a time. The others
+// wait for the lock and take over the job if the server holding it goes away.
+func (p *Plugin) runExclusive(ctx context.Context, name string, job func(ctx context.Context)) {
+	mutex, err := cluster.NewMutex(p.API, "job_"+name)
+	if err != nil {
+		p.client.Log.Error("Failed to create job lock", "job", name, "error", err.Error())
+		return
+	}
+	if err := mutex.LockWithContext(ctx); err != nil {
+		return // deactivated while waiting
+	}
+	defer mutex.Unlock()
+	job(ctx)
+}
+
+// botToken ensures the bot account exists and returns its access token, issuing one only
+// if none is stored or the stored one no longer works. Servers of a cluster activate the
+// plugin at the same time, so a new token is stored only if no other server stored one
+// first; otherwise that server's token is used.
+func (p *Plugin) botToken(bot *model.Bot, baseURL string) (string, error) {
+	botID, err := p.client.Bot.EnsureBot(bot)
+	if err != nil {
+		return "", fmt.Errorf("ensure bot @%s: %w", bot.Username, err)
+	}
+
+	key := "token_" + botID
+	for range 2 {
+		var stored []byte
+		if err := p.client.KV.Get(key, &stored); err != nil {
+			return "", fmt.Errorf("read token for @%s: %w", bot.Username, err)
+		}
+		var old botTokenRecord
+		if stored != nil && json.Unmarshal(stored, &old) == nil && tokenWorks(baseURL, old.Token) {
+			return old.Token, nil
+		}
+
+		token, appErr := p.API.CreateUserAccessToken(&model.UserAccessToken{
+			UserId:      botID,
+			Description: "Issued by the " + manifestID + " plugin",
+		})
+		if appErr != nil {
+			return "", fmt.Errorf("create token for @%s: %w", bot.Username, appErr)
+		}
+		record, _ := json.Marshal(botTokenRecord{ID: token.Id, Token: token.Token})
+		saved, err := p.client.KV.Set(key, record, pluginapi.SetAtomic(stored))
+		if err != nil || !saved {
+			p.API.RevokeUserAccessToken(token.Id)
+			if err != nil {
+				return "", fmt.Errorf("store token for @%s: %w", bot.Username, err)
+			}
+			continue // another server stored its token first
+		}
+		if old.ID != "" {
+			p.API.RevokeUserAccessToken(old.ID)
+		}
+		return token.Token, nil
+	}
+	return "", fmt.Errorf("store token for @%s: changed concurrently", bot.Username)
+}
+
+// tokenWorks reports whether an access token is still accepted. When the server cannot be
+// reached, e.g. because it is still starting, the token is assumed to work.
+func tokenWorks(baseURL, token string) bool {
+	if token == "" {
+		return false
+	}
+	client := model.NewAPIv4Client(baseURL)
+	client.SetToken(token)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, resp, err := client.GetMe(ctx, "")
+	return err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized
+}
+
+// ServeHTTP serves the bot's routes under /plugins/com.oktel.bot. Every route needs a
+// Mattermost session (the server sets Mattermost-User-Id for those, including action and
+// dialog callbacks) except the calendar feed, authenticated by its signed URL (the only
+// route the feed is served on, see CalendarService.FeedEnabled), and the
+// REST API, authenticated by API key. Slash command routes are only served through
+// ExecuteCommand.
+func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
+	if p.bot == nil {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	for _, route := range commandRoutes {
+		if r.URL.Path == route {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	p.setClientHeaders(r, c)
+	if r.URL.Path == "/api/attendance/calendar.ics" || strings.HasPrefix(r.URL.Path, "/api/v1/") {
+		p.bot.Mux.ServeHTTP(w, r)
+		return
+	}
+	userID := r.Header.Get("Mattermost-User-Id")
+	if userID == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := setCaller(r, user); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errUnsupportedBody) {
+			status = http.StatusUnsupportedMediaType
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	p.bot.Mux.ServeHTTP(w, r)
+}
+
+// clientHeaders describe the caller's device and address. Mattermost sends them to
+// outgoing integrations, and the attendance policies (mobile block, office network) rely
+// on them.
+var clientHeaders = []string{
+	"X-Mattermost-Is-Mobile",
+	"X-Mattermost-Platform",
+	"X-Mattermost-Os",
+	"X-Mattermost-Browser",
+	"X-Mattermost-Client-Ip",
+}
+
+// setClientHeaders replaces the client headers of r, which the client may have set to
+// anything, with what the server knows: the session's device and the address the server
+// saw the request from. Without a session the caller counts as mobile, and without an
+// address the office network check fails, so the policies fail closed.
+func (p *Plugin) setClientHeaders(r *http.Request, c *plugin.Context) {
+	for _, header := range clientHeaders {
+		r.Header.Del(header)
+	}
+	var session *model.Session
+	if c.SessionId != "" {
+		session, _ = p.API.GetSession(c.SessionId)
+	}
+	if session == nil {
+		r.Header.Set("X-Mattermost-Is-Mobile", "true")
+	} else {
+		r.Header.Set("X-Mattermost-Is-Mobile", strconv.FormatBool(session.IsMobileApp()))
+		for header, prop := range map[string]string{
+			"X-Mattermost-Platform": model.SessionPropPlatform,
+			"X-Mattermost-Os":       model.SessionPropOs,
+			"X-Mattermost-Browser":  model.SessionPropBrowser,
+		} {
+			if value := session.Props[prop]; value != "" {
+				r.Header.Set(header, value)
+			}
+		}
+	}
+	if c.IPAddress != "" {
+		r.Header.Set("X-Mattermost-Client-Ip", c.IPAddress)
+	}
+}
+
+// errUnsupportedBody is returned for a body setCaller cannot rewrite. The handlers decode
+// JSON whatever the Content-Type says, so such a body could carry someone else's user_id.
+var errUnsupportedBody = errors.New("unsupported content type")
+
+// setCaller overwrites the user_id and user_name the handlers read from the query, a form
+// or a JSON body with the session's user, so that a request cannot act as someone else. A
+// POST with a body of any other type is refused.
+func setCaller(r *http.Request, user *model.User) error {
+	query := r.URL.Query()
+	if query.Has("user_id") || query.Has("user_name") {
+		query.Set("user_id", user.Id)
+		query.Set("user_name", user.Username)
+		r.URL.RawQuery = query.Encode()
+	}
+	if r.Method != http.MethodPost || r.Body == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBody))
+	if err != nil {
+		return errors.New("invalid body")
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case len(body) == 0:
+		setBody(r, body)
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return errors.New("invalid form")
+		}
+		form.Set("user_id", user.Id)
+		form.Set("user_name", user.Username)
+		setBody(r, []byte(form.Encode()))
+	case mediaType == "application/json":
+		var payload map[string]json.RawMessage
+		if json.Unmarshal(body, &payload) != nil {
+			return errors.New("invalid JSON body")
+		}
+		// encoding/json matches keys case-insensitively, so drop every spelling.
+		for key := range payload {
+			if strings.EqualFold(key, "user_id") || strings.EqualFold(key, "user_name") {
+				delete(payload, key)
+			}
+		}
+		payload["user_id"], _ = json.Marshal(user.Id)
+		payload["user_name"], _ = json.Marshal(user.Username)
+		body, _ = json.Marshal(payload)
+		setBody(r, body)
+	default:
+		return errUnsupportedBody
+	}
+	return nil
+}
+
+func setBody(r *http.Request, body []byte) {
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Del("Content-Length")
+}
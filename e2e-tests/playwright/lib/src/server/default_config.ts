@@ -115,6 +115,7 @@ const defaultServerConfig: AdminConfig = {
         EnableOutgoingOAuthConnections: false,
         EnableCommands: true,
         OutgoingIntegrationRequestsTimeout: 30,
+        ForwardClientIPToIntegrations: false,
         EnablePostUsernameOverride: false,
         EnablePostIconOverride: false,
         GoogleDeveloperKey: '',
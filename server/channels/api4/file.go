@@ -33,6 +33,7 @@ const maxMultipartFormDataBytes = 10 * 1024 // 10Kb
 func (api *API) InitFile() {
 	api.BaseRoutes.Files.Handle("", api.APISessionRequired(uploadFileStream, handlerParamFileAPI)).Methods(http.MethodPost)
 	api.BaseRoutes.File.Handle("", api.APISessionRequiredTrustRequester(getFile)).Methods(http.MethodGet)
+	api.BaseRoutes.File.Handle("", api.APISessionRequired(deleteFile)).Methods(http.MethodDelete)
 	api.BaseRoutes.File.Handle("/thumbnail", api.APISessionRequiredTrustRequester(getFileThumbnail)).Methods(http.MethodGet)
 	api.BaseRoutes.File.Handle("/link", api.APISessionRequired(getFileLink)).Methods(http.MethodGet)
 	api.BaseRoutes.File.Handle("/preview", api.APISessionRequiredTrustRequester(getFilePreview)).Methods(http.MethodGet)
@@ -801,6 +802,46 @@ func getFileInfo(c *Context, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// deleteFile permanently deletes an uploaded file that was never attached to a post, such as
+// a file submitted through an interactive dialog. Files attached to posts are deleted with
+// their post.
+func deleteFile(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireFileId()
+	if c.Err != nil {
+		return
+	}
+
+	auditRec := c.MakeAuditRecord(model.AuditEventDeleteFile, model.AuditStatusFail)
+	defer c.LogAuditRec(auditRec)
+	model.AddEventParameterToAuditRec(auditRec, "file_id", c.Params.FileId)
+
+	info, err := c.App.GetFileInfo(c.AppContext, c.Params.FileId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+	auditRec.AddEventPriorState(info)
+
+	if info.PostId != "" || info.CreatorId == model.BookmarkFileOwner {
+		c.Err = model.NewAppError("deleteFile", "api.file.delete_file.attached.app_error", nil, "", http.StatusBadRequest)
+		return
+	}
+
+	if info.CreatorId != c.AppContext.Session().UserId &&
+		!c.App.SessionHasPermissionToChannel(c.AppContext, *c.AppContext.Session(), info.ChannelId, model.PermissionDeleteOthersPosts) {
+		c.SetPermissionError(model.PermissionDeleteOthersPosts)
+		return
+	}
+
+	if err := c.App.PermanentDeleteFile(c.AppContext, info); err != nil {
+		c.Err = err
+		return
+	}
+
+	auditRec.Success()
+	ReturnStatusOK(w)
+}
+
 func getPublicFile(c *Context, w http.ResponseWriter, r *http.Request) {
 	c.RequireFileId()
 	if c.Err != nil {
@@ -1365,6 +1365,75 @@ func TestGetFileInfo(t *testing.T) {
 	CheckForbiddenStatus(t, resp)
 }
 
+func TestDeleteFile(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+	client := th.Client
+	channel := th.BasicChannel
+
+	if *th.App.Config().FileSettings.DriverName == "" {
+		t.Skip("skipping because no file driver is enabled")
+	}
+
+	data, err := testutils.ReadTestFile("test.png")
+	require.NoError(t, err)
+
+	upload := func(c *model.Client4) string {
+		fileResp, _, err := c.UploadFile(context.Background(), data, channel.Id, "test.png")
+		require.NoError(t, err)
+		return fileResp.FileInfos[0].Id
+	}
+
+	t.Run("uploader can delete an unattached file", func(t *testing.T) {
+		fileId := upload(client)
+
+		_, err := client.DeleteFile(context.Background(), fileId)
+		require.NoError(t, err)
+
+		_, resp, err := client.GetFileInfo(context.Background(), fileId)
+		require.Error(t, err)
+		CheckNotFoundStatus(t, resp)
+	})
+
+	t.Run("other user without permission is forbidden", func(t *testing.T) {
+		fileId := upload(client)
+
+		otherClient := th.CreateClient()
+		otherUser := th.CreateUser(t)
+		th.LinkUserToTeam(t, otherUser, th.BasicTeam)
+		th.AddUserToChannel(t, otherUser, channel)
+		_, _, err := otherClient.Login(context.Background(), otherUser.Email, otherUser.Password)
+		require.NoError(t, err)
+
+		resp, err := otherClient.DeleteFile(context.Background(), fileId)
+		require.Error(t, err)
+		CheckForbiddenStatus(t, resp)
+	})
+
+	t.Run("system admin can delete another user's file", func(t *testing.T) {
+		fileId := upload(client)
+
+		_, err := th.SystemAdminClient.DeleteFile(context.Background(), fileId)
+		require.NoError(t, err)
+	})
+
+	t.Run("file attached to a post is rejected", func(t *testing.T) {
+		fileId := upload(client)
+		_, _, err := client.CreatePost(context.Background(), &model.Post{ChannelId: channel.Id, Message: "with file", FileIds: []string{fileId}})
+		require.NoError(t, err)
+
+		resp, err := client.DeleteFile(context.Background(), fileId)
+		require.Error(t, err)
+		CheckBadRequestStatus(t, resp)
+	})
+
+	t.Run("unknown file", func(t *testing.T) {
+		resp, err := client.DeleteFile(context.Background(), model.NewId())
+		require.Error(t, err)
+		CheckNotFoundStatus(t, resp)
+	})
+}
+
 func TestGetPublicFile(t *testing.T) {
 	mainHelper.Parallel(t)
 	th := Setup(t).InitBasic(t)
@@ -566,6 +566,9 @@ func (a *App) DoCommandRequest(rctx request.CTX, cmd *model.Command, p url.Value
 	if browser := rctx.Session().Props[model.SessionPropBrowser]; browser != "" {
 		req.Header.Set("X-Mattermost-Browser", browser)
 	}
+	if ip := rctx.IPAddress(); ip != "" && *a.Config().ServiceSettings.ForwardClientIPToIntegrations {
+		req.Header.Set("X-Mattermost-Client-Ip", ip)
+	}
 
 	resp, err := a.Srv().outgoingWebhookClient.Do(req)
 	if err != nil {
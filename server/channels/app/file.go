@@ -30,6 +30,7 @@ import (
 	"github.com/mattermost/mattermost/server/v8/channels/store"
 	"github.com/mattermost/mattermost/server/v8/channels/utils"
 	"github.com/mattermost/mattermost/server/v8/platform/services/docextractor"
+	"github.com/mattermost/mattermost/server/v8/platform/services/filededup"
 	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
 
 	"github.com/pkg/errors"
@@ -50,6 +51,18 @@ func (a *App) FileBackend() filestore.FileBackend {
 	return a.ch.filestore
 }
 
+// saveFileInfo saves info, recording the deduplicated blob its file is stored in, if any.
+func (a *App) saveFileInfo(rctx request.CTX, info *model.FileInfo) (*model.FileInfo, error) {
+	if backend, ok := filestore.UnwrapFileBackend[*filededup.DedupFileBackend](a.FileBackend()); ok {
+		blobId, err := backend.BlobId(info.Path)
+		if err != nil {
+			rctx.Logger().Warn("Unable to find the blob of the file", mlog.String("path", info.Path), mlog.Err(err))
+		}
+		info.BlobId = blobId
+	}
+	return a.Srv().Store().FileInfo().Save(rctx, info)
+}
+
 func (a *App) ExportFileBackend() filestore.FileBackend {
 	return a.ch.exportFilestore
 }
@@ -98,7 +111,7 @@ func (a *App) TestFileStoreConnectionWithConfig(cfg *model.FileSettings) *model.
 		allowInsecure := a.Config().ServiceSettings.EnableInsecureOutgoingConnections != nil && *a.Config().ServiceSettings.EnableInsecureOutgoingConnections
 		backend, err = filestore.NewFileBackend(filestore.NewExportFileBackendSettingsFromConfig(cfg, complianceEnabled && license.IsCloud(), allowInsecure))
 	} else {
-		backend, err = filestore.NewFileBackend(filestore.NewFileBackendSettingsFromConfig(cfg, complianceEnabled, insecure != nil && *insecure))
+		backend, err = filededup.NewFileBackend(filestore.NewFileBackendSettingsFromConfig(cfg, complianceEnabled, insecure != nil && *insecure), cfg, a.Srv().Store().FileBlob())
 	}
 	if err != nil {
 		return model.NewAppError("FileAttachmentBackend", "api.file.no_driver.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
@@ -541,7 +554,7 @@ func (a *App) MigrateFilenamesToFileInfos(rctx request.CTX, post *model.Post) []
 	savedInfos := make([]*model.FileInfo, 0, len(infos))
 	fileIDs := make([]string, 0, len(filenames))
 	for _, info := range infos {
-		if _, nErr = a.Srv().Store().FileInfo().Save(rctx, info); nErr != nil {
+		if _, nErr = a.saveFileInfo(rctx, info); nErr != nil {
 			rctx.Logger().Error(
 				"Unable to save file info when migrating post to use FileInfos",
 				mlog.String("post_id", post.Id),
@@ -775,7 +788,7 @@ func (t *UploadFileTask) init(a *App) {
 
 	t.pluginsEnvironment = a.GetPluginsEnvironment()
 	t.writeFile = a.WriteFile
-	t.saveToDatabase = a.Srv().Store().FileInfo().Save
+	t.saveToDatabase = a.saveFileInfo
 }
 
 // UploadFileX uploads a single file as specified in t. It applies the upload
@@ -1122,7 +1135,7 @@ func (a *App) DoUploadFileExpectModification(rctx request.CTX, now time.Time, ra
 		return nil, data, err
 	}
 
-	if _, err := a.Srv().Store().FileInfo().Save(rctx, info); err != nil {
+	if _, err := a.saveFileInfo(rctx, info); err != nil {
 		var appErr *model.AppError
 		switch {
 		case errors.As(err, &appErr):
@@ -1661,6 +1674,18 @@ func (a *App) PermanentDeleteFilesByPost(rctx request.CTX, postID string) *model
 	return nil
 }
 
+// PermanentDeleteFile removes a file that is not attached to a post, with its preview and
+// thumbnail, from the file store and the database.
+func (a *App) PermanentDeleteFile(rctx request.CTX, info *model.FileInfo) *model.AppError {
+	a.RemoveFilesFromFileStore(rctx, []*model.FileInfo{info})
+
+	if err := a.Srv().Store().FileInfo().PermanentDelete(rctx, info.Id); err != nil {
+		return model.NewAppError("PermanentDeleteFile", "app.file_info.permanent_delete.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return nil
+}
+
 func (a *App) RemoveFilesFromFileStore(rctx request.CTX, fileInfos []*model.FileInfo) {
 	for _, info := range fileInfos {
 		a.RemoveFileFromFileStore(rctx, info.Path)
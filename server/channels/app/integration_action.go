@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
@@ -342,6 +343,9 @@ func (a *App) DoActionRequest(rctx request.CTX, rawURL string, body []byte) (*ht
 	if browser := rctx.Session().Props[model.SessionPropBrowser]; browser != "" {
 		req.Header.Set("X-Mattermost-Browser", browser)
 	}
+	if ip := rctx.IPAddress(); ip != "" && *a.Config().ServiceSettings.ForwardClientIPToIntegrations {
+		req.Header.Set("X-Mattermost-Client-Ip", ip)
+	}
 
 	// Allow access to plugin routes for action buttons
 	var httpClient *http.Client
@@ -438,6 +442,11 @@ func (ch *Channels) doPluginRequest(rctx request.CTX, method, rawURL string, val
 	}
 	r.Header.Set("Mattermost-User-Id", rctx.Session().UserId)
 	r.Header.Set(model.HeaderAuth, "Bearer "+rctx.Session().Token)
+	// Pass on the address of the client whose request this is, so that the plugin sees
+	// it as the plugin.Context IPAddress of a request the client made itself.
+	if ip := rctx.IPAddress(); ip != "" {
+		r.RemoteAddr = net.JoinHostPort(ip, "0")
+	}
 	params := make(map[string]string)
 	params["plugin_id"] = pluginID
 	r = mux.SetURLVars(r, params)
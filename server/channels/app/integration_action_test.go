@@ -1507,6 +1507,32 @@ func TestDoActionRequest(t *testing.T) {
 		require.NotNil(t, err)
 		assert.Nil(t, resp)
 	})
+
+	t.Run("should only forward the client IP when enabled", func(t *testing.T) {
+		var clientIP string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientIP = r.Header.Get("X-Mattermost-Client-Ip")
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		c := th.Context.WithIPAddress("203.0.113.7")
+		resp, err := th.App.DoActionRequest(c, ts.URL, []byte(`{}`))
+		require.Nil(t, err)
+		resp.Body.Close()
+		assert.Empty(t, clientIP)
+
+		th.App.UpdateConfig(func(cfg *model.Config) {
+			*cfg.ServiceSettings.ForwardClientIPToIntegrations = true
+		})
+		defer th.App.UpdateConfig(func(cfg *model.Config) {
+			*cfg.ServiceSettings.ForwardClientIPToIntegrations = false
+		})
+		resp, err = th.App.DoActionRequest(c, ts.URL, []byte(`{}`))
+		require.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, "203.0.113.7", clientIP)
+	})
 }
 
 func TestDoLocalRequest(t *testing.T) {
@@ -107,6 +107,10 @@ func (ps *PlatformService) SaveConfig(newCfg *model.Config, sendConfigChangeClus
 		}
 	}
 
+	if appErr := ps.checkDisableDeduplication(newCfg); appErr != nil {
+		return nil, nil, appErr
+	}
+
 	oldCfg, newCfg, err := ps.configStore.Set(newCfg)
 	if errors.Is(err, config.ErrReadOnlyConfiguration) {
 		return nil, nil, model.NewAppError("saveConfig", "ent.cluster.save_config.error", nil, "", http.StatusForbidden).Wrap(err)
@@ -125,6 +129,23 @@ func (ps *PlatformService) SaveConfig(newCfg *model.Config, sendConfigChangeClus
 	return oldCfg, newCfg, nil
 }
 
+// checkDisableDeduplication refuses to turn file deduplication off while paths reference blobs,
+// since a file backend without deduplication cannot read those files.
+func (ps *PlatformService) checkDisableDeduplication(newCfg *model.Config) *model.AppError {
+	if !*ps.Config().FileSettings.EnableDeduplication || model.SafeDereference(newCfg.FileSettings.EnableDeduplication) {
+		return nil
+	}
+
+	hasReferences, err := ps.Store.FileBlob().HasReferences()
+	if err != nil {
+		return model.NewAppError("saveConfig", "app.save_config.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	if hasReferences {
+		return model.NewAppError("saveConfig", "app.save_config.disable_file_deduplication.app_error", nil, "", http.StatusBadRequest)
+	}
+	return nil
+}
+
 func (ps *PlatformService) ReloadConfig() error {
 	if err := ps.configStore.Load(); err != nil {
 		return err
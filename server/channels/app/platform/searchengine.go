@@ -17,6 +17,14 @@ func (ps *PlatformService) StartSearchEngine() (string, string) {
 		})
 	}
 
+	if ps.SearchEngine.EmbeddedEngine != nil && ps.SearchEngine.EmbeddedEngine.IsEnabled() {
+		ps.Go(func() {
+			if err := ps.SearchEngine.EmbeddedEngine.Start(); err != nil {
+				ps.Log().Error(err.Error())
+			}
+		})
+	}
+
 	configListenerId := ps.AddConfigListener(func(oldConfig *model.Config, newConfig *model.Config) {
 		if ps.SearchEngine == nil {
 			return
@@ -50,6 +58,8 @@ func (ps *PlatformService) StartSearchEngine() (string, string) {
 				}
 			})
 		}
+
+		ps.updateEmbeddedSearchEngine(oldConfig, newConfig)
 	})
 
 	licenseListenerId := ps.AddLicenseListener(func(oldLicense, newLicense *model.License) {
@@ -78,6 +88,39 @@ func (ps *PlatformService) StartSearchEngine() (string, string) {
 	return configListenerId, licenseListenerId
 }
 
+// updateEmbeddedSearchEngine starts or stops the embedded engine when indexing is toggled,
+// and restarts it when the index directory or analyzer changes.
+func (ps *PlatformService) updateEmbeddedSearchEngine(oldConfig, newConfig *model.Config) {
+	engine := ps.SearchEngine.EmbeddedEngine
+	if engine == nil {
+		return
+	}
+
+	oldSettings, newSettings := oldConfig.EmbeddedSearchSettings, newConfig.EmbeddedSearchSettings
+	if !*oldSettings.EnableIndexing && *newSettings.EnableIndexing {
+		ps.Go(func() {
+			if err := engine.Start(); err != nil {
+				ps.Log().Error(err.Error())
+			}
+		})
+	} else if *oldSettings.EnableIndexing && !*newSettings.EnableIndexing {
+		ps.Go(func() {
+			if err := engine.Stop(); err != nil {
+				ps.Log().Error(err.Error())
+			}
+		})
+	} else if *newSettings.EnableIndexing && (*oldSettings.IndexDir != *newSettings.IndexDir || *oldSettings.Analyzer != *newSettings.Analyzer) {
+		ps.Go(func() {
+			if err := engine.Stop(); err != nil {
+				ps.Log().Error(err.Error())
+			}
+			if err := engine.Start(); err != nil {
+				ps.Log().Error(err.Error())
+			}
+		})
+	}
+}
+
 func (ps *PlatformService) StopSearchEngine() {
 	ps.RemoveConfigListener(ps.searchConfigListenerId)
 	ps.RemoveLicenseListener(ps.searchLicenseListenerId)
@@ -86,4 +129,9 @@ func (ps *PlatformService) StopSearchEngine() {
 			ps.Log().Error("Failed to stop Elasticsearch engine", mlog.Err(err))
 		}
 	}
+	if ps.SearchEngine != nil && ps.SearchEngine.EmbeddedEngine != nil && ps.SearchEngine.EmbeddedEngine.IsActive() {
+		if err := ps.SearchEngine.EmbeddedEngine.Stop(); err != nil {
+			ps.Log().Error("Failed to stop embedded search engine", mlog.Err(err))
+		}
+	}
 }
@@ -107,7 +107,7 @@ func (a *App) SendReportToUser(rctx request.CTX, job *model.Job, format string)
 	if err != nil {
 		return err
 	}
-	fileInfo, fileErr := a.Srv().Store().FileInfo().Save(rctx, &model.FileInfo{
+	fileInfo, fileErr := a.saveFileInfo(rctx, &model.FileInfo{
 		Name:      makeCompiledFilename(job.Id, format),
 		Extension: format,
 		Size:      size,
@@ -41,6 +41,7 @@ import (
 	"github.com/mattermost/mattermost/server/v8/channels/jobs"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/active_users"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/cleanup_desktop_tokens"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs/cleanup_file_blobs"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/delete_dms_preferences_migration"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/delete_empty_drafts_migration"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/delete_expired_posts"
@@ -50,6 +51,8 @@ import (
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/export_process"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/export_users_to_csv"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/extract_content"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs/file_dedup"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs/file_reencryption"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/hosted_purchase_screening"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/import_delete"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/import_process"
@@ -71,6 +74,8 @@ import (
 	"github.com/mattermost/mattermost/server/v8/platform/services/awsmeter"
 	"github.com/mattermost/mattermost/server/v8/platform/services/cache"
 	"github.com/mattermost/mattermost/server/v8/platform/services/remotecluster"
+	"github.com/mattermost/mattermost/server/v8/platform/services/searchengine/embeddedengine"
+	"github.com/mattermost/mattermost/server/v8/platform/services/searchengine/embeddedengine/indexer"
 	"github.com/mattermost/mattermost/server/v8/platform/services/sharedchannel"
 	"github.com/mattermost/mattermost/server/v8/platform/services/telemetry"
 	"github.com/mattermost/mattermost/server/v8/platform/services/upgrader"
@@ -207,13 +212,14 @@ func NewServer(options ...Option) (*Server, error) {
 
 	// Needed to run before loading license.
 	s.userService, err = users.New(users.ServiceConfig{
-		UserStore:    s.Store().User(),
-		SessionStore: s.Store().Session(),
-		OAuthStore:   s.Store().OAuth(),
-		ConfigFn:     s.platform.Config,
-		Metrics:      s.GetMetrics(),
-		Cluster:      s.platform.Cluster(),
-		LicenseFn:    s.License,
+		UserStore:     s.Store().User(),
+		SessionStore:  s.Store().Session(),
+		OAuthStore:    s.Store().OAuth(),
+		ConfigFn:      s.platform.Config,
+		Metrics:       s.GetMetrics(),
+		Cluster:       s.platform.Cluster(),
+		LicenseFn:     s.License,
+		FileBlobStore: s.Store().FileBlob(),
 	})
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to create users service")
@@ -834,7 +840,8 @@ func (s *Server) Start() error {
 	err := s.FileBackend().TestConnection()
 	if err != nil {
 		if _, ok := err.(*filestore.S3FileBackendNoBucketError); ok {
-			err = s.FileBackend().(*filestore.S3FileBackend).MakeBucket()
+			backend, _ := filestore.UnwrapFileBackend[*filestore.S3FileBackend](s.FileBackend())
+			err = backend.MakeBucket()
 		}
 		if err != nil {
 			mlog.Error("Problem with file storage settings", mlog.Err(err))
@@ -1457,6 +1464,11 @@ func (s *Server) initJobs() {
 		s.Jobs.RegisterJobType(model.JobTypeElasticsearchPostIndexing, builder.MakeWorker(), nil)
 	}
 
+	if engine, ok := s.platform.SearchEngine.EmbeddedEngine.(*embeddedengine.EmbeddedEngine); ok {
+		s.Jobs.RegisterJobType(model.JobTypeEmbeddedSearchIndexing, indexer.NewIndexerJob(s.Jobs, engine).MakeWorker(), nil)
+		s.Jobs.RegisterJobType(model.JobTypeEmbeddedSearchPurge, indexer.NewPurgeJob(s.Jobs, engine).MakeWorker(), nil)
+	}
+
 	if jobsLdapSyncInterface != nil {
 		builder := jobsLdapSyncInterface(New(ServerConnector(s.Channels())))
 		s.Jobs.RegisterJobType(model.JobTypeLdapSync, builder.MakeWorker(), builder.MakeScheduler())
@@ -1559,6 +1571,24 @@ func (s *Server) initJobs() {
 		nil,
 	)
 
+	s.Jobs.RegisterJobType(
+		model.JobTypeFileReencryption,
+		file_reencryption.MakeWorker(s.Jobs, s.FileBackend),
+		nil,
+	)
+
+	s.Jobs.RegisterJobType(
+		model.JobTypeFileDedup,
+		file_dedup.MakeWorker(s.Jobs, s.FileBackend),
+		nil,
+	)
+
+	s.Jobs.RegisterJobType(
+		model.JobTypeCleanupFileBlobs,
+		cleanup_file_blobs.MakeWorker(s.Jobs, s.FileBackend),
+		cleanup_file_blobs.MakeScheduler(s.Jobs),
+	)
+
 	s.Jobs.RegisterJobType(
 		model.JobTypeLastAccessiblePost,
 		last_accessible_post.MakeWorker(s.Jobs, s.License(), New(ServerConnector(s.Channels()))),
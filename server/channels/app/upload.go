@@ -18,6 +18,7 @@ import (
 	"github.com/mattermost/mattermost/server/public/shared/mlog"
 	"github.com/mattermost/mattermost/server/public/shared/request"
 	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/platform/services/filededup"
 	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
 )
 
@@ -288,6 +289,13 @@ func (a *App) UploadData(rctx request.CTX, us *model.UploadSession, rd io.Reader
 		return nil, nil
 	}
 
+	// the parts of a resumed upload are appended to a file stored at its own path
+	if backend, ok := filestore.UnwrapFileBackend[*filededup.DedupFileBackend](a.FileBackend()); ok {
+		if _, dedupErr := backend.Dedup(uploadPath); dedupErr != nil {
+			rctx.Logger().Warn("Failed to deduplicate uploaded file", mlog.String("path", uploadPath), mlog.Err(dedupErr))
+		}
+	}
+
 	// upload is done, create FileInfo
 	file, err := a.FileReader(uploadPath)
 	if err != nil {
@@ -338,7 +346,7 @@ func (a *App) UploadData(rctx request.CTX, us *model.UploadSession, rd io.Reader
 	}
 
 	var storeErr error
-	if info, storeErr = a.Srv().Store().FileInfo().Save(rctx, info); storeErr != nil {
+	if info, storeErr = a.saveFileInfo(rctx, info); storeErr != nil {
 		var appErr *model.AppError
 		switch {
 		case errors.As(storeErr, &appErr):
@@ -428,7 +436,7 @@ func (a *App) CompletePresignedUpload(rctx request.CTX, us *model.UploadSession)
 
 	// Save FileInfo to DB
 	var storeErr error
-	if info, storeErr = a.Srv().Store().FileInfo().Save(rctx, info); storeErr != nil {
+	if info, storeErr = a.saveFileInfo(rctx, info); storeErr != nil {
 		var appError *model.AppError
 		switch {
 		case errors.As(storeErr, &appError):
@@ -21,6 +21,7 @@ import (
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/v8/channels/utils/fileutils"
+	"github.com/mattermost/mattermost/server/v8/platform/services/filededup"
 	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
 	xfont "golang.org/x/image/font"
 	"golang.org/x/image/math/fixed"
@@ -61,7 +62,7 @@ func (us *UserService) GetProfileImage(user *model.User) ([]byte, bool, error) {
 func (us *UserService) FileBackend() (filestore.FileBackend, error) {
 	license := us.license()
 	insecure := us.config().ServiceSettings.EnableInsecureOutgoingConnections
-	backend, err := filestore.NewFileBackend(filestore.NewFileBackendSettingsFromConfig(&us.config().FileSettings, license != nil && *license.Features.Compliance, insecure != nil && *insecure))
+	backend, err := filededup.NewFileBackend(filestore.NewFileBackendSettingsFromConfig(&us.config().FileSettings, license != nil && *license.Features.Compliance, insecure != nil && *insecure), &us.config().FileSettings, us.fileBlobStore)
 	if err != nil {
 		return nil, err
 	}
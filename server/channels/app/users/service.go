@@ -15,10 +15,12 @@ type UserService struct {
 	store        store.UserStore
 	sessionStore store.SessionStore
 	oAuthStore   store.OAuthStore
-	metrics      einterfaces.MetricsInterface
-	cluster      einterfaces.ClusterInterface
-	config       func() *model.Config
-	license      func() *model.License
+	// fileBlobStore tracks the deduplicated files, and is nil if they are not available.
+	fileBlobStore store.FileBlobStore
+	metrics       einterfaces.MetricsInterface
+	cluster       einterfaces.ClusterInterface
+	config        func() *model.Config
+	license       func() *model.License
 }
 
 // ServiceConfig is used to initialize the UserService.
@@ -32,6 +34,8 @@ type ServiceConfig struct {
 	// Optional fields
 	Metrics einterfaces.MetricsInterface
 	Cluster einterfaces.ClusterInterface
+	// FileBlobStore is needed to read and write profile pictures when file deduplication is enabled.
+	FileBlobStore store.FileBlobStore
 }
 
 func New(c ServiceConfig) (*UserService, error) {
@@ -40,13 +44,14 @@ func New(c ServiceConfig) (*UserService, error) {
 	}
 
 	return &UserService{
-		store:        c.UserStore,
-		sessionStore: c.SessionStore,
-		oAuthStore:   c.OAuthStore,
-		config:       c.ConfigFn,
-		license:      c.LicenseFn,
-		metrics:      c.Metrics,
-		cluster:      c.Cluster,
+		store:         c.UserStore,
+		sessionStore:  c.SessionStore,
+		oAuthStore:    c.OAuthStore,
+		config:        c.ConfigFn,
+		license:       c.LicenseFn,
+		metrics:       c.Metrics,
+		cluster:       c.Cluster,
+		fileBlobStore: c.FileBlobStore,
 	}, nil
 }
 
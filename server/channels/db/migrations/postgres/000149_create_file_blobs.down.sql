@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_fileblobreferences_path_pattern;
+DROP INDEX IF EXISTS idx_fileblobreferences_blobid;
+DROP TABLE IF EXISTS FileBlobReferences;
+
+DROP INDEX IF EXISTS idx_fileblobs_refcount_updateat;
+DROP TABLE IF EXISTS FileBlobs;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS FileBlobs (
+    Id VARCHAR(26) PRIMARY KEY,
+    Hash VARCHAR(64) NOT NULL,
+    Size BIGINT NOT NULL,
+    RefCount BIGINT NOT NULL,
+    CreateAt BIGINT NOT NULL,
+    UpdateAt BIGINT NOT NULL,
+    UNIQUE (Hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fileblobs_refcount_updateat ON FileBlobs(RefCount, UpdateAt);
+
+CREATE TABLE IF NOT EXISTS FileBlobReferences (
+    Path VARCHAR(512) PRIMARY KEY,
+    BlobId VARCHAR(26) NOT NULL,
+    CreateAt BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_fileblobreferences_blobid ON FileBlobReferences(BlobId);
+CREATE INDEX IF NOT EXISTS idx_fileblobreferences_path_pattern ON FileBlobReferences(Path varchar_pattern_ops);
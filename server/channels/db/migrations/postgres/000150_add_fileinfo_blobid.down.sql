@@ -0,0 +1 @@
+ALTER TABLE FileInfo DROP COLUMN IF EXISTS BlobId;
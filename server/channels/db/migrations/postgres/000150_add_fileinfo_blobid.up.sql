@@ -0,0 +1 @@
+ALTER TABLE FileInfo ADD COLUMN IF NOT EXISTS BlobId VARCHAR(26) DEFAULT '';
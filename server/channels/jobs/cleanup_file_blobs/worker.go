@@ -0,0 +1,85 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package cleanup_file_blobs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/platform/services/filededup"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+const (
+	jobName = "CleanupFileBlobs"
+
+	// gracePeriod is how long a blob no longer referenced is kept, so that a file being written
+	// with the same contents can still reference it.
+	gracePeriod = 1 * time.Hour
+
+	batchSize = 1000
+)
+
+// MakeWorker returns the worker deleting the blobs the last reference of which was removed.
+func MakeWorker(jobServer *jobs.JobServer, fileBackend func() filestore.FileBackend) *jobs.SimpleWorker {
+	isEnabled := func(cfg *model.Config) bool {
+		return *cfg.FileSettings.EnableDeduplication
+	}
+	execute := func(logger mlog.LoggerIFace, job *model.Job) error {
+		defer jobServer.HandleJobPanic(logger, job)
+
+		backend, ok := filestore.UnwrapFileBackend[*filededup.DedupFileBackend](fileBackend())
+		if !ok {
+			return errors.New("file deduplication is not enabled")
+		}
+		return cleanupFileBlobs(logger, jobServer.Store.FileBlob(), backend, time.Now().Add(-gracePeriod))
+	}
+	return jobs.NewSimpleWorker(jobName, jobServer, execute, isEnabled)
+}
+
+func cleanupFileBlobs(logger mlog.LoggerIFace, blobStore store.FileBlobStore, backend *filededup.DedupFileBackend, releasedBefore time.Time) error {
+	maxUpdateAt := model.GetMillisForTime(releasedBefore)
+
+	deleted := 0
+	for {
+		blobs, err := blobStore.GetUnreferenced(maxUpdateAt, batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, blob := range blobs {
+			// The blob row is deleted first: a file written with the same contents afterwards
+			// creates a new blob, stored at another path.
+			ok, err := blobStore.DeleteUnreferenced(blob.Id, maxUpdateAt)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+
+			if err := backend.Unwrap().RemoveFile(filededup.BlobPath(blob.Hash, blob.Id)); err != nil {
+				logger.Warn("Failed to remove file blob", mlog.String("blob_id", blob.Id), mlog.Err(err))
+				continue
+			}
+			deleted++
+		}
+
+		if len(blobs) < batchSize {
+			break
+		}
+	}
+
+	removedTempFiles, err := backend.RemoveStaleTempFiles(releasedBefore)
+	if err != nil {
+		logger.Warn("Failed to remove stale temporary files", mlog.Err(err))
+	}
+
+	logger.Info("File blob cleanup finished", mlog.Int("deleted", deleted), mlog.Int("removed_temp_files", removedTempFiles))
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package cleanup_file_blobs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest/mocks"
+	"github.com/mattermost/mattermost/server/v8/platform/services/filededup"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+func TestCleanupFileBlobs(t *testing.T) {
+	local, err := filestore.NewFileBackend(filestore.FileBackendSettings{
+		DriverName: model.ImageDriverLocal,
+		Directory:  t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	unreferenced := &model.FileBlob{Id: model.NewId(), Hash: strings.Repeat("a", 64)}
+	referencedAgain := &model.FileBlob{Id: model.NewId(), Hash: strings.Repeat("b", 64)}
+	tempPath := filededup.BlobsDirectory + "/tmp/" + model.NewId()
+	for _, path := range []string{
+		filededup.BlobPath(unreferenced.Hash, unreferenced.Id),
+		filededup.BlobPath(referencedAgain.Hash, referencedAgain.Id),
+		tempPath,
+	} {
+		_, err = local.WriteFile(strings.NewReader("contents"), path)
+		require.NoError(t, err)
+	}
+
+	blobStore := &mocks.FileBlobStore{}
+	defer blobStore.AssertExpectations(t)
+	blobStore.On("GetUnreferenced", mock.AnythingOfType("int64"), batchSize).Return([]*model.FileBlob{unreferenced, referencedAgain}, nil).Once()
+	blobStore.On("DeleteUnreferenced", unreferenced.Id, mock.AnythingOfType("int64")).Return(true, nil).Once()
+	blobStore.On("DeleteUnreferenced", referencedAgain.Id, mock.AnythingOfType("int64")).Return(false, nil).Once()
+	staleReference := &model.FileBlobReference{Path: tempPath, BlobId: referencedAgain.Id, CreateAt: 1}
+	writingReference := &model.FileBlobReference{Path: filededup.BlobsDirectory + "/tmp/" + model.NewId(), BlobId: referencedAgain.Id, CreateAt: model.GetMillis() + time.Hour.Milliseconds()}
+	blobStore.On("GetReferencesWithPrefix", filededup.BlobsDirectory+"/tmp/").Return([]*model.FileBlobReference{staleReference, writingReference}, nil).Once()
+	blobStore.On("RemoveReference", staleReference.Path).Return(nil).Once()
+
+	backend := filededup.NewDedupFileBackend(local, blobStore)
+	require.NoError(t, cleanupFileBlobs(mlog.CreateConsoleTestLogger(t), blobStore, backend, time.Now().Add(time.Minute)))
+
+	for path, expected := range map[string]bool{
+		filededup.BlobPath(unreferenced.Hash, unreferenced.Id):       false,
+		filededup.BlobPath(referencedAgain.Hash, referencedAgain.Id): true,
+		tempPath: false,
+	} {
+		exists, err := local.FileExists(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, exists, path)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package file_dedup
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/platform/services/filededup"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+const (
+	jobName = "FileDedup"
+
+	// batchSize is how many file infos are deduplicated between two updates of the job.
+	batchSize = 100
+
+	JobDataLastCreateAt = "last_create_at"
+	JobDataLastFileId   = "last_file_id"
+	JobDataProcessed    = "processed"
+	JobDataDeduplicated = "deduplicated"
+	JobDataFailed       = "failed"
+)
+
+// MakeWorker returns the worker moving the files uploaded before deduplication was enabled to
+// their blobs, so that identical files share their storage.
+func MakeWorker(jobServer *jobs.JobServer, fileBackend func() filestore.FileBackend) *jobs.SimpleWorker {
+	isEnabled := func(cfg *model.Config) bool {
+		return *cfg.FileSettings.EnableDeduplication
+	}
+	execute := func(logger mlog.LoggerIFace, job *model.Job) error {
+		defer jobServer.HandleJobPanic(logger, job)
+
+		backend, ok := filestore.UnwrapFileBackend[*filededup.DedupFileBackend](fileBackend())
+		if !ok {
+			return errors.New("file deduplication is not enabled")
+		}
+		return dedupFiles(logger, jobServer, backend, job)
+	}
+	return jobs.NewSimpleWorker(jobName, jobServer, execute, isEnabled)
+}
+
+func dedupFiles(logger mlog.LoggerIFace, jobServer *jobs.JobServer, backend *filededup.DedupFileBackend, job *model.Job) error {
+	if job.Data == nil {
+		job.Data = model.StringMap{}
+	}
+	lastCreateAt, _ := strconv.ParseInt(job.Data[JobDataLastCreateAt], 10, 64)
+	lastFileId := job.Data[JobDataLastFileId]
+	processed, _ := strconv.Atoi(job.Data[JobDataProcessed])
+	deduplicated, _ := strconv.Atoi(job.Data[JobDataDeduplicated])
+	failed, _ := strconv.Atoi(job.Data[JobDataFailed])
+
+	for {
+		// Files are handled in order, so that a job resumed after a restart skips those already done.
+		files, err := jobServer.Store.FileInfo().GetFilesBatchForIndexing(lastCreateAt, lastFileId, true, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		for _, file := range files {
+			for _, path := range []string{file.Path, file.ThumbnailPath, file.PreviewPath} {
+				if path == "" {
+					continue
+				}
+				changed, err := backend.Dedup(path)
+				if err != nil {
+					logger.Warn("Failed to deduplicate file", mlog.String("file_info_id", file.Id), mlog.String("path", path), mlog.Err(err))
+					failed++
+				} else if changed {
+					deduplicated++
+				}
+			}
+			setBlobId(logger, jobServer, backend, file)
+			processed++
+		}
+
+		last := files[len(files)-1]
+		lastCreateAt, lastFileId = last.CreateAt, last.Id
+		job.Data[JobDataLastCreateAt] = strconv.FormatInt(lastCreateAt, 10)
+		job.Data[JobDataLastFileId] = lastFileId
+		job.Data[JobDataProcessed] = strconv.Itoa(processed)
+		job.Data[JobDataDeduplicated] = strconv.Itoa(deduplicated)
+		job.Data[JobDataFailed] = strconv.Itoa(failed)
+		if appErr := jobServer.UpdateInProgressJobData(job); appErr != nil {
+			logger.Error("Worker: Failed to update job data", mlog.Err(appErr))
+		}
+	}
+
+	logger.Info("File deduplication finished", mlog.Int("processed", processed), mlog.Int("deduplicated", deduplicated), mlog.Int("failed", failed))
+	return nil
+}
+
+// setBlobId records on the file info the blob its file is stored in, if it changed.
+func setBlobId(logger mlog.LoggerIFace, jobServer *jobs.JobServer, backend *filededup.DedupFileBackend, file *model.FileForIndexing) {
+	blobId, err := backend.BlobId(file.Path)
+	if err != nil {
+		logger.Warn("Failed to find the blob of file", mlog.String("file_info_id", file.Id), mlog.Err(err))
+		return
+	}
+	if blobId == file.BlobId {
+		return
+	}
+	if err := jobServer.Store.FileInfo().SetBlobId(request.EmptyContext(logger), file.Id, blobId); err != nil {
+		logger.Warn("Failed to record the blob of file", mlog.String("file_info_id", file.Id), mlog.Err(err))
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package file_dedup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest"
+	"github.com/mattermost/mattermost/server/v8/channels/utils/testutils"
+	"github.com/mattermost/mattermost/server/v8/platform/services/filededup"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+func TestDedupFiles(t *testing.T) {
+	local, err := filestore.NewFileBackend(filestore.FileBackendSettings{
+		DriverName: model.ImageDriverLocal,
+		Directory:  t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	contents := "uploaded before deduplication"
+	_, err = local.WriteFile(strings.NewReader(contents), "data/a.pdf")
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte(contents))
+	blob := &model.FileBlob{Id: model.NewId(), Hash: hex.EncodeToString(sum[:]), Size: int64(len(contents)), RefCount: 1}
+
+	mockStore := &storetest.Store{}
+	defer mockStore.AssertExpectations(t)
+	mockStore.JobStore.On("UpdateOptimistically", mock.AnythingOfType("*model.Job"), model.JobStatusInProgress).Return(true, nil)
+	file := &model.FileForIndexing{FileInfo: model.FileInfo{Id: model.NewId(), CreateAt: 10, Path: "data/a.pdf", ThumbnailPath: "data/a_thumb.png"}}
+	mockStore.FileInfoStore.On("GetFilesBatchForIndexing", int64(0), "", true, batchSize).Return([]*model.FileForIndexing{file}, nil).Once()
+	mockStore.FileInfoStore.On("GetFilesBatchForIndexing", int64(10), file.Id, true, batchSize).Return([]*model.FileForIndexing{}, nil).Once()
+	reference := &model.FileBlobReference{Path: "data/a.pdf", BlobId: blob.Id, Hash: blob.Hash, Size: blob.Size}
+	mockStore.FileBlobStore.On("GetReference", "data/a.pdf").Return(nil, store.NewErrNotFound("FileBlobReference", "")).Once()
+	mockStore.FileBlobStore.On("GetReference", "data/a.pdf").Return(reference, nil).Once()
+	mockStore.FileBlobStore.On("GetReference", mock.AnythingOfType("string")).Return(nil, store.NewErrNotFound("FileBlobReference", ""))
+	tempPath := mock.MatchedBy(func(path string) bool { return strings.HasPrefix(path, filededup.BlobsDirectory+"/tmp/") })
+	mockStore.FileBlobStore.On("AddReference", tempPath, blob.Hash, blob.Size).Return(blob, nil).Once()
+	mockStore.FileBlobStore.On("MoveReference", tempPath, "data/a.pdf").Return(nil).Once()
+	mockStore.FileInfoStore.On("SetBlobId", mock.Anything, file.Id, blob.Id).Return(nil).Once()
+
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	jobServer := jobs.NewJobServer(&testutils.StaticConfigService{Cfg: cfg}, mockStore, nil, mlog.CreateConsoleTestLogger(t))
+
+	backend := filededup.NewDedupFileBackend(local, &mockStore.FileBlobStore)
+	job := &model.Job{Id: model.NewId(), Type: model.JobTypeFileDedup}
+	require.NoError(t, dedupFiles(mlog.CreateConsoleTestLogger(t), jobServer, backend, job))
+
+	assert.Equal(t, "1", job.Data[JobDataProcessed])
+	assert.Equal(t, "1", job.Data[JobDataDeduplicated])
+	assert.Equal(t, "1", job.Data[JobDataFailed], "the missing thumbnail cannot be deduplicated")
+	assert.Equal(t, "10", job.Data[JobDataLastCreateAt])
+	assert.Equal(t, file.Id, job.Data[JobDataLastFileId])
+
+	exists, err := local.FileExists("data/a.pdf")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	stored, err := local.ReadFile(filededup.BlobPath(blob.Hash, blob.Id))
+	require.NoError(t, err)
+	assert.Equal(t, contents, string(stored))
+}
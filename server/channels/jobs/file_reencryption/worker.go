@@ -0,0 +1,101 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package file_reencryption
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+const (
+	jobName = "FileReencryption"
+
+	// progressBatchSize is how many files are re-encrypted between two updates of the job.
+	progressBatchSize = 100
+
+	JobDataLastPath    = "last_path"
+	JobDataProcessed   = "processed"
+	JobDataReencrypted = "reencrypted"
+	JobDataFailed      = "failed"
+)
+
+// MakeWorker returns the worker encrypting every file of the file store with the current master
+// key. It is run after rotating the key, or after enabling encryption to encrypt existing files.
+func MakeWorker(jobServer *jobs.JobServer, fileBackend func() filestore.FileBackend) *jobs.SimpleWorker {
+	isEnabled := func(cfg *model.Config) bool {
+		return *cfg.FileSettings.EnableEncryption
+	}
+	execute := func(logger mlog.LoggerIFace, job *model.Job) error {
+		defer jobServer.HandleJobPanic(logger, job)
+
+		backend, ok := filestore.UnwrapFileBackend[*filestore.EncryptedFileBackend](fileBackend())
+		if !ok {
+			return errors.New("file encryption is not enabled")
+		}
+		return reencryptFiles(logger, jobServer, backend, job)
+	}
+	return jobs.NewSimpleWorker(jobName, jobServer, execute, isEnabled)
+}
+
+func reencryptFiles(logger mlog.LoggerIFace, jobServer *jobs.JobServer, backend *filestore.EncryptedFileBackend, job *model.Job) error {
+	paths, err := backend.ListDirectoryRecursively("")
+	if err != nil {
+		return err
+	}
+	// Files are handled in order, so that a job resumed after a restart skips those already done.
+	slices.Sort(paths)
+
+	if job.Data == nil {
+		job.Data = model.StringMap{}
+	}
+	lastPath := job.Data[JobDataLastPath]
+	processed, _ := strconv.Atoi(job.Data[JobDataProcessed])
+	reencrypted, _ := strconv.Atoi(job.Data[JobDataReencrypted])
+	failed, _ := strconv.Atoi(job.Data[JobDataFailed])
+
+	setJobData := func() {
+		job.Data[JobDataLastPath] = lastPath
+		job.Data[JobDataProcessed] = strconv.Itoa(processed)
+		job.Data[JobDataReencrypted] = strconv.Itoa(reencrypted)
+		job.Data[JobDataFailed] = strconv.Itoa(failed)
+	}
+
+	for i, path := range paths {
+		if path <= lastPath || strings.HasSuffix(path, filestore.EncryptedTempFileSuffix) {
+			continue
+		}
+
+		changed, err := backend.Rekey(path)
+		if err != nil {
+			logger.Warn("Failed to re-encrypt file", mlog.String("path", path), mlog.Err(err))
+			failed++
+		} else if changed {
+			reencrypted++
+		}
+		processed++
+		lastPath = path
+
+		if processed%progressBatchSize == 0 {
+			setJobData()
+			if appErr := jobServer.SetJobProgress(job, int64(i*100/len(paths))); appErr != nil {
+				logger.Warn("Failed to update the progress of the job", mlog.Err(appErr))
+			}
+		}
+	}
+
+	setJobData()
+	if appErr := jobServer.UpdateInProgressJobData(job); appErr != nil {
+		logger.Error("Worker: Failed to update job data", mlog.Err(appErr))
+	}
+
+	logger.Info("File re-encryption finished", mlog.Int("processed", processed), mlog.Int("reencrypted", reencrypted), mlog.Int("failed", failed))
+	return nil
+}
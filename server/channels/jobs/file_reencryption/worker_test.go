@@ -0,0 +1,88 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package file_reencryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest"
+	"github.com/mattermost/mattermost/server/v8/channels/utils/testutils"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+func newEncryptionKey(t *testing.T) []byte {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func TestReencryptFiles(t *testing.T) {
+	local, err := filestore.NewFileBackend(filestore.FileBackendSettings{
+		DriverName: model.ImageDriverLocal,
+		Directory:  t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	oldKey, newKey := newEncryptionKey(t), newEncryptionKey(t)
+	oldBackend, err := filestore.NewEncryptedFileBackend(local, oldKey)
+	require.NoError(t, err)
+	_, err = oldBackend.WriteFile(bytes.NewReader([]byte("old key")), "data/a.txt")
+	require.NoError(t, err)
+	_, err = local.WriteFile(bytes.NewReader([]byte("not encrypted")), "data/b.txt")
+	require.NoError(t, err)
+
+	backend, err := filestore.NewEncryptedFileBackend(local, newKey, oldKey)
+	require.NoError(t, err)
+	_, err = backend.WriteFile(bytes.NewReader([]byte("new key")), "data/c.txt")
+	require.NoError(t, err)
+	// Files encrypted with a key that is no longer configured cannot be re-encrypted.
+	lostBackend, err := filestore.NewEncryptedFileBackend(local, newEncryptionKey(t))
+	require.NoError(t, err)
+	_, err = lostBackend.WriteFile(bytes.NewReader([]byte("lost key")), "data/d.txt")
+	require.NoError(t, err)
+
+	mockStore := &storetest.Store{}
+	defer mockStore.AssertExpectations(t)
+	mockStore.JobStore.On("UpdateOptimistically", mock.AnythingOfType("*model.Job"), model.JobStatusInProgress).Return(true, nil)
+
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	jobServer := jobs.NewJobServer(&testutils.StaticConfigService{Cfg: cfg}, mockStore, nil, mlog.CreateConsoleTestLogger(t))
+
+	job := &model.Job{Id: model.NewId(), Type: model.JobTypeFileReencryption}
+	require.NoError(t, reencryptFiles(mlog.CreateConsoleTestLogger(t), jobServer, backend, job))
+
+	assert.Equal(t, "4", job.Data[JobDataProcessed])
+	assert.Equal(t, "2", job.Data[JobDataReencrypted])
+	assert.Equal(t, "1", job.Data[JobDataFailed])
+	assert.Equal(t, "data/d.txt", job.Data[JobDataLastPath])
+
+	rotated, err := filestore.NewEncryptedFileBackend(local, newKey)
+	require.NoError(t, err)
+	for path, contents := range map[string]string{"data/a.txt": "old key", "data/b.txt": "not encrypted", "data/c.txt": "new key"} {
+		read, err := rotated.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, contents, string(read))
+	}
+
+	t.Run("resumes after the last path", func(t *testing.T) {
+		job := &model.Job{Id: model.NewId(), Type: model.JobTypeFileReencryption, Data: model.StringMap{
+			JobDataLastPath:  "data/c.txt",
+			JobDataProcessed: "3",
+		}}
+		require.NoError(t, reencryptFiles(mlog.CreateConsoleTestLogger(t), jobServer, backend, job))
+		assert.Equal(t, "4", job.Data[JobDataProcessed])
+		assert.Equal(t, "1", job.Data[JobDataFailed])
+	})
+}
@@ -36,6 +36,7 @@ type RetryLayer struct {
 	DesktopTokensStore              store.DesktopTokensStore
 	DraftStore                      store.DraftStore
 	EmojiStore                      store.EmojiStore
+	FileBlobStore                   store.FileBlobStore
 	FileInfoStore                   store.FileInfoStore
 	GroupStore                      store.GroupStore
 	JobStore                        store.JobStore
@@ -141,6 +142,10 @@ func (s *RetryLayer) Emoji() store.EmojiStore {
 	return s.EmojiStore
 }
 
+func (s *RetryLayer) FileBlob() store.FileBlobStore {
+	return s.FileBlobStore
+}
+
 func (s *RetryLayer) FileInfo() store.FileInfoStore {
 	return s.FileInfoStore
 }
@@ -377,6 +382,11 @@ type RetryLayerEmojiStore struct {
 	Root *RetryLayer
 }
 
+type RetryLayerFileBlobStore struct {
+	store.FileBlobStore
+	Root *RetryLayer
+}
+
 type RetryLayerFileInfoStore struct {
 	store.FileInfoStore
 	Root *RetryLayer
@@ -4774,6 +4784,195 @@ func (s *RetryLayerEmojiStore) Search(name string, prefixOnly bool, limit int) (
 
 }
 
+func (s *RetryLayerFileBlobStore) AddReference(path string, hash string, size int64) (*model.FileBlob, error) {
+
+	tries := 0
+	for {
+		result, err := s.FileBlobStore.AddReference(path, hash, size)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerFileBlobStore) DeleteUnreferenced(id string, maxUpdateAt int64) (bool, error) {
+
+	tries := 0
+	for {
+		result, err := s.FileBlobStore.DeleteUnreferenced(id, maxUpdateAt)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerFileBlobStore) GetReference(path string) (*model.FileBlobReference, error) {
+
+	tries := 0
+	for {
+		result, err := s.FileBlobStore.GetReference(path)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerFileBlobStore) GetReferencesWithPrefix(prefix string) ([]*model.FileBlobReference, error) {
+
+	tries := 0
+	for {
+		result, err := s.FileBlobStore.GetReferencesWithPrefix(prefix)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerFileBlobStore) GetUnreferenced(maxUpdateAt int64, limit int) ([]*model.FileBlob, error) {
+
+	tries := 0
+	for {
+		result, err := s.FileBlobStore.GetUnreferenced(maxUpdateAt, limit)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerFileBlobStore) HasReferences() (bool, error) {
+
+	tries := 0
+	for {
+		result, err := s.FileBlobStore.HasReferences()
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerFileBlobStore) MoveReference(oldPath string, newPath string) error {
+
+	tries := 0
+	for {
+		err := s.FileBlobStore.MoveReference(oldPath, newPath)
+		if err == nil {
+			return nil
+		}
+		if !isRepeatableError(err) {
+			return err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerFileBlobStore) RemoveReference(path string) error {
+
+	tries := 0
+	for {
+		err := s.FileBlobStore.RemoveReference(path)
+		if err == nil {
+			return nil
+		}
+		if !isRepeatableError(err) {
+			return err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerFileBlobStore) RemoveReferencesWithPrefix(prefix string) (int64, error) {
+
+	tries := 0
+	for {
+		result, err := s.FileBlobStore.RemoveReferencesWithPrefix(prefix)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
 func (s *RetryLayerFileInfoStore) AttachToPost(rctx request.CTX, fileID string, postID string, channelID string, creatorID string) error {
 
 	tries := 0
@@ -5248,6 +5447,27 @@ func (s *RetryLayerFileInfoStore) Search(rctx request.CTX, paramsList []*model.S
 
 }
 
+func (s *RetryLayerFileInfoStore) SetBlobId(rctx request.CTX, fileID string, blobID string) error {
+
+	tries := 0
+	for {
+		err := s.FileInfoStore.SetBlobId(rctx, fileID, blobID)
+		if err == nil {
+			return nil
+		}
+		if !isRepeatableError(err) {
+			return err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
 func (s *RetryLayerFileInfoStore) SetContent(rctx request.CTX, fileID string, content string) error {
 
 	tries := 0
@@ -7858,6 +8078,27 @@ func (s *RetryLayerPostStore) ClearCaches() {
 
 }
 
+func (s *RetryLayerPostStore) CountForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs) (int64, error) {
+
+	tries := 0
+	for {
+		result, err := s.PostStore.CountForRetentionPolicies(retentionPolicyBatchConfigs)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
 func (s *RetryLayerPostStore) Delete(rctx request.CTX, postID string, timestamp int64, deleteByID string) error {
 
 	tries := 0
@@ -17574,6 +17815,7 @@ func New(childStore store.Store) *RetryLayer {
 	newStore.DesktopTokensStore = &RetryLayerDesktopTokensStore{DesktopTokensStore: childStore.DesktopTokens(), Root: &newStore}
 	newStore.DraftStore = &RetryLayerDraftStore{DraftStore: childStore.Draft(), Root: &newStore}
 	newStore.EmojiStore = &RetryLayerEmojiStore{EmojiStore: childStore.Emoji(), Root: &newStore}
+	newStore.FileBlobStore = &RetryLayerFileBlobStore{FileBlobStore: childStore.FileBlob(), Root: &newStore}
 	newStore.FileInfoStore = &RetryLayerFileInfoStore{FileInfoStore: childStore.FileInfo(), Root: &newStore}
 	newStore.GroupStore = &RetryLayerGroupStore{GroupStore: childStore.Group(), Root: &newStore}
 	newStore.JobStore = &RetryLayerJobStore{JobStore: childStore.Job(), Root: &newStore}
@@ -0,0 +1,294 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sqlstore
+
+import (
+	"database/sql"
+
+	sq "github.com/mattermost/squirrel"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+type SqlFileBlobStore struct {
+	*SqlStore
+
+	blobQuery      sq.SelectBuilder
+	referenceQuery sq.SelectBuilder
+}
+
+func newSqlFileBlobStore(sqlStore *SqlStore) store.FileBlobStore {
+	s := &SqlFileBlobStore{
+		SqlStore: sqlStore,
+	}
+
+	s.blobQuery = s.getQueryBuilder().
+		Select("Id", "Hash", "Size", "RefCount", "CreateAt", "UpdateAt").
+		From("FileBlobs")
+
+	s.referenceQuery = s.getQueryBuilder().
+		Select("r.Path", "r.BlobId", "b.Hash", "b.Size", "r.CreateAt").
+		From("FileBlobReferences r").
+		Join("FileBlobs b ON b.Id = r.BlobId")
+
+	return s
+}
+
+func (s *SqlFileBlobStore) AddReference(path, hash string, size int64) (_ *model.FileBlob, err error) {
+	tx, err := s.GetMaster().Beginx()
+	if err != nil {
+		return nil, errors.Wrap(err, "begin_transaction")
+	}
+	defer finalizeTransactionX(tx, &err)
+
+	now := model.GetMillis()
+	query := s.getQueryBuilder().
+		Insert("FileBlobs").
+		Columns("Id", "Hash", "Size", "RefCount", "CreateAt", "UpdateAt").
+		Values(model.NewId(), hash, size, 1, now, now).
+		SuffixExpr(sq.Expr("ON CONFLICT (Hash) DO UPDATE SET RefCount = FileBlobs.RefCount + 1, UpdateAt = ? RETURNING Id, Hash, Size, RefCount, CreateAt, UpdateAt", now))
+
+	var blob model.FileBlob
+	if err = tx.GetBuilder(&blob, query); err != nil {
+		return nil, errors.Wrapf(err, "failed to save FileBlob with hash=%s", hash)
+	}
+
+	previousBlobId, err := s.removeReference(tx, path, now)
+	if err != nil {
+		return nil, err
+	}
+	if previousBlobId == blob.Id {
+		// The path already referenced this blob, so it was counted twice.
+		blob.RefCount--
+	}
+	if err = s.insertReference(tx, path, blob.Id, now); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit_transaction")
+	}
+
+	return &blob, nil
+}
+
+func (s *SqlFileBlobStore) GetReference(path string) (*model.FileBlobReference, error) {
+	var reference model.FileBlobReference
+	if err := s.GetMaster().GetBuilder(&reference, s.referenceQuery.Where(sq.Eq{"r.Path": path})); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.NewErrNotFound("FileBlobReference", path)
+		}
+		return nil, errors.Wrapf(err, "failed to get FileBlobReference with path=%s", path)
+	}
+
+	return &reference, nil
+}
+
+func (s *SqlFileBlobStore) GetReferencesWithPrefix(prefix string) ([]*model.FileBlobReference, error) {
+	query := s.referenceQuery.
+		Where(sq.Expr("r.Path LIKE ? ESCAPE '\\'", sanitizeSearchTerm(prefix, "\\")+"%")).
+		OrderBy("r.Path")
+
+	references := []*model.FileBlobReference{}
+	if err := s.GetMaster().SelectBuilder(&references, query); err != nil {
+		return nil, errors.Wrapf(err, "failed to find FileBlobReferences with prefix=%s", prefix)
+	}
+
+	return references, nil
+}
+
+func (s *SqlFileBlobStore) MoveReference(oldPath, newPath string) (err error) {
+	if oldPath == newPath {
+		return nil
+	}
+
+	tx, err := s.GetMaster().Beginx()
+	if err != nil {
+		return errors.Wrap(err, "begin_transaction")
+	}
+	defer finalizeTransactionX(tx, &err)
+
+	query := s.getQueryBuilder().
+		Delete("FileBlobReferences").
+		Where(sq.Eq{"Path": oldPath}).
+		Suffix("RETURNING BlobId, CreateAt")
+
+	var reference model.FileBlobReference
+	if err = tx.GetBuilder(&reference, query); err != nil {
+		if err == sql.ErrNoRows {
+			return store.NewErrNotFound("FileBlobReference", oldPath)
+		}
+		return errors.Wrapf(err, "failed to delete FileBlobReference with path=%s", oldPath)
+	}
+
+	if _, err = s.removeReference(tx, newPath, model.GetMillis()); err != nil {
+		return err
+	}
+	if err = s.insertReference(tx, newPath, reference.BlobId, reference.CreateAt); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit_transaction")
+	}
+
+	return nil
+}
+
+func (s *SqlFileBlobStore) RemoveReference(path string) (err error) {
+	tx, err := s.GetMaster().Beginx()
+	if err != nil {
+		return errors.Wrap(err, "begin_transaction")
+	}
+	defer finalizeTransactionX(tx, &err)
+
+	blobId, err := s.removeReference(tx, path, model.GetMillis())
+	if err != nil {
+		return err
+	}
+	if blobId == "" {
+		return store.NewErrNotFound("FileBlobReference", path)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit_transaction")
+	}
+
+	return nil
+}
+
+func (s *SqlFileBlobStore) RemoveReferencesWithPrefix(prefix string) (_ int64, err error) {
+	tx, err := s.GetMaster().Beginx()
+	if err != nil {
+		return 0, errors.Wrap(err, "begin_transaction")
+	}
+	defer finalizeTransactionX(tx, &err)
+
+	query := s.getQueryBuilder().
+		Delete("FileBlobReferences").
+		Where(sq.Expr("Path LIKE ? ESCAPE '\\'", sanitizeSearchTerm(prefix, "\\")+"%")).
+		Suffix("RETURNING BlobId")
+
+	var blobIds []string
+	if err = tx.SelectBuilder(&blobIds, query); err != nil {
+		return 0, errors.Wrapf(err, "failed to delete FileBlobReferences with prefix=%s", prefix)
+	}
+
+	removed := map[string]int64{}
+	for _, blobId := range blobIds {
+		removed[blobId]++
+	}
+	now := model.GetMillis()
+	for blobId, count := range removed {
+		if err = s.releaseBlob(tx, blobId, count, now); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit_transaction")
+	}
+
+	return int64(len(blobIds)), nil
+}
+
+func (s *SqlFileBlobStore) HasReferences() (bool, error) {
+	var exists bool
+	if err := s.GetMaster().Get(&exists, "SELECT EXISTS (SELECT 1 FROM FileBlobReferences)"); err != nil {
+		return false, errors.Wrap(err, "failed to check for FileBlobReferences")
+	}
+
+	return exists, nil
+}
+
+func (s *SqlFileBlobStore) GetUnreferenced(maxUpdateAt int64, limit int) ([]*model.FileBlob, error) {
+	query := s.blobQuery.
+		Where(sq.And{
+			sq.LtOrEq{"RefCount": 0},
+			sq.Lt{"UpdateAt": maxUpdateAt},
+		}).
+		OrderBy("UpdateAt").
+		Limit(uint64(limit))
+
+	blobs := []*model.FileBlob{}
+	if err := s.GetMaster().SelectBuilder(&blobs, query); err != nil {
+		return nil, errors.Wrap(err, "failed to find unreferenced FileBlobs")
+	}
+
+	return blobs, nil
+}
+
+func (s *SqlFileBlobStore) DeleteUnreferenced(id string, maxUpdateAt int64) (bool, error) {
+	query := s.getQueryBuilder().
+		Delete("FileBlobs").
+		Where(sq.And{
+			sq.Eq{"Id": id},
+			sq.LtOrEq{"RefCount": 0},
+			sq.Lt{"UpdateAt": maxUpdateAt},
+		})
+
+	result, err := s.GetMaster().ExecBuilder(query)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to delete FileBlob with id=%s", id)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get rows affected while deleting FileBlob with id=%s", id)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (s *SqlFileBlobStore) insertReference(tx *sqlxTxWrapper, path, blobId string, createAt int64) error {
+	query := s.getQueryBuilder().
+		Insert("FileBlobReferences").
+		Columns("Path", "BlobId", "CreateAt").
+		Values(path, blobId, createAt)
+
+	if _, err := tx.ExecBuilder(query); err != nil {
+		return errors.Wrapf(err, "failed to save FileBlobReference with path=%s", path)
+	}
+
+	return nil
+}
+
+// removeReference deletes the reference of path, if any, releases the blob it pointed at and
+// returns the id of that blob.
+func (s *SqlFileBlobStore) removeReference(tx *sqlxTxWrapper, path string, now int64) (string, error) {
+	query := s.getQueryBuilder().
+		Delete("FileBlobReferences").
+		Where(sq.Eq{"Path": path}).
+		Suffix("RETURNING BlobId")
+
+	var blobId string
+	if err := tx.GetBuilder(&blobId, query); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "failed to delete FileBlobReference with path=%s", path)
+	}
+
+	if err := s.releaseBlob(tx, blobId, 1, now); err != nil {
+		return "", err
+	}
+
+	return blobId, nil
+}
+
+func (s *SqlFileBlobStore) releaseBlob(tx *sqlxTxWrapper, blobId string, count, now int64) error {
+	query := s.getQueryBuilder().
+		Update("FileBlobs").
+		Set("RefCount", sq.Expr("RefCount - ?", count)).
+		Set("UpdateAt", now).
+		Where(sq.Eq{"Id": blobId})
+
+	if _, err := tx.ExecBuilder(query); err != nil {
+		return errors.Wrapf(err, "failed to update FileBlob with id=%s", blobId)
+	}
+
+	return nil
+}
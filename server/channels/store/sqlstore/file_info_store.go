@@ -31,6 +31,7 @@ type fileInfoWithChannelID struct {
 	Path            string
 	ThumbnailPath   string
 	PreviewPath     string
+	BlobId          string
 	Name            string
 	Extension       string
 	Size            int64
@@ -56,6 +57,7 @@ func (fi fileInfoWithChannelID) ToModel() *model.FileInfo {
 		Path:            fi.Path,
 		ThumbnailPath:   fi.ThumbnailPath,
 		PreviewPath:     fi.PreviewPath,
+		BlobId:          fi.BlobId,
 		Name:            fi.Name,
 		Extension:       fi.Extension,
 		Size:            fi.Size,
@@ -95,6 +97,7 @@ func newSqlFileInfoStore(sqlStore *SqlStore, metrics einterfaces.MetricsInterfac
 		"FileInfo.Path",
 		"FileInfo.ThumbnailPath",
 		"FileInfo.PreviewPath",
+		"COALESCE(FileInfo.BlobId, '') AS BlobId",
 		"FileInfo.Name",
 		"FileInfo.Extension",
 		"FileInfo.Size",
@@ -119,10 +122,10 @@ func (fs SqlFileInfoStore) Save(rctx request.CTX, info *model.FileInfo) (*model.
 
 	query := `
 		INSERT INTO FileInfo
-		(Id, CreatorId, PostId, ChannelId, CreateAt, UpdateAt, DeleteAt, Path, ThumbnailPath, PreviewPath,
+		(Id, CreatorId, PostId, ChannelId, CreateAt, UpdateAt, DeleteAt, Path, ThumbnailPath, PreviewPath, BlobId,
 			Name, Extension, Size, MimeType, Width, Height, HasPreviewImage, MiniPreview, Content, RemoteId)
 		VALUES
-		(:Id, :CreatorId, :PostId, :ChannelId, :CreateAt, :UpdateAt, :DeleteAt, :Path, :ThumbnailPath, :PreviewPath,
+		(:Id, :CreatorId, :PostId, :ChannelId, :CreateAt, :UpdateAt, :DeleteAt, :Path, :ThumbnailPath, :PreviewPath, :BlobId,
 			:Name, :Extension, :Size, :MimeType, :Width, :Height, :HasPreviewImage, :MiniPreview, :Content, :RemoteId)
 	`
 
@@ -445,6 +448,19 @@ func (fs SqlFileInfoStore) SetContent(rctx request.CTX, fileId, content string)
 	return nil
 }
 
+func (fs SqlFileInfoStore) SetBlobId(rctx request.CTX, fileId, blobId string) error {
+	query := fs.getQueryBuilder().
+		Update("FileInfo").
+		Set("BlobId", blobId).
+		Where(sq.Eq{"Id": fileId})
+
+	if _, err := fs.GetMaster().ExecBuilder(query); err != nil {
+		return errors.Wrapf(err, "failed to update FileInfo blob with id=%s", fileId)
+	}
+
+	return nil
+}
+
 func (fs SqlFileInfoStore) DeleteForPost(rctx request.CTX, postId string) (string, error) {
 	if _, err := fs.GetMaster().Exec(
 		`UPDATE
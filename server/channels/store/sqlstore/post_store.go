@@ -2694,6 +2694,16 @@ func (s *SqlPostStore) GetPostsBatchForIndexing(startTime int64, startPostID str
 // the global or a granular retention policy.
 // See `genericPermanentDeleteBatchForRetentionPolicies` for details.
 func (s *SqlPostStore) PermanentDeleteBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error) {
+	return genericPermanentDeleteBatchForRetentionPolicies(s.retentionPolicyDeletionInfo(retentionPolicyBatchConfigs), s.SqlStore, cursor)
+}
+
+// CountForRetentionPolicies returns the number of posts which are affected by
+// the global or a granular retention policy, without deleting them.
+func (s *SqlPostStore) CountForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs) (int64, error) {
+	return genericCountForRetentionPolicies(s.retentionPolicyDeletionInfo(retentionPolicyBatchConfigs), s.SqlStore)
+}
+
+func (s *SqlPostStore) retentionPolicyDeletionInfo(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs) RetentionPolicyBatchDeletionInfo {
 	builder := s.getQueryBuilder().
 		Select("Posts.Id").
 		From("Posts")
@@ -2708,7 +2718,7 @@ func (s *SqlPostStore) PermanentDeleteBatchForRetentionPolicies(retentionPolicyB
 		})
 	}
 
-	return genericPermanentDeleteBatchForRetentionPolicies(RetentionPolicyBatchDeletionInfo{
+	return RetentionPolicyBatchDeletionInfo{
 		BaseBuilder:         builder,
 		Table:               "Posts",
 		TimeColumn:          "CreateAt",
@@ -2718,7 +2728,7 @@ func (s *SqlPostStore) PermanentDeleteBatchForRetentionPolicies(retentionPolicyB
 		GlobalPolicyEndTime: retentionPolicyBatchConfigs.GlobalPolicyEndTime,
 		Limit:               retentionPolicyBatchConfigs.Limit,
 		StoreDeletedIds:     true,
-	}, s.SqlStore, cursor)
+	}
 }
 
 func (s *SqlPostStore) PermanentDeleteBatch(endTime int64, limit int64) (int64, error) {
@@ -428,7 +428,7 @@ func (s *SqlRetentionPolicyStore) Delete(id string) error {
 	if err != nil {
 		return errors.Wrap(err, "unable to get rows affected")
 	} else if numRowsAffected == 0 {
-		return errors.New("policy not found")
+		return store.NewErrNotFound("RetentionPolicy", id)
 	}
 
 	return nil
@@ -941,18 +941,7 @@ func genericPermanentDeleteBatchForRetentionPolicies(
 	s *SqlStore,
 	cursor model.RetentionPolicyCursor,
 ) (int64, model.RetentionPolicyCursor, error) {
-	baseBuilder := r.BaseBuilder.InnerJoin("Channels ON " + r.ChannelIDTable + ".ChannelId = Channels.Id")
-
-	scopedTimeColumn := r.Table + "." + r.TimeColumn
-	nowStr := strconv.FormatInt(r.NowMillis, 10)
-	// A record falls under the scope of a granular retention policy if:
-	// 1. The policy's post duration is >= 0
-	// 2. The record's lifespan has not exceeded the policy's post duration
-	const millisecondsInADay = 24 * 60 * 60 * 1000
-	fallsUnderGranularPolicy := sq.And{
-		sq.GtOrEq{"RetentionPolicies.PostDuration": 0},
-		sq.Expr(nowStr + " - " + scopedTimeColumn + " > RetentionPolicies.PostDuration * " + strconv.FormatInt(millisecondsInADay, 10)),
-	}
+	channelPoliciesBuilder, teamPoliciesBuilder, globalPolicyBuilder := retentionPoliciesBuilders(r)
 
 	// If the caller wants to disable the global policy from running
 	if r.GlobalPolicyEndTime <= 0 {
@@ -968,12 +957,7 @@ func genericPermanentDeleteBatchForRetentionPolicies(
 
 	// First, delete all of the records which fall under the scope of a channel-specific policy
 	if !cursor.ChannelPoliciesDone {
-		channelPoliciesBuilder := baseBuilder.
-			InnerJoin("RetentionPoliciesChannels ON " + r.ChannelIDTable + ".ChannelId = RetentionPoliciesChannels.ChannelId").
-			InnerJoin("RetentionPolicies ON RetentionPoliciesChannels.PolicyId = RetentionPolicies.Id").
-			Where(fallsUnderGranularPolicy).
-			Limit(uint64(r.Limit))
-		rowsAffected, err := genericRetentionPoliciesDeletion(channelPoliciesBuilder, r, s)
+		rowsAffected, err := genericRetentionPoliciesDeletion(channelPoliciesBuilder.Limit(uint64(r.Limit)), r, s)
 		if err != nil {
 			return 0, cursor, err
 		}
@@ -986,18 +970,7 @@ func genericPermanentDeleteBatchForRetentionPolicies(
 
 	// Next, delete all of the records which fall under the scope of a team-specific policy
 	if cursor.ChannelPoliciesDone && !cursor.TeamPoliciesDone {
-		// Channel-specific policies override team-specific policies.
-		teamPoliciesBuilder := baseBuilder.
-			LeftJoin("RetentionPoliciesChannels ON " + r.ChannelIDTable + ".ChannelId = RetentionPoliciesChannels.ChannelId").
-			InnerJoin("RetentionPoliciesTeams ON Channels.TeamId = RetentionPoliciesTeams.TeamId").
-			InnerJoin("RetentionPolicies ON RetentionPoliciesTeams.PolicyId = RetentionPolicies.Id").
-			Where(sq.And{
-				sq.Eq{"RetentionPoliciesChannels.PolicyId": nil},
-				sq.Expr("RetentionPoliciesTeams.PolicyId = RetentionPolicies.Id"),
-			}).
-			Where(fallsUnderGranularPolicy).
-			Limit(uint64(r.Limit))
-		rowsAffected, err := genericRetentionPoliciesDeletion(teamPoliciesBuilder, r, s)
+		rowsAffected, err := genericRetentionPoliciesDeletion(teamPoliciesBuilder.Limit(uint64(r.Limit)), r, s)
 		if err != nil {
 			return 0, cursor, err
 		}
@@ -1010,18 +983,7 @@ func genericPermanentDeleteBatchForRetentionPolicies(
 
 	// Finally, delete all of the records which fall under the scope of the global policy
 	if cursor.ChannelPoliciesDone && cursor.TeamPoliciesDone && !cursor.GlobalPoliciesDone {
-		// Granular policies override the global policy.
-		globalPolicyBuilder := baseBuilder.
-			LeftJoin("RetentionPoliciesChannels ON " + r.ChannelIDTable + ".ChannelId = RetentionPoliciesChannels.ChannelId").
-			LeftJoin("RetentionPoliciesTeams ON Channels.TeamId = RetentionPoliciesTeams.TeamId").
-			LeftJoin("RetentionPolicies ON RetentionPoliciesChannels.PolicyId = RetentionPolicies.Id").
-			Where(sq.And{
-				sq.Eq{"RetentionPoliciesChannels.PolicyId": nil},
-				sq.Eq{"RetentionPoliciesTeams.PolicyId": nil},
-			}).
-			Where(sq.Lt{scopedTimeColumn: r.GlobalPolicyEndTime}).
-			Limit(uint64(r.Limit))
-		rowsAffected, err := genericRetentionPoliciesDeletion(globalPolicyBuilder, r, s)
+		rowsAffected, err := genericRetentionPoliciesDeletion(globalPolicyBuilder.Limit(uint64(r.Limit)), r, s)
 		if err != nil {
 			return 0, cursor, err
 		}
@@ -1034,6 +996,78 @@ func genericPermanentDeleteBatchForRetentionPolicies(
 	return totalRowsAffected, cursor, nil
 }
 
+// retentionPoliciesBuilders returns the queries selecting the records which fall under
+// the scope of a channel-specific policy, a team-specific policy and the global policy.
+// Channel-specific policies override team-specific policies, and granular policies
+// override the global policy.
+func retentionPoliciesBuilders(r RetentionPolicyBatchDeletionInfo) (channelPoliciesBuilder, teamPoliciesBuilder, globalPolicyBuilder sq.SelectBuilder) {
+	baseBuilder := r.BaseBuilder.InnerJoin("Channels ON " + r.ChannelIDTable + ".ChannelId = Channels.Id")
+
+	scopedTimeColumn := r.Table + "." + r.TimeColumn
+	nowStr := strconv.FormatInt(r.NowMillis, 10)
+	// A record falls under the scope of a granular retention policy if:
+	// 1. The policy's post duration is >= 0
+	// 2. The record's lifespan has not exceeded the policy's post duration
+	const millisecondsInADay = 24 * 60 * 60 * 1000
+	fallsUnderGranularPolicy := sq.And{
+		sq.GtOrEq{"RetentionPolicies.PostDuration": 0},
+		sq.Expr(nowStr + " - " + scopedTimeColumn + " > RetentionPolicies.PostDuration * " + strconv.FormatInt(millisecondsInADay, 10)),
+	}
+
+	channelPoliciesBuilder = baseBuilder.
+		InnerJoin("RetentionPoliciesChannels ON " + r.ChannelIDTable + ".ChannelId = RetentionPoliciesChannels.ChannelId").
+		InnerJoin("RetentionPolicies ON RetentionPoliciesChannels.PolicyId = RetentionPolicies.Id").
+		Where(fallsUnderGranularPolicy)
+
+	teamPoliciesBuilder = baseBuilder.
+		LeftJoin("RetentionPoliciesChannels ON " + r.ChannelIDTable + ".ChannelId = RetentionPoliciesChannels.ChannelId").
+		InnerJoin("RetentionPoliciesTeams ON Channels.TeamId = RetentionPoliciesTeams.TeamId").
+		InnerJoin("RetentionPolicies ON RetentionPoliciesTeams.PolicyId = RetentionPolicies.Id").
+		Where(sq.And{
+			sq.Eq{"RetentionPoliciesChannels.PolicyId": nil},
+			sq.Expr("RetentionPoliciesTeams.PolicyId = RetentionPolicies.Id"),
+		}).
+		Where(fallsUnderGranularPolicy)
+
+	globalPolicyBuilder = baseBuilder.
+		LeftJoin("RetentionPoliciesChannels ON " + r.ChannelIDTable + ".ChannelId = RetentionPoliciesChannels.ChannelId").
+		LeftJoin("RetentionPoliciesTeams ON Channels.TeamId = RetentionPoliciesTeams.TeamId").
+		LeftJoin("RetentionPolicies ON RetentionPoliciesChannels.PolicyId = RetentionPolicies.Id").
+		Where(sq.And{
+			sq.Eq{"RetentionPoliciesChannels.PolicyId": nil},
+			sq.Eq{"RetentionPoliciesTeams.PolicyId": nil},
+		}).
+		Where(sq.Lt{scopedTimeColumn: r.GlobalPolicyEndTime})
+
+	return
+}
+
+// genericCountForRetentionPolicies returns the number of records which
+// genericPermanentDeleteBatchForRetentionPolicies would delete if it ran until
+// every policy was done. `Limit` and `StoreDeletedIds` are ignored.
+func genericCountForRetentionPolicies(r RetentionPolicyBatchDeletionInfo, s *SqlStore) (int64, error) {
+	channelPoliciesBuilder, teamPoliciesBuilder, globalPolicyBuilder := retentionPoliciesBuilders(r)
+
+	var builders []sq.SelectBuilder
+	if r.NowMillis > 0 {
+		builders = append(builders, channelPoliciesBuilder, teamPoliciesBuilder)
+	}
+	if r.GlobalPolicyEndTime > 0 {
+		builders = append(builders, globalPolicyBuilder)
+	}
+
+	var total int64
+	for _, builder := range builders {
+		var count int64
+		query := s.getQueryBuilder().Select("COUNT(*)").FromSelect(builder, "ToDelete")
+		if err := s.GetReplica().GetBuilder(&count, query); err != nil {
+			return 0, errors.Wrap(err, "failed to count "+r.Table)
+		}
+		total += count
+	}
+	return total, nil
+}
+
 // genericRetentionPoliciesDeletion actually executes the DELETE query using a sq.SelectBuilder
 // which selects the rows to delete.
 func genericRetentionPoliciesDeletion(
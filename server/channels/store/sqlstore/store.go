@@ -114,6 +114,7 @@ type SqlStoreStores struct {
 	ContentFlagging            store.ContentFlaggingStore
 	readReceipt                store.ReadReceiptStore
 	temporaryPost              store.TemporaryPostStore
+	fileBlob                   store.FileBlobStore
 }
 
 type SqlStore struct {
@@ -267,6 +268,7 @@ func New(settings model.SqlSettings, logger mlog.LoggerIFace, metrics einterface
 	store.stores.ContentFlagging = newContentFlaggingStore(store)
 	store.stores.readReceipt = newSqlReadReceiptStore(store, metrics)
 	store.stores.temporaryPost = newSqlTemporaryPostStore(store, metrics)
+	store.stores.fileBlob = newSqlFileBlobStore(store)
 
 	store.stores.preference.(*SqlPreferenceStore).deleteUnusedFeatures()
 
@@ -894,6 +896,10 @@ func (ss *SqlStore) TemporaryPost() store.TemporaryPostStore {
 	return ss.stores.temporaryPost
 }
 
+func (ss *SqlStore) FileBlob() store.FileBlobStore {
+	return ss.stores.fileBlob
+}
+
 func (ss *SqlStore) DropAllTables() {
 	ss.masterX.Exec(`DO
 		$func$
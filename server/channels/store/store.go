@@ -100,6 +100,7 @@ type Store interface {
 	ContentFlagging() ContentFlaggingStore
 	ReadReceipt() ReadReceiptStore
 	TemporaryPost() TemporaryPostStore
+	FileBlob() FileBlobStore
 }
 
 type RetentionPolicyStore interface {
@@ -405,6 +406,7 @@ type PostStore interface {
 	GetEditHistoryForPost(postID string) ([]*model.Post, error)
 	GetPostsBatchForIndexing(startTime int64, startPostID string, limit int) ([]*model.PostForIndexing, error)
 	PermanentDeleteBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error)
+	CountForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs) (int64, error)
 	PermanentDeleteBatch(endTime int64, limit int64) (int64, error)
 	GetOldest() (*model.Post, error)
 	GetMaxPostSize() int
@@ -758,6 +760,8 @@ type FileInfoStore interface {
 	PermanentDeleteBatch(rctx request.CTX, endTime int64, limit int64) (int64, error)
 	PermanentDeleteByUser(rctx request.CTX, userID string) (int64, error)
 	SetContent(rctx request.CTX, fileID, content string) error
+	// SetBlobId records the deduplicated blob the file is stored in, or that it is not.
+	SetBlobId(rctx request.CTX, fileID, blobID string) error
 	Search(rctx request.CTX, paramsList []*model.SearchParams, userID, teamID string, page, perPage int) (*model.FileInfoList, error)
 	CountAll() (int64, error)
 	GetFilesBatchForIndexing(startTime int64, startFileID string, includeDeleted bool, limit int) ([]*model.FileForIndexing, error)
@@ -1204,6 +1208,27 @@ type TemporaryPostStore interface {
 	GetExpiredPosts(rctx request.CTX) ([]string, error)
 }
 
+// FileBlobStore keeps track of the content-addressed blobs of the file store and of the paths
+// referencing them.
+type FileBlobStore interface {
+	// AddReference points path at the blob with the given hash, creating the blob if needed, and
+	// returns the blob. A previous reference of path is replaced.
+	AddReference(path, hash string, size int64) (*model.FileBlob, error)
+	GetReference(path string) (*model.FileBlobReference, error)
+	GetReferencesWithPrefix(prefix string) ([]*model.FileBlobReference, error)
+	// MoveReference points newPath at the blob of oldPath, and removes the reference of oldPath.
+	MoveReference(oldPath, newPath string) error
+	RemoveReference(path string) error
+	RemoveReferencesWithPrefix(prefix string) (int64, error)
+	// HasReferences reports whether any path references a blob.
+	HasReferences() (bool, error)
+	// GetUnreferenced returns the blobs no path references since before maxUpdateAt.
+	GetUnreferenced(maxUpdateAt int64, limit int) ([]*model.FileBlob, error)
+	// DeleteUnreferenced deletes the blob if no path references it since before maxUpdateAt, and
+	// reports whether it was deleted.
+	DeleteUnreferenced(id string, maxUpdateAt int64) (bool, error)
+}
+
 // ChannelSearchOpts contains options for searching channels.
 //
 // NotAssociatedToGroup will exclude channels that have associated, active GroupChannels records.
@@ -0,0 +1,213 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package storetest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+func TestFileBlobStore(t *testing.T, rctx request.CTX, ss store.Store, s SqlStore) {
+	t.Run("AddReference", func(t *testing.T) { testFileBlobStoreAddReference(t, rctx, ss) })
+	t.Run("MoveReference", func(t *testing.T) { testFileBlobStoreMoveReference(t, rctx, ss) })
+	t.Run("RemoveReference", func(t *testing.T) { testFileBlobStoreRemoveReference(t, rctx, ss) })
+	t.Run("RemoveReferencesWithPrefix", func(t *testing.T) { testFileBlobStoreRemoveReferencesWithPrefix(t, rctx, ss) })
+	t.Run("HasReferences", func(t *testing.T) { testFileBlobStoreHasReferences(t, rctx, ss) })
+	t.Run("DeleteUnreferenced", func(t *testing.T) { testFileBlobStoreDeleteUnreferenced(t, rctx, ss) })
+}
+
+func newFileBlobHash() string {
+	return model.NewId() + model.NewId()
+}
+
+func getFileBlob(t *testing.T, ss store.Store, hash string) *model.FileBlob {
+	t.Helper()
+
+	blobs, err := ss.FileBlob().GetUnreferenced(model.GetMillis()+1000, 1000)
+	require.NoError(t, err)
+	for _, blob := range blobs {
+		if blob.Hash == hash {
+			return blob
+		}
+	}
+	return nil
+}
+
+func testFileBlobStoreAddReference(t *testing.T, rctx request.CTX, ss store.Store) {
+	prefix := model.NewId() + "/"
+	hash := newFileBlobHash()
+
+	blob, err := ss.FileBlob().AddReference(prefix+"a", hash, 42)
+	require.NoError(t, err)
+	assert.Equal(t, hash, blob.Hash)
+	assert.Equal(t, int64(42), blob.Size)
+	assert.Equal(t, int64(1), blob.RefCount)
+
+	t.Run("another path shares the blob", func(t *testing.T) {
+		other, err := ss.FileBlob().AddReference(prefix+"b", hash, 42)
+		require.NoError(t, err)
+		assert.Equal(t, blob.Id, other.Id)
+		assert.Equal(t, int64(2), other.RefCount)
+
+		reference, err := ss.FileBlob().GetReference(prefix + "b")
+		require.NoError(t, err)
+		assert.Equal(t, blob.Id, reference.BlobId)
+		assert.Equal(t, hash, reference.Hash)
+		assert.Equal(t, int64(42), reference.Size)
+	})
+
+	t.Run("replacing a reference releases the previous blob", func(t *testing.T) {
+		otherHash := newFileBlobHash()
+		_, err := ss.FileBlob().AddReference(prefix+"b", otherHash, 7)
+		require.NoError(t, err)
+
+		reference, err := ss.FileBlob().GetReference(prefix + "b")
+		require.NoError(t, err)
+		assert.Equal(t, otherHash, reference.Hash)
+
+		again, err := ss.FileBlob().AddReference(prefix+"a", hash, 42)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), again.RefCount, "re-adding the same reference counts it once")
+	})
+
+	t.Run("missing reference", func(t *testing.T) {
+		_, err := ss.FileBlob().GetReference(prefix + "missing")
+		var nfErr *store.ErrNotFound
+		assert.ErrorAs(t, err, &nfErr)
+	})
+
+	t.Run("references with prefix", func(t *testing.T) {
+		_, err := ss.FileBlob().AddReference(prefix+"dir/c", hash, 42)
+		require.NoError(t, err)
+		_, err = ss.FileBlob().AddReference(model.NewId()+"/c", hash, 42)
+		require.NoError(t, err)
+
+		references, err := ss.FileBlob().GetReferencesWithPrefix(prefix)
+		require.NoError(t, err)
+		paths := make([]string, 0, len(references))
+		for _, reference := range references {
+			paths = append(paths, reference.Path)
+		}
+		assert.Equal(t, []string{prefix + "a", prefix + "b", prefix + "dir/c"}, paths)
+	})
+}
+
+func testFileBlobStoreMoveReference(t *testing.T, rctx request.CTX, ss store.Store) {
+	prefix := model.NewId() + "/"
+	hash, otherHash := newFileBlobHash(), newFileBlobHash()
+
+	_, err := ss.FileBlob().AddReference(prefix+"a", hash, 1)
+	require.NoError(t, err)
+	_, err = ss.FileBlob().AddReference(prefix+"b", otherHash, 1)
+	require.NoError(t, err)
+
+	require.NoError(t, ss.FileBlob().MoveReference(prefix+"a", prefix+"b"))
+
+	_, err = ss.FileBlob().GetReference(prefix + "a")
+	var nfErr *store.ErrNotFound
+	assert.ErrorAs(t, err, &nfErr)
+
+	reference, err := ss.FileBlob().GetReference(prefix + "b")
+	require.NoError(t, err)
+	assert.Equal(t, hash, reference.Hash)
+
+	assert.Nil(t, getFileBlob(t, ss, hash), "the moved blob is still referenced")
+	assert.NotNil(t, getFileBlob(t, ss, otherHash), "the overwritten blob is no longer referenced")
+
+	err = ss.FileBlob().MoveReference(prefix+"missing", prefix+"c")
+	assert.ErrorAs(t, err, &nfErr)
+}
+
+func testFileBlobStoreRemoveReference(t *testing.T, rctx request.CTX, ss store.Store) {
+	prefix := model.NewId() + "/"
+	hash := newFileBlobHash()
+
+	_, err := ss.FileBlob().AddReference(prefix+"a", hash, 1)
+	require.NoError(t, err)
+	_, err = ss.FileBlob().AddReference(prefix+"b", hash, 1)
+	require.NoError(t, err)
+
+	require.NoError(t, ss.FileBlob().RemoveReference(prefix+"a"))
+	assert.Nil(t, getFileBlob(t, ss, hash))
+
+	require.NoError(t, ss.FileBlob().RemoveReference(prefix+"b"))
+	blob := getFileBlob(t, ss, hash)
+	require.NotNil(t, blob)
+	assert.Equal(t, int64(0), blob.RefCount)
+
+	err = ss.FileBlob().RemoveReference(prefix + "b")
+	var nfErr *store.ErrNotFound
+	assert.ErrorAs(t, err, &nfErr)
+}
+
+func testFileBlobStoreRemoveReferencesWithPrefix(t *testing.T, rctx request.CTX, ss store.Store) {
+	prefix := model.NewId() + "/"
+	hash, otherHash := newFileBlobHash(), newFileBlobHash()
+
+	for _, path := range []string{"dir/a", "dir/b", "dir/sub/c"} {
+		_, err := ss.FileBlob().AddReference(prefix+path, hash, 1)
+		require.NoError(t, err)
+	}
+	for _, path := range []string{"dir_other/a", "dirXother/a"} {
+		_, err := ss.FileBlob().AddReference(prefix+path, otherHash, 1)
+		require.NoError(t, err)
+	}
+
+	removed, err := ss.FileBlob().RemoveReferencesWithPrefix(prefix + "dir/")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), removed)
+
+	blob := getFileBlob(t, ss, hash)
+	require.NotNil(t, blob)
+	assert.Equal(t, int64(0), blob.RefCount)
+
+	removed, err = ss.FileBlob().RemoveReferencesWithPrefix(prefix + "dir_")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+
+	_, err = ss.FileBlob().GetReference(prefix + "dirXother/a")
+	require.NoError(t, err, "the underscore of the prefix must not match any character")
+}
+
+func testFileBlobStoreHasReferences(t *testing.T, rctx request.CTX, ss store.Store) {
+	path := model.NewId() + "/a"
+
+	_, err := ss.FileBlob().AddReference(path, newFileBlobHash(), 1)
+	require.NoError(t, err)
+
+	hasReferences, err := ss.FileBlob().HasReferences()
+	require.NoError(t, err)
+	assert.True(t, hasReferences)
+
+	require.NoError(t, ss.FileBlob().RemoveReference(path))
+}
+
+func testFileBlobStoreDeleteUnreferenced(t *testing.T, rctx request.CTX, ss store.Store) {
+	prefix := model.NewId() + "/"
+	hash := newFileBlobHash()
+
+	blob, err := ss.FileBlob().AddReference(prefix+"a", hash, 1)
+	require.NoError(t, err)
+
+	deleted, err := ss.FileBlob().DeleteUnreferenced(blob.Id, model.GetMillis()+1000)
+	require.NoError(t, err)
+	assert.False(t, deleted, "a referenced blob is kept")
+
+	require.NoError(t, ss.FileBlob().RemoveReference(prefix+"a"))
+
+	deleted, err = ss.FileBlob().DeleteUnreferenced(blob.Id, 0)
+	require.NoError(t, err)
+	assert.False(t, deleted, "a blob released after maxUpdateAt is kept")
+
+	deleted, err = ss.FileBlob().DeleteUnreferenced(blob.Id, model.GetMillis()+1000)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Nil(t, getFileBlob(t, ss, hash))
+}
@@ -32,6 +32,7 @@ func TestFileInfoStore(t *testing.T, rctx request.CTX, ss store.Store, s SqlStor
 	t.Run("FileInfoPermanentDelete", func(t *testing.T) { testFileInfoPermanentDelete(t, rctx, ss) })
 	t.Run("FileInfoPermanentDeleteBatch", func(t *testing.T) { testFileInfoPermanentDeleteBatch(t, rctx, ss) })
 	t.Run("FileInfoPermanentDeleteByUser", func(t *testing.T) { testFileInfoPermanentDeleteByUser(t, rctx, ss) })
+	t.Run("FileInfoSetBlobId", func(t *testing.T) { testFileInfoSetBlobId(t, rctx, ss) })
 	t.Run("FileInfoUpdateMinipreview", func(t *testing.T) { testFileInfoUpdateMinipreview(t, rctx, ss) })
 	t.Run("GetFilesBatchForIndexing", func(t *testing.T) { testFileInfoStoreGetFilesBatchForIndexing(t, rctx, ss) })
 	t.Run("CountAll", func(t *testing.T) { testFileInfoStoreCountAll(t, rctx, ss) })
@@ -77,6 +78,28 @@ func testFileInfoSaveGet(t *testing.T, rctx request.CTX, ss store.Store) {
 	}()
 }
 
+func testFileInfoSetBlobId(t *testing.T, rctx request.CTX, ss store.Store) {
+	blobId := model.NewId()
+	info, err := ss.FileInfo().Save(rctx, &model.FileInfo{
+		CreatorId: model.NewId(),
+		Path:      "file.txt",
+		BlobId:    blobId,
+	})
+	require.NoError(t, err)
+	defer func() {
+		ss.FileInfo().PermanentDelete(rctx, info.Id)
+	}()
+
+	rinfo, err := ss.FileInfo().Get(info.Id)
+	require.NoError(t, err)
+	assert.Equal(t, blobId, rinfo.BlobId)
+
+	require.NoError(t, ss.FileInfo().SetBlobId(rctx, info.Id, ""))
+	rinfo, err = ss.FileInfo().GetFromMaster(info.Id)
+	require.NoError(t, err)
+	assert.Empty(t, rinfo.BlobId)
+}
+
 func testFileInfoSaveGetByPath(t *testing.T, rctx request.CTX, ss store.Store) {
 	info := &model.FileInfo{
 		CreatorId: model.NewId(),
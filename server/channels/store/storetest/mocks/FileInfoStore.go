@@ -601,6 +601,24 @@ func (_m *FileInfoStore) Search(rctx request.CTX, paramsList []*model.SearchPara
 	return r0, r1
 }
 
+// SetBlobId provides a mock function with given fields: rctx, fileID, blobID
+func (_m *FileInfoStore) SetBlobId(rctx request.CTX, fileID string, blobID string) error {
+	ret := _m.Called(rctx, fileID, blobID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetBlobId")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(request.CTX, string, string) error); ok {
+		r0 = rf(rctx, fileID, blobID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetContent provides a mock function with given fields: rctx, fileID, content
 func (_m *FileInfoStore) SetContent(rctx request.CTX, fileID string, content string) error {
 	ret := _m.Called(rctx, fileID, content)
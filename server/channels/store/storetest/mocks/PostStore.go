@@ -138,6 +138,34 @@ func (_m *PostStore) ClearCaches() {
 	_m.Called()
 }
 
+// CountForRetentionPolicies provides a mock function with given fields: retentionPolicyBatchConfigs
+func (_m *PostStore) CountForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs) (int64, error) {
+	ret := _m.Called(retentionPolicyBatchConfigs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountForRetentionPolicies")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(model.RetentionPolicyBatchConfigs) (int64, error)); ok {
+		return rf(retentionPolicyBatchConfigs)
+	}
+	if rf, ok := ret.Get(0).(func(model.RetentionPolicyBatchConfigs) int64); ok {
+		r0 = rf(retentionPolicyBatchConfigs)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(model.RetentionPolicyBatchConfigs) error); ok {
+		r1 = rf(retentionPolicyBatchConfigs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Delete provides a mock function with given fields: rctx, postID, timestamp, deleteByID
 func (_m *PostStore) Delete(rctx request.CTX, postID string, timestamp int64, deleteByID string) error {
 	ret := _m.Called(rctx, postID, timestamp, deleteByID)
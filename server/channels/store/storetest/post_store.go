@@ -4223,6 +4223,13 @@ func testPostStorePermanentDeleteBatch(t *testing.T, rctx request.CTX, ss store.
 	o3, err = ss.Post().Save(rctx, o3)
 	require.NoError(t, err)
 
+	count, err := ss.Post().CountForRetentionPolicies(model.RetentionPolicyBatchConfigs{
+		Now:                 0,
+		GlobalPolicyEndTime: 2000,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count, "counting should report the posts the batch deletes")
+
 	deleted, _, err := ss.Post().PermanentDeleteBatchForRetentionPolicies(model.RetentionPolicyBatchConfigs{
 		Now:                 0,
 		GlobalPolicyEndTime: 2000,
@@ -4261,6 +4268,13 @@ func testPostStorePermanentDeleteBatch(t *testing.T, rctx request.CTX, ss store.
 		}
 		cursor := model.RetentionPolicyCursor{}
 
+		count, err = ss.Post().CountForRetentionPolicies(model.RetentionPolicyBatchConfigs{
+			Now:                 0,
+			GlobalPolicyEndTime: 2,
+		})
+		require.NoError(t, err)
+		require.Equal(t, int64(3), count, "counting should not be limited to a batch")
+
 		deleted, cursor, err = ss.Post().PermanentDeleteBatchForRetentionPolicies(model.RetentionPolicyBatchConfigs{
 			Now:                 0,
 			GlobalPolicyEndTime: 2,
@@ -413,6 +413,11 @@ func testRetentionPolicyStoreDelete(t *testing.T, rctx request.CTX, ss store.Sto
 		require.NoError(t, err)
 		require.Empty(t, policies)
 	})
+	t.Run("delete missing policy", func(t *testing.T) {
+		err := ss.RetentionPolicy().Delete(model.NewId())
+		var nfErr *store.ErrNotFound
+		require.ErrorAs(t, err, &nfErr)
+	})
 }
 
 func testRetentionPolicyStoreGetChannels(t *testing.T, rctx request.CTX, ss store.Store, s SqlStore) {
@@ -73,6 +73,7 @@ type Store struct {
 	ContentFlaggingStore            mocks.ContentFlaggingStore
 	ReadReceiptStore                mocks.ReadReceiptStore
 	TemporaryPostStore              mocks.TemporaryPostStore
+	FileBlobStore                   mocks.FileBlobStore
 }
 
 func (s *Store) Logger() mlog.LoggerIFace                      { return s.logger }
@@ -175,6 +176,9 @@ func (s *Store) ReadReceipt() store.ReadReceiptStore {
 func (s *Store) TemporaryPost() store.TemporaryPostStore {
 	return &s.TemporaryPostStore
 }
+func (s *Store) FileBlob() store.FileBlobStore {
+	return &s.FileBlobStore
+}
 func (s *Store) GetSchemaDefinition() (*model.SupportPacketDatabaseSchema, error) {
 	return &model.SupportPacketDatabaseSchema{
 		Tables: []model.DatabaseTable{},
@@ -229,5 +233,6 @@ func (s *Store) AssertExpectations(t mock.TestingT) bool {
 		&s.ContentFlaggingStore,
 		&s.ReadReceiptStore,
 		&s.TemporaryPostStore,
+		&s.FileBlobStore,
 	)
 }
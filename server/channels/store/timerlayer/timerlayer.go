@@ -35,6 +35,7 @@ type TimerLayer struct {
 	DesktopTokensStore              store.DesktopTokensStore
 	DraftStore                      store.DraftStore
 	EmojiStore                      store.EmojiStore
+	FileBlobStore                   store.FileBlobStore
 	FileInfoStore                   store.FileInfoStore
 	GroupStore                      store.GroupStore
 	JobStore                        store.JobStore
@@ -140,6 +141,10 @@ func (s *TimerLayer) Emoji() store.EmojiStore {
 	return s.EmojiStore
 }
 
+func (s *TimerLayer) FileBlob() store.FileBlobStore {
+	return s.FileBlobStore
+}
+
 func (s *TimerLayer) FileInfo() store.FileInfoStore {
 	return s.FileInfoStore
 }
@@ -376,6 +381,11 @@ type TimerLayerEmojiStore struct {
 	Root *TimerLayer
 }
 
+type TimerLayerFileBlobStore struct {
+	store.FileBlobStore
+	Root *TimerLayer
+}
+
 type TimerLayerFileInfoStore struct {
 	store.FileInfoStore
 	Root *TimerLayer
@@ -4013,6 +4023,150 @@ func (s *TimerLayerEmojiStore) Search(name string, prefixOnly bool, limit int) (
 	return result, err
 }
 
+func (s *TimerLayerFileBlobStore) AddReference(path string, hash string, size int64) (*model.FileBlob, error) {
+	start := time.Now()
+
+	result, err := s.FileBlobStore.AddReference(path, hash, size)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileBlobStore.AddReference", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerFileBlobStore) DeleteUnreferenced(id string, maxUpdateAt int64) (bool, error) {
+	start := time.Now()
+
+	result, err := s.FileBlobStore.DeleteUnreferenced(id, maxUpdateAt)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileBlobStore.DeleteUnreferenced", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerFileBlobStore) GetReference(path string) (*model.FileBlobReference, error) {
+	start := time.Now()
+
+	result, err := s.FileBlobStore.GetReference(path)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileBlobStore.GetReference", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerFileBlobStore) GetReferencesWithPrefix(prefix string) ([]*model.FileBlobReference, error) {
+	start := time.Now()
+
+	result, err := s.FileBlobStore.GetReferencesWithPrefix(prefix)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileBlobStore.GetReferencesWithPrefix", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerFileBlobStore) GetUnreferenced(maxUpdateAt int64, limit int) ([]*model.FileBlob, error) {
+	start := time.Now()
+
+	result, err := s.FileBlobStore.GetUnreferenced(maxUpdateAt, limit)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileBlobStore.GetUnreferenced", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerFileBlobStore) HasReferences() (bool, error) {
+	start := time.Now()
+
+	result, err := s.FileBlobStore.HasReferences()
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileBlobStore.HasReferences", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerFileBlobStore) MoveReference(oldPath string, newPath string) error {
+	start := time.Now()
+
+	err := s.FileBlobStore.MoveReference(oldPath, newPath)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileBlobStore.MoveReference", success, elapsed)
+	}
+	return err
+}
+
+func (s *TimerLayerFileBlobStore) RemoveReference(path string) error {
+	start := time.Now()
+
+	err := s.FileBlobStore.RemoveReference(path)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileBlobStore.RemoveReference", success, elapsed)
+	}
+	return err
+}
+
+func (s *TimerLayerFileBlobStore) RemoveReferencesWithPrefix(prefix string) (int64, error) {
+	start := time.Now()
+
+	result, err := s.FileBlobStore.RemoveReferencesWithPrefix(prefix)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileBlobStore.RemoveReferencesWithPrefix", success, elapsed)
+	}
+	return result, err
+}
+
 func (s *TimerLayerFileInfoStore) AttachToPost(rctx request.CTX, fileID string, postID string, channelID string, creatorID string) error {
 	start := time.Now()
 
@@ -4395,6 +4549,22 @@ func (s *TimerLayerFileInfoStore) Search(rctx request.CTX, paramsList []*model.S
 	return result, err
 }
 
+func (s *TimerLayerFileInfoStore) SetBlobId(rctx request.CTX, fileID string, blobID string) error {
+	start := time.Now()
+
+	err := s.FileInfoStore.SetBlobId(rctx, fileID, blobID)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileInfoStore.SetBlobId", success, elapsed)
+	}
+	return err
+}
+
 func (s *TimerLayerFileInfoStore) SetContent(rctx request.CTX, fileID string, content string) error {
 	start := time.Now()
 
@@ -6394,6 +6564,22 @@ func (s *TimerLayerPostStore) ClearCaches() {
 	}
 }
 
+func (s *TimerLayerPostStore) CountForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs) (int64, error) {
+	start := time.Now()
+
+	result, err := s.PostStore.CountForRetentionPolicies(retentionPolicyBatchConfigs)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("PostStore.CountForRetentionPolicies", success, elapsed)
+	}
+	return result, err
+}
+
 func (s *TimerLayerPostStore) Delete(rctx request.CTX, postID string, timestamp int64, deleteByID string) error {
 	start := time.Now()
 
@@ -14004,6 +14190,7 @@ func New(childStore store.Store, metrics einterfaces.MetricsInterface) *TimerLay
 	newStore.DesktopTokensStore = &TimerLayerDesktopTokensStore{DesktopTokensStore: childStore.DesktopTokens(), Root: &newStore}
 	newStore.DraftStore = &TimerLayerDraftStore{DraftStore: childStore.Draft(), Root: &newStore}
 	newStore.EmojiStore = &TimerLayerEmojiStore{EmojiStore: childStore.Emoji(), Root: &newStore}
+	newStore.FileBlobStore = &TimerLayerFileBlobStore{FileBlobStore: childStore.FileBlob(), Root: &newStore}
 	newStore.FileInfoStore = &TimerLayerFileInfoStore{FileInfoStore: childStore.FileInfo(), Root: &newStore}
 	newStore.GroupStore = &TimerLayerGroupStore{GroupStore: childStore.Group(), Root: &newStore}
 	newStore.JobStore = &TimerLayerJobStore{JobStore: childStore.Job(), Root: &newStore}
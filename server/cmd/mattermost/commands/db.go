@@ -190,6 +190,8 @@ func migrateCmdF(command *cobra.Command, args []string) error {
 	}
 
 	if savePlan || recoverFlag {
+		// The plan is written before the migrations run, so the file blob tables may not exist
+		// yet. Plans are never deduplicated, so a backend without deduplication handles them.
 		backend, err2 := filestore.NewFileBackend(ConfigToFileBackendSettings(&config.FileSettings, false, true))
 		if err2 != nil {
 			return fmt.Errorf("failed to initialize filebackend: %w", err2)
@@ -240,6 +242,7 @@ func downgradeCmdF(command *cobra.Command, args []string) error {
 	dryRun, _ := command.Flags().GetBool("dry-run")
 	recoverFlag, _ := command.Flags().GetBool("auto-recover")
 
+	// Migration plans are never deduplicated; see migrateCmdF.
 	backend, err2 := filestore.NewFileBackend(ConfigToFileBackendSettings(&config.FileSettings, false, true))
 	if err2 != nil {
 		return fmt.Errorf("failed to initialize filebackend: %w", err2)
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/mattermost/mattermost/server/public/model"
@@ -33,6 +34,12 @@ func desanitize(actual, target *model.Config) {
 	if *target.FileSettings.AmazonS3SecretAccessKey == model.FakeSetting {
 		target.FileSettings.AmazonS3SecretAccessKey = actual.FileSettings.AmazonS3SecretAccessKey
 	}
+	if target.FileSettings.EncryptionKey != nil && *target.FileSettings.EncryptionKey == model.FakeSetting {
+		target.FileSettings.EncryptionKey = actual.FileSettings.EncryptionKey
+	}
+	if slices.Contains(target.FileSettings.EncryptionPreviousKeys, model.FakeSetting) {
+		target.FileSettings.EncryptionPreviousKeys = actual.FileSettings.EncryptionPreviousKeys
+	}
 
 	if *target.EmailSettings.SMTPPassword == model.FakeSetting {
 		target.EmailSettings.SMTPPassword = actual.EmailSettings.SMTPPassword
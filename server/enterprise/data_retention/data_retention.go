@@ -0,0 +1,229 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package data_retention manages the global, team and channel retention policies and runs
+// the job deleting what they no longer retain, for builds without the enterprise modules.
+package data_retention
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+	ejobs "github.com/mattermost/mattermost/server/v8/einterfaces/jobs"
+)
+
+func init() {
+	app.RegisterDataRetentionInterface(func(a *app.App) einterfaces.DataRetentionInterface {
+		return &DataRetentionInterfaceImpl{Server: a.Srv()}
+	})
+	app.RegisterJobsDataRetentionJobInterface(func(s *app.Server) ejobs.DataRetentionJobInterface {
+		return &DataRetentionJobInterfaceImpl{Server: s}
+	})
+}
+
+type DataRetentionJobInterfaceImpl struct {
+	Server *app.Server
+}
+
+func (d *DataRetentionJobInterfaceImpl) MakeWorker() model.Worker {
+	w := &worker{
+		jobServer:   d.Server.Jobs,
+		store:       d.Server.Store(),
+		fileBackend: d.Server.FileBackend,
+	}
+	return jobs.NewSimpleWorker("DataRetention", d.Server.Jobs, w.doJob, isEnabled)
+}
+
+func (d *DataRetentionJobInterfaceImpl) MakeScheduler() ejobs.Scheduler {
+	startTime := func(cfg *model.Config) *time.Time {
+		parsedTime, err := time.Parse("15:04", *cfg.DataRetentionSettings.DeletionJobStartTime)
+		if err == nil {
+			return &parsedTime
+		}
+		return nil
+	}
+	return jobs.NewDailyScheduler(d.Server.Jobs, model.JobTypeDataRetention, startTime, isEnabled)
+}
+
+type DataRetentionInterfaceImpl struct {
+	Server *app.Server
+}
+
+func (d *DataRetentionInterfaceImpl) GetGlobalPolicy() (*model.GlobalRetentionPolicy, *model.AppError) {
+	settings := d.Server.Config().DataRetentionSettings
+	now := model.GetMillis()
+	return &model.GlobalRetentionPolicy{
+		MessageDeletionEnabled: *settings.EnableMessageDeletion,
+		FileDeletionEnabled:    *settings.EnableFileDeletion,
+		MessageRetentionCutoff: now - hoursToMillis(settings.GetMessageRetentionHours()),
+		FileRetentionCutoff:    now - hoursToMillis(settings.GetFileRetentionHours()),
+	}, nil
+}
+
+func (d *DataRetentionInterfaceImpl) GetPolicies(offset, limit int) (*model.RetentionPolicyWithTeamAndChannelCountsList, *model.AppError) {
+	policies, err := d.Server.Store().RetentionPolicy().GetAll(offset, limit)
+	if err != nil {
+		return nil, internalError("GetPolicies", err)
+	}
+	count, err := d.Server.Store().RetentionPolicy().GetCount()
+	if err != nil {
+		return nil, internalError("GetPolicies", err)
+	}
+	return &model.RetentionPolicyWithTeamAndChannelCountsList{Policies: policies, TotalCount: count}, nil
+}
+
+func (d *DataRetentionInterfaceImpl) GetPoliciesCount() (int64, *model.AppError) {
+	count, err := d.Server.Store().RetentionPolicy().GetCount()
+	if err != nil {
+		return 0, internalError("GetPoliciesCount", err)
+	}
+	return count, nil
+}
+
+func (d *DataRetentionInterfaceImpl) GetPolicy(policyID string) (*model.RetentionPolicyWithTeamAndChannelCounts, *model.AppError) {
+	policy, err := d.Server.Store().RetentionPolicy().Get(policyID)
+	if err != nil {
+		return nil, storeError("GetPolicy", err)
+	}
+	return policy, nil
+}
+
+func (d *DataRetentionInterfaceImpl) CreatePolicy(policy *model.RetentionPolicyWithTeamAndChannelIDs) (*model.RetentionPolicyWithTeamAndChannelCounts, *model.AppError) {
+	if policy.DisplayName == "" || policy.PostDurationDays == nil || !isValidPostDuration(*policy.PostDurationDays) {
+		return nil, invalidPolicyError("CreatePolicy")
+	}
+	created, err := d.Server.Store().RetentionPolicy().Save(policy)
+	if err != nil {
+		return nil, storeError("CreatePolicy", err)
+	}
+	return created, nil
+}
+
+func (d *DataRetentionInterfaceImpl) PatchPolicy(patch *model.RetentionPolicyWithTeamAndChannelIDs) (*model.RetentionPolicyWithTeamAndChannelCounts, *model.AppError) {
+	if patch.PostDurationDays != nil && !isValidPostDuration(*patch.PostDurationDays) {
+		return nil, invalidPolicyError("PatchPolicy")
+	}
+	patched, err := d.Server.Store().RetentionPolicy().Patch(patch)
+	if err != nil {
+		return nil, storeError("PatchPolicy", err)
+	}
+	return patched, nil
+}
+
+func (d *DataRetentionInterfaceImpl) DeletePolicy(policyID string) *model.AppError {
+	if err := d.Server.Store().RetentionPolicy().Delete(policyID); err != nil {
+		return storeError("DeletePolicy", err)
+	}
+	return nil
+}
+
+func (d *DataRetentionInterfaceImpl) GetTeamsForPolicy(policyID string, offset, limit int) (*model.TeamsWithCount, *model.AppError) {
+	teams, err := d.Server.Store().RetentionPolicy().GetTeams(policyID, offset, limit)
+	if err != nil {
+		return nil, storeError("GetTeamsForPolicy", err)
+	}
+	count, err := d.Server.Store().RetentionPolicy().GetTeamsCount(policyID)
+	if err != nil {
+		return nil, storeError("GetTeamsForPolicy", err)
+	}
+	return &model.TeamsWithCount{Teams: teams, TotalCount: count}, nil
+}
+
+func (d *DataRetentionInterfaceImpl) AddTeamsToPolicy(policyID string, teamIDs []string) *model.AppError {
+	if err := d.Server.Store().RetentionPolicy().AddTeams(policyID, teamIDs); err != nil {
+		return storeError("AddTeamsToPolicy", err)
+	}
+	return nil
+}
+
+func (d *DataRetentionInterfaceImpl) RemoveTeamsFromPolicy(policyID string, teamIDs []string) *model.AppError {
+	if err := d.Server.Store().RetentionPolicy().RemoveTeams(policyID, teamIDs); err != nil {
+		return storeError("RemoveTeamsFromPolicy", err)
+	}
+	return nil
+}
+
+func (d *DataRetentionInterfaceImpl) GetChannelsForPolicy(policyID string, offset, limit int) (*model.ChannelsWithCount, *model.AppError) {
+	channels, err := d.Server.Store().RetentionPolicy().GetChannels(policyID, offset, limit)
+	if err != nil {
+		return nil, storeError("GetChannelsForPolicy", err)
+	}
+	count, err := d.Server.Store().RetentionPolicy().GetChannelsCount(policyID)
+	if err != nil {
+		return nil, storeError("GetChannelsForPolicy", err)
+	}
+	return &model.ChannelsWithCount{Channels: channels, TotalCount: count}, nil
+}
+
+func (d *DataRetentionInterfaceImpl) AddChannelsToPolicy(policyID string, channelIDs []string) *model.AppError {
+	if err := d.Server.Store().RetentionPolicy().AddChannels(policyID, channelIDs); err != nil {
+		return storeError("AddChannelsToPolicy", err)
+	}
+	return nil
+}
+
+func (d *DataRetentionInterfaceImpl) RemoveChannelsFromPolicy(policyID string, channelIDs []string) *model.AppError {
+	if err := d.Server.Store().RetentionPolicy().RemoveChannels(policyID, channelIDs); err != nil {
+		return storeError("RemoveChannelsFromPolicy", err)
+	}
+	return nil
+}
+
+func (d *DataRetentionInterfaceImpl) GetTeamPoliciesForUser(userID string, offset, limit int) (*model.RetentionPolicyForTeamList, *model.AppError) {
+	policies, err := d.Server.Store().RetentionPolicy().GetTeamPoliciesForUser(userID, offset, limit)
+	if err != nil {
+		return nil, internalError("GetTeamPoliciesForUser", err)
+	}
+	count, err := d.Server.Store().RetentionPolicy().GetTeamPoliciesCountForUser(userID)
+	if err != nil {
+		return nil, internalError("GetTeamPoliciesForUser", err)
+	}
+	return &model.RetentionPolicyForTeamList{Policies: policies, TotalCount: count}, nil
+}
+
+func (d *DataRetentionInterfaceImpl) GetChannelPoliciesForUser(userID string, offset, limit int) (*model.RetentionPolicyForChannelList, *model.AppError) {
+	policies, err := d.Server.Store().RetentionPolicy().GetChannelPoliciesForUser(userID, offset, limit)
+	if err != nil {
+		return nil, internalError("GetChannelPoliciesForUser", err)
+	}
+	count, err := d.Server.Store().RetentionPolicy().GetChannelPoliciesCountForUser(userID)
+	if err != nil {
+		return nil, internalError("GetChannelPoliciesForUser", err)
+	}
+	return &model.RetentionPolicyForChannelList{Policies: policies, TotalCount: count}, nil
+}
+
+// isValidPostDuration reports whether days is a number of days to keep posts for, or -1 to
+// keep them forever. A policy of 0 days would delete every post up to now.
+func isValidPostDuration(days int64) bool {
+	return days == -1 || days >= 1
+}
+
+func hoursToMillis(hours int) int64 {
+	return int64(hours) * time.Hour.Milliseconds()
+}
+
+func invalidPolicyError(where string) *model.AppError {
+	return model.NewAppError("DataRetention."+where, "ent.data_retention.policies.invalid_policy", nil, "", http.StatusBadRequest)
+}
+
+func internalError(where string, err error) *model.AppError {
+	return model.NewAppError("DataRetention."+where, "ent.data_retention.policies.internal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+}
+
+// storeError maps the errors of the retention policy store, which reports a missing policy,
+// team or channel either as a not found error or as no rows.
+func storeError(where string, err error) *model.AppError {
+	var nfErr *store.ErrNotFound
+	if errors.As(err, &nfErr) || errors.Is(err, sql.ErrNoRows) {
+		return model.NewAppError("DataRetention."+where, "ent.data_retention.policies.not_found", nil, "", http.StatusNotFound).Wrap(err)
+	}
+	return internalError(where, err)
+}
@@ -0,0 +1,28 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package data_retention
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidPostDuration(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		days  int64
+		valid bool
+	}{
+		{"keep forever", -1, true},
+		{"one day", 1, true},
+		{"one year", 365, true},
+		{"zero days", 0, false},
+		{"below keep forever", -2, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.valid, isValidPostDuration(tc.days))
+		})
+	}
+}
@@ -0,0 +1,377 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package data_retention
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+const (
+	// JobDataDryRun set to "true" makes the job report what it would delete instead of
+	// deleting it.
+	JobDataDryRun = "dry_run"
+
+	// JobDataStage is the stage the job has reached, so that a job claimed again after a
+	// restart carries on from there.
+	JobDataStage = "stage"
+
+	JobDataNow                = "now"
+	JobDataMessageCutoff      = "message_retention_cutoff"
+	JobDataFileCutoff         = "file_retention_cutoff"
+	JobDataPostsDeleted       = "posts_deleted"
+	JobDataFilesDeleted       = "files_deleted"
+	JobDataReactionsDeleted   = "reactions_deleted"
+	JobDataThreadsDeleted     = "threads_deleted"
+	JobDataMembershipsDeleted = "thread_memberships_deleted"
+	JobDataHistoryDeleted     = "channel_member_history_deleted"
+	JobDataOrphansDeleted     = "orphaned_rows_deleted"
+	JobDataWarningCount       = "warning_count"
+	JobDataPostsToDelete      = "posts_to_delete"
+	JobDataFilesToDelete      = "files_to_delete"
+	JobDataFilesCursorTime    = "files_cursor_time"
+	JobDataFilesCursorId      = "files_cursor_id"
+	JobDataPoliciesCount      = "policies_count"
+)
+
+const (
+	stagePosts             = "posts"
+	stageThreads           = "threads"
+	stageThreadMemberships = "thread_memberships"
+	stageChannelHistory    = "channel_member_history"
+	stageFiles             = "files"
+	stageOrphans           = "orphaned_rows"
+)
+
+// stages are run in this order. Posts go first so that the files and reactions of the posts
+// they delete are removed with them.
+var stages = []string{
+	stagePosts,
+	stageThreads,
+	stageThreadMemberships,
+	stageChannelHistory,
+	stageFiles,
+	stageOrphans,
+}
+
+// isEnabled always lets the job run: team and channel policies apply even when the global
+// policy deletes nothing, and without policies the job has nothing to do.
+func isEnabled(_ *model.Config) bool {
+	return true
+}
+
+type worker struct {
+	jobServer   *jobs.JobServer
+	store       store.Store
+	fileBackend func() filestore.FileBackend
+}
+
+// run holds the state of one job, mirrored into the job data as it progresses.
+type run struct {
+	job      *model.Job
+	logger   mlog.LoggerIFace
+	settings model.DataRetentionSettings
+	dryRun   bool
+
+	now           int64
+	messageCutoff int64
+	fileCutoff    int64
+}
+
+func (w *worker) doJob(logger mlog.LoggerIFace, job *model.Job) error {
+	defer w.jobServer.HandleJobPanic(logger, job)
+
+	if job.Data == nil {
+		job.Data = model.StringMap{}
+	}
+	r := &run{
+		job:      job,
+		logger:   logger,
+		settings: w.jobServer.Config().DataRetentionSettings,
+		dryRun:   job.Data[JobDataDryRun] == "true",
+	}
+	r.initCutoffs()
+
+	if r.dryRun {
+		return w.dryRun(r)
+	}
+
+	start := 0
+	for i, stage := range stages {
+		if job.Data[JobDataStage] == stage {
+			start = i
+		}
+	}
+	for i := start; i < len(stages); i++ {
+		job.Data[JobDataStage] = stages[i]
+		if appErr := w.jobServer.SetJobProgress(job, int64(i*100/len(stages))); appErr != nil {
+			return appErr
+		}
+		if err := w.runStage(r, stages[i]); err != nil {
+			return errors.Wrapf(err, "data retention failed deleting %s", stages[i])
+		}
+	}
+
+	logger.Info("Data retention finished",
+		mlog.String(JobDataPostsDeleted, job.Data[JobDataPostsDeleted]),
+		mlog.String(JobDataFilesDeleted, job.Data[JobDataFilesDeleted]),
+		mlog.String(JobDataWarningCount, job.Data[JobDataWarningCount]),
+	)
+	return nil
+}
+
+// initCutoffs fixes the times the policies are applied against the first time the job is
+// claimed. A cutoff of 0 disables the global policy for that kind of data.
+func (r *run) initCutoffs() {
+	if _, ok := r.job.Data[JobDataNow]; !ok {
+		now := model.GetMillis()
+		r.job.Data[JobDataNow] = strconv.FormatInt(now, 10)
+		r.job.Data[JobDataMessageCutoff] = "0"
+		r.job.Data[JobDataFileCutoff] = "0"
+		if *r.settings.EnableMessageDeletion {
+			r.job.Data[JobDataMessageCutoff] = strconv.FormatInt(now-hoursToMillis(r.settings.GetMessageRetentionHours()), 10)
+		}
+		if *r.settings.EnableFileDeletion {
+			r.job.Data[JobDataFileCutoff] = strconv.FormatInt(now-hoursToMillis(r.settings.GetFileRetentionHours()), 10)
+		}
+	}
+
+	r.now = r.int64(JobDataNow)
+	r.messageCutoff = r.int64(JobDataMessageCutoff)
+	r.fileCutoff = r.int64(JobDataFileCutoff)
+}
+
+func (r *run) int64(key string) int64 {
+	value, _ := strconv.ParseInt(r.job.Data[key], 10, 64)
+	return value
+}
+
+func (r *run) add(key string, n int64) {
+	r.job.Data[key] = strconv.FormatInt(r.int64(key)+n, 10)
+}
+
+func (r *run) batchConfigs() model.RetentionPolicyBatchConfigs {
+	return model.RetentionPolicyBatchConfigs{
+		Now:                 r.now,
+		GlobalPolicyEndTime: r.messageCutoff,
+		Limit:               int64(*r.settings.BatchSize),
+		PreservePinnedPosts: *r.settings.PreservePinnedPosts,
+	}
+}
+
+func (w *worker) runStage(r *run, stage string) error {
+	switch stage {
+	case stagePosts:
+		// Files and reactions of posts deleted by an earlier, interrupted run go first.
+		if err := w.deleteForDeletedPosts(r); err != nil {
+			return err
+		}
+		return w.deleteBatches(r, JobDataPostsDeleted, func(configs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error) {
+			deleted, cursor, err := w.store.Post().PermanentDeleteBatchForRetentionPolicies(configs, cursor)
+			if err != nil {
+				return deleted, cursor, err
+			}
+			return deleted, cursor, w.deleteForDeletedPosts(r)
+		})
+	case stageThreads:
+		return w.deleteBatches(r, JobDataThreadsDeleted, w.store.Thread().PermanentDeleteBatchForRetentionPolicies)
+	case stageThreadMemberships:
+		return w.deleteBatches(r, JobDataMembershipsDeleted, w.store.Thread().PermanentDeleteBatchThreadMembershipsForRetentionPolicies)
+	case stageChannelHistory:
+		return w.deleteBatches(r, JobDataHistoryDeleted, w.store.ChannelMemberHistory().PermanentDeleteBatchForRetentionPolicies)
+	case stageFiles:
+		return w.deleteFiles(r)
+	case stageOrphans:
+		return w.deleteOrphanedRows(r)
+	}
+	return nil
+}
+
+type deleteBatchFunc func(configs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error)
+
+// deleteBatches calls deleteBatch until the channel, team and global policies are all done,
+// adding up what it deletes under countKey.
+func (w *worker) deleteBatches(r *run, countKey string, deleteBatch deleteBatchFunc) error {
+	configs := r.batchConfigs()
+	var cursor model.RetentionPolicyCursor
+	for !cursor.ChannelPoliciesDone || !cursor.TeamPoliciesDone || !cursor.GlobalPoliciesDone {
+		deleted, next, err := deleteBatch(configs, cursor)
+		if err != nil {
+			return err
+		}
+		cursor = next
+		r.add(countKey, deleted)
+		if appErr := w.jobServer.UpdateInProgressJobData(r.job); appErr != nil {
+			return appErr
+		}
+		w.pause(r)
+	}
+	return nil
+}
+
+// deleteForDeletedPosts removes the files and reactions of the posts recorded as deleted by
+// the retention policies. Removing the reactions also clears the record.
+func (w *worker) deleteForDeletedPosts(r *run) error {
+	for {
+		rows, err := w.store.RetentionPolicy().GetIdsForDeletionByTableName("Posts", *r.settings.RetentionIdsBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		for _, row := range rows {
+			for _, postID := range row.Ids {
+				infos, err := w.store.FileInfo().GetForPost(postID, true, true, false)
+				if err != nil {
+					return err
+				}
+				for _, info := range infos {
+					if err := w.deleteFile(r, info); err != nil {
+						return err
+					}
+				}
+			}
+
+			deleted, err := w.store.Reaction().DeleteOrphanedRowsByIds(row)
+			if err != nil {
+				return err
+			}
+			r.add(JobDataReactionsDeleted, deleted)
+		}
+
+		if appErr := w.jobServer.UpdateInProgressJobData(r.job); appErr != nil {
+			return appErr
+		}
+	}
+}
+
+// deleteFiles applies the global file policy, oldest file first.
+func (w *worker) deleteFiles(r *run) error {
+	if r.fileCutoff <= 0 {
+		return nil
+	}
+
+	for {
+		files, err := w.store.FileInfo().GetFilesBatchForIndexing(r.int64(JobDataFilesCursorTime), r.job.Data[JobDataFilesCursorId], true, *r.settings.BatchSize)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if file.CreateAt >= r.fileCutoff {
+				return nil
+			}
+			if file.CreatorId != model.BookmarkFileOwner {
+				if r.dryRun {
+					r.add(JobDataFilesToDelete, 1)
+				} else if err := w.deleteFile(r, &file.FileInfo); err != nil {
+					return err
+				}
+			}
+			r.job.Data[JobDataFilesCursorTime] = strconv.FormatInt(file.CreateAt, 10)
+			r.job.Data[JobDataFilesCursorId] = file.Id
+		}
+
+		if appErr := w.jobServer.UpdateInProgressJobData(r.job); appErr != nil {
+			return appErr
+		}
+		if len(files) < *r.settings.BatchSize {
+			return nil
+		}
+		w.pause(r)
+	}
+}
+
+// deleteFile removes a file, its thumbnail and its preview from the file backend, then its
+// file info. Files already missing from the backend are counted as warnings.
+func (w *worker) deleteFile(r *run, info *model.FileInfo) error {
+	backend := w.fileBackend()
+	for _, path := range []string{info.Path, info.ThumbnailPath, info.PreviewPath} {
+		if path == "" {
+			continue
+		}
+		if err := backend.RemoveFile(path); err != nil {
+			r.logger.Warn("Failed to remove file from the file backend", mlog.String("file_id", info.Id), mlog.String("path", path), mlog.Err(err))
+			r.add(JobDataWarningCount, 1)
+		}
+	}
+
+	if err := w.store.FileInfo().PermanentDelete(request.EmptyContext(r.logger), info.Id); err != nil {
+		return err
+	}
+	r.add(JobDataFilesDeleted, 1)
+	return nil
+}
+
+// deleteOrphanedRows removes the rows left pointing at deleted threads, posts, teams and
+// channels.
+func (w *worker) deleteOrphanedRows(r *run) error {
+	limit := *r.settings.BatchSize
+	for _, deleteOrphans := range []func(limit int) (int64, error){
+		w.store.Thread().DeleteOrphanedRows,
+		w.store.Preference().DeleteOrphanedRows,
+		w.store.RetentionPolicy().DeleteOrphanedRows,
+	} {
+		for {
+			deleted, err := deleteOrphans(limit)
+			if err != nil {
+				return err
+			}
+			r.add(JobDataOrphansDeleted, deleted)
+			if deleted < int64(limit) {
+				break
+			}
+			w.pause(r)
+		}
+	}
+	if appErr := w.jobServer.UpdateInProgressJobData(r.job); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// dryRun reports how many posts and files the policies would delete.
+func (w *worker) dryRun(r *run) error {
+	count, err := w.store.Post().CountForRetentionPolicies(r.batchConfigs())
+	if err != nil {
+		return errors.Wrap(err, "data retention failed counting posts")
+	}
+	r.job.Data[JobDataPostsToDelete] = strconv.FormatInt(count, 10)
+
+	policies, err := w.store.RetentionPolicy().GetCount()
+	if err != nil {
+		return errors.Wrap(err, "data retention failed counting policies")
+	}
+	r.job.Data[JobDataPoliciesCount] = strconv.FormatInt(policies, 10)
+
+	r.job.Data[JobDataFilesToDelete] = "0"
+	if err := w.deleteFiles(r); err != nil {
+		return errors.Wrap(err, "data retention failed counting files")
+	}
+	delete(r.job.Data, JobDataFilesCursorTime)
+	delete(r.job.Data, JobDataFilesCursorId)
+
+	r.logger.Info("Data retention dry run finished",
+		mlog.String(JobDataPostsToDelete, r.job.Data[JobDataPostsToDelete]),
+		mlog.String(JobDataFilesToDelete, r.job.Data[JobDataFilesToDelete]),
+	)
+	if appErr := w.jobServer.UpdateInProgressJobData(r.job); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+func (w *worker) pause(r *run) {
+	time.Sleep(time.Duration(*r.settings.TimeBetweenBatchesMilliseconds) * time.Millisecond)
+}
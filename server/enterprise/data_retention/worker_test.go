@@ -0,0 +1,152 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package data_retention
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest"
+	"github.com/mattermost/mattermost/server/v8/channels/utils/testutils"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+func setupWorker(t *testing.T, configure func(cfg *model.Config)) (*worker, *storetest.Store, filestore.FileBackend) {
+	t.Helper()
+
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	*cfg.DataRetentionSettings.TimeBetweenBatchesMilliseconds = 0
+	if configure != nil {
+		configure(cfg)
+	}
+
+	mockStore := &storetest.Store{}
+	t.Cleanup(func() {
+		mockStore.AssertExpectations(t)
+	})
+	mockStore.JobStore.On("UpdateOptimistically", mock.AnythingOfType("*model.Job"), model.JobStatusInProgress).Return(true, nil)
+
+	backend, err := filestore.NewFileBackend(filestore.FileBackendSettings{
+		DriverName: model.ImageDriverLocal,
+		Directory:  t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	jobServer := jobs.NewJobServer(&testutils.StaticConfigService{Cfg: cfg}, mockStore, nil, mlog.CreateConsoleTestLogger(t))
+	w := &worker{
+		jobServer:   jobServer,
+		store:       mockStore,
+		fileBackend: func() filestore.FileBackend { return backend },
+	}
+	return w, mockStore, backend
+}
+
+func doneCursor() model.RetentionPolicyCursor {
+	return model.RetentionPolicyCursor{ChannelPoliciesDone: true, TeamPoliciesDone: true, GlobalPoliciesDone: true}
+}
+
+func TestDataRetentionJob(t *testing.T) {
+	w, mockStore, backend := setupWorker(t, func(cfg *model.Config) {
+		*cfg.DataRetentionSettings.EnableMessageDeletion = true
+	})
+
+	fileInfo := &model.FileInfo{Id: model.NewId(), PostId: model.NewId(), Path: "data/report.txt", ThumbnailPath: "data/report_thumb.jpg"}
+	_, err := backend.WriteFile(bytes.NewReader([]byte("report")), fileInfo.Path)
+	require.NoError(t, err)
+	deletedPosts := &model.RetentionIdsForDeletion{Id: model.NewId(), TableName: "Posts", Ids: []string{fileInfo.PostId}}
+
+	mockStore.PostStore.On("PermanentDeleteBatchForRetentionPolicies", mock.MatchedBy(func(configs model.RetentionPolicyBatchConfigs) bool {
+		return configs.Now > 0 && configs.GlobalPolicyEndTime > 0 && configs.GlobalPolicyEndTime < configs.Now
+	}), model.RetentionPolicyCursor{}).Return(int64(1), doneCursor(), nil).Once()
+	// The first lookup runs before any post is deleted.
+	mockStore.RetentionPolicyStore.On("GetIdsForDeletionByTableName", "Posts", 100).Return([]*model.RetentionIdsForDeletion{}, nil).Once()
+	mockStore.RetentionPolicyStore.On("GetIdsForDeletionByTableName", "Posts", 100).Return([]*model.RetentionIdsForDeletion{deletedPosts}, nil).Once()
+	mockStore.RetentionPolicyStore.On("GetIdsForDeletionByTableName", "Posts", 100).Return([]*model.RetentionIdsForDeletion{}, nil).Once()
+	mockStore.FileInfoStore.On("GetForPost", fileInfo.PostId, true, true, false).Return([]*model.FileInfo{fileInfo}, nil)
+	mockStore.FileInfoStore.On("PermanentDelete", mock.Anything, fileInfo.Id).Return(nil)
+	mockStore.ReactionStore.On("DeleteOrphanedRowsByIds", deletedPosts).Return(int64(3), nil)
+
+	mockStore.ThreadStore.On("PermanentDeleteBatchForRetentionPolicies", mock.Anything, model.RetentionPolicyCursor{}).Return(int64(4), doneCursor(), nil)
+	mockStore.ThreadStore.On("PermanentDeleteBatchThreadMembershipsForRetentionPolicies", mock.Anything, model.RetentionPolicyCursor{}).Return(int64(5), doneCursor(), nil)
+	mockStore.ChannelMemberHistoryStore.On("PermanentDeleteBatchForRetentionPolicies", mock.Anything, model.RetentionPolicyCursor{}).Return(int64(6), doneCursor(), nil)
+	mockStore.ThreadStore.On("DeleteOrphanedRows", 3000).Return(int64(0), nil)
+	mockStore.PreferenceStore.On("DeleteOrphanedRows", 3000).Return(int64(7), nil)
+	mockStore.RetentionPolicyStore.On("DeleteOrphanedRows", 3000).Return(int64(0), nil)
+
+	job := &model.Job{Id: model.NewId(), Type: model.JobTypeDataRetention}
+	require.NoError(t, w.doJob(mlog.CreateConsoleTestLogger(t), job))
+
+	assert.Equal(t, "1", job.Data[JobDataPostsDeleted])
+	assert.Equal(t, "1", job.Data[JobDataFilesDeleted])
+	assert.Equal(t, "3", job.Data[JobDataReactionsDeleted])
+	assert.Equal(t, "4", job.Data[JobDataThreadsDeleted])
+	assert.Equal(t, "5", job.Data[JobDataMembershipsDeleted])
+	assert.Equal(t, "6", job.Data[JobDataHistoryDeleted])
+	assert.Equal(t, "7", job.Data[JobDataOrphansDeleted])
+	assert.Equal(t, "1", job.Data[JobDataWarningCount], "the missing thumbnail should be a warning")
+	assert.Equal(t, "0", job.Data[JobDataFileCutoff], "file deletion is disabled")
+	assert.Equal(t, stageOrphans, job.Data[JobDataStage])
+
+	exists, err := backend.FileExists(fileInfo.Path)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestDataRetentionJobResumesFromStage(t *testing.T) {
+	w, mockStore, _ := setupWorker(t, nil)
+
+	mockStore.ThreadStore.On("DeleteOrphanedRows", 3000).Return(int64(0), nil)
+	mockStore.PreferenceStore.On("DeleteOrphanedRows", 3000).Return(int64(0), nil)
+	mockStore.RetentionPolicyStore.On("DeleteOrphanedRows", 3000).Return(int64(0), nil)
+
+	job := &model.Job{Id: model.NewId(), Type: model.JobTypeDataRetention, Data: model.StringMap{
+		JobDataStage:        stageOrphans,
+		JobDataNow:          "1000",
+		JobDataPostsDeleted: "10",
+	}}
+	require.NoError(t, w.doJob(mlog.CreateConsoleTestLogger(t), job))
+
+	assert.Equal(t, "10", job.Data[JobDataPostsDeleted])
+	assert.Equal(t, "1000", job.Data[JobDataNow])
+}
+
+func TestDataRetentionJobDryRun(t *testing.T) {
+	w, mockStore, backend := setupWorker(t, func(cfg *model.Config) {
+		*cfg.DataRetentionSettings.EnableMessageDeletion = true
+		*cfg.DataRetentionSettings.EnableFileDeletion = true
+	})
+
+	old := model.GetMillis() - 400*24*60*60*1000
+	files := []*model.FileForIndexing{
+		{FileInfo: model.FileInfo{Id: model.NewId(), CreatorId: model.NewId(), Path: "data/a.txt", CreateAt: old}},
+		{FileInfo: model.FileInfo{Id: model.NewId(), CreatorId: model.BookmarkFileOwner, Path: "data/b.txt", CreateAt: old + 1}},
+		{FileInfo: model.FileInfo{Id: model.NewId(), CreatorId: model.NewId(), Path: "data/c.txt", CreateAt: model.GetMillis()}},
+	}
+	_, err := backend.WriteFile(bytes.NewReader([]byte("a")), files[0].Path)
+	require.NoError(t, err)
+
+	mockStore.PostStore.On("CountForRetentionPolicies", mock.Anything).Return(int64(42), nil)
+	mockStore.RetentionPolicyStore.On("GetCount").Return(int64(2), nil)
+	mockStore.FileInfoStore.On("GetFilesBatchForIndexing", int64(0), "", true, 3000).Return(files, nil)
+
+	job := &model.Job{Id: model.NewId(), Type: model.JobTypeDataRetention, Data: model.StringMap{JobDataDryRun: "true"}}
+	require.NoError(t, w.doJob(mlog.CreateConsoleTestLogger(t), job))
+
+	assert.Equal(t, "42", job.Data[JobDataPostsToDelete])
+	assert.Equal(t, "1", job.Data[JobDataFilesToDelete], "bookmark files and files newer than the cutoff are kept")
+	assert.Equal(t, "2", job.Data[JobDataPoliciesCount])
+	assert.Empty(t, job.Data[JobDataFilesDeleted])
+
+	exists, err := backend.FileExists(files[0].Path)
+	require.NoError(t, err)
+	assert.True(t, exists, "a dry run should not delete anything")
+}
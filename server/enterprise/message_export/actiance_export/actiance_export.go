@@ -0,0 +1,231 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package actiance_export exports messages in the XML format imported by Actiance Vantage,
+// zipped together with the batch metadata and the attachments.
+package actiance_export
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+)
+
+const (
+	// XMLFileName is the name of the XML file inside an export archive.
+	XMLFileName = "actiance_export.xml"
+
+	xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// RootNode is the document element of an export.
+type RootNode struct {
+	XMLName  xml.Name       `xml:"FileDump"`
+	XsiNs    string         `xml:"xmlns:xsi,attr"`
+	Channels []Conversation `xml:"Conversation"`
+}
+
+// Conversation holds the events of one channel during a batch. Times are in milliseconds.
+type Conversation struct {
+	Perspective string `xml:"Perspective,attr"`
+	RoomId      string `xml:"RoomID"`
+	StartTime   int64  `xml:"StartTimeUTC"`
+	Events      []any
+	EndTime     int64 `xml:"EndTimeUTC"`
+}
+
+type ParticipantEntered struct {
+	XMLName          xml.Name `xml:"ParticipantEntered"`
+	UserEmail        string   `xml:"LoginName"`
+	UserType         string   `xml:"UserType"`
+	JoinTime         int64    `xml:"DateTimeUTC"`
+	CorporateEmailID string   `xml:"CorporateEmailID"`
+}
+
+type ParticipantLeft struct {
+	XMLName          xml.Name `xml:"ParticipantLeft"`
+	UserEmail        string   `xml:"LoginName"`
+	UserType         string   `xml:"UserType"`
+	LeaveTime        int64    `xml:"DateTimeUTC"`
+	CorporateEmailID string   `xml:"CorporateEmailID"`
+}
+
+type Message struct {
+	XMLName        xml.Name `xml:"Message"`
+	MessageId      string   `xml:"MessageId"`
+	UserEmail      string   `xml:"LoginName"`
+	UserType       string   `xml:"UserType"`
+	PostTime       int64    `xml:"DateTimeUTC"`
+	Message        string   `xml:"Content"`
+	PreviewsPost   string   `xml:"PreviewsPost,omitempty"`
+	UpdatedType    string   `xml:"UpdatedType,omitempty"`
+	UpdateTime     int64    `xml:"UpdateTimeUTC,omitempty"`
+	EditedNewMsgId string   `xml:"EditedNewMsgId,omitempty"`
+}
+
+type FileTransferStarted struct {
+	XMLName   xml.Name `xml:"FileTransferStarted"`
+	UserEmail string   `xml:"LoginName"`
+	StartTime int64    `xml:"DateTimeUTC"`
+	UserFile  string   `xml:"UserFileName"`
+	FilePath  string   `xml:"FileName"`
+}
+
+type FileTransferEnded struct {
+	XMLName   xml.Name `xml:"FileTransferEnded"`
+	UserEmail string   `xml:"LoginName"`
+	EndTime   int64    `xml:"DateTimeUTC"`
+	UserFile  string   `xml:"UserFileName"`
+	FilePath  string   `xml:"FileName"`
+	Status    string   `xml:"Status"`
+}
+
+// event is an element of a conversation. order breaks ties between events at the same time,
+// so that participants enter first and leave last.
+type event struct {
+	time    int64
+	order   int
+	element any
+}
+
+// ActianceExport writes a batch of messages to p.BatchPath on the export backend.
+func ActianceExport(rctx request.CTX, p shared.ExportParams) (shared.RunExportResults, error) {
+	start := time.Now()
+
+	data, err := shared.GetGenericExportData(p)
+	if err != nil {
+		return data.Results, errors.Wrap(err, "failed to get export data")
+	}
+	slices.SortFunc(data.Exports, func(a, b shared.ChannelExport) int { return cmp.Compare(a.ChannelId, b.ChannelId) })
+	results := data.Results
+	results.NumChannels = len(data.Exports)
+	results.ProcessingPostsMs = time.Since(start).Milliseconds()
+
+	start = time.Now()
+	contents, err := writeXML(data.Exports)
+	if err != nil {
+		return results, err
+	}
+	results.ProcessingXmlMs = time.Since(start).Milliseconds()
+
+	start = time.Now()
+	modified := time.UnixMilli(p.BatchEndTime)
+	err = shared.WriteExportArchive(p.ExportBackend, p.BatchPath, func(zw *zip.Writer) error {
+		if err := shared.WriteArchiveFile(zw, XMLFileName, modified, bytes.NewReader(contents)); err != nil {
+			return err
+		}
+		if err := shared.WriteArchiveMetadata(zw, data.Metadata, modified); err != nil {
+			return err
+		}
+		warnings, err := shared.CopyAttachments(rctx, zw, p.FileAttachmentBackend, shared.UploadedFiles(data.Exports), modified)
+		results.NumWarnings += warnings
+		return err
+	})
+	results.TransferringZipMs = time.Since(start).Milliseconds()
+	if err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
+// writeXML returns the XML document for the channels of a batch.
+func writeXML(exports []shared.ChannelExport) ([]byte, error) {
+	root := RootNode{XsiNs: xsiNamespace}
+	for _, channel := range exports {
+		root.Channels = append(root.Channels, conversation(channel))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(root); err != nil {
+		return nil, errors.Wrap(err, "failed to encode Actiance XML")
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+func conversation(channel shared.ChannelExport) Conversation {
+	var events []event
+
+	for _, join := range channel.JoinEvents {
+		events = append(events, event{join.JoinTime, 0, ParticipantEntered{
+			UserEmail:        join.UserEmail,
+			UserType:         string(join.UserType),
+			JoinTime:         join.JoinTime,
+			CorporateEmailID: join.UserEmail,
+		}})
+	}
+
+	for _, post := range channel.Posts {
+		events = append(events, event{*post.PostCreateAt, 1, Message{
+			MessageId:      *post.PostId,
+			UserEmail:      *post.UserEmail,
+			UserType:       string(post.UserType),
+			PostTime:       *post.PostCreateAt,
+			Message:        post.Message,
+			PreviewsPost:   post.PreviewsPost,
+			UpdatedType:    string(post.UpdatedType),
+			UpdateTime:     post.UpdateAt,
+			EditedNewMsgId: post.EditedNewMsgId,
+		}})
+	}
+
+	for _, upload := range channel.UploadStarts {
+		events = append(events, event{upload.UploadStartTime, 2, FileTransferStarted{
+			UserEmail: upload.UserEmail,
+			StartTime: upload.UploadStartTime,
+			UserFile:  path.Base(upload.FileInfo.Name),
+			FilePath:  shared.AttachmentArchivePath(upload.FileInfo),
+		}})
+	}
+
+	for _, upload := range channel.UploadStops {
+		events = append(events, event{upload.UploadStopTime, 3, FileTransferEnded{
+			UserEmail: upload.UserEmail,
+			EndTime:   upload.UploadStopTime,
+			UserFile:  path.Base(upload.FileInfo.Name),
+			FilePath:  shared.AttachmentArchivePath(upload.FileInfo),
+			Status:    upload.Status,
+		}})
+	}
+
+	for _, leave := range channel.LeaveEvents {
+		events = append(events, event{leave.LeaveTime, 4, ParticipantLeft{
+			UserEmail:        leave.UserEmail,
+			UserType:         string(leave.UserType),
+			LeaveTime:        leave.LeaveTime,
+			CorporateEmailID: leave.UserEmail,
+		}})
+	}
+
+	slices.SortStableFunc(events, func(a, b event) int {
+		return cmp.Or(cmp.Compare(a.time, b.time), cmp.Compare(a.order, b.order))
+	})
+
+	c := Conversation{
+		Perspective: channel.DisplayName,
+		RoomId:      roomID(channel),
+		StartTime:   channel.StartTime,
+		EndTime:     channel.EndTime,
+	}
+	for _, e := range events {
+		c.Events = append(c.Events, e.element)
+	}
+	return c
+}
+
+func roomID(channel shared.ChannelExport) string {
+	return shared.ChannelTypeDisplayName(channel.ChannelType) + " - " + channel.ChannelId
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package actiance_export
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/exporttest"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared/sharedtest"
+)
+
+func TestActianceExport(t *testing.T) {
+	p := exporttest.ExportParams(t)
+
+	results, err := ActianceExport(request.TestContext(t), p)
+	require.NoError(t, err)
+	assert.Equal(t, 3, results.NumChannels)
+	assert.Equal(t, 1, results.NumWarnings, "the missing attachment should be a warning")
+
+	files := exporttest.ReadArchive(t, p.ExportBackend, exporttest.BatchPath)
+	require.Len(t, files, 3)
+	attachment := sharedtest.Attachments()[0]
+	assert.Equal(t, exporttest.AttachmentContents, string(files[shared.AttachmentArchivePath(attachment)]))
+	exporttest.AssertGolden(t, "actiance_export.xml", files[XMLFileName])
+	exporttest.AssertGolden(t, "metadata.json", files[shared.MetadataFileName])
+}
+
+func TestActianceExportParticipantsOnly(t *testing.T) {
+	p := exporttest.ExportParams(t)
+	p.Posts = nil
+
+	results, err := ActianceExport(request.TestContext(t), p)
+	require.NoError(t, err)
+	assert.Equal(t, 2, results.NumChannels, "only the channels where people joined or left")
+	assert.Zero(t, results.NumWarnings)
+
+	files := exporttest.ReadArchive(t, p.ExportBackend, exporttest.BatchPath)
+	assert.Len(t, files, 2)
+	assert.Contains(t, string(files[XMLFileName]), "<ParticipantEntered>")
+	assert.NotContains(t, string(files[XMLFileName]), "<Message>")
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package csv_export exports messages as a CSV file with one row per post, file and channel
+// membership change, zipped together with the batch metadata and the attachments.
+package csv_export
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+)
+
+const (
+	// PostsFileName is the name of the CSV file inside an export archive.
+	PostsFileName = "posts.csv"
+
+	rowTypeJoin       = "join"
+	rowTypeLeave      = "leave"
+	rowTypeAttachment = "attachment"
+)
+
+var header = []string{
+	"Post Creation Time",
+	"Team Id",
+	"Team Name",
+	"Team Display Name",
+	"Channel Id",
+	"Channel Name",
+	"Channel Display Name",
+	"Channel Type",
+	"User Id",
+	"User Email",
+	"Username",
+	"Post Id",
+	"Edited By Post Id",
+	"Replied to Post Id",
+	"Post Message",
+	"Post Type",
+	"User Type",
+	"Previews Post Id",
+	"Update Type",
+	"Update Time",
+}
+
+// row is one line of the CSV file. order breaks ties between rows with the same time so that
+// joins come first and leaves last.
+type row struct {
+	time   int64
+	order  int
+	fields []string
+}
+
+// CsvExport writes a batch of messages to p.BatchPath on the export backend.
+func CsvExport(rctx request.CTX, p shared.ExportParams) (shared.RunExportResults, error) {
+	start := time.Now()
+
+	data, err := shared.GetGenericExportData(p)
+	if err != nil {
+		return data.Results, errors.Wrap(err, "failed to get export data")
+	}
+	slices.SortFunc(data.Exports, func(a, b shared.ChannelExport) int { return cmp.Compare(a.ChannelId, b.ChannelId) })
+	results := data.Results
+	results.NumChannels = len(data.Exports)
+	results.ProcessingPostsMs = time.Since(start).Milliseconds()
+
+	start = time.Now()
+	contents, err := writeCSV(data.Exports)
+	if err != nil {
+		return results, err
+	}
+	results.ProcessingXmlMs = time.Since(start).Milliseconds()
+
+	start = time.Now()
+	modified := time.UnixMilli(p.BatchEndTime)
+	err = shared.WriteExportArchive(p.ExportBackend, p.BatchPath, func(zw *zip.Writer) error {
+		if err := shared.WriteArchiveFile(zw, PostsFileName, modified, bytes.NewReader(contents)); err != nil {
+			return err
+		}
+		if err := shared.WriteArchiveMetadata(zw, data.Metadata, modified); err != nil {
+			return err
+		}
+		warnings, err := shared.CopyAttachments(rctx, zw, p.FileAttachmentBackend, shared.UploadedFiles(data.Exports), modified)
+		results.NumWarnings += warnings
+		return err
+	})
+	results.TransferringZipMs = time.Since(start).Milliseconds()
+	if err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
+// writeCSV returns the CSV file for the channels of a batch, with the rows of each channel
+// ordered by time.
+func writeCSV(exports []shared.ChannelExport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return nil, errors.Wrap(err, "failed to write CSV header")
+	}
+
+	for _, channel := range exports {
+		rows := channelRows(channel)
+		slices.SortStableFunc(rows, func(a, b row) int {
+			return cmp.Or(cmp.Compare(a.time, b.time), cmp.Compare(a.order, b.order))
+		})
+		for _, r := range rows {
+			if err := w.Write(r.fields); err != nil {
+				return nil, errors.Wrap(err, "failed to write CSV row")
+			}
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, errors.Wrap(err, "failed to write CSV")
+	}
+	return buf.Bytes(), nil
+}
+
+func channelRows(channel shared.ChannelExport) []row {
+	var rows []row
+
+	channelFields := func(userID, email, username string) []string {
+		return []string{
+			channel.TeamId,
+			channel.TeamName,
+			channel.TeamDisplayName,
+			channel.ChannelId,
+			channel.ChannelName,
+			channel.DisplayName,
+			shared.ChannelTypeDisplayName(channel.ChannelType),
+			userID,
+			email,
+			username,
+		}
+	}
+
+	for _, join := range channel.JoinEvents {
+		fields := append([]string{formatTime(join.JoinTime)}, channelFields(join.UserId, join.UserEmail, join.Username)...)
+		fields = append(fields, "", "", "", "", rowTypeJoin, string(join.UserType), "", "", "")
+		rows = append(rows, row{time: join.JoinTime, order: 0, fields: fields})
+	}
+
+	for _, post := range channel.Posts {
+		fields := append([]string{formatTime(*post.PostCreateAt)}, channelFields(*post.UserId, *post.UserEmail, *post.Username)...)
+		updateTime := ""
+		if post.UpdatedType != "" {
+			updateTime = formatTime(post.UpdateAt)
+		}
+		fields = append(fields,
+			*post.PostId,
+			post.EditedNewMsgId,
+			model.SafeDereference(post.PostRootId),
+			post.Message,
+			model.SafeDereference(post.PostType),
+			string(post.UserType),
+			post.PreviewsPost,
+			string(post.UpdatedType),
+			updateTime,
+		)
+		rows = append(rows, row{time: *post.PostCreateAt, order: 1, fields: fields})
+
+		for _, upload := range post.AttachmentCreates {
+			fields := append([]string{formatTime(upload.UploadStartTime)}, channelFields(*post.UserId, upload.UserEmail, *post.Username)...)
+			fields = append(fields,
+				*post.PostId,
+				"",
+				model.SafeDereference(post.PostRootId),
+				shared.AttachmentArchivePath(upload.FileInfo),
+				rowTypeAttachment,
+				string(post.UserType),
+				"",
+				"",
+				"",
+			)
+			rows = append(rows, row{time: upload.UploadStartTime, order: 2, fields: fields})
+		}
+	}
+
+	for _, leave := range channel.LeaveEvents {
+		fields := append([]string{formatTime(leave.LeaveTime)}, channelFields(leave.UserId, leave.UserEmail, leave.Username)...)
+		fields = append(fields, "", "", "", "", rowTypeLeave, string(leave.UserType), "", "", "")
+		rows = append(rows, row{time: leave.LeaveTime, order: 3, fields: fields})
+	}
+
+	return rows
+}
+
+func formatTime(millis int64) string {
+	return strconv.FormatInt(millis, 10)
+}
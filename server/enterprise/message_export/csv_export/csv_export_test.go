@@ -0,0 +1,34 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package csv_export
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/exporttest"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared/sharedtest"
+)
+
+func TestCsvExport(t *testing.T) {
+	p := exporttest.ExportParams(t)
+
+	results, err := CsvExport(request.TestContext(t), p)
+	require.NoError(t, err)
+	assert.Equal(t, 3, results.NumChannels)
+	assert.Equal(t, 1, results.CreatedPosts)
+	assert.Equal(t, 2, results.UploadedFiles)
+	assert.Equal(t, 1, results.NumWarnings, "the missing attachment should be a warning")
+
+	files := exporttest.ReadArchive(t, p.ExportBackend, exporttest.BatchPath)
+	require.Len(t, files, 3)
+	attachment := sharedtest.Attachments()[0]
+	assert.Equal(t, exporttest.AttachmentContents, string(files[shared.AttachmentArchivePath(attachment)]))
+	exporttest.AssertGolden(t, "posts.csv", files[PostsFileName])
+	exporttest.AssertGolden(t, "metadata.json", files[shared.MetadataFileName])
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package exporttest builds an export batch from the sharedtest fixtures and provides the
+// helpers to check what an exporter made of it against golden files.
+package exporttest
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest/mocks"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared/sharedtest"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+const (
+	// BatchPath is where ExportParams asks the exporter to write the batch.
+	BatchPath = "export/batch001.zip"
+
+	// AttachmentContents is the first attachment of the post. The second one is missing from
+	// the file backend.
+	AttachmentContents = "the quarterly report"
+)
+
+// testStore serves the attachments of the post from memory.
+type testStore struct {
+	*mocks.Store
+	files map[string][]*model.FileInfo
+}
+
+func (s testStore) FileInfo() shared.MEFileInfoStore {
+	return s
+}
+
+func (s testStore) GetForPost(postID string, readFromMaster, includeDeleted, allowFromCache bool) ([]*model.FileInfo, error) {
+	return s.files[postID], nil
+}
+
+// ChannelMetadata returns the metadata of the direct channel of sharedtest.Post and of the
+// channels of sharedtest.ChannelMemberHistories.
+func ChannelMetadata() map[string]*shared.MetadataChannel {
+	channel := func(id, teamID, name string, channelType model.ChannelType) *shared.MetadataChannel {
+		return &shared.MetadataChannel{
+			TeamId:             model.NewPointer(teamID),
+			ChannelId:          id,
+			ChannelName:        name,
+			ChannelDisplayName: name,
+			ChannelType:        channelType,
+			RoomId:             fmt.Sprintf("%v - %v", shared.ChannelTypeDisplayName(channelType), id),
+			StartTime:          sharedtest.StartTime,
+			EndTime:            sharedtest.EndTime,
+		}
+	}
+	return map[string]*shared.MetadataChannel{
+		sharedtest.PostChannelId:     channel(sharedtest.PostChannelId, "", "Test", model.ChannelTypeDirect),
+		sharedtest.HistoryChannel1Id: channel(sharedtest.HistoryChannel1Id, "test", "test", model.ChannelTypeOpen),
+		sharedtest.HistoryChannel2Id: channel(sharedtest.HistoryChannel2Id, "test", "test", model.ChannelTypeOpen),
+	}
+}
+
+// ExportParams returns a batch over the sharedtest fixtures: the post with its two attachments
+// and the two channels where users join and leave. The file and export backends are local
+// directories owned by the test.
+func ExportParams(t *testing.T) shared.ExportParams {
+	t.Helper()
+
+	fileBackend, err := filestore.NewFileBackend(filestore.FileBackendSettings{
+		DriverName: model.ImageDriverLocal,
+		Directory:  t.TempDir(),
+	})
+	require.NoError(t, err)
+	exportBackend, err := filestore.NewFileBackend(filestore.FileBackendSettings{
+		DriverName: model.ImageDriverLocal,
+		Directory:  t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	attachments := sharedtest.Attachments()
+	_, err = fileBackend.WriteFile(bytes.NewReader([]byte(AttachmentContents)), attachments[0].Path)
+	require.NoError(t, err)
+
+	post := sharedtest.Post()
+	return shared.ExportParams{
+		ChannelMetadata:        ChannelMetadata(),
+		Posts:                  []*model.MessageExport{&post},
+		ChannelMemberHistories: sharedtest.ChannelMemberHistories(),
+		JobStartTime:           sharedtest.StartTime,
+		BatchPath:              BatchPath,
+		BatchStartTime:         sharedtest.StartTime,
+		BatchEndTime:           sharedtest.EndTime,
+		Config:                 &model.Config{},
+		Db: testStore{
+			Store: &mocks.Store{},
+			files: map[string][]*model.FileInfo{sharedtest.PostId: attachments},
+		},
+		FileAttachmentBackend: fileBackend,
+		ExportBackend:         exportBackend,
+	}
+}
+
+// ReadArchive returns the contents of the files of the zip archive at archivePath on backend,
+// by name.
+func ReadArchive(t *testing.T, backend filestore.FileBackend, archivePath string) map[string][]byte {
+	t.Helper()
+
+	data, err := backend.ReadFile(archivePath)
+	require.NoError(t, err)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string][]byte, len(zr.File))
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		contents, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		files[f.Name] = contents
+	}
+	return files
+}
+
+// AssertGolden compares actual with testdata/<name>.golden, rewriting the golden file instead
+// when the tests run with -update.
+func AssertGolden(t *testing.T, name string, actual []byte) {
+	t.Helper()
+
+	goldenPath := filepath.Join("testdata", name+".golden")
+	if *update {
+		require.NoError(t, os.MkdirAll("testdata", 0755))
+		require.NoError(t, os.WriteFile(goldenPath, actual, 0644))
+	}
+
+	expected, err := os.ReadFile(goldenPath)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(actual))
+}
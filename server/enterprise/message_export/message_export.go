@@ -0,0 +1,91 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package message_export runs compliance exports in the CSV and Actiance XML formats for
+// builds without the enterprise modules.
+package message_export
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+	ejobs "github.com/mattermost/mattermost/server/v8/einterfaces/jobs"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+)
+
+// synchronizeJobPollInterval is how often StartSynchronizeJob checks on the job it created.
+const synchronizeJobPollInterval = time.Second
+
+func init() {
+	app.RegisterJobsMessageExportJobInterface(func(s *app.Server) ejobs.MessageExportJobInterface {
+		return &MessageExportJobInterfaceImpl{Server: s}
+	})
+	app.RegisterMessageExportInterface(func(a *app.App) einterfaces.MessageExportInterface {
+		return &MessageExportInterfaceImpl{Server: a.Srv()}
+	})
+}
+
+type MessageExportJobInterfaceImpl struct {
+	Server *app.Server
+}
+
+func (m *MessageExportJobInterfaceImpl) MakeWorker() model.Worker {
+	w := &worker{
+		jobServer:     m.Server.Jobs,
+		store:         shared.NewMessageExportStore(m.Server.Store()),
+		fileBackend:   m.Server.FileBackend,
+		exportBackend: m.Server.ExportFileBackend,
+	}
+	return jobs.NewSimpleWorker("MessageExport", m.Server.Jobs, w.doJob, isEnabled)
+}
+
+func (m *MessageExportJobInterfaceImpl) MakeScheduler() ejobs.Scheduler {
+	startTime := func(cfg *model.Config) *time.Time {
+		parsedTime, err := time.Parse("15:04", *cfg.MessageExportSettings.DailyRunTime)
+		if err == nil {
+			return &parsedTime
+		}
+		return nil
+	}
+	return jobs.NewDailyScheduler(m.Server.Jobs, model.JobTypeMessageExport, startTime, isEnabled)
+}
+
+type MessageExportInterfaceImpl struct {
+	Server *app.Server
+}
+
+// StartSynchronizeJob creates an export job starting at exportFromTimestamp and waits for it to
+// finish or for the request context to be done.
+func (m *MessageExportInterfaceImpl) StartSynchronizeJob(rctx request.CTX, exportFromTimestamp int64) (*model.Job, *model.AppError) {
+	job, appErr := m.Server.Jobs.CreateJob(rctx, model.JobTypeMessageExport, map[string]string{
+		shared.JobDataJobStartTime: strconv.FormatInt(exportFromTimestamp, 10),
+		shared.JobDataInitiatedBy:  "cli",
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	ticker := time.NewTicker(synchronizeJobPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rctx.Context().Done():
+			return job, model.NewAppError("StartSynchronizeJob", "ent.message_export.synchronize_job.timeout.app_error", nil, "", http.StatusRequestTimeout).Wrap(rctx.Context().Err())
+		case <-ticker.C:
+			job, appErr = m.Server.Jobs.GetJob(rctx, job.Id)
+			if appErr != nil {
+				return nil, appErr
+			}
+			switch job.Status {
+			case model.JobStatusSuccess, model.JobStatusWarning, model.JobStatusError, model.JobStatusCanceled:
+				return job, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package shared
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+const (
+	// MetadataFileName is the name of the batch metadata inside an export archive.
+	MetadataFileName = "metadata.json"
+
+	// AttachmentsDir is the directory of an export archive that holds the file attachments.
+	AttachmentsDir = "files"
+)
+
+// WriteExportArchive streams a zip archive to batchPath on the export backend. fill adds the
+// entries; the archive is only complete once fill returns without error.
+func WriteExportArchive(exportBackend filestore.FileBackend, batchPath string, fill func(zw *zip.Writer) error) error {
+	pr, pw := io.Pipe()
+
+	go func() {
+		zw := zip.NewWriter(pw)
+		err := fill(zw)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err) // CloseWithError(nil) is the same as Close
+	}()
+
+	_, err := exportBackend.WriteFile(pr, batchPath)
+	// Unblock the writer if the backend stopped reading early.
+	pr.CloseWithError(err)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write export archive %s", batchPath)
+	}
+	return nil
+}
+
+// WriteArchiveFile adds a file with the given contents to an export archive.
+func WriteArchiveFile(zw *zip.Writer, name string, modified time.Time, contents io.Reader) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s in export archive", name)
+	}
+	if _, err := io.Copy(w, contents); err != nil {
+		return errors.Wrapf(err, "failed to write %s to export archive", name)
+	}
+	return nil
+}
+
+// WriteArchiveMetadata adds the batch metadata to an export archive as JSON.
+func WriteArchiveMetadata(zw *zip.Writer, metadata Metadata, modified time.Time) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     MetadataFileName,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create metadata in export archive")
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(metadata); err != nil {
+		return errors.Wrap(err, "failed to write metadata to export archive")
+	}
+	return nil
+}
+
+// AttachmentArchivePath returns where an attachment is stored in an export archive.
+func AttachmentArchivePath(fileInfo *model.FileInfo) string {
+	return path.Join(AttachmentsDir, fileInfo.PostId, fileInfo.Id+"-"+path.Base(fileInfo.Name))
+}
+
+// CopyAttachments copies the attachments of a batch into an export archive. Attachments missing
+// from the file backend are logged and counted as warnings rather than failing the batch.
+func CopyAttachments(rctx request.CTX, zw *zip.Writer, backend filestore.FileBackend, files []*model.FileInfo, modified time.Time) (int, error) {
+	var warnings int
+	for _, fileInfo := range files {
+		reader, err := backend.Reader(fileInfo.Path)
+		if err != nil {
+			rctx.Logger().Warn(MissingFileMessageDuringBackendRead, mlog.String("post_id", fileInfo.PostId), mlog.String("file_id", fileInfo.Id), mlog.String("file_path", fileInfo.Path), mlog.Err(err))
+			warnings++
+			continue
+		}
+
+		err = WriteArchiveFile(zw, AttachmentArchivePath(fileInfo), modified, reader)
+		reader.Close()
+		if err != nil {
+			return warnings, err
+		}
+	}
+	return warnings, nil
+}
+
+// UploadedFiles returns the attachments uploaded in a batch, each once.
+func UploadedFiles(exports []ChannelExport) []*model.FileInfo {
+	seen := map[string]bool{}
+	var files []*model.FileInfo
+	for _, channel := range exports {
+		for _, upload := range channel.UploadStarts {
+			if !seen[upload.FileInfo.Id] {
+				seen[upload.FileInfo.Id] = true
+				files = append(files, upload.FileInfo)
+			}
+		}
+	}
+	return files
+}
@@ -12,6 +12,8 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/platform/services/filededup"
 	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
 	"github.com/mattermost/mattermost/server/v8/platform/shared/templates"
 
@@ -561,7 +563,7 @@ func GetBatchPath(exportDir string, prevPostUpdateAt int64, lastPostUpdateAt int
 }
 
 // GetExportBackend returns the file backend where the export will be created.
-func GetExportBackend(rctx request.CTX, config *model.Config) (filestore.FileBackend, error) {
+func GetExportBackend(rctx request.CTX, config *model.Config, blobStore store.FileBlobStore) (filestore.FileBackend, error) {
 	insecure := config.ServiceSettings.EnableInsecureOutgoingConnections
 	skipVerify := insecure != nil && *insecure
 
@@ -575,7 +577,7 @@ func GetExportBackend(rctx request.CTX, config *model.Config) (filestore.FileBac
 		return backend, nil
 	}
 
-	backend, err := filestore.NewFileBackend(filestore.NewFileBackendSettingsFromConfig(&config.FileSettings, true, skipVerify))
+	backend, err := filededup.NewFileBackend(filestore.NewFileBackendSettingsFromConfig(&config.FileSettings, true, skipVerify), &config.FileSettings, blobStore)
 	if err != nil {
 		return nil, err
 	}
@@ -585,10 +587,10 @@ func GetExportBackend(rctx request.CTX, config *model.Config) (filestore.FileBac
 // GetFileAttachmentBackend returns the file backend where file attachments are
 // located for messages that will be exported. This may be the same backend
 // where the export will be created.
-func GetFileAttachmentBackend(rctx request.CTX, config *model.Config) (filestore.FileBackend, error) {
+func GetFileAttachmentBackend(rctx request.CTX, config *model.Config, blobStore store.FileBlobStore) (filestore.FileBackend, error) {
 	insecure := config.ServiceSettings.EnableInsecureOutgoingConnections
 
-	backend, err := filestore.NewFileBackend(filestore.NewFileBackendSettingsFromConfig(&config.FileSettings, true, insecure != nil && *insecure))
+	backend, err := filededup.NewFileBackend(filestore.NewFileBackendSettingsFromConfig(&config.FileSettings, true, insecure != nil && *insecure), &config.FileSettings, blobStore)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,65 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package sharedtest holds the message export fixtures that the shared package tests check
+// directly and that the exporters' golden files are built from.
+package sharedtest
+
+import (
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// HistoryChannel1Id and HistoryChannel2Id are the channels of ChannelMemberHistories.
+	HistoryChannel1Id = "good-request-1"
+	HistoryChannel2Id = "good-request-2"
+	// PostChannelId is the direct channel of Post.
+	PostChannelId = "Test"
+	PostId        = "test"
+
+	// StartTime and EndTime bound the export period the fixtures cover.
+	StartTime int64 = 1
+	EndTime   int64 = 7
+)
+
+// ChannelMemberHistories returns two channels where one user joins and leaves within the
+// period and two others are still members at its end.
+func ChannelMemberHistories() map[string][]*model.ChannelMemberHistoryResult {
+	return map[string][]*model.ChannelMemberHistoryResult{
+		HistoryChannel1Id: {
+			{ChannelId: HistoryChannel1Id, JoinTime: 1, UserId: "test1", UserEmail: "test1", Username: "test1"},
+			{ChannelId: HistoryChannel1Id, JoinTime: 2, LeaveTime: model.NewPointer(int64(3)), UserId: "test2", UserEmail: "test2", Username: "test2"},
+			{ChannelId: HistoryChannel1Id, JoinTime: 3, UserId: "test3", UserEmail: "test3", Username: "test3"},
+		},
+		HistoryChannel2Id: {
+			{ChannelId: HistoryChannel2Id, JoinTime: 4, UserId: "test4", UserEmail: "test4", Username: "test4"},
+			{ChannelId: HistoryChannel2Id, JoinTime: 5, LeaveTime: model.NewPointer(int64(6)), UserId: "test5", UserEmail: "test5", Username: "test5"},
+			{ChannelId: HistoryChannel2Id, JoinTime: 6, UserId: "test6", UserEmail: "test6", Username: "test6"},
+		},
+	}
+}
+
+// Post returns a message with the two Attachments in a direct channel.
+func Post() model.MessageExport {
+	return model.MessageExport{
+		PostId:             model.NewPointer(PostId),
+		ChannelId:          model.NewPointer(PostChannelId),
+		ChannelDisplayName: model.NewPointer("Test"),
+		PostCreateAt:       model.NewPointer(int64(1)),
+		PostUpdateAt:       model.NewPointer(int64(1)),
+		PostMessage:        model.NewPointer("Some message"),
+		UserEmail:          model.NewPointer("test@test.com"),
+		UserId:             model.NewPointer("test"),
+		Username:           model.NewPointer("test"),
+		ChannelType:        model.NewPointer(model.ChannelTypeDirect),
+		PostFileIds:        []string{"12345", "54321"},
+	}
+}
+
+// Attachments returns the file infos of Post.
+func Attachments() []*model.FileInfo {
+	return []*model.FileInfo{
+		{Name: "test", Id: "12345", Path: "filename.txt"},
+		{Name: "test2", Id: "54321", Path: "filename2.txt"},
+	}
+}
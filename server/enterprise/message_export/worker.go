@@ -0,0 +1,231 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package message_export
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/actiance_export"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/csv_export"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+// JobDataProgressMessage is shown in the System Console while the channel activity of the
+// export period is gathered.
+const JobDataProgressMessage = "progress_message"
+
+type exportFunc func(rctx request.CTX, p shared.ExportParams) (shared.RunExportResults, error)
+
+// exporters are the export formats available in this build. Global Relay is only available
+// with the enterprise modules.
+var exporters = map[string]exportFunc{
+	model.ComplianceExportTypeCsv:      csv_export.CsvExport,
+	model.ComplianceExportTypeActiance: actiance_export.ActianceExport,
+}
+
+func isEnabled(cfg *model.Config) bool {
+	if !*cfg.MessageExportSettings.EnableExport {
+		return false
+	}
+	_, ok := exporters[*cfg.MessageExportSettings.ExportFormat]
+	return ok
+}
+
+type worker struct {
+	jobServer     *jobs.JobServer
+	store         shared.MessageExportStore
+	fileBackend   func() filestore.FileBackend
+	exportBackend func() filestore.FileBackend
+}
+
+// initJobData fills in the job data the first time a job is claimed. A job claimed again after
+// a restart carries on from its last batch.
+func (w *worker) initJobData(job *model.Job, cfg *model.Config) (shared.JobData, error) {
+	data, err := shared.StringMapToJobDataWithZeroValues(job.Data)
+	if err != nil {
+		return data, err
+	}
+	if _, ok := job.Data[shared.JobDataJobEndTime]; ok {
+		data.ExportPeriodStartTime = data.JobStartTime
+		return data, nil
+	}
+
+	settings := cfg.MessageExportSettings
+	if data.ExportType == "" {
+		data.ExportType = *settings.ExportFormat
+	}
+	if _, ok := job.Data[shared.JobDataJobStartTime]; !ok {
+		data.JobStartTime, data.JobStartId, err = w.previousJobEnd(*settings.ExportFromTimestamp)
+		if err != nil {
+			return data, err
+		}
+	}
+	data.JobEndTime = job.CreateAt
+	data.BatchStartTime = data.JobStartTime
+	data.BatchStartId = data.JobStartId
+	data.BatchSize = *settings.BatchSize
+	data.ChannelBatchSize = *settings.ChannelBatchSize
+	data.ChannelHistoryBatchSize = *settings.ChannelHistoryBatchSize
+	data.IsDownloadable = *settings.DownloadExportResults
+	data.ExportDir = path.Join(model.ComplianceExportPath,
+		fmt.Sprintf("%s-%s", time.UnixMilli(job.CreateAt).Format(model.ComplianceExportDirectoryFormat), job.Id))
+	data.ExportPeriodStartTime = data.JobStartTime
+
+	return data, nil
+}
+
+// previousJobEnd returns where the last successful export stopped, or exportFromTimestamp if
+// there was none.
+func (w *worker) previousJobEnd(exportFromTimestamp int64) (int64, string, error) {
+	lastJob, appErr := w.jobServer.GetLastSuccessfulJobByType(model.JobTypeMessageExport)
+	if appErr != nil {
+		return 0, "", appErr
+	}
+	if lastJob == nil {
+		return exportFromTimestamp, "", nil
+	}
+
+	lastData, err := shared.StringMapToJobDataWithZeroValues(lastJob.Data)
+	if err != nil {
+		return 0, "", err
+	}
+	return lastData.BatchStartTime, lastData.BatchStartId, nil
+}
+
+func (w *worker) saveJobData(job *model.Job, data shared.JobData) {
+	if job.Data == nil {
+		job.Data = model.StringMap{}
+	}
+	for key, value := range shared.JobDataToStringMap(data) {
+		job.Data[key] = value
+	}
+}
+
+func (w *worker) doJob(logger mlog.LoggerIFace, job *model.Job) error {
+	defer w.jobServer.HandleJobPanic(logger, job)
+
+	rctx := request.EmptyContext(logger)
+	data, err := w.initJobData(job, w.jobServer.Config())
+	if err != nil {
+		return errors.Wrap(err, "failed to read the job data")
+	}
+	export, ok := exporters[data.ExportType]
+	if !ok {
+		return fmt.Errorf("unsupported export format %q", data.ExportType)
+	}
+
+	w.saveJobData(job, data)
+	if appErr := w.jobServer.UpdateInProgressJobData(job); appErr != nil {
+		return appErr
+	}
+
+	reportProgress := func(message string) {
+		job.Data[JobDataProgressMessage] = message
+		if appErr := w.jobServer.UpdateInProgressJobData(job); appErr != nil {
+			logger.Warn("Failed to update the progress message of the job", mlog.Err(appErr))
+		}
+	}
+	data, err = shared.GetInitialExportPeriodData(rctx, w.store, data, reportProgress)
+	if err != nil {
+		return errors.Wrap(err, "failed to calculate channel exports")
+	}
+
+	for {
+		posts, cursor, err := w.store.Compliance().MessageExport(rctx, data.Cursor, data.BatchSize)
+		if err != nil {
+			return errors.Wrap(err, "failed to select message export data")
+		}
+		if len(posts) == 0 {
+			break
+		}
+
+		batchEndTime := *posts[len(posts)-1].PostUpdateAt
+		if err := w.exportBatch(rctx, export, &data, posts, batchEndTime); err != nil {
+			return err
+		}
+		data.Cursor = cursor
+		data.BatchStartId = cursor.LastPostId
+
+		w.saveJobData(job, data)
+		if appErr := w.jobServer.SetJobProgress(job, progress(data)); appErr != nil {
+			return appErr
+		}
+
+		if len(posts) < data.BatchSize {
+			break
+		}
+	}
+
+	// Channels whose only activity after the last post is people joining or leaving still
+	// need to be exported.
+	for _, histories := range data.ChannelMemberHistories {
+		if shared.ChannelHasActivity(histories, data.BatchStartTime, data.JobEndTime) {
+			if err := w.exportBatch(rctx, export, &data, nil, data.JobEndTime); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	w.saveJobData(job, data)
+	if data.WarningCount > 0 {
+		logger.Warn("Message export finished with warnings", mlog.Int("warning_count", data.WarningCount))
+	}
+	logger.Info("Message export finished", mlog.Int("messages_exported", data.MessagesExported), mlog.Int("batches", data.BatchNumber))
+
+	return nil
+}
+
+// exportBatch writes the posts updated between the start of the batch and batchEndTime and
+// moves the start of the next batch to batchEndTime.
+func (w *worker) exportBatch(rctx request.CTX, export exportFunc, data *shared.JobData, posts []*model.MessageExport, batchEndTime int64) error {
+	data.BatchNumber++
+	batchPath := shared.GetBatchPath(data.ExportDir, data.BatchStartTime, batchEndTime, data.BatchNumber)
+
+	results, err := export(rctx, shared.ExportParams{
+		ExportType:             data.ExportType,
+		ChannelMetadata:        data.ChannelMetadata,
+		Posts:                  posts,
+		ChannelMemberHistories: data.ChannelMemberHistories,
+		JobStartTime:           data.JobStartTime,
+		BatchPath:              batchPath,
+		BatchStartTime:         data.BatchStartTime,
+		BatchEndTime:           batchEndTime,
+		Config:                 w.jobServer.Config(),
+		Db:                     w.store,
+		FileAttachmentBackend:  w.fileBackend(),
+		ExportBackend:          w.exportBackend(),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to export batch %d", data.BatchNumber)
+	}
+
+	rctx.Logger().Debug("Exported message export batch",
+		mlog.String("batch_path", batchPath),
+		mlog.Int("posts", len(posts)),
+		mlog.Int("channels", results.NumChannels),
+		mlog.Int("warnings", results.NumWarnings),
+	)
+
+	data.MessagesExported += len(posts)
+	data.WarningCount += results.NumWarnings
+	data.BatchStartTime = batchEndTime
+	return nil
+}
+
+func progress(data shared.JobData) int64 {
+	if data.TotalPostsExpected <= 0 {
+		return 0
+	}
+	return min(int64(data.MessagesExported)*100/int64(data.TotalPostsExpected), 99)
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package message_export
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest"
+	"github.com/mattermost/mattermost/server/v8/channels/utils/testutils"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/csv_export"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/exporttest"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared/sharedtest"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+// jobEndTime is the creation time of the jobs, after the last activity of the fixtures.
+const jobEndTime int64 = 10
+
+func setupWorker(t *testing.T, configure func(cfg *model.Config)) (*worker, *storetest.Store, filestore.FileBackend) {
+	t.Helper()
+
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	*cfg.MessageExportSettings.EnableExport = true
+	*cfg.MessageExportSettings.ExportFormat = model.ComplianceExportTypeCsv
+	if configure != nil {
+		configure(cfg)
+	}
+
+	mockStore := &storetest.Store{}
+	t.Cleanup(func() {
+		mockStore.AssertExpectations(t)
+	})
+	mockStore.JobStore.On("UpdateOptimistically", mock.AnythingOfType("*model.Job"), model.JobStatusInProgress).Return(true, nil)
+	mockStore.PostStore.On("AnalyticsPostCount", mock.AnythingOfType("*model.PostCountOptions")).Return(int64(1), nil)
+
+	fileBackend, err := filestore.NewFileBackend(filestore.FileBackendSettings{
+		DriverName: model.ImageDriverLocal,
+		Directory:  t.TempDir(),
+	})
+	require.NoError(t, err)
+	exportBackend, err := filestore.NewFileBackend(filestore.FileBackendSettings{
+		DriverName: model.ImageDriverLocal,
+		Directory:  t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	jobServer := jobs.NewJobServer(&testutils.StaticConfigService{Cfg: cfg}, mockStore, nil, mlog.CreateConsoleTestLogger(t))
+	w := &worker{
+		jobServer:     jobServer,
+		store:         shared.NewMessageExportStore(mockStore),
+		fileBackend:   func() filestore.FileBackend { return fileBackend },
+		exportBackend: func() filestore.FileBackend { return exportBackend },
+	}
+	return w, mockStore, exportBackend
+}
+
+// mockChannelActivity makes the channels of the sharedtest fixtures active from startTime to
+// jobEndTime.
+func mockChannelActivity(mockStore *storetest.Store, startTime int64) {
+	channelIDs := []string{sharedtest.PostChannelId, sharedtest.HistoryChannel1Id, sharedtest.HistoryChannel2Id}
+	histories := sharedtest.ChannelMemberHistories()
+
+	mockStore.ChannelMemberHistoryStore.On("GetChannelsWithActivityDuring", startTime, jobEndTime).Return(channelIDs, nil)
+	mockStore.ChannelStore.On("GetMany", channelIDs, true).Return(model.ChannelList{
+		{Id: sharedtest.PostChannelId, Name: "Test", DisplayName: "Test", Type: model.ChannelTypeDirect},
+		{Id: sharedtest.HistoryChannel1Id, TeamId: "test", Name: "test", DisplayName: "test", Type: model.ChannelTypeOpen},
+		{Id: sharedtest.HistoryChannel2Id, TeamId: "test", Name: "test", DisplayName: "test", Type: model.ChannelTypeOpen},
+	}, nil)
+	mockStore.ChannelMemberHistoryStore.On("GetUsersInChannelDuring", startTime, jobEndTime, channelIDs).Return(
+		append(histories[sharedtest.HistoryChannel1Id], histories[sharedtest.HistoryChannel2Id]...), nil)
+}
+
+func TestMessageExportJob(t *testing.T) {
+	w, mockStore, exportBackend := setupWorker(t, nil)
+
+	// The previous export stopped after the post "prev" updated at 1
+	mockStore.JobStore.On("GetNewestJobByStatusesAndType", []string{model.JobStatusWarning, model.JobStatusSuccess}, model.JobTypeMessageExport).Return(&model.Job{
+		Type: model.JobTypeMessageExport,
+		Data: model.StringMap{shared.JobDataBatchStartTime: "1", shared.JobDataBatchStartId: "prev"},
+	}, nil)
+	mockChannelActivity(mockStore, 1)
+
+	post := sharedtest.Post()
+	mockStore.ComplianceStore.On("MessageExport", mock.Anything, model.MessageExportCursor{LastPostUpdateAt: 1, LastPostId: "prev", UntilUpdateAt: jobEndTime}, 10000).
+		Return([]*model.MessageExport{&post}, model.MessageExportCursor{LastPostUpdateAt: 1, LastPostId: sharedtest.PostId, UntilUpdateAt: jobEndTime}, nil)
+	attachments := sharedtest.Attachments()
+	mockStore.FileInfoStore.On("GetForPost", sharedtest.PostId, true, true, false).Return(attachments, nil)
+	_, err := w.fileBackend().WriteFile(bytes.NewReader([]byte(exporttest.AttachmentContents)), attachments[0].Path)
+	require.NoError(t, err)
+
+	job := &model.Job{Id: model.NewId(), Type: model.JobTypeMessageExport, CreateAt: jobEndTime}
+	require.NoError(t, w.doJob(mlog.CreateConsoleTestLogger(t), job))
+
+	assert.Equal(t, "1", job.Data[shared.JobDataJobStartTime])
+	assert.Equal(t, "prev", job.Data[shared.JobDataJobStartId])
+	assert.Equal(t, "10", job.Data[shared.JobDataJobEndTime])
+	assert.Equal(t, sharedtest.PostId, job.Data[shared.JobDataBatchStartId])
+	assert.Equal(t, "10", job.Data[shared.JobDataBatchStartTime], "the member history batch runs to the end of the job")
+	assert.Equal(t, "2", job.Data[shared.JobDataBatchNumber])
+	assert.Equal(t, "1", job.Data[shared.JobDataMessagesExported])
+	assert.Equal(t, "1", job.Data[shared.JobDataWarningCount], "the missing attachment should be a warning")
+
+	exportDir := job.Data[shared.JobDataExportDir]
+	assert.True(t, strings.HasSuffix(exportDir, job.Id))
+	posts := exporttest.ReadArchive(t, exportBackend, path.Join(exportDir, "batch001-1-1.zip"))[csv_export.PostsFileName]
+	assert.Contains(t, string(posts), "Some message")
+
+	// Channels whose only later activity is people joining or leaving get a batch of their own
+	posts = exporttest.ReadArchive(t, exportBackend, path.Join(exportDir, "batch002-1-10.zip"))[csv_export.PostsFileName]
+	assert.NotContains(t, string(posts), "Some message")
+	assert.Contains(t, string(posts), "good-request-2")
+}
+
+func TestMessageExportJobWithoutPreviousJob(t *testing.T) {
+	w, mockStore, _ := setupWorker(t, func(cfg *model.Config) {
+		*cfg.MessageExportSettings.ExportFromTimestamp = 5
+	})
+
+	mockStore.JobStore.On("GetNewestJobByStatusesAndType", []string{model.JobStatusWarning, model.JobStatusSuccess}, model.JobTypeMessageExport).
+		Return(nil, store.NewErrNotFound("Job", model.JobTypeMessageExport))
+	mockStore.ChannelMemberHistoryStore.On("GetChannelsWithActivityDuring", int64(5), jobEndTime).Return([]string{}, nil)
+	mockStore.ComplianceStore.On("MessageExport", mock.Anything, model.MessageExportCursor{LastPostUpdateAt: 5, UntilUpdateAt: jobEndTime}, 10000).
+		Return([]*model.MessageExport{}, model.MessageExportCursor{}, nil)
+
+	job := &model.Job{Id: model.NewId(), Type: model.JobTypeMessageExport, CreateAt: jobEndTime}
+	require.NoError(t, w.doJob(mlog.CreateConsoleTestLogger(t), job))
+
+	assert.Equal(t, "5", job.Data[shared.JobDataJobStartTime], "the export starts from ExportFromTimestamp")
+	assert.Equal(t, "5", job.Data[shared.JobDataBatchStartTime])
+	assert.Equal(t, "0", job.Data[shared.JobDataBatchNumber])
+}
+
+func TestMessageExportJobResumes(t *testing.T) {
+	w, mockStore, exportBackend := setupWorker(t, nil)
+
+	// A job claimed again after a restart, with its first batch already written
+	data := shared.JobData{JobDataExported: shared.JobDataExported{
+		ExportType:              model.ComplianceExportTypeCsv,
+		ExportDir:               "export/resumed",
+		JobStartTime:            1,
+		JobEndTime:              jobEndTime,
+		BatchStartTime:          1,
+		BatchStartId:            sharedtest.PostId,
+		BatchSize:               10000,
+		ChannelBatchSize:        100,
+		ChannelHistoryBatchSize: 100,
+		BatchNumber:             1,
+		MessagesExported:        1,
+	}}
+	job := &model.Job{Id: model.NewId(), Type: model.JobTypeMessageExport, CreateAt: jobEndTime, Data: shared.JobDataToStringMap(data)}
+
+	mockChannelActivity(mockStore, 1)
+	mockStore.ComplianceStore.On("MessageExport", mock.Anything, model.MessageExportCursor{LastPostUpdateAt: 1, LastPostId: sharedtest.PostId, UntilUpdateAt: jobEndTime}, 10000).
+		Return([]*model.MessageExport{}, model.MessageExportCursor{}, nil)
+
+	require.NoError(t, w.doJob(mlog.CreateConsoleTestLogger(t), job))
+
+	assert.Equal(t, "export/resumed", job.Data[shared.JobDataExportDir])
+	assert.Equal(t, "2", job.Data[shared.JobDataBatchNumber])
+	assert.Equal(t, "1", job.Data[shared.JobDataMessagesExported])
+	assert.Equal(t, "10", job.Data[shared.JobDataBatchStartTime])
+
+	exists, err := exportBackend.FileExists("export/resumed/batch002-1-10.zip")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
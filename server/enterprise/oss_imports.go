@@ -0,0 +1,13 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+//go:build !enterprise
+
+package enterprise
+
+import (
+	// Needed to ensure the init() methods of the in-tree implementations get run. Enterprise
+	// builds register their own.
+	_ "github.com/mattermost/mattermost/server/v8/enterprise/data_retention"
+	_ "github.com/mattermost/mattermost/server/v8/enterprise/message_export"
+)
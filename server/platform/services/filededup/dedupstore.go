@@ -0,0 +1,449 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package filededup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+const (
+	// BlobsDirectory is the directory of the underlying backend the blobs are stored in. It is
+	// hidden from the listings of the deduplicating backend.
+	BlobsDirectory = "file_blobs"
+
+	// tempDirectory holds the uploads being hashed, before they are moved to their blob.
+	tempDirectory = BlobsDirectory + "/tmp"
+)
+
+// DedupFileBackend stores the contents of every file once, in a blob named after the SHA-256
+// hash of the contents, and keeps track of the paths referencing each blob in the database.
+// Copying and moving a deduplicated file only update its reference. Files written before
+// deduplication was enabled are still read from their own path until they are deduplicated.
+type DedupFileBackend struct {
+	backend filestore.FileBackend
+	store   store.FileBlobStore
+}
+
+var _ filestore.FileBackend = (*DedupFileBackend)(nil)
+
+func NewDedupFileBackend(backend filestore.FileBackend, blobStore store.FileBlobStore) *DedupFileBackend {
+	return &DedupFileBackend{
+		backend: backend,
+		store:   blobStore,
+	}
+}
+
+// NewFileBackend creates the backend of the file store described by settings, deduplicated
+// through blobStore when fileSettings enable deduplication. Every backend over the file store
+// is created with it: a backend that bypasses deduplication would not resolve the paths
+// referencing blobs, and would leave stale references behind the files it writes.
+func NewFileBackend(settings filestore.FileBackendSettings, fileSettings *model.FileSettings, blobStore store.FileBlobStore) (filestore.FileBackend, error) {
+	backend, err := filestore.NewFileBackend(settings)
+	if err != nil {
+		return nil, err
+	}
+	if fileSettings.EnableDeduplication == nil || !*fileSettings.EnableDeduplication {
+		return backend, nil
+	}
+	if blobStore == nil {
+		return nil, errors.New("file deduplication is enabled but no file blob store was provided")
+	}
+	return NewDedupFileBackend(backend, blobStore), nil
+}
+
+// BlobPath returns the path of the underlying backend the contents of blob are stored at.
+// Blobs recreated after being deleted by the cleanup job get a new id, and so a new path, so
+// that deleting the contents of a blob never races with them being written again.
+func BlobPath(hash, id string) string {
+	return filepath.Join(BlobsDirectory, hash[:2], hash+"_"+id)
+}
+
+// Unwrap returns the backend the blobs and the files not deduplicated yet are stored in.
+func (b *DedupFileBackend) Unwrap() filestore.FileBackend {
+	return b.backend
+}
+
+func (b *DedupFileBackend) DriverName() string {
+	return b.backend.DriverName()
+}
+
+func (b *DedupFileBackend) TestConnection() error {
+	return b.backend.TestConnection()
+}
+
+// resolve returns the reference of path, or nil if the file is stored at its own path.
+func (b *DedupFileBackend) resolve(path string) (*model.FileBlobReference, error) {
+	reference, err := b.store.GetReference(path)
+	if err != nil {
+		var nfErr *store.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "unable to resolve file %s", path)
+	}
+	return reference, nil
+}
+
+// storedPath returns the path of the underlying backend the contents of path are stored at.
+func (b *DedupFileBackend) storedPath(path string) (string, error) {
+	reference, err := b.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if reference == nil {
+		return path, nil
+	}
+	return BlobPath(reference.Hash, reference.BlobId), nil
+}
+
+func (b *DedupFileBackend) Reader(path string) (filestore.ReadCloseSeeker, error) {
+	storedPath, err := b.storedPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return b.backend.Reader(storedPath)
+}
+
+func (b *DedupFileBackend) ReadFile(path string) ([]byte, error) {
+	storedPath, err := b.storedPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return b.backend.ReadFile(storedPath)
+}
+
+func (b *DedupFileBackend) FileExists(path string) (bool, error) {
+	reference, err := b.resolve(path)
+	if err != nil {
+		return false, err
+	}
+	if reference != nil {
+		return true, nil
+	}
+	return b.backend.FileExists(path)
+}
+
+func (b *DedupFileBackend) FileSize(path string) (int64, error) {
+	reference, err := b.resolve(path)
+	if err != nil {
+		return 0, err
+	}
+	if reference != nil {
+		return reference.Size, nil
+	}
+	return b.backend.FileSize(path)
+}
+
+// FileModTime returns when path was last written, which for a deduplicated file is when its
+// reference was created.
+func (b *DedupFileBackend) FileModTime(path string) (time.Time, error) {
+	reference, err := b.resolve(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if reference != nil {
+		return time.UnixMilli(reference.CreateAt), nil
+	}
+	return b.backend.FileModTime(path)
+}
+
+// CopyFile only adds a reference to the blob of oldPath if it is deduplicated.
+func (b *DedupFileBackend) CopyFile(oldPath, newPath string) error {
+	reference, err := b.resolve(oldPath)
+	if err != nil {
+		return err
+	}
+	if reference == nil {
+		if err := b.backend.CopyFile(oldPath, newPath); err != nil {
+			return err
+		}
+		return b.removeReference(newPath)
+	}
+
+	if _, err := b.store.AddReference(newPath, reference.Hash, reference.Size); err != nil {
+		return errors.Wrapf(err, "unable to copy file %s to %s", oldPath, newPath)
+	}
+	return b.removeStoredFile(newPath)
+}
+
+// MoveFile only moves the reference of oldPath if it is deduplicated.
+func (b *DedupFileBackend) MoveFile(oldPath, newPath string) error {
+	reference, err := b.resolve(oldPath)
+	if err != nil {
+		return err
+	}
+	if reference == nil {
+		if err := b.backend.MoveFile(oldPath, newPath); err != nil {
+			return err
+		}
+		return b.removeReference(newPath)
+	}
+
+	if err := b.store.MoveReference(oldPath, newPath); err != nil {
+		return errors.Wrapf(err, "unable to move file %s to %s", oldPath, newPath)
+	}
+	return b.removeStoredFile(newPath)
+}
+
+func (b *DedupFileBackend) WriteFile(fr io.Reader, path string) (int64, error) {
+	return b.WriteFileContext(context.Background(), fr, path)
+}
+
+// WriteFileContext stores the contents of fr in their blob, unless an identical blob already
+// exists, and points path at it.
+func (b *DedupFileBackend) WriteFileContext(ctx context.Context, fr io.Reader, path string) (int64, error) {
+	written, err := b.writeBlob(ctx, fr, path)
+	if err != nil {
+		return written, err
+	}
+	return written, b.removeStoredFile(path)
+}
+
+// AppendFile appends to the file stored at path. A deduplicated file is first copied back to
+// its own path, since appending to its blob would change every file sharing it.
+func (b *DedupFileBackend) AppendFile(fr io.Reader, path string) (int64, error) {
+	reference, err := b.resolve(path)
+	if err != nil {
+		return 0, err
+	}
+	if reference != nil {
+		if err := b.backend.CopyFile(BlobPath(reference.Hash, reference.BlobId), path); err != nil {
+			return 0, errors.Wrapf(err, "unable to copy file %s out of its blob", path)
+		}
+		if err := b.removeReference(path); err != nil {
+			return 0, err
+		}
+	}
+	return b.backend.AppendFile(fr, path)
+}
+
+func (b *DedupFileBackend) RemoveFile(path string) error {
+	reference, err := b.resolve(path)
+	if err != nil {
+		return err
+	}
+	if reference == nil {
+		return b.backend.RemoveFile(path)
+	}
+	if err := b.store.RemoveReference(path); err != nil {
+		return errors.Wrapf(err, "unable to remove file %s", path)
+	}
+	return nil
+}
+
+func (b *DedupFileBackend) ListDirectory(path string) ([]string, error) {
+	paths, err := b.backend.ListDirectory(path)
+	if err != nil {
+		return nil, err
+	}
+	paths = slices.DeleteFunc(paths, isBlobPath)
+
+	prefix := directoryPrefix(path)
+	references, err := b.store.GetReferencesWithPrefix(prefix)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list the directory %s", path)
+	}
+	for _, reference := range references {
+		if isBlobPath(reference.Path) {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(reference.Path, prefix), "/")
+		paths = append(paths, prefix+name)
+	}
+
+	slices.Sort(paths)
+	return slices.Compact(paths), nil
+}
+
+func (b *DedupFileBackend) ListDirectoryRecursively(path string) ([]string, error) {
+	paths, err := b.backend.ListDirectoryRecursively(path)
+	if err != nil {
+		return nil, err
+	}
+	paths = slices.DeleteFunc(paths, isBlobPath)
+
+	references, err := b.store.GetReferencesWithPrefix(directoryPrefix(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list the directory %s", path)
+	}
+	for _, reference := range references {
+		if !isBlobPath(reference.Path) {
+			paths = append(paths, reference.Path)
+		}
+	}
+
+	slices.Sort(paths)
+	return slices.Compact(paths), nil
+}
+
+func (b *DedupFileBackend) RemoveDirectory(path string) error {
+	if _, err := b.store.RemoveReferencesWithPrefix(directoryPrefix(path)); err != nil {
+		return errors.Wrapf(err, "unable to remove the directory %s", path)
+	}
+	return b.backend.RemoveDirectory(path)
+}
+
+// ZipReader will create a zip of path. If path is a single file, it will zip the single file.
+// If deflate is true, the contents will be compressed.
+func (b *DedupFileBackend) ZipReader(path string, deflate bool) (io.ReadCloser, error) {
+	return filestore.ZipReader(b, path, deflate)
+}
+
+// Dedup moves the contents of a file stored at its own path to its blob. It returns whether the
+// file was deduplicated, which is not the case if it already was.
+func (b *DedupFileBackend) Dedup(path string) (bool, error) {
+	reference, err := b.resolve(path)
+	if err != nil || reference != nil {
+		return false, err
+	}
+
+	r, err := b.backend.Reader(path)
+	if err != nil {
+		return false, err
+	}
+	_, err = b.writeBlob(context.Background(), r, path)
+	r.Close()
+	if err != nil {
+		return false, err
+	}
+
+	return true, b.removeStoredFile(path)
+}
+
+// BlobId returns the id of the blob the contents of path are stored in, or an empty string if the
+// file is stored at its own path.
+func (b *DedupFileBackend) BlobId(path string) (string, error) {
+	reference, err := b.resolve(path)
+	if err != nil || reference == nil {
+		return "", err
+	}
+	return reference.BlobId, nil
+}
+
+// RemoveStaleTempFiles removes the uploads left behind in the temporary directory by writes
+// interrupted before the upload was moved to its blob, as well as the references holding their
+// blobs, and returns how many uploads were removed.
+func (b *DedupFileBackend) RemoveStaleTempFiles(olderThan time.Time) (int, error) {
+	references, err := b.store.GetReferencesWithPrefix(tempDirectory + "/")
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to list the references of temporary files")
+	}
+	for _, reference := range references {
+		if time.UnixMilli(reference.CreateAt).After(olderThan) {
+			continue
+		}
+		if err := b.removeReference(reference.Path); err != nil {
+			return 0, err
+		}
+	}
+
+	paths, err := b.backend.ListDirectory(tempDirectory)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, path := range paths {
+		modTime, err := b.backend.FileModTime(path)
+		if err != nil || modTime.After(olderThan) {
+			continue
+		}
+		if err := b.backend.RemoveFile(path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// writeBlob hashes the contents of fr while writing them to a temporary file, and then either
+// moves that file to its blob or, if the blob is already stored, removes it. The temporary path
+// references the blob until path does, so that the cleanup job cannot delete the blob while it
+// is written, and path only references the blob once its contents are stored. Concurrent writes
+// of the same contents may both store the blob, which only writes identical contents twice.
+func (b *DedupFileBackend) writeBlob(ctx context.Context, fr io.Reader, path string) (int64, error) {
+	tempPath := filepath.Join(tempDirectory, model.NewId())
+	hash := sha256.New()
+	written, err := filestore.TryWriteFileContext(ctx, b.backend, io.TeeReader(fr, hash), tempPath)
+	if err != nil {
+		// A partial temporary file is removed by the file blob cleanup job.
+		_ = b.backend.RemoveFile(tempPath)
+		return written, err
+	}
+
+	blob, err := b.store.AddReference(tempPath, hex.EncodeToString(hash.Sum(nil)), written)
+	if err != nil {
+		_ = b.backend.RemoveFile(tempPath)
+		return 0, errors.Wrapf(err, "unable to reference file %s", path)
+	}
+
+	blobPath := BlobPath(blob.Hash, blob.Id)
+	exists, err := b.backend.FileExists(blobPath)
+	if err == nil && !exists {
+		err = b.backend.MoveFile(tempPath, blobPath)
+	}
+	if err == nil {
+		err = b.store.MoveReference(tempPath, path)
+	}
+	if err != nil {
+		_ = b.backend.RemoveFile(tempPath)
+		if removeErr := b.removeReference(tempPath); removeErr != nil {
+			return 0, removeErr
+		}
+		return 0, errors.Wrapf(err, "unable to store the blob of file %s", path)
+	}
+	if exists {
+		_ = b.backend.RemoveFile(tempPath)
+	}
+
+	return written, nil
+}
+
+// removeReference removes the reference of path, if any, once its contents were written to its
+// own path.
+func (b *DedupFileBackend) removeReference(path string) error {
+	if err := b.store.RemoveReference(path); err != nil {
+		var nfErr *store.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return nil
+		}
+		return errors.Wrapf(err, "unable to remove the reference of file %s", path)
+	}
+	return nil
+}
+
+// removeStoredFile removes the file stored at path itself, if any, once path references a blob.
+func (b *DedupFileBackend) removeStoredFile(path string) error {
+	exists, err := b.backend.FileExists(path)
+	if err != nil || !exists {
+		return err
+	}
+	return b.backend.RemoveFile(path)
+}
+
+func isBlobPath(path string) bool {
+	return path == BlobsDirectory || strings.HasPrefix(path, BlobsDirectory+"/")
+}
+
+// directoryPrefix returns the prefix shared by the paths of the files in the directory path.
+func directoryPrefix(path string) string {
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	if path == "" || path == "." {
+		return ""
+	}
+	return path + "/"
+}
@@ -0,0 +1,473 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package filededup
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+// memoryBlobStore is an in-memory store.FileBlobStore.
+type memoryBlobStore struct {
+	mut        sync.Mutex
+	blobs      map[string]*model.FileBlob
+	references map[string]*model.FileBlobReference
+}
+
+func newMemoryBlobStore() *memoryBlobStore {
+	return &memoryBlobStore{
+		blobs:      map[string]*model.FileBlob{},
+		references: map[string]*model.FileBlobReference{},
+	}
+}
+
+func (s *memoryBlobStore) AddReference(path, hash string, size int64) (*model.FileBlob, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	now := model.GetMillis()
+	blob, ok := s.blobs[hash]
+	if !ok {
+		blob = &model.FileBlob{Id: model.NewId(), Hash: hash, Size: size, CreateAt: now}
+		s.blobs[hash] = blob
+	}
+	blob.RefCount++
+	blob.UpdateAt = now
+	s.removeReference(path, now)
+	s.references[path] = &model.FileBlobReference{Path: path, BlobId: blob.Id, Hash: hash, Size: size, CreateAt: now}
+
+	copied := *blob
+	return &copied, nil
+}
+
+func (s *memoryBlobStore) GetReference(path string) (*model.FileBlobReference, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	reference, ok := s.references[path]
+	if !ok {
+		return nil, store.NewErrNotFound("FileBlobReference", path)
+	}
+	copied := *reference
+	return &copied, nil
+}
+
+func (s *memoryBlobStore) GetReferencesWithPrefix(prefix string) ([]*model.FileBlobReference, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	references := []*model.FileBlobReference{}
+	for path, reference := range s.references {
+		if strings.HasPrefix(path, prefix) {
+			copied := *reference
+			references = append(references, &copied)
+		}
+	}
+	slices.SortFunc(references, func(a, b *model.FileBlobReference) int { return strings.Compare(a.Path, b.Path) })
+	return references, nil
+}
+
+func (s *memoryBlobStore) MoveReference(oldPath, newPath string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	reference, ok := s.references[oldPath]
+	if !ok {
+		return store.NewErrNotFound("FileBlobReference", oldPath)
+	}
+	if oldPath == newPath {
+		return nil
+	}
+	delete(s.references, oldPath)
+	s.removeReference(newPath, model.GetMillis())
+	reference.Path = newPath
+	s.references[newPath] = reference
+	return nil
+}
+
+func (s *memoryBlobStore) RemoveReference(path string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if !s.removeReference(path, model.GetMillis()) {
+		return store.NewErrNotFound("FileBlobReference", path)
+	}
+	return nil
+}
+
+func (s *memoryBlobStore) RemoveReferencesWithPrefix(prefix string) (int64, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var removed int64
+	for path := range s.references {
+		if strings.HasPrefix(path, prefix) {
+			s.removeReference(path, model.GetMillis())
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *memoryBlobStore) HasReferences() (bool, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return len(s.references) > 0, nil
+}
+
+func (s *memoryBlobStore) GetUnreferenced(maxUpdateAt int64, limit int) ([]*model.FileBlob, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	blobs := []*model.FileBlob{}
+	for _, blob := range s.blobs {
+		if blob.RefCount <= 0 && blob.UpdateAt < maxUpdateAt && len(blobs) < limit {
+			copied := *blob
+			blobs = append(blobs, &copied)
+		}
+	}
+	return blobs, nil
+}
+
+func (s *memoryBlobStore) DeleteUnreferenced(id string, maxUpdateAt int64) (bool, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for hash, blob := range s.blobs {
+		if blob.Id == id && blob.RefCount <= 0 && blob.UpdateAt < maxUpdateAt {
+			delete(s.blobs, hash)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryBlobStore) removeReference(path string, now int64) bool {
+	reference, ok := s.references[path]
+	if !ok {
+		return false
+	}
+	delete(s.references, path)
+	blob := s.blobs[reference.Hash]
+	blob.RefCount--
+	blob.UpdateAt = now
+	return true
+}
+
+func (s *memoryBlobStore) refCount(hash string) int64 {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if blob, ok := s.blobs[hash]; ok {
+		return blob.RefCount
+	}
+	return -1
+}
+
+func setupDedupBackend(t *testing.T) (*DedupFileBackend, filestore.FileBackend, *memoryBlobStore) {
+	t.Helper()
+
+	local, err := filestore.NewFileBackend(filestore.FileBackendSettings{
+		DriverName: model.ImageDriverLocal,
+		Directory:  t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	blobStore := newMemoryBlobStore()
+	return NewDedupFileBackend(local, blobStore), local, blobStore
+}
+
+func writeFile(t *testing.T, backend filestore.FileBackend, path, contents string) {
+	t.Helper()
+
+	written, err := backend.WriteFile(strings.NewReader(contents), path)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(contents)), written)
+}
+
+func readFile(t *testing.T, backend filestore.FileBackend, path string) string {
+	t.Helper()
+
+	contents, err := backend.ReadFile(path)
+	require.NoError(t, err)
+	return string(contents)
+}
+
+func blobFiles(t *testing.T, local filestore.FileBackend) []string {
+	t.Helper()
+
+	paths, err := local.ListDirectoryRecursively(BlobsDirectory)
+	require.NoError(t, err)
+	return slices.DeleteFunc(paths, func(path string) bool { return strings.HasPrefix(path, tempDirectory+"/") })
+}
+
+func TestWriteFileStoresContentsOnce(t *testing.T) {
+	backend, local, blobStore := setupDedupBackend(t)
+
+	writeFile(t, backend, "data/a/file.pdf", "same contents")
+	writeFile(t, backend, "data/b/file.pdf", "same contents")
+	writeFile(t, backend, "data/c/file.pdf", "other contents")
+
+	assert.Len(t, blobFiles(t, local), 2)
+	assert.Equal(t, "same contents", readFile(t, backend, "data/a/file.pdf"))
+	assert.Equal(t, "same contents", readFile(t, backend, "data/b/file.pdf"))
+	assert.Equal(t, "other contents", readFile(t, backend, "data/c/file.pdf"))
+
+	reference, err := blobStore.GetReference("data/a/file.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), blobStore.refCount(reference.Hash))
+
+	exists, err := local.FileExists("data/a/file.pdf")
+	require.NoError(t, err)
+	assert.False(t, exists, "the contents are only stored in their blob")
+
+	size, err := backend.FileSize("data/a/file.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("same contents")), size)
+
+	r, err := backend.Reader("data/b/file.pdf")
+	require.NoError(t, err)
+	defer r.Close()
+	_, err = r.Seek(5, io.SeekStart)
+	require.NoError(t, err)
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "contents", string(rest))
+
+	t.Run("overwriting a file releases its previous blob", func(t *testing.T) {
+		writeFile(t, backend, "data/b/file.pdf", "other contents")
+		assert.Equal(t, int64(1), blobStore.refCount(reference.Hash))
+		assert.Equal(t, "other contents", readFile(t, backend, "data/b/file.pdf"))
+	})
+
+	t.Run("a written file replaces a file stored at its own path", func(t *testing.T) {
+		writeFile(t, local, "data/d/file.pdf", "not deduplicated")
+		writeFile(t, backend, "data/d/file.pdf", "same contents")
+
+		exists, err := local.FileExists("data/d/file.pdf")
+		require.NoError(t, err)
+		assert.False(t, exists)
+		assert.Equal(t, "same contents", readFile(t, backend, "data/d/file.pdf"))
+	})
+}
+
+func TestCopyAndMoveFile(t *testing.T) {
+	backend, local, blobStore := setupDedupBackend(t)
+
+	writeFile(t, backend, "data/a.txt", "contents")
+	reference, err := blobStore.GetReference("data/a.txt")
+	require.NoError(t, err)
+
+	require.NoError(t, backend.CopyFile("data/a.txt", "data/b.txt"))
+	assert.Equal(t, int64(2), blobStore.refCount(reference.Hash))
+	assert.Equal(t, "contents", readFile(t, backend, "data/b.txt"))
+
+	require.NoError(t, backend.MoveFile("data/b.txt", "data/c.txt"))
+	assert.Equal(t, int64(2), blobStore.refCount(reference.Hash))
+	assert.Equal(t, "contents", readFile(t, backend, "data/c.txt"))
+
+	exists, err := backend.FileExists("data/b.txt")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Len(t, blobFiles(t, local), 1, "copies and moves do not touch the blobs")
+
+	t.Run("files not deduplicated are copied and moved", func(t *testing.T) {
+		writeFile(t, local, "legacy/a.txt", "legacy")
+
+		require.NoError(t, backend.CopyFile("legacy/a.txt", "legacy/b.txt"))
+		require.NoError(t, backend.MoveFile("legacy/b.txt", "data/c.txt"))
+
+		assert.Equal(t, "legacy", readFile(t, backend, "data/c.txt"), "the reference of the destination is removed")
+		assert.Equal(t, int64(1), blobStore.refCount(reference.Hash))
+	})
+}
+
+func TestAppendFile(t *testing.T) {
+	backend, local, blobStore := setupDedupBackend(t)
+
+	writeFile(t, backend, "data/a.txt", "contents")
+	writeFile(t, backend, "data/b.txt", "contents")
+	reference, err := blobStore.GetReference("data/a.txt")
+	require.NoError(t, err)
+
+	_, err = backend.AppendFile(strings.NewReader(" appended"), "data/a.txt")
+	require.NoError(t, err)
+
+	assert.Equal(t, "contents appended", readFile(t, backend, "data/a.txt"))
+	assert.Equal(t, "contents", readFile(t, backend, "data/b.txt"))
+	assert.Equal(t, int64(1), blobStore.refCount(reference.Hash))
+	assert.Equal(t, "contents appended", readFile(t, local, "data/a.txt"))
+
+	deduped, err := backend.Dedup("data/a.txt")
+	require.NoError(t, err)
+	assert.True(t, deduped)
+	assert.Equal(t, "contents appended", readFile(t, backend, "data/a.txt"))
+
+	deduped, err = backend.Dedup("data/a.txt")
+	require.NoError(t, err)
+	assert.False(t, deduped)
+}
+
+func TestRemoveFile(t *testing.T) {
+	backend, local, blobStore := setupDedupBackend(t)
+
+	writeFile(t, backend, "data/a.txt", "contents")
+	writeFile(t, local, "data/b.txt", "legacy")
+	reference, err := blobStore.GetReference("data/a.txt")
+	require.NoError(t, err)
+
+	require.NoError(t, backend.RemoveFile("data/a.txt"))
+	require.NoError(t, backend.RemoveFile("data/b.txt"))
+
+	for _, path := range []string{"data/a.txt", "data/b.txt"} {
+		exists, err := backend.FileExists(path)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	}
+	assert.Equal(t, int64(0), blobStore.refCount(reference.Hash))
+	assert.Len(t, blobFiles(t, local), 1, "blobs are only deleted by the cleanup job")
+}
+
+func TestListAndRemoveDirectory(t *testing.T) {
+	backend, local, _ := setupDedupBackend(t)
+
+	writeFile(t, backend, "data/a.txt", "a")
+	writeFile(t, backend, "data/sub/b.txt", "b")
+	writeFile(t, local, "data/c.txt", "c")
+	writeFile(t, backend, "other/d.txt", "d")
+
+	paths, err := backend.ListDirectory("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data", "other"}, paths)
+
+	paths, err = backend.ListDirectory("data")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data/a.txt", "data/c.txt", "data/sub"}, paths)
+
+	paths, err = backend.ListDirectoryRecursively("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data/a.txt", "data/c.txt", "data/sub/b.txt", "other/d.txt"}, paths)
+
+	require.NoError(t, backend.RemoveDirectory("data"))
+	paths, err = backend.ListDirectoryRecursively("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other/d.txt"}, paths)
+}
+
+func TestZipReader(t *testing.T) {
+	backend, local, _ := setupDedupBackend(t)
+
+	writeFile(t, backend, "data/a.txt", "a")
+	writeFile(t, local, "data/sub/b.txt", "b")
+
+	r, err := backend.ZipReader("data", false)
+	require.NoError(t, err)
+	defer r.Close()
+	zipped, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	zipReader, err := zip.NewReader(bytes.NewReader(zipped), int64(len(zipped)))
+	require.NoError(t, err)
+	files := map[string]string{}
+	for _, file := range zipReader.File {
+		f, err := file.Open()
+		require.NoError(t, err)
+		contents, err := io.ReadAll(f)
+		f.Close()
+		require.NoError(t, err)
+		files[file.Name] = string(contents)
+	}
+	assert.Equal(t, map[string]string{"a.txt": "a", "sub/b.txt": "b"}, files)
+}
+
+func TestRemoveStaleTempFiles(t *testing.T) {
+	backend, local, blobStore := setupDedupBackend(t)
+
+	tempPath := tempDirectory + "/" + model.NewId()
+	writeFile(t, local, tempPath, "interrupted")
+	blob, err := blobStore.AddReference(tempPath, strings.Repeat("ab", 32), 11)
+	require.NoError(t, err)
+
+	removed, err := backend.RemoveStaleTempFiles(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+	assert.Equal(t, int64(1), blobStore.refCount(blob.Hash))
+
+	removed, err = backend.RemoveStaleTempFiles(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, int64(0), blobStore.refCount(blob.Hash), "the blob held by the interrupted write is released")
+}
+
+// movingBackend calls onMove before moving a file.
+type movingBackend struct {
+	filestore.FileBackend
+	onMove func(oldPath, newPath string)
+}
+
+func (b *movingBackend) MoveFile(oldPath, newPath string) error {
+	b.onMove(oldPath, newPath)
+	return b.FileBackend.MoveFile(oldPath, newPath)
+}
+
+func TestWriteFileHoldsBlobUntilStored(t *testing.T) {
+	_, local, blobStore := setupDedupBackend(t)
+
+	moved := false
+	backend := NewDedupFileBackend(&movingBackend{FileBackend: local, onMove: func(oldPath, newPath string) {
+		moved = true
+		_, err := blobStore.GetReference("data/file.pdf")
+		assert.Error(t, err, "the path must not reference the blob before it is stored")
+
+		reference, err := blobStore.GetReference(oldPath)
+		require.NoError(t, err, "the write must hold the blob while storing it")
+		assert.Equal(t, int64(1), blobStore.refCount(reference.Hash))
+		assert.Equal(t, BlobPath(reference.Hash, reference.BlobId), newPath)
+	}}, blobStore)
+
+	writeFile(t, backend, "data/file.pdf", "contents")
+	assert.True(t, moved)
+
+	reference, err := blobStore.GetReference("data/file.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), blobStore.refCount(reference.Hash))
+	references, err := blobStore.GetReferencesWithPrefix(tempDirectory + "/")
+	require.NoError(t, err)
+	assert.Empty(t, references)
+	assert.Equal(t, "contents", readFile(t, backend, "data/file.pdf"))
+}
+
+func TestNewFileBackend(t *testing.T) {
+	settings := filestore.FileBackendSettings{
+		DriverName: model.ImageDriverLocal,
+		Directory:  t.TempDir(),
+	}
+
+	backend, err := NewFileBackend(settings, &model.FileSettings{EnableDeduplication: model.NewPointer(false)}, newMemoryBlobStore())
+	require.NoError(t, err)
+	_, ok := filestore.UnwrapFileBackend[*DedupFileBackend](backend)
+	assert.False(t, ok)
+
+	backend, err = NewFileBackend(settings, &model.FileSettings{EnableDeduplication: model.NewPointer(true)}, newMemoryBlobStore())
+	require.NoError(t, err)
+	_, ok = filestore.UnwrapFileBackend[*DedupFileBackend](backend)
+	assert.True(t, ok)
+
+	_, err = NewFileBackend(settings, &model.FileSettings{EnableDeduplication: model.NewPointer(true)}, nil)
+	assert.Error(t, err, "deduplication needs a blob store")
+}
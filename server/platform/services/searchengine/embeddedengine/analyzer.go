@@ -0,0 +1,148 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/token/unicodenorm"
+	bleveunicode "github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/registry"
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// Every index mapping has two analyzers built from Bleve's unicode tokenizer, which splits
+// text into words and Han, Hiragana and Katakana into single characters. textAnalyzer
+// analyzes the indexed text and queryAnalyzer the search terms. They only differ for
+// Chinese: the index holds every character and every pair of adjacent characters, and a
+// query of two or more characters only its pairs, so it matches the same run wherever it
+// occurs.
+const (
+	textAnalyzer  = "mm_text"
+	queryAnalyzer = "mm_query"
+
+	nfcFilter            = "mm_nfc"
+	cjkUnigramsFilter    = "mm_cjk_bigram_unigram"
+	diacriticsFilterName = "mm_vi_fold"
+)
+
+func init() {
+	if err := registry.RegisterTokenFilter(diacriticsFilterName, func(map[string]any, *registry.Cache) (analysis.TokenFilter, error) {
+		return diacriticsFilter{}, nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// diacriticsFilter removes tone marks and other diacritics and maps đ to d, so that
+// "Việt" and "viet" are the same term. Token offsets still point into the original text.
+type diacriticsFilter struct{}
+
+func (diacriticsFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		token.Term = []byte(foldDiacritics(string(token.Term)))
+	}
+	return input
+}
+
+func foldDiacritics(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r == 'đ' || r == 'Đ':
+			r = 'd'
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return norm.NFC.String(b.String())
+}
+
+func isChinese(analyzerName string) bool {
+	return analyzerName == model.EmbeddedSearchAnalyzerChineseSimple || analyzerName == model.EmbeddedSearchAnalyzerChineseTrad
+}
+
+// validAnalyzer returns the configured analyzer name, falling back to the standard one.
+func validAnalyzer(name string) string {
+	switch name {
+	case model.EmbeddedSearchAnalyzerVietnamese, model.EmbeddedSearchAnalyzerChineseSimple, model.EmbeddedSearchAnalyzerChineseTrad:
+		return name
+	}
+	return model.EmbeddedSearchAnalyzerStandard
+}
+
+// newIndexMapping returns an index mapping with the text and query analyzers of the
+// configured analyzer.
+func newIndexMapping(analyzerName string) (*mapping.IndexMappingImpl, error) {
+	m := mapping.NewIndexMapping()
+
+	var filters, queryFilters []string
+	switch {
+	case isChinese(analyzerName):
+		if err := m.AddCustomTokenFilter(cjkUnigramsFilter, map[string]any{
+			"type":           cjk.BigramName,
+			"output_unigram": true,
+		}); err != nil {
+			return nil, err
+		}
+		filters = []string{cjk.WidthName, lowercase.Name, cjkUnigramsFilter}
+		queryFilters = []string{cjk.WidthName, lowercase.Name, cjk.BigramName}
+	default:
+		if err := m.AddCustomTokenFilter(nfcFilter, map[string]any{
+			"type": unicodenorm.Name,
+			"form": unicodenorm.NFC,
+		}); err != nil {
+			return nil, err
+		}
+		filters = []string{nfcFilter, lowercase.Name}
+		if analyzerName == model.EmbeddedSearchAnalyzerVietnamese {
+			filters = append(filters, diacriticsFilterName)
+		}
+		queryFilters = filters
+	}
+
+	if err := m.AddCustomAnalyzer(textAnalyzer, map[string]any{
+		"type":          custom.Name,
+		"tokenizer":     bleveunicode.Name,
+		"token_filters": filters,
+	}); err != nil {
+		return nil, err
+	}
+	if err := m.AddCustomAnalyzer(queryAnalyzer, map[string]any{
+		"type":          custom.Name,
+		"tokenizer":     bleveunicode.Name,
+		"token_filters": queryFilters,
+	}); err != nil {
+		return nil, err
+	}
+	m.DefaultAnalyzer = textAnalyzer
+	return m, nil
+}
+
+// fold normalizes a whole string the way the analyzer normalizes its words, keeping the
+// characters between them. It is used for prefix matches on names and for hashtags.
+func fold(analyzerName, s string) string {
+	switch {
+	case analyzerName == model.EmbeddedSearchAnalyzerVietnamese:
+		return foldDiacritics(s)
+	case isChinese(analyzerName):
+		s = strings.Map(func(r rune) rune {
+			if folded := width.LookupRune(r).Folded(); folded != 0 {
+				return folded
+			}
+			return r
+		}, s)
+	}
+	return strings.ToLower(norm.NFC.String(s))
+}
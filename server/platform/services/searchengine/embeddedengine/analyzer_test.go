@@ -0,0 +1,81 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func testAnalyzer(t *testing.T, analyzerName, name string) analysis.Analyzer {
+	t.Helper()
+	m, err := newIndexMapping(analyzerName)
+	require.NoError(t, err)
+	a := m.AnalyzerNamed(name)
+	require.NotNil(t, a)
+	return a
+}
+
+func analyzedTerms(a analysis.Analyzer, text string) []string {
+	var terms []string
+	for _, token := range a.Analyze([]byte(text)) {
+		terms = append(terms, string(token.Term))
+	}
+	return terms
+}
+
+func TestAnalyzer(t *testing.T) {
+	t.Run("standard analyzer lowercases words and keeps diacritics", func(t *testing.T) {
+		a := testAnalyzer(t, model.EmbeddedSearchAnalyzerStandard, textAnalyzer)
+		assert.Equal(t, []string{"hello", "việt", "nam", "42"}, analyzedTerms(a, "Hello, Việt-Nam 42!"))
+	})
+
+	t.Run("vietnamese analyzer folds tones and đ", func(t *testing.T) {
+		a := testAnalyzer(t, model.EmbeddedSearchAnalyzerVietnamese, textAnalyzer)
+		assert.Equal(t, []string{"duong", "pho", "viet", "nam"}, analyzedTerms(a, "Đường phố Việt Nam"))
+		assert.Equal(t, "nguyen van a", fold(model.EmbeddedSearchAnalyzerVietnamese, "Nguyễn Văn A"))
+	})
+
+	t.Run("decomposed input folds like precomposed input", func(t *testing.T) {
+		a := testAnalyzer(t, model.EmbeddedSearchAnalyzerVietnamese, textAnalyzer)
+		assert.Equal(t, analyzedTerms(a, "Tiếng"), analyzedTerms(a, "Tiếng"))
+	})
+
+	t.Run("chinese analyzer indexes characters and bigrams", func(t *testing.T) {
+		a := testAnalyzer(t, model.EmbeddedSearchAnalyzerChineseSimple, textAnalyzer)
+		assert.ElementsMatch(t, []string{"中", "文", "搜", "索", "中文", "文搜", "搜索"}, analyzedTerms(a, "中文搜索"))
+
+		q := testAnalyzer(t, model.EmbeddedSearchAnalyzerChineseSimple, queryAnalyzer)
+		assert.Equal(t, []string{"中文", "文搜", "搜索"}, analyzedTerms(q, "中文搜索"))
+		assert.Equal(t, []string{"中"}, analyzedTerms(q, "中"))
+	})
+
+	t.Run("chinese analyzer separates words from han", func(t *testing.T) {
+		a := testAnalyzer(t, model.EmbeddedSearchAnalyzerChineseTrad, textAnalyzer)
+		assert.ElementsMatch(t, []string{"go", "語", "言", "語言"}, analyzedTerms(a, "Go語言"))
+	})
+
+	t.Run("chinese analyzer folds full-width characters", func(t *testing.T) {
+		a := testAnalyzer(t, model.EmbeddedSearchAnalyzerChineseSimple, textAnalyzer)
+		assert.Equal(t, []string{"abc", "def"}, analyzedTerms(a, "ＡＢＣ ｄｅｆ"))
+	})
+
+	t.Run("token offsets point into the original text", func(t *testing.T) {
+		a := testAnalyzer(t, model.EmbeddedSearchAnalyzerVietnamese, textAnalyzer)
+		text := "Xin chào"
+		tokens := a.Analyze([]byte(text))
+		require.Len(t, tokens, 2)
+		assert.Equal(t, "chào", text[tokens[1].Start:tokens[1].End])
+		assert.Equal(t, "chao", string(tokens[1].Term))
+	})
+
+	t.Run("unknown analyzer falls back to standard", func(t *testing.T) {
+		assert.Equal(t, model.EmbeddedSearchAnalyzerStandard, validAnalyzer("klingon"))
+	})
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/platform/services/searchengine"
+)
+
+func channelDocFromChannel(channel *model.Channel, userIDs, teamMemberIDs []string, analyzerName string) *channelDoc {
+	suggestions := append(
+		searchengine.GetSuggestionInputsSplitBy(channel.DisplayName, " "),
+		searchengine.GetSuggestionInputsSplitByMultiple(channel.Name, []string{"-", "_"})...,
+	)
+	for i, s := range suggestions {
+		suggestions[i] = fold(analyzerName, s)
+	}
+
+	return &channelDoc{
+		ID:            channel.Id,
+		Type:          string(channel.Type),
+		Deleted:       channel.DeleteAt != 0,
+		TeamID:        channel.TeamId,
+		UserIDs:       userIDs,
+		TeamMemberIDs: teamMemberIDs,
+		Suggestions:   suggestions,
+		SortName:      strings.ToLower(channel.DisplayName),
+	}
+}
+
+func (e *EmbeddedEngine) IndexChannel(rctx request.CTX, channel *model.Channel, userIDs, teamMemberIDs []string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.IndexChannel")
+	}
+
+	if err := e.indexes[IndexBaseChannels].Index(channel.Id, channelDocFromChannel(channel, userIDs, teamMemberIDs, e.analyzer)); err != nil {
+		return indexError("EmbeddedEngine.IndexChannel", err)
+	}
+	return nil
+}
+
+func (e *EmbeddedEngine) SyncBulkIndexChannels(rctx request.CTX, channels []*model.Channel, getUserIDsForChannel func(channel *model.Channel) ([]string, error), teamMemberIDs []string) *model.AppError {
+	if len(channels) == 0 {
+		return nil
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.SyncBulkIndexChannels")
+	}
+
+	index := e.indexes[IndexBaseChannels]
+	batch := index.NewBatch()
+	for _, channel := range channels {
+		userIDs, err := getUserIDsForChannel(channel)
+		if err != nil {
+			return model.NewAppError("EmbeddedEngine.SyncBulkIndexChannels", model.NoTranslation, nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+		if err := batch.Index(channel.Id, channelDocFromChannel(channel, userIDs, teamMemberIDs, e.analyzer)); err != nil {
+			return indexError("EmbeddedEngine.SyncBulkIndexChannels", err)
+		}
+	}
+	if err := index.Batch(batch); err != nil {
+		return indexError("EmbeddedEngine.SyncBulkIndexChannels", err)
+	}
+	return nil
+}
+
+func (e *EmbeddedEngine) SearchChannels(teamId, userID, term string, isGuest, includeDeleted bool) ([]string, *model.AppError) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return []string{}, notStartedError("EmbeddedEngine.SearchChannels")
+	}
+
+	var must, mustNot []query.Query
+	if teamId != "" {
+		must = append(must, termQuery("team_id", teamId))
+	} else {
+		must = append(must, termQuery("team_member_ids", userID))
+	}
+	if term != "" {
+		must = append(must, prefixQuery("suggestions", fold(e.analyzer, term)))
+	}
+
+	private := termQuery("type", string(model.ChannelTypePrivate))
+	if isGuest {
+		mustNot = append(mustNot, private)
+	} else {
+		mustNot = append(mustNot, allOf([]query.Query{private}, []query.Query{termQuery("user_ids", userID)}))
+	}
+	if !includeDeleted {
+		mustNot = append(mustNot, boolFieldQuery("deleted", true))
+	}
+
+	req := bleve.NewSearchRequestOptions(allOf(must, mustNot), model.ChannelSearchDefaultLimit, 0, false)
+	req.SortBy([]string{"sort_name", "_id"})
+	channelIDs, err := searchIDs(e.indexes[IndexBaseChannels], req)
+	if err != nil {
+		return []string{}, searchError("EmbeddedEngine.SearchChannels", err)
+	}
+	return channelIDs, nil
+}
+
+func (e *EmbeddedEngine) DeleteChannel(channel *model.Channel) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.DeleteChannel")
+	}
+
+	if err := e.indexes[IndexBaseChannels].Delete(channel.Id); err != nil {
+		return deleteError("EmbeddedEngine.DeleteChannel", err)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package embeddedengine implements a search engine that keeps its index on the server's
+// own disk, so search can do better than the database without an external cluster.
+//
+// Each kind of document has a Bleve index under IndexDir. The indexes belong to the server
+// that writes them: in a cluster every node would index only the changes it handles and
+// search only its own copy, so the engine is meant for single-node deployments and refuses
+// to run when clustering is enabled.
+package embeddedengine
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+)
+
+const (
+	EngineName = "embedded"
+
+	// indexVersion is bumped when the format of the indexes changes.
+	indexVersion = 2
+)
+
+type EmbeddedEngine struct {
+	// mutex protects the indexes map; ready is only changed while holding it. Starting,
+	// stopping and purging take the write lock, everything else the read lock, as the
+	// indexes themselves are safe for concurrent use.
+	mutex         sync.RWMutex
+	ready         int32
+	cfg           atomic.Pointer[model.Config]
+	logger        mlog.LoggerIFace
+	dir           string
+	analyzer      string
+	queryAnalyzer analysis.Analyzer
+	indexes       map[string]bleve.Index
+}
+
+func NewEmbeddedEngine(cfg *model.Config, logger mlog.LoggerIFace) *EmbeddedEngine {
+	e := &EmbeddedEngine{
+		logger: logger,
+	}
+	e.cfg.Store(cfg)
+	return e
+}
+
+func (e *EmbeddedEngine) config() *model.EmbeddedSearchSettings {
+	return &e.cfg.Load().EmbeddedSearchSettings
+}
+
+func (e *EmbeddedEngine) UpdateConfig(cfg *model.Config) {
+	e.cfg.Store(cfg)
+}
+
+func (*EmbeddedEngine) GetName() string {
+	return EngineName
+}
+
+func (e *EmbeddedEngine) IsEnabled() bool {
+	return *e.config().EnableIndexing
+}
+
+// IsActive is false once clustering is enabled, even if the engine was started before, so
+// that searches fall back to the database instead of returning one node's results.
+func (e *EmbeddedEngine) IsActive() bool {
+	return *e.config().EnableIndexing && !*e.cfg.Load().ClusterSettings.Enable && atomic.LoadInt32(&e.ready) == 1
+}
+
+func (e *EmbeddedEngine) IsIndexingEnabled() bool {
+	return *e.config().EnableIndexing
+}
+
+func (e *EmbeddedEngine) IsSearchEnabled() bool {
+	return *e.config().EnableSearching
+}
+
+func (e *EmbeddedEngine) IsAutocompletionEnabled() bool {
+	return *e.config().EnableAutocomplete
+}
+
+// IsIndexingSync is always true: documents are searchable as soon as they are indexed.
+func (*EmbeddedEngine) IsIndexingSync() bool {
+	return true
+}
+
+func (*EmbeddedEngine) GetVersion() int {
+	return indexVersion
+}
+
+func (*EmbeddedEngine) GetFullVersion() string {
+	return "1"
+}
+
+func (*EmbeddedEngine) GetPlugins() []string {
+	return []string{}
+}
+
+func (e *EmbeddedEngine) Start() *model.AppError {
+	cfg := e.cfg.Load()
+	settings := &cfg.EmbeddedSearchSettings
+	if !*settings.EnableIndexing {
+		return nil
+	}
+	if *cfg.ClusterSettings.Enable {
+		return model.NewAppError("EmbeddedEngine.Start", "embeddedengine.cluster_unsupported.error", nil, "", http.StatusNotImplemented)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if atomic.LoadInt32(&e.ready) != 0 {
+		return nil
+	}
+
+	dir := *settings.IndexDir
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return model.NewAppError("EmbeddedEngine.Start", "embeddedengine.create_index_dir.error", map[string]any{"Dir": dir}, "", http.StatusInternalServerError).Wrap(err)
+	}
+	e.dir = dir
+	e.analyzer = validAnalyzer(*settings.Analyzer)
+
+	e.indexes = map[string]bleve.Index{}
+	for _, name := range indexNames {
+		index, rebuilt, err := openIndex(dir, name, e.analyzer)
+		if err != nil {
+			e.closeIndexes()
+			return model.NewAppError("EmbeddedEngine.Start", "embeddedengine.load_index.error", map[string]any{"Dir": dir}, "", http.StatusInternalServerError).Wrap(err)
+		}
+		if rebuilt {
+			e.logger.Warn("The search index was built with another analyzer and has been emptied; run the indexing job to rebuild it", mlog.String("index", name), mlog.String("analyzer", e.analyzer))
+		}
+		e.indexes[name] = index
+	}
+	e.queryAnalyzer = e.indexes[IndexBasePosts].Mapping().AnalyzerNamed(queryAnalyzer)
+
+	atomic.StoreInt32(&e.ready, 1)
+	e.logger.Info("Embedded search engine started", mlog.String("dir", dir), mlog.String("analyzer", e.analyzer))
+
+	return nil
+}
+
+func (e *EmbeddedEngine) Stop() *model.AppError {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return model.NewAppError("EmbeddedEngine.Stop", "embeddedengine.already_stopped.error", nil, "", http.StatusInternalServerError)
+	}
+
+	atomic.StoreInt32(&e.ready, 0)
+	if err := e.closeIndexes(); err != nil {
+		return model.NewAppError("EmbeddedEngine.Stop", "embeddedengine.close_index.error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return nil
+}
+
+// closeIndexes closes the open indexes and returns the first error. The caller holds the
+// write lock.
+func (e *EmbeddedEngine) closeIndexes() error {
+	var firstErr error
+	for name, index := range e.indexes {
+		if err := index.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(e.indexes, name)
+	}
+	return firstErr
+}
+
+// purgeIndex replaces an index with an empty one. The caller holds the write lock.
+func (e *EmbeddedEngine) purgeIndex(name string) error {
+	if err := e.indexes[name].Close(); err != nil {
+		return err
+	}
+	index, err := createIndex(e.dir, name, e.analyzer)
+	if err != nil {
+		delete(e.indexes, name)
+		return err
+	}
+	e.indexes[name] = index
+	return nil
+}
+
+func notStartedError(where string) *model.AppError {
+	return model.NewAppError(where, "embeddedengine.not_started.error", nil, "", http.StatusInternalServerError)
+}
+
+func indexError(where string, err error) *model.AppError {
+	return model.NewAppError(where, "embeddedengine.index_documents.error", nil, "", http.StatusInternalServerError).Wrap(err)
+}
+
+func searchError(where string, err error) *model.AppError {
+	return model.NewAppError(where, "embeddedengine.search.error", nil, "", http.StatusInternalServerError).Wrap(err)
+}
+
+func deleteError(where string, err error) *model.AppError {
+	return model.NewAppError(where, "embeddedengine.delete_documents.error", nil, "", http.StatusInternalServerError).Wrap(err)
+}
+
+func (e *EmbeddedEngine) TestConfig(rctx request.CTX, cfg *model.Config) *model.AppError {
+	settings := cfg.EmbeddedSearchSettings
+	if !*settings.EnableIndexing {
+		return model.NewAppError("EmbeddedEngine.TestConfig", "embeddedengine.test_config.indexing_disabled.error", nil, "", http.StatusNotImplemented)
+	}
+	if *cfg.ClusterSettings.Enable {
+		return model.NewAppError("EmbeddedEngine.TestConfig", "embeddedengine.cluster_unsupported.error", nil, "", http.StatusNotImplemented)
+	}
+
+	if err := os.MkdirAll(*settings.IndexDir, 0700); err != nil {
+		return model.NewAppError("EmbeddedEngine.TestConfig", "embeddedengine.create_index_dir.error", map[string]any{"Dir": *settings.IndexDir}, "", http.StatusBadRequest).Wrap(err)
+	}
+	f, err := os.CreateTemp(*settings.IndexDir, "test-*.tmp")
+	if err != nil {
+		return model.NewAppError("EmbeddedEngine.TestConfig", "embeddedengine.create_index_dir.error", map[string]any{"Dir": *settings.IndexDir}, "", http.StatusBadRequest).Wrap(err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	return nil
+}
+
+func (e *EmbeddedEngine) PurgeIndexes(rctx request.CTX) *model.AppError {
+	return e.PurgeIndexList(rctx, indexNames)
+}
+
+// PurgeIndexList purges the named indexes: posts, files, channels or users.
+func (e *EmbeddedEngine) PurgeIndexList(rctx request.CTX, indexes []string) *model.AppError {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.PurgeIndexList")
+	}
+
+	for _, name := range indexes {
+		if _, ok := e.indexes[name]; !ok {
+			return model.NewAppError("EmbeddedEngine.PurgeIndexList", "embeddedengine.purge_indexes.unknown_index", map[string]any{"unknown_index": name}, "", http.StatusBadRequest)
+		}
+	}
+	for _, name := range indexes {
+		if err := e.purgeIndex(name); err != nil {
+			// The index can't be used any more; stop so that callers get an error instead
+			// of a missing index.
+			atomic.StoreInt32(&e.ready, 0)
+			e.closeIndexes()
+			return model.NewAppError("EmbeddedEngine.PurgeIndexList", "embeddedengine.purge_index.delete_failed", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+// RefreshIndexes does nothing: documents are searchable as soon as they are indexed.
+func (e *EmbeddedEngine) RefreshIndexes(rctx request.CTX) *model.AppError {
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.RefreshIndexes")
+	}
+	return nil
+}
+
+// DataRetentionDeleteIndexes removes the posts created before cutoff.
+func (e *EmbeddedEngine) DataRetentionDeleteIndexes(rctx request.CTX, cutoff time.Time) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.DataRetentionDeleteIndexes")
+	}
+
+	cutoffMillis := float64(model.GetMillisForTime(cutoff))
+	exclusive := false
+	before := bleve.NewNumericRangeInclusiveQuery(nil, &cutoffMillis, nil, &exclusive)
+	before.SetField("create_at")
+	if err := deleteWhere(e.indexes[IndexBasePosts], before); err != nil {
+		return deleteError("EmbeddedEngine.DataRetentionDeleteIndexes", err)
+	}
+	return nil
+}
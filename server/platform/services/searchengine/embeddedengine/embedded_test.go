@@ -0,0 +1,281 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+)
+
+func newTestEngine(t *testing.T, dir, analyzerName string) *EmbeddedEngine {
+	t.Helper()
+
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	*cfg.EmbeddedSearchSettings.EnableIndexing = true
+	*cfg.EmbeddedSearchSettings.EnableSearching = true
+	*cfg.EmbeddedSearchSettings.IndexDir = dir
+	*cfg.EmbeddedSearchSettings.Analyzer = analyzerName
+
+	engine := NewEmbeddedEngine(cfg, mlog.CreateConsoleTestLogger(t))
+	require.Nil(t, engine.Start())
+	t.Cleanup(func() {
+		if engine.IsActive() {
+			engine.Stop()
+		}
+	})
+	return engine
+}
+
+func newTestPost(channelID, userID, message string, createAt int64) *model.Post {
+	return &model.Post{
+		Id:        model.NewId(),
+		ChannelId: channelID,
+		UserId:    userID,
+		Message:   message,
+		CreateAt:  createAt,
+	}
+}
+
+func TestEmbeddedEngineSearchPosts(t *testing.T) {
+	engine := newTestEngine(t, t.TempDir(), model.EmbeddedSearchAnalyzerVietnamese)
+
+	channel := &model.Channel{Id: model.NewId()}
+	otherChannel := &model.Channel{Id: model.NewId()}
+	userID := model.NewId()
+
+	vietnamese := newTestPost(channel.Id, userID, "Họp nhóm lúc 9 giờ sáng", 1000)
+	english := newTestPost(channel.Id, model.NewId(), "Morning standup notes", 2000)
+	hashtag := newTestPost(channel.Id, userID, "Release plan #phathanh", 3000)
+	hashtag.Hashtags = "#phathanh"
+	elsewhere := newTestPost(otherChannel.Id, userID, "Họp nhóm ở kênh khác", 4000)
+	for _, post := range []*model.Post{vietnamese, english, hashtag, elsewhere} {
+		require.Nil(t, engine.IndexPost(post, model.NewId()))
+	}
+
+	search := func(params ...*model.SearchParams) ([]string, model.PostSearchMatches) {
+		t.Helper()
+		ids, matches, appErr := engine.SearchPosts(model.ChannelList{channel}, params, 0, 20)
+		require.Nil(t, appErr)
+		return ids, matches
+	}
+
+	t.Run("finds words without tone marks", func(t *testing.T) {
+		ids, matches := search(&model.SearchParams{Terms: "hop nhom"})
+		assert.Equal(t, []string{vietnamese.Id}, ids)
+		assert.ElementsMatch(t, []string{"Họp", "nhóm"}, matches[vietnamese.Id])
+	})
+
+	t.Run("matches phrases in order", func(t *testing.T) {
+		ids, _ := search(&model.SearchParams{Terms: `"gio sang"`})
+		assert.Equal(t, []string{vietnamese.Id}, ids)
+
+		ids, _ = search(&model.SearchParams{Terms: `"sang gio"`})
+		assert.Empty(t, ids)
+	})
+
+	t.Run("matches prefixes", func(t *testing.T) {
+		ids, _ := search(&model.SearchParams{Terms: "stand*"})
+		assert.Equal(t, []string{english.Id}, ids)
+	})
+
+	t.Run("or terms return newest first", func(t *testing.T) {
+		ids, _ := search(&model.SearchParams{Terms: "hop morning", OrTerms: true})
+		assert.Equal(t, []string{english.Id, vietnamese.Id}, ids)
+	})
+
+	t.Run("excluded terms", func(t *testing.T) {
+		ids, _ := search(&model.SearchParams{Terms: "", ExcludedTerms: "morning", FromUsers: []string{english.UserId}})
+		assert.Empty(t, ids)
+	})
+
+	t.Run("hashtags", func(t *testing.T) {
+		ids, matches := search(&model.SearchParams{Terms: "#phathanh", IsHashtag: true})
+		assert.Equal(t, []string{hashtag.Id}, ids)
+		assert.Equal(t, []string{"#phathanh"}, matches[hashtag.Id])
+	})
+
+	t.Run("filters by user", func(t *testing.T) {
+		ids, _ := search(&model.SearchParams{FromUsers: []string{userID}})
+		assert.Equal(t, []string{hashtag.Id, vietnamese.Id}, ids)
+	})
+
+	t.Run("deleted posts are removed", func(t *testing.T) {
+		deleted := newTestPost(channel.Id, userID, "tạm thời", 5000)
+		require.Nil(t, engine.IndexPost(deleted, ""))
+		ids, _ := search(&model.SearchParams{Terms: "tam thoi"})
+		require.Equal(t, []string{deleted.Id}, ids)
+
+		deleted.DeleteAt = 6000
+		require.Nil(t, engine.IndexPost(deleted, ""))
+		ids, _ = search(&model.SearchParams{Terms: "tam thoi"})
+		assert.Empty(t, ids)
+	})
+}
+
+func TestEmbeddedEngineSearchChinese(t *testing.T) {
+	engine := newTestEngine(t, t.TempDir(), model.EmbeddedSearchAnalyzerChineseSimple)
+
+	channel := &model.Channel{Id: model.NewId()}
+	match := newTestPost(channel.Id, model.NewId(), "我们今天讨论全文搜索功能", 1000)
+	split := newTestPost(channel.Id, model.NewId(), "全文档已经上传，搜索一下", 2000)
+	require.Nil(t, engine.IndexPost(match, ""))
+	require.Nil(t, engine.IndexPost(split, ""))
+
+	ids, matches, appErr := engine.SearchPosts(model.ChannelList{channel}, []*model.SearchParams{{Terms: "全文搜索"}}, 0, 20)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{match.Id}, ids)
+	assert.Contains(t, matches[match.Id], "全文")
+
+	ids, _, appErr = engine.SearchPosts(model.ChannelList{channel}, []*model.SearchParams{{Terms: "搜"}}, 0, 20)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{split.Id, match.Id}, ids)
+}
+
+func TestEmbeddedEngineSearchFiles(t *testing.T) {
+	engine := newTestEngine(t, t.TempDir(), model.EmbeddedSearchAnalyzerVietnamese)
+
+	channel := &model.Channel{Id: model.NewId()}
+	report := &model.FileInfo{Id: model.NewId(), Name: "bao-cao-quy.pdf", Extension: "pdf", Content: "Doanh thu tăng trưởng", CreateAt: 1000}
+	sheet := &model.FileInfo{Id: model.NewId(), Name: "doanh-thu.xlsx", Extension: "xlsx", CreateAt: 2000}
+	require.Nil(t, engine.IndexFile(report, channel.Id))
+	require.Nil(t, engine.IndexFile(sheet, channel.Id))
+
+	ids, appErr := engine.SearchFiles(model.ChannelList{channel}, []*model.SearchParams{{Terms: "doanh thu"}}, 0, 20)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{sheet.Id, report.Id}, ids)
+
+	ids, appErr = engine.SearchFiles(model.ChannelList{channel}, []*model.SearchParams{{Terms: "tang truong", Extensions: []string{"pdf"}}}, 0, 20)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{report.Id}, ids)
+
+	require.Nil(t, engine.DeleteFilesBatch(request.TestContext(t), 1500, 10))
+	ids, appErr = engine.SearchFiles(model.ChannelList{channel}, []*model.SearchParams{{Terms: "doanh"}}, 0, 20)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{sheet.Id}, ids)
+}
+
+func TestEmbeddedEngineSearchChannelsAndUsers(t *testing.T) {
+	engine := newTestEngine(t, t.TempDir(), model.EmbeddedSearchAnalyzerVietnamese)
+	rctx := request.TestContext(t)
+
+	teamID := model.NewId()
+	userID := model.NewId()
+	public := &model.Channel{Id: model.NewId(), TeamId: teamID, Type: model.ChannelTypeOpen, Name: "phong-ke-toan", DisplayName: "Phòng Kế toán"}
+	private := &model.Channel{Id: model.NewId(), TeamId: teamID, Type: model.ChannelTypePrivate, Name: "ke-hoach", DisplayName: "Kế hoạch"}
+	require.Nil(t, engine.IndexChannel(rctx, public, nil, []string{userID}))
+	require.Nil(t, engine.IndexChannel(rctx, private, []string{model.NewId()}, []string{userID}))
+
+	channelIDs, appErr := engine.SearchChannels(teamID, userID, "ke", false, false)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{public.Id}, channelIDs)
+
+	user := &model.User{Id: model.NewId(), Username: "an.nv", FirstName: "Văn An", LastName: "Nguyễn", Roles: model.SystemUserRoleId}
+	require.Nil(t, engine.IndexUser(rctx, user, []string{teamID}, []string{public.Id}))
+
+	userIDs, appErr := engine.SearchUsersInTeam(teamID, nil, "nv", &model.UserSearchOptions{Limit: 10})
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{user.Id}, userIDs)
+
+	userIDs, appErr = engine.SearchUsersInTeam(teamID, nil, "nguyen", &model.UserSearchOptions{Limit: 10})
+	require.Nil(t, appErr)
+	assert.Empty(t, userIDs, "full names are only searched when allowed")
+
+	inChannel, notInChannel, appErr := engine.SearchUsersInChannel(teamID, public.Id, nil, "nguyen", &model.UserSearchOptions{Limit: 10, AllowFullNames: true})
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{user.Id}, inChannel)
+	assert.Empty(t, notInChannel)
+}
+
+func TestEmbeddedEnginePersistence(t *testing.T) {
+	dir := t.TempDir()
+	channel := &model.Channel{Id: model.NewId()}
+	post := newTestPost(channel.Id, model.NewId(), "Cuộc họp đã được lưu", 1000)
+
+	engine := newTestEngine(t, dir, model.EmbeddedSearchAnalyzerStandard)
+	require.Nil(t, engine.IndexPost(post, ""))
+	require.Nil(t, engine.Stop())
+
+	t.Run("documents survive a restart", func(t *testing.T) {
+		engine := newTestEngine(t, dir, model.EmbeddedSearchAnalyzerStandard)
+		ids, _, appErr := engine.SearchPosts(model.ChannelList{channel}, []*model.SearchParams{{Terms: "họp"}}, 0, 20)
+		require.Nil(t, appErr)
+		assert.Equal(t, []string{post.Id}, ids)
+		require.Nil(t, engine.Stop())
+	})
+
+	t.Run("changing the analyzer empties the index", func(t *testing.T) {
+		engine := newTestEngine(t, dir, model.EmbeddedSearchAnalyzerVietnamese)
+		ids, _, appErr := engine.SearchPosts(model.ChannelList{channel}, []*model.SearchParams{{Terms: "hop"}}, 0, 20)
+		require.Nil(t, appErr)
+		assert.Empty(t, ids)
+
+		require.Nil(t, engine.IndexPost(post, ""))
+		ids, _, appErr = engine.SearchPosts(model.ChannelList{channel}, []*model.SearchParams{{Terms: "hop"}}, 0, 20)
+		require.Nil(t, appErr)
+		assert.Equal(t, []string{post.Id}, ids)
+
+		require.Nil(t, engine.PurgeIndexes(request.TestContext(t)))
+		ids, _, appErr = engine.SearchPosts(model.ChannelList{channel}, []*model.SearchParams{{Terms: "hop"}}, 0, 20)
+		require.Nil(t, appErr)
+		assert.Empty(t, ids)
+		require.Nil(t, engine.Stop())
+	})
+
+	t.Run("data retention deletes old posts", func(t *testing.T) {
+		engine := newTestEngine(t, dir, model.EmbeddedSearchAnalyzerStandard)
+		old := newTestPost(channel.Id, model.NewId(), "old news", 1000)
+		recent := newTestPost(channel.Id, model.NewId(), "recent news", model.GetMillis())
+		require.Nil(t, engine.IndexPost(old, ""))
+		require.Nil(t, engine.IndexPost(recent, ""))
+
+		require.Nil(t, engine.DataRetentionDeleteIndexes(request.TestContext(t), time.Now().Add(-time.Hour)))
+		ids, _, appErr := engine.SearchPosts(model.ChannelList{channel}, []*model.SearchParams{{Terms: "news"}}, 0, 20)
+		require.Nil(t, appErr)
+		assert.Equal(t, []string{recent.Id}, ids)
+	})
+}
+
+func TestEmbeddedEngineNotStarted(t *testing.T) {
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	engine := NewEmbeddedEngine(cfg, mlog.CreateConsoleTestLogger(t))
+
+	assert.False(t, engine.IsActive())
+	require.Nil(t, engine.Start(), "starting with indexing disabled is a no-op")
+	assert.NotNil(t, engine.IndexPost(newTestPost(model.NewId(), model.NewId(), "hello", 1), ""))
+	assert.NotNil(t, engine.Stop())
+}
+
+func TestEmbeddedEngineCluster(t *testing.T) {
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	*cfg.EmbeddedSearchSettings.EnableIndexing = true
+	*cfg.EmbeddedSearchSettings.IndexDir = t.TempDir()
+	*cfg.ClusterSettings.Enable = true
+
+	t.Run("refuses to start", func(t *testing.T) {
+		engine := NewEmbeddedEngine(cfg, mlog.CreateConsoleTestLogger(t))
+		appErr := engine.Start()
+		require.NotNil(t, appErr)
+		assert.Equal(t, "embeddedengine.cluster_unsupported.error", appErr.Id)
+		assert.False(t, engine.IsActive())
+		assert.NotNil(t, engine.TestConfig(request.TestContext(t), cfg))
+	})
+
+	t.Run("becomes inactive when clustering is enabled later", func(t *testing.T) {
+		engine := newTestEngine(t, t.TempDir(), model.EmbeddedSearchAnalyzerStandard)
+		require.True(t, engine.IsActive())
+
+		engine.UpdateConfig(cfg)
+		assert.False(t, engine.IsActive())
+	})
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+)
+
+// fileNameWords returns a file name with everything but letters and digits replaced by
+// spaces, so that "doanh-thu.xlsx" is found by each of its words.
+func fileNameWords(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) {
+			return r
+		}
+		return ' '
+	}, name)
+}
+
+func fileDocFromFileInfo(file *model.FileInfo, channelID, content string) *fileDoc {
+	return &fileDoc{
+		ID:        file.Id,
+		CreatorID: file.CreatorId,
+		ChannelID: channelID,
+		PostID:    file.PostId,
+		CreateAt:  file.CreateAt,
+		Extension: file.Extension,
+		Name:      fileNameWords(file.Name),
+		Content:   content,
+	}
+}
+
+func (e *EmbeddedEngine) IndexFile(file *model.FileInfo, channelId string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.IndexFile")
+	}
+
+	if err := e.indexes[IndexBaseFiles].Index(file.Id, fileDocFromFileInfo(file, channelId, file.Content)); err != nil {
+		return indexError("EmbeddedEngine.IndexFile", err)
+	}
+	return nil
+}
+
+// IndexFilesBatch indexes files read by the indexing job. Files that should no longer be
+// found are removed.
+func (e *EmbeddedEngine) IndexFilesBatch(files []*model.FileForIndexing) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.IndexFilesBatch")
+	}
+
+	index := e.indexes[IndexBaseFiles]
+	batch := index.NewBatch()
+	for _, file := range files {
+		if !file.ShouldIndex() {
+			batch.Delete(file.Id)
+			continue
+		}
+		if err := batch.Index(file.Id, fileDocFromFileInfo(&file.FileInfo, file.ChannelId, file.Content)); err != nil {
+			return indexError("EmbeddedEngine.IndexFilesBatch", err)
+		}
+	}
+	if err := index.Batch(batch); err != nil {
+		return indexError("EmbeddedEngine.IndexFilesBatch", err)
+	}
+	return nil
+}
+
+func (e *EmbeddedEngine) SearchFiles(channels model.ChannelList, searchParams []*model.SearchParams, page, perPage int) ([]string, *model.AppError) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return []string{}, notStartedError("EmbeddedEngine.SearchFiles")
+	}
+	if !validPage(channels, searchParams, page, perPage) {
+		return []string{}, nil
+	}
+
+	req, _ := e.newSearchRequest(channels, searchParams, fileSearchFields, page, perPage)
+	fileIDs, err := searchIDs(e.indexes[IndexBaseFiles], req)
+	if err != nil {
+		return []string{}, searchError("EmbeddedEngine.SearchFiles", err)
+	}
+	return fileIDs, nil
+}
+
+func (e *EmbeddedEngine) DeleteFile(fileID string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.DeleteFile")
+	}
+
+	if err := e.indexes[IndexBaseFiles].Delete(fileID); err != nil {
+		return deleteError("EmbeddedEngine.DeleteFile", err)
+	}
+	return nil
+}
+
+func (e *EmbeddedEngine) DeletePostFiles(rctx request.CTX, postID string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.DeletePostFiles")
+	}
+
+	if err := deleteWhere(e.indexes[IndexBaseFiles], termQuery("post_id", postID)); err != nil {
+		return deleteError("EmbeddedEngine.DeletePostFiles", err)
+	}
+	return nil
+}
+
+func (e *EmbeddedEngine) DeleteUserFiles(rctx request.CTX, userID string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.DeleteUserFiles")
+	}
+
+	if err := deleteWhere(e.indexes[IndexBaseFiles], termQuery("creator_id", userID)); err != nil {
+		return deleteError("EmbeddedEngine.DeleteUserFiles", err)
+	}
+	return nil
+}
+
+// DeleteFilesBatch removes up to limit files created at or before endTime, oldest first.
+func (e *EmbeddedEngine) DeleteFilesBatch(rctx request.CTX, endTime, limit int64) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.DeleteFilesBatch")
+	}
+	if limit <= 0 {
+		return nil
+	}
+
+	index := e.indexes[IndexBaseFiles]
+	end := float64(endTime)
+	req := bleve.NewSearchRequestOptions(createAtRange(nil, &end), int(limit), 0, false)
+	req.SortBy([]string{"create_at", "_id"})
+	fileIDs, err := searchIDs(index, req)
+	if err != nil {
+		return deleteError("EmbeddedEngine.DeleteFilesBatch", err)
+	}
+
+	batch := index.NewBatch()
+	for _, id := range fileIDs {
+		batch.Delete(id)
+	}
+	if err := index.Batch(batch); err != nil {
+		return deleteError("EmbeddedEngine.DeleteFilesBatch", err)
+	}
+	return nil
+}
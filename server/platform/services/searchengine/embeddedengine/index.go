@@ -0,0 +1,301 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+const (
+	IndexBasePosts    = "posts"
+	IndexBaseFiles    = "files"
+	IndexBaseChannels = "channels"
+	IndexBaseUsers    = "users"
+
+	indexDirExt = ".bleve"
+
+	// analyzerKey is the internal key under which an index records the analyzer it was
+	// built with.
+	analyzerKey = "analyzer"
+
+	// deleteBatchSize is the number of documents removed per batch by deleteWhere.
+	deleteBatchSize = 1000
+)
+
+var indexNames = []string{IndexBasePosts, IndexBaseFiles, IndexBaseChannels, IndexBaseUsers}
+
+// postDoc is the indexed form of a post. Hashtags holds the post's hashtags as written,
+// for highlighting, and HashtagTerms their folded form, for searching.
+type postDoc struct {
+	ID           string   `json:"-"`
+	TeamID       string   `json:"team_id"`
+	ChannelID    string   `json:"channel_id"`
+	UserID       string   `json:"user_id"`
+	CreateAt     int64    `json:"create_at"`
+	Message      string   `json:"message"`
+	Attachments  string   `json:"attachments"`
+	Hashtags     []string `json:"hashtags"`
+	HashtagTerms []string `json:"hashtag_terms"`
+}
+
+// fileDoc is the indexed form of a file.
+type fileDoc struct {
+	ID        string `json:"-"`
+	CreatorID string `json:"creator_id"`
+	ChannelID string `json:"channel_id"`
+	PostID    string `json:"post_id"`
+	CreateAt  int64  `json:"create_at"`
+	Extension string `json:"extension"`
+	Name      string `json:"name"`
+	Content   string `json:"content"`
+}
+
+// channelDoc is the indexed form of a channel. UserIDs are only set for private channels.
+type channelDoc struct {
+	ID            string   `json:"-"`
+	Type          string   `json:"type"`
+	Deleted       bool     `json:"deleted"`
+	TeamID        string   `json:"team_id"`
+	UserIDs       []string `json:"user_ids"`
+	TeamMemberIDs []string `json:"team_member_ids"`
+	Suggestions   []string `json:"suggestions"`
+	SortName      string   `json:"sort_name"`
+}
+
+// userDoc is the indexed form of a user. FullNameSuggestions are kept apart so that full
+// names are only searched when the caller allows it.
+type userDoc struct {
+	ID                  string   `json:"-"`
+	Username            string   `json:"username"`
+	Deleted             bool     `json:"deleted"`
+	Roles               []string `json:"roles"`
+	TeamsIDs            []string `json:"teams_ids"`
+	ChannelsIDs         []string `json:"channels_ids"`
+	Suggestions         []string `json:"suggestions"`
+	FullNameSuggestions []string `json:"full_name_suggestions"`
+}
+
+func keywordField() *mapping.FieldMapping {
+	f := bleve.NewKeywordFieldMapping()
+	f.Store = false
+	f.IncludeTermVectors = false
+	f.IncludeInAll = false
+	return f
+}
+
+func numericField() *mapping.FieldMapping {
+	f := bleve.NewNumericFieldMapping()
+	f.Store = false
+	f.IncludeInAll = false
+	return f
+}
+
+func booleanField() *mapping.FieldMapping {
+	f := bleve.NewBooleanFieldMapping()
+	f.Store = false
+	f.DocValues = false
+	f.IncludeInAll = false
+	return f
+}
+
+// textField is analyzed with textAnalyzer and keeps term positions for phrases and
+// highlighting. stored fields can be returned with search results.
+func textField(stored bool) *mapping.FieldMapping {
+	f := bleve.NewTextFieldMapping()
+	f.Analyzer = textAnalyzer
+	f.Store = stored
+	f.DocValues = false
+	f.IncludeInAll = false
+	return f
+}
+
+// storedField is returned with search results but not searchable.
+func storedField() *mapping.FieldMapping {
+	f := bleve.NewTextFieldMapping()
+	f.Index = false
+	f.IncludeTermVectors = false
+	f.DocValues = false
+	f.IncludeInAll = false
+	return f
+}
+
+func documentMapping(name string) *mapping.DocumentMapping {
+	dm := bleve.NewDocumentStaticMapping()
+	add := func(field string, f *mapping.FieldMapping) {
+		dm.AddFieldMappingsAt(field, f)
+	}
+
+	switch name {
+	case IndexBasePosts:
+		add("team_id", keywordField())
+		add("channel_id", keywordField())
+		add("user_id", keywordField())
+		add("create_at", numericField())
+		add("message", textField(true))
+		add("attachments", textField(true))
+		add("hashtags", storedField())
+		add("hashtag_terms", keywordField())
+	case IndexBaseFiles:
+		add("creator_id", keywordField())
+		add("channel_id", keywordField())
+		add("post_id", keywordField())
+		add("create_at", numericField())
+		add("extension", keywordField())
+		add("name", textField(false))
+		add("content", textField(false))
+	case IndexBaseChannels:
+		add("type", keywordField())
+		add("deleted", booleanField())
+		add("team_id", keywordField())
+		add("user_ids", keywordField())
+		add("team_member_ids", keywordField())
+		add("suggestions", keywordField())
+		add("sort_name", keywordField())
+	case IndexBaseUsers:
+		add("username", keywordField())
+		add("deleted", booleanField())
+		add("roles", keywordField())
+		add("teams_ids", keywordField())
+		add("channels_ids", keywordField())
+		add("suggestions", keywordField())
+		add("full_name_suggestions", keywordField())
+	}
+	return dm
+}
+
+func indexPath(dir, name string) string {
+	return filepath.Join(dir, name+indexDirExt)
+}
+
+// createIndex creates an empty index for the analyzer, replacing any index at its path.
+func createIndex(dir, name, analyzerName string) (bleve.Index, error) {
+	m, err := newIndexMapping(analyzerName)
+	if err != nil {
+		return nil, err
+	}
+	m.DefaultMapping = documentMapping(name)
+	m.IndexDynamic = false
+	m.StoreDynamic = false
+	m.DocValuesDynamic = false
+
+	path := indexPath(dir, name)
+	if err := os.RemoveAll(path); err != nil {
+		return nil, err
+	}
+	index, err := bleve.New(path, m)
+	if err != nil {
+		return nil, err
+	}
+	if err := index.SetInternal([]byte(analyzerKey), []byte(analyzerName)); err != nil {
+		index.Close()
+		return nil, err
+	}
+	return index, nil
+}
+
+// openIndex opens an index, creating it if it doesn't exist. An index built with another
+// analyzer can't be searched with this one, so it is replaced by an empty index and
+// rebuilt reports true.
+func openIndex(dir, name, analyzerName string) (index bleve.Index, rebuilt bool, err error) {
+	index, err = bleve.Open(indexPath(dir, name))
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = createIndex(dir, name, analyzerName)
+		return index, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	built, err := index.GetInternal([]byte(analyzerKey))
+	if err != nil {
+		index.Close()
+		return nil, false, err
+	}
+	if string(built) == analyzerName {
+		return index, false, nil
+	}
+
+	if err := index.Close(); err != nil {
+		return nil, false, err
+	}
+	index, err = createIndex(dir, name, analyzerName)
+	return index, true, err
+}
+
+// deleteWhere removes every document of index matching q.
+func deleteWhere(index bleve.Index, q query.Query) error {
+	for {
+		result, err := index.Search(bleve.NewSearchRequestOptions(q, deleteBatchSize, 0, false))
+		if err != nil {
+			return err
+		}
+		if len(result.Hits) == 0 {
+			return nil
+		}
+		batch := index.NewBatch()
+		for _, hit := range result.Hits {
+			batch.Delete(hit.ID)
+		}
+		if err := index.Batch(batch); err != nil {
+			return err
+		}
+	}
+}
+
+func termQuery(field, term string) query.Query {
+	q := bleve.NewTermQuery(term)
+	q.SetField(field)
+	return q
+}
+
+func prefixQuery(field, prefix string) query.Query {
+	q := bleve.NewPrefixQuery(prefix)
+	q.SetField(field)
+	return q
+}
+
+func boolFieldQuery(field string, value bool) query.Query {
+	q := bleve.NewBoolFieldQuery(value)
+	q.SetField(field)
+	return q
+}
+
+// anyTerm matches documents whose field holds any of the terms.
+func anyTerm(field string, terms []string) query.Query {
+	disjuncts := make([]query.Query, len(terms))
+	for i, term := range terms {
+		disjuncts[i] = termQuery(field, term)
+	}
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
+
+// allOf matches documents matching every query in must and none in mustNot.
+func allOf(must, mustNot []query.Query) query.Query {
+	if len(must) == 0 {
+		must = []query.Query{bleve.NewMatchAllQuery()}
+	}
+	q := bleve.NewBooleanQuery()
+	q.AddMust(must...)
+	q.AddMustNot(mustNot...)
+	return q
+}
+
+// searchIDs returns the IDs of the documents matching a search request.
+func searchIDs(index bleve.Index, req *bleve.SearchRequest) ([]string, error) {
+	result, err := index.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(result.Hits))
+	for i, hit := range result.Hits {
+		ids[i] = hit.ID
+	}
+	return ids, nil
+}
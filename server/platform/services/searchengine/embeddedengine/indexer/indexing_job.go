@@ -0,0 +1,211 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package indexer holds the jobs that fill and empty the embedded search index.
+package indexer
+
+import (
+	"strconv"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	ejobs "github.com/mattermost/mattermost/server/v8/einterfaces/jobs"
+	"github.com/mattermost/mattermost/server/v8/platform/services/searchengine/embeddedengine"
+)
+
+// IndexerJob indexes every post, file, channel and user in the database. Like the
+// Elasticsearch indexing job, job data can skip entities ("index_posts": "false") and
+// bound the creation times indexed ("start_time", "end_time").
+type IndexerJob struct {
+	jobServer *jobs.JobServer
+	engine    *embeddedengine.EmbeddedEngine
+}
+
+var _ ejobs.IndexerJobInterface = (*IndexerJob)(nil)
+
+func NewIndexerJob(jobServer *jobs.JobServer, engine *embeddedengine.EmbeddedEngine) *IndexerJob {
+	return &IndexerJob{jobServer: jobServer, engine: engine}
+}
+
+func isEnabled(cfg *model.Config) bool {
+	return *cfg.EmbeddedSearchSettings.EnableIndexing
+}
+
+func (j *IndexerJob) MakeWorker() model.Worker {
+	return jobs.NewSimpleWorker("EmbeddedSearchIndexing", j.jobServer, j.doJob, isEnabled)
+}
+
+// entityIndexer indexes one kind of entity a batch at a time, returning the creation time
+// and ID of the last entity of the batch and the batch size.
+type entityIndexer struct {
+	name  string
+	count func() (int64, error)
+	index func(rctx request.CTX, startTime int64, startID string, limit int) (int64, string, int, error)
+}
+
+func (j *IndexerJob) entities() []entityIndexer {
+	store := j.jobServer.Store
+	return []entityIndexer{
+		{
+			name: "posts",
+			count: func() (int64, error) {
+				return store.Post().AnalyticsPostCount(&model.PostCountOptions{})
+			},
+			index: func(_ request.CTX, startTime int64, startID string, limit int) (int64, string, int, error) {
+				posts, err := store.Post().GetPostsBatchForIndexing(startTime, startID, limit)
+				if err != nil || len(posts) == 0 {
+					return 0, "", 0, err
+				}
+				if appErr := j.engine.IndexPostsBatch(posts); appErr != nil {
+					return 0, "", 0, appErr
+				}
+				last := posts[len(posts)-1]
+				return last.CreateAt, last.Id, len(posts), nil
+			},
+		},
+		{
+			name:  "files",
+			count: store.FileInfo().CountAll,
+			index: func(_ request.CTX, startTime int64, startID string, limit int) (int64, string, int, error) {
+				files, err := store.FileInfo().GetFilesBatchForIndexing(startTime, startID, true, limit)
+				if err != nil || len(files) == 0 {
+					return 0, "", 0, err
+				}
+				if appErr := j.engine.IndexFilesBatch(files); appErr != nil {
+					return 0, "", 0, appErr
+				}
+				last := files[len(files)-1]
+				return last.CreateAt, last.Id, len(files), nil
+			},
+		},
+		{
+			name: "channels",
+			count: func() (int64, error) {
+				return store.Channel().AnalyticsTypeCount("", "")
+			},
+			index: func(rctx request.CTX, startTime int64, startID string, limit int) (int64, string, int, error) {
+				channels, err := store.Channel().GetChannelsBatchForIndexing(startTime, startID, limit)
+				if err != nil || len(channels) == 0 {
+					return 0, "", 0, err
+				}
+				for _, channel := range channels {
+					var userIDs []string
+					if channel.Type == model.ChannelTypePrivate {
+						if userIDs, err = store.Channel().GetAllChannelMemberIdsByChannelId(channel.Id); err != nil {
+							return 0, "", 0, err
+						}
+					}
+					teamMemberIDs, err := store.Channel().GetTeamMembersForChannel(rctx, channel.Id)
+					if err != nil {
+						return 0, "", 0, err
+					}
+					if appErr := j.engine.IndexChannel(rctx, channel, userIDs, teamMemberIDs); appErr != nil {
+						return 0, "", 0, appErr
+					}
+				}
+				last := channels[len(channels)-1]
+				return last.CreateAt, last.Id, len(channels), nil
+			},
+		},
+		{
+			name: "users",
+			count: func() (int64, error) {
+				return store.User().Count(model.UserCountOptions{IncludeBotAccounts: true})
+			},
+			index: func(_ request.CTX, startTime int64, startID string, limit int) (int64, string, int, error) {
+				users, err := store.User().GetUsersBatchForIndexing(startTime, startID, limit)
+				if err != nil || len(users) == 0 {
+					return 0, "", 0, err
+				}
+				if appErr := j.engine.IndexUsersBatch(users); appErr != nil {
+					return 0, "", 0, appErr
+				}
+				last := users[len(users)-1]
+				return last.CreateAt, last.Id, len(users), nil
+			},
+		},
+	}
+}
+
+func (j *IndexerJob) doJob(logger mlog.LoggerIFace, job *model.Job) error {
+	defer j.jobServer.HandleJobPanic(logger, job)
+
+	if job.Data == nil {
+		job.Data = model.StringMap{}
+	}
+
+	startTime, err := j.timeFromJobData(job, "start_time")
+	if err != nil {
+		return err
+	}
+	endTime, err := j.timeFromJobData(job, "end_time")
+	if err != nil {
+		return err
+	}
+
+	var entities []entityIndexer
+	var total int64
+	for _, entity := range j.entities() {
+		if job.Data["index_"+entity.name] == "false" {
+			continue
+		}
+		entities = append(entities, entity)
+		// Counting can time out on large tables; only progress reporting suffers.
+		count, err := entity.count()
+		if err != nil {
+			logger.Warn("Failed to count entities to index", mlog.String("entity", entity.name), mlog.Err(err))
+			continue
+		}
+		total += count
+	}
+
+	rctx := request.EmptyContext(logger)
+	batchSize := *j.jobServer.Config().EmbeddedSearchSettings.BatchSize
+	var done int64
+	for _, entity := range entities {
+		lastTime, lastID := startTime, ""
+		var entityDone int64
+		for {
+			batchTime, batchID, n, err := entity.index(rctx, lastTime, lastID, batchSize)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				break
+			}
+			lastTime, lastID = batchTime, batchID
+			entityDone += int64(n)
+			done += int64(n)
+
+			job.Data["done_"+entity.name+"_count"] = strconv.FormatInt(entityDone, 10)
+			if total > 0 {
+				if appErr := j.jobServer.SetJobProgress(job, min(done*100/total, 99)); appErr != nil {
+					return appErr
+				}
+			}
+			if lastTime >= endTime {
+				break
+			}
+		}
+		logger.Info("Indexed entities for embedded search", mlog.String("entity", entity.name), mlog.Int("count", entityDone))
+	}
+
+	if appErr := j.engine.RefreshIndexes(rctx); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// timeFromJobData reads start_time or end_time from the job data, defaulting to the
+// oldest entity and now.
+func (j *IndexerJob) timeFromJobData(job *model.Job, key string) (int64, error) {
+	if value, ok := job.Data[key]; ok {
+		return strconv.ParseInt(value, 10, 64)
+	}
+	if key == "end_time" {
+		return model.GetMillis(), nil
+	}
+	return j.jobServer.Store.Post().GetOldestEntityCreationTime()
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package indexer
+
+import (
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	ejobs "github.com/mattermost/mattermost/server/v8/einterfaces/jobs"
+	"github.com/mattermost/mattermost/server/v8/platform/services/searchengine/embeddedengine"
+)
+
+// PurgeJob empties the embedded search index, for example before a full reindex.
+type PurgeJob struct {
+	jobServer *jobs.JobServer
+	engine    *embeddedengine.EmbeddedEngine
+}
+
+var _ ejobs.IndexerJobInterface = (*PurgeJob)(nil)
+
+func NewPurgeJob(jobServer *jobs.JobServer, engine *embeddedengine.EmbeddedEngine) *PurgeJob {
+	return &PurgeJob{jobServer: jobServer, engine: engine}
+}
+
+func (j *PurgeJob) MakeWorker() model.Worker {
+	return jobs.NewSimpleWorker("EmbeddedSearchPurge", j.jobServer, j.doJob, isEnabled)
+}
+
+func (j *PurgeJob) doJob(logger mlog.LoggerIFace, job *model.Job) error {
+	defer j.jobServer.HandleJobPanic(logger, job)
+
+	if appErr := j.engine.PurgeIndexes(request.EmptyContext(logger)); appErr != nil {
+		return appErr
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+)
+
+// hashtagTerm returns the indexed form of a hashtag.
+func hashtagTerm(analyzerName, hashtag string) string {
+	return "#" + fold(analyzerName, strings.TrimLeft(hashtag, "#"))
+}
+
+func postDocFromPost(post *model.Post, teamID, analyzerName string) *postDoc {
+	doc := &postDoc{
+		ID:        post.Id,
+		TeamID:    teamID,
+		ChannelID: post.ChannelId,
+		UserID:    post.UserId,
+		CreateAt:  post.CreateAt,
+		Message:   post.Message,
+		Hashtags:  strings.Fields(post.Hashtags),
+	}
+	for _, hashtag := range doc.Hashtags {
+		doc.HashtagTerms = append(doc.HashtagTerms, hashtagTerm(analyzerName, hashtag))
+	}
+
+	var attachments []string
+	switch v := post.GetProp(model.PostPropsAttachments).(type) {
+	case []any:
+		for _, attachment := range v {
+			if m, ok := attachment.(map[string]any); ok {
+				if text, ok := m["text"].(string); ok {
+					attachments = append(attachments, text)
+				}
+			}
+		}
+	case []*model.SlackAttachment:
+		for _, attachment := range v {
+			if attachment != nil {
+				attachments = append(attachments, attachment.Text)
+			}
+		}
+	}
+	doc.Attachments = strings.Join(attachments, " ")
+
+	return doc
+}
+
+// searchablePost reports whether a post can be found by search. Other posts are removed
+// from the index.
+func searchablePost(post *model.Post) bool {
+	if post.DeleteAt != 0 {
+		return false
+	}
+	return post.Type == model.PostTypeDefault || post.Type == model.PostTypeSlackAttachment
+}
+
+func (e *EmbeddedEngine) IndexPost(post *model.Post, teamId string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.IndexPost")
+	}
+
+	index := e.indexes[IndexBasePosts]
+	var err error
+	if searchablePost(post) {
+		err = index.Index(post.Id, postDocFromPost(post, teamId, e.analyzer))
+	} else {
+		err = index.Delete(post.Id)
+	}
+	if err != nil {
+		return indexError("EmbeddedEngine.IndexPost", err)
+	}
+	return nil
+}
+
+// IndexPostsBatch indexes posts read by the indexing job. Deleted posts are removed.
+func (e *EmbeddedEngine) IndexPostsBatch(posts []*model.PostForIndexing) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.IndexPostsBatch")
+	}
+
+	index := e.indexes[IndexBasePosts]
+	batch := index.NewBatch()
+	for _, post := range posts {
+		if !searchablePost(&post.Post) {
+			batch.Delete(post.Id)
+			continue
+		}
+		if err := batch.Index(post.Id, postDocFromPost(&post.Post, post.TeamId, e.analyzer)); err != nil {
+			return indexError("EmbeddedEngine.IndexPostsBatch", err)
+		}
+	}
+	if err := index.Batch(batch); err != nil {
+		return indexError("EmbeddedEngine.IndexPostsBatch", err)
+	}
+	return nil
+}
+
+func (e *EmbeddedEngine) SearchPosts(channels model.ChannelList, searchParams []*model.SearchParams, page, perPage int) ([]string, model.PostSearchMatches, *model.AppError) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return []string{}, nil, notStartedError("EmbeddedEngine.SearchPosts")
+	}
+	if !validPage(channels, searchParams, page, perPage) {
+		return []string{}, model.PostSearchMatches{}, nil
+	}
+
+	req, clauses := e.newSearchRequest(channels, searchParams, postSearchFields, page, perPage)
+	result, err := e.indexes[IndexBasePosts].Search(req)
+	if err != nil {
+		return []string{}, nil, searchError("EmbeddedEngine.SearchPosts", err)
+	}
+
+	postIDs := make([]string, len(result.Hits))
+	matches := make(model.PostSearchMatches, len(result.Hits))
+	for i, hit := range result.Hits {
+		postIDs[i] = hit.ID
+		found := highlights(hit, postSearchFields.text)
+		hashtags := storedStrings(hit.Fields["hashtags"])
+		for _, c := range clauses {
+			if c.hashtag {
+				found = append(found, e.matchingHashtags(c, hashtags)...)
+			}
+		}
+		matches[hit.ID] = found
+	}
+
+	return postIDs, matches, nil
+}
+
+func (e *EmbeddedEngine) matchingHashtags(c clause, hashtags []string) []string {
+	var found []string
+	for _, hashtag := range hashtags {
+		term := hashtagTerm(e.analyzer, hashtag)
+		if term == c.terms[0] || (c.prefix && strings.HasPrefix(term, c.terms[0])) {
+			found = append(found, hashtag)
+		}
+	}
+	return found
+}
+
+func (e *EmbeddedEngine) DeletePost(post *model.Post) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.DeletePost")
+	}
+
+	if err := e.indexes[IndexBasePosts].Delete(post.Id); err != nil {
+		return deleteError("EmbeddedEngine.DeletePost", err)
+	}
+	return nil
+}
+
+func (e *EmbeddedEngine) DeleteChannelPosts(rctx request.CTX, channelID string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.DeleteChannelPosts")
+	}
+
+	if err := deleteWhere(e.indexes[IndexBasePosts], termQuery("channel_id", channelID)); err != nil {
+		return deleteError("EmbeddedEngine.DeleteChannelPosts", err)
+	}
+	return nil
+}
+
+func (e *EmbeddedEngine) DeleteUserPosts(rctx request.CTX, userID string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.DeleteUserPosts")
+	}
+
+	if err := deleteWhere(e.indexes[IndexBasePosts], termQuery("user_id", userID)); err != nil {
+		return deleteError("EmbeddedEngine.DeleteUserPosts", err)
+	}
+	return nil
+}
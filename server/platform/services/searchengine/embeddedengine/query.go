@@ -0,0 +1,137 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+var queryTermRe = regexp.MustCompile(`"[^"]*"|\S+`)
+
+// clause is one word, quoted phrase or hashtag of a search.
+type clause struct {
+	terms   []string
+	prefix  bool // the last term matches any term starting with it
+	hashtag bool
+}
+
+// parseClauses splits search terms into clauses. A word the analyzer splits into several
+// terms, such as an email address or a run of Chinese text, must match as a phrase.
+func (e *EmbeddedEngine) parseClauses(terms string, hashtag bool) []clause {
+	var clauses []clause
+	for _, raw := range queryTermRe.FindAllString(terms, -1) {
+		prefix := false
+		if strings.HasSuffix(raw, "*") {
+			prefix = true
+			raw = strings.TrimRight(raw, "*")
+		}
+
+		if hashtag {
+			raw = strings.TrimLeft(raw, "#")
+			if raw == "" {
+				continue
+			}
+			clauses = append(clauses, clause{terms: []string{"#" + fold(e.analyzer, raw)}, prefix: prefix, hashtag: true})
+			continue
+		}
+
+		raw = strings.Trim(raw, `"`)
+		var words []string
+		for _, token := range e.queryAnalyzer.Analyze([]byte(raw)) {
+			words = append(words, string(token.Term))
+		}
+		if len(words) == 0 {
+			continue
+		}
+		clauses = append(clauses, clause{terms: words, prefix: prefix})
+	}
+	return clauses
+}
+
+// query returns the query matching the clause in any of the text fields, or, for a
+// hashtag, in hashtagField. Documents without hashtags pass an empty hashtagField.
+func (c *clause) query(fields []string, hashtagField string) query.Query {
+	if c.hashtag {
+		switch {
+		case hashtagField == "":
+			return bleve.NewMatchNoneQuery()
+		case c.prefix:
+			return prefixQuery(hashtagField, c.terms[0])
+		default:
+			return termQuery(hashtagField, c.terms[0])
+		}
+	}
+
+	disjuncts := make([]query.Query, len(fields))
+	for i, field := range fields {
+		last := len(c.terms) - 1
+		switch {
+		case !c.prefix && last > 0:
+			disjuncts[i] = bleve.NewPhraseQuery(c.terms, field)
+		case !c.prefix:
+			disjuncts[i] = termQuery(field, c.terms[0])
+		default:
+			conjuncts := make([]query.Query, 0, len(c.terms))
+			for _, term := range c.terms[:last] {
+				conjuncts = append(conjuncts, termQuery(field, term))
+			}
+			conjuncts = append(conjuncts, prefixQuery(field, c.terms[last]))
+			disjuncts[i] = bleve.NewConjunctionQuery(conjuncts...)
+		}
+	}
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
+
+// highlights returns the distinct pieces of a hit's stored text fields that matched the
+// search, in the order of the fields and then of the text.
+func highlights(hit *search.DocumentMatch, fields []string) []string {
+	seen := map[string]struct{}{}
+	var found []string
+	for _, field := range fields {
+		text, _ := hit.Fields[field].(string)
+		var locations []*search.Location
+		for _, termLocations := range hit.Locations[field] {
+			locations = append(locations, termLocations...)
+		}
+		slices.SortFunc(locations, func(a, b *search.Location) int {
+			return cmp.Or(cmp.Compare(a.Start, b.Start), cmp.Compare(a.End, b.End))
+		})
+		for _, location := range locations {
+			if location.End > uint64(len(text)) {
+				continue
+			}
+			match := text[location.Start:location.End]
+			if _, ok := seen[match]; !ok {
+				seen[match] = struct{}{}
+				found = append(found, match)
+			}
+		}
+	}
+	return found
+}
+
+// storedStrings returns the values of a stored field, which Bleve returns as a string
+// when it has one value and as a slice when it has several.
+func storedStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
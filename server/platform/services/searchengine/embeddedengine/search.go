@@ -0,0 +1,145 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// searchFields names the fields of an index that a post or file search uses.
+type searchFields struct {
+	channel   string
+	user      string
+	extension string // empty when the documents have no extension
+	hashtag   string // empty when the documents have no hashtags
+	text      []string
+	stored    []string // fields returned with hits to highlight matches, if any
+}
+
+var (
+	postSearchFields = searchFields{
+		channel: "channel_id",
+		user:    "user_id",
+		hashtag: "hashtag_terms",
+		text:    []string{"message", "attachments"},
+		stored:  []string{"message", "attachments", "hashtags"},
+	}
+	fileSearchFields = searchFields{
+		channel:   "channel_id",
+		user:      "creator_id",
+		extension: "extension",
+		text:      []string{"name", "content"},
+	}
+)
+
+func createAtRange(from, to *float64) query.Query {
+	inclusive := true
+	q := bleve.NewNumericRangeInclusiveQuery(from, to, &inclusive, &inclusive)
+	q.SetField("create_at")
+	return q
+}
+
+func millisRange(from, to int64) query.Query {
+	fromF, toF := float64(from), float64(to)
+	return createAtRange(&fromF, &toF)
+}
+
+// filterQueries returns the parts of a search that don't depend on text: channels, users,
+// dates and file extensions. They come with every SearchParams but are global to the
+// search, so only the first one's are used.
+func filterQueries(params *model.SearchParams, fields searchFields) (must, mustNot []query.Query) {
+	if len(params.InChannels) > 0 {
+		must = append(must, anyTerm(fields.channel, params.InChannels))
+	}
+	if len(params.ExcludedChannels) > 0 {
+		mustNot = append(mustNot, anyTerm(fields.channel, params.ExcludedChannels))
+	}
+	if len(params.FromUsers) > 0 {
+		must = append(must, anyTerm(fields.user, params.FromUsers))
+	}
+	if len(params.ExcludedUsers) > 0 {
+		mustNot = append(mustNot, anyTerm(fields.user, params.ExcludedUsers))
+	}
+	if fields.extension != "" {
+		if len(params.Extensions) > 0 {
+			must = append(must, anyTerm(fields.extension, params.Extensions))
+		}
+		if len(params.ExcludedExtensions) > 0 {
+			mustNot = append(mustNot, anyTerm(fields.extension, params.ExcludedExtensions))
+		}
+	}
+
+	if params.OnDate != "" {
+		must = append(must, millisRange(params.GetOnDateMillis()))
+		return must, mustNot
+	}
+
+	if params.AfterDate != "" || params.BeforeDate != "" {
+		var from, to *float64
+		if params.AfterDate != "" {
+			from = model.NewPointer(float64(params.GetAfterDateMillis()))
+		}
+		if params.BeforeDate != "" {
+			to = model.NewPointer(float64(params.GetBeforeDateMillis()))
+		}
+		must = append(must, createAtRange(from, to))
+	}
+	if params.ExcludedDate != "" {
+		mustNot = append(mustNot, millisRange(params.GetExcludedDateMillis()))
+	}
+	if params.ExcludedAfterDate != "" {
+		mustNot = append(mustNot, createAtRange(model.NewPointer(float64(params.GetExcludedAfterDateMillis())), nil))
+	}
+	if params.ExcludedBeforeDate != "" {
+		mustNot = append(mustNot, createAtRange(nil, model.NewPointer(float64(params.GetExcludedBeforeDateMillis()))))
+	}
+
+	return must, mustNot
+}
+
+// newSearchRequest returns the request for one page of a post or file search in the given
+// channels, newest first, and the clauses of its terms.
+func (e *EmbeddedEngine) newSearchRequest(channels model.ChannelList, searchParams []*model.SearchParams, fields searchFields, page, perPage int) (*bleve.SearchRequest, []clause) {
+	channelIDs := make([]string, len(channels))
+	for i, channel := range channels {
+		channelIDs[i] = channel.Id
+	}
+	must, mustNot := filterQueries(searchParams[0], fields)
+	must = append(must, anyTerm(fields.channel, channelIDs))
+
+	var clauses []clause
+	var matches []query.Query
+	for _, params := range searchParams {
+		for _, c := range e.parseClauses(params.Terms, params.IsHashtag) {
+			clauses = append(clauses, c)
+			matches = append(matches, c.query(fields.text, fields.hashtag))
+		}
+		for _, c := range e.parseClauses(params.ExcludedTerms, params.IsHashtag) {
+			mustNot = append(mustNot, c.query(fields.text, fields.hashtag))
+		}
+	}
+	if len(matches) > 0 {
+		if searchParams[0].OrTerms {
+			must = append(must, bleve.NewDisjunctionQuery(matches...))
+		} else {
+			must = append(must, bleve.NewConjunctionQuery(matches...))
+		}
+	}
+
+	req := bleve.NewSearchRequestOptions(allOf(must, mustNot), perPage, page*perPage, false)
+	req.SortBy([]string{"-create_at", "_id"})
+	if len(fields.stored) > 0 {
+		req.Fields = fields.stored
+		req.IncludeLocations = true
+	}
+	return req, clauses
+}
+
+// validPage reports whether a page can hold results.
+func validPage(channels model.ChannelList, searchParams []*model.SearchParams, page, perPage int) bool {
+	return len(channels) > 0 && len(searchParams) > 0 && page >= 0 && perPage > 0
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/platform/services/searchengine"
+)
+
+func foldAll(analyzerName string, values []string) []string {
+	for i, s := range values {
+		values[i] = fold(analyzerName, s)
+	}
+	return values
+}
+
+func userDocFromUser(user *model.User, teamsIDs, channelsIDs []string, analyzerName string) *userDoc {
+	suggestions := searchengine.GetSuggestionInputsSplitByMultiple(user.Username, []string{".", "-", "_"})
+	if user.Nickname != "" {
+		suggestions = append(suggestions, searchengine.GetSuggestionInputsSplitBy(user.Nickname, " ")...)
+	}
+	var fullNameSuggestions []string
+	if fullName := strings.TrimSpace(user.FirstName + " " + user.LastName); fullName != "" {
+		fullNameSuggestions = searchengine.GetSuggestionInputsSplitBy(fullName, " ")
+	}
+
+	return &userDoc{
+		ID:                  user.Id,
+		Username:            user.Username,
+		Deleted:             user.DeleteAt > 0,
+		Roles:               user.GetRoles(),
+		TeamsIDs:            teamsIDs,
+		ChannelsIDs:         channelsIDs,
+		Suggestions:         foldAll(analyzerName, suggestions),
+		FullNameSuggestions: foldAll(analyzerName, fullNameSuggestions),
+	}
+}
+
+func (e *EmbeddedEngine) IndexUser(rctx request.CTX, user *model.User, teamsIds, channelsIds []string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.IndexUser")
+	}
+
+	if err := e.indexes[IndexBaseUsers].Index(user.Id, userDocFromUser(user, teamsIds, channelsIds, e.analyzer)); err != nil {
+		return indexError("EmbeddedEngine.IndexUser", err)
+	}
+	return nil
+}
+
+// IndexUsersBatch indexes users read by the indexing job.
+func (e *EmbeddedEngine) IndexUsersBatch(users []*model.UserForIndexing) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.IndexUsersBatch")
+	}
+
+	index := e.indexes[IndexBaseUsers]
+	batch := index.NewBatch()
+	for _, u := range users {
+		user := &model.User{
+			Id:        u.Id,
+			Username:  u.Username,
+			Nickname:  u.Nickname,
+			FirstName: u.FirstName,
+			LastName:  u.LastName,
+			Roles:     u.Roles,
+			DeleteAt:  u.DeleteAt,
+		}
+		if err := batch.Index(u.Id, userDocFromUser(user, u.TeamsIds, u.ChannelsIds, e.analyzer)); err != nil {
+			return indexError("EmbeddedEngine.IndexUsersBatch", err)
+		}
+	}
+	if err := index.Batch(batch); err != nil {
+		return indexError("EmbeddedEngine.IndexUsersBatch", err)
+	}
+	return nil
+}
+
+// searchUsers returns up to options.Limit users matching must and not mustNot whose names
+// start with term, ordered by username.
+func (e *EmbeddedEngine) searchUsers(term string, options *model.UserSearchOptions, must, mustNot []query.Query) ([]string, error) {
+	if !options.AllowInactive {
+		mustNot = append(mustNot, boolFieldQuery("deleted", true))
+	}
+	if options.Role != "" {
+		must = append(must, termQuery("roles", options.Role))
+	}
+	if term != "" {
+		prefix := fold(e.analyzer, term)
+		names := prefixQuery("suggestions", prefix)
+		if options.AllowFullNames {
+			names = bleve.NewDisjunctionQuery(names, prefixQuery("full_name_suggestions", prefix))
+		}
+		must = append(must, names)
+	}
+
+	index := e.indexes[IndexBaseUsers]
+	size := options.Limit
+	if size <= 0 {
+		count, err := index.DocCount()
+		if err != nil {
+			return nil, err
+		}
+		size = int(count)
+	}
+
+	req := bleve.NewSearchRequestOptions(allOf(must, mustNot), size, 0, false)
+	req.SortBy([]string{"username", "_id"})
+	return searchIDs(index, req)
+}
+
+func (e *EmbeddedEngine) SearchUsersInChannel(teamId, channelId string, restrictedToChannels []string, term string, options *model.UserSearchOptions) ([]string, []string, *model.AppError) {
+	if restrictedToChannels != nil && len(restrictedToChannels) == 0 {
+		return []string{}, []string{}, nil
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return nil, nil, notStartedError("EmbeddedEngine.SearchUsersInChannel")
+	}
+
+	inChannel, err := e.searchUsers(term, options, []query.Query{termQuery("channels_ids", channelId)}, nil)
+	if err != nil {
+		return nil, nil, searchError("EmbeddedEngine.SearchUsersInChannel", err)
+	}
+
+	must := []query.Query{termQuery("teams_ids", teamId)}
+	if len(restrictedToChannels) > 0 {
+		must = append(must, anyTerm("channels_ids", restrictedToChannels))
+	}
+	notInChannel, err := e.searchUsers(term, options, must, []query.Query{termQuery("channels_ids", channelId)})
+	if err != nil {
+		return nil, nil, searchError("EmbeddedEngine.SearchUsersInChannel", err)
+	}
+
+	return inChannel, notInChannel, nil
+}
+
+func (e *EmbeddedEngine) SearchUsersInTeam(teamId string, restrictedToChannels []string, term string, options *model.UserSearchOptions) ([]string, *model.AppError) {
+	if restrictedToChannels != nil && len(restrictedToChannels) == 0 {
+		return []string{}, nil
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return nil, notStartedError("EmbeddedEngine.SearchUsersInTeam")
+	}
+
+	must := []query.Query{termQuery("teams_ids", teamId)}
+	if restrictedToChannels != nil {
+		must = []query.Query{anyTerm("channels_ids", restrictedToChannels)}
+	}
+	userIDs, err := e.searchUsers(term, options, must, nil)
+	if err != nil {
+		return nil, searchError("EmbeddedEngine.SearchUsersInTeam", err)
+	}
+	return userIDs, nil
+}
+
+func (e *EmbeddedEngine) DeleteUser(user *model.User) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if atomic.LoadInt32(&e.ready) == 0 {
+		return notStartedError("EmbeddedEngine.DeleteUser")
+	}
+
+	if err := e.indexes[IndexBaseUsers].Delete(user.Id); err != nil {
+		return deleteError("EmbeddedEngine.DeleteUser", err)
+	}
+	return nil
+}
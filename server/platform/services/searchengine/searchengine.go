@@ -17,9 +17,14 @@ func (seb *Broker) RegisterElasticsearchEngine(es SearchEngineInterface) {
 	seb.ElasticsearchEngine = es
 }
 
+func (seb *Broker) RegisterEmbeddedEngine(ee SearchEngineInterface) {
+	seb.EmbeddedEngine = ee
+}
+
 type Broker struct {
 	cfg                 *model.Config
 	ElasticsearchEngine SearchEngineInterface
+	EmbeddedEngine      SearchEngineInterface
 }
 
 func (seb *Broker) UpdateConfig(cfg *model.Config) *model.AppError {
@@ -27,6 +32,9 @@ func (seb *Broker) UpdateConfig(cfg *model.Config) *model.AppError {
 	if seb.ElasticsearchEngine != nil {
 		seb.ElasticsearchEngine.UpdateConfig(cfg)
 	}
+	if seb.EmbeddedEngine != nil {
+		seb.EmbeddedEngine.UpdateConfig(cfg)
+	}
 
 	return nil
 }
@@ -36,6 +44,9 @@ func (seb *Broker) GetActiveEngines() []SearchEngineInterface {
 	if seb.ElasticsearchEngine != nil && seb.ElasticsearchEngine.IsActive() {
 		engines = append(engines, seb.ElasticsearchEngine)
 	}
+	if seb.EmbeddedEngine != nil && seb.EmbeddedEngine.IsActive() {
+		engines = append(engines, seb.EmbeddedEngine)
+	}
 	return engines
 }
 
@@ -26,7 +26,17 @@ func TestActiveEngine(t *testing.T) {
 	b.ElasticsearchEngine = esMock
 	assert.Equal(t, "elasticsearch", b.ActiveEngine())
 
+	embeddedMock := &mocks.SearchEngineInterface{}
+	embeddedMock.On("IsActive").Return(true)
+	embeddedMock.On("GetName").Return("embedded")
+
+	b.EmbeddedEngine = embeddedMock
+	assert.Equal(t, "elasticsearch", b.ActiveEngine())
+
 	b.ElasticsearchEngine = nil
+	assert.Equal(t, "embedded", b.ActiveEngine())
+
+	b.EmbeddedEngine = nil
 	*b.cfg.SqlSettings.DisableDatabaseSearch = true
 
 	assert.Equal(t, "none", b.ActiveEngine())
@@ -0,0 +1,673 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package filestore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// Encrypted files start with a header holding a random data key wrapped by a master key,
+// followed by the contents split in chunks of encryptedChunkSize bytes. Each chunk is sealed
+// with AES-GCM under the data key and stored as nonce|ciphertext|tag, so that any offset can be
+// read without decrypting what comes before it. The index of a chunk and whether it is the last
+// one are authenticated with it, which detects reordered and truncated files.
+const (
+	encryptedMagic         = "MMFSENC1"
+	encryptedKeyIdSize     = 8
+	encryptedDataKeySize   = 32
+	encryptedNonceSize     = 12
+	encryptedTagSize       = 16
+	encryptedHeaderSize    = len(encryptedMagic) + encryptedKeyIdSize + encryptedNonceSize + encryptedDataKeySize + encryptedTagSize
+	encryptedChunkSize     = 64 * 1024
+	encryptedChunkOverhead = encryptedNonceSize + encryptedTagSize
+
+	// EncryptedTempFileSuffix is the suffix of the files written while a file is appended to or
+	// re-encrypted, before they replace it.
+	EncryptedTempFileSuffix = ".encrypting"
+)
+
+type encryptionKey struct {
+	id   [encryptedKeyIdSize]byte
+	aead cipher.AEAD
+}
+
+func newEncryptionKey(key []byte) (*encryptionKey, error) {
+	if len(key) != 32 {
+		return nil, errors.Errorf("encryption keys must be 32 bytes long, got %d", len(key))
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	k := &encryptionKey{aead: aead}
+	sum := sha256.Sum256(key)
+	copy(k.id[:], sum[:])
+	return k, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create the block cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create the AES-GCM cipher")
+	}
+	return aead, nil
+}
+
+// EncryptedFileBackend encrypts the files of another backend at rest. Files written before
+// encryption was enabled are read as they are until they are re-encrypted with Rekey.
+//
+// New files are encrypted with the current master key. Previous master keys are only used to
+// read the files that have not been re-encrypted yet.
+type EncryptedFileBackend struct {
+	backend FileBackend
+	current *encryptionKey
+	keys    map[[encryptedKeyIdSize]byte]*encryptionKey
+}
+
+// NewEncryptedFileBackend wraps backend so that the files it stores are encrypted with
+// currentKey. previousKeys are the master keys used before the last rotation.
+func NewEncryptedFileBackend(backend FileBackend, currentKey []byte, previousKeys ...[]byte) (*EncryptedFileBackend, error) {
+	current, err := newEncryptionKey(currentKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid current encryption key")
+	}
+
+	b := &EncryptedFileBackend{
+		backend: backend,
+		current: current,
+		keys:    map[[encryptedKeyIdSize]byte]*encryptionKey{current.id: current},
+	}
+	for i, previousKey := range previousKeys {
+		key, err := newEncryptionKey(previousKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid previous encryption key %d", i+1)
+		}
+		b.keys[key.id] = key
+	}
+	return b, nil
+}
+
+// encryptionKeysFromSettings returns the current and previous master keys. A key file holds one
+// base64 key per line, the current one first, and takes precedence over EncryptionKey.
+func encryptionKeysFromSettings(settings FileBackendSettings) ([]byte, [][]byte, error) {
+	var encoded []string
+	if settings.EncryptionKeyFile != "" {
+		f, err := os.Open(settings.EncryptionKeyFile)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to open the encryption key file")
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				encoded = append(encoded, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, nil, errors.Wrap(err, "unable to read the encryption key file")
+		}
+	} else if settings.EncryptionKey != "" {
+		encoded = append(encoded, settings.EncryptionKey)
+	}
+	encoded = append(encoded, settings.EncryptionPreviousKeys...)
+
+	if len(encoded) == 0 {
+		return nil, nil, errors.New("no encryption key configured")
+	}
+
+	keys := make([][]byte, 0, len(encoded))
+	for _, e := range encoded {
+		key, err := base64.StdEncoding.DecodeString(e)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to decode the encryption key")
+		}
+		keys = append(keys, key)
+	}
+	return keys[0], keys[1:], nil
+}
+
+// Unwrap returns the backend the files are stored in.
+func (b *EncryptedFileBackend) Unwrap() FileBackend {
+	return b.backend
+}
+
+func (b *EncryptedFileBackend) DriverName() string {
+	return b.backend.DriverName()
+}
+
+func (b *EncryptedFileBackend) TestConnection() error {
+	return b.backend.TestConnection()
+}
+
+// Reader returns the decrypted contents of path. Seeking only decrypts the chunk read next.
+func (b *EncryptedFileBackend) Reader(path string) (ReadCloseSeeker, error) {
+	src, err := b.backend.Reader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := b.openReader(src)
+	if err != nil {
+		src.Close()
+		return nil, errors.Wrapf(err, "unable to decrypt file %s", path)
+	}
+	return r, nil
+}
+
+// openReader returns src itself if it is not encrypted.
+func (b *EncryptedFileBackend) openReader(src ReadCloseSeeker) (ReadCloseSeeker, error) {
+	header, encrypted, err := readEncryptionHeader(src)
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return src, nil
+	}
+
+	aead, _, err := b.dataKey(header)
+	if err != nil {
+		return nil, err
+	}
+	encryptedSize, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	size, err := plaintextSize(encryptedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptingReader{
+		src:           src,
+		aead:          aead,
+		size:          size,
+		encryptedSize: encryptedSize,
+		current:       -1,
+	}, nil
+}
+
+func (b *EncryptedFileBackend) ReadFile(path string) ([]byte, error) {
+	r, err := b.Reader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read file %s", path)
+	}
+	return data, nil
+}
+
+func (b *EncryptedFileBackend) FileExists(path string) (bool, error) {
+	return b.backend.FileExists(path)
+}
+
+// FileSize returns the size of the decrypted contents of path.
+func (b *EncryptedFileBackend) FileSize(path string) (int64, error) {
+	r, err := b.Reader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to get file size for %s", path)
+	}
+	return size, nil
+}
+
+func (b *EncryptedFileBackend) CopyFile(oldPath, newPath string) error {
+	return b.backend.CopyFile(oldPath, newPath)
+}
+
+func (b *EncryptedFileBackend) MoveFile(oldPath, newPath string) error {
+	return b.backend.MoveFile(oldPath, newPath)
+}
+
+func (b *EncryptedFileBackend) WriteFile(fr io.Reader, path string) (int64, error) {
+	return b.WriteFileContext(context.Background(), fr, path)
+}
+
+// WriteFileContext passes ctx to the wrapped backend if it supports contexts, and stops reading
+// fr once ctx is done.
+func (b *EncryptedFileBackend) WriteFileContext(ctx context.Context, fr io.Reader, path string) (int64, error) {
+	header, aead, err := b.newHeader()
+	if err != nil {
+		return 0, err
+	}
+
+	enc := newEncryptingReader(aead, &contextReader{ctx: ctx, r: fr}, 0)
+	if _, err := TryWriteFileContext(ctx, b.backend, io.MultiReader(bytes.NewReader(header), enc), path); err != nil {
+		return enc.written, err
+	}
+	return enc.written, nil
+}
+
+// AppendFile adds the contents of fr to the end of path. As the last chunk of an encrypted file
+// changes, the file is rewritten, but only that chunk is decrypted again. Files that are not
+// encrypted stay so.
+func (b *EncryptedFileBackend) AppendFile(fr io.Reader, path string) (int64, error) {
+	src, err := b.backend.Reader(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to find the file %s to append the data", path)
+	}
+	defer src.Close()
+
+	header, encrypted, err := readEncryptionHeader(src)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to read the file %s to append the data", path)
+	}
+	if !encrypted {
+		return b.backend.AppendFile(fr, path)
+	}
+
+	aead, _, err := b.dataKey(header)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to decrypt file %s", path)
+	}
+	encryptedSize, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to read the file %s to append the data", path)
+	}
+	if _, err = plaintextSize(encryptedSize); err != nil {
+		return 0, errors.Wrapf(err, "unable to decrypt file %s", path)
+	}
+
+	last := chunkCount(encryptedSize) - 1
+	lastChunk, err := readChunk(src, aead, last, encryptedSize)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to decrypt file %s", path)
+	}
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return 0, errors.Wrapf(err, "unable to read the file %s to append the data", path)
+	}
+
+	// The header and the chunks before the last one are copied as they are.
+	enc := newEncryptingReader(aead, io.MultiReader(bytes.NewReader(lastChunk), fr), last)
+	if err := b.replaceFile(io.MultiReader(io.LimitReader(src, chunkOffset(last)), enc), path); err != nil {
+		return max(enc.written-int64(len(lastChunk)), 0), errors.Wrapf(err, "unable append the data in the file %s", path)
+	}
+	return enc.written - int64(len(lastChunk)), nil
+}
+
+func (b *EncryptedFileBackend) RemoveFile(path string) error {
+	return b.backend.RemoveFile(path)
+}
+
+func (b *EncryptedFileBackend) FileModTime(path string) (time.Time, error) {
+	return b.backend.FileModTime(path)
+}
+
+func (b *EncryptedFileBackend) ListDirectory(path string) ([]string, error) {
+	return b.backend.ListDirectory(path)
+}
+
+func (b *EncryptedFileBackend) ListDirectoryRecursively(path string) ([]string, error) {
+	return b.backend.ListDirectoryRecursively(path)
+}
+
+func (b *EncryptedFileBackend) RemoveDirectory(path string) error {
+	return b.backend.RemoveDirectory(path)
+}
+
+// ZipReader will create a zip of path with the decrypted contents of its files. If path is a
+// single file, it will zip the single file. If deflate is true, the contents will be compressed.
+func (b *EncryptedFileBackend) ZipReader(path string, deflate bool) (io.ReadCloser, error) {
+	return ZipReader(b, path, deflate)
+}
+
+// Rekey encrypts path with the current master key, either by wrapping its data key again or, if
+// the file is not encrypted yet, by encrypting it. It returns whether the file was rewritten.
+func (b *EncryptedFileBackend) Rekey(path string) (bool, error) {
+	src, err := b.backend.Reader(path)
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+
+	header, encrypted, err := readEncryptionHeader(src)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to read file %s", path)
+	}
+
+	var contents io.Reader
+	if encrypted {
+		_, dataKey, err := b.dataKey(header)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to decrypt file %s", path)
+		}
+		if bytes.Equal(header[len(encryptedMagic):len(encryptedMagic)+encryptedKeyIdSize], b.current.id[:]) {
+			return false, nil
+		}
+
+		newHeader, err := b.wrapDataKey(dataKey)
+		if err != nil {
+			return false, err
+		}
+		// The reader is right after the header, only the data key changes.
+		contents = io.MultiReader(bytes.NewReader(newHeader), src)
+	} else {
+		if _, err = src.Seek(0, io.SeekStart); err != nil {
+			return false, errors.Wrapf(err, "unable to read file %s", path)
+		}
+		newHeader, aead, err := b.newHeader()
+		if err != nil {
+			return false, err
+		}
+		contents = io.MultiReader(bytes.NewReader(newHeader), newEncryptingReader(aead, src, 0))
+	}
+
+	if err := b.replaceFile(contents, path); err != nil {
+		return false, errors.Wrapf(err, "unable to re-encrypt file %s", path)
+	}
+	return true, nil
+}
+
+// replaceFile writes contents next to path and then moves it over path, so that path is never
+// left half written.
+func (b *EncryptedFileBackend) replaceFile(contents io.Reader, path string) error {
+	tmpPath := fmt.Sprintf("%s.%s%s", path, model.NewId(), EncryptedTempFileSuffix)
+	if _, err := b.backend.WriteFile(contents, tmpPath); err != nil {
+		_ = b.backend.RemoveFile(tmpPath)
+		return err
+	}
+	if err := b.backend.MoveFile(tmpPath, path); err != nil {
+		_ = b.backend.RemoveFile(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// newHeader generates a data key for a new file and returns the file header with the key.
+func (b *EncryptedFileBackend) newHeader() ([]byte, cipher.AEAD, error) {
+	dataKey := make([]byte, encryptedDataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, errors.Wrap(err, "unable to generate a data key")
+	}
+	header, err := b.wrapDataKey(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, aead, nil
+}
+
+// wrapDataKey returns a file header with dataKey encrypted by the current master key.
+func (b *EncryptedFileBackend) wrapDataKey(dataKey []byte) ([]byte, error) {
+	header := make([]byte, 0, encryptedHeaderSize)
+	header = append(header, encryptedMagic...)
+	header = append(header, b.current.id[:]...)
+
+	nonce := make([]byte, encryptedNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "unable to generate a nonce")
+	}
+	aad := slices.Clone(header[:len(encryptedMagic)+encryptedKeyIdSize])
+	header = append(header, nonce...)
+	return b.current.aead.Seal(header, nonce, dataKey, aad), nil
+}
+
+// dataKey decrypts the data key of a file header with the master key it was wrapped with.
+func (b *EncryptedFileBackend) dataKey(header []byte) (cipher.AEAD, []byte, error) {
+	var id [encryptedKeyIdSize]byte
+	copy(id[:], header[len(encryptedMagic):])
+	key, ok := b.keys[id]
+	if !ok {
+		return nil, nil, errors.Errorf("the file is encrypted with an unknown key %x", id)
+	}
+
+	offset := len(encryptedMagic) + encryptedKeyIdSize
+	nonce := header[offset : offset+encryptedNonceSize]
+	dataKey, err := key.aead.Open(nil, nonce, header[offset+encryptedNonceSize:], header[:offset])
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to decrypt the data key")
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, dataKey, nil
+}
+
+// readEncryptionHeader reads the header of an encrypted file from the start of r. It reports
+// whether the file is encrypted at all.
+func readEncryptionHeader(r io.Reader) ([]byte, bool, error) {
+	header := make([]byte, encryptedHeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return header[:n], bytes.Equal(header[:len(encryptedMagic)], []byte(encryptedMagic)), nil
+}
+
+func chunkAAD(index int64, last bool) []byte {
+	aad := binary.BigEndian.AppendUint64(nil, uint64(index))
+	if last {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+// chunkOffset returns where chunk index starts in an encrypted file.
+func chunkOffset(index int64) int64 {
+	return int64(encryptedHeaderSize) + index*(encryptedChunkSize+encryptedChunkOverhead)
+}
+
+// chunkCount returns the number of chunks of an encrypted file. Every file has at least one
+// chunk, which may be empty.
+func chunkCount(encryptedSize int64) int64 {
+	body := encryptedSize - int64(encryptedHeaderSize)
+	return (body + encryptedChunkSize + encryptedChunkOverhead - 1) / (encryptedChunkSize + encryptedChunkOverhead)
+}
+
+// plaintextSize returns the size of the decrypted contents of an encrypted file.
+func plaintextSize(encryptedSize int64) (int64, error) {
+	body := encryptedSize - int64(encryptedHeaderSize)
+	if body < encryptedChunkOverhead {
+		return 0, errors.New("the encrypted file is truncated")
+	}
+	chunks := chunkCount(encryptedSize)
+	lastSize := body - (chunks-1)*(encryptedChunkSize+encryptedChunkOverhead)
+	if lastSize < encryptedChunkOverhead {
+		return 0, errors.New("the encrypted file is truncated")
+	}
+	return body - chunks*encryptedChunkOverhead, nil
+}
+
+// readChunk decrypts chunk index of an encrypted file of encryptedSize bytes.
+func readChunk(src io.ReadSeeker, aead cipher.AEAD, index, encryptedSize int64) ([]byte, error) {
+	offset := chunkOffset(index)
+	size := min(encryptedSize-offset, encryptedChunkSize+encryptedChunkOverhead)
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	raw := make([]byte, size)
+	if _, err := io.ReadFull(src, raw); err != nil {
+		return nil, err
+	}
+
+	last := index == chunkCount(encryptedSize)-1
+	chunk, err := aead.Open(raw[encryptedNonceSize:encryptedNonceSize], raw[:encryptedNonceSize], raw[encryptedNonceSize:], chunkAAD(index, last))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decrypt chunk %d", index)
+	}
+	return chunk, nil
+}
+
+// encryptingReader reads the chunks encrypting the contents of src, starting at chunk index.
+type encryptingReader struct {
+	src   io.Reader
+	aead  cipher.AEAD
+	index int64
+
+	buf     []byte
+	next    []byte
+	out     []byte
+	done    bool
+	written int64
+}
+
+func newEncryptingReader(aead cipher.AEAD, src io.Reader, index int64) *encryptingReader {
+	return &encryptingReader{
+		src:   src,
+		aead:  aead,
+		index: index,
+		buf:   make([]byte, encryptedChunkSize),
+		next:  make([]byte, 0, 1),
+	}
+}
+
+func (r *encryptingReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.sealNextChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *encryptingReader) sealNextChunk() error {
+	n := copy(r.buf, r.next)
+	r.next = r.next[:0]
+
+	read, err := io.ReadFull(r.src, r.buf[n:])
+	n += read
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		// The chunk is full, read one more byte to know if it is the last one.
+		read, err = io.ReadFull(r.src, r.next[:1])
+		if err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+		r.next = r.next[:read]
+	}
+
+	nonce := make([]byte, encryptedNonceSize, encryptedChunkOverhead+n)
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "unable to generate a nonce")
+	}
+	r.out = r.aead.Seal(nonce, nonce, r.buf[:n], chunkAAD(r.index, last))
+	r.index++
+	r.written += int64(n)
+	r.done = last
+	return nil
+}
+
+// contextReader fails reads once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(p)
+	if ctxErr := r.ctx.Err(); ctxErr != nil {
+		return 0, ctxErr
+	}
+	return n, err
+}
+
+// decryptingReader reads the decrypted contents of an encrypted file.
+type decryptingReader struct {
+	src           ReadCloseSeeker
+	aead          cipher.AEAD
+	size          int64
+	encryptedSize int64
+	offset        int64
+
+	current int64
+	chunk   []byte
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	index := r.offset / encryptedChunkSize
+	if index != r.current {
+		chunk, err := readChunk(r.src, r.aead, index, r.encryptedSize)
+		if err != nil {
+			return 0, err
+		}
+		r.chunk = chunk
+		r.current = index
+	}
+
+	n := copy(p, r.chunk[r.offset-index*encryptedChunkSize:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *decryptingReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *decryptingReader) Close() error {
+	return r.src.Close()
+}
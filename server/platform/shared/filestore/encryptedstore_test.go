@@ -0,0 +1,368 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package filestore
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func newTestEncryptionKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	data := make([]byte, n)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	return data
+}
+
+func setupEncryptedBackend(t *testing.T) (*EncryptedFileBackend, *LocalFileBackend, []byte) {
+	t.Helper()
+	local := &LocalFileBackend{directory: t.TempDir()}
+	key := newTestEncryptionKey(t)
+	backend, err := NewEncryptedFileBackend(local, key)
+	require.NoError(t, err)
+	return backend, local, key
+}
+
+func TestEncryptedLocalFileBackendTestSuite(t *testing.T) {
+	suite.Run(t, &FileBackendTestSuite{
+		settings: FileBackendSettings{
+			DriverName:       driverLocal,
+			Directory:        t.TempDir(),
+			EnableEncryption: true,
+			EncryptionKey:    base64.StdEncoding.EncodeToString(newTestEncryptionKey(t)),
+		},
+	})
+}
+
+func TestEncryptedFileBackend(t *testing.T) {
+	sizes := map[string]int{
+		"empty":            0,
+		"small":            10,
+		"one chunk":        encryptedChunkSize,
+		"several chunks":   3*encryptedChunkSize + 17,
+		"exact multiple":   2 * encryptedChunkSize,
+		"one byte more":    encryptedChunkSize + 1,
+		"one byte missing": encryptedChunkSize - 1,
+	}
+
+	for name, size := range sizes {
+		t.Run(name, func(t *testing.T) {
+			backend, local, _ := setupEncryptedBackend(t)
+			data := randomBytes(t, size)
+
+			written, err := backend.WriteFile(bytes.NewReader(data), "file")
+			require.NoError(t, err)
+			assert.EqualValues(t, size, written)
+
+			raw, err := local.ReadFile("file")
+			require.NoError(t, err)
+			if size > 0 {
+				assert.False(t, bytes.Contains(raw, data), "the contents should not be stored in clear")
+			}
+
+			read, err := backend.ReadFile("file")
+			require.NoError(t, err)
+			assert.Equal(t, data, read)
+
+			fileSize, err := backend.FileSize("file")
+			require.NoError(t, err)
+			assert.EqualValues(t, size, fileSize)
+		})
+	}
+}
+
+func TestEncryptedFileBackendSeek(t *testing.T) {
+	backend, _, _ := setupEncryptedBackend(t)
+	data := randomBytes(t, 3*encryptedChunkSize+100)
+	_, err := backend.WriteFile(bytes.NewReader(data), "file")
+	require.NoError(t, err)
+
+	r, err := backend.Reader("file")
+	require.NoError(t, err)
+	defer r.Close()
+
+	for _, offset := range []int64{encryptedChunkSize + 10, 5, 3*encryptedChunkSize + 50, encryptedChunkSize - 3} {
+		pos, err := r.Seek(offset, io.SeekStart)
+		require.NoError(t, err)
+		require.Equal(t, offset, pos)
+
+		// Reads crossing a chunk boundary continue in the next chunk.
+		buf := make([]byte, 20)
+		n, err := io.ReadFull(r, buf)
+		require.NoError(t, err)
+		assert.Equal(t, data[offset:offset+int64(n)], buf[:n])
+	}
+
+	pos, err := r.Seek(-10, io.SeekEnd)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(data)-10, pos)
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, data[len(data)-10:], rest)
+
+	_, err = r.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+}
+
+func TestEncryptedFileBackendAppendFile(t *testing.T) {
+	backend, local, _ := setupEncryptedBackend(t)
+
+	first := randomBytes(t, encryptedChunkSize-5)
+	second := randomBytes(t, encryptedChunkSize+20)
+	third := randomBytes(t, 3)
+
+	_, err := backend.WriteFile(bytes.NewReader(first), "file")
+	require.NoError(t, err)
+	for _, part := range [][]byte{second, third} {
+		written, err := backend.AppendFile(bytes.NewReader(part), "file")
+		require.NoError(t, err)
+		assert.EqualValues(t, len(part), written)
+	}
+
+	read, err := backend.ReadFile("file")
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Join([][]byte{first, second, third}, nil), read)
+
+	size, err := backend.FileSize("file")
+	require.NoError(t, err)
+	assert.EqualValues(t, len(first)+len(second)+len(third), size)
+
+	files, err := local.ListDirectory("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file"}, files, "no temporary file should be left behind")
+
+	_, err = backend.AppendFile(bytes.NewReader(third), "missing")
+	assert.Error(t, err)
+}
+
+func TestEncryptedFileBackendUnencryptedFiles(t *testing.T) {
+	backend, local, _ := setupEncryptedBackend(t)
+
+	_, err := local.WriteFile(strings.NewReader("written before encryption"), "legacy")
+	require.NoError(t, err)
+
+	read, err := backend.ReadFile("legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "written before encryption", string(read))
+
+	_, err = backend.AppendFile(strings.NewReader(" was enabled"), "legacy")
+	require.NoError(t, err)
+	raw, err := local.ReadFile("legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "written before encryption was enabled", string(raw), "unencrypted files stay unencrypted until rekeyed")
+
+	changed, err := backend.Rekey("legacy")
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	raw, err = local.ReadFile("legacy")
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(raw, []byte(encryptedMagic)))
+	read, err = backend.ReadFile("legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "written before encryption was enabled", string(read))
+}
+
+func TestEncryptedFileBackendRekey(t *testing.T) {
+	local := &LocalFileBackend{directory: t.TempDir()}
+	oldKey := newTestEncryptionKey(t)
+	newKey := newTestEncryptionKey(t)
+
+	oldBackend, err := NewEncryptedFileBackend(local, oldKey)
+	require.NoError(t, err)
+	data := randomBytes(t, encryptedChunkSize+10)
+	_, err = oldBackend.WriteFile(bytes.NewReader(data), "file")
+	require.NoError(t, err)
+
+	t.Run("without the previous key", func(t *testing.T) {
+		backend, err := NewEncryptedFileBackend(local, newKey)
+		require.NoError(t, err)
+		_, err = backend.ReadFile("file")
+		assert.ErrorContains(t, err, "unknown key")
+	})
+
+	backend, err := NewEncryptedFileBackend(local, newKey, oldKey)
+	require.NoError(t, err)
+
+	read, err := backend.ReadFile("file")
+	require.NoError(t, err)
+	assert.Equal(t, data, read, "files encrypted with a previous key stay readable")
+
+	changed, err := backend.Rekey("file")
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	changed, err = backend.Rekey("file")
+	require.NoError(t, err)
+	assert.False(t, changed, "the file already uses the current key")
+
+	rotated, err := NewEncryptedFileBackend(local, newKey)
+	require.NoError(t, err)
+	read, err = rotated.ReadFile("file")
+	require.NoError(t, err)
+	assert.Equal(t, data, read, "the previous key is not needed after rekeying")
+}
+
+func TestEncryptedFileBackendTampering(t *testing.T) {
+	backend, local, _ := setupEncryptedBackend(t)
+	data := randomBytes(t, 2*encryptedChunkSize+10)
+	_, err := backend.WriteFile(bytes.NewReader(data), "file")
+	require.NoError(t, err)
+	raw, err := local.ReadFile("file")
+	require.NoError(t, err)
+
+	t.Run("modified chunk", func(t *testing.T) {
+		modified := bytes.Clone(raw)
+		modified[chunkOffset(1)+encryptedNonceSize] ^= 1
+		_, err := local.WriteFile(bytes.NewReader(modified), "modified")
+		require.NoError(t, err)
+
+		_, err = backend.ReadFile("modified")
+		assert.Error(t, err)
+	})
+
+	t.Run("truncated at a chunk boundary", func(t *testing.T) {
+		_, err := local.WriteFile(bytes.NewReader(raw[:chunkOffset(2)]), "truncated")
+		require.NoError(t, err)
+
+		_, err = backend.ReadFile("truncated")
+		assert.Error(t, err)
+	})
+
+	t.Run("truncated header", func(t *testing.T) {
+		_, err := local.WriteFile(bytes.NewReader(raw[:encryptedHeaderSize+3]), "truncated_header")
+		require.NoError(t, err)
+
+		_, err = backend.Reader("truncated_header")
+		assert.Error(t, err)
+	})
+}
+
+func readZip(t *testing.T, r io.ReadCloser) map[string][]byte {
+	t.Helper()
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	contents := map[string][]byte{}
+	for _, f := range zipReader.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		contents[f.Name], err = io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+	}
+	return contents
+}
+
+func TestEncryptedFileBackendZipReader(t *testing.T) {
+	backend, _, _ := setupEncryptedBackend(t)
+	files := map[string][]byte{
+		"dir/a.txt":        []byte("a"),
+		"dir/nested/b.bin": randomBytes(t, encryptedChunkSize+1),
+	}
+	for path, data := range files {
+		_, err := backend.WriteFile(bytes.NewReader(data), path)
+		require.NoError(t, err)
+	}
+
+	for _, deflate := range []bool{false, true} {
+		r, err := backend.ZipReader("dir", deflate)
+		require.NoError(t, err)
+		contents := readZip(t, r)
+		assert.Equal(t, map[string][]byte{"a.txt": files["dir/a.txt"], "nested/b.bin": files["dir/nested/b.bin"]}, contents)
+	}
+
+	r, err := backend.ZipReader("dir/nested/b.bin", false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"b.bin": files["dir/nested/b.bin"]}, readZip(t, r))
+
+	_, err = backend.ZipReader("missing", false)
+	assert.Error(t, err)
+}
+
+func TestEncryptionKeysFromSettings(t *testing.T) {
+	current := newTestEncryptionKey(t)
+	previous := newTestEncryptionKey(t)
+	encode := base64.StdEncoding.EncodeToString
+
+	t.Run("key file", func(t *testing.T) {
+		keyFile := filepath.Join(t.TempDir(), "keys")
+		require.NoError(t, os.WriteFile(keyFile, []byte("# rotated on Monday\n"+encode(current)+"\n\n"+encode(previous)+"\n"), 0600))
+
+		key, previousKeys, err := encryptionKeysFromSettings(FileBackendSettings{
+			EncryptionKey:     encode(newTestEncryptionKey(t)),
+			EncryptionKeyFile: keyFile,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, current, key)
+		assert.Equal(t, [][]byte{previous}, previousKeys)
+	})
+
+	t.Run("config", func(t *testing.T) {
+		key, previousKeys, err := encryptionKeysFromSettings(FileBackendSettings{
+			EncryptionKey:          encode(current),
+			EncryptionPreviousKeys: []string{encode(previous)},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, current, key)
+		assert.Equal(t, [][]byte{previous}, previousKeys)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		_, err := NewFileBackend(FileBackendSettings{
+			DriverName:       driverLocal,
+			Directory:        t.TempDir(),
+			EnableEncryption: true,
+			EncryptionKey:    encode([]byte("too short")),
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		_, err := NewFileBackend(FileBackendSettings{
+			DriverName:       driverLocal,
+			Directory:        t.TempDir(),
+			EnableEncryption: true,
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestUnwrapFileBackend(t *testing.T) {
+	backend, local, _ := setupEncryptedBackend(t)
+
+	unwrapped, ok := UnwrapFileBackend[*LocalFileBackend](backend)
+	require.True(t, ok)
+	assert.Same(t, local, unwrapped)
+
+	encrypted, ok := UnwrapFileBackend[*EncryptedFileBackend](backend)
+	require.True(t, ok)
+	assert.Same(t, backend, encrypted)
+
+	_, ok = UnwrapFileBackend[*S3FileBackend](backend)
+	assert.False(t, ok)
+}
@@ -4,8 +4,11 @@
 package filestore
 
 import (
+	"archive/zip"
 	"context"
 	"io"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
@@ -69,13 +72,21 @@ type FileBackendSettings struct {
 	AmazonS3PresignExpiresSeconds      int64
 	AmazonS3UploadPartSizeBytes        int64
 	AmazonS3StorageClass               string
+	EnableEncryption                   bool
+	EncryptionKey                      string
+	EncryptionKeyFile                  string
+	EncryptionPreviousKeys             []string
 }
 
 func NewFileBackendSettingsFromConfig(fileSettings *model.FileSettings, enableComplianceFeature bool, skipVerify bool) FileBackendSettings {
 	if *fileSettings.DriverName == model.ImageDriverLocal {
 		return FileBackendSettings{
-			DriverName: *fileSettings.DriverName,
-			Directory:  *fileSettings.Directory,
+			DriverName:             *fileSettings.DriverName,
+			Directory:              *fileSettings.Directory,
+			EnableEncryption:       *fileSettings.EnableEncryption,
+			EncryptionKey:          *fileSettings.EncryptionKey,
+			EncryptionKeyFile:      *fileSettings.EncryptionKeyFile,
+			EncryptionPreviousKeys: fileSettings.EncryptionPreviousKeys,
 		}
 	}
 	return FileBackendSettings{
@@ -95,6 +106,10 @@ func NewFileBackendSettingsFromConfig(fileSettings *model.FileSettings, enableCo
 		SkipVerify:                         skipVerify,
 		AmazonS3UploadPartSizeBytes:        *fileSettings.AmazonS3UploadPartSizeBytes,
 		AmazonS3StorageClass:               *fileSettings.AmazonS3StorageClass,
+		EnableEncryption:                   *fileSettings.EnableEncryption,
+		EncryptionKey:                      *fileSettings.EncryptionKey,
+		EncryptionKeyFile:                  *fileSettings.EncryptionKeyFile,
+		EncryptionPreviousKeys:             fileSettings.EncryptionPreviousKeys,
 	}
 }
 
@@ -149,6 +164,19 @@ func NewExportFileBackend(settings FileBackendSettings) (FileBackend, error) {
 }
 
 func newFileBackend(settings FileBackendSettings, canBeCloud bool) (FileBackend, error) {
+	backend, err := newUnencryptedFileBackend(settings, canBeCloud)
+	if err != nil || !settings.EnableEncryption {
+		return backend, err
+	}
+
+	currentKey, previousKeys, err := encryptionKeysFromSettings(settings)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load the file encryption keys")
+	}
+	return NewEncryptedFileBackend(backend, currentKey, previousKeys...)
+}
+
+func newUnencryptedFileBackend(settings FileBackendSettings, canBeCloud bool) (FileBackend, error) {
 	switch settings.DriverName {
 	case driverS3:
 		newBackendFn := NewS3FileBackend
@@ -182,3 +210,112 @@ func TryWriteFileContext(ctx context.Context, fb FileBackend, fr io.Reader, path
 
 	return fb.WriteFile(fr, path)
 }
+
+// UnwrapFileBackend returns the first backend of type T among fb and the backends it wraps.
+func UnwrapFileBackend[T FileBackend](fb FileBackend) (T, bool) {
+	for {
+		if t, ok := fb.(T); ok {
+			return t, true
+		}
+		wrapper, ok := fb.(interface{ Unwrap() FileBackend })
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		fb = wrapper.Unwrap()
+	}
+}
+
+// ZipReader will create a zip of path with the contents read through fb, for backends that
+// cannot zip the files they store directly. If path is a single file, it will zip the single
+// file. If deflate is true, the contents will be compressed.
+func ZipReader(fb FileBackend, path string, deflate bool) (io.ReadCloser, error) {
+	deflateMethod := zip.Store
+	if deflate {
+		deflateMethod = zip.Deflate
+	}
+
+	baseDir := path
+	files := []string{path}
+	if isFile(fb, path) {
+		baseDir = filepath.Dir(path)
+	} else {
+		var err error
+		files, err = fb.ListDirectoryRecursively(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			exists, err := fb.FileExists(path)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				return nil, errors.Errorf("unable to stat path %s", path)
+			}
+		}
+	}
+
+	prefix := ""
+	if baseDir != "" && baseDir != "." {
+		prefix = strings.TrimSuffix(filepath.ToSlash(baseDir), "/") + "/"
+	}
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		zipWriter := zip.NewWriter(pw)
+		err := zipFiles(fb, zipWriter, files, prefix, deflateMethod)
+		if closeErr := zipWriter.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err) // CloseWithError(nil) is the same as Close
+	}()
+
+	return pr, nil
+}
+
+// isFile reports whether path is a file that can be read, rather than a directory.
+func isFile(fb FileBackend, path string) bool {
+	r, err := fb.Reader(path)
+	if err != nil {
+		return false
+	}
+	defer r.Close()
+
+	var buf [1]byte
+	_, err = r.Read(buf[:])
+	return err == nil || err == io.EOF
+}
+
+func zipFiles(fb FileBackend, zipWriter *zip.Writer, files []string, prefix string, deflateMethod uint16) error {
+	for _, file := range files {
+		modTime, err := fb.FileModTime(file)
+		if err != nil {
+			return err
+		}
+
+		header := &zip.FileHeader{
+			Name:     strings.TrimPrefix(filepath.ToSlash(file), prefix),
+			Method:   deflateMethod,
+			Modified: modTime,
+		}
+		header.SetMode(0644) // rw-r--r-- permissions
+
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return errors.Wrapf(err, "unable to create zip entry for %s", file)
+		}
+
+		r, err := fb.Reader(file)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(writer, r)
+		r.Close()
+		if err != nil {
+			return errors.Wrapf(err, "unable to copy content for %s", file)
+		}
+	}
+	return nil
+}
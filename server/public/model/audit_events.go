@@ -151,6 +151,7 @@ const (
 
 // Files
 const (
+	AuditEventDeleteFile                = "deleteFile"                // permanently delete a file not attached to a post
 	AuditEventGetFile                   = "getFile"                   // get or download file
 	AuditEventGetFileLink               = "getFileLink"               // generate link for file sharing
 	AuditEventUploadFileMultipart       = "uploadFileMultipart"       // upload file using multipart form data
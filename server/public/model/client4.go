@@ -3914,6 +3914,16 @@ func (c *Client4) GetFileInfo(ctx context.Context, fileId string) (*FileInfo, *R
 	return DecodeJSONFromResponse[*FileInfo](r)
 }
 
+// DeleteFile permanently deletes an uploaded file that is not attached to a post.
+func (c *Client4) DeleteFile(ctx context.Context, fileId string) (*Response, error) {
+	r, err := c.DoAPIDelete(ctx, c.fileRoute(fileId))
+	if err != nil {
+		return BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return BuildResponse(r), nil
+}
+
 // GetFileInfosForPost gets all the file info objects attached to a post.
 func (c *Client4) GetFileInfosForPost(ctx context.Context, postId string, etag string) ([]*FileInfo, *Response, error) {
 	r, err := c.DoAPIGet(ctx, c.postRoute(postId)+"/files/info", etag)
@@ -5,6 +5,7 @@ package model
 
 import (
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"math"
@@ -228,6 +229,13 @@ const (
 	ElasticsearchSettingsESBackend                          = "elasticsearch"
 	ElasticsearchSettingsOSBackend                          = "opensearch"
 
+	EmbeddedSearchSettingsDefaultIndexDir  = "./data/searchindex"
+	EmbeddedSearchSettingsDefaultBatchSize = 10000
+	EmbeddedSearchAnalyzerStandard         = "standard"
+	EmbeddedSearchAnalyzerVietnamese       = "vi"
+	EmbeddedSearchAnalyzerChineseSimple    = "zh-CN"
+	EmbeddedSearchAnalyzerChineseTrad      = "zh-TW"
+
 	DataRetentionSettingsDefaultMessageRetentionDays           = 365
 	DataRetentionSettingsDefaultMessageRetentionHours          = 0
 	DataRetentionSettingsDefaultFileRetentionDays              = 365
@@ -357,6 +365,7 @@ type ServiceSettings struct {
 	EnableOutgoingOAuthConnections      *bool    `access:"integrations_integration_management"`
 	EnableCommands                      *bool    `access:"integrations_integration_management"`
 	OutgoingIntegrationRequestsTimeout  *int64   `access:"integrations_integration_management"` // In seconds.
+	ForwardClientIPToIntegrations       *bool    `access:"integrations_integration_management"` // Sends the user's IP address to slash commands and action buttons.
 	EnablePostUsernameOverride          *bool    `access:"integrations_integration_management"`
 	EnablePostIconOverride              *bool    `access:"integrations_integration_management"`
 	GoogleDeveloperKey                  *string  `access:"site_posts,write_restrictable,cloud_restrictable"`
@@ -582,6 +591,10 @@ func (s *ServiceSettings) SetDefaults(isUpdate bool) {
 		s.OutgoingIntegrationRequestsTimeout = NewPointer(int64(OutgoingIntegrationRequestsDefaultTimeout))
 	}
 
+	if s.ForwardClientIPToIntegrations == nil {
+		s.ForwardClientIPToIntegrations = NewPointer(false)
+	}
+
 	if s.ConnectionSecurity == nil {
 		s.ConnectionSecurity = NewPointer("")
 	}
@@ -1812,6 +1825,12 @@ type FileSettings struct {
 	AmazonS3PresignExpiresSeconds      *int64  `access:"environment_file_storage,write_restrictable,cloud_restrictable"` // telemetry: none
 	EnablePresignedFileDownloads       *bool   `access:"environment_file_storage,write_restrictable,cloud_restrictable"`
 	EnablePresignedFileUploads         *bool   `access:"environment_file_storage,write_restrictable,cloud_restrictable"`
+	EnableDeduplication                *bool   `access:"environment_file_storage,write_restrictable,cloud_restrictable"`
+	// Encryption at rest settings
+	EnableEncryption       *bool    `access:"environment_file_storage,write_restrictable,cloud_restrictable"`
+	EncryptionKey          *string  `access:"environment_file_storage,write_restrictable,cloud_restrictable"` // telemetry: none
+	EncryptionKeyFile      *string  `access:"environment_file_storage,write_restrictable,cloud_restrictable"` // telemetry: none
+	EncryptionPreviousKeys []string `access:"environment_file_storage,write_restrictable,cloud_restrictable"` // telemetry: none
 	// Export store settings
 	DedicatedExportStore                     *bool   `access:"environment_file_storage,write_restrictable"`
 	ExportDriverName                         *string `access:"environment_file_storage,write_restrictable"`
@@ -1958,6 +1977,26 @@ func (s *FileSettings) SetDefaults(isUpdate bool) {
 		s.EnablePresignedFileUploads = NewPointer(false)
 	}
 
+	if s.EnableDeduplication == nil {
+		s.EnableDeduplication = NewPointer(false)
+	}
+
+	if s.EnableEncryption == nil {
+		s.EnableEncryption = NewPointer(false)
+	}
+
+	if s.EncryptionKey == nil {
+		s.EncryptionKey = NewPointer("")
+	}
+
+	if s.EncryptionKeyFile == nil {
+		s.EncryptionKeyFile = NewPointer("")
+	}
+
+	if s.EncryptionPreviousKeys == nil {
+		s.EncryptionPreviousKeys = []string{}
+	}
+
 	if s.DedicatedExportStore == nil {
 		s.DedicatedExportStore = NewPointer(false)
 	}
@@ -3156,6 +3195,46 @@ func (s *NativeAppSettings) SetDefaults() {
 	}
 }
 
+// EmbeddedSearchSettings configures the search index kept on the server's own disk, an
+// alternative to Elasticsearch that needs no external cluster. The index belongs to one
+// server, so the engine does not start when ClusterSettings.Enable is on. Analyzer picks
+// the language rules used to split and fold text; changing it empties the index on the
+// next start of the engine, and the indexing job must be run again.
+type EmbeddedSearchSettings struct {
+	IndexDir           *string `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"` // telemetry: none
+	EnableIndexing     *bool   `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"`
+	EnableSearching    *bool   `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"`
+	EnableAutocomplete *bool   `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"`
+	BatchSize          *int    `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"`
+	Analyzer           *string `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"`
+}
+
+func (s *EmbeddedSearchSettings) SetDefaults() {
+	if s.IndexDir == nil {
+		s.IndexDir = NewPointer(EmbeddedSearchSettingsDefaultIndexDir)
+	}
+
+	if s.EnableIndexing == nil {
+		s.EnableIndexing = NewPointer(false)
+	}
+
+	if s.EnableSearching == nil {
+		s.EnableSearching = NewPointer(false)
+	}
+
+	if s.EnableAutocomplete == nil {
+		s.EnableAutocomplete = NewPointer(false)
+	}
+
+	if s.BatchSize == nil {
+		s.BatchSize = NewPointer(EmbeddedSearchSettingsDefaultBatchSize)
+	}
+
+	if s.Analyzer == nil {
+		s.Analyzer = NewPointer(EmbeddedSearchAnalyzerStandard)
+	}
+}
+
 type ElasticsearchSettings struct {
 	ConnectionURL                 *string `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"`
 	Backend                       *string `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"`
@@ -4022,6 +4101,7 @@ type Config struct {
 	ExperimentalSettings        ExperimentalSettings
 	AnalyticsSettings           AnalyticsSettings
 	ElasticsearchSettings       ElasticsearchSettings
+	EmbeddedSearchSettings      EmbeddedSearchSettings
 	DataRetentionSettings       DataRetentionSettings
 	MessageExportSettings       MessageExportSettings
 	JobSettings                 JobSettings
@@ -4135,6 +4215,7 @@ func (o *Config) SetDefaults() {
 	o.LocalizationSettings.SetDefaults()
 	o.AutoTranslationSettings.SetDefaults()
 	o.ElasticsearchSettings.SetDefaults()
+	o.EmbeddedSearchSettings.SetDefaults()
 	o.NativeAppSettings.SetDefaults()
 	o.IntuneSettings.SetDefaults()
 	o.DataRetentionSettings.SetDefaults()
@@ -4245,6 +4326,10 @@ func (o *Config) IsValid() *AppError {
 		return appErr
 	}
 
+	if appErr := o.EmbeddedSearchSettings.isValid(); appErr != nil {
+		return appErr
+	}
+
 	if appErr := o.DataRetentionSettings.isValid(); appErr != nil {
 		return appErr
 	}
@@ -4450,6 +4535,19 @@ func (s *FileSettings) isValid() *AppError {
 		return NewAppError("Config.IsValid", "model.config.is_valid.directory_whitespace.app_error", map[string]any{"Setting": "FileSettings.ExportDirectory", "Value": *s.ExportDirectory}, "", http.StatusBadRequest)
 	}
 
+	if *s.EnableEncryption && *s.EncryptionKey == "" && *s.EncryptionKeyFile == "" {
+		return NewAppError("Config.IsValid", "model.config.is_valid.file_encryption_key_missing.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	for _, key := range append([]string{*s.EncryptionKey}, s.EncryptionPreviousKeys...) {
+		if key == "" {
+			continue
+		}
+		if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 32 {
+			return NewAppError("Config.IsValid", "model.config.is_valid.file_encryption_key.app_error", nil, "", http.StatusBadRequest)
+		}
+	}
+
 	return nil
 }
 
@@ -4819,6 +4917,38 @@ func (s *ElasticsearchSettings) isValid() *AppError {
 	return nil
 }
 
+func (s *EmbeddedSearchSettings) isValid() *AppError {
+	if *s.EnableIndexing && *s.IndexDir == "" {
+		return NewAppError("Config.IsValid", "model.config.is_valid.embedded_search.index_dir.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if *s.EnableSearching && !*s.EnableIndexing {
+		return NewAppError("Config.IsValid", "model.config.is_valid.embedded_search.enable_searching.app_error", map[string]any{
+			"Searching":      "EmbeddedSearchSettings.EnableSearching",
+			"EnableIndexing": "EmbeddedSearchSettings.EnableIndexing",
+		}, "", http.StatusBadRequest)
+	}
+
+	if *s.EnableAutocomplete && !*s.EnableIndexing {
+		return NewAppError("Config.IsValid", "model.config.is_valid.embedded_search.enable_autocomplete.app_error", map[string]any{
+			"Autocomplete":   "EmbeddedSearchSettings.EnableAutocomplete",
+			"EnableIndexing": "EmbeddedSearchSettings.EnableIndexing",
+		}, "", http.StatusBadRequest)
+	}
+
+	if *s.BatchSize < 1 {
+		return NewAppError("Config.IsValid", "model.config.is_valid.embedded_search.batch_size.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	switch *s.Analyzer {
+	case EmbeddedSearchAnalyzerStandard, EmbeddedSearchAnalyzerVietnamese, EmbeddedSearchAnalyzerChineseSimple, EmbeddedSearchAnalyzerChineseTrad:
+	default:
+		return NewAppError("Config.IsValid", "model.config.is_valid.embedded_search.analyzer.app_error", map[string]any{"Analyzer": *s.Analyzer}, "", http.StatusBadRequest)
+	}
+
+	return nil
+}
+
 func (s *DataRetentionSettings) isValid() *AppError {
 	if s.MessageRetentionDays == nil || *s.MessageRetentionDays < 0 {
 		return NewAppError("Config.IsValid", "model.config.is_valid.data_retention.message_retention_days_too_low.app_error", nil, "", http.StatusBadRequest)
@@ -5041,6 +5171,14 @@ func (o *Config) Sanitize(pluginManifests []*Manifest, opts *SanitizeOptions) {
 		*o.FileSettings.AmazonS3SecretAccessKey = FakeSetting
 	}
 
+	if o.FileSettings.EncryptionKey != nil && *o.FileSettings.EncryptionKey != "" {
+		*o.FileSettings.EncryptionKey = FakeSetting
+	}
+
+	for i := range o.FileSettings.EncryptionPreviousKeys {
+		o.FileSettings.EncryptionPreviousKeys[i] = FakeSetting
+	}
+
 	if o.EmailSettings.SMTPPassword != nil && *o.EmailSettings.SMTPPassword != "" {
 		*o.EmailSettings.SMTPPassword = FakeSetting
 	}
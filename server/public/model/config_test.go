@@ -4,6 +4,7 @@
 package model
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"maps"
@@ -208,6 +209,64 @@ func TestConfigDefaultFileSettingsS3SSE(t *testing.T) {
 	require.False(t, *c1.FileSettings.AmazonS3SSE)
 }
 
+func TestFileSettingsEncryptionValidation(t *testing.T) {
+	validKey := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	for name, tc := range map[string]struct {
+		configure func(s *FileSettings)
+		errorId   string
+	}{
+		"disabled": {
+			configure: func(s *FileSettings) {},
+		},
+		"enabled with a key": {
+			configure: func(s *FileSettings) {
+				s.EnableEncryption = NewPointer(true)
+				s.EncryptionKey = NewPointer(validKey)
+				s.EncryptionPreviousKeys = []string{validKey}
+			},
+		},
+		"enabled with a key file": {
+			configure: func(s *FileSettings) {
+				s.EnableEncryption = NewPointer(true)
+				s.EncryptionKeyFile = NewPointer("/etc/mattermost/file-keys")
+			},
+		},
+		"enabled without a key": {
+			configure: func(s *FileSettings) {
+				s.EnableEncryption = NewPointer(true)
+			},
+			errorId: "model.config.is_valid.file_encryption_key_missing.app_error",
+		},
+		"short key": {
+			configure: func(s *FileSettings) {
+				s.EncryptionKey = NewPointer(base64.StdEncoding.EncodeToString(make([]byte, 16)))
+			},
+			errorId: "model.config.is_valid.file_encryption_key.app_error",
+		},
+		"previous key not base64": {
+			configure: func(s *FileSettings) {
+				s.EncryptionPreviousKeys = []string{"not base64!"}
+			},
+			errorId: "model.config.is_valid.file_encryption_key.app_error",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.SetDefaults()
+			tc.configure(&cfg.FileSettings)
+
+			appErr := cfg.FileSettings.isValid()
+			if tc.errorId == "" {
+				require.Nil(t, appErr)
+			} else {
+				require.NotNil(t, appErr)
+				assert.Equal(t, tc.errorId, appErr.Id)
+			}
+		})
+	}
+}
+
 func TestFileSettingsDirectoryWhitespaceValidation(t *testing.T) {
 	// Define Unicode whitespace characters to test
 	unicodeWhitespaces := []struct {
@@ -1580,6 +1639,8 @@ func TestConfigSanitize(t *testing.T) {
 
 	*c.LdapSettings.BindPassword = "foo"
 	*c.FileSettings.AmazonS3SecretAccessKey = "bar"
+	*c.FileSettings.EncryptionKey = "key"
+	c.FileSettings.EncryptionPreviousKeys = []string{"previous"}
 	*c.EmailSettings.SMTPPassword = "baz"
 	*c.GitLabSettings.Secret = "bingo"
 	*c.OpenIdSettings.Secret = "secret"
@@ -1596,6 +1657,8 @@ func TestConfigSanitize(t *testing.T) {
 	assert.Equal(t, FakeSetting, *c.LdapSettings.BindPassword)
 	assert.Equal(t, FakeSetting, *c.FileSettings.PublicLinkSalt)
 	assert.Equal(t, FakeSetting, *c.FileSettings.AmazonS3SecretAccessKey)
+	assert.Equal(t, FakeSetting, *c.FileSettings.EncryptionKey)
+	assert.Equal(t, []string{FakeSetting}, c.FileSettings.EncryptionPreviousKeys)
 	assert.Equal(t, FakeSetting, *c.EmailSettings.SMTPPassword)
 	assert.Equal(t, FakeSetting, *c.GitLabSettings.Secret)
 	assert.Equal(t, FakeSetting, *c.OpenIdSettings.Secret)
@@ -0,0 +1,25 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+// FileBlob is a file stored once by the hash of its contents, and shared by every path holding
+// the same contents. Blobs no longer referenced by any path are deleted by the file blob cleanup
+// job.
+type FileBlob struct {
+	Id       string `json:"id"`
+	Hash     string `json:"hash"`
+	Size     int64  `json:"size"`
+	RefCount int64  `json:"ref_count"`
+	CreateAt int64  `json:"create_at"`
+	UpdateAt int64  `json:"update_at"`
+}
+
+// FileBlobReference points a path of the file store at the blob holding its contents.
+type FileBlobReference struct {
+	Path     string `json:"path"`
+	BlobId   string `json:"blob_id"`
+	Hash     string `json:"hash"`
+	Size     int64  `json:"size"`
+	CreateAt int64  `json:"create_at"`
+}
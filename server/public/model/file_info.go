@@ -46,6 +46,7 @@ type FileInfo struct {
 	Path            string  `json:"-"` // not sent back to the client
 	ThumbnailPath   string  `json:"-"` // not sent back to the client
 	PreviewPath     string  `json:"-"` // not sent back to the client
+	BlobId          string  `json:"-"` // the deduplicated blob storing the file, if any; not sent back to the client
 	Name            string  `json:"name"`
 	Extension       string  `json:"extension"`
 	Size            int64   `json:"size"`
@@ -46,6 +46,11 @@ const (
 	JobTypeAccessControlSync             = "access_control_sync"
 	JobTypePushProxyAuth                 = "push_proxy_auth"
 	JobTypeDeleteExpiredPosts            = "delete_expired_posts"
+	JobTypeEmbeddedSearchIndexing        = "embedded_search_indexing"
+	JobTypeEmbeddedSearchPurge           = "embedded_search_purge"
+	JobTypeFileReencryption              = "file_reencryption"
+	JobTypeFileDedup                     = "file_dedup"
+	JobTypeCleanupFileBlobs              = "cleanup_file_blobs"
 
 	JobStatusPending         = "pending"
 	JobStatusInProgress      = "in_progress"
@@ -78,6 +83,11 @@ var AllJobTypes = [...]string{
 	JobTypeCleanupDesktopTokens,
 	JobTypeRefreshMaterializedViews,
 	JobTypeMobileSessionMetadata,
+	JobTypeEmbeddedSearchIndexing,
+	JobTypeEmbeddedSearchPurge,
+	JobTypeFileReencryption,
+	JobTypeFileDedup,
+	JobTypeCleanupFileBlobs,
 }
 
 type Job struct {
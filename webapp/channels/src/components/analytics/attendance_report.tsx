@@ -34,7 +34,17 @@ type AttendanceStats = {
     pending_requests: number;
 };
 
-type BreakLog = { reason: string; start: number; start_device?: string; end?: number; end_device?: string };
+type BreakLog = { reason: string; start: number; start_device?: string; end?: number; end_device?: string; exceeded?: string[] };
+
+type BreakReason = {
+    id: string;
+    labels?: Record<string, string>;
+    max_per_day?: number;
+    max_minutes?: number;
+    max_daily_minutes?: number;
+};
+
+type BreakStats = { count: number; minutes: number; exceeded?: number };
 
 type AttendanceEntry = {
     date: string;
@@ -46,11 +56,7 @@ type AttendanceEntry = {
     checkout_image_id?: string;
     status: string;
     total_breaks: number;
-    break_rest: number;
-    break_eat: number;
-    break_restroom_s: number;
-    break_restroom_l: number;
-    break_smoke: number;
+    break_stats: Record<string, BreakStats>;
     breaks?: BreakLog[];
 };
 
@@ -60,6 +66,7 @@ type LeaveEntry = {
     reason: string;
     expected_time?: string;
     status: string;
+    attachments?: Array<{file_id: string; name: string}>;
 };
 
 type UserReport = {
@@ -70,11 +77,7 @@ type UserReport = {
     days_leave: number;
     late_arrivals: number;
     early_departures: number;
-    break_rest: number;
-    break_eat: number;
-    break_restroom_s: number;
-    break_restroom_l: number;
-    break_smoke: number;
+    break_stats: Record<string, BreakStats>;
     attendance: AttendanceEntry[];
     leave_requests: LeaveEntry[];
 };
@@ -82,6 +85,7 @@ type UserReport = {
 type AttendanceReport = {
     from: string;
     to: string;
+    break_reasons: BreakReason[];
     users: Omit<UserReport, 'id'>[];
 };
 
@@ -103,6 +107,7 @@ const messages = defineMessages({
     type: { id: 'analytics.attendance.type', defaultMessage: 'Type' },
     dates: { id: 'analytics.attendance.dates', defaultMessage: 'Dates' },
     reason: { id: 'analytics.attendance.reason', defaultMessage: 'Reason' },
+    attachments: { id: 'analytics.attendance.attachments', defaultMessage: 'Documents' },
     totalBreaks: { id: 'analytics.attendance.totalBreaks', defaultMessage: 'Total Breaks' },
     breakRest: { id: 'analytics.attendance.breakRest', defaultMessage: 'Rest' },
     breakEat: { id: 'analytics.attendance.breakEat', defaultMessage: 'Lunch' },
@@ -117,6 +122,7 @@ const messages = defineMessages({
     breakSmokeCol: {id: 'analytics.attendance.breakSmokeCol', defaultMessage: 'Smoking'},
     breaks: {id: 'analytics.attendance.breaks', defaultMessage: 'Breaks'},
     onBreak: {id: 'analytics.attendance.onBreak', defaultMessage: 'on break'},
+    breakExceeded: {id: 'analytics.attendance.breakExceeded', defaultMessage: 'Over the break limit'},
     back: { id: 'analytics.attendance.back', defaultMessage: 'Back to all users' },
     attendanceDetail: { id: 'analytics.attendance.attendanceDetail', defaultMessage: 'Attendance' },
     leaveDetail: { id: 'analytics.attendance.leaveDetail', defaultMessage: 'Leave Requests' },
@@ -184,19 +190,39 @@ const statusBadge = (status: string) => {
 // User detail panel
 type UserDetailPanelProps = {
     user: UserReport;
+    breakReasons: BreakReason[];
     filterMode: 'month' | 'date';
     selectedMonth: string;
     selectedDay: number;
 }
 
-const BREAK_REASON_META: Record<string, { icon: string; msgKey: keyof typeof messages }> = {
-    nghi_ngoi: {icon: 'fa-coffee', msgKey: 'breakRest'},
-    di_an: {icon: 'fa-cutlery', msgKey: 'breakEat'},
-    tieu_tien: {icon: 'fa-tint', msgKey: 'breakRestroomS'},
-    dai_tien: {icon: 'fa-tint', msgKey: 'breakRestroomL'},
-    hut_thuoc: {icon: 'fa-fire', msgKey: 'breakSmoke'},
+// Icons and labels of the built-in break reasons; custom reasons come with their own labels.
+const BREAK_REASON_META: Record<string, { icon: string; msgKey: keyof typeof messages; colKey: keyof typeof messages }> = {
+    nghi_ngoi: {icon: 'fa-coffee', msgKey: 'breakRest', colKey: 'breakRestCol'},
+    di_an: {icon: 'fa-cutlery', msgKey: 'breakEat', colKey: 'breakEatCol'},
+    tieu_tien: {icon: 'fa-tint', msgKey: 'breakRestroomS', colKey: 'breakRestroomSCol'},
+    dai_tien: {icon: 'fa-tint', msgKey: 'breakRestroomL', colKey: 'breakRestroomLCol'},
+    hut_thuoc: {icon: 'fa-fire', msgKey: 'breakSmoke', colKey: 'breakSmokeCol'},
 };
 
+function findBreakReason(reasons: BreakReason[], id: string): BreakReason {
+    return reasons.find((r) => r.id === id) ?? {id};
+}
+
+// breakReasonLabel labels a break reason in the given locale, using the short column label
+// of a built-in reason when column is set.
+function breakReasonLabel(reason: BreakReason, fmt: FormatMessage, locale: string, column = false): string {
+    const label = reason.labels?.[locale] || reason.labels?.en;
+    if (label) {
+        return label;
+    }
+    const meta = BREAK_REASON_META[reason.id];
+    if (meta) {
+        return fmt(messages[column ? meta.colKey : meta.msgKey]);
+    }
+    return reason.id;
+}
+
 function fmtTime(unix: number): string {
     return new Date(unix * 1000).toLocaleTimeString('vi-VN', {hour: '2-digit', minute: '2-digit', second: '2-digit'});
 }
@@ -227,7 +253,7 @@ function autoColWidths(rows: Array<Array<string | number>>): Array<{wch: number}
     return widths.map((w) => ({wch: Math.min(Math.ceil(w) + 2, 50)}));
 }
 
-function exportToExcel(users: UserReport[], from: string, to: string, fmt: FormatMessage) {
+function exportToExcel(users: UserReport[], breakReasons: BreakReason[], from: string, to: string, fmt: FormatMessage, locale: string) {
     const wb = XLSX.utils.book_new();
 
     // Sheet 1: Tổng hợp
@@ -235,13 +261,12 @@ function exportToExcel(users: UserReport[], from: string, to: string, fmt: Forma
         [
             fmt(messages.username), fmt(messages.daysWorked), fmt(messages.daysLeave),
             fmt(messages.lateArrivals), fmt(messages.earlyDepartures),
-            fmt(messages.breakRestCol), fmt(messages.breakEatCol),
-            fmt(messages.breakRestroomSCol), fmt(messages.breakRestroomLCol), fmt(messages.breakSmokeCol),
+            ...breakReasons.map((reason) => breakReasonLabel(reason, fmt, locale, true)),
         ],
         ...users.map((u) => [
             u.username, u.days_worked, u.days_leave,
             u.late_arrivals, u.early_departures,
-            u.break_rest, u.break_eat, u.break_restroom_s, u.break_restroom_l, u.break_smoke,
+            ...breakReasons.map((reason) => u.break_stats?.[reason.id]?.count ?? 0),
         ]),
     ];
     const ws1 = XLSX.utils.aoa_to_sheet(sheet1Rows);
@@ -259,8 +284,7 @@ function exportToExcel(users: UserReport[], from: string, to: string, fmt: Forma
                 detailRows.push([...base, '', '', '', '', '']);
             } else {
                 for (const b of e.breaks) {
-                    const meta = BREAK_REASON_META[b.reason];
-                    const reasonLabel = meta ? fmt(messages[meta.msgKey]) : b.reason;
+                    const reasonLabel = breakReasonLabel(findBreakReason(breakReasons, b.reason), fmt, locale);
                     const duration = b.end ? formatBreakDuration(calcBreakDuration(b.start, b.end)) : '';
                     const breakDevice = [b.start_device ?? '', b.end_device ?? ''].filter(Boolean).join(' → ') || '';
                     detailRows.push([...base, reasonLabel, fmtTime(b.start), b.end ? fmtTime(b.end) : '', duration, breakDevice]);
@@ -283,8 +307,8 @@ function exportToExcel(users: UserReport[], from: string, to: string, fmt: Forma
     XLSX.writeFile(wb, `attendance_${from}_${to}.xlsx`);
 }
 
-const BreakLogList: React.FC<{ entry: AttendanceEntry }> = ({entry}) => {
-    const {formatMessage} = useIntl();
+const BreakLogList: React.FC<{ entry: AttendanceEntry; breakReasons: BreakReason[] }> = ({entry, breakReasons}) => {
+    const {formatMessage, locale} = useIntl();
     const logs = entry.breaks ?? [];
     if (logs.length === 0) {
         return null;
@@ -309,7 +333,7 @@ const BreakLogList: React.FC<{ entry: AttendanceEntry }> = ({entry}) => {
                 <tbody>
                     {logs.map((b) => {
                         const meta = BREAK_REASON_META[b.reason];
-                        const label = meta ? formatMessage(messages[meta.msgKey]) : b.reason;
+                        const label = breakReasonLabel(findBreakReason(breakReasons, b.reason), formatMessage, locale);
                         const icon = meta ? meta.icon : 'fa-clock-o';
                         const duration = b.end ? formatBreakDuration(calcBreakDuration(b.start, b.end)) : null;
                         return (
@@ -320,6 +344,16 @@ const BreakLogList: React.FC<{ entry: AttendanceEntry }> = ({entry}) => {
                                 </td>
                                 <td style={{paddingRight: '12px'}}>
                                     <i className={`fa ${icon}`}/>{' '}{label}
+                                    {b.exceeded && b.exceeded.length > 0 && (
+                                        <>
+                                            {' '}
+                                            <i
+                                                className='fa fa-exclamation-triangle'
+                                                style={{color: '#d24b4e'}}
+                                                title={formatMessage(messages.breakExceeded)}
+                                            />
+                                        </>
+                                    )}
                                 </td>
                                 <td style={{paddingRight: '12px'}}>{duration === null ? '-' : duration}</td>
                                 <td style={{fontSize: '11px'}}>{b.start_device || b.end_device ? `${b.start_device || '—'} → ${b.end_device || '—'}` : '—'}</td>
@@ -332,7 +366,7 @@ const BreakLogList: React.FC<{ entry: AttendanceEntry }> = ({entry}) => {
     );
 };
 
-const UserDetailPanel: React.FC<UserDetailPanelProps> = ({user, filterMode, selectedMonth, selectedDay}) => {
+const UserDetailPanel: React.FC<UserDetailPanelProps> = ({user, breakReasons, filterMode, selectedMonth, selectedDay}) => {
     // Build full day list for month mode
     const allDays = useMemo(() => {
         if (filterMode !== 'month') {
@@ -422,7 +456,10 @@ const UserDetailPanel: React.FC<UserDetailPanelProps> = ({user, filterMode, sele
                                 <span className='attendance-day-card__label'><FormattedMessage {...messages.status} /></span>
                                 <span className='attendance-day-card__value'>{statusBadge(singleEntry.status)}</span>
                             </div>
-                            <BreakLogList entry={singleEntry}/>
+                            <BreakLogList
+                                entry={singleEntry}
+                                breakReasons={breakReasons}
+                            />
                         </div>
                     ) : (
                         <div className='attendance-day-card attendance-day-card--empty'>
@@ -481,7 +518,10 @@ const UserDetailPanel: React.FC<UserDetailPanelProps> = ({user, filterMode, sele
                                         <span className='attendance-day-card__label'><FormattedMessage {...messages.status} /></span>
                                         <span className='attendance-day-card__value'>{statusBadge(entry.status)}</span>
                                     </div>
-                                    <BreakLogList entry={entry}/>
+                                    <BreakLogList
+                                        entry={entry}
+                                        breakReasons={breakReasons}
+                                    />
                                 </div>
                             ) : (
                                 <div
@@ -508,6 +548,7 @@ const UserDetailPanel: React.FC<UserDetailPanelProps> = ({user, filterMode, sele
                                     <th><FormattedMessage {...messages.type} /></th>
                                     <th><FormattedMessage {...messages.dates} /></th>
                                     <th><FormattedMessage {...messages.reason} /></th>
+                                    <th><FormattedMessage {...messages.attachments} /></th>
                                     <th><FormattedMessage {...messages.status} /></th>
                                 </tr>
                             </thead>
@@ -517,6 +558,19 @@ const UserDetailPanel: React.FC<UserDetailPanelProps> = ({user, filterMode, sele
                                         <td>{req.type}</td>
                                         <td>{req.dates.join(', ')}</td>
                                         <td>{req.reason}</td>
+                                        <td>
+                                            {req.attachments?.map((a) => (
+                                                <div key={a.file_id}>
+                                                    <a
+                                                        href={`/api/v4/files/${a.file_id}`}
+                                                        target='_blank'
+                                                        rel='noopener noreferrer'
+                                                    >
+                                                        {a.name}
+                                                    </a>
+                                                </div>
+                                            ))}
+                                        </td>
                                         <td>{statusBadge(req.status)}</td>
                                     </tr>
                                 ))}
@@ -530,7 +584,7 @@ const UserDetailPanel: React.FC<UserDetailPanelProps> = ({user, filterMode, sele
 };
 
 const AttendanceReportPage: React.FC = () => {
-    const { formatMessage } = useIntl();
+    const { formatMessage, locale } = useIntl();
     const [selectedMonth, setSelectedMonth] = useState(() => {
         const now = new Date();
         return now.toISOString().slice(0, 7); // YYYY-MM
@@ -602,7 +656,7 @@ const AttendanceReportPage: React.FC = () => {
             to = from;
         }
 
-        const base = Client4.getBaseRoute();
+        const base = `${Client4.getUrl()}/plugins/com.oktel.bot/api`;
         let query = `from=${from}&to=${to}`;
 
         if (filterTeam && filterTeam !== 'teams_filter_for_all_teams') {
@@ -615,8 +669,8 @@ const AttendanceReportPage: React.FC = () => {
 
         try {
             const [statsRes, reportRes] = await Promise.all([
-                apiFetch(`${base}/bot-service/attendance/stats?${query}`),
-                apiFetch(`${base}/bot-service/attendance/report?${query}`),
+                apiFetch(`${base}/attendance/stats?${query}`),
+                apiFetch(`${base}/attendance/report?${query}`),
             ]);
 
             if (!statsRes.ok) {
@@ -698,42 +752,27 @@ const AttendanceReportPage: React.FC = () => {
             cell: (info) => info.getValue(),
             enablePinning: false,
         },
-        {
-            id: 'break_rest',
-            accessorKey: 'break_rest',
-            header: formatMessage(messages.breakRestCol),
-            cell: (info) => info.getValue(),
-            enablePinning: false,
-        },
-        {
-            id: 'break_eat',
-            accessorKey: 'break_eat',
-            header: formatMessage(messages.breakEatCol),
-            cell: (info) => info.getValue(),
-            enablePinning: false,
-        },
-        {
-            id: 'break_restroom_s',
-            accessorKey: 'break_restroom_s',
-            header: formatMessage(messages.breakRestroomSCol),
-            cell: (info) => info.getValue(),
-            enablePinning: false,
-        },
-        {
-            id: 'break_restroom_l',
-            accessorKey: 'break_restroom_l',
-            header: formatMessage(messages.breakRestroomLCol),
-            cell: (info) => info.getValue(),
-            enablePinning: false,
-        },
-        {
-            id: 'break_smoke',
-            accessorKey: 'break_smoke',
-            header: formatMessage(messages.breakSmokeCol),
-            cell: (info) => info.getValue(),
+        ...(report?.break_reasons ?? []).map((reason): ColumnDef<UserReport, any> => ({
+            id: `break_${reason.id}`,
+            accessorFn: (u: UserReport) => u.break_stats?.[reason.id]?.count ?? 0,
+            header: breakReasonLabel(reason, formatMessage, locale, true),
+            cell: (info) => {
+                const exceeded = info.row.original.break_stats?.[reason.id]?.exceeded ?? 0;
+                return (
+                    <span title={exceeded > 0 ? formatMessage(messages.breakExceeded) : undefined}>
+                        {info.getValue()}
+                        {exceeded > 0 && (
+                            <i
+                                className='fa fa-exclamation-triangle'
+                                style={{color: '#d24b4e', marginLeft: '4px'}}
+                            />
+                        )}
+                    </span>
+                );
+            },
             enablePinning: false,
-        },
-    ], [formatMessage]);
+        })),
+    ], [formatMessage, locale, report?.break_reasons]);
 
     // Table instance
     const table = useReactTable({
@@ -806,6 +845,7 @@ const AttendanceReportPage: React.FC = () => {
                     {selectedUser ? (
                         <UserDetailPanel
                             user={selectedUser}
+                            breakReasons={report?.break_reasons ?? []}
                             filterMode={filterMode}
                             selectedMonth={selectedMonth}
                             selectedDay={selectedDay}
@@ -881,7 +921,7 @@ const AttendanceReportPage: React.FC = () => {
                                     <button
                                         className='btn btn-default'
                                         disabled={filteredUsers.length === 0}
-                                        onClick={() => exportToExcel(filteredUsers, dateRange.from, dateRange.to, formatMessage)}
+                                        onClick={() => exportToExcel(filteredUsers, report?.break_reasons ?? [], dateRange.from, dateRange.to, formatMessage, locale)}
                                     >
                                         <i className='fa fa-download'/>
                                         {' Export Excel'}
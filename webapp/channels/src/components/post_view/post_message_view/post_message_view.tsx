@@ -96,6 +96,10 @@ class PostMessageView extends React.PureComponent<Props, State> {
             delete formattedData.FileID;
             const mention = formattedData.Mention;
             delete formattedData.Mention;
+            const attachments = Array.isArray(formattedData.Attachments) ? formattedData.Attachments as Array<{FileID: string; Name: string}> : [];
+            delete formattedData.Attachments;
+            const compTimeHours = typeof formattedData.CompTimeHours === 'number' ? formattedData.CompTimeHours as number : 0;
+            delete formattedData.CompTimeHours;
 
             if (formattedData.Time && typeof formattedData.Time === 'number') {
                 const timeDate = new Date(formattedData.Time * 1000);
@@ -118,12 +122,11 @@ class PostMessageView extends React.PureComponent<Props, State> {
                 );
             }
 
-            // Pre-translate break reason keys (e.g. "di_an" → "Đi ăn")
+            // Pre-translate break reason keys (e.g. "di_an" → "Đi ăn"); custom reasons carry their own labels
             if (formattedData.Reason && typeof formattedData.Reason === 'string' &&
                 messageKey.startsWith('attendance.msg.break_')) {
-                formattedData.Reason = this.props.intl.formatMessage(
-                    {id: `attendance.break_reason.${formattedData.Reason}`, defaultMessage: formattedData.Reason},
-                );
+                formattedData.Reason = this.breakReasonLabel(formattedData.Reason, formattedData.ReasonLabels as Record<string, string> | undefined);
+                delete formattedData.ReasonLabels;
             }
 
             // Format duration fields from raw seconds to localized strings
@@ -136,11 +139,9 @@ class PostMessageView extends React.PureComponent<Props, State> {
 
             // Build BreakList from structured Breaks array
             if (Array.isArray(formattedData.Breaks)) {
-                const lines = (formattedData.Breaks as Array<{Reason: string; Duration: number}>).map(
+                const lines = (formattedData.Breaks as Array<{Reason: string; ReasonLabels?: Record<string, string>; Duration: number}>).map(
                     (b, idx) => {
-                        const reason = this.props.intl.formatMessage(
-                            {id: `attendance.break_reason.${b.Reason}`, defaultMessage: b.Reason},
-                        );
+                        const reason = this.breakReasonLabel(b.Reason, b.ReasonLabels);
                         const dur = this.formatDuration(b.Duration);
                         return `${idx + 1}. ${reason} — ${dur}`;
                     },
@@ -160,6 +161,16 @@ class PostMessageView extends React.PureComponent<Props, State> {
                 if (fileID) {
                     translated += `\n\n![photo](/api/v4/files/${fileID}/preview)`;
                 }
+                if (compTimeHours > 0) {
+                    const label = this.props.intl.formatMessage({id: 'leave.comp_time', defaultMessage: 'Paid from comp time'});
+                    const hours = this.props.intl.formatNumber(compTimeHours, {maximumFractionDigits: 2});
+                    translated += `\n\n**${label}:** ${hours} h`;
+                }
+                if (attachments.length > 0) {
+                    const label = this.props.intl.formatMessage({id: 'leave.attachments', defaultMessage: 'Attachments'});
+                    const links = attachments.map((a) => `[${a.Name.replace(/[[\]]/g, '')}](/api/v4/files/${a.FileID})`);
+                    translated += `\n\n**${label}:** ${links.join(', ')}`;
+                }
                 return translated;
             } catch (e) {
                 // If translation fails, fall back to original message
@@ -170,6 +181,18 @@ class PostMessageView extends React.PureComponent<Props, State> {
         return post.message;
     }
 
+    // breakReasonLabel labels a break reason: a custom reason's label in the user's locale
+    // (or English), else the built-in translation, else the ID.
+    private breakReasonLabel(reason: string, labels?: Record<string, string>): string {
+        const label = labels?.[this.props.intl.locale] || labels?.en;
+        if (label) {
+            return label;
+        }
+        return this.props.intl.formatMessage(
+            {id: `attendance.break_reason.${reason}`, defaultMessage: reason},
+        );
+    }
+
     private formatDuration(totalSeconds: number): string {
         const h = Math.floor(totalSeconds / 3600);
         const m = Math.floor((totalSeconds % 3600) / 60);